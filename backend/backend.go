@@ -0,0 +1,230 @@
+// Package backend is the integration point for handlers which call remote
+// services, such as a schema registry: it carries per-session credentials
+// taken from initializationOptions, applies timeout and retry defaults, and
+// turns remote failures into something the editor can show.
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+const (
+	DefaultTimeout = 10 * time.Second
+	DefaultRetries = 2
+	DefaultBackoff = 200 * time.Millisecond
+)
+
+// Config is a service's entry in initializationOptions, e.g.
+//
+//	{"registry": {"endpoint": "https://registry.example.com", "token": "..."}}
+type Config struct {
+	Endpoint string            `json:"endpoint"`
+	Token    string            `json:"token,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+// ConfigFromInitializationOptions decodes the named entry from the raw
+// initializationOptions value. A missing entry is not an error; the returned
+// Config is then empty and Configured reports false.
+func ConfigFromInitializationOptions(options json.RawMessage, key string) (Config, error) {
+	var cfg Config
+	if len(options) == 0 || string(options) == "null" {
+		return cfg, nil
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(options, &all); err != nil {
+		return cfg, fmt.Errorf("initializationOptions: %w", err)
+	}
+	raw, ok := all[key]
+	if !ok {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("initializationOptions.%s: %w", key, err)
+	}
+	return cfg, nil
+}
+
+// Configured reports whether an endpoint was supplied.
+func (c Config) Configured() bool {
+	return c.Endpoint != ""
+}
+
+// Client calls one remote service for one session.
+type Client struct {
+	// Name identifies the service in errors shown to the user, and the
+	// initializationOptions key the credentials came from.
+	Name   string
+	Config Config
+
+	HTTP *http.Client
+
+	// Timeout bounds each attempt, DefaultTimeout when zero.
+	Timeout time.Duration
+
+	// Retries is the number of additional attempts for idempotent requests
+	// which fail transiently. Negative disables retries; zero means
+	// DefaultRetries.
+	Retries int
+
+	// Backoff is the delay before the first retry, doubling each time.
+	Backoff time.Duration
+
+	// Clock times attempts and backoff, clock.Real when nil.
+	Clock clock.Clock
+}
+
+// NewClient builds a client for the named initializationOptions entry.
+func NewClient(name string, options json.RawMessage) (*Client, error) {
+	cfg, err := ConfigFromInitializationOptions(options, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Name: name, Config: cfg}, nil
+}
+
+// JSON sends body (when non-nil) as JSON and decodes a successful response
+// into out (when non-nil). path is joined to the configured endpoint.
+func (c *Client) JSON(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	res, err := c.Do(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(res) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(res, out); err != nil {
+		return &RemoteError{Service: c.Name, Method: method, Path: path, Err: fmt.Errorf("decoding response: %w", err)}
+	}
+	return nil
+}
+
+// Do performs the request with credentials, the caller's trace context and
+// the retry policy, returning the response body of a 2xx response. Any
+// failure is a *RemoteError.
+func (c *Client) Do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	if !c.Config.Configured() {
+		return nil, &RemoteError{Service: c.Name, Method: method, Path: path, Err: ErrNotConfigured}
+	}
+
+	retries := c.Retries
+	if retries == 0 {
+		retries = DefaultRetries
+	}
+	if retries < 0 || !idempotent(method) {
+		retries = 0
+	}
+	backoff := c.Backoff
+	if backoff == 0 {
+		backoff = DefaultBackoff
+	}
+
+	var last *RemoteError
+	for attempt := 0; ; attempt++ {
+		res, err := c.attempt(ctx, method, path, body)
+		if err == nil {
+			return res, nil
+		}
+		last = err
+		if !err.Retryable || attempt >= retries {
+			return nil, last
+		}
+		if clock.Sleep(ctx, c.Clock, backoff<<attempt) != nil {
+			return nil, last
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, body []byte) ([]byte, *RemoteError) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := clock.WithTimeout(ctx, c.Clock, timeout)
+	defer cancel()
+
+	remoteErr := func(status int, retryable bool, err error) *RemoteError {
+		return &RemoteError{Service: c.Name, Method: method, Path: path, Status: status, Retryable: retryable, Err: err}
+	}
+
+	url := strings.TrimRight(c.Config.Endpoint, "/") + "/" + strings.TrimLeft(path, "/")
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, remoteErr(0, false, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Config.Token)
+	}
+	for name, val := range c.Config.Headers {
+		req.Header.Set(name, val)
+	}
+	if tc, ok := jsonrpc2.Trace(ctx); ok {
+		req.Header.Set("traceparent", tc.TraceParent())
+		if tc.State != "" {
+			req.Header.Set("tracestate", tc.State)
+		}
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		retryable := errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+		if ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			retryable = false
+		}
+		return nil, remoteErr(0, retryable, err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, 8<<20))
+	if err != nil {
+		return nil, remoteErr(res.StatusCode, true, err)
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return data, nil
+	}
+	retryable := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	rErr := remoteErr(res.StatusCode, retryable, errors.New(http.StatusText(res.StatusCode)))
+	rErr.Body = strings.TrimSpace(string(data))
+	return nil, rErr
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
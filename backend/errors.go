@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// ErrNotConfigured is wrapped by calls to a service which has no endpoint in
+// initializationOptions.
+var ErrNotConfigured = errors.New("service is not configured")
+
+// RemoteError describes a failed call to a remote service.
+type RemoteError struct {
+	Service   string
+	Method    string
+	Path      string
+	Status    int
+	Body      string
+	Retryable bool
+	Err       error
+}
+
+func (e *RemoteError) Error() string {
+	msg := fmt.Sprintf("%s: %s %s", e.Service, e.Method, e.Path)
+	if e.Status != 0 {
+		msg += fmt.Sprintf(": %d", e.Status)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *RemoteError) Unwrap() error {
+	return e.Err
+}
+
+// Hint says what the user can do about the failure, phrased for the editor.
+func (e *RemoteError) Hint() string {
+	switch {
+	case errors.Is(e.Err, ErrNotConfigured):
+		return fmt.Sprintf("set initializationOptions.%s.endpoint in the editor's language server settings", e.Service)
+	case e.Status == http.StatusUnauthorized:
+		return fmt.Sprintf("check initializationOptions.%s.token; the service rejected the credentials", e.Service)
+	case e.Status == http.StatusForbidden:
+		return "the configured credentials do not have access to this resource"
+	case e.Status == http.StatusNotFound:
+		return "the resource does not exist on the service"
+	case e.Status == http.StatusTooManyRequests:
+		return "the service is rate limiting requests; try again shortly"
+	case e.Status >= 500 || e.Retryable:
+		return "the service is unavailable; try again shortly"
+	}
+	return ""
+}
+
+func (e *RemoteError) userMessage() string {
+	msg := fmt.Sprintf("%s request failed", e.Service)
+	if e.Status != 0 {
+		msg += fmt.Sprintf(" (%d %s)", e.Status, http.StatusText(e.Status))
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if hint := e.Hint(); hint != "" {
+		msg += ": " + hint
+	}
+	return msg
+}
+
+// Diagnostic reports the failure at rng, typically the import or reference
+// which needed the remote lookup.
+func (e *RemoteError) Diagnostic(rng protocol.Range) protocol.Diagnostic {
+	severity := protocol.SeverityWarning
+	if errors.Is(e.Err, ErrNotConfigured) {
+		severity = protocol.SeverityInformation
+	}
+	return protocol.Diagnostic{
+		Range:    rng,
+		Severity: severity,
+		Source:   e.Service,
+		Message:  e.userMessage(),
+	}
+}
+
+// ShowMessage builds a window/showMessage payload for failures which are not
+// tied to a document location.
+func (e *RemoteError) ShowMessage() *protocol.ShowMessageParams {
+	msgType := protocol.MessageError
+	if e.Retryable {
+		msgType = protocol.MessageWarning
+	}
+	return &protocol.ShowMessageParams{Type: msgType, Message: e.userMessage()}
+}
@@ -0,0 +1,131 @@
+// Package clock is the time source behind debouncers, schedulers and
+// timeouts. Production code uses Real; tests substitute a fake, such as
+// lsptest.FakeClock, to drive time-based behaviour deterministically.
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and arms timers.
+type Clock interface {
+	Now() time.Time
+
+	// NewTimer returns a timer which sends the time on its channel once d
+	// has elapsed.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc calls f once d has elapsed.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single pending event, as time.Timer.
+type Timer interface {
+	// C is the channel the time is sent on; nil for AfterFunc timers.
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Real is the system clock.
+var Real Clock = realClock{}
+
+// Or returns c, or Real when c is nil, so option structs can leave their
+// Clock unset.
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	t := time.NewTimer(d)
+	return realTimer{t: t, c: t.C}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{t: time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	t *time.Timer
+	c <-chan time.Time
+}
+
+func (t realTimer) C() <-chan time.Time        { return t.c }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// Since is the time elapsed on c since start.
+func Since(c Clock, start time.Time) time.Duration {
+	return Or(c).Now().Sub(start)
+}
+
+// Sleep waits for d to elapse on c, returning early with the context's error
+// if ctx is done first.
+func Sleep(ctx context.Context, c Clock, d time.Duration) error {
+	t := Or(c).NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C():
+		return nil
+	}
+}
+
+// WithTimeout is context.WithTimeout measured on c: the returned context's
+// Err is context.DeadlineExceeded once d elapses on c.
+func WithTimeout(ctx context.Context, c Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	c = Or(c)
+	if c == Real {
+		return context.WithTimeout(ctx, d)
+	}
+	tc := &timeoutCtx{Context: ctx, deadline: c.Now().Add(d), done: make(chan struct{})}
+	if parent, ok := ctx.Deadline(); ok && parent.Before(tc.deadline) {
+		tc.deadline = parent
+	}
+	timer := c.AfterFunc(d, func() { tc.finish(context.DeadlineExceeded) })
+	stop := context.AfterFunc(ctx, func() { tc.finish(ctx.Err()) })
+	return tc, func() {
+		timer.Stop()
+		stop()
+		tc.finish(context.Canceled)
+	}
+}
+
+// timeoutCtx is a context whose deadline is kept by a Clock rather than the
+// runtime's timers.
+type timeoutCtx struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *timeoutCtx) Deadline() (time.Time, bool) { return c.deadline, true }
+func (c *timeoutCtx) Done() <-chan struct{}       { return c.done }
+
+func (c *timeoutCtx) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *timeoutCtx) finish(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
@@ -0,0 +1,57 @@
+// Command bspschema generates Go types from the JSON Schema definitions of the
+// Build Server Protocol.
+//
+//	go run ./cmd/bspschema -input bsp.schema.json -package bsp -out bsp/types.go
+//
+// BSP messages travel over the same JSON-RPC base protocol as LSP, so only
+// the types are generated here; the transport is shared.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pentops/lsplib/internal/jsonschema"
+)
+
+func main() {
+	input := flag.String("input", "", "schema file or URL")
+	out := flag.String("out", "", "output file, stdout when empty")
+	pkg := flag.String("package", "bsp", "package name of the generated file")
+	flag.Parse()
+
+	if err := run(*input, *out, *pkg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(input, out, pkg string) error {
+	if input == "" {
+		return errors.New("-input is required: BSP does not publish a canonical schema URL")
+	}
+	doc, err := jsonschema.Load(input)
+	if err != nil {
+		return err
+	}
+
+	gen := &jsonschema.GoGenerator{
+		Doc:     doc,
+		Package: pkg,
+		Header:  fmt.Sprintf("Code generated by bspschema from %s. DO NOT EDIT.", input),
+		// BSP carries millisecond timestamps and durations as integers.
+		Integer: "int64",
+	}
+	src, err := gen.Generate()
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
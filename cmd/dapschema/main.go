@@ -0,0 +1,63 @@
+// Command dapschema generates Go types from the Debug Adapter Protocol JSON
+// schema.
+//
+//	go run ./cmd/dapschema -out dap/types.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pentops/lsplib/internal/jsonschema"
+)
+
+const defaultSchemaURL = "https://microsoft.github.io/debug-adapter-protocol/debugAdapterProtocol.json"
+
+// handWritten are the envelope types implemented by the dap package itself.
+// The schema's Message (the structured error body) is dap.Error, since the
+// envelope union already claims the name.
+var handWritten = map[string]bool{
+	"Message":         true,
+	"ProtocolMessage": true,
+	"Request":         true,
+	"Response":        true,
+	"Event":           true,
+	"ErrorResponse":   true,
+}
+
+func main() {
+	input := flag.String("input", defaultSchemaURL, "schema file or URL")
+	out := flag.String("out", "", "output file, stdout when empty")
+	pkg := flag.String("package", "dap", "package name of the generated file")
+	flag.Parse()
+
+	if err := run(*input, *out, *pkg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(input, out, pkg string) error {
+	doc, err := jsonschema.Load(input)
+	if err != nil {
+		return err
+	}
+
+	gen := &jsonschema.GoGenerator{
+		Doc:     doc,
+		Package: pkg,
+		Header:  fmt.Sprintf("Code generated by dapschema from %s. DO NOT EDIT.", input),
+		Skip:    handWritten,
+	}
+	src, err := gen.Generate()
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
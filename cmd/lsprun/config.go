@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pentops/lsplib/execx"
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/internal/glob"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/toolrun"
+)
+
+// Config defines a server.
+type Config struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+
+	// Languages are the language ids of the documents served; Files are
+	// glob patterns of their paths, matching the file name alone when they
+	// have no slash. A document is served when it matches both, an empty
+	// list matching anything.
+	Languages []string `json:"languages,omitempty"`
+	Files     []string `json:"files,omitempty"`
+
+	// Env names the variables the commands see from the server's
+	// environment, execx.DefaultEnv when absent.
+	Env []string `json:"env,omitempty"`
+
+	Lint   *Lint   `json:"lint,omitempty"`
+	Format *Format `json:"format,omitempty"`
+}
+
+// Lint is a command run over a document when it is opened and saved, whose
+// output reports problems in it.
+type Lint struct {
+	// Command is the program and its arguments, in which ${file} is the
+	// document's path.
+	Command []string `json:"command"`
+
+	// Dir is the working directory, relative to the workspace root.
+	Dir string `json:"dir,omitempty"`
+
+	// Matcher names a built-in problem matcher: gcc, clang, go or generic.
+	// Pattern is instead a regular expression with the named groups of
+	// toolrun.RegexParser. Severity is that of problems printed without
+	// one, error when empty.
+	Matcher  string `json:"matcher,omitempty"`
+	Pattern  string `json:"pattern,omitempty"`
+	Severity string `json:"severity,omitempty"`
+
+	// Stream is the output problems are read from: stdout, stderr or both,
+	// the default.
+	Stream string `json:"stream,omitempty"`
+
+	// ReportExitStatus reports a failing command which printed no problems
+	// as a problem itself.
+	ReportExitStatus bool `json:"reportExitStatus,omitempty"`
+
+	// Timeout, such as "30s", bounds each run; execx.DefaultTimeout when
+	// empty.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Format is a command reading a document on standard input and writing it
+// formatted to standard output.
+type Format struct {
+	// Command is the program and its arguments, in which ${file} is the
+	// document's path.
+	Command []string `json:"command"`
+	Dir     string   `json:"dir,omitempty"`
+	Timeout string   `json:"timeout,omitempty"`
+}
+
+// LoadConfig reads and checks the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) check() error {
+	if cfg.Name == "" {
+		return errors.New("name is required")
+	}
+	if cfg.Lint == nil && cfg.Format == nil {
+		return errors.New("neither lint nor format is set")
+	}
+	if _, err := cfg.filePatterns(); err != nil {
+		return err
+	}
+	if cfg.Lint != nil {
+		if _, err := cfg.Lint.tool(cfg.Name); err != nil {
+			return fmt.Errorf("lint: %w", err)
+		}
+	}
+	if cfg.Format != nil {
+		if _, err := command(cfg.Format.Command, cfg.Format.Dir, cfg.Format.Timeout); err != nil {
+			return fmt.Errorf("format: %w", err)
+		}
+	}
+	return nil
+}
+
+// filePatterns compiles Files.
+func (cfg *Config) filePatterns() ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(cfg.Files))
+	for _, file := range cfg.Files {
+		if !strings.Contains(file, "/") {
+			file = "**/" + file
+		}
+		re, err := glob.Compile(file, fscase.Auto)
+		if err != nil {
+			return nil, fmt.Errorf("files: %q: %w", file, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// tool is the toolrun.Tool running the lint command.
+func (l *Lint) tool(name string) (toolrun.Tool, error) {
+	cmd, err := command(l.Command, l.Dir, l.Timeout)
+	if err != nil {
+		return toolrun.Tool{}, err
+	}
+	tool := toolrun.Tool{Name: name, Command: cmd, ReportExitStatus: l.ReportExitStatus}
+
+	severity := protocol.SeverityError
+	if l.Severity != "" {
+		var ok bool
+		if severity, ok = toolrun.ParseSeverity(l.Severity); !ok {
+			return toolrun.Tool{}, fmt.Errorf("unknown severity %q", l.Severity)
+		}
+	}
+	switch {
+	case l.Matcher != "" && l.Pattern != "":
+		return toolrun.Tool{}, errors.New("matcher and pattern are exclusive")
+	case l.Pattern != "":
+		re, err := regexp.Compile(l.Pattern)
+		if err != nil {
+			return toolrun.Tool{}, fmt.Errorf("pattern: %w", err)
+		}
+		tool.Parser = &toolrun.RegexParser{Pattern: re, Severity: severity}
+	case l.Matcher == "generic" || l.Matcher == "":
+		tool.Parser = toolrun.GenericParser(severity)
+	default:
+		parser, ok := toolrun.Matcher(l.Matcher)
+		if !ok {
+			return toolrun.Tool{}, fmt.Errorf("unknown matcher %q", l.Matcher)
+		}
+		tool.Parser = parser
+	}
+
+	switch l.Stream {
+	case "", "both":
+		tool.Stream = toolrun.StreamBoth
+	case "stdout":
+		tool.Stream = toolrun.StreamStdout
+	case "stderr":
+		tool.Stream = toolrun.StreamStderr
+	default:
+		return toolrun.Tool{}, fmt.Errorf("unknown stream %q", l.Stream)
+	}
+	return tool, nil
+}
+
+// command builds the execx.Command of a configured command line.
+func command(args []string, dir, timeout string) (execx.Command, error) {
+	if len(args) == 0 || args[0] == "" {
+		return execx.Command{}, errors.New("command is required")
+	}
+	cmd := execx.Command{Name: args[0], Args: args[1:], Dir: dir}
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return execx.Command{}, fmt.Errorf("timeout: %w", err)
+		}
+		cmd.Timeout = d
+	}
+	return cmd, nil
+}
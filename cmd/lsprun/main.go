@@ -0,0 +1,57 @@
+// Command lsprun is a language server wrapping command-line tools, defined
+// by a config file rather than Go code:
+//
+//	lsprun -config shellcheck.json
+//
+// The config names the server, the documents it serves and the commands it
+// runs over them:
+//
+//	{
+//	  "name": "shellcheck",
+//	  "languages": ["shellscript"],
+//	  "files": ["*.sh"],
+//	  "lint": {
+//	    "command": ["shellcheck", "--format=gcc", "${file}"],
+//	    "matcher": "gcc"
+//	  },
+//	  "format": {
+//	    "command": ["shfmt", "--filename", "${file}"]
+//	  }
+//	}
+//
+// The lint command runs when a document is opened and saved, and the
+// problems in its output, read by a built-in matcher or a "pattern" with
+// named groups, are published as the document's diagnostics. The format
+// command reads the document on standard input and writes it formatted,
+// answering textDocument/formatting. Commands run in the workspace root
+// through an execx.Sandbox; see Config for every field.
+//
+// Config files are JSON; YAML would take a dependency the module doesn't
+// have.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+)
+
+func main() {
+	configPath := flag.String("config", "", "server config file")
+	flag.Parse()
+	if *configPath == "" {
+		log.Fatal("-config is required")
+	}
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := newServer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_ = s.lsp.ServeStdio(context.Background())
+	os.Exit(s.lsp.ExitCode())
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pentops/lsplib/diagnostics"
+	"github.com/pentops/lsplib/execx"
+	"github.com/pentops/lsplib/internal/glob"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/middleware"
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/textsync"
+	"github.com/pentops/lsplib/toolrun"
+)
+
+// server serves the documents a Config selects.
+type server struct {
+	cfg   *Config
+	files []*regexp.Regexp
+	lsp   *lsp.Server
+	store *textsync.Store
+	diags *diagnostics.Manager
+
+	mu      sync.Mutex
+	sandbox *execx.Sandbox
+	// runners lint each document, by URI key, so that linting one doesn't
+	// cancel another's run.
+	runners map[string]*toolrun.Runner
+}
+
+func newServer(cfg *Config) (*server, error) {
+	files, err := cfg.filePatterns()
+	if err != nil {
+		return nil, err
+	}
+	s := &server{cfg: cfg, files: files, runners: map[string]*toolrun.Runner{}}
+	caps := protocol.ServerCapabilities{TextDocumentSync: textsync.Capability()}
+	if cfg.Format != nil {
+		caps.DocumentFormattingProvider = true
+	}
+	s.lsp = lsp.NewServer(lsp.Options{
+		Name:         cfg.Name,
+		Version:      cfg.Version,
+		Capabilities: caps,
+		OnInitialize: s.initialize,
+		// A handler's panic fails its request rather than the server, and
+		// with it every editor using it.
+		Middleware: []jsonrpc2.Middleware{
+			middleware.Recover(middleware.RecoverOptions{Logger: slog.New(slog.NewTextHandler(os.Stderr, nil))}),
+		},
+	})
+	s.store = &textsync.Store{Encoding: s.lsp.PositionEncoding}
+	s.store.Register(s.lsp.Mux)
+	s.diags = diagnostics.NewManager(s.lsp, diagnostics.ManagerOptions{})
+	s.diags.Track(s.store)
+	if cfg.Lint != nil {
+		s.store.OnEvent(s.lint)
+	}
+	if cfg.Format != nil {
+		jsonrpc2.RegisterMethod(s.lsp.Mux, "textDocument/formatting", s.format)
+	}
+	return s, nil
+}
+
+// initialize roots the commands in the client's workspace, or the current
+// directory when it has none.
+func (s *server) initialize(ctx context.Context, params *protocol.InitializeParams, result *protocol.InitializeResult) error {
+	root := ""
+	switch {
+	case params.RootURI != nil && *params.RootURI != "":
+		root = glob.Path(string(*params.RootURI))
+	case len(params.WorkspaceFolders) > 0:
+		root = glob.Path(string(params.WorkspaceFolders[0].URI))
+	default:
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.sandbox = &execx.Sandbox{Root: root, AllowEnv: s.cfg.Env}
+	s.mu.Unlock()
+	return nil
+}
+
+// serves reports whether the config selects doc.
+func (s *server) serves(doc textsync.Document) bool {
+	if len(s.cfg.Languages) > 0 && !slices.Contains(s.cfg.Languages, doc.LanguageID) {
+		return false
+	}
+	if len(s.files) == 0 {
+		return true
+	}
+	path := glob.Path(string(doc.URI))
+	for _, re := range s.files {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// lint runs the lint command over a document as it is opened or saved,
+// in the background so that other messages aren't held up.
+func (s *server) lint(ctx context.Context, event textsync.Event) {
+	if !s.serves(event.Document) {
+		return
+	}
+	key := s.store.Case.URIKey(string(event.Document.URI))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch event.Kind {
+	case textsync.Opened, textsync.Saved:
+	case textsync.Closed:
+		delete(s.runners, key)
+		return
+	default:
+		return
+	}
+	runner, ok := s.runners[key]
+	if !ok {
+		tool, _ := s.cfg.Lint.tool(s.cfg.Name)
+		runner = &toolrun.Runner{Sandbox: s.sandbox, Tool: tool, Publisher: publisher{s}}
+		s.runners[key] = runner
+	}
+	go func() {
+		_ = runner.OnSave(context.WithoutCancel(ctx), event.Document.URI)
+	}()
+}
+
+// format answers textDocument/formatting with the formatter's output as a
+// single edit replacing the document.
+func (s *server) format(ctx context.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
+	doc, ok := s.store.Get(params.TextDocument.URI)
+	if !ok || !s.serves(doc) {
+		return nil, nil
+	}
+	cmd, err := command(s.cfg.Format.Command, s.cfg.Format.Dir, s.cfg.Format.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	path := glob.Path(string(doc.URI))
+	for idx, arg := range cmd.Args {
+		cmd.Args[idx] = strings.ReplaceAll(arg, toolrun.FilePlaceholder, path)
+	}
+	cmd.Stdin = strings.NewReader(doc.Text)
+
+	s.mu.Lock()
+	sandbox := s.sandbox
+	s.mu.Unlock()
+	res, err := sandbox.Run(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("%s: exit status %d: %s", cmd, res.ExitCode, bytes.TrimSpace(res.Stderr))
+	}
+	if res.Truncated {
+		return nil, fmt.Errorf("%s: output too large", cmd)
+	}
+	formatted := string(res.Stdout)
+	if formatted == doc.Text {
+		return []protocol.TextEdit{}, nil
+	}
+	end := position.NewIndex(doc.Text).OffsetToPosition(len(doc.Text), s.lsp.PositionEncoding())
+	return []protocol.TextEdit{{
+		Range:   protocol.Range{End: end},
+		NewText: formatted,
+	}}, nil
+}
+
+// publisher hands a lint run's diagnostics to the Manager, with the
+// version of the document when it is open. Other files the command reports
+// problems in are published as they are on disk.
+type publisher struct {
+	s *server
+}
+
+func (p publisher) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	version := diagnostics.NoVersion
+	if doc, ok := p.s.store.Get(params.URI); ok {
+		version = doc.Version
+	}
+	p.s.diags.Set(params.URI, version, params.Diagnostics)
+	return nil
+}
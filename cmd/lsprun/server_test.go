@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/pentops/lsplib/lsptest"
+	"github.com/pentops/lsplib/protocol"
+)
+
+func TestFormatWithoutTrailingNewline(t *testing.T) {
+	if _, err := exec.LookPath("tr"); err != nil {
+		t.Skip("no tr command")
+	}
+	s, err := newServer(&Config{Name: "upper", Format: &Format{Command: []string{"tr", "a-z", "A-Z"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := protocol.DocumentURI("file://" + t.TempDir())
+	c := lsptest.NewClient(t, s.lsp)
+	c.Initialize(&protocol.InitializeParams{RootURI: &root})
+
+	uri := root + "/main.txt"
+	// The range replacing the document ends at its last character, on a
+	// line with no ending.
+	c.OpenDocument(uri, "ab\ncd😀")
+	var edits []protocol.TextEdit
+	params := &protocol.DocumentFormattingParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}
+	if err := c.Request("textDocument/formatting", params, &edits); err != nil {
+		t.Fatalf("formatting: %v", err)
+	}
+	want := protocol.TextEdit{
+		Range:   protocol.Range{End: protocol.Position{Line: 1, Character: 4}},
+		NewText: "AB\nCD😀",
+	}
+	if len(edits) != 1 || edits[0] != want {
+		t.Fatalf("formatting edits = %+v, want [%+v]", edits, want)
+	}
+}
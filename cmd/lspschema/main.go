@@ -0,0 +1,541 @@
+// Command lspschema generates code and documentation from the Language
+// Server Protocol metaModel.
+//
+//	go run ./cmd/lspschema -out protocol.go
+//	go run ./cmd/lspschema -out ./lsp -package lsp
+//	go run ./cmd/lspschema -type Diagnostic
+//	go run ./cmd/lspschema -format markdown -out PROTOCOL.md
+//	go run ./cmd/lspschema -format corpus -out testdata/fuzz/FuzzMessage
+//
+// Go code is formatted and type-checked before it is written, and an -out
+// not ending in .go is a package directory, which it is split across as
+// types.go, enums.go, unions.go, methods.go, accessors.go and deprecated.go,
+// with Example functions for the message handlers in example_test.go.
+// There, -roundtrip adds roundtrip_test.go, which decodes the smallest and
+// largest example payload of each type, kept in testdata/roundtrip, failing
+// on properties without a field, and checks they encode back as they were.
+//
+// The corpus format writes the minimal and maximal JSON-RPC message of each
+// method's params and result, as Go fuzz seed files, into the -out
+// directory, replacing the ones an earlier run wrote.
+//
+// An -overrides file maps metaModel types and properties to Go types
+// written by hand, which are imported in their place and not generated;
+// see metamodel.Overrides for the format:
+//
+//	go run ./cmd/lspschema -overrides overrides.json -out ./protocol
+//
+// The structures -accessors names, ClientCapabilities unless it is set
+// otherwise, get nil-safe views in accessors.go, so a handler reads a
+// client feature as caps.View().TextDocument().Hover().ContentFormat()
+// without checking each level is there.
+//
+// Beside the handlers, methods.go holds Methods, a table of each message's
+// direction, params, result and partial result types and registration
+// method, for tools such as proxies and trace printers which handle
+// messages without a list of their own.
+//
+// Numbers take the sizes the specification gives them unless -numbers
+// widens integers to int64 or holds every number as json.Number, for peers
+// sending IDs and offsets past them; serve the types over connections with
+// the jsonrpc2.Options.Numbers of the same name, so LSPAny values decode
+// alike.
+//
+// Proposed elements are left out unless -proposed asks for them, deprecated
+// ones when -deprecated=false, and those added after -min-version, which
+// every peer is assumed to speak; whatever refers to them goes with them. To
+// preview the next release without changing the stable API, generate a
+// separate package including proposals, which callers opt in to by importing
+// it:
+//
+//	go run ./cmd/lspschema -proposed -out ./protocol/proposed -package proposed
+//
+// With -probe, it audits a third-party server instead, started from the
+// command after the flags or, given one URL, dialled where it is running:
+//
+//	go run ./cmd/lspschema -probe -language go -- gopls serve
+//	go run ./cmd/lspschema -probe tcp://localhost:9257
+//
+// It initializes the server claiming every client capability, opens an
+// empty document and sends each request a client may, with the smallest
+// valid params, then reports where the responses and the messages the
+// server sends deviate from the metaModel: properties it doesn't declare,
+// missing required ones, nulls where they aren't allowed, and methods
+// which aren't found although their capability is advertised. It exits
+// non-zero when there are any.
+//
+// A package regenerated for a newer release keeps the names it was
+// generated with from the release -shims names compiling for one more
+// release cycle: types and constants renamed since as deprecated aliases of
+// their new names, and view getters as deprecated getters calling theirs,
+// in deprecated.go:
+//
+//	go run ./cmd/lspschema -version 3.18.0 -shims 3.17.5 -out ./protocol
+//
+// With -changelog, it lists what changed in the generated Go API since an
+// earlier metaModel, named as -input is or by its protocol release: the
+// messages added, removed or retyped, and the exported declarations, struct
+// fields and methods added, removed or changed, as Markdown or, with
+// -format json, for tools:
+//
+//	go run ./cmd/lspschema -changelog 3.16.0 -version 3.17.5 -out CHANGES.md
+//
+// As lspschema diff, it compares two metaModels, files, URLs or protocol
+// releases, element by element, to review a bump of the pinned release
+// before regenerating: the structures and properties, enumerations and
+// their values, type aliases and messages added, removed and changed, with
+// the changes which break code using the Go API generated from the old one
+// flagged and listed first, as Markdown or, with -format json, for tools:
+//
+//	go run ./cmd/lspschema diff 3.17.0 3.18.0
+//	go run ./cmd/lspschema diff -format json old.json new.json
+//
+// As lspschema assert, it writes a file of a server's package asserting at
+// compile time that its -server type implements the handler interfaces of
+// the messages each of -capabilities advertises, imported from the
+// generated package at -protocol, so a server claiming a feature it
+// doesn't handle fails to build:
+//
+//	go run ./cmd/lspschema assert -package server -server Server \
+//		-protocol example.com/tool/protocol \
+//		-capabilities hoverProvider,definitionProvider -out server/handlers_lsp.go
+//
+// As lspschema verify, it checks the packages matching its arguments, "."
+// when there are none, the other way round: the server capabilities they
+// set in ServerCapabilities literals and assignments against the handler
+// interfaces their types implement. It prints each capability advertised
+// without a handler and each handler whose capability isn't advertised, and
+// exits non-zero when there are any. The generated package is the one they
+// import, or -protocol; generate it and verify with the same -overrides and
+// -numbers:
+//
+//	go run ./cmd/lspschema verify ./...
+//
+// The metaModel is the copy embedded in the binary unless -input names a
+// file or URL, or -version a protocol release to fetch. Run from the module
+// root, -update refreshes the embedded copy from the pinned release.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pentops/lsplib/internal/metamodel"
+)
+
+func main() {
+	input := flag.String("input", "", "metaModel file or URL, the embedded copy when empty")
+	version := flag.String("version", "", "protocol release to fetch the metaModel of, such as 3.17")
+	update := flag.Bool("update", false, "refresh the embedded metaModel instead of generating")
+	out := flag.String("out", "", "output file or, for go not ending in .go, package directory; stdout when empty")
+	outFormat := flag.String("format", "go", "output format: go, markdown or corpus")
+	pkg := flag.String("package", "protocol", "package name of generated Go code")
+	typeName := flag.String("type", "", "single structure to generate in go format; every type when empty")
+	overrides := flag.String("overrides", "", "JSON file mapping metaModel types and properties to hand-written Go types")
+	accessors := flag.String("accessors", "ClientCapabilities", "comma-separated structures to generate nil-safe views of")
+	numbers := flag.String("numbers", "", "Go types of numbers: int64 integers, or json for json.Number; the specification's sizes when empty")
+	probeServer := flag.Bool("probe", false, "report where the server given after the flags deviates from the metaModel")
+	language := flag.String("language", "plaintext", "language of the document -probe opens")
+	shims := flag.String("shims", "", "metaModel file, URL or protocol release generated from before, whose renamed names get deprecated shims")
+	roundTrips := flag.Bool("roundtrip", false, "add round-trip tests of the generated types, with golden payloads, to a package directory")
+	server := flag.String("server", "", "type assert asserts implements the handlers of -capabilities")
+	capabilities := flag.String("capabilities", "", "comma-separated server capabilities assert checks -server handles, such as hoverProvider")
+	protocolPath := flag.String("protocol", "", "import path of the generated package assert's and verify's code uses")
+	since := flag.String("changelog", "", "metaModel file, URL or protocol release to list the generated API's changes since, as markdown or json")
+	var filter metamodel.Filter
+	flag.BoolVar(&filter.Proposed, "proposed", false, "include proposed elements")
+	flag.BoolVar(&filter.Deprecated, "deprecated", true, "include deprecated elements")
+	flag.StringVar(&filter.Version, "min-version", "", "leave out elements added after this protocol version, such as 3.16")
+	args := os.Args[1:]
+	var command string
+	if len(args) > 0 {
+		switch args[0] {
+		case "diff", "assert", "verify":
+			command, args = args[0], args[1:]
+		}
+	}
+	_ = flag.CommandLine.Parse(args)
+
+	source, err := resolve(*input, *version, *update)
+	switch {
+	case err != nil:
+	case command == "diff":
+		err = diffModels(flag.Args(), filter, *out, *outFormat)
+	case command == "assert":
+		err = assertions(source, filter, *overrides, *numbers, *server, *capabilities, *protocolPath, *out, *pkg)
+	case command == "verify":
+		var gen *metamodel.GoGenerator
+		if gen, err = loadGenerator(source, filter, *overrides, *numbers); err == nil {
+			err = verify(gen, *protocolPath, flag.Args())
+		}
+	case *update:
+		err = refresh(source)
+	case *probeServer:
+		err = probe(source, flag.Args(), *language)
+	case *since != "":
+		err = changelog(*since, source, filter, *overrides, *numbers, *accessors, *out, *outFormat, *pkg)
+	default:
+		err = run(source, filter, *overrides, *numbers, *accessors, *shims, *roundTrips, *out, *outFormat, *pkg, *typeName)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// resolve picks the metaModel source from the -input and -version flags;
+// empty is the embedded copy, except when updating it.
+func resolve(input, version string, update bool) (string, error) {
+	if input != "" && version != "" {
+		return "", errors.New("-input and -version are exclusive")
+	}
+	if input != "" {
+		return input, nil
+	}
+	if version == "" && update {
+		version = metamodel.PinnedVersion
+	}
+	if version == "" {
+		return "", nil
+	}
+	return metamodel.VersionURL(version)
+}
+
+// refresh replaces the embedded metaModel with the one at source, once it
+// is known to decode.
+func refresh(source string) error {
+	data, err := metamodel.Fetch(source)
+	if err != nil {
+		return err
+	}
+	if _, err := metamodel.Parse(data, source); err != nil {
+		return err
+	}
+	return os.WriteFile(metamodel.EmbeddedPath, data, 0o644)
+}
+
+func run(input string, filter metamodel.Filter, overrides, numbers, accessors, shims string, roundTrips bool, out, outFormat, pkg, typeName string) error {
+	model, err := load(input, filter)
+	if err != nil {
+		return err
+	}
+	if input == "" {
+		input = "the embedded metaModel " + metamodel.PinnedVersion
+	}
+
+	if outFormat == "corpus" {
+		return writeCorpus(model, out)
+	}
+
+	var src []byte
+	switch outFormat {
+	case "go":
+		header := fmt.Sprintf("Code generated by lspschema from %s. DO NOT EDIT.", input)
+		gen, err := generator(model, pkg, header, overrides, numbers, accessors)
+		if err != nil {
+			return err
+		}
+		if shims != "" {
+			if gen.Previous, err = loadEarlier(shims, filter); err != nil {
+				return err
+			}
+		}
+		gen.RoundTrips = roundTrips
+		switch {
+		case roundTrips && (typeName != "" || out == "" || strings.HasSuffix(out, ".go")):
+			return errors.New("-roundtrip needs an -out package directory")
+		case typeName != "":
+			src, err = gen.Struct(typeName)
+		case out != "" && !strings.HasSuffix(out, ".go"):
+			return writePackage(gen, out)
+		default:
+			if src, err = gen.Generate(); err == nil {
+				err = typeCheck(pkg, map[string][]byte{filepath.Base(out): src})
+			}
+		}
+	case "markdown", "md":
+		var buf bytes.Buffer
+		err = metamodel.WriteMarkdown(&buf, model)
+		src = buf.Bytes()
+	default:
+		err = fmt.Errorf("unknown format %q", outFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
+
+// load loads the metaModel at source and applies filter to it.
+func load(source string, filter metamodel.Filter) (*metamodel.Model, error) {
+	model, err := metamodel.Load(source)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Apply(model)
+}
+
+// loadEarlier loads an earlier metaModel, named by a file or URL or by its
+// protocol release, as -changelog and -shims do.
+func loadEarlier(source string, filter metamodel.Filter) (*metamodel.Model, error) {
+	if !strings.Contains(source, "/") && !strings.HasSuffix(source, ".json") {
+		var err error
+		if source, err = metamodel.VersionURL(source); err != nil {
+			return nil, err
+		}
+	}
+	return load(source, filter)
+}
+
+// generator is a GoGenerator with the settings of the flags.
+func generator(model *metamodel.Model, pkg, header, overrides, numbers, accessors string) (*metamodel.GoGenerator, error) {
+	gen := &metamodel.GoGenerator{Model: model, Package: pkg, Header: header}
+	var err error
+	if gen.Numbers, err = metamodel.ParseNumbers(numbers); err != nil {
+		return nil, err
+	}
+	if accessors != "" {
+		gen.Accessors = strings.Split(accessors, ",")
+	}
+	if overrides != "" {
+		data, err := os.ReadFile(overrides)
+		if err != nil {
+			return nil, err
+		}
+		if gen.Overrides, err = metamodel.ParseOverrides(data); err != nil {
+			return nil, err
+		}
+	}
+	return gen, nil
+}
+
+// changelog writes the changes to the generated API between the metaModel
+// at since, a file, URL or protocol release, and the one at input, both
+// filtered alike, as Markdown or JSON.
+func changelog(since, input string, filter metamodel.Filter, overrides, numbers, accessors, out, outFormat, pkg string) error {
+	old, err := loadEarlier(since, filter)
+	if err != nil {
+		return err
+	}
+	model, err := load(input, filter)
+	if err != nil {
+		return err
+	}
+	gen, err := generator(nil, pkg, "", overrides, numbers, accessors)
+	if err != nil {
+		return err
+	}
+	log, err := metamodel.Diff(gen, old, model)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch outFormat {
+	case "go", "markdown", "md":
+		err = log.WriteMarkdown(&buf)
+	case "json":
+		err = log.WriteJSON(&buf)
+	default:
+		err = fmt.Errorf("-changelog writes markdown or json, not %q", outFormat)
+	}
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(out, buf.Bytes(), 0o644)
+}
+
+// loadGenerator is a GoGenerator of the metaModel at source naming what
+// it generates as a package generated with the same flags does.
+func loadGenerator(source string, filter metamodel.Filter, overrides, numbers string) (*metamodel.GoGenerator, error) {
+	model, err := load(source, filter)
+	if err != nil {
+		return nil, err
+	}
+	return generator(model, "protocol", "", overrides, numbers, "")
+}
+
+// assertions writes the file asserting server handles the messages of the
+// comma-separated capabilities, as package pkg importing the generated
+// package at protocol.
+func assertions(source string, filter metamodel.Filter, overrides, numbers, server, capabilities, protocol, out, pkg string) error {
+	if server == "" || capabilities == "" {
+		return errors.New("assert needs -server and -capabilities")
+	}
+	gen, err := loadGenerator(source, filter, overrides, numbers)
+	if err != nil {
+		return err
+	}
+	gen.Package, gen.Header = pkg, "Code generated by lspschema assert. DO NOT EDIT."
+	src, err := gen.Assertions(server, protocol, strings.Split(capabilities, ","))
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
+
+// diffModels writes the changes between the two metaModels args name, as
+// files, URLs or protocol releases, both filtered alike, as Markdown or
+// JSON.
+func diffModels(args []string, filter metamodel.Filter, out, outFormat string) error {
+	if len(args) != 2 {
+		return errors.New("diff needs the old and new metaModels")
+	}
+	old, err := loadEarlier(args[0], filter)
+	if err != nil {
+		return err
+	}
+	model, err := loadEarlier(args[1], filter)
+	if err != nil {
+		return err
+	}
+	d := metamodel.DiffModels(old, model)
+
+	var buf bytes.Buffer
+	switch outFormat {
+	case "go", "markdown", "md":
+		err = d.WriteMarkdown(&buf)
+	case "json":
+		err = d.WriteJSON(&buf)
+	default:
+		err = fmt.Errorf("diff writes markdown or json, not %q", outFormat)
+	}
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(out, buf.Bytes(), 0o644)
+}
+
+// writePackage writes the generated package's files into dir, once they
+// compile, and removes those an earlier run wrote which this one didn't.
+func writePackage(gen *metamodel.GoGenerator, dir string) error {
+	files, err := gen.GenerateFiles()
+	if err != nil {
+		return err
+	}
+	if err := typeCheck(gen.Package, files); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// The golden payloads are all generated, so those of types since
+	// removed go.
+	fixtures := filepath.Join(dir, "testdata", "roundtrip")
+	if err := os.RemoveAll(fixtures); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if !strings.HasPrefix(name, "testdata/") {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"types.go", "enums.go", "unions.go", "methods.go", "accessors.go", "deprecated.go", "example_test.go", "roundtrip_test.go"} {
+		path := filepath.Join(dir, name)
+		if src, ok := files[name]; ok {
+			if err := os.WriteFile(path, src, 0o644); err != nil {
+				return err
+			}
+			continue
+		}
+		old, err := os.ReadFile(path)
+		if err == nil && bytes.HasPrefix(old, []byte("// Code generated by lspschema")) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// typeCheck fails unless files compile as package pkg, importing the
+// packages they use from source.
+func typeCheck(pkg string, files map[string][]byte) error {
+	fset := token.NewFileSet()
+	var parsed []*ast.File
+	for name, src := range files {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, f)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check(pkg, fset, parsed, nil); err != nil {
+		return fmt.Errorf("generated code doesn't compile: %w", err)
+	}
+	return nil
+}
+
+// corpusPrefix marks the seed files lspschema writes, so a run can remove
+// the previous version's without touching seeds added by hand or found by
+// the fuzzer.
+const corpusPrefix = "lsp-"
+
+func writeCorpus(model *metamodel.Model, dir string) error {
+	if dir == "" {
+		return errors.New("-format corpus needs an -out directory")
+	}
+	entries, err := (&metamodel.Examples{Model: model}).Corpus()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	stale, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range stale {
+		if !f.IsDir() && strings.HasPrefix(f.Name(), corpusPrefix) {
+			if err := os.Remove(filepath.Join(dir, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	for _, e := range entries {
+		seed := fmt.Sprintf("go test fuzz v1\n[]byte(%q)\n", e.Data)
+		if err := os.WriteFile(filepath.Join(dir, corpusPrefix+e.Name), []byte(seed), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
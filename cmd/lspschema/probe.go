@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/internal/metamodel"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lspclient"
+)
+
+// probeTimeout bounds each request of a probe, so a server which never
+// answers one holds up the report only so long.
+const probeTimeout = 10 * time.Second
+
+// prober audits a server against the metaModel: it initializes it,
+// claiming every client capability, opens a document and sends each
+// request the model lets a client send, with the smallest valid params,
+// then checks what comes back.
+type prober struct {
+	model   *metamodel.Model
+	checker *metamodel.Checker
+	conn    *jsonrpc2.Conn
+
+	mu    sync.Mutex
+	found []string
+}
+
+// probe runs the server command in args, or dials the running server
+// at args' one URL, and prints every deviation it finds. It fails when
+// there are any.
+func probe(input string, args []string, language string) error {
+	if len(args) == 0 {
+		return errors.New("-probe needs a server command or address")
+	}
+	model, err := metamodel.Load(input)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var stream io.ReadWriteCloser
+	if len(args) == 1 && strings.Contains(args[0], "://") {
+		if stream, err = lspclient.Dial(ctx, args[0], nil); err != nil {
+			return err
+		}
+	} else {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stderr = os.Stderr
+		if stream, err = lspclient.Start(ctx, cmd); err != nil {
+			return err
+		}
+	}
+	defer stream.Close()
+
+	p := &prober{model: model, checker: &metamodel.Checker{Model: model}}
+	p.conn = jsonrpc2.NewConn(stream, jsonrpc2.Options{
+		CancelMethod:    "$/cancelRequest",
+		OnProtocolError: func(err error) { p.report("", "", err.Error()) },
+	})
+	go func() { _ = p.conn.Run(ctx, jsonrpc2.HandlerFunc(p.handle)) }()
+
+	if err := p.run(ctx, language); err != nil {
+		return err
+	}
+	sort.Strings(p.found)
+	for _, line := range p.found {
+		fmt.Println(line)
+	}
+	if len(p.found) > 0 {
+		return fmt.Errorf("%d deviations from the metaModel", len(p.found))
+	}
+	return nil
+}
+
+func (p *prober) run(ctx context.Context, language string) error {
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	examples := &metamodel.Examples{Model: p.model}
+	initialize := p.request("initialize")
+	if initialize == nil || len(initialize.Params) != 1 {
+		return errors.New("the metaModel has no initialize request")
+	}
+	params, _ := examples.Minimal(initialize.Params[0]).(map[string]any)
+	if params == nil {
+		params = map[string]any{}
+	}
+	rootURI := "file://" + filepath.ToSlash(root)
+	params["processId"] = os.Getpid()
+	params["rootUri"] = rootURI
+	params["workspaceFolders"] = []any{map[string]any{"uri": rootURI, "name": filepath.Base(root)}}
+	params["capabilities"] = examples.Maximal(&metamodel.Type{Kind: metamodel.KindReference, Name: "ClientCapabilities"})
+
+	var result json.RawMessage
+	if err := p.call(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	p.check("initialize", "result", initialize.Result, result)
+	var caps struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	_ = json.Unmarshal(result, &caps)
+	if err := p.conn.Notify(ctx, "initialized", map[string]any{}); err != nil {
+		return err
+	}
+	// Requests name the document Examples uses, so the server has it open.
+	doc := map[string]any{"uri": "file:///example/main.txt", "languageId": language, "version": 1, "text": ""}
+	if err := p.conn.Notify(ctx, "textDocument/didOpen", map[string]any{"textDocument": doc}); err != nil {
+		return err
+	}
+
+	for _, req := range p.model.Requests {
+		if req.MessageDirection == metamodel.ServerToClient || req.Method == "initialize" || req.Method == "shutdown" {
+			continue
+		}
+		var params any
+		if len(req.Params) == 1 {
+			params = examples.Minimal(req.Params[0])
+		}
+		var result json.RawMessage
+		err := p.call(ctx, req.Method, params, &result)
+		advertised := req.ServerCapability != "" && hasCapability(caps.Capabilities, req.ServerCapability)
+		var rpcErr *jsonrpc2.Error
+		switch {
+		case err == nil:
+			p.check(req.Method, "result", req.Result, result)
+		case errors.As(err, &rpcErr):
+			if rpcErr.Code == jsonrpc2.CodeMethodNotFound && advertised {
+				p.report(req.Method, "", fmt.Sprintf("method not found, but capability %s is advertised", req.ServerCapability))
+			} else if len(rpcErr.Data) > 0 {
+				p.check(req.Method, "error.data", req.ErrorData, rpcErr.Data)
+			}
+		case errors.Is(err, context.DeadlineExceeded):
+			p.report(req.Method, "", fmt.Sprintf("no response within %s", probeTimeout))
+		default:
+			return fmt.Errorf("%s: %w", req.Method, err)
+		}
+	}
+
+	if err := p.call(ctx, "shutdown", nil, nil); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return p.conn.Notify(ctx, "exit", nil)
+}
+
+func (p *prober) call(ctx context.Context, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	return p.conn.Call(ctx, method, params, result)
+}
+
+// handle checks the messages the server sends and answers its requests
+// with the smallest valid result.
+func (p *prober) handle(_ context.Context, req *jsonrpc2.Request) (any, error) {
+	if r := p.request(req.Method); r != nil && r.MessageDirection != metamodel.ClientToServer {
+		p.checkParams(req.Method, r.Params, req.Params)
+		return (&metamodel.Examples{Model: p.model}).Minimal(r.Result), nil
+	}
+	for _, n := range p.model.Notifications {
+		if n.Method == req.Method && n.MessageDirection != metamodel.ClientToServer {
+			p.checkParams(req.Method, n.Params, req.Params)
+			return nil, nil
+		}
+	}
+	if !strings.HasPrefix(req.Method, "$/") {
+		p.report(req.Method, "", "server sent a method the metaModel doesn't have it send")
+	}
+	return nil, jsonrpc2.NewError(jsonrpc2.CodeMethodNotFound, "method not found: %s", req.Method)
+}
+
+func (p *prober) request(method string) *metamodel.Request {
+	for _, r := range p.model.Requests {
+		if r.Method == method {
+			return r
+		}
+	}
+	return nil
+}
+
+func (p *prober) checkParams(method string, params metamodel.Params, raw json.RawMessage) {
+	switch {
+	case len(params) == 1:
+		p.check(method, "params", params[0], raw)
+	case len(params) == 0 && len(raw) > 0 && string(raw) != "null":
+		p.report(method, "params", "params the metaModel doesn't declare")
+	}
+}
+
+func (p *prober) check(method, prefix string, t *metamodel.Type, raw json.RawMessage) {
+	if len(raw) == 0 {
+		raw = json.RawMessage("null")
+	}
+	for _, d := range p.checker.Check(t, raw) {
+		path := prefix
+		if d.Path != "" && !strings.HasPrefix(d.Path, "[") {
+			path += "."
+		}
+		path += d.Path
+		p.report(method, path, d.Message)
+	}
+}
+
+func (p *prober) report(method, path, message string) {
+	line := message
+	if path != "" {
+		line = path + ": " + line
+	}
+	if method != "" {
+		line = method + ": " + line
+	}
+	p.mu.Lock()
+	p.found = append(p.found, line)
+	p.mu.Unlock()
+}
+
+// hasCapability reports whether capabilities has a value other than null
+// or false at a dotted path, such as "workspace.workspaceFolders".
+func hasCapability(capabilities map[string]any, path string) bool {
+	var v any = capabilities
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return false
+		}
+		v = obj[key]
+	}
+	return v != nil && v != false
+}
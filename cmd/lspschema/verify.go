@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pentops/lsplib/internal/metamodel"
+)
+
+// listedPackage is what verify needs of go list's description of a
+// package.
+type listedPackage struct {
+	ImportPath string
+	Dir        string
+	GoFiles    []string
+	CgoFiles   []string
+	Error      *struct{ Err string }
+}
+
+// verifier checks the packages of a server against the generated protocol
+// package they use: the server capabilities they set, in composite
+// literals and assignments, against the handler interfaces their types
+// implement.
+type verifier struct {
+	fset     *token.FileSet
+	protocol *types.Package
+
+	// advertised are the capability paths set, with where.
+	advertised map[string][]token.Pos
+	// implemented are the types implementing each method's handler.
+	implemented map[string][]string
+}
+
+// verify loads the packages matching patterns, finds the generated package
+// they import, at protocolPath when it is set, and prints where an
+// advertised capability has no handler or a handler's capability isn't
+// advertised. It fails when there are any.
+func verify(gen *metamodel.GoGenerator, protocolPath string, patterns []string) error {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	listed, err := listPackages(patterns)
+	if err != nil {
+		return err
+	}
+	v := &verifier{fset: token.NewFileSet(), advertised: map[string][]token.Pos{}, implemented: map[string][]string{}}
+	imp := importer.ForCompiler(v.fset, "source", nil)
+
+	type checkedPackage struct {
+		pkg   *types.Package
+		files []*ast.File
+		info  *types.Info
+	}
+	var checked []checkedPackage
+	for _, lp := range listed {
+		if lp.Error != nil {
+			return fmt.Errorf("%s: %s", lp.ImportPath, lp.Error.Err)
+		}
+		var files []*ast.File
+		for _, name := range append(lp.GoFiles, lp.CgoFiles...) {
+			f, err := parser.ParseFile(v.fset, filepath.Join(lp.Dir, name), nil, 0)
+			if err != nil {
+				return err
+			}
+			files = append(files, f)
+		}
+		info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}, Selections: map[*ast.SelectorExpr]*types.Selection{}}
+		conf := types.Config{Importer: imp, FakeImportC: true}
+		pkg, err := conf.Check(lp.ImportPath, v.fset, files, info)
+		if err != nil {
+			return fmt.Errorf("%s: %w", lp.ImportPath, err)
+		}
+		checked = append(checked, checkedPackage{pkg, files, info})
+	}
+
+	// The protocol package the servers' types refer to is the one they
+	// import, not a copy checked here.
+	candidates := map[string]*types.Package{}
+	for _, c := range checked {
+		for _, p := range c.pkg.Imports() {
+			if isProtocol(p) && (protocolPath == "" || p.Path() == protocolPath) {
+				candidates[p.Path()] = p
+			}
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		if protocolPath != "" {
+			return fmt.Errorf("no package imports %s", protocolPath)
+		}
+		return errors.New("no package imports a package lspschema generated; name it with -protocol")
+	case 1:
+		for _, p := range candidates {
+			v.protocol = p
+		}
+	default:
+		paths := make([]string, 0, len(candidates))
+		for path := range candidates {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		return fmt.Errorf("packages import several generated packages, %s; name one with -protocol", strings.Join(paths, ", "))
+	}
+
+	handlers := gen.ServerHandlers()
+	for _, c := range checked {
+		if c.pkg.Path() == v.protocol.Path() {
+			continue
+		}
+		for _, f := range c.files {
+			v.collectAdvertised(f, c.info)
+		}
+		v.collectImplemented(c.pkg, handlers)
+	}
+	return v.report(os.Stdout, handlers)
+}
+
+// listPackages runs go list on patterns.
+func listPackages(patterns []string) ([]listedPackage, error) {
+	cmd := exec.Command("go", append([]string{"list", "-e", "-json=ImportPath,Dir,GoFiles,CgoFiles,Error"}, patterns...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var pkgs []listedPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p listedPackage
+		if err := dec.Decode(&p); err == io.EOF {
+			return pkgs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("go list: %w", err)
+		}
+		pkgs = append(pkgs, p)
+	}
+}
+
+// isProtocol reports whether p looks like a package lspschema generated:
+// one declaring ServerCapabilities and RegisterServer.
+func isProtocol(p *types.Package) bool {
+	_, caps := p.Scope().Lookup("ServerCapabilities").(*types.TypeName)
+	_, register := p.Scope().Lookup("RegisterServer").(*types.Func)
+	return caps && register
+}
+
+// isCapabilities reports whether t is the protocol package's
+// ServerCapabilities or a pointer to it.
+func (v *verifier) isCapabilities(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == v.protocol.Path() && named.Obj().Name() == "ServerCapabilities"
+}
+
+// collectAdvertised records the capabilities f sets: the fields of
+// ServerCapabilities literals, nested literals' by their JSON paths, and
+// the fields assigned through a ServerCapabilities value, other than to
+// nil or false.
+func (v *verifier) collectAdvertised(f *ast.File, info *types.Info) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CompositeLit:
+			if v.isCapabilities(info.TypeOf(n)) {
+				v.literal(n, "", info)
+			}
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				break
+			}
+			for i, lhs := range n.Lhs {
+				path, ok := v.fieldPath(lhs, info)
+				if !ok || !set(n.Rhs[i], info) {
+					continue
+				}
+				v.advertised[path] = append(v.advertised[path], lhs.Pos())
+				if lit := compositeOf(n.Rhs[i]); lit != nil {
+					v.literal(lit, path, info)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// literal records the fields lit sets, under prefix.
+func (v *verifier) literal(lit *ast.CompositeLit, prefix string, info *types.Info) {
+	st, ok := structOf(info.TypeOf(lit))
+	if !ok {
+		return
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i).Name() != key.Name {
+				continue
+			}
+			name, ok := jsonName(st, i)
+			if !ok || !set(kv.Value, info) {
+				break
+			}
+			path := joinPath(prefix, name)
+			v.advertised[path] = append(v.advertised[path], kv.Pos())
+			if inner := compositeOf(kv.Value); inner != nil {
+				v.literal(inner, path, info)
+			}
+			break
+		}
+	}
+}
+
+// fieldPath is the JSON path of the field expr selects through a
+// ServerCapabilities value, such as workspace.fileOperations for
+// caps.Workspace.FileOperations.
+func (v *verifier) fieldPath(expr ast.Expr, info *types.Info) (string, bool) {
+	sel, ok := ast.Unparen(expr).(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	selection := info.Selections[sel]
+	if selection == nil || selection.Kind() != types.FieldVal || len(selection.Index()) != 1 {
+		return "", false
+	}
+	st, ok := structOf(info.TypeOf(sel.X))
+	if !ok {
+		return "", false
+	}
+	name, ok := jsonName(st, selection.Index()[0])
+	if !ok {
+		return "", false
+	}
+	if v.isCapabilities(info.TypeOf(sel.X)) {
+		return name, true
+	}
+	prefix, ok := v.fieldPath(sel.X, info)
+	return joinPath(prefix, name), ok
+}
+
+// collectImplemented records the handler interfaces the types pkg declares
+// implement, through their pointers' method sets.
+func (v *verifier) collectImplemented(pkg *types.Package, handlers []metamodel.ServerHandler) {
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 || types.IsInterface(named) {
+			continue
+		}
+		ptr := types.NewPointer(named)
+		for _, h := range handlers {
+			if v.implements(ptr, h.Interface) || h.Partial != "" && v.implements(ptr, h.Partial) {
+				v.implemented[h.Method] = append(v.implemented[h.Method], pkg.Name()+"."+name)
+			}
+		}
+	}
+}
+
+func (v *verifier) implements(t types.Type, iface string) bool {
+	obj, ok := v.protocol.Scope().Lookup(iface).(*types.TypeName)
+	if !ok {
+		return false
+	}
+	it, ok := obj.Type().Underlying().(*types.Interface)
+	return ok && types.Implements(t, it)
+}
+
+// report prints the mismatches, sorted, and fails when there are any. A
+// capability advertising several messages needs a handler for one of them,
+// as the options it is set to may advertise only some.
+func (v *verifier) report(w io.Writer, handlers []metamodel.ServerHandler) error {
+	var found []string
+	if len(v.advertised) == 0 {
+		found = append(found, "no server capabilities are set: build a ServerCapabilities of "+v.protocol.Path())
+	}
+	byCapability := map[string][]metamodel.ServerHandler{}
+	for _, h := range handlers {
+		if h.Capability != "" {
+			byCapability[h.Capability] = append(byCapability[h.Capability], h)
+		}
+	}
+	for capability, hs := range byCapability {
+		var ifaces []string
+		handled := false
+		for _, h := range hs {
+			ifaces = append(ifaces, h.Interface)
+			handled = handled || len(v.implemented[h.Method]) > 0
+		}
+		positions := v.advertised[capability]
+		if len(positions) > 0 && !handled {
+			found = append(found, fmt.Sprintf("%s: %s is advertised, but no type implements %s", v.position(positions[0]), capability, strings.Join(ifaces, " or ")))
+		}
+		if len(positions) > 0 || len(v.advertised) == 0 {
+			continue
+		}
+		for _, h := range hs {
+			for _, impl := range v.implemented[h.Method] {
+				found = append(found, fmt.Sprintf("%s implements %s, but %s isn't advertised", impl, h.Interface, capability))
+			}
+		}
+	}
+	sort.Strings(found)
+	for _, line := range found {
+		fmt.Fprintln(w, line)
+	}
+	if len(found) > 0 {
+		return fmt.Errorf("%d mismatches between advertised capabilities and handlers", len(found))
+	}
+	return nil
+}
+
+// position is where pos is, relative to the working directory when it is
+// inside it.
+func (v *verifier) position(pos token.Pos) string {
+	p := v.fset.Position(pos)
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, p.Filename); err == nil && !strings.HasPrefix(rel, "..") {
+			p.Filename = rel
+		}
+	}
+	return p.String()
+}
+
+// set reports whether expr sets a capability: anything but nil and false.
+func set(expr ast.Expr, info *types.Info) bool {
+	tv := info.Types[expr]
+	if tv.IsNil() {
+		return false
+	}
+	return tv.Value == nil || tv.Value.Kind() != constant.Bool || constant.BoolVal(tv.Value)
+}
+
+// compositeOf is the composite literal expr is, or takes the address of.
+func compositeOf(expr ast.Expr) *ast.CompositeLit {
+	expr = ast.Unparen(expr)
+	if u, ok := expr.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		expr = ast.Unparen(u.X)
+	}
+	lit, _ := expr.(*ast.CompositeLit)
+	return lit
+}
+
+func structOf(t types.Type) (*types.Struct, bool) {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if t == nil {
+		return nil, false
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	return st, ok
+}
+
+// jsonName is the JSON name of st's field i, false when it isn't encoded.
+func jsonName(st *types.Struct, i int) (string, bool) {
+	name, _, _ := strings.Cut(reflect.StructTag(st.Tag(i)).Get("json"), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return st.Field(i).Name(), true
+	}
+	return name, true
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,139 @@
+// Package commands routes workspace/executeCommand to handlers registered
+// by command name. A Registry advertises the names it has in the
+// executeCommandProvider capability, decodes each command's arguments for
+// its handler, and applies the WorkspaceEdit a handler returns through
+// workspace/applyEdit, so commands such as "fix imports" needn't ask the
+// client themselves.
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsperror"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Method is the request commands arrive in.
+const Method = "workspace/executeCommand"
+
+// Caller sends requests to the client; *lsp.Server is one.
+type Caller interface {
+	Call(ctx context.Context, method string, params, result any) error
+}
+
+// Handler runs one command with its arguments, as the client sent them.
+// A *protocol.WorkspaceEdit result is applied rather than returned.
+type Handler func(ctx context.Context, args []json.RawMessage) (any, error)
+
+// Registry maps command names to handlers. It is safe for concurrent use.
+type Registry struct {
+	client Caller
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// New returns a Registry applying edits through client.
+func New(client Caller) *Registry {
+	return &Registry{client: client, handlers: map[string]Handler{}}
+}
+
+// Register sets the handler for the command name, replacing any previous
+// one. Commands registered after initialize work, but aren't advertised
+// unless their names were in Capability's result.
+func (r *Registry) Register(name string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[name] = h
+}
+
+// Names lists the registered commands in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Capability is the executeCommandProvider server capability listing the
+// registered commands.
+func (r *Registry) Capability() protocol.ExecuteCommandOptions {
+	return protocol.ExecuteCommandOptions{Commands: r.Names()}
+}
+
+// Route routes workspace/executeCommand on mux to the Registry.
+func (r *Registry) Route(mux *jsonrpc2.Mux) {
+	jsonrpc2.RegisterMethod(mux, Method, r.Execute)
+}
+
+// Execute handles workspace/executeCommand. Unknown commands fail with
+// CodeInvalidParams. When the handler returns an edit, Execute answers once
+// the client has applied it, failing with CodeRequestFailed if the client
+// didn't.
+func (r *Registry) Execute(ctx context.Context, params *protocol.ExecuteCommandParams) (any, error) {
+	r.mu.RLock()
+	h, ok := r.handlers[params.Command]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, jsonrpc2.NewError(jsonrpc2.CodeInvalidParams, "unknown command %q", params.Command)
+	}
+	result, err := h(ctx, params.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	edit, ok := result.(*protocol.WorkspaceEdit)
+	if !ok {
+		return result, nil
+	}
+	if edit == nil {
+		return nil, nil
+	}
+	var applied protocol.ApplyWorkspaceEditResult
+	if err := r.client.Call(ctx, "workspace/applyEdit", &protocol.ApplyWorkspaceEditParams{Edit: *edit}, &applied); err != nil {
+		return nil, err
+	}
+	if !applied.Applied {
+		reason := applied.FailureReason
+		if reason == "" {
+			reason = "the client refused it"
+		}
+		return nil, lsperror.RequestFailed("%s: edit not applied: %s", params.Command, reason)
+	}
+	return nil, nil
+}
+
+// Func adapts a function taking the command's one argument, decoded as an
+// A, to a Handler. A command sent without arguments gets A's zero value.
+func Func[A, R any](fn func(ctx context.Context, arg A) (R, error)) Handler {
+	return func(ctx context.Context, args []json.RawMessage) (any, error) {
+		var arg A
+		if err := Decode(args, &arg); err != nil {
+			return nil, err
+		}
+		return fn(ctx, arg)
+	}
+}
+
+// Decode decodes the arguments into targets, pointers, in order. Targets
+// past the arguments sent are left untouched; arguments past the targets
+// are an error, as are arguments which don't decode. Errors are
+// CodeInvalidParams.
+func Decode(args []json.RawMessage, targets ...any) error {
+	if len(args) > len(targets) {
+		return jsonrpc2.NewError(jsonrpc2.CodeInvalidParams, "expected at most %d arguments, got %d", len(targets), len(args))
+	}
+	for i, arg := range args {
+		if err := json.Unmarshal(arg, targets[i]); err != nil {
+			return jsonrpc2.NewError(jsonrpc2.CodeInvalidParams, "argument %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
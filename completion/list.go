@@ -0,0 +1,159 @@
+// Package completion builds textDocument/completion results. A Builder
+// collects a response's items and produces a CompletionList which moves the
+// edit range and commit characters the items share into itemDefaults, when
+// the client supports them, so large lists aren't sent with the same values
+// on every item, and which can be cut to its first items and marked
+// incomplete. A Resolver answers completionItem/resolve, handing each item
+// back to the function registered for the kind of data stashed with it by
+// SetData, so documentation and edits can be computed for the item the user
+// picks rather than for every item.
+package completion
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Builder collects the items of one completion response.
+type Builder struct {
+	// ItemDefaults lists the CompletionList.itemDefaults properties the
+	// client supports; none are used when empty.
+	ItemDefaults []string
+
+	// Max, when positive, cuts the list to the Max items first in the
+	// order the client sorts them in, marking it incomplete.
+	Max int
+
+	items      []protocol.CompletionItem
+	incomplete bool
+}
+
+// NewBuilder returns a Builder using the item defaults caps declares.
+func NewBuilder(caps *protocol.ClientCapabilities) *Builder {
+	return &Builder{ItemDefaults: ItemDefaults(caps)}
+}
+
+// ItemDefaults returns the itemDefaults properties caps declares support
+// for. caps may be nil.
+func ItemDefaults(caps *protocol.ClientCapabilities) []string {
+	if caps == nil || caps.TextDocument == nil || caps.TextDocument.Completion == nil || caps.TextDocument.Completion.CompletionList == nil {
+		return nil
+	}
+	return caps.TextDocument.Completion.CompletionList.ItemDefaults
+}
+
+// Add adds items to the list.
+func (b *Builder) Add(items ...protocol.CompletionItem) {
+	b.items = append(b.items, items...)
+}
+
+// Len is the number of items added.
+func (b *Builder) Len() int {
+	return len(b.items)
+}
+
+// Incomplete marks the list incomplete, so the client asks again as the
+// user types, such as when lsp.BestEffort gathered only part of it.
+func (b *Builder) Incomplete() {
+	b.incomplete = true
+}
+
+// List returns the list of the items added. When at least two items
+// replace the same range, and every item has an edit, that range becomes
+// the default edit range and those items carry only their text; likewise,
+// when every item has commit characters, the set most share becomes the
+// default. Items keep values differing from the defaults.
+func (b *Builder) List() *protocol.CompletionList {
+	items := slices.Clone(b.items)
+	incomplete := b.incomplete
+	if b.Max > 0 && len(items) > b.Max {
+		slices.SortStableFunc(items, func(x, y protocol.CompletionItem) int {
+			return cmp.Compare(sortKey(&x), sortKey(&y))
+		})
+		clear(items[b.Max:])
+		items = items[:b.Max]
+		incomplete = true
+	}
+	if items == nil {
+		items = []protocol.CompletionItem{}
+	}
+
+	var defaults protocol.CompletionItemDefaults
+	if slices.Contains(b.ItemDefaults, "editRange") {
+		if r, ok := common(items, editRange); ok {
+			defaults.EditRange = &r
+			for i := range items {
+				item := &items[i]
+				if item.TextEdit.Range != r {
+					continue
+				}
+				if item.TextEdit.NewText != item.Label {
+					item.TextEditText = item.TextEdit.NewText
+				}
+				item.TextEdit = nil
+			}
+		}
+	}
+	if slices.Contains(b.ItemDefaults, "commitCharacters") {
+		if chars, ok := common(items, commitCharacters); ok {
+			for i := range items {
+				item := &items[i]
+				if commit, _ := commitCharacters(item); commit != chars {
+					continue
+				}
+				if defaults.CommitCharacters == nil {
+					defaults.CommitCharacters = item.CommitCharacters
+				}
+				item.CommitCharacters = nil
+			}
+		}
+	}
+
+	list := &protocol.CompletionList{IsIncomplete: incomplete, Items: items}
+	if defaults.EditRange != nil || defaults.CommitCharacters != nil {
+		list.ItemDefaults = &defaults
+	}
+	return list
+}
+
+// sortKey is what the client sorts an item by.
+func sortKey(item *protocol.CompletionItem) string {
+	if item.SortText != "" {
+		return item.SortText
+	}
+	return item.Label
+}
+
+func editRange(item *protocol.CompletionItem) (protocol.Range, bool) {
+	if item.TextEdit == nil {
+		return protocol.Range{}, false
+	}
+	return item.TextEdit.Range, true
+}
+
+func commitCharacters(item *protocol.CompletionItem) (string, bool) {
+	return strings.Join(item.CommitCharacters, "\x00"), len(item.CommitCharacters) > 0
+}
+
+// common returns the key most items have, the first such when several tie,
+// if every item has one and at least two share it. An item without a key
+// would take on the default, so none is used then.
+func common[K comparable](items []protocol.CompletionItem, key func(*protocol.CompletionItem) (K, bool)) (K, bool) {
+	counts := map[K]int{}
+	var best K
+	for i := range items {
+		k, ok := key(&items[i])
+		if !ok {
+			var zero K
+			return zero, false
+		}
+		counts[k]++
+		if counts[k] > counts[best] {
+			best = k
+		}
+	}
+	return best, counts[best] >= 2
+}
@@ -0,0 +1,92 @@
+package completion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// ResolveMethod is the request by which the client asks for the rest of an
+// item.
+const ResolveMethod = "completionItem/resolve"
+
+// ResolveFunc fills in the rest of item, such as its documentation or
+// additional edits, from the data stashed with it.
+type ResolveFunc func(ctx context.Context, item *protocol.CompletionItem, data json.RawMessage) error
+
+// data is what SetData stores in an item's Data.
+type data struct {
+	Kind string          `json:"k"`
+	Data json.RawMessage `json:"d,omitempty"`
+}
+
+// SetData stashes data, marshalled to JSON, in item for the ResolveFunc
+// registered under kind, such as "symbol" or "keyword", to resolve it with.
+// Keep data small, an identifier rather than the value it names: it is
+// sent with every item.
+func SetData(item *protocol.CompletionItem, kind string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("completion: data for %q: %w", kind, err)
+	}
+	stashed, err := json.Marshal(data{Kind: kind, Data: raw})
+	if err != nil {
+		return fmt.Errorf("completion: data for %q: %w", kind, err)
+	}
+	item.Data = stashed
+	return nil
+}
+
+// Resolver routes completionItem/resolve to the ResolveFunc registered for
+// the kind of data stashed in each item. The zero value is ready to use; it
+// is safe for concurrent use.
+type Resolver struct {
+	mu    sync.RWMutex
+	funcs map[string]ResolveFunc
+}
+
+// Register sets the ResolveFunc for items stashed under kind, replacing any
+// previous one.
+func (r *Resolver) Register(kind string, fn ResolveFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.funcs == nil {
+		r.funcs = map[string]ResolveFunc{}
+	}
+	r.funcs[kind] = fn
+}
+
+// Capability is the completionProvider server capability, with resolve
+// support, for the trigger characters given.
+func (r *Resolver) Capability(triggers ...string) protocol.CompletionOptions {
+	return protocol.CompletionOptions{TriggerCharacters: triggers, ResolveProvider: true}
+}
+
+// Route routes completionItem/resolve on mux to the Resolver.
+func (r *Resolver) Route(mux *jsonrpc2.Mux) {
+	jsonrpc2.RegisterMethod(mux, ResolveMethod, r.Resolve)
+}
+
+// Resolve handles completionItem/resolve. Items without data stashed by
+// SetData, or stashed under a kind nothing is registered for, such as by a
+// previous server process, are returned as they are.
+func (r *Resolver) Resolve(ctx context.Context, item *protocol.CompletionItem) (*protocol.CompletionItem, error) {
+	var stashed data
+	if len(item.Data) == 0 || json.Unmarshal(item.Data, &stashed) != nil || stashed.Kind == "" {
+		return item, nil
+	}
+	r.mu.RLock()
+	fn, ok := r.funcs[stashed.Kind]
+	r.mu.RUnlock()
+	if !ok {
+		return item, nil
+	}
+	if err := fn(ctx, item, stashed.Data); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -0,0 +1,215 @@
+// Package config keeps a server's copy of the user's settings. A Manager
+// fetches one section with workspace/configuration when the client answers
+// it, and from initializationOptions when it doesn't, decodes it over the
+// server's defaults, and refetches on workspace/didChangeConfiguration,
+// telling its watchers whenever the decoded settings change. Validate
+// reports the settings which don't fit their type, by JSON path.
+//
+// Clients which only notify servers that registered for
+// workspace/didChangeConfiguration need the server to register for it, with
+// lsp.Server.Register, once initialized.
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Method is the notification settings changes arrive in.
+const Method = "workspace/didChangeConfiguration"
+
+// Client is the session settings are fetched from; *lsp.Server is one.
+type Client interface {
+	Call(ctx context.Context, method string, params, result any) error
+	ClientSupports(path string) bool
+	InitializeParams() *protocol.InitializeParams
+}
+
+// Manager holds the settings of type T. T is decoded from JSON, so its
+// fields carry json tags as the settings' keys.
+type Manager[T any] struct {
+	client   Client
+	section  string
+	defaults []byte
+
+	mu       sync.Mutex
+	current  T
+	encoded  []byte
+	seq      int
+	watchers map[int]func(ctx context.Context, old, new T)
+
+	// loadMu serialises fetches, so an older one never overwrites a newer.
+	loadMu sync.Mutex
+}
+
+// New returns a Manager for section, such as "gopls" or "go.lsp": a path
+// of keys into the settings, the whole settings when empty. Until Load,
+// and wherever the user's settings leave a field out, it holds defaults.
+func New[T any](client Client, section string, defaults T) (*Manager[T], error) {
+	encoded, err := json.Marshal(defaults)
+	if err != nil {
+		return nil, fmt.Errorf("config: defaults: %w", err)
+	}
+	m := &Manager[T]{client: client, section: section, defaults: encoded, watchers: map[int]func(context.Context, T, T){}}
+	if m.current, err = m.decode(nil); err != nil {
+		return nil, err
+	}
+	m.encoded = encoded
+	return m, nil
+}
+
+// Register routes the client's change notifications from mux to the
+// Manager.
+func (m *Manager[T]) Register(mux *jsonrpc2.Mux) {
+	jsonrpc2.RegisterNotification(mux, Method, m.DidChangeConfiguration)
+}
+
+// Get returns the current settings. Reference fields, such as slices and
+// maps, are shared with the Manager and must not be modified.
+func (m *Manager[T]) Get() T {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// Watch calls fn with the old and new settings each time they change, until
+// the returned function is called. Watchers run in the order they were
+// added, on the goroutine which noticed the change.
+func (m *Manager[T]) Watch(fn func(ctx context.Context, old, new T)) (stop func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	id := m.seq
+	m.watchers[id] = fn
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.watchers, id)
+	}
+}
+
+// Load fetches the settings, as a server does once initialized: from
+// workspace/configuration if the client supports it, else from
+// initializationOptions.
+func (m *Manager[T]) Load(ctx context.Context) error {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	raw, err := m.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	return m.set(ctx, raw)
+}
+
+// DidChangeConfiguration handles workspace/didChangeConfiguration: the
+// settings are fetched anew from a client which answers
+// workspace/configuration, and otherwise taken from the notification.
+func (m *Manager[T]) DidChangeConfiguration(ctx context.Context, params *protocol.DidChangeConfigurationParams) error {
+	if m.pull() {
+		return m.Load(ctx)
+	}
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	return m.set(ctx, section(params.Settings, m.section))
+}
+
+func (m *Manager[T]) pull() bool {
+	return m.client.ClientSupports("workspace.configuration")
+}
+
+func (m *Manager[T]) fetch(ctx context.Context) (json.RawMessage, error) {
+	if !m.pull() {
+		var options json.RawMessage
+		if params := m.client.InitializeParams(); params != nil {
+			options = params.InitializationOptions
+		}
+		return section(options, m.section), nil
+	}
+	var result []json.RawMessage
+	err := m.client.Call(ctx, "workspace/configuration", &protocol.ConfigurationParams{
+		Items: []protocol.ConfigurationItem{{Section: m.section}},
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("config: fetching %q: %w", m.section, err)
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result[0], nil
+}
+
+// set decodes raw as the settings and tells the watchers if that changed
+// them.
+func (m *Manager[T]) set(ctx context.Context, raw json.RawMessage) error {
+	next, err := m.decode(raw)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("config: %q: %w", m.section, err)
+	}
+
+	m.mu.Lock()
+	if bytes.Equal(encoded, m.encoded) {
+		m.mu.Unlock()
+		return nil
+	}
+	old := m.current
+	m.current, m.encoded = next, encoded
+	ids := make([]int, 0, len(m.watchers))
+	for id := range m.watchers {
+		ids = append(ids, id)
+	}
+	fns := make([]func(context.Context, T, T), 0, len(ids))
+	sort.Ints(ids)
+	for _, id := range ids {
+		fns = append(fns, m.watchers[id])
+	}
+	m.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(ctx, old, next)
+	}
+	return nil
+}
+
+// decode builds settings from the defaults, then raw over them; a null or
+// missing raw leaves the defaults.
+func (m *Manager[T]) decode(raw json.RawMessage) (T, error) {
+	var out T
+	if err := json.Unmarshal(m.defaults, &out); err != nil {
+		return out, fmt.Errorf("config: defaults: %w", err)
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return out, nil
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return out, fmt.Errorf("config: decoding %q: %w", m.section, err)
+	}
+	return out, nil
+}
+
+// section is the value at the dotted path within settings, nil where it
+// has none.
+func section(settings json.RawMessage, path string) json.RawMessage {
+	if path == "" {
+		return settings
+	}
+	for _, key := range strings.Split(path, ".") {
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(settings, &obj) != nil {
+			return nil
+		}
+		settings = obj[key]
+	}
+	return settings
+}
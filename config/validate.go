@@ -0,0 +1,337 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Problem is a setting which doesn't fit the type it is decoded into.
+type Problem struct {
+	// Path is the setting's JSON path from the settings' root, such as
+	// "format.tabWidth" or "exclude[2]", empty for the root itself.
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// Validate checks the JSON settings in data against the type of schema, a
+// struct or a pointer to one whose fields' json tags name the settings, and
+// returns the keys it doesn't declare and the values of the wrong JSON type,
+// in path order. json.Unmarshal ignores the first and fails on the first of
+// the second without saying where, so a misspelt setting silently keeps its
+// default. Keys matching a field but for case are accepted, as
+// json.Unmarshal accepts them, and values of types decoding themselves are
+// checked by decoding them.
+func Validate(data []byte, schema any) []Problem {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if !json.Valid(data) {
+		return []Problem{{Message: "not valid JSON"}}
+	}
+	v := &validator{}
+	v.walk("", data, reflect.TypeOf(schema))
+	return v.problems
+}
+
+type validator struct {
+	problems []Problem
+}
+
+var (
+	unmarshalerType     = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	numberType          = reflect.TypeFor[json.Number]()
+)
+
+func (v *validator) report(path, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) walk(path string, data json.RawMessage, t reflect.Type) {
+	if t == nil {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	kind := jsonKind(data)
+	if kind == "null" {
+		// null leaves any field as it is.
+		return
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		if err := json.Unmarshal(data, reflect.New(t).Interface()); err != nil {
+			v.report(path, "invalid value: %v", err)
+		}
+		return
+	}
+	if kind == "string" && reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		if err := json.Unmarshal(data, reflect.New(t).Interface()); err != nil {
+			v.report(path, "invalid value: %v", err)
+		}
+		return
+	}
+	if t == numberType {
+		if kind != "number" {
+			v.report(path, "expected a number, got %s", article(kind))
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return
+	case reflect.Struct:
+		if kind != "object" {
+			break
+		}
+		var object map[string]json.RawMessage
+		_ = json.Unmarshal(data, &object)
+		fields := structFields(t)
+		for _, key := range sortedKeys(object) {
+			field, ok := fields.lookup(key)
+			if !ok {
+				if near := fields.nearest(key); near != "" {
+					v.report(join(path, key), "unknown setting; did you mean %q?", near)
+				} else {
+					v.report(join(path, key), "unknown setting")
+				}
+				continue
+			}
+			v.walk(join(path, key), object[key], field)
+		}
+		return
+	case reflect.Map:
+		if kind != "object" {
+			break
+		}
+		var object map[string]json.RawMessage
+		_ = json.Unmarshal(data, &object)
+		for _, key := range sortedKeys(object) {
+			v.walk(join(path, key), object[key], t.Elem())
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// Bytes are encoded as a base64 string.
+			if kind != "string" {
+				break
+			}
+			return
+		}
+		if kind != "array" {
+			break
+		}
+		var elems []json.RawMessage
+		_ = json.Unmarshal(data, &elems)
+		if t.Kind() == reflect.Array && len(elems) > t.Len() {
+			v.report(path, "expected at most %d elements, got %d", t.Len(), len(elems))
+		}
+		for i, elem := range elems {
+			v.walk(fmt.Sprintf("%s[%d]", path, i), elem, t.Elem())
+		}
+		return
+	case reflect.String:
+		if kind == "string" {
+			return
+		}
+	case reflect.Bool:
+		if kind == "boolean" {
+			return
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if kind != "number" {
+			break
+		}
+		if _, err := strconv.ParseInt(string(data), 10, t.Bits()); err != nil {
+			v.report(path, "expected an integer of %d bits, got %s", t.Bits(), data)
+		}
+		return
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if kind != "number" {
+			break
+		}
+		if _, err := strconv.ParseUint(string(data), 10, t.Bits()); err != nil {
+			v.report(path, "expected an unsigned integer of %d bits, got %s", t.Bits(), data)
+		}
+		return
+	case reflect.Float32, reflect.Float64:
+		if kind == "number" {
+			return
+		}
+	default:
+		return
+	}
+	v.report(path, "expected %s, got %s", article(expected(t)), article(kind))
+}
+
+// jsonKind names the JSON type of the valid value data.
+func jsonKind(data json.RawMessage) string {
+	data = bytes.TrimSpace(data)
+	switch data[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+	return "number"
+}
+
+// expected names the JSON type t is decoded from.
+func expected(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return "array"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	}
+	return "number"
+}
+
+func article(kind string) string {
+	switch kind {
+	case "null":
+		return kind
+	case "object", "array":
+		return "an " + kind
+	}
+	return "a " + kind
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(object map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fields are a struct's settings by name, as json.Unmarshal finds them.
+type fields map[string]reflect.Type
+
+func (f fields) lookup(key string) (reflect.Type, bool) {
+	if t, ok := f[key]; ok {
+		return t, true
+	}
+	for name, t := range f {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// nearest is the name closest to key, if within two edits of it.
+func (f fields) nearest(key string) string {
+	best, bestDistance := "", 3
+	for name := range f {
+		d := distance(strings.ToLower(name), strings.ToLower(key))
+		if d < bestDistance || d == bestDistance && name < best {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// structFields collects t's fields by their JSON names, promoting those of
+// untagged embedded structs as encoding/json does, shallower fields first.
+func structFields(t reflect.Type) fields {
+	f := fields{}
+	level := []reflect.Type{t}
+	for len(level) > 0 {
+		var next []reflect.Type
+		found := fields{}
+		for _, t := range level {
+			for i := range t.NumField() {
+				field := t.Field(i)
+				tag := field.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, _, _ := strings.Cut(tag, ",")
+				if field.Anonymous && name == "" {
+					ft := field.Type
+					for ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, ft)
+						continue
+					}
+				}
+				if !field.IsExported() {
+					continue
+				}
+				if name == "" {
+					name = field.Name
+				}
+				if _, ok := f[name]; !ok {
+					found[name] = field.Type
+				}
+			}
+		}
+		for name, ft := range found {
+			f[name] = ft
+		}
+		level = next
+	}
+	return f
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,53 @@
+package crashreport
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/pentops/lsplib/commands"
+	"github.com/pentops/lsplib/lsperror"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/uri"
+)
+
+// RevealCommand is the command revealing the latest bundle.
+const RevealCommand = "crashreport.reveal"
+
+// RegisterCommand registers RevealCommand with reg, which opens the
+// directory holding the latest bundle in the system's file manager through
+// window/showDocument, sent with client. It fails with CodeRequestFailed
+// before any bundle is written.
+func (r *Reporter) RegisterCommand(reg *commands.Registry, client commands.Caller) {
+	reg.Register(RevealCommand, func(ctx context.Context, _ []json.RawMessage) (any, error) {
+		latest := r.Latest()
+		if latest == "" {
+			return nil, lsperror.RequestFailed("%s: %v", RevealCommand, errNoReport)
+		}
+		params := &protocol.ShowDocumentParams{
+			URI:      protocol.URI(uri.FromPath(filepath.Dir(latest))),
+			External: true,
+		}
+		var result protocol.ShowDocumentResult
+		if err := client.Call(ctx, "window/showDocument", params, &result); err != nil {
+			return nil, err
+		}
+		if !result.Success {
+			return nil, lsperror.RequestFailed("%s: the client could not show %s", RevealCommand, latest)
+		}
+		return nil, nil
+	})
+}
+
+// Action is a code action running RevealCommand, for a server's
+// textDocument/codeAction results to offer once a bundle is written; nil
+// before then.
+func (r *Reporter) Action() *protocol.CodeAction {
+	if r.Latest() == "" {
+		return nil
+	}
+	return &protocol.CodeAction{
+		Title:   "Reveal crash report",
+		Command: &protocol.Command{Title: "Reveal crash report", Command: RevealCommand},
+	}
+}
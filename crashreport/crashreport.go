@@ -0,0 +1,275 @@
+// Package crashreport gathers what a bug report from an editor user needs
+// into a single zip when the server fails: the panic or error with its
+// stack, the last messages of the session with document text and secrets
+// redacted, a snapshot of the settings, and the server's and Go's versions.
+// A Reporter keeps the session's recent messages once set as the
+// connection's Rewrite, writes a bundle from a deferred Recover or from
+// middleware.RecoverOptions.OnPanic, and logs its path; a command and code
+// action reveal the latest bundle to the user.
+package crashreport
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/replay"
+)
+
+// Options configures a Reporter.
+type Options struct {
+	// Dir is where bundles are written, os.TempDir when empty.
+	Dir string
+
+	// Name and Version identify the server in the bundle, as
+	// lsp.Options.Name and Version do to the client.
+	Name    string
+	Version string
+
+	// History is how many of the session's most recent messages a bundle
+	// holds, 200 when zero.
+	History int
+
+	// Redact returns a message as the bundle keeps it, RedactText when nil.
+	Redact func(msg json.RawMessage) json.RawMessage
+
+	// Config, when set, returns the settings in effect, such as a
+	// config.Manager's Get, which the bundle keeps as JSON.
+	Config func() any
+
+	// Log is told each bundle's path, os.Stderr when nil.
+	Log io.Writer
+
+	Clock clock.Clock
+}
+
+// Reporter writes crash report bundles for one session. It is safe for
+// concurrent use.
+type Reporter struct {
+	opts  Options
+	clock clock.Clock
+
+	mu     sync.Mutex
+	frames []replay.Frame
+	next   int
+	latest string
+}
+
+// New returns a Reporter.
+func New(opts Options) *Reporter {
+	if opts.History <= 0 {
+		opts.History = 200
+	}
+	if opts.Redact == nil {
+		opts.Redact = RedactText
+	}
+	if opts.Log == nil {
+		opts.Log = os.Stderr
+	}
+	return &Reporter{opts: opts, clock: clock.Or(opts.Clock)}
+}
+
+// ServerRewrite keeps the messages a server's connection reads and writes,
+// leaving them as they are. Set it as lsp.Options.Conn.Rewrite.
+func (r *Reporter) ServerRewrite() jsonrpc2.Rewrite {
+	return jsonrpc2.Rewrite{In: r.keeper(replay.FromClient), Out: r.keeper(replay.FromServer)}
+}
+
+func (r *Reporter) keeper(from replay.From) func([]byte) ([]byte, error) {
+	return func(msg []byte) ([]byte, error) {
+		frame := replay.Frame{Time: r.clock.Now(), From: from, Message: append(json.RawMessage(nil), msg...)}
+		r.mu.Lock()
+		if len(r.frames) < r.opts.History {
+			r.frames = append(r.frames, frame)
+		} else {
+			r.frames[r.next] = frame
+			r.next = (r.next + 1) % len(r.frames)
+		}
+		r.mu.Unlock()
+		return msg, nil
+	}
+}
+
+// history is the kept messages, oldest first.
+func (r *Reporter) history() []replay.Frame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	frames := make([]replay.Frame, 0, len(r.frames))
+	frames = append(frames, r.frames[r.next:]...)
+	return append(frames, r.frames[:r.next]...)
+}
+
+// Latest is the path of the last bundle written, empty if none has been.
+func (r *Reporter) Latest() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.latest
+}
+
+// Recover, deferred at the top of main and of each goroutine the server
+// starts, writes a bundle when the goroutine panics and panics again with
+// the same value, so the process still crashes, with the bundle's path in
+// its crash log.
+//
+//	defer reporter.Recover()
+func (r *Reporter) Recover() {
+	value := recover()
+	if value == nil {
+		return
+	}
+	_, _ = r.Report(value, debug.Stack())
+	panic(value)
+}
+
+// OnPanic writes a bundle for a handler's panic; set it as
+// middleware.RecoverOptions.OnPanic.
+func (r *Reporter) OnPanic(_ context.Context, method string, value any, stack []byte) {
+	_, _ = r.Report(fmt.Errorf("%s panicked: %v", method, value), stack)
+}
+
+// Report writes a bundle for cause, a panic's value or a fatal error, with
+// the stack where it arose, nil for none, logs its path to Options.Log and
+// returns it.
+func (r *Reporter) Report(cause any, stack []byte) (string, error) {
+	path, err := r.write(cause, stack)
+	if err != nil {
+		fmt.Fprintf(r.opts.Log, "crashreport: %v\n", err)
+		return "", fmt.Errorf("crashreport: %w", err)
+	}
+	r.mu.Lock()
+	r.latest = path
+	r.mu.Unlock()
+	fmt.Fprintf(r.opts.Log, "crashreport: report written to %s\n", path)
+	return path, nil
+}
+
+func (r *Reporter) write(cause any, stack []byte) (path string, err error) {
+	dir := r.opts.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	now := r.clock.Now()
+	f, err := os.CreateTemp(dir, "crash-"+now.UTC().Format("20060102-150405")+"-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	z := zip.NewWriter(f)
+	add := func(name string, data []byte) error {
+		w, err := z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	if err := add("stack.txt", []byte(describe(cause, stack))); err != nil {
+		return "", err
+	}
+	if err := add("trace.jsonl", r.trace()); err != nil {
+		return "", err
+	}
+	if r.opts.Config != nil {
+		if err := add("config.json", r.config()); err != nil {
+			return "", err
+		}
+	}
+	if err := add("version.json", r.version(now)); err != nil {
+		return "", err
+	}
+	if err := z.Close(); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func describe(cause any, stack []byte) string {
+	kind := "panic"
+	if _, ok := cause.(error); ok {
+		kind = "error"
+	}
+	s := fmt.Sprintf("%s: %v\n", kind, cause)
+	if len(stack) > 0 {
+		s += "\n" + string(stack)
+	}
+	return s
+}
+
+// trace is the kept messages, redacted, as a recording replay.Read reads.
+func (r *Reporter) trace() []byte {
+	var out []byte
+	for _, frame := range r.history() {
+		frame.Message = r.opts.Redact(frame.Message)
+		line, err := json.Marshal(frame)
+		if err != nil {
+			continue
+		}
+		out = append(append(out, line...), '\n')
+	}
+	return out
+}
+
+// config is the settings snapshot, redacted as messages are, or why there
+// is none: the server is failing, and the bundle is wanted all the more if
+// Config fails too.
+func (r *Reporter) config() (out []byte) {
+	defer func() {
+		if value := recover(); value != nil {
+			out, _ = json.Marshal(map[string]string{"error": fmt.Sprintf("Config panicked: %v", value)})
+		}
+	}()
+	out, err := json.Marshal(r.opts.Config())
+	if err != nil {
+		out, _ = json.Marshal(map[string]string{"error": err.Error()})
+		return out
+	}
+	var buf bytes.Buffer
+	if json.Indent(&buf, r.opts.Redact(out), "", "  ") != nil {
+		return out
+	}
+	return buf.Bytes()
+}
+
+func (r *Reporter) version(now time.Time) []byte {
+	info := map[string]any{
+		"name":    r.opts.Name,
+		"version": r.opts.Version,
+		"go":      runtime.Version(),
+		"os":      runtime.GOOS,
+		"arch":    runtime.GOARCH,
+		"time":    now.UTC(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info["module"] = build.Main.Path + "@" + build.Main.Version
+		settings := map[string]string{}
+		for _, s := range build.Settings {
+			settings[s.Key] = s.Value
+		}
+		info["build"] = settings
+	}
+	out, _ := json.MarshalIndent(info, "", "  ")
+	return out
+}
+
+// errNoReport is returned by the reveal command before any bundle is
+// written.
+var errNoReport = errors.New("no crash report has been written")
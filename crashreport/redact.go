@@ -0,0 +1,71 @@
+package crashreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// textKeys are the keys whose strings hold the user's code in LSP
+// messages: document and cell text, edits, completions and hover content.
+var textKeys = map[string]bool{
+	"text":       true,
+	"newText":    true,
+	"insertText": true,
+	"value":      true,
+	"contents":   true,
+}
+
+// secretWords mark keys, such as settings', whose strings are credentials.
+var secretWords = []string{"password", "secret", "apikey", "accesstoken", "authorization"}
+
+// RedactText returns msg with the strings holding the user's code, and
+// those under keys naming credentials, replaced by their length, leaving
+// methods, URIs, positions and versions for diagnosis.
+func RedactText(msg json.RawMessage) json.RawMessage {
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	dec.UseNumber()
+	var v any
+	if dec.Decode(&v) != nil {
+		return json.RawMessage(fmt.Sprintf("%q", fmt.Sprintf("[redacted %d bytes]", len(msg))))
+	}
+	out, err := json.Marshal(redact(v, ""))
+	if err != nil {
+		return msg
+	}
+	return out
+}
+
+func redact(v any, key string) any {
+	switch v := v.(type) {
+	case string:
+		if textKeys[key] || secret(key) {
+			return fmt.Sprintf("[redacted %d bytes]", len(v))
+		}
+		return v
+	case map[string]any:
+		for k, value := range v {
+			v[k] = redact(value, k)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			// The elements of an array inherit its key, as
+			// MarkedString[] contents do.
+			v[i] = redact(value, key)
+		}
+		return v
+	}
+	return v
+}
+
+func secret(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range secretWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
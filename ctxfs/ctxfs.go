@@ -0,0 +1,183 @@
+// Package ctxfs reads files and directories under a context, for the reads
+// a server does while a request or an indexing run waits, such as going to
+// a definition in a file which isn't open. A read on a slow or hung network
+// filesystem can block in the kernel for far longer than the request
+// lives, and no context reaches it there; FS's operations return as soon
+// as the context is done or their timeout passes, leaving the blocked call
+// to finish in the background and its result to be dropped.
+package ctxfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrTimeout is the error of an operation which ran past FS.Timeout. It
+// matches os.ErrDeadlineExceeded.
+var ErrTimeout = fmt.Errorf("ctxfs: operation timed out: %w", os.ErrDeadlineExceeded)
+
+// chunkSize is how much ReadFile reads between checks of its context, so
+// an abandoned read of a large file stops soon after.
+const chunkSize = 64 << 10
+
+// FS bounds the operations on the local filesystem. An operation cut short
+// fails with an *fs.PathError whose Err is the context's cause, ErrTimeout
+// when it was Timeout. The zero value has no timeout.
+type FS struct {
+	// Timeout bounds each operation, one file's read or one directory's
+	// listing, rather than a whole walk.
+	Timeout time.Duration
+}
+
+// ReadFile reads the file name, as os.ReadFile does.
+func (f FS) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	return do(ctx, f, "read", name, func(ctx context.Context) ([]byte, error) {
+		return readFile(ctx, name, -1)
+	})
+}
+
+// ReadHead reads at most n bytes from the start of the file name, as when
+// sniffing whether it is binary.
+func (f FS) ReadHead(ctx context.Context, name string, n int) ([]byte, error) {
+	return do(ctx, f, "read", name, func(ctx context.Context) ([]byte, error) {
+		return readFile(ctx, name, n)
+	})
+}
+
+// ReadDir lists the directory name, as os.ReadDir does.
+func (f FS) ReadDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	return do(ctx, f, "readdir", name, func(context.Context) ([]fs.DirEntry, error) {
+		return os.ReadDir(name)
+	})
+}
+
+// Stat returns the FileInfo of the file name, as os.Stat does.
+func (f FS) Stat(ctx context.Context, name string) (fs.FileInfo, error) {
+	return do(ctx, f, "stat", name, func(context.Context) (fs.FileInfo, error) {
+		return os.Stat(name)
+	})
+}
+
+// WalkDir walks the tree at root as filepath.WalkDir does, each directory
+// listed with ReadDir, stopping with the context's cause when ctx is done.
+func (f FS) WalkDir(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	info, err := f.Stat(ctx, root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = f.walkDir(ctx, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func (f FS) walkDir(ctx context.Context, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	entries, err := f.ReadDir(ctx, path)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		// As filepath.WalkDir does, report the failed listing to fn.
+		if err = fn(path, d, err); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if err := f.walkDir(ctx, filepath.Join(path, entry.Name()), entry, fn); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// do runs fn, returning when it does or when ctx is done or f's timeout
+// passes, whichever is first.
+func do[T any](ctx context.Context, f FS, op, name string, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	if ctx.Err() != nil {
+		return zero, &fs.PathError{Op: op, Path: name, Err: context.Cause(ctx)}
+	}
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, f.Timeout, ErrTimeout)
+		defer cancel()
+	}
+	type result struct {
+		v   T
+		err error
+	}
+	// Buffered, so an abandoned fn doesn't block on sending.
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn(ctx)
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		return zero, &fs.PathError{Op: op, Path: name, Err: context.Cause(ctx)}
+	}
+}
+
+// readFile reads up to limit bytes of the file name, all of it when limit
+// is negative, giving up between chunks once ctx is done.
+func readFile(ctx context.Context, name string, limit int) ([]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	size := limit
+	if limit < 0 {
+		size = 512
+		if info, err := file.Stat(); err == nil && info.Size() > 0 && int64(int(info.Size())) == info.Size() {
+			size = int(info.Size()) + 1
+		}
+	}
+	data := make([]byte, 0, size)
+	for limit < 0 || len(data) < limit {
+		if ctx.Err() != nil {
+			return nil, &fs.PathError{Op: "read", Path: name, Err: context.Cause(ctx)}
+		}
+		if len(data) == cap(data) {
+			data = append(data, 0)[:len(data)]
+		}
+		end := min(cap(data), len(data)+chunkSize)
+		if limit >= 0 {
+			end = min(end, limit)
+		}
+		n, err := file.Read(data[len(data):end])
+		data = data[:len(data)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,324 @@
+// Package dap is a minimal Debug Adapter Protocol connection, sharing the
+// Content-Length framing of the LSP transport so one binary can serve both
+// protocols.
+//
+// The message bodies (LaunchRequestArguments, StoppedEvent and so on) are
+// generated from the upstream schema by cmd/dapschema; this file only
+// implements the envelope and the request/response/event plumbing.
+package dap
+
+//go:generate go run ../cmd/dapschema -out types.go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pentops/lsplib/framing"
+)
+
+const (
+	TypeRequest  = "request"
+	TypeResponse = "response"
+	TypeEvent    = "event"
+)
+
+// Message is the union of the protocol's Request, Response and Event
+// envelopes, discriminated by Type.
+type Message struct {
+	Seq  int    `json:"seq"`
+	Type string `json:"type"`
+
+	// Request and Response
+	Command string `json:"command,omitempty"`
+
+	// Request
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+
+	// Response
+	RequestSeq int    `json:"request_seq,omitempty"`
+	Success    *bool  `json:"success,omitempty"`
+	Message    string `json:"message,omitempty"`
+
+	// Response and Event
+	Body json.RawMessage `json:"body,omitempty"`
+
+	// Event
+	Event string `json:"event,omitempty"`
+}
+
+// Error is returned by handlers to fail a request with a structured error
+// body. Any other error fails the request with its text as the message.
+type Error struct {
+	ID       int    `json:"id"`
+	Format   string `json:"format"`
+	ShowUser bool   `json:"showUser,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Format
+}
+
+// Handler serves requests from the client (the IDE).
+type Handler interface {
+	HandleRequest(ctx context.Context, req *Message) (body any, err error)
+}
+
+// HandlerFunc adapts a function to the Handler interface.
+type HandlerFunc func(ctx context.Context, req *Message) (any, error)
+
+func (f HandlerFunc) HandleRequest(ctx context.Context, req *Message) (any, error) {
+	return f(ctx, req)
+}
+
+// Conn is one side of a DAP session.
+type Conn struct {
+	reader framing.Reader
+
+	writeMu sync.Mutex
+	writer  framing.Writer
+	seq     int
+
+	pendingMu sync.Mutex
+	pending   map[int]chan *Message
+}
+
+// NewConn wraps a stream, usually stdin/stdout or an accepted socket.
+func NewConn(rw io.ReadWriter) *Conn {
+	return &Conn{
+		reader:  framing.NewHeaderReader(rw),
+		writer:  framing.NewHeaderWriter(rw),
+		pending: map[int]chan *Message{},
+	}
+}
+
+// Run reads messages until the stream ends. Requests are handed to h one at
+// a time in arrival order, on a separate goroutine from the reader so that
+// handlers may themselves make reverse requests.
+func (c *Conn) Run(ctx context.Context, h Handler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	requests := &queue{wake: make(chan struct{}, 1)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			req, ok := requests.pop()
+			if !ok {
+				return
+			}
+			c.serve(ctx, h, req)
+		}
+	}()
+
+	err := c.readLoop(requests)
+	requests.close()
+	cancel()
+	<-done
+
+	c.pendingMu.Lock()
+	for seq, ch := range c.pending {
+		close(ch)
+		delete(c.pending, seq)
+	}
+	c.pendingMu.Unlock()
+
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+func (c *Conn) readLoop(requests *queue) error {
+	for {
+		body, err := c.reader.ReadMessage()
+		if err != nil {
+			return err
+		}
+		msg := &Message{}
+		if err := json.Unmarshal(body, msg); err != nil {
+			return fmt.Errorf("decoding message: %w", err)
+		}
+		switch msg.Type {
+		case TypeRequest:
+			requests.push(msg)
+		case TypeResponse:
+			c.pendingMu.Lock()
+			ch, ok := c.pending[msg.RequestSeq]
+			delete(c.pending, msg.RequestSeq)
+			c.pendingMu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		}
+	}
+}
+
+func (c *Conn) serve(ctx context.Context, h Handler, req *Message) {
+	body, err := h.HandleRequest(ctx, req)
+	res := &Message{
+		Type:       TypeResponse,
+		RequestSeq: req.Seq,
+		Command:    req.Command,
+	}
+	success := err == nil
+	res.Success = &success
+	if err != nil {
+		res.Message = err.Error()
+		var dapErr *Error
+		if errors.As(err, &dapErr) {
+			body = struct {
+				Error *Error `json:"error"`
+			}{dapErr}
+		}
+	}
+	if body != nil {
+		raw, mErr := json.Marshal(body)
+		if mErr != nil {
+			success = false
+			res.Message = mErr.Error()
+		} else {
+			res.Body = raw
+		}
+	}
+	_ = c.send(res)
+}
+
+// SendEvent emits an event to the client.
+func (c *Conn) SendEvent(event string, body any) error {
+	msg := &Message{Type: TypeEvent, Event: event}
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		msg.Body = raw
+	}
+	return c.send(msg)
+}
+
+// Request sends a reverse request (e.g. runInTerminal) and decodes the
+// response body into result, which may be nil.
+func (c *Conn) Request(ctx context.Context, command string, args, result any) error {
+	msg := &Message{Type: TypeRequest, Command: command}
+	if args != nil {
+		raw, err := json.Marshal(args)
+		if err != nil {
+			return err
+		}
+		msg.Arguments = raw
+	}
+
+	ch := make(chan *Message, 1)
+	seq, err := c.sendWith(msg, func(seq int) {
+		c.pendingMu.Lock()
+		c.pending[seq] = ch
+		c.pendingMu.Unlock()
+	})
+	if err != nil {
+		c.forget(seq)
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		c.forget(seq)
+		return ctx.Err()
+	case res, ok := <-ch:
+		if !ok {
+			return io.ErrClosedPipe
+		}
+		if res.Success == nil || !*res.Success {
+			return fmt.Errorf("%s failed: %s", command, res.Message)
+		}
+		if result != nil && len(res.Body) > 0 {
+			return json.Unmarshal(res.Body, result)
+		}
+		return nil
+	}
+}
+
+// forget drops the pending reverse request seq, which will never be
+// answered, or whose answer is no longer wanted.
+func (c *Conn) forget(seq int) {
+	c.pendingMu.Lock()
+	delete(c.pending, seq)
+	c.pendingMu.Unlock()
+}
+
+func (c *Conn) send(msg *Message) error {
+	_, err := c.sendWith(msg, nil)
+	return err
+}
+
+// sendWith assigns the next sequence number and writes the message; before
+// writing it calls register, so a response can never beat the registration.
+func (c *Conn) sendWith(msg *Message, register func(seq int)) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.seq++
+	msg.Seq = c.seq
+	if register != nil {
+		register(msg.Seq)
+	}
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return msg.Seq, err
+	}
+	return msg.Seq, c.writer.WriteMessage(raw)
+}
+
+// queue holds the requests read until they are served. It is unbounded: the
+// reader must never wait on a handler, or a handler waiting on the response
+// to a reverse request would never get it.
+type queue struct {
+	mu     sync.Mutex
+	items  []*Message
+	closed bool
+	wake   chan struct{}
+}
+
+func (q *queue) push(req *Message) {
+	q.mu.Lock()
+	q.items = append(q.items, req)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// close ends the queue once the requests in it are served.
+func (q *queue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue) pop() (*Message, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			req := q.items[0]
+			q.items[0] = nil
+			q.items = q.items[1:]
+			q.mu.Unlock()
+			return req, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+		if closed {
+			return nil, false
+		}
+		<-q.wake
+	}
+}
@@ -0,0 +1,90 @@
+package dap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pentops/lsplib/framing"
+)
+
+func TestReverseRequestBehindBurst(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	conn := NewConn(local)
+	ran := make(chan error, 1)
+	go func() {
+		ran <- conn.Run(context.Background(), HandlerFunc(func(ctx context.Context, req *Message) (any, error) {
+			if req.Command == "launch" {
+				return nil, conn.Request(ctx, "runInTerminal", nil, nil)
+			}
+			return nil, nil
+		}))
+	}()
+	r, w := framing.NewHeaderReader(remote), framing.NewHeaderWriter(remote)
+	write := func(msg string) {
+		t.Helper()
+		if err := w.WriteMessage([]byte(msg)); err != nil {
+			t.Fatalf("writing %s: %v", msg, err)
+		}
+	}
+	read := func() *Message {
+		t.Helper()
+		body, err := r.ReadMessage()
+		if err != nil {
+			t.Fatalf("reading: %v", err)
+		}
+		msg := &Message{}
+		if err := json.Unmarshal(body, msg); err != nil {
+			t.Fatalf("decoding %s: %v", body, err)
+		}
+		return msg
+	}
+
+	write(`{"seq":1,"type":"request","command":"launch"}`)
+	reverse := read()
+	if reverse.Command != "runInTerminal" {
+		t.Fatalf("read %+v, want the runInTerminal request", reverse)
+	}
+	// The requests queued behind the blocked handler don't keep the reader
+	// from its response.
+	const burst = 100
+	for seq := 2; seq < 2+burst; seq++ {
+		write(`{"seq":` + strconv.Itoa(seq) + `,"type":"request","command":"threads"}`)
+	}
+	write(`{"seq":` + strconv.Itoa(2+burst) + `,"type":"response","request_seq":` + strconv.Itoa(reverse.Seq) + `,"command":"runInTerminal","success":true}`)
+	for want := 1; want < 2+burst; want++ {
+		res := read()
+		if res.RequestSeq != want || res.Success == nil || !*res.Success {
+			t.Fatalf("read %+v, want the successful response to request %d", res, want)
+		}
+	}
+	remote.Close()
+	if err := <-ran; err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestRequestWriteFails(t *testing.T) {
+	conn := NewConn(struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(""), failingWriter{}})
+	if err := conn.Request(context.Background(), "runInTerminal", nil, nil); err == nil {
+		t.Fatal("Request succeeded writing to a broken stream")
+	}
+	if n := len(conn.pending); n != 0 {
+		t.Fatalf("%d requests still pending after the write failed", n)
+	}
+}
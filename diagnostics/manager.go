@@ -0,0 +1,317 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/textsync"
+)
+
+// NoVersion marks diagnostics which aren't tied to a document version,
+// such as those for files the client doesn't have open.
+const NoVersion int32 = -1
+
+// Notifier sends notifications to the client; *lsp.Server is one.
+type Notifier interface {
+	Notify(ctx context.Context, method string, params any) error
+}
+
+// ManagerOptions configures a Manager.
+type ManagerOptions struct {
+	// Delay is how long the Manager waits for further updates before
+	// publishing, 200ms when zero. Each update restarts the wait, up to
+	// MaxDelay, 1s when zero, after the first unpublished one.
+	Delay    time.Duration
+	MaxDelay time.Duration
+
+	// Version reports a document's current version, and whether it is
+	// open. Diagnostics computed for an older version are dropped rather
+	// than published. Nil skips the check; Track sets it.
+	Version func(uri protocol.DocumentURI) (int32, bool)
+
+	// Case controls URI comparison.
+	Case fscase.Sensitivity
+
+	// SkipUnchanged doesn't publish diagnostics equal to those last
+	// published for the document, sparing the client from redrawing them.
+	SkipUnchanged bool
+
+	// Pull keeps each document's diagnostics for the client to pull with
+	// textDocument/diagnostic, answered by Document, or
+	// workspace/diagnostic, answered by Workspace, instead of publishing
+	// them. Set takes effect at once, without the debounce delay.
+	Pull bool
+
+	// Compute analyzes a document when the client pulls its diagnostics and
+	// none were Set or computed from its current version. Nil answers pulls
+	// with what was Set.
+	Compute func(ctx context.Context, uri protocol.DocumentURI) ([]protocol.Diagnostic, error)
+
+	// PageSize is the most documents one workspace/diagnostic response
+	// reports, 100 when zero.
+	PageSize int
+
+	// OnError is told about publishes which fail.
+	OnError func(uri protocol.DocumentURI, err error)
+
+	Clock clock.Clock
+}
+
+// Manager publishes the diagnostics analyses produce. They Set results as
+// they finish; the Manager coalesces rapid updates, drops results for
+// versions the user has already typed past, and sends what remains as
+// textDocument/publishDiagnostics in one batch, or, with Pull, keeps them
+// for the client to pull. It is safe for concurrent use.
+type Manager struct {
+	notifier Notifier
+	opts     ManagerOptions
+	clock    clock.Clock
+
+	mu      sync.Mutex
+	pending map[string]*entry
+	// latest is the newest version Set for each URI, so a slow analysis of
+	// an old version can't overwrite a newer result.
+	latest map[string]int32
+	timer  clock.Timer
+	first  time.Time
+	// seq orders Sets against Clears: cleared holds the seq at which each
+	// URI was last cleared, so a batch taken before is not published after.
+	seq     uint64
+	cleared map[string]uint64
+	// history is the last diagnostics published, or set when pulling, for
+	// each URI.
+	history  map[string]*record
+	resultID uint64
+	// changed is closed when history next changes, waking workspace pulls
+	// waiting for something to report; nil until one waits.
+	changed chan struct{}
+
+	// publishMu keeps batches in order.
+	publishMu sync.Mutex
+}
+
+type entry struct {
+	key     string
+	seq     uint64
+	uri     protocol.DocumentURI
+	version int32
+	diags   []protocol.Diagnostic
+}
+
+// record is a document's diagnostics as the client last had them, with the
+// result id pulls refer to them by.
+type record struct {
+	id      string
+	encoded string
+	uri     protocol.DocumentURI
+	version int32
+	diags   []protocol.Diagnostic
+}
+
+// NewManager returns a Manager publishing through n.
+func NewManager(n Notifier, opts ManagerOptions) *Manager {
+	if opts.Delay <= 0 {
+		opts.Delay = 200 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = time.Second
+	}
+	return &Manager{
+		notifier: n,
+		opts:     opts,
+		clock:    clock.Or(opts.Clock),
+		pending:  map[string]*entry{},
+		latest:   map[string]int32{},
+		cleared:  map[string]uint64{},
+		history:  map[string]*record{},
+	}
+}
+
+// Track gates publishes on the versions of store's documents and clears a
+// document's diagnostics when it is closed. Call it before serving.
+func (m *Manager) Track(store *textsync.Store) {
+	m.opts.Version = func(uri protocol.DocumentURI) (int32, bool) {
+		doc, ok := store.Get(uri)
+		return doc.Version, ok
+	}
+	store.OnEvent(func(ctx context.Context, event textsync.Event) {
+		if event.Kind == textsync.Closed {
+			_ = m.Clear(ctx, event.Document.URI)
+		}
+	})
+}
+
+// Set replaces a document's diagnostics, computed from the given version
+// of it or NoVersion. They are published after the debounce delay unless
+// replaced first, or dropped if the document has moved on by then.
+func (m *Manager) Set(uri protocol.DocumentURI, version int32, diags []protocol.Diagnostic) {
+	key := m.opts.Case.URIKey(string(uri))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if latest, ok := m.latest[key]; ok && version != NoVersion && version < latest {
+		return
+	}
+	if version != NoVersion {
+		m.latest[key] = version
+	}
+	if diags == nil {
+		diags = []protocol.Diagnostic{}
+	}
+	if m.opts.Pull {
+		m.remember(key, uri, version, diags)
+		return
+	}
+	m.seq++
+	m.pending[key] = &entry{key: key, seq: m.seq, uri: uri, version: version, diags: diags}
+
+	now := m.clock.Now()
+	switch {
+	case m.timer == nil:
+		m.first = now
+		m.timer = m.clock.AfterFunc(m.opts.Delay, m.fire)
+	case now.Add(m.opts.Delay).Sub(m.first) <= m.opts.MaxDelay:
+		m.timer.Reset(m.opts.Delay)
+	}
+}
+
+// Clear publishes an empty set of diagnostics for a document at once,
+// discarding any pending ones, as when the document is closed or deleted.
+// When pulling, it forgets them.
+func (m *Manager) Clear(ctx context.Context, uri protocol.DocumentURI) error {
+	key := m.opts.Case.URIKey(string(uri))
+	m.mu.Lock()
+	delete(m.pending, key)
+	delete(m.latest, key)
+	m.seq++
+	m.cleared[key] = m.seq
+	if _, ok := m.history[key]; ok {
+		delete(m.history, key)
+		m.notifyChanged()
+	}
+	m.mu.Unlock()
+	if m.opts.Pull {
+		return nil
+	}
+
+	m.publishMu.Lock()
+	defer m.publishMu.Unlock()
+	return m.notifier.Notify(ctx, "textDocument/publishDiagnostics", &protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: []protocol.Diagnostic{},
+	})
+}
+
+// Flush publishes pending diagnostics now, as on shutdown.
+func (m *Manager) Flush(ctx context.Context) {
+	m.mu.Lock()
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	batch := m.pending
+	m.pending = map[string]*entry{}
+	m.mu.Unlock()
+	m.publish(ctx, batch)
+}
+
+func (m *Manager) fire() {
+	m.Flush(context.Background())
+}
+
+// publish sends a batch in URI order, skipping entries for versions the
+// document no longer has and those cleared since they were set.
+func (m *Manager) publish(ctx context.Context, batch map[string]*entry) {
+	entries := make([]*entry, 0, len(batch))
+	for _, e := range batch {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].uri < entries[j].uri })
+
+	m.publishMu.Lock()
+	defer m.publishMu.Unlock()
+	for _, e := range entries {
+		m.mu.Lock()
+		cleared := m.cleared[e.key] > e.seq
+		unchanged := m.opts.SkipUnchanged && m.unchanged(e.key, e.diags)
+		m.mu.Unlock()
+		if cleared || unchanged {
+			continue
+		}
+		params := &protocol.PublishDiagnosticsParams{URI: e.uri, Diagnostics: e.diags}
+		if e.version != NoVersion {
+			if m.opts.Version != nil {
+				if current, open := m.opts.Version(e.uri); !open || current != e.version {
+					continue
+				}
+			}
+			version := e.version
+			params.Version = &version
+		}
+		if err := m.notifier.Notify(ctx, "textDocument/publishDiagnostics", params); err != nil {
+			if m.opts.OnError != nil {
+				m.opts.OnError(e.uri, err)
+			}
+			continue
+		}
+		m.mu.Lock()
+		m.remember(e.key, e.uri, e.version, e.diags)
+		m.mu.Unlock()
+	}
+}
+
+// Report is the report for uri's diagnostics, set when pulling or last
+// published otherwise: Unchanged when they are still those of
+// previousResultID, Full otherwise.
+func (m *Manager) Report(uri protocol.DocumentURI, previousResultID string) protocol.DocumentDiagnosticReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.history[m.opts.Case.URIKey(string(uri))]
+	if !ok {
+		return protocol.DocumentDiagnosticReport{Full: &protocol.FullDocumentDiagnosticReport{Kind: protocol.DiagnosticReportFull, Items: []protocol.Diagnostic{}}}
+	}
+	if previousResultID != "" && previousResultID == rec.id {
+		return protocol.DocumentDiagnosticReport{Unchanged: &protocol.UnchangedDocumentDiagnosticReport{Kind: protocol.DiagnosticReportUnchanged, ResultID: rec.id}}
+	}
+	return protocol.DocumentDiagnosticReport{Full: &protocol.FullDocumentDiagnosticReport{Kind: protocol.DiagnosticReportFull, ResultID: rec.id, Items: rec.diags}}
+}
+
+// unchanged reports whether diags are those the client last had for key,
+// with mu held.
+func (m *Manager) unchanged(key string, diags []protocol.Diagnostic) bool {
+	rec, ok := m.history[key]
+	return ok && rec.encoded == encode(diags)
+}
+
+// remember records diags as the client's for key, under a new result id if
+// they changed, with mu held.
+func (m *Manager) remember(key string, uri protocol.DocumentURI, version int32, diags []protocol.Diagnostic) {
+	encoded := encode(diags)
+	if rec, ok := m.history[key]; ok && rec.encoded == encoded {
+		rec.version = version
+		return
+	}
+	m.resultID++
+	m.history[key] = &record{id: strconv.FormatUint(m.resultID, 10), encoded: encoded, uri: uri, version: version, diags: diags}
+	m.notifyChanged()
+}
+
+// notifyChanged wakes the workspace pulls waiting for a change, with mu
+// held.
+func (m *Manager) notifyChanged() {
+	if m.changed != nil {
+		close(m.changed)
+		m.changed = nil
+	}
+}
+
+func encode(diags []protocol.Diagnostic) string {
+	data, _ := json.Marshal(diags)
+	return string(data)
+}
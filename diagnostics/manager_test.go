@@ -0,0 +1,144 @@
+package diagnostics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pentops/lsplib/lsptest"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// published is a publishDiagnostics sent, and when.
+type published struct {
+	at     time.Duration
+	params *protocol.PublishDiagnosticsParams
+}
+
+// recorder is a Notifier recording what is published, timed by the clock.
+type recorder struct {
+	clock *lsptest.FakeClock
+	start time.Time
+
+	mu   sync.Mutex
+	sent []published
+}
+
+func newRecorder() *recorder {
+	c := lsptest.NewFakeClock(time.Time{})
+	return &recorder{clock: c, start: c.Now()}
+}
+
+func (r *recorder) Notify(_ context.Context, method string, params any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, published{at: r.clock.Now().Sub(r.start), params: params.(*protocol.PublishDiagnosticsParams)})
+	return nil
+}
+
+// take returns what was published since it was last called.
+func (r *recorder) take() []published {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sent := r.sent
+	r.sent = nil
+	return sent
+}
+
+func diag(message string) []protocol.Diagnostic {
+	return []protocol.Diagnostic{{Message: message}}
+}
+
+const doc protocol.DocumentURI = "file:///a.go"
+
+func TestManagerDebounces(t *testing.T) {
+	r := newRecorder()
+	m := NewManager(r, ManagerOptions{Clock: r.clock})
+	m.Set(doc, 1, diag("first"))
+	r.clock.Advance(100 * time.Millisecond)
+	m.Set(doc, 2, diag("second"))
+	r.clock.Advance(199 * time.Millisecond)
+	if sent := r.take(); len(sent) != 0 {
+		t.Fatalf("published %d times within the delay of the last update", len(sent))
+	}
+	r.clock.Advance(time.Millisecond)
+	sent := r.take()
+	if len(sent) != 1 {
+		t.Fatalf("published %d times, want once", len(sent))
+	}
+	if p := sent[0]; p.at != 300*time.Millisecond || p.params.Diagnostics[0].Message != "second" || *p.params.Version != 2 {
+		t.Fatalf("published %q for version %d at %v, want the second at 300ms", p.params.Diagnostics[0].Message, *p.params.Version, p.at)
+	}
+}
+
+func TestManagerMaxDelay(t *testing.T) {
+	r := newRecorder()
+	m := NewManager(r, ManagerOptions{Clock: r.clock})
+	// Updates every 150ms keep restarting the 200ms wait, until restarting
+	// it would pass a second after the first.
+	for version := int32(1); version <= 8; version++ {
+		m.Set(doc, version, diag("update"))
+		r.clock.Advance(150 * time.Millisecond)
+	}
+	sent := r.take()
+	if len(sent) != 1 || sent[0].at != 950*time.Millisecond {
+		t.Fatalf("published %+v, want once at 950ms", sent)
+	}
+	if v := *sent[0].params.Version; v != 7 {
+		t.Fatalf("published version %d, want 7, the last set by then", v)
+	}
+}
+
+func TestManagerDropsStale(t *testing.T) {
+	r := newRecorder()
+	current := int32(3)
+	m := NewManager(r, ManagerOptions{
+		Clock:   r.clock,
+		Version: func(protocol.DocumentURI) (int32, bool) { return current, true },
+	})
+	// A result for a version typed past is dropped as it is published.
+	m.Set(doc, 2, diag("old"))
+	r.clock.Advance(time.Second)
+	if sent := r.take(); len(sent) != 0 {
+		t.Fatalf("published %d results of an old version", len(sent))
+	}
+	// And one finishing after a newer result is dropped as it is set.
+	m.Set(doc, 3, diag("new"))
+	m.Set(doc, 2, diag("slow"))
+	r.clock.Advance(time.Second)
+	sent := r.take()
+	if len(sent) != 1 || sent[0].params.Diagnostics[0].Message != "new" {
+		t.Fatalf("published %+v, want only the newer result", sent)
+	}
+}
+
+func TestManagerClearDiscardsPending(t *testing.T) {
+	r := newRecorder()
+	m := NewManager(r, ManagerOptions{Clock: r.clock})
+	m.Set(doc, 1, diag("pending"))
+	if err := m.Clear(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	sent := r.take()
+	if len(sent) != 1 || sent[0].at != 0 || len(sent[0].params.Diagnostics) != 0 {
+		t.Fatalf("Clear published %+v, want an empty set at once", sent)
+	}
+	r.clock.Advance(time.Second)
+	if sent := r.take(); len(sent) != 0 {
+		t.Fatalf("published %d pending results after Clear", len(sent))
+	}
+}
+
+func TestManagerSkipUnchanged(t *testing.T) {
+	r := newRecorder()
+	m := NewManager(r, ManagerOptions{Clock: r.clock, SkipUnchanged: true})
+	for _, message := range []string{"same", "same", "other"} {
+		m.Set(doc, NoVersion, diag(message))
+		r.clock.Advance(time.Second)
+	}
+	sent := r.take()
+	if len(sent) != 2 || sent[0].params.Diagnostics[0].Message != "same" || sent[1].params.Diagnostics[0].Message != "other" {
+		t.Fatalf("published %+v, want same then other", sent)
+	}
+}
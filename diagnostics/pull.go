@@ -0,0 +1,71 @@
+package diagnostics
+
+import (
+	"context"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Capability is the diagnosticProvider capability of a pulling Manager
+// registered on the server's mux.
+func Capability() protocol.DiagnosticOptions {
+	return protocol.DiagnosticOptions{WorkspaceDiagnostics: true}
+}
+
+// Register routes the pull requests on mux to the Manager, which should
+// have Pull set.
+func (m *Manager) Register(mux *jsonrpc2.Mux) {
+	jsonrpc2.RegisterMethod(mux, "textDocument/diagnostic", m.Document)
+	jsonrpc2.RegisterMethod(mux, "workspace/diagnostic", m.Workspace)
+}
+
+// Document answers textDocument/diagnostic with Report, running Compute
+// first when pulling unless the document's diagnostics were Set or
+// computed from its current version.
+func (m *Manager) Document(ctx context.Context, params *protocol.DocumentDiagnosticParams) (protocol.DocumentDiagnosticReport, error) {
+	uri := params.TextDocument.URI
+	if m.opts.Pull && m.opts.Compute != nil {
+		if err := m.compute(ctx, uri); err != nil {
+			return protocol.DocumentDiagnosticReport{}, err
+		}
+	}
+	return m.Report(uri, params.PreviousResultID), nil
+}
+
+// compute runs Compute for uri unless its recorded diagnostics are of the
+// document's current version. Those of closed documents, which have no
+// version, are always computed again.
+func (m *Manager) compute(ctx context.Context, uri protocol.DocumentURI) error {
+	key := m.opts.Case.URIKey(string(uri))
+	version, open := NoVersion, false
+	if m.opts.Version != nil {
+		version, open = m.opts.Version(uri)
+		if !open {
+			version = NoVersion
+		}
+	}
+	m.mu.Lock()
+	rec, ok := m.history[key]
+	current := open && ok && rec.version == version
+	m.mu.Unlock()
+	if current {
+		return nil
+	}
+
+	diags, err := m.opts.Compute(ctx, uri)
+	if err != nil {
+		return err
+	}
+	if diags == nil {
+		diags = []protocol.Diagnostic{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// A newer version may have been Set while computing.
+	if latest, ok := m.latest[key]; ok && version != NoVersion && version < latest {
+		return nil
+	}
+	m.remember(key, uri, version, diags)
+	return nil
+}
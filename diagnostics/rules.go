@@ -0,0 +1,134 @@
+// Package diagnostics contains helpers for producing and publishing LSP
+// diagnostics: a Registry documenting diagnostic codes, and a Manager which
+// debounces and version-gates publishing, or keeps diagnostics for clients
+// which pull them.
+package diagnostics
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Rule documents a diagnostic code.
+type Rule struct {
+	// Source scopes the code to a diagnostic source, e.g. a linter name.
+	// Rules without a source match diagnostics from any source.
+	Source string
+	Code   string
+
+	// Href links to the rule's documentation.
+	Href string
+
+	// Title is a one-line summary, used by exporters such as SARIF.
+	Title string
+}
+
+// Registry maps diagnostic codes to their documentation. It is safe for
+// concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	rules     map[ruleKey]Rule
+	templates map[string]string
+}
+
+type ruleKey struct{ source, code string }
+
+func NewRegistry() *Registry {
+	return &Registry{rules: map[ruleKey]Rule{}, templates: map[string]string{}}
+}
+
+// Register adds or replaces a rule.
+func (r *Registry) Register(rules ...Rule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rule := range rules {
+		r.rules[ruleKey{rule.Source, rule.Code}] = rule
+	}
+}
+
+// RegisterTemplate documents every code of a source with a URL template in
+// which "{code}" is replaced by the code, for tools whose documentation
+// follows a pattern. Registered rules take precedence.
+func (r *Registry) RegisterTemplate(source, template string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[source] = template
+}
+
+// Lookup finds the rule for a code, preferring one registered for the
+// source.
+func (r *Registry) Lookup(source, code string) (Rule, bool) {
+	if code == "" {
+		return Rule{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rule, ok := r.rules[ruleKey{source, code}]; ok {
+		return rule, true
+	}
+	if template, ok := r.templates[source]; ok {
+		return Rule{Source: source, Code: code, Href: strings.ReplaceAll(template, "{code}", code)}, true
+	}
+	if rule, ok := r.rules[ruleKey{"", code}]; ok {
+		return rule, true
+	}
+	return Rule{}, false
+}
+
+// Rules lists the registered rules by source then code, for exporters which
+// emit a rule table.
+func (r *Registry) Rules() []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rules := make([]Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Source != rules[j].Source {
+			return rules[i].Source < rules[j].Source
+		}
+		return rules[i].Code < rules[j].Code
+	})
+	return rules
+}
+
+// Annotate sets CodeDescription on diagnostics whose code has a documented
+// rule. Diagnostics which already have one are left alone.
+func (r *Registry) Annotate(diags []protocol.Diagnostic) {
+	for idx := range diags {
+		diag := &diags[idx]
+		if diag.CodeDescription != nil || diag.Code == nil {
+			continue
+		}
+		if rule, ok := r.Lookup(diag.Source, diag.Code.Value()); ok && rule.Href != "" {
+			diag.CodeDescription = &protocol.CodeDescription{Href: protocol.URI(rule.Href)}
+		}
+	}
+}
+
+// Publisher is anything which publishes diagnostics, such as a toolrun
+// Publisher or the server's connection.
+type Publisher interface {
+	PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error
+}
+
+// Annotating wraps a Publisher so every published diagnostic is annotated
+// from the registry.
+func (r *Registry) Annotating(next Publisher) Publisher {
+	return annotating{registry: r, next: next}
+}
+
+type annotating struct {
+	registry *Registry
+	next     Publisher
+}
+
+func (a annotating) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	a.registry.Annotate(params.Diagnostics)
+	return a.next.PublishDiagnostics(ctx, params)
+}
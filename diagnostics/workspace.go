@@ -0,0 +1,106 @@
+package diagnostics
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Workspace answers workspace/diagnostic with the diagnostics the Manager
+// holds, set when pulling or last published otherwise, for the documents
+// whose diagnostics differ from those of the client's previous result ids,
+// in URI order. Documents which have been cleared since get an empty
+// report. Each response reports at most PageSize documents; the client
+// pulls again with the result ids it was given and so resumes where the
+// last response stopped.
+//
+// With a partial result token, the pages are instead streamed as $/progress
+// as they are found, including those Set while streaming, and the response
+// itself is empty. When there is nothing to report, Workspace waits for
+// something to change or ctx to be done, as the client expects of a
+// long-running pull.
+func (m *Manager) Workspace(ctx context.Context, params *protocol.WorkspaceDiagnosticParams) (*protocol.WorkspaceDiagnosticReport, error) {
+	known := map[string]string{}
+	uris := map[string]protocol.DocumentURI{}
+	for _, prev := range params.PreviousResultIDs {
+		key := m.opts.Case.URIKey(string(prev.URI))
+		known[key] = prev.Value
+		uris[key] = prev.URI
+	}
+	streamed := false
+	for {
+		items, changed := m.page(known, uris)
+		switch {
+		case len(items) > 0 && params.PartialResultToken == nil:
+			return &protocol.WorkspaceDiagnosticReport{Items: items}, nil
+		case len(items) > 0:
+			err := m.notifier.Notify(ctx, "$/progress", &protocol.ProgressParams{
+				Token: *params.PartialResultToken,
+				Value: &protocol.WorkspaceDiagnosticReportPartialResult{Items: items},
+			})
+			if err != nil {
+				return nil, err
+			}
+			streamed = true
+		case streamed:
+			return &protocol.WorkspaceDiagnosticReport{Items: []protocol.WorkspaceDocumentDiagnosticReport{}}, nil
+		default:
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// page reports the next documents whose diagnostics differ from known, the
+// client's result ids by key, updating known to what it reports. When there
+// are none, it returns a channel closed at the next change instead.
+func (m *Manager) page(known map[string]string, uris map[string]protocol.DocumentURI) ([]protocol.WorkspaceDocumentDiagnosticReport, <-chan struct{}) {
+	limit := m.opts.PageSize
+	if limit <= 0 {
+		limit = 100
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for key, rec := range m.history {
+		if known[key] != rec.id {
+			keys = append(keys, key)
+			uris[key] = rec.uri
+		}
+	}
+	for key, id := range known {
+		if _, ok := m.history[key]; !ok && id != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		if m.changed == nil {
+			m.changed = make(chan struct{})
+		}
+		return nil, m.changed
+	}
+	sort.Slice(keys, func(i, j int) bool { return uris[keys[i]] < uris[keys[j]] })
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	items := make([]protocol.WorkspaceDocumentDiagnosticReport, 0, len(keys))
+	for _, key := range keys {
+		report := &protocol.WorkspaceFullDocumentDiagnosticReport{Kind: protocol.DiagnosticReportFull, URI: uris[key], Items: []protocol.Diagnostic{}}
+		if rec, ok := m.history[key]; ok {
+			report.ResultID, report.Items = rec.id, rec.diags
+			if rec.version != NoVersion {
+				version := rec.version
+				report.Version = &version
+			}
+		}
+		known[key] = report.ResultID
+		items = append(items, protocol.WorkspaceDocumentDiagnosticReport{Full: report})
+	}
+	return items, nil
+}
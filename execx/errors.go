@@ -0,0 +1,106 @@
+package execx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Kind classifies why a command could not produce a Result.
+type Kind int
+
+const (
+	KindStart Kind = iota
+	KindNotFound
+	KindTimeout
+	KindCancelled
+	KindBadDir
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindTimeout:
+		return "timed out"
+	case KindCancelled:
+		return "cancelled"
+	case KindBadDir:
+		return "invalid working directory"
+	default:
+		return "failed to start"
+	}
+}
+
+// Error is returned by Sandbox.Run when the command did not run to completion.
+type Error struct {
+	Kind    Kind
+	Command string
+	Stderr  []byte
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Command, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostic describes the failure as a diagnostic, for servers which surface
+// tool problems in the editor rather than in logs. Cancellation is usually
+// not worth showing; callers should check for KindCancelled first.
+func (e *Error) Diagnostic(rng protocol.Range) protocol.Diagnostic {
+	msg := fmt.Sprintf("%s %s", e.Command, e.Kind)
+	switch e.Kind {
+	case KindNotFound:
+		msg += ", is it installed and on PATH?"
+	case KindStart, KindBadDir:
+		msg += ": " + e.Err.Error()
+	}
+	if stderr := strings.TrimSpace(string(e.Stderr)); stderr != "" {
+		msg += "\n" + stderr
+	}
+	return protocol.Diagnostic{
+		Range:    rng,
+		Severity: protocol.SeverityError,
+		Source:   "execx",
+		Message:  msg,
+	}
+}
+
+// Diagnostic converts any error from Run, or a non-zero exit status, into a
+// diagnostic at rng. It returns false when there is nothing to report.
+func Diagnostic(res *Result, err error, rng protocol.Range) (protocol.Diagnostic, bool) {
+	var execErr *Error
+	if errors.As(err, &execErr) {
+		if execErr.Kind == KindCancelled {
+			return protocol.Diagnostic{}, false
+		}
+		return execErr.Diagnostic(rng), true
+	}
+	if err != nil {
+		return protocol.Diagnostic{
+			Range:    rng,
+			Severity: protocol.SeverityError,
+			Source:   "execx",
+			Message:  err.Error(),
+		}, true
+	}
+	if res == nil || res.ExitCode == 0 {
+		return protocol.Diagnostic{}, false
+	}
+	msg := fmt.Sprintf("exited with status %d", res.ExitCode)
+	if stderr := strings.TrimSpace(string(res.Stderr)); stderr != "" {
+		msg += "\n" + stderr
+	}
+	return protocol.Diagnostic{
+		Range:    rng,
+		Severity: protocol.SeverityError,
+		Source:   "execx",
+		Message:  msg,
+	}, true
+}
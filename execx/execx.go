@@ -0,0 +1,229 @@
+// Package execx runs external tools (formatters, compilers, linters) on behalf
+// of a language server with the guard rails an editor session needs:
+// cancellation, timeouts, bounded output, a scrubbed environment and working
+// directories confined to the workspace.
+package execx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTimeout   = 30 * time.Second
+	DefaultMaxOutput = 4 << 20
+)
+
+// DefaultEnv lists the environment variables passed through from the server
+// process when a Sandbox does not specify its own allow list.
+var DefaultEnv = []string{
+	"PATH",
+	"HOME",
+	"USER",
+	"TMPDIR",
+	"TEMP",
+	"TMP",
+	"LANG",
+	"LC_ALL",
+	"SYSTEMROOT",
+}
+
+// Sandbox holds the policy shared by every command run for a workspace.
+type Sandbox struct {
+	// Root is the workspace root; command directories are resolved relative
+	// to it and may not escape it.
+	Root string
+
+	// AllowEnv names the variables copied from the server's environment.
+	// Nil means DefaultEnv, an empty non-nil slice passes nothing through.
+	AllowEnv []string
+
+	// Timeout applies to commands which do not set their own, DefaultTimeout
+	// when zero.
+	Timeout time.Duration
+
+	// MaxOutput caps the bytes retained from each of stdout and stderr,
+	// DefaultMaxOutput when zero.
+	MaxOutput int
+}
+
+// Command describes a single invocation.
+type Command struct {
+	Name string
+	Args []string
+
+	// Dir is the working directory, relative to the sandbox root. Empty means
+	// the root itself.
+	Dir string
+
+	// Env adds KEY=VALUE entries on top of the scrubbed environment.
+	Env []string
+
+	Stdin io.Reader
+
+	Timeout   time.Duration
+	MaxOutput int
+}
+
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Result is the outcome of a command which started and ran to completion,
+// whatever its exit status.
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	Duration time.Duration
+
+	// Truncated is set when either stream exceeded the output cap.
+	Truncated bool
+}
+
+// Run executes the command. A non-zero exit status is not an error in itself,
+// it is reported through Result.ExitCode so that tools which signal findings
+// through their exit code can still be parsed. Failures to run at all, and
+// timeouts, are returned as *Error.
+func (s *Sandbox) Run(ctx context.Context, cmd Command) (*Result, error) {
+	dir, err := s.ResolveDir(cmd.Dir)
+	if err != nil {
+		return nil, &Error{Kind: KindBadDir, Command: cmd.String(), Err: err}
+	}
+
+	timeout := cmd.Timeout
+	if timeout == 0 {
+		timeout = s.Timeout
+	}
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	maxOutput := cmd.MaxOutput
+	if maxOutput == 0 {
+		maxOutput = s.MaxOutput
+	}
+	if maxOutput == 0 {
+		maxOutput = DefaultMaxOutput
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &cappedBuffer{max: maxOutput}
+	stderr := &cappedBuffer{max: maxOutput}
+
+	c := exec.CommandContext(ctx, cmd.Name, cmd.Args...)
+	c.Dir = dir
+	c.Env = append(s.environ(), cmd.Env...)
+	c.Stdin = cmd.Stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	c.WaitDelay = time.Second
+
+	start := time.Now()
+	runErr := c.Run()
+	res := &Result{
+		Stdout:    stdout.Bytes(),
+		Stderr:    stderr.Bytes(),
+		Duration:  time.Since(start),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+
+	if runErr == nil {
+		return res, nil
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		kind := KindCancelled
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			kind = KindTimeout
+		}
+		return res, &Error{Kind: kind, Command: cmd.String(), Stderr: res.Stderr, Err: ctxErr}
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		res.ExitCode = exitErr.ExitCode()
+		return res, nil
+	}
+
+	if errors.Is(runErr, exec.ErrNotFound) || errors.Is(runErr, os.ErrNotExist) {
+		return res, &Error{Kind: KindNotFound, Command: cmd.String(), Err: runErr}
+	}
+	return res, &Error{Kind: KindStart, Command: cmd.String(), Err: runErr}
+}
+
+// ResolveDir converts a workspace-relative directory into an absolute path,
+// rejecting anything which resolves outside of the root.
+func (s *Sandbox) ResolveDir(dir string) (string, error) {
+	if s.Root == "" {
+		return "", errors.New("sandbox has no workspace root")
+	}
+	root, err := filepath.Abs(s.Root)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		return root, nil
+	}
+
+	full := dir
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(root, dir)
+	}
+	full = filepath.Clean(full)
+
+	rel, err := filepath.Rel(root, full)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("directory %q is outside of the workspace", dir)
+	}
+	return full, nil
+}
+
+func (s *Sandbox) environ() []string {
+	allow := s.AllowEnv
+	if allow == nil {
+		allow = DefaultEnv
+	}
+	env := make([]string, 0, len(allow))
+	for _, name := range allow {
+		if val, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+val)
+		}
+	}
+	return env
+}
+
+// cappedBuffer keeps the first max bytes written and silently discards the
+// rest, so a runaway tool can't exhaust server memory.
+type cappedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	remaining := b.max - b.Buffer.Len()
+	if remaining <= 0 {
+		b.truncated = n > 0 || b.truncated
+		return n, nil
+	}
+	if len(p) > remaining {
+		p = p[:remaining]
+		b.truncated = true
+	}
+	b.Buffer.Write(p)
+	return n, nil
+}
@@ -0,0 +1,281 @@
+// Package filecache persists per-file hashes and derived metadata between
+// server runs, so startup indexing only reprocesses files which changed.
+//
+// The cache file is written atomically and carries a checksum; a missing,
+// truncated, corrupted or version-mismatched file is discarded and the cache
+// starts empty, which costs a full re-index but never serves bad data.
+package filecache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/fscase"
+)
+
+const formatVersion = 1
+
+// Entry is what the cache knows about one file.
+type Entry struct {
+	// Path is the file's path as first recorded.
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash"`
+
+	// Data is the server's derived metadata (symbols, imports, ...), opaque
+	// to the cache.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type envelope struct {
+	Format   int             `json:"format"`
+	Version  string          `json:"version"`
+	Checksum string          `json:"checksum"`
+	Entries  json.RawMessage `json:"entries"`
+}
+
+// Options configures a Cache.
+type Options struct {
+	// Version identifies the shape of the derived Data, typically the server
+	// version; a cache written by another version is discarded.
+	Version string
+
+	// Case is the case sensitivity of the indexed filesystem. On insensitive
+	// filesystems paths differing only in case share one entry. Auto uses
+	// the platform default; callers which probed the workspace with
+	// fscase.Detect should pass the result.
+	Case fscase.Sensitivity
+}
+
+// Cache maps absolute file paths to entries. It is safe for concurrent use.
+type Cache struct {
+	path    string
+	version string
+	cs      fscase.Sensitivity
+
+	mu      sync.Mutex
+	entries map[string]Entry
+	dirty   bool
+	rebuilt error
+}
+
+// Open loads the cache stored at path. It only fails if the cache directory
+// can't be created.
+func Open(path string, opts Options) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		path:    path,
+		version: opts.Version,
+		cs:      opts.Case,
+		entries: map[string]Entry{},
+	}
+	if err := c.load(); err != nil {
+		c.entries = map[string]Entry{}
+		c.rebuilt = err
+		c.dirty = true
+	}
+	return c, nil
+}
+
+// Rebuilt returns why the stored cache was discarded, nil if it loaded
+// cleanly or simply did not exist yet.
+func (c *Cache) Rebuilt() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rebuilt
+}
+
+func (c *Cache) load() error {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return fmt.Errorf("corrupt cache: %w", err)
+	}
+	if env.Format != formatVersion || env.Version != c.version {
+		return fmt.Errorf("cache version %d/%q does not match %d/%q", env.Format, env.Version, formatVersion, c.version)
+	}
+	if checksum(env.Entries) != env.Checksum {
+		return errors.New("corrupt cache: checksum mismatch")
+	}
+	var entries []Entry
+	if err := json.Unmarshal(env.Entries, &entries); err != nil {
+		return fmt.Errorf("corrupt cache: %w", err)
+	}
+	for _, entry := range entries {
+		c.entries[c.cs.Key(entry.Path)] = entry
+	}
+	return nil
+}
+
+// Get returns the stored entry without checking the file.
+func (c *Cache) Get(path string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[c.cs.Key(path)]
+	return entry, ok
+}
+
+// Check compares the file on disk with the stored entry. When size and
+// modification time match the entry is trusted without reading the file;
+// otherwise the content is hashed, so touching a file without changing it
+// does not count as a change. changed is true for files new to the cache.
+// The returned entry is stored, keeping Data only if the content is
+// unchanged.
+func (c *Cache) Check(path string) (entry Entry, changed bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	key := c.cs.Key(path)
+	c.mu.Lock()
+	prev, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+		return prev, false, nil
+	}
+
+	hash, err := HashFile(path)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	entry = Entry{Path: path, Size: info.Size(), ModTime: info.ModTime(), Hash: hash}
+	changed = !ok || prev.Hash != hash
+	if !changed {
+		entry.Path = prev.Path
+		entry.Data = prev.Data
+	}
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.dirty = true
+	c.mu.Unlock()
+	return entry, changed, nil
+}
+
+// SetData stores derived metadata for a file already recorded by Check.
+func (c *Cache) SetData(path string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	key := c.cs.Key(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return fmt.Errorf("%s is not in the cache", path)
+	}
+	entry.Data = raw
+	c.entries[key] = entry
+	c.dirty = true
+	return nil
+}
+
+// Delete forgets a file.
+func (c *Cache) Delete(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := c.cs.Key(path)
+	if _, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.dirty = true
+	}
+}
+
+// Retain drops every entry for which keep returns false, typically files not
+// seen by the startup walk.
+func (c *Cache) Retain(keep func(path string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		if !keep(entry.Path) {
+			delete(c.entries, key)
+			c.dirty = true
+		}
+	}
+}
+
+// Save writes the cache if anything changed since it was loaded or saved.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	list := make([]Entry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	entries, err := json.Marshal(list)
+	c.dirty = false
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(envelope{
+		Format:   formatVersion,
+		Version:  c.version,
+		Checksum: checksum(entries),
+		Entries:  entries,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// HashFile returns the hex SHA-256 of a file's content.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(bytes.TrimSpace(data))
+	return hex.EncodeToString(sum[:])
+}
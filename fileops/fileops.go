@@ -0,0 +1,205 @@
+// Package fileops handles the workspace file operation events editors send
+// when the user creates, renames or deletes files in the editor: the
+// workspace/will... requests, answered with a WorkspaceEdit the client
+// applies along with the operation, such as rewritten import paths after a
+// rename, and the workspace/did... notifications once it is done. Handlers
+// advertises the operations it has handlers for in the
+// workspace.fileOperations capability, with its filters, and passes each
+// handler the files matching them.
+package fileops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/internal/glob"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// The methods of the file operation events.
+const (
+	WillCreate = "workspace/willCreateFiles"
+	DidCreate  = "workspace/didCreateFiles"
+	WillRename = "workspace/willRenameFiles"
+	DidRename  = "workspace/didRenameFiles"
+	WillDelete = "workspace/willDeleteFiles"
+	DidDelete  = "workspace/didDeleteFiles"
+)
+
+// Handlers are a server's handlers of file operations. Nil handlers leave
+// an operation out of the capability and unrouted. Set them before
+// Capability and Register, and don't change them after.
+type Handlers struct {
+	// Filters select the files the server is told about, for every
+	// operation: a rename by its old URI. Files not matching any are
+	// dropped before the handlers see them, and an event with none left
+	// isn't handled.
+	Filters []protocol.FileOperationFilter
+
+	// Case controls pattern matching, for patterns not asking to ignore
+	// case.
+	Case fscase.Sensitivity
+
+	// The will... handlers return the edit the client applies before the
+	// operation, nil for none. The client may bound how long it waits, and
+	// carry out the operation without one.
+	WillCreate func(ctx context.Context, files []protocol.FileCreate) (*protocol.WorkspaceEdit, error)
+	DidCreate  func(ctx context.Context, files []protocol.FileCreate) error
+	WillRename func(ctx context.Context, files []protocol.FileRename) (*protocol.WorkspaceEdit, error)
+	DidRename  func(ctx context.Context, files []protocol.FileRename) error
+	WillDelete func(ctx context.Context, files []protocol.FileDelete) (*protocol.WorkspaceEdit, error)
+	DidDelete  func(ctx context.Context, files []protocol.FileDelete) error
+}
+
+// Capability is the workspace.fileOperations server capability for the
+// operations the Handlers handle, set as ServerCapabilities.Workspace:
+//
+//	caps.Workspace = map[string]any{"fileOperations": handlers.Capability()}
+func (h *Handlers) Capability() protocol.FileOperationOptions {
+	var caps protocol.FileOperationOptions
+	opts := &protocol.FileOperationRegistrationOptions{Filters: h.Filters}
+	if opts.Filters == nil {
+		opts.Filters = []protocol.FileOperationFilter{}
+	}
+	if h.WillCreate != nil {
+		caps.WillCreate = opts
+	}
+	if h.DidCreate != nil {
+		caps.DidCreate = opts
+	}
+	if h.WillRename != nil {
+		caps.WillRename = opts
+	}
+	if h.DidRename != nil {
+		caps.DidRename = opts
+	}
+	if h.WillDelete != nil {
+		caps.WillDelete = opts
+	}
+	if h.DidDelete != nil {
+		caps.DidDelete = opts
+	}
+	return caps
+}
+
+// Register routes the operations the Handlers handle from mux to them. It
+// fails if a filter's pattern doesn't compile.
+func (h *Handlers) Register(mux *jsonrpc2.Mux) error {
+	filters, err := compile(h.Filters, h.Case)
+	if err != nil {
+		return err
+	}
+	if h.WillCreate != nil {
+		jsonrpc2.RegisterMethod(mux, WillCreate, func(ctx context.Context, params *protocol.CreateFilesParams) (*protocol.WorkspaceEdit, error) {
+			if files := matching(filters, params.Files, createURI); len(files) > 0 {
+				return h.WillCreate(ctx, files)
+			}
+			return nil, nil
+		})
+	}
+	if h.DidCreate != nil {
+		jsonrpc2.RegisterNotification(mux, DidCreate, func(ctx context.Context, params *protocol.CreateFilesParams) error {
+			if files := matching(filters, params.Files, createURI); len(files) > 0 {
+				return h.DidCreate(ctx, files)
+			}
+			return nil
+		})
+	}
+	if h.WillRename != nil {
+		jsonrpc2.RegisterMethod(mux, WillRename, func(ctx context.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+			if files := matching(filters, params.Files, renameURI); len(files) > 0 {
+				return h.WillRename(ctx, files)
+			}
+			return nil, nil
+		})
+	}
+	if h.DidRename != nil {
+		jsonrpc2.RegisterNotification(mux, DidRename, func(ctx context.Context, params *protocol.RenameFilesParams) error {
+			if files := matching(filters, params.Files, renameURI); len(files) > 0 {
+				return h.DidRename(ctx, files)
+			}
+			return nil
+		})
+	}
+	if h.WillDelete != nil {
+		jsonrpc2.RegisterMethod(mux, WillDelete, func(ctx context.Context, params *protocol.DeleteFilesParams) (*protocol.WorkspaceEdit, error) {
+			if files := matching(filters, params.Files, deleteURI); len(files) > 0 {
+				return h.WillDelete(ctx, files)
+			}
+			return nil, nil
+		})
+	}
+	if h.DidDelete != nil {
+		jsonrpc2.RegisterNotification(mux, DidDelete, func(ctx context.Context, params *protocol.DeleteFilesParams) error {
+			if files := matching(filters, params.Files, deleteURI); len(files) > 0 {
+				return h.DidDelete(ctx, files)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+func createURI(f protocol.FileCreate) protocol.DocumentURI { return f.URI }
+func renameURI(f protocol.FileRename) protocol.DocumentURI { return f.OldURI }
+func deleteURI(f protocol.FileDelete) protocol.DocumentURI { return f.URI }
+
+// filter is a compiled FileOperationFilter.
+type filter struct {
+	scheme  string
+	pattern *glob.Pattern
+}
+
+func compile(filters []protocol.FileOperationFilter, cs fscase.Sensitivity) ([]filter, error) {
+	compiled := make([]filter, len(filters))
+	for i, f := range filters {
+		sensitivity := cs
+		if f.Pattern.Options != nil && f.Pattern.Options.IgnoreCase {
+			sensitivity = fscase.Insensitive
+		}
+		pattern, err := glob.New(protocol.GlobPattern{Pattern: f.Pattern.Glob}, sensitivity)
+		if err != nil {
+			return nil, fmt.Errorf("fileops: pattern %q: %w", f.Pattern.Glob, err)
+		}
+		compiled[i] = filter{scheme: f.Scheme, pattern: pattern}
+	}
+	return compiled, nil
+}
+
+// matching returns the files whose URIs match a filter, all of them when
+// there are no filters. Whether a pattern is for files or folders is left
+// to the client, which can tell them apart before the operation.
+func matching[F any](filters []filter, files []F, uri func(F) protocol.DocumentURI) []F {
+	if len(filters) == 0 {
+		return files
+	}
+	var matched []F
+	for _, file := range files {
+		u := string(uri(file))
+		for _, f := range filters {
+			if f.scheme != "" && scheme(u) != f.scheme {
+				continue
+			}
+			if f.pattern.Match(glob.Path(u)) {
+				matched = append(matched, file)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// scheme is uri's scheme, empty when it has none.
+func scheme(uri string) string {
+	for i, c := range uri {
+		switch {
+		case c == ':':
+			return uri[:i]
+		case c == '/' || c == '?' || c == '#':
+			return ""
+		}
+	}
+	return ""
+}
@@ -0,0 +1,296 @@
+// Package focus tracks which documents the user is looking at, so analysis
+// and diagnostics for those can be prioritised over background files.
+//
+// Clients which support it send a textDocument/didFocus notification when an
+// editor gains focus. For the rest, focus is inferred from traffic: an
+// interactive request such as hover or completion means the user is in that
+// document, and the whole-document requests editors make for visible editors
+// (semantic tokens, inlay hints, code lenses) mark it visible.
+package focus
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// DidFocusMethod is the non-standard notification some clients send when a
+// document's editor gains focus, with TextDocumentIdentifier-shaped params.
+const DidFocusMethod = "textDocument/didFocus"
+
+// DidFocusParams is the payload of DidFocusMethod.
+type DidFocusParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// Priority ranks documents by user attention.
+type Priority int
+
+const (
+	Background Priority = iota
+	Open
+	Visible
+	Focused
+)
+
+func (p Priority) String() string {
+	switch p {
+	case Focused:
+		return "focused"
+	case Visible:
+		return "visible"
+	case Open:
+		return "open"
+	default:
+		return "background"
+	}
+}
+
+// interactive requests are made at the cursor, so the user is in that
+// document right now.
+var interactive = map[string]bool{
+	"textDocument/hover":             true,
+	"textDocument/completion":        true,
+	"textDocument/signatureHelp":     true,
+	"textDocument/definition":        true,
+	"textDocument/declaration":       true,
+	"textDocument/typeDefinition":    true,
+	"textDocument/implementation":    true,
+	"textDocument/references":        true,
+	"textDocument/documentHighlight": true,
+	"textDocument/codeAction":        true,
+	"textDocument/rename":            true,
+	"textDocument/prepareRename":     true,
+	"textDocument/didChange":         true,
+}
+
+// display requests are made by editors for whatever is on screen.
+var display = map[string]bool{
+	"textDocument/semanticTokens/full":       true,
+	"textDocument/semanticTokens/full/delta": true,
+	"textDocument/semanticTokens/range":      true,
+	"textDocument/inlayHint":                 true,
+	"textDocument/codeLens":                  true,
+	"textDocument/documentLink":              true,
+	"textDocument/documentColor":             true,
+	"textDocument/foldingRange":              true,
+	"textDocument/documentSymbol":            true,
+	"textDocument/diagnostic":                true,
+}
+
+// Tracker records document attention. It is safe for concurrent use.
+type Tracker struct {
+	// Case controls URI comparison.
+	Case fscase.Sensitivity
+
+	// VisibleFor is how long a display request keeps a document visible,
+	// 30s when zero.
+	VisibleFor time.Duration
+
+	// Clock is the time source, clock.Real when nil.
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	focused   protocol.DocumentURI
+	open      map[string]protocol.DocumentURI
+	seen      map[string]time.Time
+	listeners []func(protocol.DocumentURI)
+}
+
+func (t *Tracker) now() time.Time {
+	return clock.Or(t.Clock).Now()
+}
+
+func (t *Tracker) visibleFor() time.Duration {
+	if t.VisibleFor > 0 {
+		return t.VisibleFor
+	}
+	return 30 * time.Second
+}
+
+func (t *Tracker) key(uri protocol.DocumentURI) string {
+	return t.Case.URIKey(string(uri))
+}
+
+func (t *Tracker) init() {
+	if t.open == nil {
+		t.open = map[string]protocol.DocumentURI{}
+		t.seen = map[string]time.Time{}
+	}
+}
+
+// OnFocusChange registers a callback for changes of the focused document.
+// Callbacks run synchronously and must not call back into the Tracker.
+func (t *Tracker) OnFocusChange(fn func(protocol.DocumentURI)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, fn)
+}
+
+// Focus marks uri as the focused document.
+func (t *Tracker) Focus(uri protocol.DocumentURI) {
+	t.mu.Lock()
+	t.init()
+	t.seen[t.key(uri)] = t.now()
+	changed := t.key(t.focused) != t.key(uri)
+	t.focused = uri
+	listeners := t.listeners
+	t.mu.Unlock()
+	if changed {
+		for _, fn := range listeners {
+			fn(uri)
+		}
+	}
+}
+
+// Seen marks uri as visible now.
+func (t *Tracker) Seen(uri protocol.DocumentURI) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.init()
+	t.seen[t.key(uri)] = t.now()
+}
+
+// Opened records textDocument/didOpen.
+func (t *Tracker) Opened(uri protocol.DocumentURI) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.init()
+	t.open[t.key(uri)] = uri
+	t.seen[t.key(uri)] = t.now()
+}
+
+// Closed records textDocument/didClose.
+func (t *Tracker) Closed(uri protocol.DocumentURI) {
+	t.mu.Lock()
+	t.init()
+	key := t.key(uri)
+	delete(t.open, key)
+	delete(t.seen, key)
+	changed := t.key(t.focused) == key
+	if changed {
+		t.focused = ""
+	}
+	listeners := t.listeners
+	t.mu.Unlock()
+	if changed {
+		for _, fn := range listeners {
+			fn("")
+		}
+	}
+}
+
+// Focused returns the focused document, if known.
+func (t *Tracker) Focused() protocol.DocumentURI {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.focused
+}
+
+// Priority ranks a document.
+func (t *Tracker) Priority(uri protocol.DocumentURI) Priority {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.init()
+	key := t.key(uri)
+	if t.focused != "" && t.key(t.focused) == key {
+		return Focused
+	}
+	if seen, ok := t.seen[key]; ok && t.now().Sub(seen) < t.visibleFor() {
+		return Visible
+	}
+	if _, ok := t.open[key]; ok {
+		return Open
+	}
+	return Background
+}
+
+// LastSeen is when the document was last focused or displayed, zero if
+// never.
+func (t *Tracker) LastSeen(uri protocol.DocumentURI) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.seen[t.key(uri)]
+}
+
+// IsVisible reports whether the document is focused or recently visible.
+func (t *Tracker) IsVisible(uri protocol.DocumentURI) bool {
+	return t.Priority(uri) >= Visible
+}
+
+// Observe updates the tracker from an inbound message.
+func (t *Tracker) Observe(method string, params json.RawMessage) {
+	var holder struct {
+		TextDocument struct {
+			URI protocol.DocumentURI `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if err := json.Unmarshal(params, &holder); err != nil || holder.TextDocument.URI == "" {
+		return
+	}
+	uri := holder.TextDocument.URI
+	switch {
+	case method == DidFocusMethod:
+		t.Focus(uri)
+	case method == "textDocument/didOpen":
+		t.Opened(uri)
+	case method == "textDocument/didClose":
+		t.Closed(uri)
+	case interactive[method]:
+		t.Focus(uri)
+	case display[method]:
+		t.Seen(uri)
+	}
+}
+
+// Middleware observes every inbound message and consumes DidFocusMethod,
+// so no handler needs to be registered for it.
+func (t *Tracker) Middleware() jsonrpc2.Middleware {
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			t.Observe(req.Method, req.Params)
+			if req.Method == DidFocusMethod && req.IsNotification() {
+				return nil, nil
+			}
+			return next.Handle(ctx, req)
+		})
+	}
+}
+
+// Deferrable is a jsonrpc2.Backpressure policy delaying log messages and
+// diagnostics for documents which aren't visible.
+func (t *Tracker) Deferrable(method string, params any) bool {
+	switch method {
+	case "window/logMessage", "$/logTrace":
+		return true
+	case "textDocument/publishDiagnostics":
+		switch p := params.(type) {
+		case *protocol.PublishDiagnosticsParams:
+			return !t.IsVisible(p.URI)
+		case protocol.PublishDiagnosticsParams:
+			return !t.IsVisible(p.URI)
+		}
+	}
+	return false
+}
+
+// CoalesceDiagnostics is a jsonrpc2.Backpressure coalescing key which keeps
+// only the latest parked diagnostics per document.
+func CoalesceDiagnostics(method string, params any) string {
+	if method != "textDocument/publishDiagnostics" {
+		return ""
+	}
+	switch p := params.(type) {
+	case *protocol.PublishDiagnosticsParams:
+		return string(p.URI)
+	case protocol.PublishDiagnosticsParams:
+		return string(p.URI)
+	}
+	return ""
+}
@@ -0,0 +1,216 @@
+// Package folders keeps the workspace folders of a session, the roots of a
+// multi-root workspace, from initialize onward. A Set takes the folders the
+// client opened with from the initialize params, follows
+// workspace/didChangeWorkspaceFolders as the user adds and removes folders,
+// and can ask the client for them again with workspace/workspaceFolders.
+// Servers keep their analysis state per folder, spinning it up and down
+// from the Set's callbacks, and find the folder a document belongs to with
+// For:
+//
+//	set := &folders.Set{}
+//	set.OnAdd(startAnalysis)
+//	set.OnRemove(stopAnalysis)
+//	set.Register(server.Mux)
+//	opts.OnInitialize = func(ctx context.Context, params *protocol.InitializeParams, result *protocol.InitializeResult) error {
+//		set.Initialize(ctx, params)
+//		return nil
+//	}
+//	opts.Capabilities.Workspace = map[string]any{"workspaceFolders": folders.Capability()}
+package folders
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// The methods of workspace folders: the notification of the folders'
+// changes, and the request asking the client for them.
+const (
+	DidChangeMethod = "workspace/didChangeWorkspaceFolders"
+	Method          = "workspace/workspaceFolders"
+)
+
+// Caller sends requests to the client; *lsp.Server is one.
+type Caller interface {
+	Call(ctx context.Context, method string, params, result any) error
+}
+
+// Capability is the workspace.workspaceFolders server capability a Set
+// serves.
+func Capability() protocol.WorkspaceFoldersServerCapabilities {
+	return protocol.WorkspaceFoldersServerCapabilities{Supported: true, ChangeNotifications: true}
+}
+
+// Set holds the workspace folders. The zero value is ready to use; it is
+// safe for concurrent use. Register callbacks before Initialize, so they
+// see the first folders.
+type Set struct {
+	// Case controls URI comparison.
+	Case fscase.Sensitivity
+
+	// changeMu serialises changes, so callbacks see them in order.
+	changeMu sync.Mutex
+
+	mu      sync.RWMutex
+	folders []protocol.WorkspaceFolder
+	added   []func(context.Context, protocol.WorkspaceFolder)
+	removed []func(context.Context, protocol.WorkspaceFolder)
+}
+
+// Register routes workspace/didChangeWorkspaceFolders on mux to the Set.
+func (s *Set) Register(mux *jsonrpc2.Mux) {
+	jsonrpc2.RegisterNotification(mux, DidChangeMethod, s.DidChangeWorkspaceFolders)
+}
+
+// OnAdd registers a callback for each folder added, those of Initialize
+// included. Callbacks run synchronously, after the Set is updated; they may
+// read the Set.
+func (s *Set) OnAdd(fn func(ctx context.Context, folder protocol.WorkspaceFolder)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.added = append(s.added, fn)
+}
+
+// OnRemove registers a callback for each folder removed, run as those of
+// OnAdd are.
+func (s *Set) OnRemove(fn func(ctx context.Context, folder protocol.WorkspaceFolder)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removed = append(s.removed, fn)
+}
+
+// Initialize sets the folders from the initialize params: their
+// workspaceFolders, or for clients which don't send them, a folder of
+// rootUri, named after its last element. No folders are set when neither
+// is, as when the client opened a single file.
+func (s *Set) Initialize(ctx context.Context, params *protocol.InitializeParams) {
+	folders := params.WorkspaceFolders
+	if len(folders) == 0 && params.RootURI != nil && *params.RootURI != "" {
+		root := protocol.URI(*params.RootURI)
+		folders = []protocol.WorkspaceFolder{{URI: root, Name: path.Base(strings.TrimSuffix(string(root), "/"))}}
+	}
+	s.replace(ctx, folders)
+}
+
+// DidChangeWorkspaceFolders handles workspace/didChangeWorkspaceFolders,
+// removing folders before adding them. Removing a folder which isn't in
+// the Set, or adding one which is, does nothing.
+func (s *Set) DidChangeWorkspaceFolders(ctx context.Context, params *protocol.DidChangeWorkspaceFoldersParams) error {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+	s.mu.Lock()
+	next := make([]protocol.WorkspaceFolder, 0, len(s.folders))
+	gone := map[string]bool{}
+	for _, f := range params.Event.Removed {
+		gone[s.key(f.URI)] = true
+	}
+	for _, f := range s.folders {
+		if !gone[s.key(f.URI)] {
+			next = append(next, f)
+		}
+	}
+	next = append(next, params.Event.Added...)
+	s.mu.Unlock()
+	s.apply(ctx, next)
+	return nil
+}
+
+// Refresh asks the client for the folders, with workspace/workspaceFolders,
+// and sets them, as when a server suspects it missed a change. A client
+// with no workspace open answers with none.
+func (s *Set) Refresh(ctx context.Context, caller Caller) error {
+	var folders []protocol.WorkspaceFolder
+	if err := caller.Call(ctx, Method, nil, &folders); err != nil {
+		return fmt.Errorf("folders: %w", err)
+	}
+	s.replace(ctx, folders)
+	return nil
+}
+
+// Folders lists the folders, in the order they were added.
+func (s *Set) Folders() []protocol.WorkspaceFolder {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]protocol.WorkspaceFolder(nil), s.folders...)
+}
+
+// For returns the folder uri is in, the innermost when folders are nested.
+func (s *Set) For(uri protocol.DocumentURI) (protocol.WorkspaceFolder, bool) {
+	key := s.Case.URIKey(string(uri))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var best protocol.WorkspaceFolder
+	bestLen := -1
+	for _, f := range s.folders {
+		root := s.key(f.URI)
+		if key != root && !strings.HasPrefix(key, root+"/") {
+			continue
+		}
+		if len(root) > bestLen {
+			best, bestLen = f, len(root)
+		}
+	}
+	return best, bestLen >= 0
+}
+
+func (s *Set) replace(ctx context.Context, folders []protocol.WorkspaceFolder) {
+	s.changeMu.Lock()
+	defer s.changeMu.Unlock()
+	s.apply(ctx, folders)
+}
+
+// apply sets the folders to next, without duplicates, and runs the
+// callbacks of the folders removed, then of those added. changeMu is held.
+func (s *Set) apply(ctx context.Context, next []protocol.WorkspaceFolder) {
+	seen := map[string]bool{}
+	deduped := next[:0:0]
+	for _, f := range next {
+		if k := s.key(f.URI); !seen[k] {
+			seen[k] = true
+			deduped = append(deduped, f)
+		}
+	}
+
+	s.mu.Lock()
+	previous := map[string]bool{}
+	var removed []protocol.WorkspaceFolder
+	for _, f := range s.folders {
+		k := s.key(f.URI)
+		previous[k] = true
+		if !seen[k] {
+			removed = append(removed, f)
+		}
+	}
+	var added []protocol.WorkspaceFolder
+	for _, f := range deduped {
+		if !previous[s.key(f.URI)] {
+			added = append(added, f)
+		}
+	}
+	s.folders = deduped
+	onAdd, onRemove := s.added, s.removed
+	s.mu.Unlock()
+
+	for _, f := range removed {
+		for _, fn := range onRemove {
+			fn(ctx, f)
+		}
+	}
+	for _, f := range added {
+		for _, fn := range onAdd {
+			fn(ctx, f)
+		}
+	}
+}
+
+// key is the form folder URIs are compared in, without a trailing slash.
+func (s *Set) key(uri protocol.URI) string {
+	return strings.TrimSuffix(s.Case.URIKey(string(uri)), "/")
+}
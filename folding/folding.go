@@ -0,0 +1,142 @@
+// Package folding builds textDocument/foldingRange results from the spans
+// a server finds, such as blocks, comments and import lists, fitting them
+// to what the client folds: clamped to the document, whole lines for
+// clients with lineFoldingOnly, one range per line, in order, and no more
+// than the client keeps, dropping the most deeply nested first.
+package folding
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Builder collects the folding ranges of one document.
+type Builder struct {
+	// LineFoldingOnly folds whole lines, leaving out the characters.
+	LineFoldingOnly bool
+
+	// RangeLimit, when positive, caps the ranges returned.
+	RangeLimit int
+
+	// CollapsedText keeps the ranges' collapsed text.
+	CollapsedText bool
+
+	ix     *position.Index
+	enc    position.Encoding
+	ranges []protocol.FoldingRange
+}
+
+// NewBuilder returns a Builder for the document text whose positions are
+// in enc, set up from the folding capabilities caps declares. caps may be
+// nil.
+func NewBuilder(caps *protocol.ClientCapabilities, text string, enc position.Encoding) *Builder {
+	b := &Builder{ix: position.NewIndex(text), enc: enc}
+	if caps != nil && caps.TextDocument != nil && caps.TextDocument.FoldingRange != nil {
+		c := caps.TextDocument.FoldingRange
+		b.LineFoldingOnly = c.LineFoldingOnly
+		b.RangeLimit = int(c.RangeLimit)
+		b.CollapsedText = c.FoldingRange != nil && c.FoldingRange.CollapsedText
+	}
+	return b
+}
+
+// Add adds the span r to fold, such as the inside of a block, clamped to
+// the document. When lines are folded whole, a last line which r ends
+// before the end of is left out, so what follows the span on it, such as
+// a closing brace, stays visible. A span left folding nothing, within a
+// line, is dropped.
+func (b *Builder) Add(r protocol.Range, kind protocol.FoldingRangeKind, collapsedText string) {
+	start, end := b.ix.RangeToOffsets(r, b.enc)
+	if end < start {
+		return
+	}
+	from, to := b.ix.OffsetToPosition(start, b.enc), b.ix.OffsetToPosition(end, b.enc)
+	fr := protocol.FoldingRange{StartLine: from.Line, EndLine: to.Line, Kind: kind}
+	if b.CollapsedText {
+		fr.CollapsedText = collapsedText
+	}
+	if b.LineFoldingOnly {
+		lineEnd := b.ix.PositionToOffset(protocol.Position{Line: to.Line, Character: math.MaxUint32}, b.enc)
+		if end < lineEnd && fr.EndLine > fr.StartLine {
+			fr.EndLine--
+		}
+	} else {
+		fr.StartCharacter, fr.EndCharacter = &from.Character, &to.Character
+	}
+	if fr.EndLine <= fr.StartLine {
+		return
+	}
+	b.ranges = append(b.ranges, fr)
+}
+
+// Len is the number of ranges added.
+func (b *Builder) Len() int {
+	return len(b.ranges)
+}
+
+// Ranges returns the ranges added, ordered by start, enclosing ranges
+// before those they enclose. When lines are folded whole, only the
+// outermost of the ranges starting on one line is kept, as clients keep.
+func (b *Builder) Ranges() []protocol.FoldingRange {
+	ranges := slices.Clone(b.ranges)
+	slices.SortStableFunc(ranges, func(x, y protocol.FoldingRange) int {
+		if c := cmp.Compare(x.StartLine, y.StartLine); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(character(x.StartCharacter), character(y.StartCharacter)); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(y.EndLine, x.EndLine); c != 0 {
+			return c
+		}
+		return cmp.Compare(character(y.EndCharacter), character(x.EndCharacter))
+	})
+	if b.LineFoldingOnly {
+		ranges = slices.CompactFunc(ranges, func(x, y protocol.FoldingRange) bool {
+			return x.StartLine == y.StartLine
+		})
+	}
+	if b.RangeLimit > 0 && len(ranges) > b.RangeLimit {
+		ranges = limit(ranges, b.RangeLimit)
+	}
+	return ranges
+}
+
+// limit keeps n of the sorted ranges, the least deeply nested, in order.
+func limit(ranges []protocol.FoldingRange, n int) []protocol.FoldingRange {
+	depth := make([]int, len(ranges))
+	// open holds the ranges enclosing the current one, innermost last.
+	var open []protocol.FoldingRange
+	for i, r := range ranges {
+		for len(open) > 0 && open[len(open)-1].EndLine < r.EndLine {
+			open = open[:len(open)-1]
+		}
+		depth[i] = len(open)
+		open = append(open, r)
+	}
+	order := make([]int, len(ranges))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(i, j int) int { return cmp.Compare(depth[i], depth[j]) })
+	order = order[:n]
+	slices.Sort(order)
+	out := make([]protocol.FoldingRange, n)
+	for i, idx := range order {
+		out[i] = ranges[idx]
+	}
+	return out
+}
+
+// character is a range end's character, nil sorting as the end of the
+// line.
+func character(c *uint32) uint32 {
+	if c == nil {
+		return math.MaxUint32
+	}
+	return *c
+}
@@ -0,0 +1,91 @@
+package folding
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+func span(startLine, startChar, endLine, endChar uint32) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: startLine, Character: startChar},
+		End:   protocol.Position{Line: endLine, Character: endChar},
+	}
+}
+
+func char(c uint32) *uint32 {
+	return &c
+}
+
+func js(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestAddAtEnd(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		r    protocol.Range
+		// want with characters, and with lines folded whole.
+		chars, lines []protocol.FoldingRange
+	}{
+		{
+			name:  "ends at eof without newline",
+			text:  "{\n a\n}",
+			r:     span(0, 1, 2, 1),
+			chars: []protocol.FoldingRange{{StartLine: 0, StartCharacter: char(1), EndLine: 2, EndCharacter: char(1)}},
+			lines: []protocol.FoldingRange{{StartLine: 0, EndLine: 2}},
+		},
+		{
+			name:  "ends past eof",
+			text:  "{\n a\n}",
+			r:     span(0, 1, 9, 0),
+			chars: []protocol.FoldingRange{{StartLine: 0, StartCharacter: char(1), EndLine: 2, EndCharacter: char(1)}},
+			lines: []protocol.FoldingRange{{StartLine: 0, EndLine: 2}},
+		},
+		{
+			name:  "ends before the last character",
+			text:  "{\n a\n}",
+			r:     span(0, 1, 2, 0),
+			chars: []protocol.FoldingRange{{StartLine: 0, StartCharacter: char(1), EndLine: 2, EndCharacter: char(0)}},
+			lines: []protocol.FoldingRange{{StartLine: 0, EndLine: 1}},
+		},
+		{
+			name:  "ends at eof after crlf",
+			text:  "{\r\n a\r\n}",
+			r:     span(0, 1, 2, 1),
+			chars: []protocol.FoldingRange{{StartLine: 0, StartCharacter: char(1), EndLine: 2, EndCharacter: char(1)}},
+			lines: []protocol.FoldingRange{{StartLine: 0, EndLine: 2}},
+		},
+		{
+			name:  "ends at eof after surrogate pair",
+			text:  "{\n a\n😀",
+			r:     span(0, 1, 2, 2),
+			chars: []protocol.FoldingRange{{StartLine: 0, StartCharacter: char(1), EndLine: 2, EndCharacter: char(2)}},
+			lines: []protocol.FoldingRange{{StartLine: 0, EndLine: 2}},
+		},
+		{
+			name: "starts on the last line",
+			text: "{\n a\n}",
+			r:    span(2, 0, 9, 0),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBuilder(nil, tc.text, position.UTF16)
+			b.Add(tc.r, "", "")
+			if got := b.Ranges(); !reflect.DeepEqual(got, tc.chars) {
+				t.Errorf("Ranges() = %s, want %s", js(got), js(tc.chars))
+			}
+			b = NewBuilder(nil, tc.text, position.UTF16)
+			b.LineFoldingOnly = true
+			b.Add(tc.r, "", "")
+			if got := b.Ranges(); !reflect.DeepEqual(got, tc.lines) {
+				t.Errorf("lines folded whole: Ranges() = %s, want %s", js(got), js(tc.lines))
+			}
+		})
+	}
+}
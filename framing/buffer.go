@@ -0,0 +1,83 @@
+package framing
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// BufferWriter is a Writer which frames a body built in place, in a buffer
+// of its own with room left for the framing, so a large message is written
+// without the copies of encoding it apart and passing it to WriteMessage.
+// HeaderWriter and LineWriter are BufferWriters.
+type BufferWriter interface {
+	Writer
+
+	// WriteBuffer calls build to append the body to buf, then frames it and
+	// writes it. buf may already hold bytes of the framing: build must only
+	// append to it, never Reset it or Truncate it below the length it had.
+	// If build fails nothing is written and its error is returned. buf is
+	// reused once WriteBuffer returns.
+	WriteBuffer(build func(buf *bytes.Buffer) error) error
+}
+
+// maxPooled is the largest buffer kept for reuse; one grown past it by a
+// rare huge message is dropped rather than held for good.
+const maxPooled = 4 << 20
+
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooled {
+		return
+	}
+	buf.Reset()
+	buffers.Put(buf)
+}
+
+// headerRoom is the space left ahead of a body for its Content-Length
+// header, enough for any length.
+const headerRoom = len("Content-Length: \r\n\r\n") + 20
+
+var noHeader [headerRoom]byte
+
+// WriteBuffer writes the body build appends, with its header written into
+// the room left ahead of it, in a single write.
+func (hw *HeaderWriter) WriteBuffer(build func(buf *bytes.Buffer) error) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	buf.Write(noHeader[:])
+	if err := build(buf); err != nil {
+		return err
+	}
+	b := buf.Bytes()
+	var header [headerRoom]byte
+	h := append(header[:0], "Content-Length: "...)
+	h = strconv.AppendInt(h, int64(len(b)-headerRoom), 10)
+	h = append(h, "\r\n\r\n"...)
+	start := headerRoom - len(h)
+	copy(b[start:], h)
+	_, err := hw.w.Write(b[start:])
+	return err
+}
+
+// WriteBuffer writes the body build appends, and its newline, in a single
+// write.
+func (lw *LineWriter) WriteBuffer(build func(buf *bytes.Buffer) error) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := build(buf); err != nil {
+		return err
+	}
+	if bytes.IndexByte(buf.Bytes(), '\n') >= 0 {
+		return fmt.Errorf("message body contains a newline")
+	}
+	buf.WriteByte('\n')
+	_, err := lw.w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,351 @@
+// Package framing splits a byte stream into the discrete messages carried by
+// the LSP base protocol and its relatives (DAP, BSP).
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FrameError is a malformed frame. The reader has skipped past it, so the
+// next ReadMessage resumes with the following message.
+type FrameError struct {
+	Msg string
+
+	// Prefix is the start of a body skipped for exceeding the size limit,
+	// from which a request's ID may be salvaged; nil for other errors.
+	Prefix []byte
+}
+
+// prefixSize is how much of an oversized body FrameError.Prefix keeps.
+const prefixSize = 1024
+
+func tooLarge(size, limit int, prefix []byte) *FrameError {
+	return &FrameError{Msg: fmt.Sprintf("message of %d bytes exceeds the limit of %d", size, limit), Prefix: prefix}
+}
+
+func (e *FrameError) Error() string {
+	return "framing: " + e.Msg
+}
+
+// Reader reads one framed message body at a time.
+type Reader interface {
+	ReadMessage() ([]byte, error)
+}
+
+// Writer writes one message body, adding whatever framing is required.
+// Writers are not safe for concurrent use; callers serialize writes, and
+// may reuse body once WriteMessage returns.
+type Writer interface {
+	WriteMessage(body []byte) error
+}
+
+// Framer builds readers and writers for one framing convention.
+type Framer interface {
+	NewReader(r io.Reader) Reader
+	NewWriter(w io.Writer) Writer
+}
+
+// Limit returns f with its readers refusing messages larger than n bytes
+// with a FrameError, so a peer can't make the reader hold an arbitrarily
+// large body; n <= 0 leaves f as it is. Header and Line readers skip such a
+// message without reading it into memory; others are checked once read.
+func Limit(f Framer, n int) Framer {
+	if n <= 0 {
+		return f
+	}
+	return limitFramer{f, n}
+}
+
+type limitFramer struct {
+	Framer
+	n int
+}
+
+func (f limitFramer) NewReader(r io.Reader) Reader {
+	reader := f.Framer.NewReader(r)
+	switch reader := reader.(type) {
+	case *HeaderReader:
+		reader.MaxSize = f.n
+	case *LineReader:
+		reader.MaxSize = f.n
+	default:
+		return limitReader{reader, f.n}
+	}
+	return reader
+}
+
+type limitReader struct {
+	Reader
+	n int
+}
+
+func (r limitReader) ReadMessage() ([]byte, error) {
+	body, err := r.Reader.ReadMessage()
+	if err == nil && len(body) > r.n {
+		return nil, tooLarge(len(body), r.n, body[:min(len(body), prefixSize)])
+	}
+	return body, err
+}
+
+// Header is the Content-Length header framing used by LSP, DAP and BSP.
+var Header Framer = headerFramer{}
+
+type headerFramer struct{}
+
+func (headerFramer) NewReader(r io.Reader) Reader { return NewHeaderReader(r) }
+func (headerFramer) NewWriter(w io.Writer) Writer { return NewHeaderWriter(w) }
+
+// HeaderReader reads messages framed with Content-Length headers. After a
+// malformed header it resynchronises by skipping input up to the next
+// Content-Length header. Content-Type is optional; a message declaring a
+// charset other than UTF-8 is skipped with a FrameError.
+type HeaderReader struct {
+	// MaxSize bounds a message's body; a larger one is skipped with a
+	// FrameError. No limit when zero.
+	MaxSize int
+
+	r      *bufio.Reader
+	resync bool
+	// pending is a header line to read again, its message's first, found
+	// after the end of a body which overran into it.
+	pending string
+}
+
+func NewHeaderReader(r io.Reader) *HeaderReader {
+	return &HeaderReader{r: bufio.NewReader(r)}
+}
+
+func (hr *HeaderReader) ReadMessage() ([]byte, error) {
+	length := -1
+	var badType error
+	// headers is whether a header of this message has been read, so the
+	// stream ending before its blank line ends it early.
+	headers := false
+	for {
+		line := hr.pending
+		hr.pending = ""
+		var err error
+		if line == "" {
+			line, err = hr.r.ReadString('\n')
+		}
+		if err != nil {
+			if err == io.EOF && (line != "" || headers) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if idx := strings.Index(strings.ToLower(line), "content-length:"); idx > 0 {
+			// The tail of a body shorter than its declared length, run into
+			// the next message's header.
+			line = line[idx:]
+			length = -1
+			if !hr.resync {
+				hr.pending = line
+				return nil, &FrameError{Msg: "message body overran its Content-Length"}
+			}
+		}
+		name, value, ok := strings.Cut(line, ":")
+		ok = ok && isToken(name)
+		isLength := ok && strings.EqualFold(name, "Content-Length")
+		if hr.resync {
+			if !isLength {
+				continue
+			}
+			hr.resync = false
+		}
+		if line == "" {
+			if length < 0 {
+				// Stray blank line between messages.
+				continue
+			}
+			break
+		}
+		if !ok {
+			hr.resync = true
+			return nil, &FrameError{Msg: fmt.Sprintf("invalid header line %q", truncate(line))}
+		}
+		headers = true
+		if isLength {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || length < 0 {
+				hr.resync = true
+				return nil, &FrameError{Msg: fmt.Sprintf("invalid Content-Length %q", truncate(value))}
+			}
+		} else if strings.EqualFold(name, "Content-Type") {
+			badType = checkContentType(value)
+		}
+	}
+
+	if hr.MaxSize > 0 && length > hr.MaxSize {
+		// Skipping the whole body keeps the stream in step.
+		prefix := make([]byte, min(length, prefixSize))
+		if _, err := io.ReadFull(hr.r, prefix); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if _, err := io.CopyN(io.Discard, hr.r, int64(length-len(prefix))); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return nil, tooLarge(length, hr.MaxSize, prefix)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(hr.r, body); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if badType != nil {
+		// The length was good, so the stream is still in step.
+		return nil, badType
+	}
+	return body, nil
+}
+
+// unexpectedEOF reports a stream ending inside a body as truncated.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// checkContentType accepts the base protocol's content types: any media
+// type, with a charset of utf-8 or, for backwards compatibility, utf8.
+func checkContentType(value string) error {
+	params := strings.Split(value, ";")
+	for _, param := range params[1:] {
+		key, val, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "charset") {
+			continue
+		}
+		switch strings.ToLower(strings.Trim(strings.TrimSpace(val), `"`)) {
+		case "utf-8", "utf8":
+		default:
+			return &FrameError{Msg: fmt.Sprintf("unsupported charset %q", truncate(strings.TrimSpace(val)))}
+		}
+	}
+	return nil
+}
+
+// isToken reports whether s is a valid header field name.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if c <= ' ' || c >= 0x7F || strings.IndexByte("()<>@,;:\\\"/[]?={}", c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func truncate(s string) string {
+	if len(s) > 64 {
+		return s[:64] + "..."
+	}
+	return s
+}
+
+// HeaderWriter writes messages framed with Content-Length headers.
+type HeaderWriter struct {
+	w io.Writer
+}
+
+func NewHeaderWriter(w io.Writer) *HeaderWriter {
+	return &HeaderWriter{w: w}
+}
+
+func (hw *HeaderWriter) WriteMessage(body []byte) error {
+	// A single write keeps header and body together on transports where
+	// writes are not otherwise atomic.
+	return hw.WriteBuffer(func(buf *bytes.Buffer) error {
+		buf.Write(body)
+		return nil
+	})
+}
+
+// Line is newline-delimited framing: one JSON document per line, as used by
+// many plain JSON-RPC tools.
+var Line Framer = lineFramer{}
+
+type lineFramer struct{}
+
+func (lineFramer) NewReader(r io.Reader) Reader { return NewLineReader(r) }
+func (lineFramer) NewWriter(w io.Writer) Writer { return NewLineWriter(w) }
+
+// LineReader reads newline-delimited messages, skipping blank lines.
+type LineReader struct {
+	// MaxSize bounds a message's line; a longer one is skipped with a
+	// FrameError. No limit when zero.
+	MaxSize int
+
+	r *bufio.Reader
+}
+
+func NewLineReader(r io.Reader) *LineReader {
+	return &LineReader{r: bufio.NewReader(r)}
+}
+
+func (lr *LineReader) ReadMessage() ([]byte, error) {
+	for {
+		line, err := lr.readLine()
+		var frameErr *FrameError
+		if errors.As(err, &frameErr) {
+			return nil, err
+		}
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 {
+			// A final message without a trailing newline is still a message.
+			return trimmed, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readLine reads up to and including the next newline; a line longer than
+// MaxSize is read through and refused.
+func (lr *LineReader) readLine() ([]byte, error) {
+	if lr.MaxSize <= 0 {
+		return lr.r.ReadBytes('\n')
+	}
+	var line []byte
+	size := 0
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		size += len(chunk)
+		if len(line) <= lr.MaxSize {
+			line = append(line, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if len(bytes.TrimSpace(line)) > lr.MaxSize {
+			return nil, tooLarge(size, lr.MaxSize, line[:min(len(line), prefixSize)])
+		}
+		return line, err
+	}
+}
+
+// LineWriter writes newline-delimited messages. Bodies must not contain raw
+// newlines, which holds for anything produced by encoding/json.
+type LineWriter struct {
+	w io.Writer
+}
+
+func NewLineWriter(w io.Writer) *LineWriter {
+	return &LineWriter{w: w}
+}
+
+func (lw *LineWriter) WriteMessage(body []byte) error {
+	return lw.WriteBuffer(func(buf *bytes.Buffer) error {
+		buf.Write(body)
+		return nil
+	})
+}
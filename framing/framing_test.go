@@ -0,0 +1,209 @@
+package framing
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// readAll reads r until it ends, returning each body as a string, each
+// FrameError as "error: " and its message, and last how the stream ended.
+func readAll(r Reader) []string {
+	var got []string
+	for {
+		body, err := r.ReadMessage()
+		var frameErr *FrameError
+		switch {
+		case errors.As(err, &frameErr):
+			got = append(got, "error: "+frameErr.Msg)
+		case err != nil:
+			return append(got, err.Error())
+		default:
+			got = append(got, string(body))
+		}
+	}
+}
+
+func TestHeaderRoundTrip(t *testing.T) {
+	bodies := []string{`{"jsonrpc":"2.0","method":"a"}`, "", `{"text":"héllo 😀\r\n"}`, strings.Repeat("x", 100_000)}
+	var buf bytes.Buffer
+	w := NewHeaderWriter(&buf)
+	for _, body := range bodies {
+		if err := w.WriteMessage([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteBuffer(func(buf *bytes.Buffer) error {
+		buf.WriteString(`{"built":true}`)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "Content-Length: 30\r\n\r\n{") {
+		t.Fatalf("wrote %q, want a Content-Length header then the body", buf.String()[:40])
+	}
+	got := readAll(NewHeaderReader(&buf))
+	if want := append(bodies, `{"built":true}`, "EOF"); !slices.Equal(got, want) {
+		t.Fatalf("read %q, want %q", got, want)
+	}
+}
+
+func TestLineRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewLineWriter(&buf)
+	for _, body := range []string{`{"a":1}`, `{"b":"😀"}`} {
+		if err := w.WriteMessage([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteMessage([]byte("{\n}")); err == nil {
+		t.Fatal("wrote a body with a raw newline")
+	}
+	// Blank lines are skipped, and a last line needs no newline.
+	buf.WriteString("\r\n  \n{\"c\":3}")
+	if got, want := readAll(NewLineReader(&buf)), []string{`{"a":1}`, `{"b":"😀"}`, `{"c":3}`, "EOF"}; !slices.Equal(got, want) {
+		t.Fatalf("read %q, want %q", got, want)
+	}
+}
+
+func TestHeaderParsing(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"lower case name", "content-length: 2\r\n\r\n{}", []string{"{}", "EOF"}},
+		{"spaces around the value", "Content-Length:   2  \r\n\r\n{}", []string{"{}", "EOF"}},
+		{"lf line ends", "Content-Length: 2\n\n{}", []string{"{}", "EOF"}},
+		{"content type", "Content-Length: 2\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n{}", []string{"{}", "EOF"}},
+		{"legacy charset", "Content-Type: application/json; charset=\"UTF8\"\r\nContent-Length: 2\r\n\r\n{}", []string{"{}", "EOF"}},
+		{"other headers", "X-Trace: 1\r\nContent-Length: 2\r\n\r\n{}", []string{"{}", "EOF"}},
+		{"blank lines between messages", "\r\nContent-Length: 1\r\n\r\n1\r\n\r\nContent-Length: 1\r\n\r\n2", []string{"1", "2", "EOF"}},
+		{"empty body", "Content-Length: 0\r\n\r\n", []string{"", "EOF"}},
+		{
+			"unsupported charset",
+			"Content-Length: 2\r\nContent-Type: text/plain; charset=latin1\r\n\r\n{}Content-Length: 1\r\n\r\n1",
+			[]string{`error: unsupported charset "latin1"`, "1", "EOF"},
+		},
+		{"eof in the header", "Content-Length: 2\r\n", []string{"unexpected EOF"}},
+		{"eof before the blank line", "Content-Length: 2", []string{"unexpected EOF"}},
+		{"eof in the body", "Content-Length: 5\r\n\r\n{}", []string{"unexpected EOF"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := readAll(NewHeaderReader(strings.NewReader(tc.input))); !slices.Equal(got, tc.want) {
+				t.Fatalf("read %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMalformedContentLength(t *testing.T) {
+	for _, value := range []string{"abc", "-1", "", "1.5", "99999999999999999999"} {
+		t.Run(value, func(t *testing.T) {
+			// The reader skips to the next Content-Length header.
+			input := "Content-Length: " + value + "\r\n\r\n{}\r\nContent-Length: 2\r\n\r\n{}"
+			if got, want := readAll(NewHeaderReader(strings.NewReader(input))), []string{`error: invalid Content-Length " ` + value + `"`, "{}", "EOF"}; !slices.Equal(got, want) {
+				t.Fatalf("read %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	big := `{"id":7,"params":"` + strings.Repeat("x", 2000) + `"}`
+	for _, tc := range []struct {
+		name   string
+		framer Framer
+		write  func(w io.Writer, body string)
+	}{
+		{"header", Header, func(w io.Writer, body string) { NewHeaderWriter(w).WriteMessage([]byte(body)) }},
+		{"line", Line, func(w io.Writer, body string) { NewLineWriter(w).WriteMessage([]byte(body)) }},
+		{"other", otherFramer{}, func(w io.Writer, body string) { NewLineWriter(w).WriteMessage([]byte(body)) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			for _, body := range []string{`{"id":1}`, big, `{"id":2}`} {
+				tc.write(&buf, body)
+			}
+			r := Limit(tc.framer, 100).NewReader(&buf)
+			if body, err := r.ReadMessage(); string(body) != `{"id":1}` || err != nil {
+				t.Fatalf("read %q, %v, want the first message", body, err)
+			}
+			// The oversized message is refused, keeping the start of it.
+			_, err := r.ReadMessage()
+			var frameErr *FrameError
+			if !errors.As(err, &frameErr) || !strings.Contains(frameErr.Msg, "exceeds the limit of 100") {
+				t.Fatalf("read the oversized message with %v, want a FrameError", err)
+			}
+			if want := big[:prefixSize]; string(frameErr.Prefix) != want {
+				t.Fatalf("the error keeps %q, want %q", frameErr.Prefix, want)
+			}
+			// The stream is still in step.
+			if got, want := readAll(r), []string{`{"id":2}`, "EOF"}; !slices.Equal(got, want) {
+				t.Fatalf("then read %q, want %q", got, want)
+			}
+		})
+	}
+	if Limit(Header, 0) != Header {
+		t.Fatal("Limit(Header, 0) changed the framer")
+	}
+}
+
+// otherFramer is line framing other than Line's, for the generic limit.
+type otherFramer struct{}
+
+func (otherFramer) NewReader(r io.Reader) Reader { return struct{ Reader }{NewLineReader(r)} }
+func (otherFramer) NewWriter(w io.Writer) Writer { return NewLineWriter(w) }
+
+func TestHeaderResync(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			"garbage line before a header",
+			"garbage\r\nContent-Length: 2\r\n\r\n{}",
+			[]string{`error: invalid header line "garbage"`, "{}", "EOF"},
+		},
+		{
+			"garbage lines between frames",
+			"Content-Length: 1\r\n\r\n1junk\r\n\r\n{\"x\":1}\r\nX-Not: a header\r\nContent-Length: 1\r\n\r\n2",
+			[]string{"1", `error: invalid header line "junk"`, "2", "EOF"},
+		},
+		{
+			"garbage on the line of the next header",
+			"Content-Length: 1\r\n\r\n1junk Content-Length: 1\r\n\r\n2",
+			[]string{"1", "error: message body overran its Content-Length", "2", "EOF"},
+		},
+		{
+			"body shorter than its length",
+			"Content-Length: 5\r\n\r\n{\"a\":1}Content-Length: 2\r\n\r\n{}",
+			[]string{`{"a":`, "error: message body overran its Content-Length", "{}", "EOF"},
+		},
+		{
+			"overrun while skipping garbage",
+			"garbage\r\nmore Content-Length: 2\r\n\r\n{}",
+			[]string{`error: invalid header line "garbage"`, "{}", "EOF"},
+		},
+		{
+			"invalid header after a good one",
+			"Content-Length: 2\r\n{}\r\n\r\nContent-Length: 1\r\n\r\n1",
+			[]string{`error: invalid header line "{}"`, "1", "EOF"},
+		},
+		{
+			"garbage to the end",
+			"Content-Length: 2\r\n\r\n{}\x00\x01garbage",
+			[]string{"{}", "unexpected EOF"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := readAll(NewHeaderReader(strings.NewReader(tc.input))); !slices.Equal(got, tc.want) {
+				t.Fatalf("read %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Package fscase describes whether a workspace's filesystem treats paths
+// case-sensitively, so every component which compares or indexes paths
+// (URI comparison, the document store, glob matching, the file index) agrees
+// on whether "Main.go" and "main.go" are the same file.
+package fscase
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Sensitivity is the case behaviour of a filesystem.
+type Sensitivity int
+
+const (
+	// Auto resolves to the platform default; Resolve probes the filesystem.
+	Auto Sensitivity = iota
+	Sensitive
+	Insensitive
+)
+
+func (s Sensitivity) String() string {
+	switch s {
+	case Sensitive:
+		return "sensitive"
+	case Insensitive:
+		return "insensitive"
+	default:
+		return "auto"
+	}
+}
+
+// Parse reads a workspace option value: "sensitive", "insensitive" or
+// "auto" (also the empty string).
+func Parse(val string) (Sensitivity, bool) {
+	switch strings.ToLower(val) {
+	case "", "auto":
+		return Auto, true
+	case "sensitive":
+		return Sensitive, true
+	case "insensitive":
+		return Insensitive, true
+	}
+	return Auto, false
+}
+
+// Default is the usual behaviour of the platform's default filesystem:
+// insensitive on macOS and Windows, sensitive elsewhere.
+func Default() Sensitivity {
+	switch runtime.GOOS {
+	case "darwin", "windows", "ios":
+		return Insensitive
+	}
+	return Sensitive
+}
+
+// Resolve replaces Auto with the behaviour detected in dir.
+func (s Sensitivity) Resolve(dir string) Sensitivity {
+	if s != Auto {
+		return s
+	}
+	return Detect(dir)
+}
+
+// Detect probes dir by creating a lower-case file and looking it up in upper
+// case. It falls back to Default when dir is not writable.
+func Detect(dir string) Sensitivity {
+	f, err := os.CreateTemp(dir, ".lsplib-case-probe-")
+	if err != nil {
+		return Default()
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+
+	base := filepath.Base(name)
+	upper := filepath.Join(filepath.Dir(name), strings.ToUpper(base))
+	if upper == name {
+		return Default()
+	}
+	if _, err := os.Stat(upper); err == nil {
+		return Insensitive
+	}
+	return Sensitive
+}
+
+func (s Sensitivity) insensitive() bool {
+	if s == Auto {
+		s = Default()
+	}
+	return s == Insensitive
+}
+
+// Key returns the canonical form of a path for use as a map key.
+func (s Sensitivity) Key(path string) string {
+	if s.insensitive() {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// Equal compares two paths.
+func (s Sensitivity) Equal(a, b string) bool {
+	if s.insensitive() {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// HasPrefix reports whether path starts with prefix.
+func (s Sensitivity) HasPrefix(path, prefix string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	return s.Equal(path[:len(prefix)], prefix)
+}
+
+// URIKey returns the canonical form of a file URI for use as a map key: the
+// path is case-folded when the filesystem is insensitive, and percent
+// encoding is normalised so "file:///c%3A/x" and "file:///C:/x" agree.
+// Non-file URIs are returned unchanged.
+func (s Sensitivity) URIKey(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		// Drive letters are case-insensitive everywhere.
+		path = "/" + strings.ToLower(path[1:2]) + path[2:]
+	}
+	return "file://" + u.Host + s.Key(path)
+}
@@ -0,0 +1,109 @@
+// Package handoff passes a running language server's session to a new
+// process, such as after its binary was upgraded, without the editor
+// noticing. The old process stops reading between two messages, finishes
+// what it was handling, and starts its successor on the same standard input
+// and output, handing it the session's state: the client's initialize
+// params, the open documents, the settings and whatever was read but not
+// yet handled. The successor resumes the session from there; see
+// lsp.Server's Handoff and Resume.
+//
+// Editors watch the process they launched, so the old process should then
+// wait for its successor and exit with its exit code.
+package handoff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// envFD names the successor's file descriptor the state is read from.
+const envFD = "LSPLIB_HANDOFF_FD"
+
+// Document is an open document, as the client last sent it.
+type Document struct {
+	URI        protocol.DocumentURI `json:"uri"`
+	LanguageID string               `json:"languageId"`
+	Version    int32                `json:"version"`
+	Text       string               `json:"text"`
+}
+
+// State is a session as it passes between processes.
+type State struct {
+	// Initialize is the client's initialize params as it sent them.
+	Initialize json.RawMessage `json:"initialize"`
+
+	Documents []Document `json:"documents,omitempty"`
+
+	// Settings are the client's settings, replayed to the successor as
+	// workspace/didChangeConfiguration; nil for none.
+	Settings json.RawMessage `json:"settings,omitempty"`
+
+	// Subject is the session's authenticated subject, when an lsp.Verifier
+	// authenticated it.
+	Subject string `json:"subject,omitempty"`
+
+	// Data is the server's own state, for it to restore itself.
+	Data json.RawMessage `json:"data,omitempty"`
+
+	// Pending is what was read from the client and not yet handled, the
+	// start of the messages still to come.
+	Pending []byte `json:"pending,omitempty"`
+}
+
+// Start starts cmd as the successor, reading in's file and writing standard
+// output unless cmd.Stdout is set, and sends it state. The successor finds
+// it with Inherited.
+func Start(cmd *exec.Cmd, in *Input, state *State) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("handoff: %w", err)
+	}
+	defer w.Close()
+	cmd.ExtraFiles = append(cmd.ExtraFiles, r)
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, envFD+"="+strconv.Itoa(2+len(cmd.ExtraFiles)))
+	cmd.Stdin = in.File()
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("handoff: starting successor: %w", err)
+	}
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		return fmt.Errorf("handoff: sending state: %w", err)
+	}
+	return nil
+}
+
+// Inherited returns the state a predecessor handed this process, or nil if
+// it was started afresh.
+func Inherited() (*State, error) {
+	value, ok := os.LookupEnv(envFD)
+	if !ok {
+		return nil, nil
+	}
+	os.Unsetenv(envFD)
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("handoff: %s=%q: %w", envFD, value, err)
+	}
+	f := os.NewFile(uintptr(fd), "handoff")
+	defer f.Close()
+	state := &State{}
+	if err := json.NewDecoder(f).Decode(state); err != nil {
+		return nil, fmt.Errorf("handoff: reading state: %w", err)
+	}
+	return state, nil
+}
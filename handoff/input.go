@@ -0,0 +1,152 @@
+package handoff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Input reads a session's incoming messages from a file, such as standard
+// input, so that it can be stopped between two messages and the rest left
+// for a successor. It passes on only whole messages in the Content-Length
+// framing, one at a time. Use it as the reading half of the server's
+// stream:
+//
+//	in, err := handoff.Stdin()
+//	...
+//	err = s.Serve(ctx, jsonrpc2.Pipe(in, os.Stdout))
+type Input struct {
+	f *os.File
+
+	mu sync.Mutex
+	// buf is what was read from f and not passed on yet; out is the part of
+	// it being passed on, the rest of one message.
+	buf     []byte
+	out     int
+	stopped bool
+	// drained is closed once, after Stop, everything passed on has been
+	// read.
+	drained chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// NewInput returns an Input reading f. Stop needs f to support read
+// deadlines, as pipes and sockets opened non-blocking do; Stdin arranges
+// that for standard input.
+func NewInput(f *os.File) *Input {
+	return &Input{f: f, drained: make(chan struct{}), closed: make(chan struct{})}
+}
+
+// File is the file the Input reads, for a successor to read from.
+func (in *Input) File() *os.File {
+	return in.f
+}
+
+// Read implements io.Reader. Once stopped, it reads only the rest of a
+// message already begun and then blocks until Close.
+func (in *Input) Read(p []byte) (int, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for {
+		if in.out > 0 {
+			n := copy(p, in.buf[:in.out])
+			in.buf = in.buf[n:]
+			in.out -= n
+			return n, nil
+		}
+		if in.stopped {
+			in.once.Do(func() { close(in.drained) })
+			in.mu.Unlock()
+			<-in.closed
+			in.mu.Lock()
+			return 0, io.EOF
+		}
+		if size := frameSize(in.buf); size > 0 {
+			in.out = size
+			continue
+		}
+
+		chunk := make([]byte, 32*1024)
+		in.mu.Unlock()
+		n, err := in.f.Read(chunk)
+		in.mu.Lock()
+		in.buf = append(in.buf, chunk[:n]...)
+		if err != nil && !(in.stopped && errors.Is(err, os.ErrDeadlineExceeded)) {
+			if in.out == 0 && frameSize(in.buf) == 0 {
+				return 0, err
+			}
+		}
+	}
+}
+
+// Stop stops passing messages on: the one being read is finished, and
+// Stop returns once it has been, with what was read from the file beyond
+// it, the start of the messages still to come. The file is left open, ready
+// for a successor to read the rest. It fails, and the Input carries on, if
+// the file doesn't support read deadlines.
+func (in *Input) Stop(ctx context.Context) ([]byte, error) {
+	// Clearing the deadline finds out whether the file has them before
+	// anything changes.
+	if err := in.f.SetReadDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("handoff: input can't be interrupted: %w", err)
+	}
+	in.mu.Lock()
+	in.stopped = true
+	in.mu.Unlock()
+	if err := in.f.SetReadDeadline(time.Now()); err != nil {
+		return nil, fmt.Errorf("handoff: interrupting input: %w", err)
+	}
+	select {
+	case <-in.drained:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return append([]byte(nil), in.buf...), nil
+}
+
+// Close unblocks a stopped Input's reader. It doesn't close the file, which
+// a successor may have inherited.
+func (in *Input) Close() error {
+	select {
+	case <-in.closed:
+	default:
+		close(in.closed)
+	}
+	return nil
+}
+
+// frameSize is the length of the message at the start of buf, headers
+// included, or 0 if it hasn't all arrived. Headers without a valid
+// Content-Length are passed on alone, for the connection to reject.
+func frameSize(buf []byte) int {
+	end := bytes.Index(buf, []byte("\r\n\r\n"))
+	if end < 0 {
+		return 0
+	}
+	body := end + 4
+	for _, line := range strings.Split(string(buf[:end]), "\r\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			continue
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || length < 0 {
+			return body
+		}
+		if len(buf) < body+length {
+			return 0
+		}
+		return body + length
+	}
+	return body
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package handoff
+
+import "os"
+
+// Stdin returns an Input reading standard input. Outside Unix, reads of it
+// can't be interrupted, so Stop fails.
+func Stdin() (*Input, error) {
+	return NewInput(os.Stdin), nil
+}
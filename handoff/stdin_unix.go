@@ -0,0 +1,17 @@
+//go:build unix
+
+package handoff
+
+import (
+	"os"
+	"syscall"
+)
+
+// Stdin returns an Input reading standard input, which it switches to
+// non-blocking mode so that Stop can interrupt a read.
+func Stdin() (*Input, error) {
+	if err := syscall.SetNonblock(syscall.Stdin, true); err != nil {
+		return nil, err
+	}
+	return NewInput(os.NewFile(uintptr(syscall.Stdin), "/dev/stdin")), nil
+}
@@ -0,0 +1,132 @@
+package hierarchy
+
+import (
+	"context"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsperror"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// The methods of call hierarchies.
+const (
+	PrepareCallHierarchy = "textDocument/prepareCallHierarchy"
+	IncomingCalls        = "callHierarchy/incomingCalls"
+	OutgoingCalls        = "callHierarchy/outgoingCalls"
+)
+
+// CallItem is an item of a call hierarchy and the server's value for the
+// callable it stands for. Item's Data is set by CallHierarchy.
+type CallItem[N any] struct {
+	Item protocol.CallHierarchyItem
+	Node N
+}
+
+// IncomingCall is a caller of a node, with the ranges of its calls in the
+// caller.
+type IncomingCall[N any] struct {
+	From       CallItem[N]
+	FromRanges []protocol.Range
+}
+
+// OutgoingCall is what a node calls, with the ranges of the calls in the
+// node.
+type OutgoingCall[N any] struct {
+	To         CallItem[N]
+	FromRanges []protocol.Range
+}
+
+// CallHierarchy answers the call hierarchy requests from a call graph over
+// the server's values N, keeping the value of every item it sends in
+// Items. Set the functions before Register, and don't change them after;
+// advertise it with callHierarchyProvider set to true.
+type CallHierarchy[N any] struct {
+	// Prepare returns the callables at a position, usually one, none when
+	// there is nothing there to call.
+	Prepare func(ctx context.Context, params *protocol.CallHierarchyPrepareParams) ([]CallItem[N], error)
+
+	// Incoming returns the callers of node, and Outgoing what it calls.
+	Incoming func(ctx context.Context, node N) ([]IncomingCall[N], error)
+	Outgoing func(ctx context.Context, node N) ([]OutgoingCall[N], error)
+
+	Items Store[N]
+}
+
+// Register routes the call hierarchy requests on mux to h; those whose
+// function is nil are left unrouted.
+func (h *CallHierarchy[N]) Register(mux *jsonrpc2.Mux) {
+	if h.Prepare != nil {
+		jsonrpc2.RegisterMethod(mux, PrepareCallHierarchy, h.PrepareCallHierarchy)
+	}
+	if h.Incoming != nil {
+		jsonrpc2.RegisterMethod(mux, IncomingCalls, h.IncomingCalls)
+	}
+	if h.Outgoing != nil {
+		jsonrpc2.RegisterMethod(mux, OutgoingCalls, h.OutgoingCalls)
+	}
+}
+
+// PrepareCallHierarchy handles textDocument/prepareCallHierarchy.
+func (h *CallHierarchy[N]) PrepareCallHierarchy(ctx context.Context, params *protocol.CallHierarchyPrepareParams) ([]protocol.CallHierarchyItem, error) {
+	items, err := h.Prepare(ctx, params)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	out := make([]protocol.CallHierarchyItem, len(items))
+	for i, item := range items {
+		out[i] = h.item(item)
+	}
+	return out, nil
+}
+
+// IncomingCalls handles callHierarchy/incomingCalls. An item whose value
+// is no longer kept, having expired or been stored by a previous server
+// process, fails with ContentModified, which clients take as a cue to
+// prepare the hierarchy again.
+func (h *CallHierarchy[N]) IncomingCalls(ctx context.Context, params *protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	node, ok := h.Items.Get(params.Item.Data)
+	if !ok {
+		return nil, lsperror.ContentModified()
+	}
+	calls, err := h.Incoming(ctx, node)
+	if err != nil || len(calls) == 0 {
+		return nil, err
+	}
+	out := make([]protocol.CallHierarchyIncomingCall, len(calls))
+	for i, call := range calls {
+		out[i] = protocol.CallHierarchyIncomingCall{From: h.item(call.From), FromRanges: ranges(call.FromRanges)}
+	}
+	return out, nil
+}
+
+// OutgoingCalls handles callHierarchy/outgoingCalls, failing as
+// IncomingCalls does.
+func (h *CallHierarchy[N]) OutgoingCalls(ctx context.Context, params *protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	node, ok := h.Items.Get(params.Item.Data)
+	if !ok {
+		return nil, lsperror.ContentModified()
+	}
+	calls, err := h.Outgoing(ctx, node)
+	if err != nil || len(calls) == 0 {
+		return nil, err
+	}
+	out := make([]protocol.CallHierarchyOutgoingCall, len(calls))
+	for i, call := range calls {
+		out[i] = protocol.CallHierarchyOutgoingCall{To: h.item(call.To), FromRanges: ranges(call.FromRanges)}
+	}
+	return out, nil
+}
+
+func (h *CallHierarchy[N]) item(item CallItem[N]) protocol.CallHierarchyItem {
+	out := item.Item
+	out.Data = h.Items.Put(item.Node)
+	return out
+}
+
+// ranges is rs, empty rather than nil, as fromRanges isn't optional.
+func ranges(rs []protocol.Range) []protocol.Range {
+	if rs == nil {
+		return []protocol.Range{}
+	}
+	return rs
+}
@@ -0,0 +1,150 @@
+// Package hierarchy keeps the state of call and type hierarchies between
+// the requests walking them. textDocument/prepareCallHierarchy and
+// prepareTypeHierarchy return items the client sends back, one at a time,
+// in callHierarchy/incomingCalls and outgoingCalls or typeHierarchy/
+// supertypes and subtypes, as the user expands the tree. CallHierarchy and
+// TypeHierarchy stash the server's own value for each item they send, such
+// as a symbol's ID, under an opaque token in the item's data, and hand it
+// back when the item returns, so a server only supplies the graph: the
+// items at a position, and the neighbours of a value.
+package hierarchy
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+)
+
+// The defaults of Store's limits.
+const (
+	DefaultTTL = 10 * time.Minute
+	DefaultMax = 10000
+)
+
+// Store keeps values under tokens set as items' data. A value is kept for
+// TTL after it was stored or last resolved, so a tree the user keeps
+// expanding stays alive, and at most Max values are kept, dropping the
+// least recently used. The zero value is ready to use; it is safe for
+// concurrent use.
+type Store[T any] struct {
+	// TTL is how long a value is kept unused, DefaultTTL when zero.
+	TTL time.Duration
+
+	// Max bounds the values kept, DefaultMax when zero.
+	Max int
+
+	Clock clock.Clock
+
+	mu sync.Mutex
+	// prefix tells this Store's tokens from those of another, such as a
+	// previous server process's, which a client may still hold.
+	prefix string
+	seq    uint64
+	// lru holds entries, most recently used first.
+	lru     list.List
+	entries map[string]*list.Element
+}
+
+type entry[T any] struct {
+	token   string
+	value   T
+	expires time.Time
+}
+
+// Put keeps v and returns the token to set as an item's data.
+func (s *Store[T]) Put(v T) json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := clock.Or(s.Clock).Now()
+	s.init()
+	s.sweep(now)
+	s.seq++
+	token := s.prefix + strconv.FormatUint(s.seq, 36)
+	s.entries[token] = s.lru.PushFront(&entry[T]{token: token, value: v, expires: now.Add(s.ttl())})
+	max := s.Max
+	if max <= 0 {
+		max = DefaultMax
+	}
+	for s.lru.Len() > max {
+		s.remove(s.lru.Back())
+	}
+	data, _ := json.Marshal(token)
+	return data
+}
+
+// Get returns the value kept under data, an item's data as Put returned
+// it, keeping it for another TTL. It reports false when data isn't a token
+// of this Store's or its value has expired or been dropped.
+func (s *Store[T]) Get(data json.RawMessage) (T, bool) {
+	var zero T
+	var token string
+	if json.Unmarshal(data, &token) != nil {
+		return zero, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := clock.Or(s.Clock).Now()
+	s.sweep(now)
+	el, ok := s.entries[token]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*entry[T])
+	e.expires = now.Add(s.ttl())
+	s.lru.MoveToFront(el)
+	return e.value, true
+}
+
+// Clear drops every value, as when the workspace changed so much the
+// items sent no longer mean what they did.
+func (s *Store[T]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lru.Init()
+	s.entries = nil
+}
+
+// Len is the number of values kept, expired ones included until the next
+// Put or Get sweeps them.
+func (s *Store[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func (s *Store[T]) init() {
+	if s.prefix == "" {
+		var b [6]byte
+		_, _ = rand.Read(b[:])
+		s.prefix = base64.RawURLEncoding.EncodeToString(b[:]) + "."
+	}
+	if s.entries == nil {
+		s.entries = map[string]*list.Element{}
+	}
+}
+
+func (s *Store[T]) ttl() time.Duration {
+	if s.TTL > 0 {
+		return s.TTL
+	}
+	return DefaultTTL
+}
+
+// sweep drops the expired values. Every value gets the same TTL when it is
+// used, so they expire least recently used first.
+func (s *Store[T]) sweep(now time.Time) {
+	for el := s.lru.Back(); el != nil && !now.Before(el.Value.(*entry[T]).expires); el = s.lru.Back() {
+		s.remove(el)
+	}
+}
+
+func (s *Store[T]) remove(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.entries, el.Value.(*entry[T]).token)
+}
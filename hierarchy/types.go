@@ -0,0 +1,98 @@
+package hierarchy
+
+import (
+	"context"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsperror"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// The methods of type hierarchies.
+const (
+	PrepareTypeHierarchy = "textDocument/prepareTypeHierarchy"
+	Supertypes           = "typeHierarchy/supertypes"
+	Subtypes             = "typeHierarchy/subtypes"
+)
+
+// TypeItem is an item of a type hierarchy and the server's value for the
+// type it stands for. Item's Data is set by TypeHierarchy.
+type TypeItem[N any] struct {
+	Item protocol.TypeHierarchyItem
+	Node N
+}
+
+// TypeHierarchy answers the type hierarchy requests from a type graph over
+// the server's values N, as CallHierarchy does calls; advertise it with
+// typeHierarchyProvider set to true.
+type TypeHierarchy[N any] struct {
+	// Prepare returns the types at a position, usually one.
+	Prepare func(ctx context.Context, params *protocol.TypeHierarchyPrepareParams) ([]TypeItem[N], error)
+
+	// Supertypes returns the types node extends or implements, and
+	// Subtypes those extending or implementing it.
+	Supertypes func(ctx context.Context, node N) ([]TypeItem[N], error)
+	Subtypes   func(ctx context.Context, node N) ([]TypeItem[N], error)
+
+	Items Store[N]
+}
+
+// Register routes the type hierarchy requests on mux to h; those whose
+// function is nil are left unrouted.
+func (h *TypeHierarchy[N]) Register(mux *jsonrpc2.Mux) {
+	if h.Prepare != nil {
+		jsonrpc2.RegisterMethod(mux, PrepareTypeHierarchy, h.PrepareTypeHierarchy)
+	}
+	if h.Supertypes != nil {
+		jsonrpc2.RegisterMethod(mux, Supertypes, h.TypeHierarchySupertypes)
+	}
+	if h.Subtypes != nil {
+		jsonrpc2.RegisterMethod(mux, Subtypes, h.TypeHierarchySubtypes)
+	}
+}
+
+// PrepareTypeHierarchy handles textDocument/prepareTypeHierarchy.
+func (h *TypeHierarchy[N]) PrepareTypeHierarchy(ctx context.Context, params *protocol.TypeHierarchyPrepareParams) ([]protocol.TypeHierarchyItem, error) {
+	items, err := h.Prepare(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return h.items(items), nil
+}
+
+// TypeHierarchySupertypes handles typeHierarchy/supertypes, failing with
+// ContentModified when the item's value is no longer kept, as
+// CallHierarchy.IncomingCalls does.
+func (h *TypeHierarchy[N]) TypeHierarchySupertypes(ctx context.Context, params *protocol.TypeHierarchySupertypesParams) ([]protocol.TypeHierarchyItem, error) {
+	return h.related(ctx, params.Item, h.Supertypes)
+}
+
+// TypeHierarchySubtypes handles typeHierarchy/subtypes, failing as
+// TypeHierarchySupertypes does.
+func (h *TypeHierarchy[N]) TypeHierarchySubtypes(ctx context.Context, params *protocol.TypeHierarchySubtypesParams) ([]protocol.TypeHierarchyItem, error) {
+	return h.related(ctx, params.Item, h.Subtypes)
+}
+
+func (h *TypeHierarchy[N]) related(ctx context.Context, item protocol.TypeHierarchyItem, fn func(context.Context, N) ([]TypeItem[N], error)) ([]protocol.TypeHierarchyItem, error) {
+	node, ok := h.Items.Get(item.Data)
+	if !ok {
+		return nil, lsperror.ContentModified()
+	}
+	items, err := fn(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	return h.items(items), nil
+}
+
+func (h *TypeHierarchy[N]) items(items []TypeItem[N]) []protocol.TypeHierarchyItem {
+	if len(items) == 0 {
+		return nil
+	}
+	out := make([]protocol.TypeHierarchyItem, len(items))
+	for i, item := range items {
+		out[i] = item.Item
+		out[i].Data = h.Items.Put(item.Node)
+	}
+	return out
+}
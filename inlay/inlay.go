@@ -0,0 +1,145 @@
+// Package inlay builds textDocument/inlayHint results and answers
+// inlayHint/resolve. A Builder keeps the hints of the requested range in
+// position order; a Label assembles a label from parts, sent as a plain
+// string when none of them needs to be a part of its own. A Resolver hands
+// each hint back to the function registered for the kind of data stashed
+// with it by SetData, so tooltips and locations are computed for the hint
+// the user hovers rather than for every hint on screen; CanResolve tells
+// which properties a client lets it fill in, the rest to be set up front.
+package inlay
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Builder collects the hints of one inlay hint response.
+type Builder struct {
+	// Range is the range hints were asked for; Add drops hints outside it.
+	Range protocol.Range
+
+	hints []protocol.InlayHint
+}
+
+// NewBuilder returns a Builder for the hints params asks for.
+func NewBuilder(params *protocol.InlayHintParams) *Builder {
+	return &Builder{Range: params.Range}
+}
+
+// Add adds hints, dropping those positioned outside Range.
+func (b *Builder) Add(hints ...protocol.InlayHint) {
+	for _, h := range hints {
+		if compare(h.Position, b.Range.Start) >= 0 && compare(h.Position, b.Range.End) <= 0 {
+			b.hints = append(b.hints, h)
+		}
+	}
+}
+
+// Type adds a type hint, ": label", after the name ending at pos.
+func (b *Builder) Type(pos protocol.Position, label protocol.InlayHintLabel) {
+	b.Add(protocol.InlayHint{Position: pos, Label: prefix(": ", label), Kind: protocol.InlayHintType})
+}
+
+// Parameter adds a parameter hint, "name:", before the argument starting
+// at pos.
+func (b *Builder) Parameter(pos protocol.Position, name protocol.InlayHintLabel) {
+	b.Add(protocol.InlayHint{Position: pos, Label: suffix(name, ":"), Kind: protocol.InlayHintParameter, PaddingRight: true})
+}
+
+// Len is the number of hints added.
+func (b *Builder) Len() int {
+	return len(b.hints)
+}
+
+// Hints returns the hints added, ordered by position, those at one
+// position in the order added.
+func (b *Builder) Hints() []protocol.InlayHint {
+	hints := slices.Clone(b.hints)
+	slices.SortStableFunc(hints, func(x, y protocol.InlayHint) int { return compare(x.Position, y.Position) })
+	return hints
+}
+
+// Label assembles a hint label from parts. The zero value is an empty
+// label.
+type Label struct {
+	parts []protocol.InlayHintLabelPart
+}
+
+// Text appends text which is only shown.
+func (l *Label) Text(text string) *Label {
+	return l.Part(protocol.InlayHintLabelPart{Value: text})
+}
+
+// Link appends text going to loc when clicked, such as a type's name
+// going to its declaration.
+func (l *Label) Link(text string, loc protocol.Location) *Label {
+	return l.Part(protocol.InlayHintLabelPart{Value: text, Location: &loc})
+}
+
+// Part appends part.
+func (l *Label) Part(part protocol.InlayHintLabelPart) *Label {
+	if n := len(l.parts); n > 0 && plain(l.parts[n-1]) && plain(part) {
+		l.parts[n-1].Value += part.Value
+		return l
+	}
+	l.parts = append(l.parts, part)
+	return l
+}
+
+// Label returns the label, a plain string when no part has a tooltip,
+// location or command, consecutive text being merged into one part.
+func (l *Label) Label() protocol.InlayHintLabel {
+	if len(l.parts) == 0 {
+		return protocol.InlayHintLabel{}
+	}
+	if len(l.parts) == 1 && plain(l.parts[0]) {
+		return protocol.InlayHintLabel{Value: l.parts[0].Value}
+	}
+	return protocol.InlayHintLabel{Parts: slices.Clone(l.parts)}
+}
+
+// String is the label's text, as the client shows it.
+func (l *Label) String() string {
+	var b strings.Builder
+	for _, p := range l.parts {
+		b.WriteString(p.Value)
+	}
+	return b.String()
+}
+
+func plain(part protocol.InlayHintLabelPart) bool {
+	return part.Tooltip == nil && part.Location == nil && part.Command == nil
+}
+
+// prefix and suffix add text to a label, merged into its first or last
+// part when that is plain text.
+func prefix(text string, label protocol.InlayHintLabel) protocol.InlayHintLabel {
+	if len(label.Parts) == 0 {
+		return protocol.InlayHintLabel{Value: text + label.Value}
+	}
+	var l Label
+	l.Text(text)
+	for _, part := range label.Parts {
+		l.Part(part)
+	}
+	return l.Label()
+}
+
+func suffix(label protocol.InlayHintLabel, text string) protocol.InlayHintLabel {
+	if len(label.Parts) == 0 {
+		return protocol.InlayHintLabel{Value: label.Value + text}
+	}
+	l := Label{parts: slices.Clone(label.Parts)}
+	l.Text(text)
+	return l.Label()
+}
+
+func compare(a, b protocol.Position) int {
+	if c := cmp.Compare(a.Line, b.Line); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Character, b.Character)
+}
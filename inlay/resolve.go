@@ -0,0 +1,104 @@
+package inlay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// ResolveMethod is the request by which the client asks for the rest of a
+// hint.
+const ResolveMethod = "inlayHint/resolve"
+
+// ResolveFunc fills in the rest of hint, such as its tooltip or its label
+// parts' locations, from the data stashed with it.
+type ResolveFunc func(ctx context.Context, hint *protocol.InlayHint, data json.RawMessage) error
+
+// data is what SetData stores in a hint's Data.
+type data struct {
+	Kind string          `json:"k"`
+	Data json.RawMessage `json:"d,omitempty"`
+}
+
+// SetData stashes data, marshalled to JSON, in hint for the ResolveFunc
+// registered under kind, such as "type" or "parameter", to resolve it
+// with. Keep data small, an identifier rather than the value it names: it
+// is sent with every hint.
+func SetData(hint *protocol.InlayHint, kind string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("inlay: data for %q: %w", kind, err)
+	}
+	stashed, err := json.Marshal(data{Kind: kind, Data: raw})
+	if err != nil {
+		return fmt.Errorf("inlay: data for %q: %w", kind, err)
+	}
+	hint.Data = stashed
+	return nil
+}
+
+// CanResolve reports whether caps lets inlayHint/resolve fill in property,
+// such as "tooltip", "textEdits", "label.tooltip", "label.location" or
+// "label.command"; what it can't resolve must be set in the hints
+// returned. caps may be nil.
+func CanResolve(caps *protocol.ClientCapabilities, property string) bool {
+	if caps == nil || caps.TextDocument == nil || caps.TextDocument.InlayHint == nil || caps.TextDocument.InlayHint.ResolveSupport == nil {
+		return false
+	}
+	return slices.Contains(caps.TextDocument.InlayHint.ResolveSupport.Properties, property)
+}
+
+// Resolver routes inlayHint/resolve to the ResolveFunc registered for the
+// kind of data stashed in each hint. The zero value is ready to use; it is
+// safe for concurrent use.
+type Resolver struct {
+	mu    sync.RWMutex
+	funcs map[string]ResolveFunc
+}
+
+// Register sets the ResolveFunc for hints stashed under kind, replacing
+// any previous one.
+func (r *Resolver) Register(kind string, fn ResolveFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.funcs == nil {
+		r.funcs = map[string]ResolveFunc{}
+	}
+	r.funcs[kind] = fn
+}
+
+// Capability is the inlayHintProvider server capability, with resolve
+// support.
+func (r *Resolver) Capability() protocol.InlayHintOptions {
+	return protocol.InlayHintOptions{ResolveProvider: true}
+}
+
+// Route routes inlayHint/resolve on mux to the Resolver.
+func (r *Resolver) Route(mux *jsonrpc2.Mux) {
+	jsonrpc2.RegisterMethod(mux, ResolveMethod, r.Resolve)
+}
+
+// Resolve handles inlayHint/resolve. Hints without data stashed by
+// SetData, or stashed under a kind nothing is registered for, such as by a
+// previous server process, are returned as they are.
+func (r *Resolver) Resolve(ctx context.Context, hint *protocol.InlayHint) (*protocol.InlayHint, error) {
+	var stashed data
+	if len(hint.Data) == 0 || json.Unmarshal(hint.Data, &stashed) != nil || stashed.Kind == "" {
+		return hint, nil
+	}
+	r.mu.RLock()
+	fn, ok := r.funcs[stashed.Kind]
+	r.mu.RUnlock()
+	if !ok {
+		return hint, nil
+	}
+	if err := fn(ctx, hint, stashed.Data); err != nil {
+		return nil, err
+	}
+	return hint, nil
+}
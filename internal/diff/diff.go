@@ -0,0 +1,197 @@
+// Package diff computes the line differences between two texts and renders
+// them as unified diffs, as patch and git print them.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context is the number of unchanged lines Unified shows around a change.
+const Context = 3
+
+// Unified returns the unified diff turning old into new, with headers
+// naming them oldName and newName, empty when they are equal.
+func Unified(oldName, newName, old, new string) string {
+	if old == new {
+		return ""
+	}
+	a, b := lines(old), lines(new)
+	ops := diffLines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for len(ops) > 0 {
+		// A hunk runs from Context lines before a change to Context lines after
+		// the last change less than 2*Context lines after the one before.
+		start := 0
+		for start < len(ops) && ops[start].kind == equal {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind == equal {
+				if i-end >= 2*Context {
+					break
+				}
+				continue
+			}
+			end = i + 1
+		}
+		from, to := max(start-Context, 0), min(end+Context, len(ops))
+		writeHunk(&sb, ops[from:to])
+		ops = ops[to:]
+	}
+	return sb.String()
+}
+
+type kind int
+
+const (
+	equal kind = iota
+	remove
+	insert
+)
+
+// op is a line of the diff: aLine and bLine are its index in each text,
+// where it is or would be.
+type op struct {
+	kind         kind
+	line         string
+	aLine, bLine int
+}
+
+func writeHunk(sb *strings.Builder, ops []op) {
+	aStart, bStart := ops[0].aLine, ops[0].bLine
+	var aLen, bLen int
+	for _, o := range ops {
+		if o.kind != insert {
+			aLen++
+		}
+		if o.kind != remove {
+			bLen++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+	for _, o := range ops {
+		sb.WriteByte(" -+"[o.kind])
+		sb.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange is a hunk's start and length in one text, 1-based, the start
+// of an empty range being the line before it.
+func hunkRange(start, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// lines splits text after each newline, the last line without one when
+// text doesn't end with a newline.
+func lines(text string) []string {
+	ls := strings.SplitAfter(text, "\n")
+	if ls[len(ls)-1] == "" {
+		ls = ls[:len(ls)-1]
+	}
+	return ls
+}
+
+// diffLines returns the shortest edit script turning a into b, as every
+// line of both in order, removals before the insertions replacing them.
+func diffLines(a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var ops []op
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, op{kind: equal, line: a[i], aLine: i, bLine: i})
+	}
+	for _, o := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		o.aLine += prefix
+		o.bLine += prefix
+		ops = append(ops, o)
+	}
+	for i := suffix; i > 0; i-- {
+		ops = append(ops, op{kind: equal, line: a[len(a)-i], aLine: len(a) - i, bLine: len(b) - i})
+	}
+	return ops
+}
+
+// myers is Myers's O(ND) algorithm, keeping the furthest reaching paths of
+// every D to walk the edit script back from the end.
+func myers(a, b []string) []op {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var rev []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			rev = append(rev, op{kind: equal, line: a[x], aLine: x, bLine: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, op{kind: insert, line: b[y], aLine: x, bLine: y})
+		} else {
+			x--
+			rev = append(rev, op{kind: remove, line: a[x], aLine: x, bLine: y})
+		}
+	}
+	ops := make([]op, len(rev))
+	for i, o := range rev {
+		ops[len(rev)-1-i] = o
+	}
+	return ops
+}
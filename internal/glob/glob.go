@@ -0,0 +1,108 @@
+// Package glob translates the glob syntax of LSP file watchers and document
+// filters into regular expressions.
+package glob
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Pattern is a compiled GlobPattern.
+type Pattern struct {
+	base string
+	re   *regexp.Regexp
+	cs   fscase.Sensitivity
+}
+
+// New compiles g, matching paths with the case sensitivity cs.
+func New(g protocol.GlobPattern, cs fscase.Sensitivity) (*Pattern, error) {
+	re, err := Compile(g.Pattern, cs)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pattern{re: re, cs: cs}
+	if g.BaseURI != "" {
+		p.base = strings.TrimSuffix(Path(string(g.BaseURI)), "/") + "/"
+	}
+	return p, nil
+}
+
+// Match reports whether the file at path matches. Relative patterns match
+// the path below their base.
+func (p *Pattern) Match(path string) bool {
+	if p.base != "" {
+		if !p.cs.HasPrefix(path, p.base) {
+			return false
+		}
+		path = path[len(p.base):]
+	}
+	return p.re.MatchString(path)
+}
+
+// Path is the path patterns match a URI by: the path of a file URI, or the
+// whole URI otherwise.
+func Path(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+// Compile translates LSP glob syntax: * and ? within a path segment, **
+// across segments, {a,b} alternatives and [...] ranges, negated with [!...].
+// The expression matches whole paths, ignoring case when cs does.
+func Compile(glob string, cs fscase.Sensitivity) (*regexp.Regexp, error) {
+	var out strings.Builder
+	if cs.Equal("a", "A") {
+		out.WriteString("(?i)")
+	}
+	out.WriteString("^")
+	depth := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			out.WriteString("(?:.*/)?")
+			i += 2
+		case c == '/' && glob[i:] == "/**":
+			out.WriteString("(?:/.*)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			out.WriteString(".*")
+			i++
+		case c == '*':
+			out.WriteString("[^/]*")
+		case c == '?':
+			out.WriteString("[^/]")
+		case c == '{':
+			out.WriteString("(?:")
+			depth++
+		case c == ',' && depth > 0:
+			out.WriteString("|")
+		case c == '}' && depth > 0:
+			out.WriteString(")")
+			depth--
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				out.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			out.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			out.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	out.WriteString(strings.Repeat(")", depth) + "$")
+	return regexp.Compile(out.String())
+}
@@ -0,0 +1,301 @@
+package jsonschema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GoGenerator renders the definitions of a Document as Go types.
+type GoGenerator struct {
+	Doc     *Document
+	Package string
+
+	// Header is written as a comment above the package clause, typically the
+	// source of the schema.
+	Header string
+
+	// Skip lists definitions which are provided by hand-written code in the
+	// target package.
+	Skip map[string]bool
+
+	// Integer is the Go type used for "integer", int when empty.
+	Integer string
+
+	out     bytes.Buffer
+	nested  []nestedType
+	emitted map[string]bool
+}
+
+type nestedType struct {
+	name   string
+	schema *Schema
+}
+
+// Generate renders every definition, sorted by name, as gofmt'd source.
+func (g *GoGenerator) Generate() ([]byte, error) {
+	g.out.Reset()
+	g.emitted = map[string]bool{}
+
+	if g.Header != "" {
+		writeComment(&g.out, "", g.Header)
+		g.out.WriteString("\n")
+	}
+	fmt.Fprintf(&g.out, "package %s\n\n", g.Package)
+	g.out.WriteString("import \"encoding/json\"\n\n")
+	g.out.WriteString("var _ json.RawMessage\n\n")
+
+	names := make([]string, 0, len(g.Doc.Definitions))
+	for name := range g.Doc.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if g.Skip[name] {
+			continue
+		}
+		if err := g.definition(name, g.Doc.Definitions[name]); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := g.flushNested(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	src, err := format.Source(g.out.Bytes())
+	if err != nil {
+		return g.out.Bytes(), fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+func (g *GoGenerator) definition(name string, schema *Schema) error {
+	flat, err := g.Doc.Flatten(schema)
+	if err != nil {
+		return err
+	}
+	goName := GoName(name)
+	if g.emitted[goName] {
+		return nil
+	}
+	g.emitted[goName] = true
+
+	writeComment(&g.out, "", flat.Description)
+	if flat.Deprecated {
+		g.out.WriteString("//\n// Deprecated: see the protocol specification.\n")
+	}
+
+	switch {
+	case flat.IsObject():
+		return g.structType(goName, flat)
+
+	case flat.Type.Is("string") && (len(flat.Enum) > 0 || len(flat.OpenEnum) > 0):
+		fmt.Fprintf(&g.out, "type %s string\n\n", goName)
+		values := flat.Enum
+		if len(values) == 0 {
+			values = flat.OpenEnum
+		}
+		g.out.WriteString("const (\n")
+		for idx, val := range values {
+			if idx < len(flat.EnumDescriptions) {
+				writeComment(&g.out, "\t", flat.EnumDescriptions[idx])
+			}
+			fmt.Fprintf(&g.out, "\t%s%s %s = %q\n", goName, GoName(val), goName, val)
+		}
+		g.out.WriteString(")\n\n")
+		return nil
+
+	default:
+		typ, err := g.goType(flat, goName, true)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.out, "type %s %s\n\n", goName, typ)
+		return nil
+	}
+}
+
+func (g *GoGenerator) structType(goName string, schema *Schema) error {
+	fmt.Fprintf(&g.out, "type %s struct {\n", goName)
+	for _, prop := range schema.Properties {
+		required := schema.IsRequired(prop.Name)
+		typ, err := g.goType(prop.Schema, goName+GoName(prop.Name), required)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", prop.Name, err)
+		}
+		writeComment(&g.out, "\t", prop.Schema.Description)
+		tag := prop.Name
+		if !required {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&g.out, "\t%s %s `json:%q`\n", GoName(prop.Name), typ, tag)
+	}
+	g.out.WriteString("}\n\n")
+	return nil
+}
+
+func (g *GoGenerator) flushNested() error {
+	for len(g.nested) > 0 {
+		next := g.nested[0]
+		g.nested = g.nested[1:]
+		if g.emitted[next.name] {
+			continue
+		}
+		g.emitted[next.name] = true
+		writeComment(&g.out, "", next.schema.Description)
+		if err := g.structType(next.name, next.schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// goType returns the Go type expression for a schema used in a field or
+// alias. context names any inline struct which has to be hoisted to the top
+// level.
+func (g *GoGenerator) goType(schema *Schema, context string, required bool) (string, error) {
+	if schema.Ref != "" {
+		target, name, err := g.Doc.Resolve(schema)
+		if err != nil {
+			return "", err
+		}
+		typ := GoName(name)
+		flat, err := g.Doc.Flatten(target)
+		if err != nil {
+			return "", err
+		}
+		if flat.IsObject() && !required {
+			return "*" + typ, nil
+		}
+		return typ, nil
+	}
+
+	if len(schema.AllOf) > 0 {
+		flat, err := g.Doc.Flatten(schema)
+		if err != nil {
+			return "", err
+		}
+		return g.goType(flat, context, required)
+	}
+
+	if len(schema.OneOf) > 0 || len(schema.AnyOf) > 0 || len(schema.Type) != 1 {
+		return "json.RawMessage", nil
+	}
+
+	switch schema.Type[0] {
+	case "string":
+		return "string", nil
+	case "integer":
+		if g.Integer != "" {
+			return g.Integer, nil
+		}
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if schema.Items == nil {
+			return "[]json.RawMessage", nil
+		}
+		item, err := g.goType(schema.Items, context+"Item", true)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if len(schema.Properties) > 0 {
+			g.nested = append(g.nested, nestedType{name: context, schema: schema})
+			if required {
+				return context, nil
+			}
+			return "*" + context, nil
+		}
+		if schema.AdditionalProperties != nil {
+			val, err := g.goType(schema.AdditionalProperties, context+"Value", true)
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + val, nil
+		}
+		return "json.RawMessage", nil
+	}
+	return "json.RawMessage", nil
+}
+
+var initialisms = map[string]string{
+	"id":   "ID",
+	"ids":  "IDs",
+	"uri":  "URI",
+	"url":  "URL",
+	"json": "JSON",
+	"http": "HTTP",
+	"api":  "API",
+	"os":   "OS",
+	"cpu":  "CPU",
+	"ip":   "IP",
+}
+
+// GoName converts a schema identifier (camelCase, snake_case or free text)
+// into an exported Go identifier.
+func GoName(name string) string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = cur[:0]
+		}
+	}
+	runes := []rune(name)
+	for idx, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && idx > 0 && unicode.IsLower(runes[idx-1]):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+
+	var out strings.Builder
+	for _, word := range words {
+		if up, ok := initialisms[strings.ToLower(word)]; ok {
+			out.WriteString(up)
+			continue
+		}
+		rs := []rune(word)
+		rs[0] = unicode.ToUpper(rs[0])
+		out.WriteString(string(rs))
+	}
+	if out.Len() == 0 {
+		return "Empty"
+	}
+	ident := out.String()
+	if unicode.IsDigit([]rune(ident)[0]) {
+		ident = "N" + ident
+	}
+	return ident
+}
+
+func writeComment(buf *bytes.Buffer, indent, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			fmt.Fprintf(buf, "%s//\n", indent)
+			continue
+		}
+		fmt.Fprintf(buf, "%s// %s\n", indent, line)
+	}
+}
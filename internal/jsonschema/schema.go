@@ -0,0 +1,314 @@
+// Package jsonschema models the subset of JSON Schema used by protocol
+// definition files such as the Debug Adapter Protocol schema, resolves
+// references and allOf composition, and renders the result as Go types.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Document is a schema file with named definitions.
+type Document struct {
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Definitions map[string]*Schema `json:"definitions"`
+
+	// Defs is the newer spelling of Definitions; Load merges it into
+	// Definitions.
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Schema is a single JSON Schema node.
+type Schema struct {
+	Ref                  string     `json:"$ref,omitempty"`
+	Type                 TypeList   `json:"type,omitempty"`
+	Title                string     `json:"title,omitempty"`
+	Description          string     `json:"description,omitempty"`
+	Properties           Properties `json:"properties,omitempty"`
+	Required             []string   `json:"required,omitempty"`
+	Items                *Schema    `json:"items,omitempty"`
+	AllOf                []*Schema  `json:"allOf,omitempty"`
+	OneOf                []*Schema  `json:"oneOf,omitempty"`
+	AnyOf                []*Schema  `json:"anyOf,omitempty"`
+	Enum                 []string   `json:"enum,omitempty"`
+	OpenEnum             []string   `json:"_enum,omitempty"`
+	EnumDescriptions     []string   `json:"enumDescriptions,omitempty"`
+	AdditionalProperties *Schema    `json:"-"`
+	Deprecated           bool       `json:"deprecated,omitempty"`
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	var raw struct {
+		plain
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Schema(raw.plain)
+	ap := bytes.TrimSpace(raw.AdditionalProperties)
+	if len(ap) > 0 && ap[0] == '{' {
+		s.AdditionalProperties = &Schema{}
+		if err := json.Unmarshal(ap, s.AdditionalProperties); err != nil {
+			return fmt.Errorf("additionalProperties: %w", err)
+		}
+	}
+	return nil
+}
+
+// TypeList is the "type" keyword, which may be a single name or a list.
+type TypeList []string
+
+func (t *TypeList) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var single string
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		*t = TypeList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// Is reports whether the list is exactly the given single type.
+func (t TypeList) Is(name string) bool {
+	return len(t) == 1 && t[0] == name
+}
+
+// Property is a named member of an object schema.
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// Properties keeps object members in the order the schema declares them, so
+// generated code reads like the specification.
+type Properties []Property
+
+func (p *Properties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("properties: expected object")
+	}
+	var out Properties
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("properties: expected name")
+		}
+		schema := &Schema{}
+		if err := dec.Decode(schema); err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+		out = append(out, Property{Name: name, Schema: schema})
+	}
+	*p = out
+	return nil
+}
+
+func (p Properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for idx, prop := range p {
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(prop.Name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		val, err := json.Marshal(prop.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Get returns the named property.
+func (p Properties) Get(name string) (*Schema, bool) {
+	for _, prop := range p {
+		if prop.Name == name {
+			return prop.Schema, true
+		}
+	}
+	return nil, false
+}
+
+// Load reads a document from a local file or an http(s) URL.
+func Load(source string) (*Document, error) {
+	var r io.ReadCloser
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		res, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("fetching %s: %s", source, res.Status)
+		}
+		r = res.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	defer r.Close()
+
+	doc := &Document{}
+	if err := json.NewDecoder(r).Decode(doc); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", source, err)
+	}
+	if len(doc.Defs) > 0 {
+		if doc.Definitions == nil {
+			doc.Definitions = map[string]*Schema{}
+		}
+		for name, def := range doc.Defs {
+			if _, ok := doc.Definitions[name]; !ok {
+				doc.Definitions[name] = def
+			}
+		}
+		doc.Defs = nil
+	}
+	return doc, nil
+}
+
+// RefName returns the definition name a local reference points to.
+func RefName(ref string) string {
+	if name, ok := strings.CutPrefix(ref, "#/$defs/"); ok {
+		return name
+	}
+	return strings.TrimPrefix(ref, "#/definitions/")
+}
+
+// Resolve follows a chain of references to the schema which defines the
+// shape, returning the definition name of the last hop.
+func (d *Document) Resolve(s *Schema) (*Schema, string, error) {
+	name := ""
+	seen := map[string]bool{}
+	for s.Ref != "" {
+		name = RefName(s.Ref)
+		if seen[name] {
+			return nil, "", fmt.Errorf("reference cycle at %s", name)
+		}
+		seen[name] = true
+		target, ok := d.Definitions[name]
+		if !ok {
+			return nil, "", fmt.Errorf("unresolved reference %s", s.Ref)
+		}
+		s = target
+	}
+	return s, name, nil
+}
+
+// Flatten merges allOf members into a single object schema. Later members
+// override properties of earlier ones, which is how protocol schemas narrow
+// a base type, e.g. a Request whose command is fixed to one value.
+func (d *Document) Flatten(s *Schema) (*Schema, error) {
+	if len(s.AllOf) == 0 {
+		return s, nil
+	}
+	out := &Schema{
+		Type:        s.Type,
+		Title:       s.Title,
+		Description: s.Description,
+		Deprecated:  s.Deprecated,
+	}
+	required := map[string]bool{}
+	merge := func(part *Schema) {
+		if len(part.Type) > 0 && len(out.Type) == 0 {
+			out.Type = part.Type
+		}
+		if out.Description == "" {
+			out.Description = part.Description
+		}
+		for _, prop := range part.Properties {
+			replaced := false
+			for idx := range out.Properties {
+				if out.Properties[idx].Name == prop.Name {
+					out.Properties[idx].Schema = mergeProperty(out.Properties[idx].Schema, prop.Schema)
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				out.Properties = append(out.Properties, prop)
+			}
+		}
+		for _, name := range part.Required {
+			if !required[name] {
+				required[name] = true
+				out.Required = append(out.Required, name)
+			}
+		}
+		if part.AdditionalProperties != nil {
+			out.AdditionalProperties = part.AdditionalProperties
+		}
+	}
+	for _, part := range s.AllOf {
+		resolved, _, err := d.Resolve(part)
+		if err != nil {
+			return nil, err
+		}
+		flat, err := d.Flatten(resolved)
+		if err != nil {
+			return nil, err
+		}
+		merge(flat)
+	}
+	merge(&Schema{Properties: s.Properties, Required: s.Required, AdditionalProperties: s.AdditionalProperties})
+	return out, nil
+}
+
+// mergeProperty narrows a base property with an override. An override which
+// only constrains values (an enum) keeps the base documentation and type.
+func mergeProperty(base, override *Schema) *Schema {
+	merged := *override
+	if merged.Description == "" {
+		merged.Description = base.Description
+	}
+	if len(merged.Type) == 0 && merged.Ref == "" && len(merged.AllOf) == 0 {
+		merged.Type = base.Type
+	}
+	return &merged
+}
+
+// IsRequired reports whether an object schema requires the named property.
+func (s *Schema) IsRequired(name string) bool {
+	for _, req := range s.Required {
+		if req == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsObject reports whether the schema describes a structured object.
+func (s *Schema) IsObject() bool {
+	if len(s.Properties) > 0 {
+		return len(s.Type) == 0 || s.Type.Is("object")
+	}
+	return s.Type.Is("object") && s.AdditionalProperties == nil
+}
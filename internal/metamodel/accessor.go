@@ -0,0 +1,78 @@
+package metamodel
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// fileAccessors holds the views GenerateFiles writes for Accessors.
+const fileAccessors = "accessors.go"
+
+// field is a struct field as written, for its accessor.
+type field struct {
+	name   string
+	goType string
+	info   Info
+}
+
+// viewStruct is the struct a field of goType holds, if a view can read
+// it: a structure or literal written by the generator, or an alias of one.
+func (g *GoGenerator) viewStruct(goType string) (string, bool) {
+	name := strings.TrimPrefix(goType, "*")
+	if a := g.Model.TypeAlias(name); a != nil && a.Type.Kind == KindReference {
+		name = a.Type.Name
+	}
+	_, ok := g.fields[name]
+	return name, ok
+}
+
+// writeAccessors writes the views of Accessors and of the structs their
+// fields reach, in the order they are reached.
+func (g *GoGenerator) writeAccessors(buf *bytes.Buffer) {
+	views := map[string]string{}
+	var order []string
+	var reach func(name string)
+	reach = func(name string) {
+		if _, ok := views[name]; ok {
+			return
+		}
+		if _, ok := g.fields[name]; !ok {
+			return
+		}
+		views[name] = g.claim(name + "View")
+		order = append(order, name)
+		for _, f := range g.fields[name] {
+			if s, ok := g.viewStruct(f.goType); ok {
+				reach(s)
+			}
+		}
+	}
+	for _, root := range g.Accessors {
+		reach(root)
+	}
+
+	for _, name := range order {
+		view := views[name]
+		fmt.Fprintf(buf, "// %s reads a *%s, which may be nil, its getters\n// returning the zero value of absent fields.\n", view, name)
+		fmt.Fprintf(buf, "type %s struct {\n\ts *%s\n}\n\n", view, name)
+		fmt.Fprintf(buf, "// View returns a nil-safe reader of s.\nfunc (s *%s) View() %s {\n\treturn %[2]s{s}\n}\n\n", name, view)
+		fmt.Fprintf(buf, "// Get returns the %s read, or nil.\nfunc (v %s) Get() *%[1]s {\n\treturn v.s\n}\n\n", name, view)
+		for _, f := range g.fields[name] {
+			writeDoc(buf, "", f.info)
+			if s, ok := g.viewStruct(f.goType); ok {
+				ref := "v.s." + f.name
+				if !strings.HasPrefix(f.goType, "*") {
+					ref = "&" + ref
+				}
+				fmt.Fprintf(buf, "func (v %s) %s() %s {\n\tif v.s == nil {\n\t\treturn %[3]s{}\n\t}\n\treturn %[3]s{%s}\n}\n\n", view, f.name, views[s], ref)
+				continue
+			}
+			if elem, ok := strings.CutPrefix(f.goType, "*"); ok {
+				fmt.Fprintf(buf, "func (v %s) %s() (x %s) {\n\tif v.s != nil && v.s.%[2]s != nil {\n\t\tx = *v.s.%[2]s\n\t}\n\treturn x\n}\n\n", view, f.name, elem)
+				continue
+			}
+			fmt.Fprintf(buf, "func (v %s) %s() (x %s) {\n\tif v.s != nil {\n\t\tx = v.s.%[2]s\n\t}\n\treturn x\n}\n\n", view, f.name, f.goType)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package metamodel
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+// ServerHandler is a handler interface Generate declares for a message the
+// client sends, with the server capability advertising it, empty for
+// messages every server handles.
+type ServerHandler struct {
+	Method     string
+	Capability string
+
+	// Interface is the handler interface, and Partial the one streaming
+	// partial results, empty when the request has none. RegisterServer
+	// registers either.
+	Interface string
+	Partial   string
+}
+
+// ServerHandlers lists the handler interfaces of the messages a server
+// handles, by method, as Generate names them with g's settings.
+func (g *GoGenerator) ServerHandlers() []ServerHandler {
+	var buf bytes.Buffer
+	g.generate(&buf)
+	var out []ServerHandler
+	for _, m := range g.handled {
+		if m.direction != ClientToServer && m.direction != Both {
+			continue
+		}
+		out = append(out, ServerHandler{
+			Method:     m.method,
+			Capability: m.serverCapability,
+			Interface:  m.iface,
+			Partial:    m.partialIface,
+		})
+	}
+	return out
+}
+
+// Assertions is a Go file of package g.Package asserting at compile time
+// that the type server implements the handler interfaces of every message
+// the server capabilities advertise, such as hoverProvider or
+// workspace.fileOperations.willRename, so a server claiming a feature it
+// doesn't handle fails to build. protocol is the import path of the
+// package Generate's code is in, empty when it is the same package.
+func (g *GoGenerator) Assertions(server, protocol string, capabilities []string) ([]byte, error) {
+	handlers := map[string][]ServerHandler{}
+	for _, h := range g.ServerHandlers() {
+		if h.Capability != "" {
+			handlers[h.Capability] = append(handlers[h.Capability], h)
+		}
+	}
+	qualifier := ""
+	if protocol != "" {
+		qualifier = protocol[strings.LastIndex(protocol, "/")+1:] + "."
+	}
+
+	sort.Strings(capabilities)
+	var body bytes.Buffer
+	for _, c := range capabilities {
+		hs := handlers[c]
+		if len(hs) == 0 {
+			return nil, fmt.Errorf("metamodel: no message the client sends is advertised by %q", c)
+		}
+		fmt.Fprintf(&body, "\t// %s\n", c)
+		for _, h := range hs {
+			fmt.Fprintf(&body, "\t_ %s%s = (*%s)(nil) // %s\n", qualifier, h.Interface, server, h.Method)
+		}
+	}
+
+	var buf bytes.Buffer
+	if g.Header != "" {
+		fmt.Fprintf(&buf, "// %s\n\n", g.Header)
+	}
+	fmt.Fprintf(&buf, "package %s\n\n", g.Package)
+	if protocol != "" && len(capabilities) > 0 {
+		fmt.Fprintf(&buf, "import %q\n\n", protocol)
+	}
+	if len(capabilities) > 0 {
+		fmt.Fprintf(&buf, "// %s handles the messages of the capabilities it advertises.\nvar (\n%s)\n", server, body.String())
+	}
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,284 @@
+package metamodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is how an element differs between two versions.
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change is one element which differs between two versions: a message by
+// its method, or a Go declaration by its name, a field or method as
+// Type.Name. Old and New describe it: a message's direction and types, a
+// declaration's Go type or signature.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	Name string     `json:"name"`
+	Old  string     `json:"old,omitempty"`
+	New  string     `json:"new,omitempty"`
+}
+
+// Changelog is what changed between the metaModels of two protocol
+// releases, for downstream servers planning a migration: the messages, and
+// the exported Go API generated from each, in name order.
+type Changelog struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Messages []Change `json:"messages"`
+	API      []Change `json:"api"`
+}
+
+// Diff generates the Go code of old and new with gen's settings, its Model
+// aside, and lists the differences.
+func Diff(gen *GoGenerator, old, new *Model) (*Changelog, error) {
+	oldAPI, err := api(gen, old)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", old.MetaData.Version, err)
+	}
+	newAPI, err := api(gen, new)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", new.MetaData.Version, err)
+	}
+	return &Changelog{
+		From:     old.MetaData.Version,
+		To:       new.MetaData.Version,
+		Messages: diff(messages(old), messages(new)),
+		API:      diff(oldAPI, newAPI),
+	}, nil
+}
+
+func diff(old, new map[string]string) []Change {
+	changes := []Change{}
+	for name, o := range old {
+		n, ok := new[name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: Removed, Name: name, Old: o})
+		case n != o:
+			changes = append(changes, Change{Kind: Changed, Name: name, Old: o, New: n})
+		}
+	}
+	for name, n := range new {
+		if _, ok := old[name]; !ok {
+			changes = append(changes, Change{Kind: Added, Name: name, New: n})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// messages describes each of m's messages by method.
+func messages(m *Model) map[string]string {
+	out := map[string]string{}
+	for _, r := range m.Requests {
+		out[r.Method] = describeRequest(r)
+	}
+	for _, n := range m.Notifications {
+		out[n.Method] = describeNotification(n)
+	}
+	return out
+}
+
+func describeParams(params Params) string {
+	if len(params) == 0 {
+		return "none"
+	}
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// api describes each exported declaration of the code gen generates from
+// m: types by their definition, struct fields and interface methods as
+// Type.Name, functions and methods by signature, and constants by value.
+func api(gen *GoGenerator, m *Model) (map[string]string, error) {
+	g := &GoGenerator{
+		Model:      m,
+		Package:    gen.Package,
+		Skip:       gen.Skip,
+		Overrides:  gen.Overrides,
+		RPCPackage: gen.RPCPackage,
+		Numbers:    gen.Numbers,
+		Accessors:  gen.Accessors,
+	}
+	src, err := g.Generate()
+	if err != nil {
+		return nil, err
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiver(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				name = recv + "." + name
+			}
+			out[name] = types.ExprString(d.Type)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.IsExported() {
+						typeAPI(out, spec)
+					}
+				case *ast.ValueSpec:
+					for i, name := range spec.Names {
+						if !name.IsExported() {
+							continue
+						}
+						desc := d.Tok.String()
+						if spec.Type != nil {
+							desc += " " + types.ExprString(spec.Type)
+						}
+						if i < len(spec.Values) && d.Tok == token.CONST {
+							desc += " = " + types.ExprString(spec.Values[i])
+						}
+						out[name.Name] = desc
+					}
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func typeAPI(out map[string]string, spec *ast.TypeSpec) {
+	name := spec.Name.Name
+	def := "type"
+	if spec.Assign.IsValid() {
+		def += " ="
+	}
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		out[name] = def + " struct"
+		for _, field := range t.Fields.List {
+			desc := types.ExprString(field.Type)
+			if field.Tag != nil {
+				desc += " " + field.Tag.Value
+			}
+			if len(field.Names) == 0 {
+				out[name+"."+receiver(field.Type)] = "embedded " + desc
+			}
+			for _, n := range field.Names {
+				if n.IsExported() {
+					out[name+"."+n.Name] = desc
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		out[name] = def + " interface"
+		for _, method := range t.Methods.List {
+			for _, n := range method.Names {
+				out[name+"."+n.Name] = types.ExprString(method.Type)
+			}
+			if len(method.Names) == 0 {
+				out[name+"."+types.ExprString(method.Type)] = "embedded"
+			}
+		}
+	default:
+		out[name] = def + " " + types.ExprString(spec.Type)
+	}
+}
+
+// receiver is the name of the type of a receiver or embedded field.
+func receiver(t ast.Expr) string {
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		return receiver(t.X)
+	case *ast.IndexExpr:
+		return receiver(t.X)
+	case *ast.IndexListExpr:
+		return receiver(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	}
+	return types.ExprString(t)
+}
+
+// WriteJSON writes the changelog as indented JSON.
+func (c *Changelog) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteMarkdown writes the changelog as a Markdown document, the messages
+// and then the Go API, each added, removed and changed.
+func (c *Changelog) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Changes from %s to %s\n", c.From, c.To)
+	section := func(title string, changes []Change) {
+		fmt.Fprintf(&b, "\n## %s\n", title)
+		if len(changes) == 0 {
+			b.WriteString("\nNone.\n")
+			return
+		}
+		for _, kind := range []ChangeKind{Added, Removed, Changed} {
+			var lines []string
+			for _, ch := range changes {
+				if ch.Kind != kind {
+					continue
+				}
+				switch kind {
+				case Added:
+					lines = append(lines, fmt.Sprintf("- `%s`: %s", ch.Name, code(ch.New)))
+				case Removed:
+					lines = append(lines, fmt.Sprintf("- `%s`: %s", ch.Name, code(ch.Old)))
+				default:
+					lines = append(lines, fmt.Sprintf("- `%s`: %s → %s", ch.Name, code(ch.Old), code(ch.New)))
+				}
+			}
+			if len(lines) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "\n### %s%s\n\n%s\n", strings.ToUpper(string(kind[:1])), kind[1:], strings.Join(lines, "\n"))
+		}
+	}
+	section("Messages", c.Messages)
+	section("Go API", c.API)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// code quotes s as inline code, with a fence longer than any backtick run
+// inside it, as struct tags have.
+func code(s string) string {
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
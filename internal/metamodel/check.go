@@ -0,0 +1,315 @@
+package metamodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Checker reports where JSON values deviate from the model's types, for
+// auditing what a peer sends: properties the model doesn't declare,
+// required ones missing, nulls where the type doesn't allow them, and
+// values of the wrong type.
+type Checker struct {
+	Model *Model
+}
+
+// Deviation is one place a value differs from its type.
+type Deviation struct {
+	// Path locates the value, as in "capabilities.hoverProvider" or
+	// "items[2]"; empty for the value itself.
+	Path    string
+	Message string
+}
+
+func (d Deviation) String() string {
+	if d.Path == "" {
+		return d.Message
+	}
+	return d.Path + ": " + d.Message
+}
+
+// Check reports how data deviates from type t, in document order; none
+// when it conforms. Data which isn't JSON is one deviation.
+func (c *Checker) Check(t *Type, data json.RawMessage) []Deviation {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return []Deviation{{Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	var out []Deviation
+	c.check(t, v, "", &out)
+	return out
+}
+
+func (c *Checker) check(t *Type, v any, path string, out *[]Deviation) {
+	if t == nil {
+		return
+	}
+	if v == nil {
+		if !c.nullable(t, map[string]bool{}) {
+			c.report(out, path, "null, want %s", t)
+		}
+		return
+	}
+	switch t.Kind {
+	case KindBase:
+		if !baseMatches(t.Name, v) {
+			c.report(out, path, "%s, want %s", describe(v), t.Name)
+		}
+	case KindReference:
+		c.reference(t, v, path, out)
+	case KindArray:
+		items, ok := v.([]any)
+		if !ok {
+			c.report(out, path, "%s, want %s", describe(v), t)
+			return
+		}
+		for idx, item := range items {
+			c.check(t.Element, item, fmt.Sprintf("%s[%d]", path, idx), out)
+		}
+	case KindMap:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			c.report(out, path, "%s, want %s", describe(v), t)
+			return
+		}
+		for _, key := range sortedKeys(obj) {
+			c.check(t.Value, obj[key], join(path, key), out)
+		}
+	case KindAnd:
+		var props []*Property
+		for _, item := range t.Items {
+			props = append(props, c.properties(item)...)
+		}
+		c.object(props, v, path, out)
+	case KindOr:
+		c.or(t, v, path, out)
+	case KindTuple:
+		items, ok := v.([]any)
+		if !ok || len(items) != len(t.Items) {
+			c.report(out, path, "%s, want %s", describe(v), t)
+			return
+		}
+		for idx, item := range items {
+			c.check(t.Items[idx], item, fmt.Sprintf("%s[%d]", path, idx), out)
+		}
+	case KindLiteral:
+		c.object(c.properties(t), v, path, out)
+	case KindStringLiteral:
+		if v != t.StringValue {
+			c.report(out, path, "%s, want %q", describe(v), t.StringValue)
+		}
+	case KindIntegerLiteral:
+		if n, ok := v.(json.Number); !ok || n.String() != fmt.Sprint(t.IntegerValue) {
+			c.report(out, path, "%s, want %d", describe(v), t.IntegerValue)
+		}
+	case KindBooleanLiteral:
+		if v != t.BooleanValue {
+			c.report(out, path, "%s, want %t", describe(v), t.BooleanValue)
+		}
+	}
+}
+
+func (c *Checker) reference(t *Type, v any, path string, out *[]Deviation) {
+	switch t.Name {
+	case "LSPAny":
+		return
+	case "LSPObject":
+		if _, ok := v.(map[string]any); !ok {
+			c.report(out, path, "%s, want object", describe(v))
+		}
+		return
+	case "LSPArray":
+		if _, ok := v.([]any); !ok {
+			c.report(out, path, "%s, want array", describe(v))
+		}
+		return
+	}
+	if s := c.Model.Structure(t.Name); s != nil {
+		c.object(c.Model.Properties(s), v, path, out)
+		return
+	}
+	if e := c.Model.Enumeration(t.Name); e != nil {
+		c.enum(e, v, path, out)
+		return
+	}
+	if a := c.Model.TypeAlias(t.Name); a != nil {
+		c.check(a.Type, v, path, out)
+	}
+}
+
+func (c *Checker) enum(e *Enumeration, v any, path string, out *[]Deviation) {
+	if e.Type != nil && !baseMatches(e.Type.Name, v) {
+		c.report(out, path, "%s, want %s", describe(v), e.Name)
+		return
+	}
+	if e.SupportsCustomValues {
+		return
+	}
+	raw, _ := json.Marshal(v)
+	for _, entry := range e.Values {
+		var value bytes.Buffer
+		if json.Compact(&value, entry.Value) == nil && bytes.Equal(value.Bytes(), raw) {
+			return
+		}
+	}
+	c.report(out, path, "%s is not a %s", raw, e.Name)
+}
+
+// or accepts v if any member of t does and otherwise reports the member
+// it comes closest to, which is usually the one the sender meant.
+func (c *Checker) or(t *Type, v any, path string, out *[]Deviation) {
+	var closest []Deviation
+	for idx, item := range t.Items {
+		var found []Deviation
+		c.check(item, v, path, &found)
+		if len(found) == 0 {
+			return
+		}
+		if idx == 0 || len(found) < len(closest) {
+			closest = found
+		}
+	}
+	if len(closest) == 1 && closest[0].Path == path {
+		c.report(out, path, "%s, want %s", describe(v), t)
+		return
+	}
+	*out = append(*out, closest...)
+}
+
+func (c *Checker) object(props []*Property, v any, path string, out *[]Deviation) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		c.report(out, path, "%s, want object", describe(v))
+		return
+	}
+	known := map[string]bool{}
+	for _, p := range props {
+		known[p.Name] = true
+		val, present := obj[p.Name]
+		switch {
+		case present:
+			c.check(p.Type, val, join(path, p.Name), out)
+		case !p.Optional:
+			c.report(out, join(path, p.Name), "missing required property")
+		}
+	}
+	for _, key := range sortedKeys(obj) {
+		if !known[key] {
+			c.report(out, join(path, key), "unknown property")
+		}
+	}
+}
+
+// properties are the properties of an object type, flattened.
+func (c *Checker) properties(t *Type) []*Property {
+	switch t.Kind {
+	case KindLiteral:
+		if t.Literal != nil {
+			return t.Literal.Properties
+		}
+	case KindReference:
+		if s := c.Model.Structure(t.Name); s != nil {
+			return c.Model.Properties(s)
+		}
+		if a := c.Model.TypeAlias(t.Name); a != nil {
+			return c.properties(a.Type)
+		}
+	}
+	return nil
+}
+
+// nullable reports whether t admits null, following aliases; seen breaks
+// cycles of them.
+func (c *Checker) nullable(t *Type, seen map[string]bool) bool {
+	switch t.Kind {
+	case KindBase:
+		return t.Name == "null"
+	case KindOr:
+		for _, item := range t.Items {
+			if c.nullable(item, seen) {
+				return true
+			}
+		}
+	case KindReference:
+		if t.Name == "LSPAny" {
+			return true
+		}
+		if a := c.Model.TypeAlias(t.Name); a != nil && !seen[t.Name] {
+			seen[t.Name] = true
+			return c.nullable(a.Type, seen)
+		}
+	}
+	return false
+}
+
+func (c *Checker) report(out *[]Deviation, path, format string, args ...any) {
+	*out = append(*out, Deviation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func baseMatches(name string, v any) bool {
+	switch name {
+	case "string", "URI", "DocumentUri", "RegExp":
+		_, ok := v.(string)
+		return ok
+	case "integer", "uinteger":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return false
+		}
+		if name == "uinteger" {
+			return i >= 0 && i <= math.MaxInt32
+		}
+		return i >= math.MinInt32 && i <= math.MaxInt32
+	case "decimal":
+		_, ok := v.(json.Number)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
+// describe names the JSON type of v for a deviation's message.
+func describe(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case json.Number:
+		return "number " + v.String()
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
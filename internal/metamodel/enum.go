@@ -0,0 +1,87 @@
+package metamodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// writeEnum declares an enumeration's type and constants, named after the
+// type and entry, with a String method. Enumerations which don't support
+// custom values reject unknown values when unmarshaling; the others keep
+// them as they are, so they survive a round trip, and get an IsKnown
+// method telling them from the constants.
+func (g *GoGenerator) writeEnum(buf *bytes.Buffer, e *Enumeration) {
+	goType := GoType(e.Type)
+	isString := goType == "string"
+
+	documented := writeDoc(buf, "", e.Info)
+	if e.SupportsCustomValues {
+		if documented {
+			buf.WriteString("//\n")
+		}
+		buf.WriteString("// Values other than the constants below are allowed; IsKnown reports\n// whether a value is one of them.\n")
+	}
+	fmt.Fprintf(buf, "type %s %s\n\n", e.Name, goType)
+
+	var constants []constant
+	seen := map[string]bool{}
+	buf.WriteString("const (\n")
+	for _, entry := range e.Values {
+		value := string(entry.Value)
+		if isString {
+			var s string
+			if json.Unmarshal(entry.Value, &s) != nil {
+				continue
+			}
+			value = strconv.Quote(s)
+		}
+		name := g.claim(e.Name + exported(entry.Name))
+		writeDoc(buf, "\t", entry.Info)
+		fmt.Fprintf(buf, "\t%s %s = %s\n", name, e.Name, value)
+		if !seen[value] {
+			seen[value] = true
+			constants = append(constants, constant{name, value})
+		}
+	}
+	buf.WriteString(")\n\n")
+
+	if isString {
+		fmt.Fprintf(buf, "func (e %s) String() string {\n\treturn string(e)\n}\n\n", e.Name)
+	} else {
+		fmt.Fprintf(buf, "func (e %s) String() string {\n\tswitch e {\n", e.Name)
+		for _, c := range constants {
+			fmt.Fprintf(buf, "\tcase %s:\n\t\treturn %q\n", c.name, c.name[len(e.Name):])
+		}
+		fmt.Fprintf(buf, "\t}\n\treturn fmt.Sprintf(\"%s(%%d)\", %s(e))\n}\n\n", e.Name, goType)
+	}
+
+	if len(constants) == 0 {
+		return
+	}
+	if e.SupportsCustomValues {
+		fmt.Fprintf(buf, "func (e %s) IsKnown() bool {\n\tswitch e {\n\tcase ", e.Name)
+		writeNames(buf, constants)
+		buf.WriteString(":\n\t\treturn true\n\t}\n\treturn false\n}\n\n")
+		return
+	}
+	fmt.Fprintf(buf, "func (e *%s) UnmarshalJSON(data []byte) error {\n", e.Name)
+	fmt.Fprintf(buf, "\tvar v %s\n\tif err := json.Unmarshal(data, &v); err != nil {\n\t\treturn err\n\t}\n", goType)
+	fmt.Fprintf(buf, "\tswitch %s(v) {\n\tcase ", e.Name)
+	writeNames(buf, constants)
+	fmt.Fprintf(buf, ":\n\t\t*e = %s(v)\n\t\treturn nil\n\t}\n", e.Name)
+	fmt.Fprintf(buf, "\treturn fmt.Errorf(\"unknown %s %%v\", v)\n}\n\n", e.Name)
+}
+
+type constant struct{ name, value string }
+
+// writeNames writes a case list of the constants.
+func writeNames(buf *bytes.Buffer, constants []constant) {
+	for idx, c := range constants {
+		if idx > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(c.name)
+	}
+}
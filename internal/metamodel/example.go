@@ -0,0 +1,300 @@
+package metamodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Examples builds the smallest and the largest valid value of each of the
+// model's types, deterministically, for seed corpora and documentation.
+// The minimal value has only required properties, empty arrays and maps,
+// and null where it is allowed; the maximal one has every property and one
+// element in each array and map, down to MaxDepth.
+type Examples struct {
+	Model *Model
+
+	// MaxDepth is how deep maximal values nest before they turn minimal,
+	// 4 when zero.
+	MaxDepth int
+
+	// truncated is set once a value is cut short by a cycle.
+	truncated bool
+}
+
+// Minimal returns the smallest value of type t.
+func (e *Examples) Minimal(t *Type) any {
+	return e.value(t, 0, false)
+}
+
+// Maximal returns the largest value of type t.
+func (e *Examples) Maximal(t *Type) any {
+	return e.value(t, 0, true)
+}
+
+func (e *Examples) maxDepth() int {
+	if e.MaxDepth > 0 {
+		return e.MaxDepth
+	}
+	return 4
+}
+
+func (e *Examples) value(t *Type, depth int, full bool) any {
+	// As in Random, a cycle of required properties has no finite value.
+	if t == nil {
+		return nil
+	}
+	if depth > 2*e.maxDepth()+4 {
+		e.truncated = true
+		return nil
+	}
+	full = full && depth < e.maxDepth()
+	switch t.Kind {
+	case KindBase:
+		return exampleBase(t.Name, full)
+	case KindReference:
+		return e.reference(t.Name, depth, full)
+	case KindArray:
+		if !full {
+			return []any{}
+		}
+		return []any{e.value(t.Element, depth+1, full)}
+	case KindMap:
+		if !full {
+			return map[string]any{}
+		}
+		return map[string]any{fmt.Sprint(e.value(t.Key, depth+1, full)): e.value(t.Value, depth+1, full)}
+	case KindAnd:
+		out := map[string]any{}
+		for _, item := range t.Items {
+			if obj, ok := e.value(item, depth, full).(map[string]any); ok {
+				for key, val := range obj {
+					out[key] = val
+				}
+			}
+		}
+		return out
+	case KindOr:
+		return e.value(e.choose(t.Items, full), depth, full)
+	case KindTuple:
+		out := make([]any, len(t.Items))
+		for idx, item := range t.Items {
+			out[idx] = e.value(item, depth+1, full)
+		}
+		return out
+	case KindLiteral:
+		if t.Literal == nil {
+			return map[string]any{}
+		}
+		return e.object(t.Literal.Properties, depth, full)
+	case KindStringLiteral:
+		return t.StringValue
+	case KindIntegerLiteral:
+		return float64(t.IntegerValue)
+	case KindBooleanLiteral:
+		return t.BooleanValue
+	}
+	return nil
+}
+
+// choose picks the member of an or type to build: null when minimal and
+// allowed, else the first member; when maximal the first which is an
+// object, else the first which isn't null.
+func (e *Examples) choose(items []*Type, full bool) *Type {
+	var first *Type
+	for _, item := range items {
+		null := item.Kind == KindBase && item.Name == "null"
+		if null && !full {
+			return item
+		}
+		if !null && first == nil {
+			first = item
+		}
+		if full && e.isObject(item) {
+			return item
+		}
+	}
+	if first == nil && len(items) > 0 {
+		return items[0]
+	}
+	return first
+}
+
+func (e *Examples) isObject(t *Type) bool {
+	switch t.Kind {
+	case KindLiteral, KindAnd:
+		return true
+	case KindReference:
+		return e.Model.Structure(t.Name) != nil
+	}
+	return false
+}
+
+func exampleBase(name string, full bool) any {
+	switch name {
+	case "string":
+		if full {
+			return "naïve 日本語 😀"
+		}
+		return ""
+	case "RegExp":
+		return ".*"
+	case "URI", "DocumentUri":
+		return "file:///example/main.txt"
+	case "integer", "uinteger":
+		if full {
+			return float64(1<<31 - 1)
+		}
+		return float64(0)
+	case "decimal":
+		if full {
+			return 1.5
+		}
+		return float64(0)
+	case "boolean":
+		return full
+	}
+	return nil
+}
+
+func (e *Examples) reference(name string, depth int, full bool) any {
+	switch name {
+	case "LSPAny":
+		if full {
+			return map[string]any{"key": []any{"value", float64(1), true}}
+		}
+		return nil
+	case "LSPObject":
+		if full {
+			return map[string]any{"key": "value"}
+		}
+		return map[string]any{}
+	case "LSPArray":
+		if full {
+			return []any{"value"}
+		}
+		return []any{}
+	}
+	if s := e.Model.Structure(name); s != nil {
+		return e.object(e.Model.Properties(s), depth, full)
+	}
+	if enum := e.Model.Enumeration(name); enum != nil && len(enum.Values) > 0 {
+		entry := enum.Values[0]
+		if full {
+			entry = enum.Values[len(enum.Values)-1]
+		}
+		var v any
+		_ = json.Unmarshal(entry.Value, &v)
+		return v
+	}
+	if a := e.Model.TypeAlias(name); a != nil {
+		return e.value(a.Type, depth, full)
+	}
+	return nil
+}
+
+func (e *Examples) object(props []*Property, depth int, full bool) map[string]any {
+	// Properties are built a level down, where a maximal value may already
+	// have turned minimal. An optional one is only kept while its own
+	// value is maximal: a minimal one, an empty array or false, is dropped
+	// by omitempty when encoded back.
+	full = full && depth+1 < e.maxDepth()
+	out := map[string]any{}
+	for _, p := range props {
+		if p.Optional && !full {
+			continue
+		}
+		out[p.Name] = e.value(p.Type, depth+1, full)
+	}
+	return out
+}
+
+// CorpusEntry is one seed message: a JSON-RPC request, notification or
+// response, named for its method, part and size, such as
+// "textDocument_hover.params.max". Messages without params are one entry
+// named for the method alone.
+type CorpusEntry struct {
+	Name string
+	Data []byte
+}
+
+// Corpus builds the minimal and maximal message of each request's params
+// and result and each notification's params, as complete JSON-RPC messages,
+// sorted by name.
+func (e *Examples) Corpus() ([]CorpusEntry, error) {
+	var out []CorpusEntry
+	add := func(name string, msg map[string]any) error {
+		msg["jsonrpc"] = "2.0"
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("example %s: %w", name, err)
+		}
+		out = append(out, CorpusEntry{Name: name, Data: data})
+		return nil
+	}
+	sized := func(method, part string, t func(full bool) any, msg func(value any) map[string]any) error {
+		for _, full := range []bool{false, true} {
+			size := "min"
+			if full {
+				size = "max"
+			}
+			if err := add(corpusName(method)+"."+part+"."+size, msg(t(full))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	message := func(method string, params Params, id bool) error {
+		msg := func(value any) map[string]any {
+			m := map[string]any{"method": method}
+			if id {
+				m["id"] = 1
+			}
+			if value != nil {
+				m["params"] = value
+			}
+			return m
+		}
+		if len(params) == 0 {
+			return add(corpusName(method), msg(nil))
+		}
+		return sized(method, "params", func(full bool) any { return e.params(params, full) }, msg)
+	}
+
+	for _, req := range e.Model.Requests {
+		if err := message(req.Method, req.Params, true); err != nil {
+			return nil, err
+		}
+		result := func(full bool) any { return e.value(req.Result, 0, full) }
+		err := sized(req.Method, "result", result, func(value any) map[string]any {
+			return map[string]any{"id": 1, "result": value}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range e.Model.Notifications {
+		if err := message(n.Method, n.Params, false); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (e *Examples) params(params Params, full bool) any {
+	if len(params) == 1 {
+		return e.value(params[0], 0, full)
+	}
+	list := make([]any, len(params))
+	for idx, t := range params {
+		list[idx] = e.value(t, 0, full)
+	}
+	return list
+}
+
+// corpusName makes a method usable in a file name.
+func corpusName(method string) string {
+	return strings.NewReplacer("/", "_", "$", "").Replace(method)
+}
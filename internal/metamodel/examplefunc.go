@@ -0,0 +1,125 @@
+package metamodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// exampleDepth is how deeply example params nest structures before the
+// rest are left at their zero values.
+const exampleDepth = 3
+
+// writeExamples writes an Example function for the handler interface of
+// each message whose params are a structure, as example_test.go: it
+// registers a handler for the message on a mux, builds the params with
+// their required fields and handles the result, so godoc shows working
+// usage and go test keeps it compiling.
+func (g *GoGenerator) writeExamples(buf *bytes.Buffer, msgs []*message) {
+	pointers := g.cycleFields(g.decls())
+	rpc := g.rpcPackage()
+	rpcName := rpc[strings.LastIndex(rpc, "/")+1:]
+	for _, m := range msgs {
+		if len(m.params) != 1 {
+			continue
+		}
+		t, _ := stripNull(m.params[0])
+		if t.Kind != KindReference || m.paramType != "*"+t.Name || g.Model.Structure(t.Name) == nil {
+			continue
+		}
+		fmt.Fprintf(buf, "func Example%s() {\n", m.iface)
+		fmt.Fprintf(buf, "\tmux := %s.NewMux()\n", rpcName)
+		switch {
+		case !m.request:
+			fmt.Fprintf(buf, "\t%s.RegisterNotification(mux, %s, func(ctx context.Context, params %s) error {\n\t\treturn nil\n\t})\n\n", rpcName, m.constant, m.paramType)
+		case m.resultType != "":
+			fmt.Fprintf(buf, "\t%s.RegisterMethod(mux, %s, func(ctx context.Context, params %s) (%s, error) {\n", rpcName, m.constant, m.paramType, m.resultType)
+			fmt.Fprintf(buf, "\t\tvar result %s\n\t\treturn result, nil\n\t})\n\n", m.resultType)
+		default:
+			fmt.Fprintf(buf, "\t%s.RegisterMethod(mux, %s, func(ctx context.Context, params %s) (any, error) {\n\t\treturn nil, nil\n\t})\n\n", rpcName, m.constant, m.paramType)
+		}
+
+		fmt.Fprintf(buf, "\tparams, err := json.Marshal(&%s)\n", g.exampleStruct(t.Name, pointers, 0, "\t"))
+		buf.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+		id := `ID: json.RawMessage("1"), `
+		if !m.request {
+			id = ""
+		}
+		fmt.Fprintf(buf, "\tresult, err := mux.Handle(context.Background(), &%s.Request{%sMethod: %s, Params: params})\n", rpcName, id, m.constant)
+		buf.WriteString("\tif err != nil {\n\t\tfmt.Println(err)\n\t\treturn\n\t}\n")
+		if m.resultType != "" {
+			fmt.Fprintf(buf, "\treply, _ := result.(%s)\n\tfmt.Println(reply)\n}\n\n", m.resultType)
+		} else {
+			buf.WriteString("\tfmt.Println(result)\n}\n\n")
+		}
+	}
+}
+
+// exampleStruct is a composite literal of the structure name, setting its
+// required fields which have no nil value.
+func (g *GoGenerator) exampleStruct(name string, pointers map[string]map[string]bool, depth int, indent string) string {
+	var fields []string
+	if depth < exampleDepth {
+		for _, p := range g.Model.Properties(g.Model.Structure(name)) {
+			if _, overridden := g.overrideProperty(name, p.Name); overridden || p.Optional || pointers[name][p.Name] {
+				continue
+			}
+			if value := g.exampleValue(p.Type, pointers, depth, indent+"\t"); value != "" {
+				fields = append(fields, fmt.Sprintf("%s\t%s: %s,\n", indent, GoName(p.Name), value))
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return name + "{}"
+	}
+	return name + "{\n" + strings.Join(fields, "") + indent + "}"
+}
+
+// exampleValue is a Go expression for a value of t, or "" to leave it at
+// its zero value, as unions, collections and nullable types are.
+func (g *GoGenerator) exampleValue(t *Type, pointers map[string]map[string]bool, depth int, indent string) string {
+	if t.Kind == KindBase || t.Kind == KindReference {
+		if _, overridden := g.overrideType(t.Name); overridden {
+			return ""
+		}
+	}
+	switch t.Kind {
+	case KindBase:
+		switch t.Name {
+		case "string":
+			return `"example"`
+		case "URI", "DocumentUri":
+			return `"file:///example/main.txt"`
+		case "integer", "uinteger":
+			if g.Numbers == NumbersJSON {
+				return `"1"`
+			}
+			return "1"
+		case "decimal":
+			if g.Numbers == NumbersJSON {
+				return `"1.5"`
+			}
+			return "1.5"
+		case "boolean":
+			return "true"
+		}
+	case KindReference:
+		if g.Model.Structure(t.Name) != nil {
+			return g.exampleStruct(t.Name, pointers, depth+1, indent)
+		}
+		if e := g.Model.Enumeration(t.Name); e != nil && len(e.Values) > 0 {
+			value := string(e.Values[0].Value)
+			var s string
+			if json.Unmarshal(e.Values[0].Value, &s) == nil {
+				value = strconv.Quote(s)
+			}
+			return fmt.Sprintf("%s(%s)", e.Name, value)
+		}
+		if a := g.Model.TypeAlias(t.Name); a != nil && depth < exampleDepth {
+			return g.exampleValue(a.Type, pointers, depth+1, indent)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,221 @@
+package metamodel
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter selects the elements of a model by their lifecycle: proposed
+// features previewing the next release, deprecated ones, and those added
+// after a protocol version.
+type Filter struct {
+	// Proposed and Deprecated keep the elements marked so. Deprecated ones
+	// are still part of the protocol, so lspschema keeps them by default.
+	Proposed   bool
+	Deprecated bool
+
+	// Version, such as "3.16", leaves out the elements added since it; none
+	// when empty.
+	Version string
+}
+
+// Apply returns a copy of m holding only the elements f keeps. Whatever
+// refers to a type left out is left out with it: a property, a message, or
+// a structure extending it. An enumeration left without values is left out
+// too.
+func (f Filter) Apply(m *Model) (*Model, error) {
+	var version []int
+	if f.Version != "" {
+		var ok bool
+		if version, ok = parseVersion(f.Version); !ok {
+			return nil, fmt.Errorf("metamodel: invalid version %q", f.Version)
+		}
+	}
+	keep := func(info Info) bool {
+		switch {
+		case info.Proposed && !f.Proposed:
+			return false
+		case info.Deprecated != "" && !f.Deprecated:
+			return false
+		case version != nil:
+			since, ok := parseVersion(info.Since)
+			return !ok || compareVersions(since, version) <= 0
+		}
+		return true
+	}
+
+	out := &Model{MetaData: m.MetaData}
+	dropped := map[string]bool{}
+	for _, s := range m.Structures {
+		if !keep(s.Info) {
+			dropped[s.Name] = true
+			continue
+		}
+		c := *s
+		c.Properties = nil
+		for _, p := range s.Properties {
+			if keep(p.Info) {
+				pc := *p
+				pc.Type = filterType(p.Type, keep)
+				c.Properties = append(c.Properties, &pc)
+			}
+		}
+		out.Structures = append(out.Structures, &c)
+	}
+	for _, e := range m.Enumerations {
+		if !keep(e.Info) {
+			dropped[e.Name] = true
+			continue
+		}
+		c := *e
+		c.Values = nil
+		for _, v := range e.Values {
+			if keep(v.Info) {
+				c.Values = append(c.Values, v)
+			}
+		}
+		if len(c.Values) == 0 {
+			dropped[e.Name] = true
+			continue
+		}
+		out.Enumerations = append(out.Enumerations, &c)
+	}
+	for _, a := range m.TypeAliases {
+		if !keep(a.Info) {
+			dropped[a.Name] = true
+			continue
+		}
+		c := *a
+		c.Type = filterType(a.Type, keep)
+		out.TypeAliases = append(out.TypeAliases, &c)
+	}
+	for _, r := range m.Requests {
+		if keep(r.Info) {
+			out.Requests = append(out.Requests, r)
+		}
+	}
+	for _, n := range m.Notifications {
+		if keep(n.Info) {
+			out.Notifications = append(out.Notifications, n)
+		}
+	}
+
+	// Leaving a type out can strand others which refer to it, which in turn
+	// strands more.
+	for len(dropped) > 0 {
+		next := map[string]bool{}
+		out.Structures = filterSlice(out.Structures, func(s *Structure) bool {
+			if refersTo(dropped, append(append([]*Type(nil), s.Extends...), s.Mixins...)...) {
+				next[s.Name] = true
+				return false
+			}
+			s.Properties = filterSlice(s.Properties, func(p *Property) bool { return !refersTo(dropped, p.Type) })
+			return true
+		})
+		out.TypeAliases = filterSlice(out.TypeAliases, func(a *TypeAlias) bool {
+			if refersTo(dropped, a.Type) {
+				next[a.Name] = true
+				return false
+			}
+			return true
+		})
+		out.Requests = filterSlice(out.Requests, func(r *Request) bool {
+			return !refersTo(dropped, append(append([]*Type(nil), r.Params...), r.Result, r.PartialResult, r.ErrorData, r.RegistrationOptions)...)
+		})
+		out.Notifications = filterSlice(out.Notifications, func(n *Notification) bool {
+			return !refersTo(dropped, append(append([]*Type(nil), n.Params...), n.RegistrationOptions)...)
+		})
+		dropped = next
+	}
+	return out, nil
+}
+
+// filterType is t with the literal properties keep rejects left out.
+func filterType(t *Type, keep func(Info) bool) *Type {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	c.Element = filterType(t.Element, keep)
+	c.Key = filterType(t.Key, keep)
+	c.Value = filterType(t.Value, keep)
+	c.Items = nil
+	for _, item := range t.Items {
+		c.Items = append(c.Items, filterType(item, keep))
+	}
+	if t.Literal != nil {
+		lit := *t.Literal
+		lit.Properties = nil
+		for _, p := range t.Literal.Properties {
+			if keep(p.Info) {
+				pc := *p
+				pc.Type = filterType(p.Type, keep)
+				lit.Properties = append(lit.Properties, &pc)
+			}
+		}
+		c.Literal = &lit
+	}
+	return &c
+}
+
+func refersTo(names map[string]bool, types ...*Type) bool {
+	set := map[string]bool{}
+	for _, t := range types {
+		collectRefs(t, set)
+	}
+	for name := range set {
+		if names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func filterSlice[T any](items []T, keep func(T) bool) []T {
+	out := items[:0]
+	for _, item := range items {
+		if keep(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+var versionPrefix = regexp.MustCompile(`^\d+(?:\.\d+)*`)
+
+// parseVersion reads the version at the start of a since tag, such as
+// "3.17.0" or "3.18.0 - proposed".
+func parseVersion(s string) ([]int, bool) {
+	match := versionPrefix.FindString(strings.TrimSpace(s))
+	if match == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, part := range strings.Split(match, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// compareVersions orders versions, missing parts counting as zero.
+func compareVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
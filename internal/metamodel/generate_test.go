@@ -0,0 +1,61 @@
+package metamodel
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestGeneratedRoundTrips generates a package with round-trip tests from
+// a model shaped like the specification's, nesting past MaxDepth, and runs
+// them against their own golden payloads.
+func TestGeneratedRoundTrips(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and tests a generated package")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command")
+	}
+	data, err := os.ReadFile("testdata/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := Parse(data, "testdata/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := &GoGenerator{
+		Model:      model,
+		Package:    "protocol",
+		Header:     "Code generated by lspschema from testdata/model.json. DO NOT EDIT.",
+		Accessors:  []string{"ClientCapabilities"},
+		RoundTrips: true,
+	}
+	files, err := gen.GenerateFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files["go.mod"] = []byte("module example.com/protocol\n\ngo 1.23.2\n\nrequire github.com/pentops/lsplib v0.0.0\n\nreplace github.com/pentops/lsplib => " + root + "\n")
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "test", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test of the generated package: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,157 @@
+package metamodel
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// writeDoc writes info as a Go doc comment, reporting whether there was
+// anything to write.
+func writeDoc(buf *bytes.Buffer, indent string, info Info) bool {
+	lines := docLines(info)
+	for _, line := range lines {
+		switch {
+		case line == "":
+			buf.WriteString(indent + "//\n")
+		case strings.HasPrefix(line, "\t"):
+			buf.WriteString(indent + "//" + line + "\n")
+		default:
+			buf.WriteString(indent + "// " + line + "\n")
+		}
+	}
+	return len(lines) > 0
+}
+
+var (
+	linkTag      = regexp.MustCompile(`\{@link(?:code|plain)?\s+([^\s}]+)(?:\s+([^}]*))?\}`)
+	markdownLink = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	codeSpan     = regexp.MustCompile("`([^`]+)`")
+	listItem     = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s`)
+	heading      = regexp.MustCompile(`^#+\s+(.*?)\s*#*$`)
+	identifier   = regexp.MustCompile(`^[A-Z][A-Za-z0-9_]*$`)
+)
+
+// docLines converts the TypeScript-flavoured markdown of the metaModel's
+// documentation to Go doc comment syntax, one line per element, blank
+// lines separating paragraphs and tab-indented lines being code. JSDoc tags
+// are moved into the trailing paragraphs gopls and go doc recognise:
+// @since and Since become "Since", @deprecated and Deprecated become
+// "Deprecated:", and @proposed and Proposed a note that the element may
+// still change.
+func docLines(info Info) []string {
+	since, deprecated, proposed := info.Since, info.Deprecated, info.Proposed
+	var lines []string
+	inCode, inList := false, false
+	text := strings.ReplaceAll(strings.TrimSpace(info.Documentation), "\r\n", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t")
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+			lines = appendBlank(lines)
+			continue
+		}
+		if inCode {
+			lines = append(lines, "\t"+line)
+			continue
+		}
+
+		tag, rest, _ := strings.Cut(trimmed, " ")
+		switch tag {
+		case "@since":
+			if since == "" {
+				since = strings.TrimSpace(rest)
+			}
+			continue
+		case "@deprecated":
+			if deprecated == "" {
+				deprecated = strings.TrimSpace(rest)
+			}
+			continue
+		case "@proposed":
+			proposed = true
+			continue
+		case "@sample", "@example":
+			trimmed = strings.TrimSpace(rest)
+		}
+
+		trimmed = inline(trimmed)
+		switch {
+		case trimmed == "":
+			lines = appendBlank(lines)
+			inList = false
+		case heading.MatchString(trimmed):
+			lines = appendBlank(lines)
+			lines = append(lines, "# "+heading.FindStringSubmatch(trimmed)[1], "")
+			inList = false
+		case listItem.MatchString(trimmed):
+			if !inList {
+				lines = appendBlank(lines)
+			}
+			marker, item, _ := strings.Cut(trimmed, " ")
+			if !strings.ContainsAny(marker[len(marker)-1:], ".)") {
+				marker = "-"
+			}
+			lines = append(lines, "  "+marker+" "+strings.TrimSpace(item))
+			inList = true
+		case inList && line != trimmed:
+			// An indented line continues the list item before it.
+			lines = append(lines, "    "+trimmed)
+		default:
+			if inList {
+				lines = appendBlank(lines)
+				inList = false
+			}
+			lines = append(lines, trimmed)
+		}
+	}
+
+	if proposed {
+		lines = appendParagraph(lines, "Proposed: this is not yet final in the specification and may change.")
+	}
+	if since != "" {
+		lines = appendParagraph(lines, "Since "+since+".")
+	}
+	if deprecated != "" {
+		lines = appendParagraph(lines, "Deprecated: "+inline(strings.Join(strings.Fields(deprecated), " ")))
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	return lines
+}
+
+// inline rewrites {@link} tags as Go doc links where they name a type,
+// judged by the capital letter types have,
+// markdown links as text followed by the URL, and drops code span quotes,
+// which Go doc comments don't use.
+func inline(s string) string {
+	s = linkTag.ReplaceAllStringFunc(s, func(tag string) string {
+		m := linkTag.FindStringSubmatch(tag)
+		target, text := m[1], strings.TrimSpace(m[2])
+		if text != "" {
+			return text
+		}
+		if identifier.MatchString(target) {
+			return "[" + target + "]"
+		}
+		return target
+	})
+	s = markdownLink.ReplaceAllString(s, "$1 ($2)")
+	return codeSpan.ReplaceAllString(s, "$1")
+}
+
+func appendBlank(lines []string) []string {
+	if len(lines) == 0 || lines[len(lines)-1] == "" {
+		return lines
+	}
+	return append(lines, "")
+}
+
+func appendParagraph(lines []string, paragraph string) []string {
+	return append(appendBlank(lines), paragraph)
+}
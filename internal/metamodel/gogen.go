@@ -0,0 +1,660 @@
+package metamodel
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// GoGenerator renders metaModel types as Go source.
+type GoGenerator struct {
+	Model   *Model
+	Package string
+	Header  string
+
+	// Skip names types which are written by hand elsewhere in the package.
+	Skip map[string]bool
+
+	// Overrides maps types and properties to Go types written by hand,
+	// which are used in their place and imported as needed.
+	Overrides *Overrides
+
+	// RPCPackage is the import path of the jsonrpc2 package Generate's
+	// dispatchers register with; DefaultRPCPackage when empty.
+	RPCPackage string
+
+	// Numbers chooses the Go types of the integer, uinteger and decimal
+	// base types, and how unions decode the numbers of LSPAny variants. It
+	// should match the jsonrpc2.Numbers of the connections the types are
+	// decoded from.
+	Numbers Numbers
+
+	// Accessors names structures, such as ClientCapabilities, to generate
+	// nil-safe views of alongside the structs their fields reach. A View
+	// method on each struct returns its view, whose getters return the
+	// fields, as views in turn for structs, or their zero value when the
+	// struct is absent, so caps.View().TextDocument().Hover().ContentFormat()
+	// needs no nil checks.
+	Accessors []string
+
+	// Previous, when set, is the metaModel of the release generated from
+	// before, whose names renamed since are kept compiling for a release
+	// cycle: types and constants as deprecated aliases of their new names,
+	// and view getters as deprecated getters calling theirs, in
+	// deprecated.go.
+	Previous *Model
+
+	// RoundTrips adds a test to GenerateFiles' package decoding the
+	// minimal and maximal example of each type declared strictly, so a
+	// property without a field fails it, and checking it encodes back to
+	// the same JSON, with the examples as golden files the test reads.
+	RoundTrips bool
+
+	shims    []shim
+	unions   []*union
+	literals []*literal
+	tuples   []*tuple
+	taken    map[string]bool
+	// fields are the fields of the structs written, by struct name.
+	fields       map[string][]field
+	wroteUnions  bool
+	wrotePartial bool
+	// files are the buffers of GenerateFiles' files, by name; nil when
+	// generating one.
+	files map[string]*bytes.Buffer
+	// fixtures are the golden payloads of the round-trip tests, by path.
+	fixtures map[string][]byte
+	// handled are the messages writeHandlers named handlers for.
+	handled []*message
+}
+
+// The files GenerateFiles splits a package into.
+const (
+	fileTypes   = "types.go"
+	fileEnums   = "enums.go"
+	fileUnions  = "unions.go"
+	fileMethods = "methods.go"
+	// fileExamples holds the Example functions, which only a package has.
+	fileExamples = "example_test.go"
+)
+
+// Numbers chooses the Go types of the numeric base types. Enumerations
+// keep the specification's.
+type Numbers string
+
+const (
+	// NumbersFloat64 keeps the specification's sizes, int32, uint32 and
+	// float64, and leaves LSPAny numbers float64.
+	NumbersFloat64 Numbers = ""
+	// NumbersInt64 widens integers to int64 and uint64, for peers sending
+	// IDs and offsets past the specification's range, and decodes LSPAny
+	// integers as int64.
+	NumbersInt64 Numbers = "int64"
+	// NumbersJSON holds every number as json.Number, as it was sent.
+	NumbersJSON Numbers = "json"
+)
+
+// ParseNumbers checks a Numbers named on a command line.
+func ParseNumbers(name string) (Numbers, error) {
+	switch n := Numbers(name); n {
+	case NumbersFloat64, NumbersInt64, NumbersJSON:
+		return n, nil
+	}
+	return "", fmt.Errorf("metamodel: unknown number handling %q, want int64 or json", name)
+}
+
+// decl is one named type of the model.
+type decl struct {
+	name      string
+	structure *Structure
+	enum      *Enumeration
+	alias     *TypeAlias
+}
+
+// builtinRefs are metaModel names mapped straight to Go types instead of
+// being declared; LSPAny and friends are recursive through each other, which
+// Go type aliases can't express.
+var builtinRefs = map[string]string{
+	"LSPAny":    "any",
+	"LSPObject": "map[string]any",
+	"LSPArray":  "[]any",
+}
+
+// Struct renders a single structure, without the types it depends on.
+func (g *GoGenerator) Struct(name string) ([]byte, error) {
+	s := g.Model.Structure(name)
+	if s == nil {
+		return nil, fmt.Errorf("no structure %q in the metaModel", name)
+	}
+	g.reset()
+	var buf bytes.Buffer
+	g.writeStruct(&buf, s, nil)
+	g.flushDeclared(&buf)
+	g.writeRuntime(&buf)
+	return g.source(&buf)
+}
+
+// Generate renders every structure, enumeration and type alias as one
+// package. Declarations are ordered dependencies first, and value fields
+// which would make a struct contain itself are made pointers. Unions and
+// literal types become structs named after the field holding them, such as
+// ServerCapabilitiesWorkspace, as do tuples unless their items share a type
+// and make a Go array.
+//
+// Each request and notification gets a method constant and a handler
+// interface, and RegisterServer and RegisterClient route messages to
+// whichever of those interfaces an implementation satisfies. Requests
+// which can stream partial results get a second interface, preferred over
+// the first, whose handlers take a PartialResultSender. Client has a typed
+// method for each message the server sends, over any Caller, and messages
+// with registration options get a constructor of their DynamicRegistration.
+func (g *GoGenerator) Generate() ([]byte, error) {
+	if err := g.prepareShims(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	g.generate(&buf)
+	return g.source(&buf)
+}
+
+// GenerateFiles renders what Generate does as the files of a package, by
+// name: the structures in types.go, enumerations in enums.go, unions in
+// unions.go, the messages' methods and handlers in methods.go and the views
+// of Accessors in accessors.go, the shims of Previous's renamed names in
+// deprecated.go, with an Example function for each handler taking
+// structure params in example_test.go and, with RoundTrips, the round-trip
+// tests in roundtrip_test.go and their payloads in testdata/roundtrip.
+// Files which would be empty are left out.
+func (g *GoGenerator) GenerateFiles() (map[string][]byte, error) {
+	if err := g.prepareShims(); err != nil {
+		return nil, err
+	}
+	var types bytes.Buffer
+	g.files = map[string]*bytes.Buffer{fileTypes: &types}
+	g.fixtures = nil
+	defer func() { g.files, g.fixtures = nil, nil }()
+	g.generate(&types)
+	if g.RoundTrips {
+		if err := g.writeRoundTrips(g.file(fileRoundTrips, &types)); err != nil {
+			return nil, err
+		}
+	}
+
+	out := map[string][]byte{}
+	for name, buf := range g.files {
+		if buf.Len() == 0 {
+			continue
+		}
+		src, err := g.source(buf)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		out[name] = src
+	}
+	for name, data := range g.fixtures {
+		out[name] = data
+	}
+	return out, nil
+}
+
+func (g *GoGenerator) generate(buf *bytes.Buffer) {
+	g.reset()
+	decls := g.decls()
+	pointers := g.cycleFields(decls)
+
+	for _, d := range g.order(decls) {
+		switch {
+		case d.structure != nil:
+			g.writeStruct(buf, d.structure, pointers[d.name])
+		case d.enum != nil:
+			g.writeEnum(g.file(fileEnums, buf), d.enum)
+		case d.alias != nil:
+			if inner, _ := stripNull(d.alias.Type); inner.Kind == KindOr {
+				writeDoc(g.file(fileUnions, buf), "", d.alias.Info)
+				g.declareUnion(d.name, inner)
+			} else {
+				writeDoc(buf, "", d.alias.Info)
+				fmt.Fprintf(buf, "type %s = %s\n\n", d.name, g.typeOf(d.alias.Type, d.name))
+			}
+		}
+		g.flushDeclared(buf)
+	}
+	g.writeHandlers(g.file(fileMethods, buf))
+	g.writeAccessors(g.file(fileAccessors, buf))
+	if len(g.shims) > 0 {
+		g.writeShims(g.file(fileShims, buf))
+	}
+	g.writeRuntime(buf)
+}
+
+// file is the buffer of the named file when GenerateFiles is splitting
+// the package, and otherwise buf.
+func (g *GoGenerator) file(name string, buf *bytes.Buffer) *bytes.Buffer {
+	if g.files == nil {
+		return buf
+	}
+	if g.files[name] == nil {
+		g.files[name] = &bytes.Buffer{}
+	}
+	return g.files[name]
+}
+
+// reset reserves the model's own names before unions are named.
+func (g *GoGenerator) reset() {
+	g.unions, g.literals, g.tuples = nil, nil, nil
+	g.wroteUnions, g.wrotePartial = false, false
+	g.taken = map[string]bool{}
+	g.fields = map[string][]field{}
+	for name := range builtinRefs {
+		g.taken[name] = true
+	}
+	for _, s := range g.Model.Structures {
+		g.taken[s.Name] = true
+	}
+	for _, e := range g.Model.Enumerations {
+		g.taken[e.Name] = true
+	}
+	for _, a := range g.Model.TypeAliases {
+		g.taken[a.Name] = true
+	}
+}
+
+// flushDeclared writes the unions, literal and tuple structs declared so
+// far, including any their fields declare in turn. Unions go first, so an
+// alias's doc comment written just before stays on its union.
+func (g *GoGenerator) flushDeclared(buf *bytes.Buffer) {
+	for len(g.unions) > 0 || len(g.literals) > 0 || len(g.tuples) > 0 {
+		if len(g.unions) > 0 {
+			u := g.unions[0]
+			g.unions = g.unions[1:]
+			g.writeUnion(g.file(fileUnions, buf), u)
+			g.wroteUnions = true
+			continue
+		}
+		if len(g.literals) > 0 {
+			l := g.literals[0]
+			g.literals = g.literals[1:]
+			g.writeLiteral(g.file(fileTypes, buf), l)
+			continue
+		}
+		tp := g.tuples[0]
+		g.tuples = g.tuples[1:]
+		g.writeTuple(g.file(fileTypes, buf), tp)
+	}
+}
+
+// writeRuntime appends the helpers union decoding and partial results
+// call, if they were used.
+func (g *GoGenerator) writeRuntime(buf *bytes.Buffer) {
+	if g.wroteUnions {
+		g.file(fileUnions, buf).WriteString(g.unionRuntime())
+	}
+	if g.wrotePartial {
+		rpc := g.rpcPackage()
+		g.file(fileMethods, buf).WriteString(strings.ReplaceAll(partialRuntime, "jsonrpc2.", rpc[strings.LastIndex(rpc, "/")+1:]+"."))
+	}
+}
+
+// source prefixes the declarations in body with the header and package
+// clause and formats the result.
+func (g *GoGenerator) source(body *bytes.Buffer) ([]byte, error) {
+	var buf bytes.Buffer
+	if g.Header != "" {
+		fmt.Fprintf(&buf, "// %s\n\n", g.Header)
+	}
+	fmt.Fprintf(&buf, "package %s\n\n", g.Package)
+	imports, err := g.usedImports(body.Bytes())
+	if err != nil {
+		return body.Bytes(), fmt.Errorf("parsing generated code: %w", err)
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(&buf, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	}
+	buf.Write(body.Bytes())
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return buf.Bytes(), fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// usedImports lists, quoted, the packages the declarations in body refer to.
+func (g *GoGenerator) usedImports(body []byte) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package "+g.Package+"\n"+string(body), 0)
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	// A package an override imports wins over one of the same name the
+	// generator would, as a uri package of the user's own over URIPackage.
+	byName := map[string]string{}
+	var names []string
+	for _, path := range append([]string{"bytes", "context", "encoding/json", "fmt", "os", "path/filepath", "reflect", "testing", URIPackage, g.rpcPackage()}, g.overrideImports()...) {
+		name := path[strings.LastIndex(path, "/")+1:]
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = path
+	}
+	var imports []string
+	for _, name := range names {
+		if used[name] {
+			imports = append(imports, fmt.Sprintf("%q", byName[name]))
+		}
+	}
+	return imports, nil
+}
+
+func (g *GoGenerator) decls() map[string]*decl {
+	decls := map[string]*decl{}
+	add := func(d *decl) {
+		if _, overridden := g.overrideType(d.name); !overridden && !g.Skip[d.name] && builtinRefs[d.name] == "" {
+			decls[d.name] = d
+		}
+	}
+	for _, s := range g.Model.Structures {
+		add(&decl{name: s.Name, structure: s})
+	}
+	for _, e := range g.Model.Enumerations {
+		add(&decl{name: e.Name, enum: e})
+	}
+	for _, a := range g.Model.TypeAliases {
+		add(&decl{name: a.Name, alias: a})
+	}
+	return decls
+}
+
+// order sorts declarations so each follows the types it refers to, breaking
+// ties and cycles by name.
+func (g *GoGenerator) order(decls map[string]*decl) []*decl {
+	names := make([]string, 0, len(decls))
+	for name := range decls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out []*decl
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		d, ok := decls[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, dep := range d.refs() {
+			visit(dep)
+		}
+		out = append(out, d)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return out
+}
+
+// refs lists the names a declaration refers to, sorted.
+func (d *decl) refs() []string {
+	set := map[string]bool{}
+	switch {
+	case d.structure != nil:
+		for _, t := range append(append([]*Type(nil), d.structure.Extends...), d.structure.Mixins...) {
+			collectRefs(t, set)
+		}
+		for _, p := range d.structure.Properties {
+			collectRefs(p.Type, set)
+		}
+	case d.enum != nil:
+		collectRefs(d.enum.Type, set)
+	case d.alias != nil:
+		collectRefs(d.alias.Type, set)
+	}
+	refs := make([]string, 0, len(set))
+	for name := range set {
+		refs = append(refs, name)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func collectRefs(t *Type, set map[string]bool) {
+	if t == nil {
+		return
+	}
+	if t.Kind == KindReference {
+		set[t.Name] = true
+	}
+	collectRefs(t.Element, set)
+	collectRefs(t.Key, set)
+	collectRefs(t.Value, set)
+	for _, item := range t.Items {
+		collectRefs(item, set)
+	}
+	if t.Literal != nil {
+		for _, p := range t.Literal.Properties {
+			collectRefs(p.Type, set)
+		}
+	}
+}
+
+// cycleFields finds the struct fields to make pointers so no struct contains
+// itself by value, returning them by struct name.
+func (g *GoGenerator) cycleFields(decls map[string]*decl) map[string]map[string]bool {
+	// valueRef resolves a field type to the struct it embeds by value, if any.
+	valueRef := func(t *Type, optional bool) string {
+		goType, pointer := fieldType(t, optional, g.goType)
+		if pointer {
+			return ""
+		}
+		for {
+			d, ok := decls[goType]
+			if !ok {
+				return ""
+			}
+			if d.structure != nil {
+				return d.name
+			}
+			if d.alias == nil || d.alias.Type.Kind != KindReference {
+				return ""
+			}
+			goType = d.alias.Type.Name
+		}
+	}
+
+	pointers := map[string]map[string]bool{}
+	state := map[string]int{} // 0 unvisited, 1 in progress, 2 done
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = 1
+		for _, p := range g.Model.Properties(decls[name].structure) {
+			if _, overridden := g.overrideProperty(name, p.Name); overridden {
+				continue
+			}
+			target := valueRef(p.Type, p.Optional)
+			if target == "" {
+				continue
+			}
+			switch state[target] {
+			case 1:
+				if pointers[name] == nil {
+					pointers[name] = map[string]bool{}
+				}
+				pointers[name][p.Name] = true
+			case 0:
+				visit(target)
+			}
+		}
+		state[name] = 2
+	}
+	names := make([]string, 0, len(decls))
+	for name, d := range decls {
+		if d.structure != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if state[name] == 0 {
+			visit(name)
+		}
+	}
+	return pointers
+}
+
+func (g *GoGenerator) writeStruct(buf *bytes.Buffer, s *Structure, pointers map[string]bool) {
+	writeDoc(buf, "", s.Info)
+	fmt.Fprintf(buf, "type %s struct {\n", s.Name)
+	g.writeFields(buf, s.Name, g.Model.Properties(s), pointers)
+	buf.WriteString("}\n\n")
+}
+
+// writeFields writes the fields of the struct name, naming the types its
+// fields declare after them.
+func (g *GoGenerator) writeFields(buf *bytes.Buffer, name string, props []*Property, pointers map[string]bool) {
+	for _, prop := range props {
+		writeDoc(buf, "\t", prop.Info)
+		goType, pointer := fieldType(prop.Type, prop.Optional, func(t *Type) string {
+			if goType, ok := g.overrideProperty(name, prop.Name); ok {
+				return goType
+			}
+			return g.typeOf(t, name+GoName(prop.Name))
+		})
+		if pointer || pointers[prop.Name] && !nilable(goType) {
+			goType = "*" + goType
+		}
+		omit := ""
+		if prop.Optional {
+			omit = ",omitempty"
+		}
+		fmt.Fprintf(buf, "\t%s %s `json:\"%s%s\"`\n", GoName(prop.Name), goType, prop.Name, omit)
+		g.fields[name] = append(g.fields[name], field{GoName(prop.Name), goType, prop.Info})
+	}
+}
+
+// fieldType maps a property's type with mapType, reporting whether it must
+// be a pointer to represent absence: optional or nullable properties whose
+// Go type has no nil value.
+func fieldType(t *Type, optional bool, mapType func(*Type) string) (goType string, pointer bool) {
+	t, nullable := stripNull(t)
+	goType = mapType(t)
+	return goType, (optional || nullable) && !nilable(goType)
+}
+
+func nilable(goType string) bool {
+	return strings.HasPrefix(goType, "[]") || strings.HasPrefix(goType, "map[") ||
+		strings.HasPrefix(goType, "*") || goType == "json.RawMessage" || goType == "any"
+}
+
+// URIPackage is the package of the Go types GoType maps the URI and
+// DocumentUri base types to, whose methods convert them to file paths and
+// compare them.
+const URIPackage = "github.com/pentops/lsplib/uri"
+
+// GoType maps a type expression to a Go type.
+func GoType(t *Type) string {
+	if t == nil {
+		return "any"
+	}
+	switch t.Kind {
+	case KindBase:
+		switch t.Name {
+		case "string", "RegExp":
+			return "string"
+		case "URI":
+			return "uri.URI"
+		case "DocumentUri":
+			return "uri.DocumentURI"
+		case "integer":
+			return "int32"
+		case "uinteger":
+			return "uint32"
+		case "decimal":
+			return "float64"
+		case "boolean":
+			return "bool"
+		case "null":
+			return "any"
+		}
+	case KindReference:
+		if builtin, ok := builtinRefs[t.Name]; ok {
+			return builtin
+		}
+		return t.Name
+	case KindArray:
+		return "[]" + GoType(t.Element)
+	case KindMap:
+		return "map[" + GoType(t.Key) + "]" + GoType(t.Value)
+	}
+	return "json.RawMessage"
+}
+
+// goType is GoType with the generator's overrides applied.
+func (g *GoGenerator) goType(t *Type) string {
+	if t == nil {
+		return GoType(t)
+	}
+	switch t.Kind {
+	case KindBase, KindReference:
+		if goType, ok := g.overrideType(t.Name); ok {
+			return goType
+		}
+		if goType, ok := g.numberType(t); ok {
+			return goType
+		}
+	case KindArray:
+		return "[]" + g.goType(t.Element)
+	case KindMap:
+		return "map[" + g.goType(t.Key) + "]" + g.goType(t.Value)
+	}
+	return GoType(t)
+}
+
+// numberType is the Go type of a numeric base type other than as GoType
+// maps it.
+func (g *GoGenerator) numberType(t *Type) (string, bool) {
+	if t.Kind != KindBase {
+		return "", false
+	}
+	switch g.Numbers {
+	case NumbersInt64:
+		switch t.Name {
+		case "integer":
+			return "int64", true
+		case "uinteger":
+			return "uint64", true
+		}
+	case NumbersJSON:
+		switch t.Name {
+		case "integer", "uinteger", "decimal":
+			return "json.Number", true
+		}
+	}
+	return "", false
+}
+
+// GoName exports a protocol property name.
+func GoName(name string) string {
+	if name == "" {
+		return name
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+	for _, initialism := range []string{"Id", "Uri", "Url", "Json"} {
+		if strings.HasSuffix(name, initialism) {
+			name = name[:len(name)-len(initialism)] + strings.ToUpper(initialism)
+		}
+	}
+	return name
+}
@@ -0,0 +1,433 @@
+package metamodel
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultRPCPackage is the jsonrpc2 package generated dispatchers register
+// handlers with.
+const DefaultRPCPackage = "github.com/pentops/lsplib/jsonrpc2"
+
+// message is a request or notification, as the handler generator sees it.
+type message struct {
+	Info
+	method    string
+	base      string
+	params    Params
+	result    *Type
+	partial   *Type
+	errorData *Type
+	direction Direction
+	request   bool
+
+	// regMethod and regOptions are what registers m at runtime: the method
+	// to register, when not m's own, and its options. capability is the
+	// client capability declaring support for m, and serverCapability the
+	// server's advertising it.
+	regMethod        string
+	regOptions       *Type
+	capability       string
+	serverCapability string
+
+	// constant, iface and goMethod are the names of the method constant,
+	// the handler interface and its method; paramType and resultType their
+	// Go types, "" for none. partialIface and partialType are set for
+	// requests with partial results, and errorType for those whose error
+	// replies carry data.
+	constant, iface, goMethod string
+	paramType, resultType     string
+	partialIface, partialType string
+	errorType                 string
+}
+
+// messages lists the model's requests and notifications, sorted by method.
+func (g *GoGenerator) messages() []*message {
+	var out []*message
+	for _, r := range g.Model.Requests {
+		out = append(out, &message{
+			Info: r.Info, method: r.Method, base: messageBase(r.TypeName, "Request", r.Method),
+			params: r.Params, result: r.Result, partial: r.PartialResult, errorData: r.ErrorData, direction: r.MessageDirection, request: true,
+			regMethod: r.RegistrationMethod, regOptions: r.RegistrationOptions, capability: r.ClientCapability, serverCapability: r.ServerCapability,
+		})
+	}
+	for _, n := range g.Model.Notifications {
+		out = append(out, &message{
+			Info: n.Info, method: n.Method, base: messageBase(n.TypeName, "Notification", n.Method),
+			params: n.Params, direction: n.MessageDirection,
+			regMethod: n.RegistrationMethod, regOptions: n.RegistrationOptions, capability: n.ClientCapability, serverCapability: n.ServerCapability,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].method < out[j].method })
+	return out
+}
+
+// messageBase names a message for Go: its metaModel type name without the
+// Request or Notification suffix, or else its method.
+func messageBase(typeName, suffix, method string) string {
+	if base := strings.TrimSuffix(typeName, suffix); base != "" {
+		return base
+	}
+	return exported(method)
+}
+
+// writeHandlers writes the method constants, a handler interface per
+// message and the dispatchers which register implementations of them.
+func (g *GoGenerator) writeHandlers(buf *bytes.Buffer) {
+	msgs := g.messages()
+	g.handled = msgs
+	if len(msgs) == 0 {
+		return
+	}
+	buf.WriteString("// Method names.\nconst (\n")
+	for _, m := range msgs {
+		m.constant = g.claim("Method" + m.base)
+		fmt.Fprintf(buf, "\t%s = %q\n", m.constant, m.method)
+	}
+	buf.WriteString(")\n\n")
+
+	for _, m := range msgs {
+		m.iface = g.claim(m.base + "Handler")
+		m.goMethod = m.base
+		m.paramType = g.handlerType(m.params, m.base+"Params")
+		if m.request {
+			m.resultType = g.handlerType(Params{m.result}, m.base+"Result")
+		}
+		if m.errorData != nil {
+			t, _ := stripNull(m.errorData)
+			m.errorType = g.typeOf(t, m.base+"ErrorData")
+		}
+		g.writeHandler(buf, m)
+		if m.partial != nil && m.paramType != "" {
+			m.partialIface = g.claim(m.base + "PartialHandler")
+			m.partialType = g.handlerType(Params{m.partial}, m.base+"PartialResult")
+			g.writePartialHandler(buf, m)
+			g.wrotePartial = true
+		}
+		g.flushDeclared(buf)
+	}
+
+	rpc := g.rpcPackage()
+	rpcName := rpc[strings.LastIndex(rpc, "/")+1:]
+	for _, side := range []struct {
+		name, doc string
+		from      Direction
+	}{
+		{"RegisterServer", "sent by the client", ClientToServer},
+		{"RegisterClient", "sent by the server", ServerToClient},
+	} {
+		fmt.Fprintf(buf, "// %s registers on mux each handler impl implements for messages\n// %s. It returns the methods registered.\n", side.name, side.doc)
+		fmt.Fprintf(buf, "func %s(mux *%s.Mux, impl any) []string {\n\tvar methods []string\n", side.name, rpcName)
+		for _, m := range msgs {
+			if m.direction != side.from && m.direction != Both {
+				continue
+			}
+			if m.partialIface != "" {
+				fmt.Fprintf(buf, "\tif h, ok := impl.(%s); ok {\n", m.partialIface)
+				g.writePartialRegistration(buf, rpcName, m)
+				buf.WriteString("\t\tmethods = append(methods, " + m.constant + ")\n\t} else ")
+			} else {
+				buf.WriteString("\t")
+			}
+			fmt.Fprintf(buf, "if h, ok := impl.(%s); ok {\n", m.iface)
+			g.writeRegistration(buf, rpcName, m)
+			fmt.Fprintf(buf, "\t\tmethods = append(methods, %s)\n\t}\n", m.constant)
+		}
+		buf.WriteString("\treturn methods\n}\n\n")
+	}
+	g.writeClient(buf, msgs)
+	g.writeRegistrations(buf, msgs)
+	g.writeMethodTable(buf, msgs)
+	if g.files != nil {
+		g.writeExamples(g.file(fileExamples, buf), msgs)
+	}
+}
+
+// writeRegistrations writes a constructor for the dynamic registration of
+// each message which has registration options, and the table of the client
+// capabilities declaring dynamic registration support.
+func (g *GoGenerator) writeRegistrations(buf *bytes.Buffer, msgs []*message) {
+	var registered []*message
+	for _, m := range msgs {
+		if m.regOptions != nil {
+			registered = append(registered, m)
+		}
+	}
+	if len(registered) == 0 {
+		return
+	}
+	reg := g.claim("DynamicRegistration")
+	fmt.Fprintf(buf, "// %s is a feature to register with the client\n// at runtime: the method and its registration options. It converts to\n// lsp.Dynamic.\n", reg)
+	fmt.Fprintf(buf, "type %s struct {\n\tMethod          string\n\tRegisterOptions any\n}\n\n", reg)
+	seen := map[string]bool{}
+	var capabilities [][2]string
+	for _, m := range registered {
+		// Some features register under a method of their own, as the
+		// semantic tokens requests share textDocument/semanticTokens.
+		regMethod, method := m.method, m.constant
+		if m.regMethod != "" && m.regMethod != m.method {
+			regMethod, method = m.regMethod, strconv.Quote(m.regMethod)
+		}
+		name := g.claim("New" + m.base + "Registration")
+		optType := g.handlerType(Params{m.regOptions}, m.base+"RegistrationOptions")
+		fmt.Fprintf(buf, "// %s registers %s with options.\n", name, regMethod)
+		fmt.Fprintf(buf, "func %s(options %s) %s {\n\treturn %s{Method: %s, RegisterOptions: options}\n}\n\n", name, optType, reg, reg, method)
+		g.flushDeclared(buf)
+		if m.capability != "" && !seen[regMethod] {
+			seen[regMethod] = true
+			capabilities = append(capabilities, [2]string{method, m.capability})
+		}
+	}
+	if len(capabilities) == 0 {
+		return
+	}
+	table := g.claim("DynamicRegistrationCapabilities")
+	fmt.Fprintf(buf, "// %s are the client capabilities whose\n// dynamicRegistration flag declares that the client can register each\n// method at runtime.\n", table)
+	fmt.Fprintf(buf, "var %s = map[string]string{\n", table)
+	for _, c := range capabilities {
+		fmt.Fprintf(buf, "\t%s: %q,\n", c[0], c[1])
+	}
+	buf.WriteString("}\n\n")
+}
+
+// writeMethodTable writes the Methods table, describing each message at
+// runtime for tools which handle messages without knowing them, such as
+// proxies and trace printers.
+func (g *GoGenerator) writeMethodTable(buf *bytes.Buffer, msgs []*message) {
+	info, table := g.claim("MethodInfo"), g.claim("Methods")
+	fmt.Fprintf(buf, "// %s describes a message of the protocol.\ntype %s struct {\n", info, info)
+	buf.WriteString("\t// Direction is the side sending it: \"clientToServer\", \"serverToClient\"\n\t// or \"both\".\n\tDirection string\n")
+	buf.WriteString("\t// Request is set for requests, and unset for notifications.\n\tRequest bool\n")
+	buf.WriteString("\t// Params and Result are the Go types of its params and result, as\n\t// handlers take and return them, and PartialResult that of the parts\n\t// of a result streamed; nil when there are none.\n")
+	buf.WriteString("\tParams, Result, PartialResult reflect.Type\n")
+	buf.WriteString("\t// RegistrationMethod is the method it is registered under at runtime,\n\t// \"\" when it can't be.\n\tRegistrationMethod string\n}\n\n")
+	fmt.Fprintf(buf, "// %s describes each message of the protocol, by method.\nvar %s = map[string]%s{\n", table, table, info)
+	typeOf := func(goType string) string {
+		if goType == "" {
+			return ""
+		}
+		return fmt.Sprintf("reflect.TypeFor[%s]()", goType)
+	}
+	for _, m := range msgs {
+		fields := []string{fmt.Sprintf("Direction: %q", m.direction)}
+		if m.request {
+			fields = append(fields, "Request: true")
+		}
+		for _, f := range [][2]string{{"Params", typeOf(m.paramType)}, {"Result", typeOf(m.resultType)}, {"PartialResult", typeOf(m.partialType)}} {
+			if f[1] != "" {
+				fields = append(fields, f[0]+": "+f[1])
+			}
+		}
+		switch {
+		case m.regOptions == nil:
+		case m.regMethod != "" && m.regMethod != m.method:
+			fields = append(fields, fmt.Sprintf("RegistrationMethod: %q", m.regMethod))
+		default:
+			fields = append(fields, "RegistrationMethod: "+m.constant)
+		}
+		fmt.Fprintf(buf, "\t%s: {%s},\n", m.constant, strings.Join(fields, ", "))
+	}
+	buf.WriteString("}\n\n")
+}
+
+// writeClient writes the Client interface, with a typed method for each
+// message the server sends, and its implementation over a connection.
+func (g *GoGenerator) writeClient(buf *bytes.Buffer, msgs []*message) {
+	var sent []*message
+	for _, m := range msgs {
+		if m.direction == ServerToClient || m.direction == Both {
+			sent = append(sent, m)
+		}
+	}
+	if len(sent) == 0 {
+		return
+	}
+	caller, client, newClient, impl := g.claim("Caller"), g.claim("Client"), g.claim("NewClient"), g.claim("clientConn")
+	fmt.Fprintf(buf, "// %s sends requests and notifications to the peer, as a JSON-RPC\n// connection or a language server does.\n", caller)
+	fmt.Fprintf(buf, "type %s interface {\n\tCall(ctx context.Context, method string, params, result any) error\n\tNotify(ctx context.Context, method string, params any) error\n}\n\n", caller)
+	fmt.Fprintf(buf, "// %s sends the messages a server sends the client, each through a typed\n// method.\ntype %s interface {\n", client, client)
+	for _, m := range sent {
+		fmt.Fprintf(buf, "\t// %s sends %s.\n\t%s(%s) ", m.goMethod, m.method, m.goMethod, m.signature())
+		if m.resultType != "" {
+			fmt.Fprintf(buf, "(%s, error)\n", m.resultType)
+		} else {
+			buf.WriteString("error\n")
+		}
+	}
+	buf.WriteString("}\n\n")
+	fmt.Fprintf(buf, "// %s returns a %s sending through conn.\nfunc %s(conn %s) %s {\n\treturn %s{conn}\n}\n\n", newClient, client, newClient, caller, client, impl)
+	fmt.Fprintf(buf, "type %s struct {\n\tconn %s\n}\n\n", impl, caller)
+	for _, m := range sent {
+		params := "params"
+		if m.paramType == "" {
+			params = "nil"
+		}
+		if m.resultType != "" {
+			fmt.Fprintf(buf, "func (c %s) %s(%s) (%s, error) {\n", impl, m.goMethod, m.signature(), m.resultType)
+			fmt.Fprintf(buf, "\tvar result %s\n\terr := c.conn.Call(ctx, %s, %s, &result)\n\treturn result, err\n}\n\n", m.resultType, m.constant, params)
+			continue
+		}
+		fmt.Fprintf(buf, "func (c %s) %s(%s) error {\n", impl, m.goMethod, m.signature())
+		if m.request {
+			fmt.Fprintf(buf, "\treturn c.conn.Call(ctx, %s, %s, nil)\n}\n\n", m.constant, params)
+		} else {
+			fmt.Fprintf(buf, "\treturn c.conn.Notify(ctx, %s, %s)\n}\n\n", m.constant, params)
+		}
+	}
+}
+
+// handlerType maps a message's params or result to the Go type its handler
+// takes or returns: a pointer unless the type is already nilable, and ""
+// when there is none.
+func (g *GoGenerator) handlerType(params Params, name string) string {
+	switch {
+	case len(params) == 0 || params[0] == nil:
+		return ""
+	case len(params) > 1:
+		return "json.RawMessage"
+	}
+	t, _ := stripNull(params[0])
+	if t.Kind == KindBase && t.Name == "null" {
+		return ""
+	}
+	goType, pointer := fieldType(t, true, func(t *Type) string { return g.typeOf(t, name) })
+	if pointer {
+		return "*" + goType
+	}
+	return goType
+}
+
+func (g *GoGenerator) writeHandler(buf *bytes.Buffer, m *message) {
+	kind := "notification"
+	if m.request {
+		kind = "request"
+	}
+	fmt.Fprintf(buf, "// %s handles the %s %s.\n", m.iface, m.method, kind)
+	var doc bytes.Buffer
+	if writeDoc(&doc, "", m.Info) {
+		buf.WriteString("//\n")
+		buf.Write(doc.Bytes())
+	}
+	if m.errorType != "" {
+		fmt.Fprintf(buf, "//\n// Its error replies carry data of type %s: return an\n// lsperror.ResponseError[%s].\n", m.errorType, m.errorType)
+	}
+	fmt.Fprintf(buf, "type %s interface {\n\t%s(%s) ", m.iface, m.goMethod, m.signature())
+	if m.resultType != "" {
+		fmt.Fprintf(buf, "(%s, error)", m.resultType)
+	} else {
+		buf.WriteString("error")
+	}
+	buf.WriteString("\n}\n\n")
+}
+
+// writePartialHandler writes the interface of handlers for m which stream
+// partial results.
+func (g *GoGenerator) writePartialHandler(buf *bytes.Buffer, m *message) {
+	fmt.Fprintf(buf, "// %s handles the %s request, streaming parts of its result\n", m.partialIface, m.method)
+	buf.WriteString("// through partial when the client asks for them.\n")
+	fmt.Fprintf(buf, "type %s interface {\n\t%sPartial(%s, partial *PartialResultSender[%s]) ", m.partialIface, m.goMethod, m.signature(), m.partialType)
+	if m.resultType != "" {
+		fmt.Fprintf(buf, "(%s, error)", m.resultType)
+	} else {
+		buf.WriteString("error")
+	}
+	buf.WriteString("\n}\n\n")
+}
+
+// writePartialRegistration writes the mux registration of a partial
+// handler h for m, which decodes the params itself to find the token.
+func (g *GoGenerator) writePartialRegistration(buf *bytes.Buffer, rpcName string, m *message) {
+	fmt.Fprintf(buf, "\t\tmux.RegisterFunc(%s, func(ctx context.Context, req *%s.Request) (any, error) {\n", m.constant, rpcName)
+	fmt.Fprintf(buf, "\t\t\tvar params %s\n", m.paramType)
+	buf.WriteString("\t\t\tif err := req.UnmarshalParams(&params); err != nil {\n\t\t\t\treturn nil, err\n\t\t\t}\n")
+	call := fmt.Sprintf("h.%sPartial(ctx, params, newPartialResultSender[%s](req))", m.goMethod, m.partialType)
+	if m.resultType != "" {
+		fmt.Fprintf(buf, "\t\t\treturn %s\n", call)
+	} else {
+		fmt.Fprintf(buf, "\t\t\treturn nil, %s\n", call)
+	}
+	buf.WriteString("\t\t})\n")
+}
+
+func (m *message) signature() string {
+	if m.paramType == "" {
+		return "ctx context.Context"
+	}
+	return "ctx context.Context, params " + m.paramType
+}
+
+// writeRegistration writes the mux registration of h for m.
+func (g *GoGenerator) writeRegistration(buf *bytes.Buffer, rpcName string, m *message) {
+	switch {
+	case m.paramType == "":
+		fmt.Fprintf(buf, "\t\tmux.RegisterFunc(%s, func(ctx context.Context, _ *%s.Request) (any, error) {\n", m.constant, rpcName)
+		if m.resultType != "" {
+			fmt.Fprintf(buf, "\t\t\treturn h.%s(ctx)\n", m.goMethod)
+		} else {
+			fmt.Fprintf(buf, "\t\t\treturn nil, h.%s(ctx)\n", m.goMethod)
+		}
+		buf.WriteString("\t\t})\n")
+	case !m.request:
+		fmt.Fprintf(buf, "\t\t%s.RegisterNotification(mux, %s, h.%s)\n", rpcName, m.constant, m.goMethod)
+	case m.resultType != "":
+		fmt.Fprintf(buf, "\t\t%s.RegisterMethod(mux, %s, h.%s)\n", rpcName, m.constant, m.goMethod)
+	default:
+		fmt.Fprintf(buf, "\t\t%s.RegisterMethod(mux, %s, func(ctx context.Context, params %s) (any, error) {\n", rpcName, m.constant, m.paramType)
+		fmt.Fprintf(buf, "\t\t\treturn nil, h.%s(ctx, params)\n\t\t})\n", m.goMethod)
+	}
+}
+
+func (g *GoGenerator) rpcPackage() string {
+	if g.RPCPackage != "" {
+		return g.RPCPackage
+	}
+	return DefaultRPCPackage
+}
+
+// partialRuntime is PartialResultSender, written with the jsonrpc2 package
+// qualifier the generator's RPCPackage replaces.
+const partialRuntime = `// PartialResultSender streams parts of a request's result to the client,
+// as $/progress notifications on the partialResultToken of its params.
+type PartialResultSender[T any] struct {
+	token json.RawMessage
+}
+
+func newPartialResultSender[T any](req *jsonrpc2.Request) *PartialResultSender[T] {
+	var params struct {
+		PartialResultToken json.RawMessage ` + "`json:\"partialResultToken\"`" + `
+	}
+	_ = json.Unmarshal(req.Params, &params)
+	if string(params.PartialResultToken) == "null" {
+		params.PartialResultToken = nil
+	}
+	return &PartialResultSender[T]{token: params.PartialResultToken}
+}
+
+// Enabled reports whether the client asked for partial results. Without a
+// token the handler returns its whole result instead.
+func (s *PartialResultSender[T]) Enabled() bool {
+	return s != nil && s.token != nil
+}
+
+// Send sends part of the result, reporting whether it did: false when the
+// client asked for no partial results, in which case the part belongs in
+// the returned result. Once parts are sent the final result should be
+// empty.
+func (s *PartialResultSender[T]) Send(ctx context.Context, part T) (bool, error) {
+	if !s.Enabled() {
+		return false, nil
+	}
+	conn := jsonrpc2.ConnFrom(ctx)
+	if conn == nil {
+		return false, jsonrpc2.ErrClosed
+	}
+	params := struct {
+		Token json.RawMessage ` + "`json:\"token\"`" + `
+		Value T               ` + "`json:\"value\"`" + `
+	}{s.token, part}
+	return true, conn.Notify(ctx, "$/progress", &params)
+}
+`
@@ -0,0 +1,34 @@
+package metamodel
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// literal is a Go struct generated for a literal type, an object declared
+// inline, such as ServerCapabilities.workspace.
+type literal struct {
+	name  string
+	info  Info
+	props []*Property
+}
+
+// declareLiteral declares the struct for t and returns its name.
+func (g *GoGenerator) declareLiteral(name string, t *Type) string {
+	l := &literal{name: name}
+	if t.Literal != nil {
+		l.info = t.Literal.Info
+		l.props = t.Literal.Properties
+	}
+	g.literals = append(g.literals, l)
+	return name
+}
+
+func (g *GoGenerator) writeLiteral(buf *bytes.Buffer, l *literal) {
+	if !writeDoc(buf, "", l.info) {
+		fmt.Fprintf(buf, "// %s is an object the metaModel declares inline.\n", l.name)
+	}
+	fmt.Fprintf(buf, "type %s struct {\n", l.name)
+	g.writeFields(buf, l.name, l.props, nil)
+	buf.WriteString("}\n\n")
+}
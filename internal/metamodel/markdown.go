@@ -0,0 +1,220 @@
+package metamodel
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteMarkdown renders the model as a single markdown reference: messages
+// first, linked to their params, results and capabilities, then every type.
+// Elements are sorted by name so regenerating for a new protocol version
+// gives a readable diff.
+func WriteMarkdown(w io.Writer, m *Model) error {
+	md := &markdown{w: bufio.NewWriter(w), model: m}
+	md.render()
+	return md.w.Flush()
+}
+
+type markdown struct {
+	w     *bufio.Writer
+	model *Model
+}
+
+func (md *markdown) printf(format string, args ...any) {
+	fmt.Fprintf(md.w, format, args...)
+}
+
+func (md *markdown) render() {
+	md.printf("# Language Server Protocol %s\n\n", md.model.MetaData.Version)
+	md.printf("Generated by lspschema from the protocol metaModel. Do not edit.\n\n")
+	md.printf("- [Requests](#requests)\n- [Notifications](#notifications)\n")
+	md.printf("- [Structures](#structures)\n- [Enumerations](#enumerations)\n- [Type aliases](#type-aliases)\n\n")
+
+	requests := append([]*Request(nil), md.model.Requests...)
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Method < requests[j].Method })
+	md.printf("## Requests\n\n")
+	for _, req := range requests {
+		md.message(req.Method, req.Info, req.MessageDirection, req.Params, req.RegistrationOptions, req.ClientCapability, req.ServerCapability)
+		md.printf("- Result: %s\n", md.typeRef(req.Result))
+		if req.PartialResult != nil {
+			md.printf("- Partial result: %s\n", md.typeRef(req.PartialResult))
+		}
+		if req.ErrorData != nil {
+			md.printf("- Error data: %s\n", md.typeRef(req.ErrorData))
+		}
+		md.printf("\n%s", md.docs(req.Info))
+	}
+
+	notifications := append([]*Notification(nil), md.model.Notifications...)
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].Method < notifications[j].Method })
+	md.printf("## Notifications\n\n")
+	for _, note := range notifications {
+		md.message(note.Method, note.Info, note.MessageDirection, note.Params, note.RegistrationOptions, note.ClientCapability, note.ServerCapability)
+		md.printf("\n%s", md.docs(note.Info))
+	}
+
+	structures := append([]*Structure(nil), md.model.Structures...)
+	sort.Slice(structures, func(i, j int) bool { return structures[i].Name < structures[j].Name })
+	md.printf("## Structures\n\n")
+	for _, s := range structures {
+		md.heading(s.Name, s.Info)
+		md.printf("%s", md.docs(s.Info))
+		if len(s.Extends) > 0 {
+			md.printf("Extends %s.\n\n", md.typeList(s.Extends))
+		}
+		if len(s.Mixins) > 0 {
+			md.printf("Mixes in %s.\n\n", md.typeList(s.Mixins))
+		}
+		md.properties(s.Properties)
+	}
+
+	enums := append([]*Enumeration(nil), md.model.Enumerations...)
+	sort.Slice(enums, func(i, j int) bool { return enums[i].Name < enums[j].Name })
+	md.printf("## Enumerations\n\n")
+	for _, e := range enums {
+		md.heading(e.Name, e.Info)
+		md.printf("%s", md.docs(e.Info))
+		md.printf("Values are `%s`", e.Type)
+		if e.SupportsCustomValues {
+			md.printf("; other values are allowed")
+		}
+		md.printf(".\n\n| Name | Value | Description |\n| --- | --- | --- |\n")
+		for _, v := range e.Values {
+			md.printf("| %s | `%s` | %s |\n", v.Name, v.Value, cell(v.Info))
+		}
+		md.printf("\n")
+	}
+
+	aliases := append([]*TypeAlias(nil), md.model.TypeAliases...)
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+	md.printf("## Type aliases\n\n")
+	for _, a := range aliases {
+		md.heading(a.Name, a.Info)
+		md.printf("%s", md.docs(a.Info))
+		md.printf("= %s\n\n", md.typeRef(a.Type))
+	}
+}
+
+func (md *markdown) message(method string, info Info, dir Direction, params Params, reg *Type, clientCap, serverCap string) {
+	md.heading(method, info)
+	md.printf("- Direction: %s\n", direction(dir))
+	if len(params) > 0 {
+		md.printf("- Params: %s\n", md.typeList(params))
+	}
+	if reg != nil {
+		md.printf("- Registration options: %s\n", md.typeRef(reg))
+	}
+	if clientCap != "" {
+		md.printf("- Client capability: [`%s`](#clientcapabilities)\n", clientCap)
+	}
+	if serverCap != "" {
+		md.printf("- Server capability: [`%s`](#servercapabilities)\n", serverCap)
+	}
+}
+
+func (md *markdown) heading(name string, info Info) {
+	md.printf("<a id=\"%s\"></a>\n### %s%s\n\n", anchor(name), name, badges(info))
+}
+
+func (md *markdown) properties(props []*Property) {
+	if len(props) == 0 {
+		return
+	}
+	md.printf("| Property | Type | Description |\n| --- | --- | --- |\n")
+	for _, p := range props {
+		name := p.Name
+		if p.Optional {
+			name += "?"
+		}
+		md.printf("| %s%s | %s | %s |\n", name, badges(p.Info), md.typeRef(p.Type), cell(p.Info))
+	}
+	md.printf("\n")
+}
+
+func (md *markdown) docs(info Info) string {
+	out := ""
+	if info.Deprecated != "" {
+		out += "> **Deprecated:** " + oneLine(info.Deprecated) + "\n\n"
+	}
+	if info.Documentation != "" {
+		out += strings.TrimSpace(info.Documentation) + "\n\n"
+	}
+	return out
+}
+
+func (md *markdown) typeList(types []*Type) string {
+	parts := make([]string, len(types))
+	for idx, t := range types {
+		parts[idx] = md.typeRef(t)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// typeRef renders a type with references linked to their anchors.
+func (md *markdown) typeRef(t *Type) string {
+	if t == nil {
+		return "`null`"
+	}
+	switch t.Kind {
+	case KindReference:
+		return fmt.Sprintf("[%s](#%s)", t.Name, anchor(t.Name))
+	case KindArray:
+		return md.typeRef(t.Element) + "[]"
+	case KindMap:
+		return "{ [key: " + md.typeRef(t.Key) + "]: " + md.typeRef(t.Value) + " }"
+	case KindOr, KindAnd, KindTuple:
+		sep := map[Kind]string{KindOr: " \\| ", KindAnd: " & ", KindTuple: ", "}[t.Kind]
+		parts := make([]string, len(t.Items))
+		for idx, item := range t.Items {
+			parts[idx] = md.typeRef(item)
+		}
+		if t.Kind == KindTuple {
+			return "[" + strings.Join(parts, sep) + "]"
+		}
+		return strings.Join(parts, sep)
+	}
+	return "`" + t.String() + "`"
+}
+
+func anchor(name string) string {
+	return strings.NewReplacer("/", "-", "$", "", ".", "-").Replace(strings.ToLower(name))
+}
+
+func badges(info Info) string {
+	out := ""
+	if info.Since != "" {
+		out += " <sub>since " + info.Since + "</sub>"
+	}
+	if info.Proposed {
+		out += " <sub>proposed</sub>"
+	}
+	if info.Deprecated != "" {
+		out += " <sub>deprecated</sub>"
+	}
+	return out
+}
+
+func direction(dir Direction) string {
+	switch dir {
+	case ClientToServer:
+		return "client → server"
+	case ServerToClient:
+		return "server → client"
+	case Both:
+		return "both"
+	}
+	return string(dir)
+}
+
+// cell is the first paragraph of the documentation on one line, for tables.
+func cell(info Info) string {
+	doc, _, _ := strings.Cut(strings.TrimSpace(info.Documentation), "\n\n")
+	return strings.ReplaceAll(oneLine(doc), "|", "\\|")
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,381 @@
+// Package metamodel models the LSP specification's machine-readable
+// metaModel.json: every request, notification, structure, enumeration and
+// type alias of the protocol, from which lspschema generates code and docs.
+package metamodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Model is a whole metaModel file.
+type Model struct {
+	MetaData      MetaData        `json:"metaData"`
+	Requests      []*Request      `json:"requests"`
+	Notifications []*Notification `json:"notifications"`
+	Structures    []*Structure    `json:"structures"`
+	Enumerations  []*Enumeration  `json:"enumerations"`
+	TypeAliases   []*TypeAlias    `json:"typeAliases"`
+}
+
+type MetaData struct {
+	Version string `json:"version"`
+}
+
+// Info is the documentation and lifecycle metadata shared by every element.
+type Info struct {
+	Documentation string `json:"documentation,omitempty"`
+	Since         string `json:"since,omitempty"`
+	Proposed      bool   `json:"proposed,omitempty"`
+	Deprecated    string `json:"deprecated,omitempty"`
+}
+
+// Direction is which side sends a message.
+type Direction string
+
+const (
+	ClientToServer Direction = "clientToServer"
+	ServerToClient Direction = "serverToClient"
+	Both           Direction = "both"
+)
+
+type Request struct {
+	Info
+	Method              string    `json:"method"`
+	TypeName            string    `json:"typeName,omitempty"`
+	Params              Params    `json:"params,omitempty"`
+	Result              *Type     `json:"result"`
+	PartialResult       *Type     `json:"partialResult,omitempty"`
+	ErrorData           *Type     `json:"errorData,omitempty"`
+	RegistrationMethod  string    `json:"registrationMethod,omitempty"`
+	RegistrationOptions *Type     `json:"registrationOptions,omitempty"`
+	MessageDirection    Direction `json:"messageDirection"`
+	ClientCapability    string    `json:"clientCapability,omitempty"`
+	ServerCapability    string    `json:"serverCapability,omitempty"`
+}
+
+type Notification struct {
+	Info
+	Method              string    `json:"method"`
+	TypeName            string    `json:"typeName,omitempty"`
+	Params              Params    `json:"params,omitempty"`
+	RegistrationMethod  string    `json:"registrationMethod,omitempty"`
+	RegistrationOptions *Type     `json:"registrationOptions,omitempty"`
+	MessageDirection    Direction `json:"messageDirection"`
+	ClientCapability    string    `json:"clientCapability,omitempty"`
+	ServerCapability    string    `json:"serverCapability,omitempty"`
+}
+
+// Params is a message's params: usually one type, but the schema allows a
+// positional list.
+type Params []*Type
+
+func (p *Params) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var list []*Type
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		*p = list
+		return nil
+	}
+	single := &Type{}
+	if err := json.Unmarshal(data, single); err != nil {
+		return err
+	}
+	*p = Params{single}
+	return nil
+}
+
+type Structure struct {
+	Info
+	Name       string      `json:"name"`
+	Extends    []*Type     `json:"extends,omitempty"`
+	Mixins     []*Type     `json:"mixins,omitempty"`
+	Properties []*Property `json:"properties"`
+}
+
+type Property struct {
+	Info
+	Name     string `json:"name"`
+	Type     *Type  `json:"type"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+type Enumeration struct {
+	Info
+	Name                 string              `json:"name"`
+	Type                 *Type               `json:"type"`
+	Values               []*EnumerationEntry `json:"values"`
+	SupportsCustomValues bool                `json:"supportsCustomValues,omitempty"`
+}
+
+type EnumerationEntry struct {
+	Info
+	Name string `json:"name"`
+
+	// Value is a string or a number.
+	Value json.RawMessage `json:"value"`
+}
+
+type TypeAlias struct {
+	Info
+	Name string `json:"name"`
+	Type *Type  `json:"type"`
+}
+
+// Kind is the shape of a Type.
+type Kind string
+
+const (
+	KindBase           Kind = "base"
+	KindReference      Kind = "reference"
+	KindArray          Kind = "array"
+	KindMap            Kind = "map"
+	KindAnd            Kind = "and"
+	KindOr             Kind = "or"
+	KindTuple          Kind = "tuple"
+	KindLiteral        Kind = "literal"
+	KindStringLiteral  Kind = "stringLiteral"
+	KindIntegerLiteral Kind = "integerLiteral"
+	KindBooleanLiteral Kind = "booleanLiteral"
+)
+
+// Type is a type expression. Which fields are set depends on Kind.
+type Type struct {
+	Kind Kind
+
+	// Name is the base type (string, integer, uinteger, decimal, boolean,
+	// null, URI, DocumentUri, RegExp) or the referenced element.
+	Name string
+
+	// Element is an array's element type.
+	Element *Type
+
+	// Key and Value are a map's key and value types.
+	Key   *Type
+	Value *Type
+
+	// Items are the members of and, or and tuple types.
+	Items []*Type
+
+	// Literal is an anonymous structure.
+	Literal *StructureLiteral
+
+	// StringValue, IntegerValue and BooleanValue hold literal values.
+	StringValue  string
+	IntegerValue int64
+	BooleanValue bool
+}
+
+// StructureLiteral is an inline object type.
+type StructureLiteral struct {
+	Info
+	Properties []*Property `json:"properties"`
+}
+
+func (t *Type) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Kind    Kind            `json:"kind"`
+		Name    string          `json:"name"`
+		Element *Type           `json:"element"`
+		Key     *Type           `json:"key"`
+		Items   []*Type         `json:"items"`
+		Value   json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*t = Type{Kind: raw.Kind, Name: raw.Name, Element: raw.Element, Key: raw.Key, Items: raw.Items}
+	if len(raw.Value) == 0 {
+		return nil
+	}
+	var err error
+	switch raw.Kind {
+	case KindMap:
+		t.Value = &Type{}
+		err = json.Unmarshal(raw.Value, t.Value)
+	case KindLiteral:
+		t.Literal = &StructureLiteral{}
+		err = json.Unmarshal(raw.Value, t.Literal)
+	case KindStringLiteral:
+		err = json.Unmarshal(raw.Value, &t.StringValue)
+	case KindIntegerLiteral:
+		err = json.Unmarshal(raw.Value, &t.IntegerValue)
+	case KindBooleanLiteral:
+		err = json.Unmarshal(raw.Value, &t.BooleanValue)
+	}
+	if err != nil {
+		return fmt.Errorf("%s type value: %w", raw.Kind, err)
+	}
+	return nil
+}
+
+// String renders the type in the TypeScript-like notation of the
+// specification.
+func (t *Type) String() string {
+	if t == nil {
+		return "void"
+	}
+	switch t.Kind {
+	case KindBase, KindReference:
+		return t.Name
+	case KindArray:
+		elem := t.Element.String()
+		if t.Element.Kind == KindOr || t.Element.Kind == KindAnd {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case KindMap:
+		return "{ [key: " + t.Key.String() + "]: " + t.Value.String() + " }"
+	case KindAnd, KindOr, KindTuple:
+		parts := make([]string, len(t.Items))
+		for idx, item := range t.Items {
+			parts[idx] = item.String()
+		}
+		switch t.Kind {
+		case KindAnd:
+			return strings.Join(parts, " & ")
+		case KindOr:
+			return strings.Join(parts, " | ")
+		default:
+			return "[" + strings.Join(parts, ", ") + "]"
+		}
+	case KindLiteral:
+		if t.Literal == nil || len(t.Literal.Properties) == 0 {
+			return "{}"
+		}
+		parts := make([]string, len(t.Literal.Properties))
+		for idx, prop := range t.Literal.Properties {
+			opt := ""
+			if prop.Optional {
+				opt = "?"
+			}
+			parts[idx] = prop.Name + opt + ": " + prop.Type.String()
+		}
+		return "{ " + strings.Join(parts, "; ") + " }"
+	case KindStringLiteral:
+		return fmt.Sprintf("%q", t.StringValue)
+	case KindIntegerLiteral:
+		return fmt.Sprint(t.IntegerValue)
+	case KindBooleanLiteral:
+		return fmt.Sprint(t.BooleanValue)
+	}
+	return string(t.Kind)
+}
+
+// Load reads a metaModel from a file or an http(s) URL, or the embedded
+// copy when source is empty.
+func Load(source string) (*Model, error) {
+	if source == "" {
+		return Embedded()
+	}
+	data, err := Fetch(source)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, source)
+}
+
+// Fetch reads the raw contents of a file or an http(s) URL.
+func Fetch(source string) ([]byte, error) {
+	var r io.ReadCloser
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		res, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("fetching %s: %s", source, res.Status)
+		}
+		r = res.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Parse decodes a metaModel read from source.
+func Parse(data []byte, source string) (*Model, error) {
+	model := &Model{}
+	if err := json.Unmarshal(data, model); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", source, err)
+	}
+	return model, nil
+}
+
+// Structure finds a structure by name.
+func (m *Model) Structure(name string) *Structure {
+	for _, s := range m.Structures {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Enumeration finds an enumeration by name.
+func (m *Model) Enumeration(name string) *Enumeration {
+	for _, e := range m.Enumerations {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// TypeAlias finds a type alias by name.
+func (m *Model) TypeAlias(name string) *TypeAlias {
+	for _, a := range m.TypeAliases {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Properties flattens a structure: the properties of everything it extends
+// and mixes in, in declaration order, then its own. A property declared
+// again further down replaces the earlier one in place, so the JSON names
+// stay unique.
+func (m *Model) Properties(s *Structure) []*Property {
+	var out []*Property
+	index := map[string]int{}
+	var walk func(s *Structure, seen map[string]bool)
+	walk = func(s *Structure, seen map[string]bool) {
+		if seen[s.Name] {
+			return
+		}
+		seen[s.Name] = true
+		for _, parent := range append(append([]*Type(nil), s.Extends...), s.Mixins...) {
+			if parent.Kind != KindReference {
+				continue
+			}
+			if ps := m.Structure(parent.Name); ps != nil {
+				walk(ps, seen)
+			}
+		}
+		for _, p := range s.Properties {
+			if idx, ok := index[p.Name]; ok {
+				out[idx] = p
+				continue
+			}
+			index[p.Name] = len(out)
+			out = append(out, p)
+		}
+	}
+	walk(s, map[string]bool{})
+	return out
+}
@@ -0,0 +1,367 @@
+package metamodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Element is the kind of metaModel element a ModelChange is to.
+type Element string
+
+const (
+	ElementRequest      Element = "request"
+	ElementNotification Element = "notification"
+	ElementStructure    Element = "structure"
+	ElementProperty     Element = "property"
+	ElementEnumeration  Element = "enumeration"
+	ElementValue        Element = "value"
+	ElementTypeAlias    Element = "typeAlias"
+)
+
+// elements are the Elements in the order a ModelDiff lists them, with the
+// titles of their Markdown sections.
+var elements = []struct {
+	element Element
+	title   string
+}{
+	{ElementRequest, "Requests"},
+	{ElementNotification, "Notifications"},
+	{ElementStructure, "Structures"},
+	{ElementProperty, "Properties"},
+	{ElementEnumeration, "Enumerations"},
+	{ElementValue, "Enumeration values"},
+	{ElementTypeAlias, "Type aliases"},
+}
+
+// ModelChange is one element which differs between two metaModels: a
+// message by its method, a property or enumeration value as Parent.name,
+// and anything else by its name. Old and New describe it as the
+// specification does.
+type ModelChange struct {
+	Kind    ChangeKind `json:"kind"`
+	Element Element    `json:"element"`
+	Name    string     `json:"name"`
+	Old     string     `json:"old,omitempty"`
+	New     string     `json:"new,omitempty"`
+
+	// Breaking is how the change breaks code written against the Go API
+	// generated from the old metaModel, empty when it doesn't.
+	Breaking string `json:"breaking,omitempty"`
+}
+
+// ModelDiff is what changed between two metaModels, element by element,
+// for reviewing a bump of the protocol version before regenerating: where
+// Diff compares the generated code, ModelDiff says which structures,
+// properties, enumeration values and messages the specification changed.
+type ModelDiff struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Changes []ModelChange `json:"changes"`
+}
+
+// DiffModels lists the elements added to, removed from and changed
+// between old and new. A structure's properties are those it inherits as
+// well as its own, as the generated struct has them; those of a structure
+// added or removed, like the values of an enumeration, aren't listed
+// separately.
+func DiffModels(old, new *Model) *ModelDiff {
+	oldElems, newElems := describeModel(old), describeModel(new)
+	// Members of what was added or removed go with it.
+	inBoth := func(key *elementKey) bool {
+		if key == nil {
+			return true
+		}
+		_, inOld := oldElems[*key]
+		_, inNew := newElems[*key]
+		return inOld && inNew
+	}
+	d := &ModelDiff{From: old.MetaData.Version, To: new.MetaData.Version, Changes: []ModelChange{}}
+	for key, o := range oldElems {
+		n, ok := newElems[key]
+		switch {
+		case !inBoth(o.parent):
+		case !ok:
+			d.Changes = append(d.Changes, ModelChange{Kind: Removed, Element: key.element, Name: key.name, Old: o.desc, Breaking: o.removed})
+		case n.desc != o.desc:
+			d.Changes = append(d.Changes, ModelChange{Kind: Changed, Element: key.element, Name: key.name, Old: o.desc, New: n.desc, Breaking: breaks(o.item, n.item)})
+		}
+	}
+	for key, n := range newElems {
+		if _, ok := oldElems[key]; !ok && inBoth(n.parent) {
+			d.Changes = append(d.Changes, ModelChange{Kind: Added, Element: key.element, Name: key.name, New: n.desc})
+		}
+	}
+	order := map[Element]int{}
+	for i, e := range elements {
+		order[e.element] = i
+	}
+	sort.Slice(d.Changes, func(i, j int) bool {
+		a, b := d.Changes[i], d.Changes[j]
+		if a.Element != b.Element {
+			return order[a.Element] < order[b.Element]
+		}
+		return a.Name < b.Name
+	})
+	return d
+}
+
+// Breaking is the changes which break code using the old Go API.
+func (d *ModelDiff) Breaking() []ModelChange {
+	var out []ModelChange
+	for _, ch := range d.Changes {
+		if ch.Breaking != "" {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+type elementKey struct {
+	element Element
+	name    string
+}
+
+// described is an element as DiffModels compares it: its description, how
+// removing it breaks the Go API, and the structure or enumeration it is a
+// member of.
+type described struct {
+	desc    string
+	removed string
+	item    any
+	parent  *elementKey
+}
+
+// property is a Property in the structure it is flattened into.
+type property struct {
+	parent string
+	*Property
+}
+
+// value is an EnumerationEntry of its enumeration.
+type value struct {
+	enum *Enumeration
+	*EnumerationEntry
+}
+
+func describeModel(m *Model) map[elementKey]described {
+	out := map[elementKey]described{}
+	add := func(element Element, name string, d described) {
+		out[elementKey{element, name}] = d
+	}
+	for _, r := range m.Requests {
+		add(ElementRequest, r.Method, described{
+			desc:    describeRequest(r) + deprecation(r.Info),
+			removed: "its method and handler types are no longer generated",
+			item:    r,
+		})
+	}
+	for _, n := range m.Notifications {
+		add(ElementNotification, n.Method, described{
+			desc:    describeNotification(n) + deprecation(n.Info),
+			removed: "its method and handler types are no longer generated",
+			item:    n,
+		})
+	}
+	for _, s := range m.Structures {
+		add(ElementStructure, s.Name, described{
+			desc:    describeStructure(s) + deprecation(s.Info),
+			removed: fmt.Sprintf("type %s is no longer generated", s.Name),
+			item:    s,
+		})
+		for _, p := range m.Properties(s) {
+			desc := p.Type.String()
+			if p.Optional {
+				desc = "optional " + desc
+			}
+			add(ElementProperty, s.Name+"."+p.Name, described{
+				desc:    desc + deprecation(p.Info),
+				removed: fmt.Sprintf("field %s.%s is no longer generated", s.Name, GoName(p.Name)),
+				item:    property{s.Name, p},
+				parent:  &elementKey{ElementStructure, s.Name},
+			})
+		}
+	}
+	for _, e := range m.Enumerations {
+		desc := "enumeration of " + e.Type.String()
+		if e.SupportsCustomValues {
+			desc += " with custom values"
+		}
+		add(ElementEnumeration, e.Name, described{
+			desc:    desc + deprecation(e.Info),
+			removed: fmt.Sprintf("type %s and its constants are no longer generated", e.Name),
+			item:    e,
+		})
+		for _, v := range e.Values {
+			add(ElementValue, e.Name+"."+v.Name, described{
+				desc:    string(v.Value) + deprecation(v.Info),
+				removed: fmt.Sprintf("constant %s%s is no longer generated", e.Name, exported(v.Name)),
+				item:    value{e, v},
+				parent:  &elementKey{ElementEnumeration, e.Name},
+			})
+		}
+	}
+	for _, a := range m.TypeAliases {
+		add(ElementTypeAlias, a.Name, described{
+			desc:    a.Type.String() + deprecation(a.Info),
+			removed: fmt.Sprintf("type %s is no longer generated", a.Name),
+			item:    a,
+		})
+	}
+	return out
+}
+
+func describeRequest(r *Request) string {
+	return fmt.Sprintf("request %s, params %s, result %s", r.MessageDirection, describeParams(r.Params), r.Result)
+}
+
+func describeNotification(n *Notification) string {
+	return fmt.Sprintf("notification %s, params %s", n.MessageDirection, describeParams(n.Params))
+}
+
+func describeStructure(s *Structure) string {
+	desc := "structure"
+	for _, parents := range []struct {
+		verb  string
+		types []*Type
+	}{{"extends", s.Extends}, {"mixes in", s.Mixins}} {
+		if len(parents.types) == 0 {
+			continue
+		}
+		names := make([]string, len(parents.types))
+		for i, t := range parents.types {
+			names[i] = t.String()
+		}
+		desc += " " + parents.verb + " " + strings.Join(names, ", ")
+	}
+	return desc
+}
+
+func deprecation(info Info) string {
+	if info.Deprecated != "" {
+		return ", deprecated"
+	}
+	return ""
+}
+
+// breaks is how changing an element from old to new breaks the Go API,
+// empty when only its documentation, lifecycle or what the Go API doesn't
+// reflect changed. Structures' parents aren't embedded in the generated
+// structs, so what they add and take away is reported as properties.
+func breaks(old, new any) string {
+	switch o := old.(type) {
+	case *Request:
+		n := new.(*Request)
+		switch {
+		case o.MessageDirection != n.MessageDirection:
+			return fmt.Sprintf("it is now sent %s, so its handler belongs to the other side", n.MessageDirection)
+		case describeRequest(o) != describeRequest(n):
+			return "the Go types of its params or result change"
+		}
+	case *Notification:
+		n := new.(*Notification)
+		switch {
+		case o.MessageDirection != n.MessageDirection:
+			return fmt.Sprintf("it is now sent %s, so its handler belongs to the other side", n.MessageDirection)
+		case describeNotification(o) != describeNotification(n):
+			return "the Go types of its params change"
+		}
+	case property:
+		n := new.(property)
+		field := o.parent + "." + GoName(o.Name)
+		if o.Type.String() != n.Type.String() {
+			return fmt.Sprintf("the Go type of field %s changes", field)
+		}
+		_, oldPointer := fieldType(o.Type, o.Optional, GoType)
+		_, newPointer := fieldType(n.Type, n.Optional, GoType)
+		switch {
+		case !oldPointer && newPointer:
+			return fmt.Sprintf("field %s becomes a pointer", field)
+		case oldPointer && !newPointer:
+			return fmt.Sprintf("field %s is no longer a pointer", field)
+		}
+	case *Enumeration:
+		n := new.(*Enumeration)
+		switch {
+		case GoType(o.Type) != GoType(n.Type):
+			return fmt.Sprintf("type %s becomes a %s", o.Name, GoType(n.Type))
+		case o.SupportsCustomValues && !n.SupportsCustomValues:
+			return fmt.Sprintf("%s.IsKnown is no longer generated, and values other than its constants no longer decode", o.Name)
+		}
+	case value:
+		n := new.(value)
+		if string(o.Value) != string(n.Value) {
+			return fmt.Sprintf("the value of constant %s%s changes", o.enum.Name, exported(o.Name))
+		}
+	case *TypeAlias:
+		n := new.(*TypeAlias)
+		if o.Type.String() != n.Type.String() {
+			return fmt.Sprintf("the Go type %s stands for changes", o.Name)
+		}
+	}
+	return ""
+}
+
+// WriteJSON writes the diff as indented JSON.
+func (d *ModelDiff) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteMarkdown writes the diff as a Markdown document: the breaking
+// changes, then a section per kind of element, each added, removed and
+// changed.
+func (d *ModelDiff) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# metaModel changes from %s to %s\n", d.From, d.To)
+	line := func(ch ModelChange) string {
+		switch ch.Kind {
+		case Added:
+			return fmt.Sprintf("- `%s`: %s", ch.Name, code(ch.New))
+		case Removed:
+			return fmt.Sprintf("- `%s`: %s", ch.Name, code(ch.Old))
+		}
+		return fmt.Sprintf("- `%s`: %s → %s", ch.Name, code(ch.Old), code(ch.New))
+	}
+
+	breaking := d.Breaking()
+	b.WriteString("\n## Breaking changes\n\n")
+	if len(breaking) == 0 {
+		fmt.Fprintf(&b, "None: code using the Go API generated from %s keeps compiling.\n", d.From)
+	}
+	for _, ch := range breaking {
+		fmt.Fprintf(&b, "- %s %s `%s`: %s\n", ch.Kind, ch.Element, ch.Name, ch.Breaking)
+	}
+
+	for _, e := range elements {
+		var changes []ModelChange
+		for _, ch := range d.Changes {
+			if ch.Element == e.element {
+				changes = append(changes, ch)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n", e.title)
+		for _, kind := range []ChangeKind{Added, Removed, Changed} {
+			var lines []string
+			for _, ch := range changes {
+				if ch.Kind == kind {
+					lines = append(lines, line(ch))
+				}
+			}
+			if len(lines) > 0 {
+				fmt.Fprintf(&b, "\n### %s%s\n\n%s\n", strings.ToUpper(string(kind[:1])), kind[1:], strings.Join(lines, "\n"))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,130 @@
+package metamodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"path"
+	"sort"
+)
+
+// Overrides replaces the Go types GoGenerator would generate with ones
+// written by hand, such as a DocumentUri type which validates its value:
+//
+//	{
+//	  "types": {
+//	    "DocumentUri": {"type": "uri.URI", "import": "example.com/uri"},
+//	    "LSPAny": {"type": "json.RawMessage"}
+//	  },
+//	  "properties": {
+//	    "TextDocumentItem.text": {"type": "rope.Text", "import": "example.com/rope"}
+//	  }
+//	}
+type Overrides struct {
+	// Types are keyed by metaModel name: a structure, enumeration or type
+	// alias, which is then not generated, a base type such as DocumentUri,
+	// or LSPAny, LSPObject or LSPArray.
+	Types map[string]Override `json:"types,omitempty"`
+
+	// Properties are keyed by structure and property name, as in
+	// "TextDocumentItem.text". Inline objects are named as their generated
+	// structs are, as in "ServerCapabilitiesWorkspace.fileOperations".
+	Properties map[string]Override `json:"properties,omitempty"`
+}
+
+// Override is the Go type standing in for a metaModel type or property.
+// Where a property is optional or nullable, its field is a pointer to the
+// type unless the type is spelled as a slice, map, pointer, any or
+// json.RawMessage.
+type Override struct {
+	// Type is a Go type expression, qualified by package name, as in
+	// "uri.URI" or "[]rope.Line".
+	Type string `json:"type"`
+
+	// Import is the path of the package Type is from, whose name must be
+	// the last element of the path. The packages the generated code imports
+	// anyway, such as encoding/json, need none.
+	Import string `json:"import,omitempty"`
+}
+
+// ParseOverrides decodes and checks an overrides config.
+func ParseOverrides(data []byte) (*Overrides, error) {
+	var o Overrides
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return nil, fmt.Errorf("metamodel: overrides: %w", err)
+	}
+	for _, set := range []map[string]Override{o.Types, o.Properties} {
+		for name, ov := range set {
+			if err := ov.check(); err != nil {
+				return nil, fmt.Errorf("metamodel: override of %s: %w", name, err)
+			}
+		}
+	}
+	return &o, nil
+}
+
+func (o Override) check() error {
+	expr, err := parser.ParseExpr(o.Type)
+	if err != nil {
+		return fmt.Errorf("invalid type %q", o.Type)
+	}
+	var bad error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if ok && pkg.Name != path.Base(o.Import) && !stdImports[pkg.Name] {
+			bad = fmt.Errorf("type %s needs an import of package %s", o.Type, pkg.Name)
+		}
+		return false
+	})
+	return bad
+}
+
+// stdImports are the names of the packages the generated code imports
+// itself, which overrides may use without an import.
+var stdImports = map[string]bool{"bytes": true, "context": true, "json": true, "fmt": true, "uri": true}
+
+// overrideType is the Go type a metaModel type name is overridden with.
+func (g *GoGenerator) overrideType(name string) (string, bool) {
+	if g.Overrides == nil {
+		return "", false
+	}
+	ov, ok := g.Overrides.Types[name]
+	return ov.Type, ok
+}
+
+// overrideProperty is the Go type the field for property prop of the
+// struct named strct is overridden with.
+func (g *GoGenerator) overrideProperty(strct, prop string) (string, bool) {
+	if g.Overrides == nil {
+		return "", false
+	}
+	ov, ok := g.Overrides.Properties[strct+"."+prop]
+	return ov.Type, ok
+}
+
+// overrideImports are the paths of the packages overrides import, sorted.
+func (g *GoGenerator) overrideImports() []string {
+	if g.Overrides == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var paths []string
+	for _, set := range []map[string]Override{g.Overrides.Types, g.Overrides.Properties} {
+		for _, ov := range set {
+			if ov.Import != "" && !seen[ov.Import] {
+				seen[ov.Import] = true
+				paths = append(paths, ov.Import)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,46 @@
+package metamodel
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PinnedVersion is the protocol release the embedded metaModel is taken
+// from, and the one VersionURL resolves 3.17 to.
+const PinnedVersion = "3.17.5"
+
+// EmbeddedPath is where the embedded metaModel lives, relative to the
+// module root; lspschema -update rewrites it.
+const EmbeddedPath = "internal/metamodel/metaModel.json"
+
+//go:embed metaModel.json
+var embedded []byte
+
+// minors maps each minor protocol version to its pinned release.
+var minors = map[string]string{
+	"3.17": PinnedVersion,
+}
+
+// VersionURL is the metaModel of a protocol release, published under the
+// release/protocol tags of vscode-languageserver-node. A minor version such
+// as 3.17 resolves to its pinned release.
+func VersionURL(version string) (string, error) {
+	if pinned, ok := minors[version]; ok {
+		version = pinned
+	}
+	if strings.Count(version, ".") != 2 {
+		return "", fmt.Errorf("unknown protocol version %q", version)
+	}
+	return "https://raw.githubusercontent.com/microsoft/vscode-languageserver-node/release/protocol/" + version + "/protocol/metaModel.json", nil
+}
+
+// Embedded is the metaModel of PinnedVersion built into the binary, so
+// generation is reproducible and works offline.
+func Embedded() (*Model, error) {
+	if len(embedded) == 0 {
+		return nil, errors.New("no metaModel is embedded; run lspschema -update")
+	}
+	return Parse(embedded, "embedded metaModel "+PinnedVersion)
+}
@@ -0,0 +1,192 @@
+package metamodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+)
+
+// Random builds arbitrary values which conform to the model's types, for
+// soak testing handlers with valid input. It is not safe for concurrent use.
+type Random struct {
+	Model *Model
+	Rand  *rand.Rand
+
+	// MaxDepth is how deep values nest before optional properties are left
+	// out and arrays and maps left empty, 6 when zero.
+	MaxDepth int
+
+	// URIs are the document URIs values draw from; file:///random/N.txt
+	// when empty.
+	URIs []string
+}
+
+// words seed string values: ASCII, multi-byte and astral characters, since
+// handlers most often mishandle the last two.
+var words = []string{"", "x", "item", "hello world", "naïve", "日本語", "emoji 😀", "tab\tsep", "line\nbreak"}
+
+// Params returns random params for a request or notification method, and
+// whether the model has the method. A method without params gets nil.
+func (r *Random) Params(method string) (any, bool) {
+	for _, req := range r.Model.Requests {
+		if req.Method == method {
+			return r.params(req.Params), true
+		}
+	}
+	for _, n := range r.Model.Notifications {
+		if n.Method == method {
+			return r.params(n.Params), true
+		}
+	}
+	return nil, false
+}
+
+func (r *Random) params(params Params) any {
+	switch len(params) {
+	case 0:
+		return nil
+	case 1:
+		return r.Value(params[0])
+	}
+	out := make([]any, len(params))
+	for idx, t := range params {
+		out[idx] = r.Value(t)
+	}
+	return out
+}
+
+// Value returns a random value of type t, made of the types encoding/json
+// produces when decoding into any.
+func (r *Random) Value(t *Type) any {
+	return r.value(t, 0)
+}
+
+func (r *Random) maxDepth() int {
+	if r.MaxDepth > 0 {
+		return r.MaxDepth
+	}
+	return 6
+}
+
+func (r *Random) value(t *Type, depth int) any {
+	// Past twice the depth limit only required properties remain, and a
+	// cycle of those has no finite value.
+	if t == nil || depth > 2*r.maxDepth() {
+		return nil
+	}
+	deep := depth >= r.maxDepth()
+	switch t.Kind {
+	case KindBase:
+		return r.base(t.Name)
+	case KindReference:
+		return r.reference(t.Name, depth)
+	case KindArray:
+		n := 0
+		if !deep {
+			n = r.Rand.Intn(4)
+		}
+		out := make([]any, n)
+		for idx := range out {
+			out[idx] = r.value(t.Element, depth+1)
+		}
+		return out
+	case KindMap:
+		out := map[string]any{}
+		if !deep {
+			for n := r.Rand.Intn(3); n > 0; n-- {
+				out[fmt.Sprint(r.value(t.Key, depth+1))] = r.value(t.Value, depth+1)
+			}
+		}
+		return out
+	case KindAnd:
+		out := map[string]any{}
+		for _, item := range t.Items {
+			if obj, ok := r.value(item, depth).(map[string]any); ok {
+				for key, val := range obj {
+					out[key] = val
+				}
+			}
+		}
+		return out
+	case KindOr:
+		if len(t.Items) == 0 {
+			return nil
+		}
+		return r.value(t.Items[r.Rand.Intn(len(t.Items))], depth)
+	case KindTuple:
+		out := make([]any, len(t.Items))
+		for idx, item := range t.Items {
+			out[idx] = r.value(item, depth+1)
+		}
+		return out
+	case KindLiteral:
+		if t.Literal == nil {
+			return map[string]any{}
+		}
+		return r.object(t.Literal.Properties, depth)
+	case KindStringLiteral:
+		return t.StringValue
+	case KindIntegerLiteral:
+		return float64(t.IntegerValue)
+	case KindBooleanLiteral:
+		return t.BooleanValue
+	}
+	return nil
+}
+
+func (r *Random) base(name string) any {
+	switch name {
+	case "string":
+		return words[r.Rand.Intn(len(words))]
+	case "RegExp":
+		return ".*"
+	case "URI", "DocumentUri":
+		if len(r.URIs) > 0 {
+			return r.URIs[r.Rand.Intn(len(r.URIs))]
+		}
+		return fmt.Sprintf("file:///random/%d.txt", r.Rand.Intn(4))
+	case "integer":
+		return float64(r.Rand.Int31n(200) - 10)
+	case "uinteger":
+		return float64(r.Rand.Int31n(200))
+	case "decimal":
+		return r.Rand.Float64()
+	case "boolean":
+		return r.Rand.Intn(2) == 0
+	}
+	return nil
+}
+
+func (r *Random) reference(name string, depth int) any {
+	switch name {
+	case "LSPAny":
+		return r.base([]string{"string", "integer", "boolean"}[r.Rand.Intn(3)])
+	case "LSPObject":
+		return map[string]any{}
+	case "LSPArray":
+		return []any{}
+	}
+	if s := r.Model.Structure(name); s != nil {
+		return r.object(r.Model.Properties(s), depth)
+	}
+	if e := r.Model.Enumeration(name); e != nil && len(e.Values) > 0 {
+		var v any
+		_ = json.Unmarshal(e.Values[r.Rand.Intn(len(e.Values))].Value, &v)
+		return v
+	}
+	if a := r.Model.TypeAlias(name); a != nil {
+		return r.value(a.Type, depth)
+	}
+	return nil
+}
+
+func (r *Random) object(props []*Property, depth int) map[string]any {
+	out := map[string]any{}
+	for _, p := range props {
+		if p.Optional && (depth >= r.maxDepth() || r.Rand.Intn(2) == 0) {
+			continue
+		}
+		out[p.Name] = r.value(p.Type, depth+1)
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package metamodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+)
+
+// fileRoundTrips holds the round-trip tests, which only a package has, and
+// roundTripFixtures is the directory of their golden payloads.
+const (
+	fileRoundTrips    = "roundtrip_test.go"
+	roundTripFixtures = "testdata/roundtrip"
+)
+
+// writeRoundTrips writes TestRoundTrip, which for each structure,
+// enumeration and type alias declared decodes its minimal and maximal
+// Examples, kept as golden files, rejecting properties the Go type has no
+// field for, and encodes them back to the same JSON. Structures with an
+// overridden property are left to the tests of the hand-written types,
+// and types in a cycle of required properties have no finite example.
+func (g *GoGenerator) writeRoundTrips(buf *bytes.Buffer) error {
+	decls := g.decls()
+	names := make([]string, 0, len(decls))
+	for name, d := range decls {
+		if d.enum != nil && len(d.enum.Values) == 0 || d.structure != nil && g.overridesProperty(d.structure) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fixtures := map[string][]byte{}
+	var tested []string
+	for _, name := range names {
+		examples := &Examples{Model: g.Model}
+		ref := &Type{Kind: KindReference, Name: name}
+		values := map[string]any{"min": examples.Minimal(ref), "max": examples.Maximal(ref)}
+		if examples.truncated {
+			continue
+		}
+		for size, value := range values {
+			data, err := json.MarshalIndent(value, "", "  ")
+			if err != nil {
+				return fmt.Errorf("%s example: %w", name, err)
+			}
+			fixtures[path.Join(roundTripFixtures, name+"."+size+".json")] = append(data, '\n')
+		}
+		tested = append(tested, name)
+	}
+	if len(tested) == 0 {
+		return nil
+	}
+	g.fixtures = fixtures
+
+	buf.WriteString("func TestRoundTrip(t *testing.T) {\n\tfor _, tc := range []struct {\n\t\tname  string\n\t\tvalue func() any\n\t}{\n")
+	for _, name := range tested {
+		fmt.Fprintf(buf, "\t\t{%q, func() any { return new(%s) }},\n", name, name)
+	}
+	buf.WriteString("\t} {\n\t\tfor _, size := range []string{\"min\", \"max\"} {\n")
+	buf.WriteString("\t\t\tt.Run(tc.name+\"/\"+size, func(t *testing.T) {\n")
+	fmt.Fprintf(buf, "\t\t\t\tgolden, err := os.ReadFile(filepath.Join(%q, tc.name+\".\"+size+\".json\"))\n", roundTripFixtures)
+	buf.WriteString(`				if err != nil {
+					t.Fatal(err)
+				}
+				v := tc.value()
+				dec := json.NewDecoder(bytes.NewReader(golden))
+				dec.DisallowUnknownFields()
+				if err := dec.Decode(v); err != nil {
+					t.Fatalf("decoding %s: %v", golden, err)
+				}
+				out, err := json.Marshal(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var want, got any
+				if err := json.Unmarshal(golden, &want); err != nil {
+					t.Fatal(err)
+				}
+				if err := json.Unmarshal(out, &got); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("encoded back as %s, want %s", out, golden)
+				}
+			})
+		}
+	}
+}
+`)
+	return nil
+}
+
+// overridesProperty reports whether s has a property mapped to a Go type
+// written by hand.
+func (g *GoGenerator) overridesProperty(s *Structure) bool {
+	for _, p := range g.Model.Properties(s) {
+		if _, ok := g.overrideProperty(s.Name, p.Name); ok {
+			return true
+		}
+	}
+	return false
+}
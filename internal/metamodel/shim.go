@@ -0,0 +1,184 @@
+package metamodel
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// fileShims holds the deprecated shims of names Previous generated.
+const fileShims = "deprecated.go"
+
+// shim keeps a name generated from Previous compiling: a type alias or
+// constant of the name it was renamed to, or a view getter calling the
+// renamed one.
+type shim struct {
+	kind     string // "type", "const" or "getter"
+	old, new string
+	// view and results are a getter's view type and result list.
+	view, results string
+}
+
+// prepareShims finds what was renamed between Previous and Model: types and
+// constants no longer generated whose definitions, but for the names of
+// other renamed types, match exactly one newly generated, and view getters
+// replaced by exactly one of the same type on the same view. A struct
+// field renamed can't be kept, as there is no field alias, but reading it
+// through its view can.
+func (g *GoGenerator) prepareShims() error {
+	g.shims = nil
+	if g.Previous == nil {
+		return nil
+	}
+	oldAPI, err := api(g, g.Previous)
+	if err != nil {
+		return fmt.Errorf("previous metaModel: %w", err)
+	}
+	newAPI, err := api(g, g.Model)
+	if err != nil {
+		return err
+	}
+
+	// Declarations are top-level names; members are Type.Name.
+	removed, added := map[string]bool{}, map[string]bool{}
+	for name := range oldAPI {
+		if _, ok := newAPI[name]; !ok && !strings.Contains(name, ".") {
+			removed[name] = true
+		}
+	}
+	for name := range newAPI {
+		if _, ok := oldAPI[name]; !ok && !strings.Contains(name, ".") {
+			added[name] = true
+		}
+	}
+	renamed := map[string]bool{}
+	for name := range removed {
+		renamed[name] = true
+	}
+	for name := range added {
+		renamed[name] = true
+	}
+	normalize := func(s string) string {
+		return identPattern.ReplaceAllStringFunc(s, func(id string) string {
+			if renamed[id] {
+				return "·"
+			}
+			return id
+		})
+	}
+	signature := func(api map[string]string, name string) string {
+		sig := []string{normalize(api[name])}
+		for _, member := range members(api, name) {
+			sig = append(sig, member+" "+normalize(api[name+"."+member]))
+		}
+		return strings.Join(sig, "\n")
+	}
+
+	bySignature := map[string][]string{}
+	for name := range added {
+		key := signature(newAPI, name)
+		bySignature[key] = append(bySignature[key], name)
+	}
+	oldBySignature := map[string]int{}
+	for name := range removed {
+		oldBySignature[signature(oldAPI, name)]++
+	}
+	for _, name := range slices.Sorted(maps.Keys(removed)) {
+		key := signature(oldAPI, name)
+		matches := bySignature[key]
+		if len(matches) != 1 || oldBySignature[key] != 1 {
+			continue
+		}
+		switch def := newAPI[matches[0]]; {
+		case strings.HasPrefix(def, "type"):
+			g.shims = append(g.shims, shim{kind: "type", old: name, new: matches[0]})
+		case strings.HasPrefix(def, "const"):
+			g.shims = append(g.shims, shim{kind: "const", old: name, new: matches[0]})
+		}
+	}
+
+	// Getters of views still generated: the new names of those renamed
+	// are the shims themselves.
+	kept := map[string]bool{}
+	for name := range oldAPI {
+		view, getter, ok := strings.Cut(name, ".")
+		if !ok || getter == "Get" || !strings.HasSuffix(view, "View") {
+			continue
+		}
+		if _, ok := newAPI[view]; !ok {
+			continue
+		}
+		kept[view] = true
+	}
+	for _, view := range slices.Sorted(maps.Keys(kept)) {
+		gone, fresh := map[string][]string{}, map[string][]string{}
+		for _, getter := range members(oldAPI, view) {
+			if _, ok := newAPI[view+"."+getter]; !ok {
+				sig := normalize(oldAPI[view+"."+getter])
+				gone[sig] = append(gone[sig], getter)
+			}
+		}
+		for _, getter := range members(newAPI, view) {
+			if _, ok := oldAPI[view+"."+getter]; !ok {
+				sig := normalize(newAPI[view+"."+getter])
+				fresh[sig] = append(fresh[sig], getter)
+			}
+		}
+		for _, sig := range slices.Sorted(maps.Keys(gone)) {
+			if len(gone[sig]) != 1 || len(fresh[sig]) != 1 {
+				continue
+			}
+			g.shims = append(g.shims, shim{
+				kind:    "getter",
+				old:     gone[sig][0],
+				new:     fresh[sig][0],
+				view:    view,
+				results: strings.TrimPrefix(newAPI[view+"."+fresh[sig][0]], "func() "),
+			})
+		}
+	}
+	return nil
+}
+
+var identPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// members lists the members api describes of the declaration name, sorted.
+func members(api map[string]string, name string) []string {
+	var out []string
+	for key := range api {
+		if member, ok := strings.CutPrefix(key, name+"."); ok {
+			out = append(out, member)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// writeShims writes the shims prepareShims found, each deprecated in favour
+// of the name it stands for.
+func (g *GoGenerator) writeShims(buf *bytes.Buffer) {
+	before := g.Previous.MetaData.Version
+	for _, s := range g.shims {
+		fmt.Fprintf(buf, "// %s is %s as it was named before %s.\n//\n// Deprecated: Use %[2]s; %[1]s will be removed in the next release.\n", s.old, s.new, g.afterVersion(before))
+		switch s.kind {
+		case "type":
+			fmt.Fprintf(buf, "type %s = %s\n\n", s.old, s.new)
+		case "const":
+			fmt.Fprintf(buf, "const %s = %s\n\n", s.old, s.new)
+		case "getter":
+			fmt.Fprintf(buf, "func (v %s) %s() %s {\n\treturn v.%s()\n}\n\n", s.view, s.old, s.results, s.new)
+		}
+	}
+}
+
+// afterVersion names the release Model is, for shims' comments.
+func (g *GoGenerator) afterVersion(before string) string {
+	if v := g.Model.MetaData.Version; v != "" && v != before {
+		return v
+	}
+	return "this release"
+}
@@ -0,0 +1,67 @@
+package metamodel
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// tuple is a Go struct generated for a tuple type whose items differ in
+// type, encoded as a JSON array of fixed length.
+type tuple struct {
+	name  string
+	types []string
+}
+
+// tupleType maps a tuple to a Go array when its items share a type, and
+// otherwise declares a struct named name with a field for each item.
+func (g *GoGenerator) tupleType(name string, t *Type) string {
+	if len(t.Items) > 0 && sameTypes(t.Items) {
+		return fmt.Sprintf("[%d]%s", len(t.Items), g.typeOf(t.Items[0], name+"Item"))
+	}
+	tp := &tuple{name: g.claim(name)}
+	for idx, item := range t.Items {
+		tp.types = append(tp.types, g.typeOf(item, fmt.Sprintf("%sV%d", tp.name, idx)))
+	}
+	g.tuples = append(g.tuples, tp)
+	return tp.name
+}
+
+// sameTypes reports whether items are all the same base type or reference.
+func sameTypes(items []*Type) bool {
+	for _, item := range items {
+		if item.Kind != KindBase && item.Kind != KindReference {
+			return false
+		}
+		if item.Kind != items[0].Kind || item.Name != items[0].Name {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *GoGenerator) writeTuple(buf *bytes.Buffer, tp *tuple) {
+	fmt.Fprintf(buf, "// %s is a JSON array of %d items, one per field.\n", tp.name, len(tp.types))
+	fmt.Fprintf(buf, "type %s struct {\n", tp.name)
+	for idx, goType := range tp.types {
+		fmt.Fprintf(buf, "\tV%d %s\n", idx, goType)
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(buf, "func (t %s) MarshalJSON() ([]byte, error) {\n\treturn json.Marshal([]any{", tp.name)
+	for idx := range tp.types {
+		if idx > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(buf, "t.V%d", idx)
+	}
+	buf.WriteString("})\n}\n\n")
+
+	fmt.Fprintf(buf, "func (t *%s) UnmarshalJSON(data []byte) error {\n", tp.name)
+	buf.WriteString("\tvar items []json.RawMessage\n\tif err := json.Unmarshal(data, &items); err != nil {\n\t\treturn err\n\t}\n")
+	fmt.Fprintf(buf, "\tif len(items) != %d {\n\t\treturn fmt.Errorf(\"%s: want %d items, got %%d\", len(items))\n\t}\n", len(tp.types), tp.name, len(tp.types))
+	fmt.Fprintf(buf, "\t*t = %s{}\n", tp.name)
+	for idx := range tp.types {
+		fmt.Fprintf(buf, "\tif err := json.Unmarshal(items[%d], &t.V%[1]d); err != nil {\n\t\treturn fmt.Errorf(\"%s[%[1]d]: %%w\", err)\n\t}\n", idx, tp.name)
+	}
+	buf.WriteString("\treturn nil\n}\n\n")
+}
@@ -0,0 +1,427 @@
+package metamodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// union is a Go struct generated for an `or` type, with one field per
+// variant and custom JSON methods choosing between them.
+type union struct {
+	name     string
+	variants []*variant
+}
+
+type variant struct {
+	field string
+	// goType is the decode target; the field is a pointer to it unless it
+	// is nilable already.
+	goType string
+	shape  shape
+}
+
+// shape is what a JSON value must look like to decode as a variant.
+type shape struct {
+	// kind is the first byte of the value: '"', '0' for numbers, 't' for
+	// booleans, '[' or '{'; zero when any value may match.
+	kind byte
+	// required are the object keys which must be present.
+	required []string
+	// literals maps object keys to the compact JSON they must hold, from
+	// string, integer or boolean literal properties such as kind: "create".
+	literals map[string]string
+	// value is the compact JSON a literal variant must equal.
+	value string
+}
+
+// typeOf maps a type expression to a Go type, declaring a struct named name
+// for any `or`, literal or tuple it contains.
+func (g *GoGenerator) typeOf(t *Type, name string) string {
+	if t == nil {
+		return "any"
+	}
+	switch t.Kind {
+	case KindOr:
+		inner, nullable := stripNull(t)
+		if nullable && inner.Kind != KindOr {
+			return g.typeOf(inner, name)
+		}
+		return g.declareUnion(g.claim(name), inner)
+	case KindArray:
+		return "[]" + g.typeOf(t.Element, name+"Item")
+	case KindMap:
+		return "map[" + g.keyType(t.Key) + "]" + g.typeOf(t.Value, name+"Value")
+	case KindLiteral:
+		return g.declareLiteral(g.claim(name), t)
+	case KindTuple:
+		return g.tupleType(name, t)
+	}
+	return g.goType(t)
+}
+
+// keyType maps a map's key type to a Go type: a key which is a union, such
+// as one of several string enumerations, is a plain string, as JSON keys
+// are.
+func (g *GoGenerator) keyType(t *Type) string {
+	if t != nil && t.Kind == KindReference {
+		if goType, ok := g.overrideType(t.Name); ok {
+			return goType
+		}
+		if a := g.Model.TypeAlias(t.Name); a != nil {
+			if inner, _ := stripNull(a.Type); inner.Kind == KindOr {
+				return "string"
+			}
+		}
+	}
+	return g.goType(t)
+}
+
+// stripNull removes null from the members of an `or`, reporting whether it
+// was there. A single remaining member is returned by itself.
+func stripNull(t *Type) (*Type, bool) {
+	if t == nil || t.Kind != KindOr {
+		return t, false
+	}
+	var others []*Type
+	for _, item := range t.Items {
+		if item.Kind != KindBase || item.Name != "null" {
+			others = append(others, item)
+		}
+	}
+	if len(others) == len(t.Items) {
+		return t, false
+	}
+	if len(others) == 1 {
+		return others[0], true
+	}
+	return &Type{Kind: KindOr, Items: others}, true
+}
+
+// declareUnion declares the struct for t and returns its name.
+func (g *GoGenerator) declareUnion(name string, t *Type) string {
+	u := &union{name: name}
+	fields := map[string]bool{}
+	for _, item := range t.Items {
+		field := variantName(item)
+		for n := 2; fields[field]; n++ {
+			field = fmt.Sprintf("%s%d", variantName(item), n)
+		}
+		fields[field] = true
+		u.variants = append(u.variants, &variant{
+			field:  field,
+			goType: g.typeOf(item, name+field),
+			shape:  g.shapeOf(item),
+		})
+	}
+	g.unions = append(g.unions, u)
+	return name
+}
+
+// claim reserves a type name, suffixing it if it is already taken.
+func (g *GoGenerator) claim(name string) string {
+	for base, n := name, 2; g.taken[name]; n++ {
+		name = fmt.Sprintf("%s%d", base, n)
+	}
+	g.taken[name] = true
+	return name
+}
+
+func variantName(t *Type) string {
+	switch t.Kind {
+	case KindReference:
+		return exported(t.Name)
+	case KindBase:
+		switch t.Name {
+		case "boolean":
+			return "Bool"
+		case "DocumentUri":
+			return "DocumentURI"
+		}
+		return exported(t.Name)
+	case KindArray:
+		return variantName(t.Element) + "Array"
+	case KindStringLiteral:
+		if name := exported(t.StringValue); name != "" {
+			return name
+		}
+	case KindMap:
+		return "Map"
+	case KindTuple:
+		return "Tuple"
+	case KindAnd:
+		return "Intersection"
+	}
+	return "Literal"
+}
+
+// exported turns a name or literal value into an exported identifier.
+func exported(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	out := b.String()
+	if out != "" && !unicode.IsLetter(rune(out[0])) {
+		out = "V" + out
+	}
+	return out
+}
+
+func (g *GoGenerator) shapeOf(t *Type) shape {
+	switch t.Kind {
+	case KindBase:
+		switch t.Name {
+		case "integer", "uinteger", "decimal":
+			return shape{kind: '0'}
+		case "boolean":
+			return shape{kind: 't'}
+		case "null":
+			return shape{kind: 'n'}
+		}
+		return shape{kind: '"'}
+	case KindReference:
+		switch t.Name {
+		case "LSPAny":
+			return shape{}
+		case "LSPObject":
+			return shape{kind: '{'}
+		case "LSPArray":
+			return shape{kind: '['}
+		}
+		if s := g.Model.Structure(t.Name); s != nil {
+			sh := shape{kind: '{'}
+			addProperties(&sh, g.Model.Properties(s))
+			return sh
+		}
+		if e := g.Model.Enumeration(t.Name); e != nil {
+			return g.shapeOf(e.Type)
+		}
+		if a := g.Model.TypeAlias(t.Name); a != nil {
+			if a.Type.Kind == KindOr {
+				return shape{}
+			}
+			return g.shapeOf(a.Type)
+		}
+	case KindArray, KindTuple:
+		return shape{kind: '['}
+	case KindMap, KindAnd:
+		return shape{kind: '{'}
+	case KindLiteral:
+		sh := shape{kind: '{'}
+		if t.Literal != nil {
+			addProperties(&sh, t.Literal.Properties)
+		}
+		return sh
+	case KindStringLiteral:
+		return shape{kind: '"', value: literalJSON(t)}
+	case KindIntegerLiteral:
+		return shape{kind: '0', value: literalJSON(t)}
+	case KindBooleanLiteral:
+		return shape{kind: 't', value: literalJSON(t)}
+	}
+	return shape{}
+}
+
+// literalJSON is the compact JSON of a literal type's value.
+func literalJSON(t *Type) string {
+	switch t.Kind {
+	case KindStringLiteral:
+		raw, _ := json.Marshal(t.StringValue)
+		return string(raw)
+	case KindIntegerLiteral:
+		return fmt.Sprint(t.IntegerValue)
+	case KindBooleanLiteral:
+		return fmt.Sprint(t.BooleanValue)
+	}
+	return ""
+}
+
+func addProperties(sh *shape, props []*Property) {
+	for _, p := range props {
+		if p.Optional {
+			continue
+		}
+		sh.required = append(sh.required, p.Name)
+		switch p.Type.Kind {
+		case KindStringLiteral, KindIntegerLiteral, KindBooleanLiteral:
+			if sh.literals == nil {
+				sh.literals = map[string]string{}
+			}
+			sh.literals[p.Name] = literalJSON(p.Type)
+		}
+	}
+	sort.Strings(sh.required)
+}
+
+func (g *GoGenerator) writeUnion(buf *bytes.Buffer, u *union) {
+	fmt.Fprintf(buf, "// %s holds one of its fields, chosen by the shape of the JSON value.\n", u.name)
+	fmt.Fprintf(buf, "type %s struct {\n", u.name)
+	for _, v := range u.variants {
+		fmt.Fprintf(buf, "\t%s %s\n", v.field, v.fieldType())
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(buf, "func (u %s) MarshalJSON() ([]byte, error) {\n\tswitch {\n", u.name)
+	for _, v := range u.variants {
+		fmt.Fprintf(buf, "\tcase u.%s != nil:\n\t\treturn json.Marshal(u.%[1]s)\n", v.field)
+	}
+	buf.WriteString("\t}\n\treturn []byte(\"null\"), nil\n}\n\n")
+
+	fmt.Fprintf(buf, "func (u *%s) UnmarshalJSON(data []byte) error {\n", u.name)
+	fmt.Fprintf(buf, "\t*u = %s{}\n\treturn decodeUnion(%q, data, []unionVariant{\n", u.name, u.name)
+	for _, v := range u.variants {
+		buf.WriteString("\t\t{")
+		if v.shape.kind != 0 {
+			fmt.Fprintf(buf, "shape: '%c', ", v.shape.kind)
+		}
+		if len(v.shape.required) > 0 {
+			fmt.Fprintf(buf, "required: %#v, ", v.shape.required)
+		}
+		if len(v.shape.literals) > 0 {
+			fmt.Fprintf(buf, "literals: %#v, ", v.shape.literals)
+		}
+		if v.shape.value != "" {
+			fmt.Fprintf(buf, "value: %q, ", v.shape.value)
+		}
+		assign := "&v"
+		if nilable(v.goType) {
+			assign = "v"
+		}
+		fmt.Fprintf(buf, "decode: func(strict bool) error {\n\t\t\tvar v %s\n", v.goType)
+		buf.WriteString("\t\t\tif err := decodeVariant(data, &v, strict); err != nil {\n\t\t\t\treturn err\n\t\t\t}\n")
+		fmt.Fprintf(buf, "\t\t\tu.%s = %s\n\t\t\treturn nil\n\t\t}},\n", v.field, assign)
+	}
+	buf.WriteString("\t})\n}\n\n")
+}
+
+func (v *variant) fieldType() string {
+	if nilable(v.goType) {
+		return v.goType
+	}
+	return "*" + v.goType
+}
+
+// unionRuntime is emitted once into packages with unions.
+const unionRuntime = `// unionVariant is one member of a union as seen by decodeUnion.
+type unionVariant struct {
+	shape    byte
+	required []string
+	literals map[string]string
+	value    string
+	decode   func(strict bool) error
+}
+
+// decodeUnion decodes data into the first variant whose shape matches,
+// preferring variants which take every field of the value.
+func decodeUnion(name string, data []byte, variants []unionVariant) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	shape := data[0]
+	switch shape {
+	case 't', 'f':
+		shape = 't'
+	case '"', '[', '{':
+	default:
+		shape = '0'
+	}
+	var keys map[string]json.RawMessage
+	if shape == '{' {
+		_ = json.Unmarshal(data, &keys)
+	}
+	var candidates []unionVariant
+	for _, v := range variants {
+		if v.matches(shape, data, keys) {
+			candidates = append(candidates, v)
+		}
+	}
+	for _, strict := range []bool{true, false} {
+		for _, v := range candidates {
+			if v.decode(strict) == nil {
+				return nil
+			}
+		}
+	}
+	if len(data) > 40 {
+		data = append(data[:40:40], "..."...)
+	}
+	return fmt.Errorf("%s: no variant matches %s", name, data)
+}
+
+func (v unionVariant) matches(shape byte, data []byte, keys map[string]json.RawMessage) bool {
+	if v.shape != 0 && v.shape != shape {
+		return false
+	}
+	if v.value != "" && !jsonEqual(data, v.value) {
+		return false
+	}
+	for _, key := range v.required {
+		if _, ok := keys[key]; !ok {
+			return false
+		}
+	}
+	for key, literal := range v.literals {
+		if !jsonEqual(keys[key], literal) {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonEqual(raw []byte, compact string) bool {
+	var buf bytes.Buffer
+	return json.Compact(&buf, raw) == nil && buf.String() == compact
+}
+
+func decodeVariant(data []byte, v any, strict bool) error {
+	if !strict {
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+`
+
+// numbersVariant replaces unionRuntime's decodeVariant when LSPAny numbers
+// aren't float64, decoding them as the connection would; NUMBERS is the
+// jsonrpc2.Numbers.
+const numbersVariant = `func decodeVariant(data []byte, v any, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return NUMBERS.Decode(dec, v)
+}
+`
+
+// unionRuntime is the union decoding helpers with the generator's number
+// handling.
+func (g *GoGenerator) unionRuntime() string {
+	var numbers string
+	switch g.Numbers {
+	case NumbersInt64:
+		numbers = "NumbersInt64"
+	case NumbersJSON:
+		numbers = "NumbersJSON"
+	default:
+		return unionRuntime
+	}
+	rpc := g.rpcPackage()
+	runtime, _, _ := strings.Cut(unionRuntime, "func decodeVariant(")
+	return runtime + strings.ReplaceAll(numbersVariant, "NUMBERS", rpc[strings.LastIndex(rpc, "/")+1:]+"."+numbers)
+}
@@ -0,0 +1,397 @@
+// Package websocket is the subset of RFC 6455 language servers need: the
+// opening handshake on both sides, and a connection carrying one JSON-RPC
+// message per WebSocket message, as browser-based clients frame them.
+// Extensions are not negotiated.
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxMessage bounds the size of a received message, against peers which
+// announce absurd lengths.
+const MaxMessage = 64 << 20
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrProtocol is returned, wrapped, for frames which break RFC 6455.
+var ErrProtocol = errors.New("websocket: protocol error")
+
+// Conn is a WebSocket connection. ReadMessage and WriteMessage exchange
+// whole messages, so jsonrpc2 uses it without further framing; Read and
+// Write expose the same messages as a stream, each Write being one message.
+// Pings are answered as they are read.
+type Conn struct {
+	net.Conn
+	br *bufio.Reader
+	// client masks the frames it sends and expects unmasked ones back; a
+	// server does the opposite.
+	client bool
+
+	// readTimeout, when set, is the deadline for each frame to arrive.
+	readTimeout time.Duration
+	unread      []byte
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closeSent bool
+}
+
+// Upgrade completes the opening handshake of an HTTP request, hijacking its
+// connection. On failure it has replied with an HTTP error. wrap, when set,
+// wraps the hijacked connection, e.g. for keepalive.
+func Upgrade(w http.ResponseWriter, r *http.Request, wrap func(net.Conn) net.Conn) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case r.Method != http.MethodGet:
+		http.Error(w, "websocket: the handshake must use GET", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("%w: handshake with method %s", ErrProtocol, r.Method)
+	case !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket"):
+		http.Error(w, "websocket: not a WebSocket handshake", http.StatusBadRequest)
+		return nil, fmt.Errorf("%w: not a WebSocket handshake", ErrProtocol)
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "websocket: unsupported version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("%w: version %q", ErrProtocol, r.Header.Get("Sec-WebSocket-Version"))
+	case key == "":
+		http.Error(w, "websocket: missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("%w: missing Sec-WebSocket-Key", ErrProtocol)
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket: the connection can't be hijacked", http.StatusInternalServerError)
+		return nil, errors.New("websocket: the response writer can't hijack its connection")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: hijacking: %w", err)
+	}
+	if wrap != nil {
+		conn = wrap(conn)
+	}
+	// The client may have sent its first frames with the handshake.
+	buffered, _ := rw.Reader.Peek(rw.Reader.Buffered())
+	br := bufio.NewReader(io.MultiReader(bytes.NewReader(bytes.Clone(buffered)), conn))
+	_, err = fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", accept(key))
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("websocket: handshake: %w", err)
+	}
+	return &Conn{Conn: conn, br: br}, nil
+}
+
+// Dial opens a WebSocket connection to a ws:// or wss:// URL, sending
+// header with the handshake. cfg configures TLS for wss; nil uses the
+// system roots.
+func Dial(ctx context.Context, u *url.URL, header http.Header, cfg *tls.Config) (*Conn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "wss" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	var d net.Dialer
+	var conn net.Conn
+	var err error
+	switch u.Scheme {
+	case "ws":
+		conn, err = d.DialContext(ctx, "tcp", host)
+	case "wss":
+		td := tls.Dialer{NetDialer: &d, Config: cfg}
+		conn, err = td.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: http.Header{}}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("websocket: handshake: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("websocket: handshake: %w", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		_ = conn.Close()
+		return nil, fmt.Errorf("websocket: handshake: %s", res.Status)
+	}
+	if res.Header.Get("Sec-WebSocket-Accept") != accept(key) {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: wrong Sec-WebSocket-Accept", ErrProtocol)
+	}
+	return &Conn{Conn: conn, br: br, client: true}, nil
+}
+
+func accept(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHas reports whether a comma-separated header lists token.
+func headerHas(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetReadTimeout makes reads fail unless each frame arrives within d of
+// the read starting; zero disables it. Pongs count, so a peer answering
+// pings sent more often than d stays alive. Set it before reading.
+func (c *Conn) SetReadTimeout(d time.Duration) {
+	c.readTimeout = d
+}
+
+// ReadMessage returns the next text or binary message. A close frame is
+// answered and reported as io.EOF.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	fragmented := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = c.writeClose(payload)
+			return nil, io.EOF
+		case opText, opBinary:
+			if fragmented {
+				return nil, fmt.Errorf("%w: new message inside a fragmented one", ErrProtocol)
+			}
+		case opContinuation:
+			if !fragmented {
+				return nil, fmt.Errorf("%w: continuation without a message", ErrProtocol)
+			}
+		default:
+			return nil, fmt.Errorf("%w: opcode %#x", ErrProtocol, op)
+		}
+		if len(msg)+len(payload) > MaxMessage {
+			return nil, fmt.Errorf("%w: message over %d bytes", ErrProtocol, MaxMessage)
+		}
+		msg = append(msg, payload...)
+		if fin {
+			if msg == nil {
+				msg = []byte{}
+			}
+			return msg, nil
+		}
+		fragmented = true
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	if c.readTimeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op = head[0]&0x80 != 0, head[0]&0x0f
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: reserved bits set", ErrProtocol)
+	}
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		return false, 0, nil, fmt.Errorf("%w: frame masking is wrong for its direction", ErrProtocol)
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, fmt.Errorf("%w: control frame fragmented or over 125 bytes", ErrProtocol)
+	}
+	if length > MaxMessage {
+		return false, 0, nil, fmt.Errorf("%w: frame over %d bytes", ErrProtocol, MaxMessage)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// WriteMessage sends body as one text message.
+func (c *Conn) WriteMessage(body []byte) error {
+	return c.writeFrame(opText, body)
+}
+
+// Ping sends a ping, which the peer answers with a pong.
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	return c.writeFrameLocked(op, payload)
+}
+
+func (c *Conn) writeFrameLocked(op byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|op)
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if !c.client {
+		frame = append(frame, payload...)
+	} else {
+		var mask [4]byte
+		_, _ = rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// writeClose sends a close frame, once, echoing the status code of the
+// peer's close frame if answering one.
+func (c *Conn) writeClose(payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+	status := []byte{0x03, 0xe8} // 1000, normal closure
+	if len(payload) >= 2 {
+		status = payload[:2]
+	}
+	_ = c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+	return c.writeFrameLocked(opClose, status)
+}
+
+// Read reads from the current message, reading the next once it is used
+// up, so messages arrive back to back.
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.unread) == 0 {
+		msg, err := c.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		c.unread = msg
+	}
+	n := copy(p, c.unread)
+	c.unread = c.unread[n:]
+	return n, nil
+}
+
+// Write sends p as one message.
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.WriteMessage(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close sends a close frame and closes the connection, without waiting for
+// the peer's answer.
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		_ = c.writeClose(nil)
+		err = c.Conn.Close()
+	})
+	return err
+}
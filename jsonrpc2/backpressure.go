@@ -0,0 +1,164 @@
+package jsonrpc2
+
+import (
+	"sync"
+)
+
+// Backpressure configures slow-consumer handling. When the number of
+// outbound messages waiting to be written reaches HighWater the peer is
+// considered slow, and deferrable notifications are parked instead of
+// written; once the queue falls to LowWater they are flushed in order.
+type Backpressure struct {
+	// HighWater is the queue depth marking the peer slow, 32 when zero.
+	HighWater int
+
+	// LowWater is the depth at which parked notifications are released,
+	// HighWater/4 when zero.
+	LowWater int
+
+	// Deferrable selects the non-essential notifications which may be
+	// delayed, e.g. logs and diagnostics for documents nobody is looking at.
+	// Requests, responses and other notifications are never delayed.
+	Deferrable func(method string, params any) bool
+
+	// Coalesce, when set, returns a key under which a parked notification
+	// replaces an earlier parked one, e.g. the URI of publishDiagnostics.
+	// An empty key never coalesces.
+	Coalesce func(method string, params any) string
+
+	// MaxParked caps the parked notifications; the oldest are dropped beyond
+	// it. 1000 when zero.
+	MaxParked int
+
+	// OnChange is told when the peer becomes slow and when it recovers.
+	OnChange func(slow bool)
+}
+
+type parked struct {
+	key string
+	msg *wireMessage
+}
+
+type backpressure struct {
+	cfg Backpressure
+
+	mu       sync.Mutex
+	depth    int
+	slow     bool
+	flushing bool
+	parked   []parked
+}
+
+func newBackpressure(cfg *Backpressure) *backpressure {
+	if cfg == nil {
+		return &backpressure{}
+	}
+	bp := &backpressure{cfg: *cfg}
+	if bp.cfg.HighWater <= 0 {
+		bp.cfg.HighWater = 32
+	}
+	if bp.cfg.LowWater <= 0 {
+		bp.cfg.LowWater = bp.cfg.HighWater / 4
+	}
+	if bp.cfg.MaxParked <= 0 {
+		bp.cfg.MaxParked = 1000
+	}
+	return bp
+}
+
+// enter records a message joining the write queue.
+func (bp *backpressure) enter() {
+	bp.mu.Lock()
+	bp.depth++
+	becameSlow := bp.cfg.Deferrable != nil && !bp.slow && bp.depth >= bp.cfg.HighWater
+	if becameSlow {
+		bp.slow = true
+	}
+	bp.mu.Unlock()
+	if becameSlow && bp.cfg.OnChange != nil {
+		bp.cfg.OnChange(true)
+	}
+}
+
+// leave records a message leaving the write queue. It reports true to the
+// one caller which should then drain the parked messages with next.
+func (bp *backpressure) leave() bool {
+	bp.mu.Lock()
+	bp.depth--
+	recovered := bp.slow && bp.depth <= bp.cfg.LowWater
+	drain := recovered && !bp.flushing && len(bp.parked) > 0
+	if recovered {
+		bp.slow = false
+	}
+	if drain {
+		bp.flushing = true
+	}
+	bp.mu.Unlock()
+	if recovered && bp.cfg.OnChange != nil {
+		bp.cfg.OnChange(false)
+	}
+	return drain
+}
+
+// next pops the oldest parked message, ending the flush when none remain.
+// Notifications sent during a flush are parked behind the ones being
+// flushed, so a newer message can never be overtaken by an older one.
+func (bp *backpressure) next() *wireMessage {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if len(bp.parked) == 0 || bp.slow {
+		bp.flushing = false
+		return nil
+	}
+	msg := bp.parked[0].msg
+	bp.parked = bp.parked[1:]
+	return msg
+}
+
+// park holds back a deferrable notification while the peer is slow. It
+// reports false when the message should be written now.
+func (bp *backpressure) park(msg *wireMessage, params any) bool {
+	if bp.cfg.Deferrable == nil {
+		return false
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if !(bp.slow || bp.flushing) || !bp.cfg.Deferrable(msg.Method, params) {
+		return false
+	}
+	key := ""
+	if bp.cfg.Coalesce != nil {
+		key = bp.cfg.Coalesce(msg.Method, params)
+	}
+	if key != "" {
+		for idx, p := range bp.parked {
+			if p.key == key {
+				bp.parked = append(bp.parked[:idx], bp.parked[idx+1:]...)
+				break
+			}
+		}
+	}
+	bp.parked = append(bp.parked, parked{key: key, msg: msg})
+	if len(bp.parked) > bp.cfg.MaxParked {
+		bp.parked = bp.parked[len(bp.parked)-bp.cfg.MaxParked:]
+	}
+	return true
+}
+
+func (bp *backpressure) state() (depth int, slow bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.depth, bp.slow
+}
+
+// QueueDepth is the number of outbound messages waiting to be written.
+func (c *Conn) QueueDepth() int {
+	depth, _ := c.bp.state()
+	return depth
+}
+
+// Slow reports whether the peer is currently considered a slow consumer.
+func (c *Conn) Slow() bool {
+	_, slow := c.bp.state()
+	return slow
+}
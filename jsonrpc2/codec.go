@@ -0,0 +1,183 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/pentops/lsplib/framing"
+)
+
+// Codec encodes the params and results of outbound messages. The
+// connection writes each message's envelope itself and has the Codec
+// encode the values into it, straight into the buffer the framing writes
+// when it is a framing.BufferWriter, as framing.Header and framing.Line
+// are, so a large result, such as tens of thousands of workspace symbols,
+// is encoded once rather than marshalled apart and copied in twice.
+// Decoding is Options.Numbers'.
+type Codec interface {
+	// Encode appends the JSON of v to buf. It must only append, and on
+	// failure the connection drops what it appended.
+	Encode(buf *bytes.Buffer, v any) error
+}
+
+// JSONCodec is the Codec of encoding/json, the one used when
+// Options.Codec is nil. It encodes as json.Marshal does.
+type JSONCodec struct {
+	// Passthrough writes json.RawMessage values as they are, rather than
+	// having encoding/json check and compact them, for proxies forwarding
+	// params and results they don't decode. Their bytes must then be valid
+	// JSON and, over framing.Line, hold no newline.
+	Passthrough bool
+}
+
+func (c JSONCodec) Encode(buf *bytes.Buffer, v any) error {
+	if c.Passthrough {
+		if raw, ok := rawMessage(v); ok {
+			if len(raw) == 0 {
+				raw = json.RawMessage("null")
+			}
+			buf.Write(raw)
+			return nil
+		}
+	}
+	// An Encoder writes what it encodes from a buffer of its pool, where
+	// Marshal would return a copy of it.
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return err
+	}
+	// It ends the value with a newline.
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+func rawMessage(v any) (json.RawMessage, bool) {
+	switch raw := v.(type) {
+	case json.RawMessage:
+		return raw, true
+	case *json.RawMessage:
+		if raw != nil {
+			return *raw, true
+		}
+	}
+	return nil, false
+}
+
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooled is the largest buffer kept for reuse, as framing's.
+const maxPooled = 4 << 20
+
+func getBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooled {
+		return
+	}
+	buf.Reset()
+	buffers.Put(buf)
+}
+
+// marshal encodes v with the Codec, for values the connection keeps, such
+// as a call's params.
+func (c *Conn) marshal(v any) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := c.codec.Encode(buf, v); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// encode is msg as JSON, for writes which need it whole, as to trace or
+// rewrite it.
+func (c *Conn) encode(msg *wireMessage) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := c.encodeTo(buf, msg); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// encodeTo appends msg to buf as json.Marshal would, its result, when not
+// yet encoded, encoded in place by the Codec. A result which fails to
+// encode is answered with CodeInternalError instead.
+func (c *Conn) encodeTo(buf *bytes.Buffer, msg *wireMessage) error {
+	start := buf.Len()
+	buf.WriteString(`{"jsonrpc":"`)
+	buf.WriteString(Version)
+	buf.WriteByte('"')
+	if len(msg.ID) > 0 {
+		buf.WriteString(`,"id":`)
+		buf.Write(msg.ID)
+	}
+	if msg.Method != "" {
+		method, err := json.Marshal(msg.Method)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`,"method":`)
+		buf.Write(method)
+	}
+	if len(msg.Params) > 0 {
+		buf.WriteString(`,"params":`)
+		buf.Write(msg.Params)
+	}
+	switch {
+	case msg.Error != nil:
+		rpcErr, err := json.Marshal(msg.Error)
+		if err != nil {
+			buf.Truncate(start)
+			return err
+		}
+		buf.WriteString(`,"error":`)
+		buf.Write(rpcErr)
+	case msg.result != nil:
+		buf.WriteString(`,"result":`)
+		if err := c.codec.Encode(buf, msg.result); err != nil {
+			buf.Truncate(start)
+			failed := *msg
+			failed.result, failed.Error = nil, NewError(CodeInternalError, "encoding result: %v", err)
+			return c.encodeTo(buf, &failed)
+		}
+	case len(msg.Result) > 0:
+		buf.WriteString(`,"result":`)
+		buf.Write(msg.Result)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeEncoded writes msg, encoding it into the writer's own buffer when
+// it is a framing.BufferWriter.
+func (c *Conn) writeEncoded(msg *wireMessage) error {
+	var encodeErr error
+	build := func(buf *bytes.Buffer) error {
+		start := buf.Len()
+		if encodeErr = c.encodeTo(buf, msg); encodeErr != nil {
+			return encodeErr
+		}
+		if c.instrument != nil {
+			c.instrument.Wrote(buf.Len() - start)
+		}
+		return nil
+	}
+	var err error
+	if w, ok := c.writer.(framing.BufferWriter); ok {
+		err = w.WriteBuffer(build)
+	} else {
+		buf := getBuffer()
+		defer putBuffer(buf)
+		if err := build(buf); err != nil {
+			return err
+		}
+		err = c.writer.WriteMessage(buf.Bytes())
+	}
+	if err != nil && err != encodeErr {
+		return &TransportError{Op: "write", Err: err}
+	}
+	return err
+}
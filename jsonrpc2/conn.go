@@ -0,0 +1,997 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/framing"
+)
+
+// ErrClosed is returned by calls made on, or interrupted by, a closed
+// connection, and is the context cause of handlers still running when it
+// closes. It is a *TransportError.
+var ErrClosed error = &TransportError{Err: errors.New("jsonrpc2: connection closed")}
+
+// ErrTooManyMalformed is the error Run returns, wrapping the last one,
+// when the peer sends more malformed messages than Options.ErrorBudget
+// allows: a protocol failure rather than the stream ending. It is a
+// *ProtocolError.
+var ErrTooManyMalformed error = &ProtocolError{Err: errors.New("jsonrpc2: too many malformed messages")}
+
+// Options configures a Conn. The zero value is ready to use.
+type Options struct {
+	// Framer selects the message framing. When nil, a stream which carries
+	// discrete messages itself, implementing framing.Reader and
+	// framing.Writer as a WebSocket does, is used unframed; any other gets
+	// framing.Header.
+	Framer framing.Framer
+
+	// Backpressure enables slow-consumer handling for outbound
+	// notifications.
+	Backpressure *Backpressure
+
+	// ErrorBudget is the number of consecutive malformed messages tolerated
+	// before the connection is dropped, 10 when zero. Negative drops the
+	// connection on the first one.
+	ErrorBudget int
+
+	// OnProtocolError is told about each malformed message, and about each
+	// request reusing the ID of one still in flight, as *DuplicateIDError.
+	OnProtocolError func(err error)
+
+	// MaxMessageSize bounds the size of a message read, in bytes. A larger
+	// one is skipped, without being held in memory with framing.Header or
+	// framing.Line, answered with CodeInvalidRequest when it was a request
+	// whose ID survives in its first bytes, and counted as malformed. No
+	// limit when zero.
+	MaxMessageSize int
+
+	// Rewrite transforms messages as they cross the connection.
+	Rewrite Rewrite
+
+	// CancelMethod is the notification, with params {"id": ...}, by which a
+	// peer cancels a request: the handler's context is cancelled with cause
+	// ErrCancelled and an error it returns is answered with
+	// CodeRequestCancelled. Call sends it when its context is done. None
+	// when empty; LSP's is "$/cancelRequest".
+	CancelMethod string
+
+	// Scheduler, when set, decides when each inbound message is handled,
+	// in place of Run's default order.
+	Scheduler Scheduler
+
+	// Tracer, when set, traces every message read and written.
+	Tracer *Tracer
+
+	// Overload, when set, bounds the inbound requests waiting to be
+	// handled, and with a Supersede key drops stale ones while they back
+	// up.
+	Overload *Overload
+
+	// Numbers is how params and results decode numbers held in any-typed
+	// values, as float64 by default.
+	Numbers Numbers
+
+	// Codec encodes outbound params and results, JSONCodec{} when nil.
+	Codec Codec
+
+	// Document, when set, names the document a request is about, for
+	// Pending; lsp.Document reads LSP's.
+	Document func(req *Request) string
+
+	// Clock times requests for Pending, clock.Real when nil.
+	Clock clock.Clock
+
+	// Instrument, when set, is told about every message, for metrics and
+	// tracing.
+	Instrument Instrument
+}
+
+// Scheduler orders the handling of inbound messages, so that some may run
+// concurrently while others wait, as lsp.Scheduler serialises document
+// changes per document. Admit is called for each message in arrival order,
+// from the goroutine dispatching them, and must not block. The message is
+// handled on its own goroutine once ready is closed, and done is called
+// when its handler returns, or when a request is cancelled, or dropped by
+// Options.Overload, before it was ready, so it never runs.
+type Scheduler interface {
+	Admit(req *Request) (ready <-chan struct{}, done func())
+}
+
+// ErrCancelled is the context cause of a handler whose request the peer
+// cancelled, and the class of CancelledError.
+var ErrCancelled = errors.New("jsonrpc2: request cancelled by peer")
+
+// Rewrite transforms raw messages: In each one read, before it is decoded,
+// and Out each one about to be written. A function which fails leaves the
+// message as it was. pathmap uses it to translate file paths.
+type Rewrite struct {
+	In, Out func(msg []byte) ([]byte, error)
+}
+
+func rewrite(fn func([]byte) ([]byte, error), msg []byte) []byte {
+	if fn == nil {
+		return msg
+	}
+	if out, err := fn(msg); err == nil {
+		return out
+	}
+	return msg
+}
+
+// messageStream is a stream made of discrete messages.
+type messageStream interface {
+	framing.Reader
+	framing.Writer
+}
+
+// unframed reads and writes a messageStream's messages as they are.
+type unframed struct{}
+
+func (unframed) NewReader(r io.Reader) framing.Reader { return r.(messageStream) }
+func (unframed) NewWriter(w io.Writer) framing.Writer { return w.(messageStream) }
+
+// Conn is a bidirectional JSON-RPC connection: it serves inbound requests
+// through a Handler and issues outbound requests with Call and Notify.
+type Conn struct {
+	stream io.ReadWriter
+	reader framing.Reader
+	// values is the stream when it carries messages as values.
+	values valueStream
+
+	errorBudget     int
+	onProtocolError func(error)
+	rewrite         Rewrite
+	cancelMethod    string
+	scheduler       Scheduler
+	tracer          *Tracer
+	overload        *Overload
+	numbers         Numbers
+	codec           Codec
+	document        func(req *Request) string
+	clock           clock.Clock
+	instrument      Instrument
+
+	writeMu sync.Mutex
+	writer  framing.Writer
+	bp      *backpressure
+
+	inflightMu sync.Mutex
+	inflight   map[ID]*inflight
+
+	seq       atomic.Int64
+	pendingMu sync.Mutex
+	pending   map[ID]*outbound
+
+	// busy counts the messages read and not yet handled, requests until
+	// answered, for Drain; idle is closed while it is zero.
+	busyMu sync.Mutex
+	busy   int
+	idle   chan struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConn wraps a stream. If the stream is also an io.Closer it is closed by
+// Close.
+func NewConn(stream io.ReadWriter, opts Options) *Conn {
+	framer := opts.Framer
+	if framer == nil {
+		framer = framing.Header
+		if _, ok := stream.(messageStream); ok {
+			framer = unframed{}
+		}
+	}
+	framer = framing.Limit(framer, opts.MaxMessageSize)
+	budget := opts.ErrorBudget
+	if budget == 0 {
+		budget = 10
+	}
+	var values valueStream
+	if vs, ok := stream.(valueStream); ok && opts.Framer == nil && vs.carriesValues() {
+		values = vs
+	}
+	codec := opts.Codec
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &Conn{
+		values:          values,
+		stream:          stream,
+		reader:          framer.NewReader(stream),
+		errorBudget:     max(budget, 0),
+		onProtocolError: opts.OnProtocolError,
+		rewrite:         opts.Rewrite,
+		cancelMethod:    opts.CancelMethod,
+		scheduler:       opts.Scheduler,
+		tracer:          opts.Tracer,
+		overload:        normalizeOverload(opts.Overload),
+		numbers:         opts.Numbers,
+		codec:           codec,
+		document:        opts.Document,
+		clock:           clock.Or(opts.Clock),
+		instrument:      opts.Instrument,
+		writer:          framer.NewWriter(stream),
+		bp:              newBackpressure(opts.Backpressure),
+		pending:         map[ID]*outbound{},
+		inflight:        map[ID]*inflight{},
+		done:            make(chan struct{}),
+	}
+}
+
+// Run reads and dispatches messages until the stream ends or ctx is done.
+// Notifications are handled one at a time in arrival order, so handlers see
+// e.g. document changes in sequence; requests are each handled on their own
+// goroutine once every earlier message has been dispatched. A Scheduler in
+// Options replaces that order with its own. Responses to
+// outbound calls are routed independently of both, so any handler may Call
+// the peer. Cancellations through Options.CancelMethod also bypass the
+// queue, taking effect as soon as they are read.
+//
+// Malformed messages, and those past Options.MaxMessageSize, are answered
+// with CodeParseError or CodeInvalidRequest and skipped, until
+// Options.ErrorBudget is exhausted. Run returns nil when
+// the peer closes the stream cleanly, ErrClosed after Close, and
+// ErrTooManyMalformed or the stream's error, as a *TransportError,
+// otherwise. Either way, the
+// contexts of handlers still running are cancelled as it returns, with
+// cause ErrClosed unless ctx was done first.
+func (c *Conn) Run(ctx context.Context, h Handler) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	q := &queue{wake: make(chan struct{}, 1), overload: c.overload, scheduled: c.scheduler != nil && c.overload != nil}
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		c.dispatch(ctx, h, q)
+	}()
+
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- c.readLoop(q)
+	}()
+
+	var err error
+	select {
+	case err = <-readErr:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-c.done:
+		err = ErrClosed
+	}
+
+	q.close()
+	cancel(ErrClosed)
+	<-dispatched
+	c.shutdown()
+
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// Drain waits until every message read so far has been handled and every
+// request among them answered, failing if ctx is done or the connection
+// shuts down first. Messages read meanwhile are waited for too, so a caller
+// wanting the connection quiet must first stop what it reads.
+func (c *Conn) Drain(ctx context.Context) error {
+	c.busyMu.Lock()
+	idle := c.idle
+	if c.busy == 0 {
+		idle = nil
+	}
+	c.busyMu.Unlock()
+	if idle == nil {
+		return nil
+	}
+	select {
+	case <-idle:
+		return c.Drain(ctx)
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return ErrClosed
+	}
+}
+
+func (c *Conn) enter() {
+	c.busyMu.Lock()
+	defer c.busyMu.Unlock()
+	if c.busy == 0 {
+		c.idle = make(chan struct{})
+	}
+	c.busy++
+}
+
+func (c *Conn) leave() {
+	c.busyMu.Lock()
+	defer c.busyMu.Unlock()
+	c.busy--
+	if c.busy == 0 {
+		close(c.idle)
+	}
+}
+
+// Done is closed once the connection has shut down.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close shuts the connection down, failing outstanding calls.
+func (c *Conn) Close() error {
+	c.shutdown()
+	if closer, ok := c.stream.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *Conn) shutdown() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.pendingMu.Lock()
+		for id, call := range c.pending {
+			close(call.ch)
+			delete(c.pending, id)
+		}
+		c.pendingMu.Unlock()
+	})
+}
+
+func (c *Conn) readLoop(q *queue) error {
+	failures := 0
+	fail := func(err error) error {
+		failures++
+		if c.onProtocolError != nil {
+			c.onProtocolError(err)
+		}
+		if failures > c.errorBudget {
+			return fmt.Errorf("%w: %w", ErrTooManyMalformed, err)
+		}
+		return nil
+	}
+	for {
+		body, msg, err := c.read()
+		var frameErr *framing.FrameError
+		if errors.As(err, &frameErr) {
+			if frameErr.Prefix != nil {
+				c.refuseOversized(frameErr)
+			}
+			if err := fail(&ProtocolError{Err: err}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return &TransportError{Op: "read", Err: err}
+		}
+		if msg == nil {
+			if c.instrument != nil {
+				c.instrument.Read(len(body))
+			}
+			if c.tracer != nil {
+				c.tracer.Received(body)
+			}
+			body = rewrite(c.rewrite.In, body)
+			msg = &wireMessage{}
+			if err := json.Unmarshal(body, msg); err != nil {
+				_ = c.reply(recoverID(body), nil, NewError(CodeParseError, "parse error: %v", err))
+				if err := fail(&ProtocolError{Err: fmt.Errorf("jsonrpc2: decoding message: %w", err)}); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !msg.isResponse() && msg.Method == "" {
+			_ = c.reply(recoverID(body), nil, NewError(CodeInvalidRequest, "message is neither a request nor a response"))
+			if err := fail(&ProtocolError{Err: errors.New("jsonrpc2: message is neither a request nor a response")}); err != nil {
+				return err
+			}
+			continue
+		}
+		failures = 0
+		switch {
+		case msg.isResponse():
+			c.pendingMu.Lock()
+			call, ok := c.pending[idKey(msg.ID)]
+			delete(c.pending, idKey(msg.ID))
+			c.pendingMu.Unlock()
+			if ok {
+				call.ch <- msg
+			}
+		case msg.ID == nil && msg.Method == c.cancelMethod && c.cancelMethod != "":
+			var params struct {
+				ID json.RawMessage `json:"id"`
+			}
+			if json.Unmarshal(msg.Params, &params) == nil && validID(params.ID) && c.cancelInflight(params.ID) {
+				// Still queued: answer it now rather than after the
+				// requests ahead of it.
+				if req := q.remove(params.ID); req != nil {
+					c.refuseCancelled(req)
+				}
+			}
+		case msg.ID != nil && !c.track(msg):
+			dupErr := &DuplicateIDError{ID: msg.ID, Method: msg.Method}
+			if c.onProtocolError != nil {
+				c.onProtocolError(dupErr)
+			}
+			_ = c.reply(msg.ID, nil, NewError(CodeInvalidRequest, "request id %s is already in use", msg.ID))
+		default:
+			c.enter()
+			req := &Request{ID: msg.ID, Method: msg.Method, Params: msg.Params, value: msg.params, numbers: c.numbers}
+			dropped, ok := q.push(req)
+			for _, stale := range dropped {
+				c.drop(stale)
+			}
+			if !ok {
+				c.drop(req)
+			}
+		}
+	}
+}
+
+// read returns the next message from the peer: as JSON, or as a value when
+// it came over a DirectPipe and needn't be traced or rewritten.
+func (c *Conn) read() ([]byte, *wireMessage, error) {
+	if c.values == nil {
+		body, err := c.reader.ReadMessage()
+		return body, nil, err
+	}
+	body, msg, err := c.values.readValue()
+	if msg == nil || (c.tracer == nil && c.rewrite.In == nil) {
+		return body, msg, err
+	}
+	body, err = msg.encoded()
+	return body, nil, err
+}
+
+// direct reports whether messages are written as values, over a
+// DirectPipe with nothing to trace or rewrite them.
+func (c *Conn) direct() bool {
+	return c.values != nil && c.tracer == nil && c.rewrite.Out == nil
+}
+
+// DuplicateIDError reports a request whose ID matches one still being
+// handled. The duplicate is refused with CodeInvalidRequest; the original
+// carries on.
+type DuplicateIDError struct {
+	ID     json.RawMessage
+	Method string
+}
+
+func (e *DuplicateIDError) Error() string {
+	return fmt.Sprintf("jsonrpc2: duplicate request id %s for %s", e.ID, e.Method)
+}
+
+// Is makes a DuplicateIDError of the class ErrProtocol.
+func (e *DuplicateIDError) Is(target error) bool { return target == ErrProtocol }
+
+// inflight is an inbound request which has not been answered yet.
+type inflight struct {
+	method  string
+	params  json.RawMessage
+	started time.Time
+	// cancel is set once the request is dispatched; cancelled records a
+	// cancellation which arrived before that.
+	cancel    context.CancelCauseFunc
+	cancelled bool
+}
+
+// track records an inbound request as in flight, reporting false if its ID
+// already is.
+func (c *Conn) track(msg *wireMessage) bool {
+	key := idKey(msg.ID)
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	if _, dup := c.inflight[key]; dup {
+		return false
+	}
+	c.inflight[key] = &inflight{method: msg.Method, params: msg.Params, started: c.clock.Now()}
+	return true
+}
+
+// started gives a dispatched request its context, cancelling it at once if
+// the peer already has.
+func (c *Conn) started(id json.RawMessage, cancel context.CancelCauseFunc) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	if req, ok := c.inflight[idKey(id)]; ok {
+		req.cancel = cancel
+		if req.cancelled {
+			cancel(ErrCancelled)
+		}
+	}
+}
+
+// cancelInflight cancels the request with the given ID, if it is still in
+// flight, reporting whether it has yet to be dispatched.
+func (c *Conn) cancelInflight(id json.RawMessage) bool {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	req, ok := c.inflight[idKey(id)]
+	if !ok {
+		return false
+	}
+	req.cancelled = true
+	if req.cancel != nil {
+		req.cancel(ErrCancelled)
+		return false
+	}
+	return true
+}
+
+// cancelledEarly reports whether the peer cancelled a request before it
+// was dispatched.
+func (c *Conn) cancelledEarly(id json.RawMessage) bool {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	req, ok := c.inflight[idKey(id)]
+	return ok && req.cancelled
+}
+
+// refuseCancelled answers a request cancelled before it was dispatched,
+// which its handler never sees.
+func (c *Conn) refuseCancelled(req *Request) {
+	c.untrack(req.ID)
+	_ = c.reply(req.ID, nil, NewError(CodeRequestCancelled, "request cancelled"))
+	c.leave()
+}
+
+// untrack forgets an inbound request. It comes before the reply: the peer
+// may reuse the ID as soon as it has the response, and a request reusing
+// it read before untrack would be refused as a duplicate.
+func (c *Conn) untrack(id json.RawMessage) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	delete(c.inflight, idKey(id))
+}
+
+var idPattern = regexp.MustCompile(`"id"\s*:\s*(-?\d+|"(?:[^"\\]|\\.)*")`)
+
+var methodPattern = regexp.MustCompile(`"method"\s*:`)
+
+// refuseOversized answers a request skipped for its size with
+// CodeInvalidRequest, or fails the call a skipped response was for with
+// CodeInternalError, so its caller doesn't wait on it forever.
+func (c *Conn) refuseOversized(frameErr *framing.FrameError) {
+	id := recoverID(frameErr.Prefix)
+	if string(id) == "null" {
+		return
+	}
+	if methodPattern.Match(frameErr.Prefix) {
+		_ = c.reply(id, nil, NewError(CodeInvalidRequest, "%s", frameErr.Msg))
+		return
+	}
+	c.pendingMu.Lock()
+	call, ok := c.pending[idKey(id)]
+	delete(c.pending, idKey(id))
+	c.pendingMu.Unlock()
+	if ok {
+		call.ch <- &wireMessage{ID: id, Error: NewError(CodeInternalError, "response %s", frameErr.Msg)}
+	}
+}
+
+// recoverID finds the id of a message which failed to decode, so the error
+// response can be correlated. It is null when none can be found, as the spec
+// requires.
+func recoverID(body []byte) json.RawMessage {
+	var probe struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(body, &probe) == nil && validID(probe.ID) {
+		return probe.ID
+	}
+	if match := idPattern.FindSubmatch(body); match != nil && validID(match[1]) {
+		return json.RawMessage(match[1])
+	}
+	return json.RawMessage("null")
+}
+
+func validID(raw json.RawMessage) bool {
+	_, err := ParseID(raw)
+	return err == nil
+}
+
+func (c *Conn) dispatch(ctx context.Context, h Handler, q *queue) {
+	for {
+		req, ok := q.pop()
+		if !ok {
+			return
+		}
+		if !req.IsNotification() && c.cancelledEarly(req.ID) {
+			if q.take(req) {
+				c.refuseCancelled(req)
+			}
+			continue
+		}
+		reqCtx := withRequest(ctx, c, req)
+		var ready <-chan struct{}
+		done := func() {}
+		if c.scheduler != nil {
+			ready, done = c.scheduler.Admit(req)
+		}
+		if req.IsNotification() {
+			if c.scheduler == nil {
+				c.handleNotification(reqCtx, h, req)
+				c.leave()
+				continue
+			}
+			go func() {
+				defer c.leave()
+				defer done()
+				select {
+				case <-ready:
+					c.handleNotification(reqCtx, h, req)
+				case <-ctx.Done():
+				}
+			}()
+			continue
+		}
+		if ready == nil && !q.take(req) {
+			// Superseded since it was popped, and answered.
+			done()
+			continue
+		}
+		reqCtx, cancel := context.WithCancelCause(reqCtx)
+		c.started(req.ID, cancel)
+		if ready != nil {
+			q.wait(req, cancel)
+		}
+		go func() {
+			defer cancel(nil)
+			if ready != nil {
+				select {
+				case <-ready:
+				case <-reqCtx.Done():
+				}
+				if !q.take(req) {
+					// Superseded while it waited, and answered.
+					done()
+					return
+				}
+				if reqCtx.Err() != nil {
+					done()
+					c.refuseCancelled(req)
+					return
+				}
+			}
+			defer c.leave()
+			defer done()
+			handlerCtx, end := c.start(reqCtx, req)
+			result, err := h.Handle(handlerCtx, req)
+			var rpcErr *Error
+			if err != nil && !errors.As(err, &rpcErr) && errors.Is(context.Cause(reqCtx), ErrCancelled) {
+				err = NewError(CodeRequestCancelled, "request cancelled")
+			}
+			if err != nil {
+				end(toError(err))
+			} else {
+				end(nil)
+			}
+			c.untrack(req.ID)
+			_ = c.reply(req.ID, result, err)
+		}()
+	}
+}
+
+func (c *Conn) reply(id json.RawMessage, result any, err error) error {
+	msg := &wireMessage{JSONRPC: Version, ID: id}
+	switch {
+	case err != nil:
+		msg.Error = toError(err)
+	case result == nil:
+		msg.Result = json.RawMessage("null")
+	default:
+		// Encoded as it is written, or never over a DirectPipe.
+		msg.result = result
+	}
+	return c.write(msg)
+}
+
+func toError(err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	return &Error{Code: CodeInternalError, Message: err.Error()}
+}
+
+// Call sends a request and waits for its response, decoding the result into
+// result unless it is nil. An error response is returned as a *HandlerError
+// wrapping the *Error sent, or a *CancelledError for CodeRequestCancelled.
+// If ctx is done first, the peer is told through Options.CancelMethod and
+// a *CancelledError returned. A result which doesn't decode is a
+// *ProtocolError; a failure of the stream, a *TransportError.
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	id := json.RawMessage(strconv.FormatInt(c.seq.Add(1), 10))
+	msg := &wireMessage{JSONRPC: Version, ID: id, Method: method}
+	if err := c.setParams(msg, params); err != nil {
+		return err
+	}
+
+	ch := make(chan *wireMessage, 1)
+	c.pendingMu.Lock()
+	c.pending[idKey(id)] = &outbound{ch: ch, method: method, params: msg.Params, started: c.clock.Now()}
+	c.pendingMu.Unlock()
+	forget := func() {
+		c.pendingMu.Lock()
+		delete(c.pending, idKey(id))
+		c.pendingMu.Unlock()
+	}
+
+	if err := c.write(msg); err != nil {
+		forget()
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		forget()
+		if c.cancelMethod != "" {
+			_ = c.Notify(context.Background(), c.cancelMethod, map[string]json.RawMessage{"id": id})
+		}
+		return &CancelledError{Method: method, Err: ctx.Err()}
+	case res, ok := <-ch:
+		if !ok {
+			return ErrClosed
+		}
+		if res.Error != nil {
+			return callError(method, res.Error)
+		}
+		if result != nil && assign(result, res.result) {
+			return nil
+		}
+		if res.result != nil {
+			if _, err := res.encoded(); err != nil {
+				return &ProtocolError{Err: fmt.Errorf("jsonrpc2: decoding %s result: %w", method, err)}
+			}
+			if res.Error != nil {
+				return callError(method, res.Error)
+			}
+		}
+		if result != nil && len(res.Result) > 0 {
+			if err := c.numbers.Unmarshal(res.Result, result); err != nil {
+				return &ProtocolError{Err: fmt.Errorf("jsonrpc2: decoding %s result: %w", method, err)}
+			}
+		}
+		return nil
+	}
+}
+
+// callError classifies the error response to a call of method.
+func callError(method string, rpcErr *Error) error {
+	if rpcErr.Code == CodeRequestCancelled {
+		return &CancelledError{Method: method, Err: rpcErr}
+	}
+	return &HandlerError{Method: method, Err: rpcErr}
+}
+
+// Notify sends a notification.
+func (c *Conn) Notify(ctx context.Context, method string, params any) error {
+	msg := &wireMessage{JSONRPC: Version, Method: method}
+	if err := c.setParams(msg, params); err != nil {
+		return err
+	}
+	if c.bp.park(msg, params) {
+		return nil
+	}
+	return c.write(msg)
+}
+
+func (c *Conn) setParams(msg *wireMessage, params any) error {
+	if params == nil {
+		return nil
+	}
+	raw, err := c.marshal(params)
+	if err != nil {
+		return fmt.Errorf("jsonrpc2: encoding %s params: %w", msg.Method, err)
+	}
+	msg.Params, msg.params = raw, params
+	return nil
+}
+
+func (c *Conn) write(msg *wireMessage) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	if c.direct() {
+		return c.send(func() error {
+			if err := c.values.writeValue(msg); err != nil {
+				return &TransportError{Op: "write", Err: err}
+			}
+			return nil
+		})
+	}
+	if c.tracer == nil && c.rewrite.Out == nil {
+		return c.send(func() error { return c.writeEncoded(msg) })
+	}
+	raw, err := c.encode(msg)
+	if err != nil {
+		return err
+	}
+	return c.writeRaw(raw)
+}
+
+func (c *Conn) writeRaw(raw []byte) error {
+	raw = rewrite(c.rewrite.Out, raw)
+	return c.send(func() error {
+		if c.tracer != nil {
+			c.tracer.Sent(raw)
+		}
+		if c.instrument != nil {
+			c.instrument.Wrote(len(raw))
+		}
+		if err := c.writer.WriteMessage(raw); err != nil {
+			return &TransportError{Op: "write", Err: err}
+		}
+		return nil
+	})
+}
+
+// send makes one write with writeMu held, then releases the parked
+// notifications if the peer has caught up.
+func (c *Conn) send(write func() error) error {
+	c.bp.enter()
+	c.writeMu.Lock()
+	err := write()
+	c.writeMu.Unlock()
+	if c.bp.leave() {
+		// Writes made while draining never start another drain.
+		for parked := c.bp.next(); parked != nil; parked = c.bp.next() {
+			if raw, mErr := c.encode(parked); mErr == nil {
+				_ = c.writeRaw(raw)
+			}
+		}
+	}
+	return err
+}
+
+// queue is the inbound queue between the reader and the dispatcher, and
+// holds the requests dispatched to wait for the Scheduler. It must never
+// block the reader, or a handler waiting on a Call response could deadlock
+// the connection; Overload bounds the requests in it by refusing those
+// past the bound instead.
+type queue struct {
+	mu     sync.Mutex
+	items  []*Request
+	closed bool
+	wake   chan struct{}
+	// requests counts the requests among items.
+	requests int
+
+	overload *Overload
+	// scheduled is set when the Overload applies to requests waiting for
+	// the Scheduler too, and unready holds them, from when they are popped
+	// until they are ready, with what cancels their wait once dispatch has
+	// it.
+	scheduled bool
+	unready   map[*Request]context.CancelCauseFunc
+}
+
+// push queues req, returning the requests it superseded, which the caller
+// answers, and false if req is past the bound and isn't queued, which the
+// caller answers too.
+func (q *queue) push(req *Request) ([]*Request, bool) {
+	if q.overload != nil && q.overload.Supersede != nil && !req.IsNotification() {
+		req.supersede = q.overload.Supersede(req)
+	}
+	q.mu.Lock()
+	var dropped []*Request
+	if q.overload != nil {
+		dropped = q.supersede(req)
+		if q.full(req) {
+			q.mu.Unlock()
+			return dropped, false
+		}
+	}
+	q.items = append(q.items, req)
+	if !req.IsNotification() {
+		q.requests++
+	}
+	q.mu.Unlock()
+	q.signal()
+	return dropped, true
+}
+
+// wait records what cancels the wait of req, popped to wait for the
+// Scheduler, cancelling it at once if req was superseded meanwhile. It
+// does nothing unless the Overload applies to such requests.
+func (q *queue) wait(req *Request, cancel context.CancelCauseFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.scheduled {
+		return
+	}
+	if _, ok := q.unready[req]; !ok {
+		cancel(errSuperseded)
+		return
+	}
+	q.unready[req] = cancel
+}
+
+// take takes req off the requests waiting for the Scheduler, reporting
+// false if it was superseded, and answered, meanwhile.
+func (q *queue) take(req *Request) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.scheduled || req.IsNotification() {
+		return true
+	}
+	if _, ok := q.unready[req]; !ok {
+		return false
+	}
+	delete(q.unready, req)
+	return true
+}
+
+// remove takes the request with the given ID out of the queue, returning
+// nil if it isn't there.
+func (q *queue) remove(id json.RawMessage) *Request {
+	key := idKey(id)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, req := range q.items {
+		if req.ID != nil && idKey(req.ID) == key {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			q.requests--
+			return req
+		}
+	}
+	return nil
+}
+
+func (q *queue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue) pop() (*Request, bool) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, false
+		}
+		if len(q.items) > 0 {
+			req := q.items[0]
+			q.items[0] = nil
+			q.items = q.items[1:]
+			if !req.IsNotification() {
+				q.requests--
+				if q.scheduled {
+					if q.unready == nil {
+						q.unready = map[*Request]context.CancelCauseFunc{}
+					}
+					q.unready[req] = nil
+				}
+			}
+			q.mu.Unlock()
+			return req, true
+		}
+		q.mu.Unlock()
+		<-q.wake
+	}
+}
@@ -0,0 +1,456 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pentops/lsplib/framing"
+)
+
+// peer is the raw other end of a Conn under test.
+type peer struct {
+	t *testing.T
+	r framing.Reader
+	w framing.Writer
+}
+
+// serve runs a Conn with h over a pipe, returning its peer. The Conn is
+// closed when the test ends.
+func serve(t *testing.T, opts Options, h Handler) *peer {
+	t.Helper()
+	local, remote := net.Pipe()
+	conn := NewConn(local, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = conn.Run(ctx, h)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		_ = conn.Close()
+		_ = remote.Close()
+		<-done
+	})
+	return &peer{t: t, r: framing.NewHeaderReader(remote), w: framing.NewHeaderWriter(remote)}
+}
+
+func (p *peer) send(msg string) {
+	p.t.Helper()
+	if err := p.w.WriteMessage([]byte(msg)); err != nil {
+		p.t.Fatalf("writing %s: %v", msg, err)
+	}
+}
+
+func (p *peer) receive() *wireMessage {
+	p.t.Helper()
+	body, err := p.r.ReadMessage()
+	if err != nil {
+		p.t.Fatalf("reading: %v", err)
+	}
+	msg := &wireMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		p.t.Fatalf("decoding %s: %v", body, err)
+	}
+	return msg
+}
+
+func TestReuseIDOnceAnswered(t *testing.T) {
+	p := serve(t, Options{}, HandlerFunc(func(context.Context, *Request) (any, error) {
+		return "ok", nil
+	}))
+	// The peer may send the next request with the ID as soon as it has
+	// the response to the last.
+	for i := 0; i < 500; i++ {
+		p.send(`{"jsonrpc":"2.0","id":1,"method":"echo"}`)
+		msg := p.receive()
+		if msg.Error != nil {
+			t.Fatalf("request %d reusing id 1 was refused: %v", i, msg.Error)
+		}
+		if string(msg.ID) != "1" || string(msg.Result) != `"ok"` {
+			t.Fatalf("request %d was answered with id %s, result %s", i, msg.ID, msg.Result)
+		}
+	}
+}
+
+func TestCancelBeforeDispatch(t *testing.T) {
+	release := make(chan struct{})
+	handled := make(chan string, 4)
+	p := serve(t, Options{CancelMethod: "$/cancelRequest"}, HandlerFunc(func(_ context.Context, req *Request) (any, error) {
+		if req.Method == "block" {
+			<-release
+			return nil, nil
+		}
+		handled <- req.Method
+		return "ok", nil
+	}))
+	// Notifications are handled in order, holding back the request queued
+	// behind this one.
+	p.send(`{"jsonrpc":"2.0","method":"block"}`)
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"slow"}`)
+	p.send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`)
+
+	msg := p.receive()
+	if string(msg.ID) != "1" || msg.Error == nil || msg.Error.Code != CodeRequestCancelled {
+		t.Fatalf("queued request cancelled was answered with %+v, want CodeRequestCancelled", msg)
+	}
+	close(release)
+
+	// Its ID is free again, and its handler never ran.
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"again"}`)
+	if msg := p.receive(); msg.Error != nil {
+		t.Fatalf("request reusing the cancelled id was refused: %v", msg.Error)
+	}
+	select {
+	case method := <-handled:
+		if method != "again" {
+			t.Fatalf("handled %s, want only again", method)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request reusing the id was never handled")
+	}
+}
+
+// gate is a Scheduler holding every message until open is closed,
+// telling admitted about each.
+type gate struct {
+	open     chan struct{}
+	admitted chan *Request
+}
+
+func newGate() *gate {
+	return &gate{open: make(chan struct{}), admitted: make(chan *Request, 16)}
+}
+
+func (g *gate) Admit(req *Request) (<-chan struct{}, func()) {
+	g.admitted <- req
+	return g.open, func() {}
+}
+
+// admit sends the request with id, waiting until the gate admits it, so it
+// waits on the Scheduler rather than in the queue.
+func (p *peer) admit(g *gate, id int) {
+	p.t.Helper()
+	p.send(`{"jsonrpc":"2.0","id":` + strconv.Itoa(id) + `,"method":"hover"}`)
+	select {
+	case req := <-g.admitted:
+		if string(req.ID) != strconv.Itoa(id) {
+			p.t.Fatalf("admitted request %s, want %d", req.ID, id)
+		}
+	case <-time.After(5 * time.Second):
+		p.t.Fatalf("request %d was never admitted", id)
+	}
+}
+
+// cancelled reads as many answers as ids, failing unless they cancel the
+// requests with those ids, in any order.
+func (p *peer) cancelled(ids ...string) {
+	p.t.Helper()
+	var got []string
+	for range ids {
+		msg := p.receive()
+		if msg.Error == nil || msg.Error.Code != CodeRequestCancelled {
+			p.t.Fatalf("request %s was answered with %+v, want CodeRequestCancelled", msg.ID, msg)
+		}
+		got = append(got, string(msg.ID))
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, ids) {
+		p.t.Fatalf("cancelled requests %v, want %v", got, ids)
+	}
+}
+
+func TestSupersedeWhileScheduled(t *testing.T) {
+	g := newGate()
+	var dropped atomic.Int32
+	handled := make(chan string, 8)
+	p := serve(t, Options{
+		Scheduler: g,
+		Overload: &Overload{
+			MaxQueued: 2,
+			Supersede: func(req *Request) string { return req.Method },
+			OnDrop:    func(*Request) { dropped.Add(1) },
+		},
+	}, HandlerFunc(func(_ context.Context, req *Request) (any, error) {
+		handled <- string(req.ID)
+		return "ok", nil
+	}))
+	// Each request arriving while two wait on the gate drops them.
+	p.admit(g, 1)
+	p.admit(g, 2)
+	p.send(`{"jsonrpc":"2.0","id":3,"method":"hover"}`)
+	p.cancelled("1", "2")
+	<-g.admitted
+	p.admit(g, 4)
+	p.send(`{"jsonrpc":"2.0","id":5,"method":"hover"}`)
+	p.cancelled("3", "4")
+	<-g.admitted
+
+	close(g.open)
+	if msg := p.receive(); string(msg.ID) != "5" || msg.Error != nil {
+		t.Fatalf("the newest request was answered with %+v, want its result", msg)
+	}
+	if id := <-handled; id != "5" {
+		t.Fatalf("handled request %s, want only 5", id)
+	}
+	select {
+	case id := <-handled:
+		t.Fatalf("handled superseded request %s", id)
+	default:
+	}
+	if n := dropped.Load(); n != 4 {
+		t.Fatalf("OnDrop was told about %d requests, want 4", n)
+	}
+}
+
+func TestBoundWhileScheduled(t *testing.T) {
+	g := newGate()
+	noted := make(chan struct{}, 1)
+	p := serve(t, Options{
+		Scheduler: g,
+		Overload:  &Overload{MaxRequests: 3},
+	}, HandlerFunc(func(_ context.Context, req *Request) (any, error) {
+		if req.IsNotification() {
+			noted <- struct{}{}
+		}
+		return "ok", nil
+	}))
+	// Requests past the bound are refused at once, while those within it
+	// wait on the gate.
+	for id := 1; id <= 3; id++ {
+		p.admit(g, id)
+	}
+	p.send(`{"jsonrpc":"2.0","id":4,"method":"hover"}`)
+	p.cancelled("4")
+	// Notifications are kept past it.
+	p.send(`{"jsonrpc":"2.0","method":"note"}`)
+
+	close(g.open)
+	var ids []string
+	for range 3 {
+		msg := p.receive()
+		if msg.Error != nil {
+			t.Fatalf("request %s within the bound failed: %v", msg.ID, msg.Error)
+		}
+		ids = append(ids, string(msg.ID))
+	}
+	slices.Sort(ids)
+	if want := []string{"1", "2", "3"}; !slices.Equal(ids, want) {
+		t.Fatalf("answered %v once the gate opened, want %v", ids, want)
+	}
+	select {
+	case <-noted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the notification past the bound was never handled")
+	}
+	// Once they are answered there is room again.
+	p.send(`{"jsonrpc":"2.0","id":5,"method":"hover"}`)
+	if msg := p.receive(); string(msg.ID) != "5" || msg.Error != nil {
+		t.Fatalf("a request after the others were answered got %+v, want its result", msg)
+	}
+}
+
+func TestBoundWhileQueued(t *testing.T) {
+	release := make(chan struct{})
+	p := serve(t, Options{Overload: &Overload{MaxRequests: 1}}, HandlerFunc(func(_ context.Context, req *Request) (any, error) {
+		if req.Method == "block" {
+			<-release
+		}
+		return "ok", nil
+	}))
+	// The blocking notification holds the queue, with one request in it.
+	p.send(`{"jsonrpc":"2.0","method":"block"}`)
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"hover"}`)
+	p.send(`{"jsonrpc":"2.0","id":2,"method":"hover"}`)
+	p.cancelled("2")
+	close(release)
+	if msg := p.receive(); string(msg.ID) != "1" || msg.Error != nil {
+		t.Fatalf("the queued request was answered with %+v, want its result", msg)
+	}
+}
+
+func TestSchedulerWithoutOverload(t *testing.T) {
+	g := newGate()
+	p := serve(t, Options{Scheduler: g}, HandlerFunc(func(context.Context, *Request) (any, error) {
+		return "ok", nil
+	}))
+	p.admit(g, 1)
+	close(g.open)
+	if msg := p.receive(); string(msg.ID) != "1" || msg.Error != nil {
+		t.Fatalf("the request was answered with %+v, want its result", msg)
+	}
+}
+
+func TestCancelWhileScheduled(t *testing.T) {
+	g := newGate()
+	handled := make(chan string, 4)
+	p := serve(t, Options{CancelMethod: "$/cancelRequest", Scheduler: g}, HandlerFunc(func(_ context.Context, req *Request) (any, error) {
+		handled <- string(req.ID)
+		return "ok", nil
+	}))
+	// A request cancelled while it waits for the Scheduler is answered at
+	// once, and its handler never runs.
+	p.admit(g, 1)
+	p.send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`)
+	p.cancelled("1")
+	close(g.open)
+	p.send(`{"jsonrpc":"2.0","id":2,"method":"hover"}`)
+	if msg := p.receive(); string(msg.ID) != "2" || msg.Error != nil {
+		t.Fatalf("the next request was answered with %+v, want its result", msg)
+	}
+	if id := <-handled; id != "2" {
+		t.Fatalf("handled request %s, want only 2", id)
+	}
+}
+
+func TestCancelDuringHandler(t *testing.T) {
+	started := make(chan struct{}, 1)
+	causes := make(chan error, 1)
+	p := serve(t, Options{CancelMethod: "$/cancelRequest"}, HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+		switch req.Method {
+		case "finish":
+			return "done anyway", nil
+		case "own":
+			return nil, NewError(CodeInvalidParams, "own error")
+		}
+		return nil, ctx.Err()
+	}))
+	for _, tc := range []struct {
+		method string
+		check  func(*wireMessage) bool
+	}{
+		// Returning because of the cancellation is answered as cancelled.
+		{"wait", func(msg *wireMessage) bool { return msg.Error != nil && msg.Error.Code == CodeRequestCancelled }},
+		// A handler finishing regardless has its result sent.
+		{"finish", func(msg *wireMessage) bool { return msg.Error == nil && string(msg.Result) == `"done anyway"` }},
+		// As does one returning an error of its own.
+		{"own", func(msg *wireMessage) bool { return msg.Error != nil && msg.Error.Code == CodeInvalidParams }},
+	} {
+		p.send(`{"jsonrpc":"2.0","id":1,"method":"` + tc.method + `"}`)
+		<-started
+		p.send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`)
+		if cause := <-causes; !errors.Is(cause, ErrCancelled) {
+			t.Fatalf("%s: the handler's context was cancelled with %v, want ErrCancelled", tc.method, cause)
+		}
+		if msg := p.receive(); string(msg.ID) != "1" || !tc.check(msg) {
+			t.Fatalf("%s: answered with %+v", tc.method, msg)
+		}
+	}
+}
+
+func TestCancelAfterReply(t *testing.T) {
+	var handled atomic.Int32
+	p := serve(t, Options{CancelMethod: "$/cancelRequest"}, HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+		handled.Add(1)
+		return "ok", nil
+	}))
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"hover"}`)
+	if msg := p.receive(); msg.Error != nil {
+		t.Fatalf("the request failed: %v", msg.Error)
+	}
+	// Cancelling a request already answered, or one never sent, is
+	// ignored: the next message is the answer to the request after.
+	p.send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`)
+	p.send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":99}}`)
+	p.send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{}}`)
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"hover"}`)
+	if msg := p.receive(); string(msg.ID) != "1" || msg.Error != nil || string(msg.Result) != `"ok"` {
+		t.Fatalf("the request after was answered with %+v, want its result", msg)
+	}
+	if n := handled.Load(); n != 2 {
+		t.Fatalf("handled %d requests, want 2", n)
+	}
+}
+
+func TestCallCancelled(t *testing.T) {
+	local, remote := net.Pipe()
+	conn := NewConn(local, Options{CancelMethod: "$/cancelRequest"})
+	go func() {
+		_ = conn.Run(context.Background(), HandlerFunc(func(context.Context, *Request) (any, error) { return nil, nil }))
+	}()
+	defer conn.Close()
+	p := &peer{t: t, r: framing.NewHeaderReader(remote), w: framing.NewHeaderWriter(remote)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- conn.Call(ctx, "workspace/symbol", nil, nil) }()
+	req := p.receive()
+	cancel()
+	// The peer is told, and the call returns without waiting for it.
+	note := p.receive()
+	if note.Method != "$/cancelRequest" || string(note.Params) != `{"id":`+string(req.ID)+`}` {
+		t.Fatalf("after the call's context was done the peer read %+v, want it cancelled", note)
+	}
+	if err := <-errs; !errors.Is(err, ErrCancelled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Call returned %v, want a CancelledError", err)
+	}
+	// A late answer is dropped, and doesn't answer the next call.
+	p.send(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"error":{"code":-32800,"message":"request cancelled"}}`)
+	go func() { errs <- conn.Call(context.Background(), "workspace/symbol", nil, nil) }()
+	next := p.receive()
+	p.send(`{"jsonrpc":"2.0","id":` + string(next.ID) + `,"result":[]}`)
+	if err := <-errs; err != nil {
+		t.Fatalf("the next call returned %v", err)
+	}
+}
+
+func TestCancelStorm(t *testing.T) {
+	release := make(chan struct{})
+	handled := make(chan string, 32)
+	p := serve(t, Options{CancelMethod: "$/cancelRequest"}, HandlerFunc(func(_ context.Context, req *Request) (any, error) {
+		if req.Method == "block" {
+			<-release
+			return nil, nil
+		}
+		handled <- string(req.ID)
+		return "ok", nil
+	}))
+	// Requests queue behind the blocking notification, and all but the
+	// last are cancelled, as on switching files.
+	p.send(`{"jsonrpc":"2.0","method":"block"}`)
+	const n = 20
+	for id := 1; id <= n; id++ {
+		p.send(`{"jsonrpc":"2.0","id":` + strconv.Itoa(id) + `,"method":"hover"}`)
+	}
+	var ids []string
+	for id := 1; id < n; id++ {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	slices.Sort(ids)
+	// The answers are written as the cancellations are read, so those are
+	// sent alongside.
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for _, id := range ids {
+			if err := p.w.WriteMessage([]byte(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":` + id + `}}`)); err != nil {
+				return
+			}
+		}
+	}()
+	// Each is answered while the queue is still held.
+	p.cancelled(ids...)
+	<-sent
+	close(release)
+	if msg := p.receive(); string(msg.ID) != strconv.Itoa(n) || msg.Error != nil {
+		t.Fatalf("the request left was answered with %+v, want its result", msg)
+	}
+	if id := <-handled; id != strconv.Itoa(n) {
+		t.Fatalf("handled request %s, want only %d", id, n)
+	}
+	select {
+	case id := <-handled:
+		t.Fatalf("handled cancelled request %s", id)
+	default:
+	}
+}
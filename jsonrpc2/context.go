@@ -0,0 +1,140 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type requestKey struct{}
+
+type requestInfo struct {
+	conn   *Conn
+	id     json.RawMessage
+	method string
+	trace  *TraceContext
+}
+
+func withRequest(ctx context.Context, conn *Conn, req *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, &requestInfo{
+		conn:   conn,
+		id:     req.ID,
+		method: req.Method,
+		trace:  traceFromParams(req.Params),
+	})
+}
+
+func requestFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestKey{}).(*requestInfo)
+	return info
+}
+
+// ReqID returns the raw JSON id of the request being handled, or nil for
+// notifications and contexts not derived from a handler.
+func ReqID(ctx context.Context) json.RawMessage {
+	if info := requestFrom(ctx); info != nil {
+		return info.id
+	}
+	return nil
+}
+
+// ConnFrom returns the connection the request or notification being
+// handled arrived on, so handlers can send to the peer; nil for contexts not
+// derived from a handler.
+func ConnFrom(ctx context.Context) *Conn {
+	if info := requestFrom(ctx); info != nil {
+		return info.conn
+	}
+	return nil
+}
+
+// Method returns the method of the request or notification being handled.
+func Method(ctx context.Context) string {
+	if info := requestFrom(ctx); info != nil {
+		return info.method
+	}
+	return ""
+}
+
+// TraceContext is a W3C trace context, carried in the params' `_meta` object
+// as "traceparent" (and optionally "tracestate") by clients which propagate
+// distributed traces.
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+	State   string
+}
+
+// Sampled reports the sampled flag.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&0x01 != 0
+}
+
+// TraceParent formats the traceparent header value, for forwarding the trace
+// to backend services.
+func (tc TraceContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(tc.TraceID[:]), hex.EncodeToString(tc.SpanID[:]), tc.Flags)
+}
+
+// ParseTraceParent parses a version 00 traceparent value.
+func ParseTraceParent(val string) (TraceContext, error) {
+	var tc TraceContext
+	parts := strings.Split(strings.TrimSpace(val), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return tc, fmt.Errorf("malformed traceparent %q", val)
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return tc, fmt.Errorf("unsupported traceparent version %q", parts[0])
+	}
+	if _, err := hex.Decode(tc.TraceID[:], []byte(parts[1])); err != nil {
+		return tc, fmt.Errorf("malformed trace id: %w", err)
+	}
+	if _, err := hex.Decode(tc.SpanID[:], []byte(parts[2])); err != nil {
+		return tc, fmt.Errorf("malformed span id: %w", err)
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return tc, fmt.Errorf("malformed trace flags: %w", err)
+	}
+	tc.Flags = flags[0]
+	if tc.TraceID == [16]byte{} || tc.SpanID == [8]byte{} {
+		return tc, fmt.Errorf("traceparent has an all-zero id")
+	}
+	return tc, nil
+}
+
+// Trace returns the trace context supplied with the request being handled.
+func Trace(ctx context.Context) (TraceContext, bool) {
+	if info := requestFrom(ctx); info != nil && info.trace != nil {
+		return *info.trace, true
+	}
+	return TraceContext{}, false
+}
+
+var metaKey = []byte(`"_meta"`)
+
+func traceFromParams(params json.RawMessage) *TraceContext {
+	// Most messages carry no _meta; avoid a second decode of those.
+	if !bytes.Contains(params, metaKey) {
+		return nil
+	}
+	var holder struct {
+		Meta struct {
+			TraceParent string `json:"traceparent"`
+			TraceState  string `json:"tracestate"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(params, &holder); err != nil || holder.Meta.TraceParent == "" {
+		return nil
+	}
+	tc, err := ParseTraceParent(holder.Meta.TraceParent)
+	if err != nil {
+		return nil
+	}
+	tc.State = holder.Meta.TraceState
+	return &tc
+}
@@ -0,0 +1,89 @@
+package jsonrpc2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The classes of failure a connection reports, for errors.Is. Each is the
+// class of one error type, which errors.As reaches for the details:
+//
+//	ErrTransport  *TransportError  the stream failed or was closed
+//	ErrProtocol   *ProtocolError   the peer sent something unreadable
+//	ErrHandler    *HandlerError    the peer answered a call with an error
+//	ErrCancelled  *CancelledError  a call or handler was cancelled
+//
+// Callers branch on the class, such as to redial after ErrTransport but not
+// after ErrHandler, rather than on the errors' text.
+var (
+	ErrTransport = errors.New("jsonrpc2: transport failure")
+	ErrProtocol  = errors.New("jsonrpc2: protocol violation")
+	ErrHandler   = errors.New("jsonrpc2: request failed")
+)
+
+// TransportError is a failure of the stream under the connection: reading
+// or writing it, or the connection being closed, as ErrClosed is.
+type TransportError struct {
+	// Op is the operation which failed, "read" or "write", or "dial" for
+	// lspclient.Dial; empty for failures which aren't of one operation.
+	Op  string
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	if e.Op == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("jsonrpc2: %s: %v", e.Op, e.Err)
+}
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+func (e *TransportError) Is(target error) bool { return target == ErrTransport }
+
+// ProtocolError is a message which breaks JSON-RPC: one which doesn't
+// decode, is neither a request nor a response, is malformed as a frame, or
+// answers a call with a result which doesn't decode as its result. It is
+// what Options.OnProtocolError is told about, and what Run returns, as
+// ErrTooManyMalformed, when there are too many.
+type ProtocolError struct {
+	Err error
+}
+
+func (e *ProtocolError) Error() string { return e.Err.Error() }
+
+func (e *ProtocolError) Unwrap() error { return e.Err }
+
+func (e *ProtocolError) Is(target error) bool { return target == ErrProtocol }
+
+// HandlerError is the error response the peer answered a call with. Err is
+// the *Error it sent, so errors.As reaches its code and data, and a handler
+// returning a HandlerError passes the peer's error on as it is.
+type HandlerError struct {
+	Method string
+	Err    error
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("jsonrpc2: %s: %v", e.Method, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error { return e.Err }
+
+func (e *HandlerError) Is(target error) bool { return target == ErrHandler }
+
+// CancelledError is a call which ended unanswered because its context was
+// done, Err being the context's error, or which the peer answered with
+// CodeRequestCancelled, Err being the *Error it sent.
+type CancelledError struct {
+	Method string
+	Err    error
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("jsonrpc2: %s: %v", e.Method, e.Err)
+}
+
+func (e *CancelledError) Unwrap() error { return e.Err }
+
+func (e *CancelledError) Is(target error) bool { return target == ErrCancelled }
@@ -0,0 +1,126 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ID is a request ID, which JSON-RPC allows to be a string or a number;
+// some clients use strings. IDs are equal when they are the same string or
+// the same number however the JSON spells them, 1 and 1.0 alike, so an ID
+// keys the maps correlating requests and responses. An ID marshals in the
+// form it was read in. The zero ID is null, as a notification's is.
+type ID struct {
+	// value is the string, or the number in canonical form.
+	value  string
+	number bool
+	valid  bool
+}
+
+// StringID returns the ID which is the string s.
+func StringID(s string) ID {
+	return ID{value: s, valid: true}
+}
+
+// NumberID returns the ID which is the number n.
+func NumberID(n int64) ID {
+	return ID{value: strconv.FormatInt(n, 10), number: true, valid: true}
+}
+
+// ParseID decodes a JSON id, failing unless it is a string or a number.
+func ParseID(raw json.RawMessage) (ID, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return ID{}, fmt.Errorf("jsonrpc2: id %s: %w", raw, err)
+		}
+		return StringID(s), nil
+	}
+	var n json.Number
+	if string(raw) == "null" || json.Unmarshal(raw, &n) != nil {
+		return ID{}, fmt.Errorf("jsonrpc2: id %s is neither a string nor a number", raw)
+	}
+	return ID{value: canonicalNumber(string(n)), number: true, valid: true}, nil
+}
+
+// canonicalNumber spells equal numbers alike: integers as they are, so
+// large ones keep every digit, and other numbers as the integer they are
+// equal to, if any, or as strconv formats them.
+func canonicalNumber(n string) string {
+	if !strings.ContainsAny(n, ".eE") {
+		if n == "-0" {
+			return "0"
+		}
+		return n
+	}
+	f, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return n
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// IsString reports whether the ID is a string.
+func (id ID) IsString() bool {
+	return id.valid && !id.number
+}
+
+// IsNull reports whether the ID is the zero ID.
+func (id ID) IsNull() bool {
+	return !id.valid
+}
+
+// String is the ID as JSON: a number, or a quoted string.
+func (id ID) String() string {
+	switch {
+	case !id.valid:
+		return "null"
+	case id.number:
+		return id.value
+	default:
+		return strconv.Quote(id.value)
+	}
+}
+
+func (id ID) MarshalJSON() ([]byte, error) {
+	switch {
+	case !id.valid:
+		return []byte("null"), nil
+	case id.number:
+		return []byte(id.value), nil
+	default:
+		return json.Marshal(id.value)
+	}
+}
+
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		*id = ID{}
+		return nil
+	}
+	parsed, err := ParseID(data)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// idKey is the ID keying a raw id in correlation maps. An id which is
+// neither a string nor a number, which the peer shouldn't send, keys as
+// its JSON.
+func idKey(raw json.RawMessage) ID {
+	id, err := ParseID(raw)
+	if err != nil {
+		return ID{value: string(raw), valid: true}
+	}
+	return id
+}
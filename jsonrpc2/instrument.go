@@ -0,0 +1,35 @@
+package jsonrpc2
+
+import "context"
+
+// Instrument observes a connection for metrics and tracing; package
+// telemetry has implementations. Its methods are called concurrently and
+// must not block.
+type Instrument interface {
+	// Start is called as an inbound request or notification is handed to
+	// its handler, which gets the context it returns, such as one carrying
+	// a span. end is called once it has been handled, with the error a
+	// request was answered with, or nil.
+	Start(ctx context.Context, req *Request) (_ context.Context, end func(err *Error))
+
+	// Read and Wrote count the bytes of each message read and written, as
+	// they are on the wire, without framing. Messages passed as values
+	// over a DirectPipe aren't counted.
+	Read(n int)
+	Wrote(n int)
+}
+
+// start tells the Instrument a message is being handled, returning the
+// context to handle it in and the function to call once it has been.
+func (c *Conn) start(ctx context.Context, req *Request) (context.Context, func(err *Error)) {
+	if c.instrument == nil {
+		return ctx, func(*Error) {}
+	}
+	return c.instrument.Start(ctx, req)
+}
+
+func (c *Conn) handleNotification(ctx context.Context, h Handler, req *Request) {
+	ctx, end := c.start(ctx, req)
+	_, _ = h.Handle(ctx, req)
+	end(nil)
+}
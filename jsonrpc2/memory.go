@@ -0,0 +1,160 @@
+package jsonrpc2
+
+import (
+	"io"
+	"reflect"
+	"sync"
+)
+
+// MessagePipe returns the two ends of an in-memory stream of discrete
+// messages, for connecting two Conns in one process. Each message written
+// to one end is read whole from the other, without framing or copying
+// through a byte stream; Conns over the ends are unframed. Closing either
+// end ends both.
+func MessagePipe() (a, b io.ReadWriteCloser) {
+	return memoryPipe(false)
+}
+
+// DirectPipe is a MessagePipe which Conns over its ends skip serialization
+// on where they can: params still travel as JSON, since handlers and
+// middleware may read them raw, but UnmarshalParams hands the handler the
+// value the peer sent when it has the type asked for, and results pass to
+// Call as values, never encoded. A Conn with Options.Rewrite or a Tracer
+// set works on JSON all the same.
+//
+// Values are shared, not copied: a sender must not modify params once it
+// has sent them, nor a handler its result once it has returned it, and the
+// receiver must treat them as read-only.
+func DirectPipe() (a, b io.ReadWriteCloser) {
+	return memoryPipe(true)
+}
+
+func memoryPipe(values bool) (a, b io.ReadWriteCloser) {
+	ab, ba := make(chan *memoryMessage), make(chan *memoryMessage)
+	done := make(chan struct{})
+	var once sync.Once
+	closer := func() { once.Do(func() { close(done) }) }
+	return &memoryEnd{in: ba, out: ab, done: done, close: closer, values: values},
+		&memoryEnd{in: ab, out: ba, done: done, close: closer, values: values}
+}
+
+// memoryMessage is a message crossing a pipe, either as JSON or, between
+// Conns over a DirectPipe, as the message itself.
+type memoryMessage struct {
+	raw []byte
+	msg *wireMessage
+}
+
+// valueStream is a stream which carries messages as values.
+type valueStream interface {
+	carriesValues() bool
+	writeValue(msg *wireMessage) error
+	// readValue returns the next message, as JSON or as a value.
+	readValue() ([]byte, *wireMessage, error)
+}
+
+// memoryEnd is one end of a MessagePipe. Read and Write carry a message
+// per call, as a WebSocket does, for use as a plain io.ReadWriter.
+type memoryEnd struct {
+	in, out chan *memoryMessage
+	done    chan struct{}
+	close   func()
+	values  bool
+
+	readMu sync.Mutex
+	// unread is the rest of a message Read didn't have room for.
+	unread []byte
+}
+
+func (e *memoryEnd) ReadMessage() ([]byte, error) {
+	raw, msg, err := e.readValue()
+	if msg != nil {
+		return msg.encoded()
+	}
+	return raw, err
+}
+
+func (e *memoryEnd) WriteMessage(body []byte) error {
+	return e.send(&memoryMessage{raw: append([]byte(nil), body...)})
+}
+
+func (e *memoryEnd) carriesValues() bool {
+	return e.values
+}
+
+func (e *memoryEnd) readValue() ([]byte, *wireMessage, error) {
+	select {
+	case m := <-e.in:
+		return m.raw, m.msg, nil
+	case <-e.done:
+		return nil, nil, io.EOF
+	}
+}
+
+func (e *memoryEnd) writeValue(msg *wireMessage) error {
+	return e.send(&memoryMessage{msg: msg})
+}
+
+func (e *memoryEnd) send(m *memoryMessage) error {
+	select {
+	case e.out <- m:
+		return nil
+	case <-e.done:
+		return io.ErrClosedPipe
+	}
+}
+
+func (e *memoryEnd) Read(p []byte) (int, error) {
+	e.readMu.Lock()
+	defer e.readMu.Unlock()
+	if len(e.unread) == 0 {
+		msg, err := e.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		e.unread = msg
+	}
+	n := copy(p, e.unread)
+	e.unread = e.unread[n:]
+	return n, nil
+}
+
+func (e *memoryEnd) Write(p []byte) (int, error) {
+	if err := e.WriteMessage(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (e *memoryEnd) Close() error {
+	e.close()
+	return nil
+}
+
+// assign sets what target points to from value, a message's value sent
+// over a DirectPipe, when it has the type target wants, or points to or is
+// pointed to by it. Targets of interface type are left for JSON to fill, as
+// they would be with maps rather than the sender's types.
+func assign(target, value any) bool {
+	dst := reflect.ValueOf(target)
+	if value == nil || dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return false
+	}
+	dst = dst.Elem()
+	src := reflect.ValueOf(value)
+	switch {
+	case dst.Kind() == reflect.Interface:
+		return false
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+	case src.Kind() == reflect.Pointer && !src.IsNil() && src.Elem().Type().AssignableTo(dst.Type()):
+		dst.Set(src.Elem())
+	case dst.Kind() == reflect.Pointer && src.Type().AssignableTo(dst.Type().Elem()):
+		ptr := reflect.New(src.Type())
+		ptr.Elem().Set(src)
+		dst.Set(ptr)
+	default:
+		return false
+	}
+	return true
+}
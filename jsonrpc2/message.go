@@ -0,0 +1,117 @@
+// Package jsonrpc2 is a JSON-RPC 2.0 implementation, used by lsplib as the
+// LSP base protocol but independent of LSP semantics: any tool needing plain
+// JSON-RPC can register methods on a Mux and serve them over a Conn with
+// either Content-Length or newline-delimited framing.
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const Version = "2.0"
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     int64 = -32700
+	CodeInvalidRequest int64 = -32600
+	CodeMethodNotFound int64 = -32601
+	CodeInvalidParams  int64 = -32602
+	CodeInternalError  int64 = -32603
+)
+
+// CodeRequestCancelled answers a request the peer cancelled through
+// Options.CancelMethod. The value is LSP's, shared by other protocols which
+// follow its cancellation convention.
+const CodeRequestCancelled int64 = -32800
+
+// Error is a JSON-RPC error object. Handlers return it to control the code
+// sent to the peer; any other error is sent as CodeInternalError.
+type Error struct {
+	Code    int64           `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// NewError builds an Error with a formatted message.
+func NewError(code int64, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc2 error %d: %s", e.Code, e.Message)
+}
+
+// Request is an inbound request or notification.
+type Request struct {
+	// ID is the raw JSON id, echoed back verbatim in the response. It is nil
+	// for notifications. ParseID reads it as an ID to compare.
+	ID     json.RawMessage
+	Method string
+	Params json.RawMessage
+
+	// value is the params as the peer sent them, over a DirectPipe.
+	value any
+	// supersede is its Overload key, set as it is queued.
+	supersede string
+	// numbers is how UnmarshalParams decodes numbers.
+	numbers Numbers
+}
+
+// IsNotification reports whether the sender expects no response.
+func (r *Request) IsNotification() bool {
+	return r.ID == nil
+}
+
+// UnmarshalParams decodes the params into v, reporting failures as
+// CodeInvalidParams. Absent params leave v untouched. Numbers held in
+// any-typed values are decoded as the connection's Options.Numbers says.
+func (r *Request) UnmarshalParams(v any) error {
+	if len(r.Params) == 0 || string(r.Params) == "null" {
+		return nil
+	}
+	if assign(v, r.value) {
+		return nil
+	}
+	if err := r.numbers.Unmarshal(r.Params, v); err != nil {
+		return NewError(CodeInvalidParams, "invalid params for %s: %v", r.Method, err)
+	}
+	return nil
+}
+
+// WithParams returns a copy of the request carrying params instead, as
+// decoded by the same connection.
+func (r *Request) WithParams(params json.RawMessage) *Request {
+	return &Request{ID: r.ID, Method: r.Method, Params: params, numbers: r.numbers}
+}
+
+// wireMessage is the union of every JSON-RPC message shape.
+type wireMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+
+	// params is the value Params encodes, and result the value of a result
+	// not encoded into Result, for messages crossing a DirectPipe.
+	params any
+	result any
+}
+
+func (m *wireMessage) isResponse() bool {
+	return m.Method == "" && m.ID != nil
+}
+
+// encoded is the message as JSON, with its result value encoded.
+func (m *wireMessage) encoded() ([]byte, error) {
+	if m.result != nil {
+		raw, err := json.Marshal(m.result)
+		if err != nil {
+			m.Error = NewError(CodeInternalError, "encoding result: %v", err)
+		}
+		m.Result, m.result = raw, nil
+	}
+	return json.Marshal(m)
+}
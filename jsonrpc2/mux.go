@@ -0,0 +1,117 @@
+package jsonrpc2
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Handler serves inbound requests and notifications. The result is ignored
+// for notifications.
+type Handler interface {
+	Handle(ctx context.Context, req *Request) (any, error)
+}
+
+// HandlerFunc adapts a function to the Handler interface.
+type HandlerFunc func(ctx context.Context, req *Request) (any, error)
+
+func (f HandlerFunc) Handle(ctx context.Context, req *Request) (any, error) {
+	return f(ctx, req)
+}
+
+// Mux routes requests to handlers registered by method name.
+type Mux struct {
+	mu      sync.RWMutex
+	methods map[string]Handler
+
+	// NotFound serves methods with no registered handler. When nil, requests
+	// fail with CodeMethodNotFound and notifications are dropped.
+	NotFound Handler
+}
+
+func NewMux() *Mux {
+	return &Mux{methods: map[string]Handler{}}
+}
+
+// Register sets the handler for a method, replacing any previous one.
+func (m *Mux) Register(method string, h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.methods == nil {
+		m.methods = map[string]Handler{}
+	}
+	m.methods[method] = h
+}
+
+// RegisterFunc sets a function as the handler for a method.
+func (m *Mux) RegisterFunc(method string, fn func(ctx context.Context, req *Request) (any, error)) {
+	m.Register(method, HandlerFunc(fn))
+}
+
+// Lookup returns the handler registered for a method.
+func (m *Mux) Lookup(method string) (Handler, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.methods[method]
+	return h, ok
+}
+
+// Methods lists the registered methods in sorted order.
+func (m *Mux) Methods() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	methods := make([]string, 0, len(m.methods))
+	for method := range m.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (m *Mux) Handle(ctx context.Context, req *Request) (any, error) {
+	if h, ok := m.Lookup(req.Method); ok {
+		return h.Handle(ctx, req)
+	}
+	if m.NotFound != nil {
+		return m.NotFound.Handle(ctx, req)
+	}
+	if req.IsNotification() {
+		return nil, nil
+	}
+	return nil, NewError(CodeMethodNotFound, "method not found: %s", req.Method)
+}
+
+// RegisterMethod registers a typed request handler: params are decoded into P
+// and the returned R is encoded as the result.
+func RegisterMethod[P, R any](m *Mux, method string, fn func(ctx context.Context, params P) (R, error)) {
+	m.Register(method, HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+		var params P
+		if err := req.UnmarshalParams(&params); err != nil {
+			return nil, err
+		}
+		return fn(ctx, params)
+	}))
+}
+
+// RegisterNotification registers a typed notification handler.
+func RegisterNotification[P any](m *Mux, method string, fn func(ctx context.Context, params P) error) {
+	m.Register(method, HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+		var params P
+		if err := req.UnmarshalParams(&params); err != nil {
+			return nil, err
+		}
+		return nil, fn(ctx, params)
+	}))
+}
+
+// Middleware wraps a Handler with cross-cutting behaviour. It sees both
+// requests and notifications.
+type Middleware func(next Handler) Handler
+
+// Chain wraps h in the middleware, the first listed being outermost.
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
@@ -0,0 +1,146 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// Numbers is how JSON numbers held in any-typed values, such as LSPAny
+// params and results, are decoded. Fields with a numeric type take the
+// number as that type, whichever is chosen.
+type Numbers int
+
+const (
+	// NumbersFloat64 decodes numbers as float64, as encoding/json does,
+	// which loses precision past 2^53, in large IDs and offsets.
+	NumbersFloat64 Numbers = iota
+	// NumbersJSON decodes numbers as json.Number, keeping them as sent.
+	NumbersJSON
+	// NumbersInt64 decodes integers which fit as int64, and other numbers
+	// as float64.
+	NumbersInt64
+)
+
+// Unmarshal decodes the JSON value data into v as n says, as
+// json.Unmarshal does otherwise.
+func (n Numbers) Unmarshal(data []byte, v any) error {
+	if n == NumbersFloat64 {
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := n.Decode(dec, v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("jsonrpc2: data after the top-level value")
+	}
+	return nil
+}
+
+// Decode decodes the next value of dec into v as n says, for decoders
+// configured otherwise, such as to disallow unknown fields. Generated
+// union types decode their variants with it.
+func (n Numbers) Decode(dec *json.Decoder, v any) error {
+	if n != NumbersFloat64 {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if n == NumbersInt64 {
+		toInt64(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+// toInt64 replaces the json.Numbers held in interfaces reachable from v
+// with int64 or float64 values.
+func toInt64(v reflect.Value) {
+	if !holdsInterface(v.Type()) {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			toInt64(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if n, ok := v.Interface().(json.Number); ok {
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(number(n)))
+			}
+			return
+		}
+		toInt64(v.Elem())
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				toInt64(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			toInt64(v.Index(i))
+		}
+	case reflect.Map:
+		// Map values can't be set in place; each is converted in a copy.
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			toInt64(elem)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+}
+
+func number(n json.Number) any {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// interfaceTypes caches holdsInterface by type.
+var interfaceTypes sync.Map
+
+// holdsInterface reports whether a value of t can hold an interface, which
+// a decoded json.Number may be in.
+func holdsInterface(t reflect.Type) bool {
+	if held, ok := interfaceTypes.Load(t); ok {
+		return held.(bool)
+	}
+	held := reaches(t, map[reflect.Type]bool{})
+	interfaceTypes.Store(t, held)
+	return held
+}
+
+// reaches reports whether t or a type it is made of is an interface,
+// not counting those already visited.
+func reaches(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return reaches(t.Elem(), visited)
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if f := t.Field(i); f.IsExported() && reaches(f.Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package jsonrpc2
+
+import "errors"
+
+// Overload configures bounding the inbound requests waiting to be handled,
+// and dropping stale ones, when they arrive faster than they are handled.
+// A request waits while it is queued for dispatch and, with a Scheduler,
+// until the Scheduler readies it. Once MaxQueued messages are waiting, a
+// request arriving replaces the waiting requests with its Supersede key,
+// which are answered with CodeRequestCancelled without being handled, so
+// the newest, say, hover of a document is served instead of every one the
+// user has since moved past. Past MaxRequests, a request arriving is
+// answered likewise rather than waiting. The reader is never blocked:
+// notifications, which can't be answered, are always kept.
+type Overload struct {
+	// MaxQueued is the number of messages waiting from which superseded
+	// requests are dropped, 64 when zero.
+	MaxQueued int
+
+	// MaxRequests bounds the requests waiting, 1024 when zero.
+	MaxRequests int
+
+	// Supersede returns the key under which a newer request replaces an
+	// older waiting one, such as its method and document; lsp.Supersede is
+	// such a key. Requests with an empty key are never dropped. None are
+	// when nil.
+	Supersede func(req *Request) string
+
+	// OnDrop, when set, is told about each request dropped or refused.
+	OnDrop func(req *Request)
+}
+
+// errSuperseded is the context cause of a request dropped while it waited
+// for the Scheduler.
+var errSuperseded = errors.New("jsonrpc2: request superseded")
+
+func normalizeOverload(cfg *Overload) *Overload {
+	if cfg == nil {
+		return nil
+	}
+	o := *cfg
+	if o.MaxQueued <= 0 {
+		o.MaxQueued = 64
+	}
+	if o.MaxRequests <= 0 {
+		o.MaxRequests = 1024
+	}
+	return &o
+}
+
+// supersede removes the waiting requests req replaces, if the queue is
+// overloaded, with mu held. Those waiting for the Scheduler are woken to
+// give up.
+func (q *queue) supersede(req *Request) []*Request {
+	if req.supersede == "" || len(q.items)+len(q.unready) < q.overload.MaxQueued {
+		return nil
+	}
+	var dropped []*Request
+	kept := q.items[:0]
+	for _, queued := range q.items {
+		if queued.supersede == req.supersede {
+			dropped = append(dropped, queued)
+			continue
+		}
+		kept = append(kept, queued)
+	}
+	clear(q.items[len(kept):])
+	q.items = kept
+	q.requests -= len(dropped)
+	for unready, cancel := range q.unready {
+		if unready.supersede == req.supersede {
+			delete(q.unready, unready)
+			if cancel != nil {
+				cancel(errSuperseded)
+			}
+			dropped = append(dropped, unready)
+		}
+	}
+	return dropped
+}
+
+// full reports whether req is past the bound on requests waiting, with mu
+// held.
+func (q *queue) full(req *Request) bool {
+	return !req.IsNotification() && q.requests+len(q.unready) >= q.overload.MaxRequests
+}
+
+// drop answers a request superseded or refused by the queue.
+func (c *Conn) drop(req *Request) {
+	if c.overload.OnDrop != nil {
+		c.overload.OnDrop(req)
+	}
+	c.refuseCancelled(req)
+}
@@ -0,0 +1,61 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Pending is a request in flight on a Conn: an inbound one its handler has
+// yet to answer, or an outbound Call awaiting the peer's response.
+type Pending struct {
+	ID      ID
+	Method  string
+	Inbound bool
+	// Started is when the request was read or sent, and Age how long ago
+	// that was.
+	Started time.Time
+	Age     time.Duration
+	// Document is the document the request is about, as Options.Document
+	// names it; empty without one.
+	Document string
+}
+
+// outbound is a Call awaiting its response.
+type outbound struct {
+	ch      chan *wireMessage
+	method  string
+	params  json.RawMessage
+	started time.Time
+}
+
+// Pending lists the requests in flight each way, oldest first, so that a
+// debug page can show them and a supervisor can find handlers which are
+// stuck, or a peer which has stopped answering.
+func (c *Conn) Pending() []Pending {
+	var list []Pending
+	var params []json.RawMessage
+	c.inflightMu.Lock()
+	for id, req := range c.inflight {
+		list = append(list, Pending{ID: id, Method: req.method, Inbound: true, Started: req.started})
+		params = append(params, req.params)
+	}
+	c.inflightMu.Unlock()
+	c.pendingMu.Lock()
+	for id, call := range c.pending {
+		list = append(list, Pending{ID: id, Method: call.method, Started: call.started})
+		params = append(params, call.params)
+	}
+	c.pendingMu.Unlock()
+
+	now := c.clock.Now()
+	for i := range list {
+		p := &list[i]
+		p.Age = now.Sub(p.Started)
+		if c.document != nil {
+			p.Document = c.document(&Request{ID: json.RawMessage(p.ID.String()), Method: p.Method, Params: params[i], numbers: c.numbers})
+		}
+	}
+	slices.SortFunc(list, func(a, b Pending) int { return a.Started.Compare(b.Started) })
+	return list
+}
@@ -0,0 +1,36 @@
+package jsonrpc2
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// Stdio is the process's standard input and output as one stream, the
+// transport editors use when they launch a server. Nothing else may write
+// to stdout while it is in use; send logs to stderr.
+func Stdio() io.ReadWriteCloser {
+	return Pipe(os.Stdin, os.Stdout)
+}
+
+// Pipe joins a reader and a writer into one stream, such as the pipes of a
+// child process. Close closes whichever of them are io.Closers.
+func Pipe(r io.Reader, w io.Writer) io.ReadWriteCloser {
+	return &pipe{Reader: r, Writer: w}
+}
+
+type pipe struct {
+	io.Reader
+	io.Writer
+}
+
+func (p *pipe) Close() error {
+	var errs []error
+	if c, ok := p.Reader.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	if c, ok := p.Writer.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
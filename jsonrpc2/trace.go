@@ -0,0 +1,130 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+)
+
+// Tracer writes every message crossing a connection to Out in the format of
+// VS Code's language client traces, its "messages" level or, with Verbose,
+// its "verbose" one, so a server's trace reads like the editor's:
+//
+//	[Trace - 10:23:45 AM] Received request 'textDocument/hover - (12)'.
+//	[Trace - 10:23:45 AM] Sending response 'textDocument/hover - (12)'. Processing request took 3ms
+//
+// Messages are traced as they are on the wire, before Options.Rewrite.In
+// and after Options.Rewrite.Out. A Tracer serves one connection.
+type Tracer struct {
+	Out     io.Writer
+	Verbose bool
+	Clock   clock.Clock
+
+	mu sync.Mutex
+	// received and sent are the requests each way awaiting responses, by
+	// id, for the response's method and latency.
+	received map[ID]traced
+	sent     map[ID]traced
+}
+
+type traced struct {
+	method string
+	at     time.Time
+}
+
+// Received traces a message read from the peer.
+func (t *Tracer) Received(raw []byte) {
+	t.trace(raw, false)
+}
+
+// Sent traces a message written to the peer.
+func (t *Tracer) Sent(raw []byte) {
+	t.trace(raw, true)
+}
+
+func (t *Tracer) trace(raw []byte, sending bool) {
+	var msg wireMessage
+	if json.Unmarshal(raw, &msg) != nil {
+		return
+	}
+	now := clock.Or(t.Clock).Now()
+	verb := "Received"
+	if sending {
+		verb = "Sending"
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var line, data string
+	switch {
+	case msg.isResponse():
+		requests := t.sent
+		if sending {
+			requests = t.received
+		}
+		req, ok := requests[idKey(msg.ID)]
+		delete(requests, idKey(msg.ID))
+		if !ok {
+			req = traced{method: "unknown", at: now}
+		}
+		took := now.Sub(req.at).Milliseconds()
+		if sending {
+			line = fmt.Sprintf("Sending response '%s - (%s)'. Processing request took %dms", req.method, msg.ID, took)
+		} else {
+			line = fmt.Sprintf("Received response '%s - (%s)' in %dms.", req.method, msg.ID, took)
+			if msg.Error != nil {
+				line += fmt.Sprintf(" Request failed: %s (%d).", msg.Error.Message, msg.Error.Code)
+			}
+		}
+		switch {
+		case msg.Error != nil && len(msg.Error.Data) > 0:
+			data = "Error data: " + indent(msg.Error.Data)
+		case msg.Error != nil:
+		case len(msg.Result) > 0 && string(msg.Result) != "null":
+			data = "Result: " + indent(msg.Result)
+		default:
+			data = "No result returned."
+		}
+	case msg.ID != nil:
+		if t.received == nil {
+			t.received, t.sent = map[ID]traced{}, map[ID]traced{}
+		}
+		requests := t.received
+		if sending {
+			requests = t.sent
+		}
+		requests[idKey(msg.ID)] = traced{method: msg.Method, at: now}
+		line = fmt.Sprintf("%s request '%s - (%s)'.", verb, msg.Method, msg.ID)
+		data = params(msg.Params)
+	default:
+		line = fmt.Sprintf("%s notification '%s'.", verb, msg.Method)
+		data = params(msg.Params)
+	}
+
+	out := fmt.Sprintf("[Trace - %s] %s\n", now.Format("3:04:05 PM"), line)
+	if t.Verbose && data != "" {
+		out += data + "\n\n\n"
+	}
+	_, _ = io.WriteString(t.Out, out)
+}
+
+func params(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return "No parameters provided."
+	}
+	return "Params: " + indent(raw)
+}
+
+// indent formats JSON as JSON.stringify(value, null, 4) does.
+func indent(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if json.Indent(&buf, raw, "", "    ") != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
@@ -0,0 +1,168 @@
+// Package lineend detects a document's line ending convention and keeps
+// edits consistent with it, so formatting a CRLF file doesn't produce a diff
+// touching every line.
+package lineend
+
+import (
+	"strings"
+
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Style is a line ending convention.
+type Style int
+
+const (
+	LF Style = iota
+	CRLF
+	CR
+)
+
+func (s Style) String() string {
+	switch s {
+	case CRLF:
+		return "CRLF"
+	case CR:
+		return "CR"
+	default:
+		return "LF"
+	}
+}
+
+// Sequence is the line terminator for the style.
+func (s Style) Sequence() string {
+	switch s {
+	case CRLF:
+		return "\r\n"
+	case CR:
+		return "\r"
+	default:
+		return "\n"
+	}
+}
+
+// Info describes the line endings found in a document.
+type Info struct {
+	// Dominant is the most common style, LF for documents without line
+	// breaks or on a tie.
+	Dominant Style
+
+	// Counts of each style.
+	LF, CRLF, CR int
+}
+
+// Mixed reports whether more than one style is present.
+func (i Info) Mixed() bool {
+	present := 0
+	for _, n := range []int{i.LF, i.CRLF, i.CR} {
+		if n > 0 {
+			present++
+		}
+	}
+	return present > 1
+}
+
+// Detect counts the line endings in text.
+func Detect(text string) Info {
+	var info Info
+	for idx := 0; idx < len(text); idx++ {
+		switch text[idx] {
+		case '\n':
+			info.LF++
+		case '\r':
+			if idx+1 < len(text) && text[idx+1] == '\n' {
+				info.CRLF++
+				idx++
+			} else {
+				info.CR++
+			}
+		}
+	}
+	switch {
+	case info.CRLF > info.LF && info.CRLF >= info.CR:
+		info.Dominant = CRLF
+	case info.CR > info.LF && info.CR > info.CRLF:
+		info.Dominant = CR
+	}
+	return info
+}
+
+// Normalize rewrites every line ending in text to style.
+func Normalize(text string, style Style) string {
+	if !strings.ContainsRune(text, '\r') && style == LF {
+		return text
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	if style == LF {
+		return text
+	}
+	return strings.ReplaceAll(text, "\n", style.Sequence())
+}
+
+// Preserve rewrites the line endings of the edits' new text to the dominant
+// style of the document they apply to. Edit builders and formatters, which
+// usually produce LF, pass their output through it.
+func Preserve(content string, edits []protocol.TextEdit) []protocol.TextEdit {
+	style := Detect(content).Dominant
+	out := make([]protocol.TextEdit, len(edits))
+	for idx, edit := range edits {
+		edit.NewText = Normalize(edit.NewText, style)
+		out[idx] = edit
+	}
+	return out
+}
+
+// NormalizeEdits returns the minimal edits converting every line ending in
+// content to style: one edit per line break which differs, its position
+// counted in enc.
+func NormalizeEdits(content string, style Style, enc position.Encoding) []protocol.TextEdit {
+	var edits []protocol.TextEdit
+	want := style.Sequence()
+	var line uint32
+	// start is the offset of the current line.
+	start := 0
+	for idx := 0; idx < len(content); idx++ {
+		c := content[idx]
+		if c != '\n' && c != '\r' {
+			continue
+		}
+		got := string(c)
+		if c == '\r' && idx+1 < len(content) && content[idx+1] == '\n' {
+			got = "\r\n"
+		}
+		if got != want {
+			edits = append(edits, protocol.TextEdit{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: line, Character: uint32(position.Len(content[start:idx], enc))},
+					End:   protocol.Position{Line: line + 1},
+				},
+				NewText: want,
+			})
+		}
+		idx += len(got) - 1
+		start = idx + 1
+		line++
+	}
+	return edits
+}
+
+// NormalizeAction builds an opt-in source action converting the document to
+// style, or returns nil if there is nothing to change. enc is the
+// connection's position encoding.
+func NormalizeAction(uri protocol.DocumentURI, content string, style Style, enc position.Encoding) *protocol.CodeAction {
+	edits := NormalizeEdits(content, style, enc)
+	if len(edits) == 0 {
+		return nil
+	}
+	return &protocol.CodeAction{
+		Title: "Convert line endings to " + style.String(),
+		Kind:  ActionKind,
+		Edit:  &protocol.WorkspaceEdit{Changes: map[protocol.DocumentURI][]protocol.TextEdit{uri: edits}},
+	}
+}
+
+// ActionKind is the kind of the normalize action. Being a source action the
+// client only offers it when asked for source actions.
+const ActionKind = protocol.CodeActionSource + ".normalizeLineEndings"
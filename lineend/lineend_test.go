@@ -0,0 +1,82 @@
+package lineend
+
+import (
+	"testing"
+
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/textedit"
+)
+
+func TestNormalizeEditsPosition(t *testing.T) {
+	// The break follows a two byte, one unit character and a four byte,
+	// two unit one.
+	const content = "é😀\r\nx"
+	for enc, want := range map[position.Encoding]uint32{position.UTF8: 6, position.UTF16: 3, position.UTF32: 2} {
+		edits := NormalizeEdits(content, LF, enc)
+		if len(edits) != 1 {
+			t.Fatalf("%s: %d edits, want 1", enc, len(edits))
+		}
+		wantRange := protocol.Range{Start: protocol.Position{Character: want}, End: protocol.Position{Line: 1}}
+		if edits[0].Range != wantRange || edits[0].NewText != "\n" {
+			t.Errorf("%s: edit %+v, want %q over %+v", enc, edits[0], "\n", wantRange)
+		}
+	}
+}
+
+func TestNormalizeEdits(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"no breaks 😀",
+		"a\nb\nc",
+		"a\r\nb\r\n",
+		"a\rb\r",
+		"mixed\r\nlf\ncr\rend",
+		"😀 wide\r\n\r\né\n\n𝄞\r",
+		"\r\n\n\r",
+		"trailing cr\r",
+	} {
+		for _, style := range []Style{LF, CRLF, CR} {
+			for _, enc := range []position.Encoding{position.UTF8, position.UTF16, position.UTF32} {
+				edits := NormalizeEdits(content, style, enc)
+				got, err := textedit.Apply(content, edits, enc)
+				if err != nil {
+					t.Fatalf("%q to %s in %s: %v", content, style, enc, err)
+				}
+				if want := Normalize(content, style); got != want {
+					t.Errorf("%q to %s in %s: edits %+v give %q, want %q", content, style, enc, edits, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestNormalizeAction(t *testing.T) {
+	const uri protocol.DocumentURI = "file:///a.txt"
+	if action := NormalizeAction(uri, "a\nb\n", LF, position.UTF16); action != nil {
+		t.Fatalf("action %+v for a document already LF", action)
+	}
+	action := NormalizeAction(uri, "a😀\nb\n", CRLF, position.UTF16)
+	if action == nil || action.Kind != ActionKind || len(action.Edit.Changes[uri]) != 2 {
+		t.Fatalf("action %+v, want two edits to %s", action, uri)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		want  Style
+		mixed bool
+	}{
+		{"", LF, false},
+		{"a\r\nb\r\nc\n", CRLF, true},
+		{"a\r\nb\n", LF, true},
+		{"a\rb\r", CR, false},
+		{"a\r\n\r\n", CRLF, false},
+	} {
+		info := Detect(tc.text)
+		if info.Dominant != tc.want || info.Mixed() != tc.mixed {
+			t.Errorf("Detect(%q) = %+v, want %s, mixed %v", tc.text, info, tc.want, tc.mixed)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package locale translates user-facing text into the language of the
+// client's locale, InitializeParams.locale, such as "de" or "pt-BR". A
+// message is keyed by its English text, a fmt format, which a Catalog maps
+// to a translation per language tag; text with no translation is shown in
+// English, so only the messages translated need to be listed. lsplib's own
+// messages, such as progress titles and the warnings of initialize, are
+// looked up in Default, which servers extend with translations of them and
+// of their own messages, or replace with a Catalog of theirs through
+// lsp.Options.Catalog.
+package locale
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Default is the Catalog lsplib's messages are looked up in.
+var Default = &Catalog{}
+
+// Catalog holds translations by language tag. The zero value is ready to
+// use; it is safe for concurrent use.
+type Catalog struct {
+	mu sync.RWMutex
+	// messages holds the translations of each normalized tag, by key.
+	messages map[string]map[string]string
+}
+
+// Set translates the message key into format for tag.
+func (c *Catalog) Set(tag, key, format string) {
+	c.Add(tag, map[string]string{key: format})
+}
+
+// Add adds the translations of messages, by key, for tag.
+func (c *Catalog) Add(tag string, messages map[string]string) {
+	tag = normalize(tag)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.messages == nil {
+		c.messages = map[string]map[string]string{}
+	}
+	if c.messages[tag] == nil {
+		c.messages[tag] = map[string]string{}
+	}
+	for key, format := range messages {
+		c.messages[tag][key] = format
+	}
+}
+
+// LoadJSON adds the translations for tag of data, a JSON object mapping
+// keys to translations.
+func (c *Catalog) LoadJSON(tag string, data []byte) error {
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("locale: translations for %s: %w", tag, err)
+	}
+	c.Add(tag, messages)
+	return nil
+}
+
+// LoadFS adds the translations of the files <tag>.json in the directory
+// dir of fsys, such as an embedded "locales" directory holding de.json and
+// pt-BR.json.
+func (c *Catalog) LoadFS(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("locale: %w", err)
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("locale: %w", err)
+		}
+		if err := c.LoadJSON(strings.TrimSuffix(path.Base(file), ".json"), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Printer returns the Printer of c for locale. A nil c is Default.
+func (c *Catalog) Printer(locale string) Printer {
+	if c == nil {
+		c = Default
+	}
+	return Printer{catalog: c, tags: fallbacks(locale)}
+}
+
+func (c *Catalog) lookup(tags []string, key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, tag := range tags {
+		if format, ok := c.messages[tag][key]; ok {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// Printer formats messages in one locale. The zero value formats them in
+// English.
+type Printer struct {
+	catalog *Catalog
+	tags    []string
+}
+
+// Sprintf formats the translation of key, a fmt format, with args.
+func (p Printer) Sprintf(key string, args ...any) string {
+	return fmt.Sprintf(p.Text(key), args...)
+}
+
+// Text returns the translation of key, as it is, for text which isn't a
+// format, such as a title a server chose.
+func (p Printer) Text(key string) string {
+	if p.catalog == nil {
+		return key
+	}
+	if format, ok := p.catalog.lookup(p.tags, key); ok {
+		return format
+	}
+	return key
+}
+
+// Locale is the normalized locale p translates into, empty when none was
+// given.
+func (p Printer) Locale() string {
+	if len(p.tags) == 0 {
+		return ""
+	}
+	return p.tags[0]
+}
+
+// fallbacks lists the tags translations for locale are looked for under,
+// from the most specific: "zh-Hant-TW", "zh-Hant" and "zh".
+func fallbacks(locale string) []string {
+	tag := normalize(locale)
+	var tags []string
+	for tag != "" {
+		tags = append(tags, tag)
+		i := strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return tags
+}
+
+// normalize folds the spellings of a language tag clients send, such as
+// "pt_BR" and "pt-br", to one.
+func normalize(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
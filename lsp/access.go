@@ -0,0 +1,50 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsperror"
+)
+
+// CodeRequestFailed answers requests which were understood and valid but
+// refused, such as those Options.Allow denies.
+const CodeRequestFailed = lsperror.CodeRequestFailed
+
+// DenyMethods is an Options.Allow refusing the listed methods outright,
+// for servers which must never run them for a remote client.
+func DenyMethods(methods ...string) func(ctx context.Context, method string, params json.RawMessage) error {
+	denied := map[string]bool{}
+	for _, method := range methods {
+		denied[method] = true
+	}
+	return func(ctx context.Context, method string, params json.RawMessage) error {
+		if denied[method] {
+			return errors.New("not permitted")
+		}
+		return nil
+	}
+}
+
+// allow consults Options.Allow. A refused request gets the hook's error if
+// that is a *jsonrpc2.Error, otherwise CodeRequestFailed; a refused
+// notification is dropped.
+func (s *Server) allow(ctx context.Context, req *jsonrpc2.Request) (bool, error) {
+	if s.opts.Allow == nil || req.Method == "exit" {
+		return true, nil
+	}
+	err := s.opts.Allow(ctx, req.Method, req.Params)
+	if err == nil {
+		return true, nil
+	}
+	if req.IsNotification() {
+		return false, nil
+	}
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		return false, rpcErr
+	}
+	return false, jsonrpc2.NewError(CodeRequestFailed, "%s: %v", req.Method, err)
+}
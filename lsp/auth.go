@@ -0,0 +1,125 @@
+package lsp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// AuthenticateMethod is the request a client sends first, with
+// AuthenticateParams, on transports which carry no headers, such as TCP.
+const AuthenticateMethod = "$/authenticate"
+
+// CodeUnauthenticated answers requests from a client which has not
+// authenticated, and authentication attempts which fail. It is in the
+// range JSON-RPC reserves for implementation-defined server errors.
+const CodeUnauthenticated int64 = -32010
+
+// AuthenticateParams is the payload of AuthenticateMethod.
+type AuthenticateParams struct {
+	Token string `json:"token"`
+}
+
+// Verifier checks a bearer token, returning the subject it identifies.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (subject string, err error)
+}
+
+// VerifierFunc adapts a function to a Verifier.
+type VerifierFunc func(ctx context.Context, token string) (string, error)
+
+func (f VerifierFunc) Verify(ctx context.Context, token string) (string, error) {
+	return f(ctx, token)
+}
+
+// ErrBadToken is returned by StaticTokens for unknown tokens.
+var ErrBadToken = errors.New("lsp: invalid token")
+
+// StaticTokens verifies against a fixed set of tokens, each mapped to its
+// subject, comparing in constant time.
+func StaticTokens(tokens map[string]string) Verifier {
+	return VerifierFunc(func(ctx context.Context, token string) (string, error) {
+		subject, found := "", false
+		for known, sub := range tokens {
+			if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+				subject, found = sub, true
+			}
+		}
+		if !found {
+			return "", ErrBadToken
+		}
+		return subject, nil
+	})
+}
+
+// BearerToken extracts the token from an Authorization header value.
+func BearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+type subjectKey struct{}
+
+// Subject is the authenticated subject of the session a handler's ctx
+// belongs to; empty when the server has no Verifier.
+func Subject(ctx context.Context) string {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
+
+// Authenticate verifies a token the transport received out of band, such
+// as a WebSocket's Authorization header, before Serve. The client then
+// need not send AuthenticateMethod.
+func (s *Server) Authenticate(ctx context.Context, token string) error {
+	if s.opts.Verifier == nil {
+		return nil
+	}
+	subject, err := s.opts.Verifier.Verify(ctx, token)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.subject, s.authenticated = subject, true
+	s.mu.Unlock()
+	return nil
+}
+
+// authenticate gates messages on Options.Verifier, handling
+// AuthenticateMethod itself. It returns ctx carrying the subject once the
+// session is authenticated, and handled when the message needs no further
+// dispatch.
+func (s *Server) authenticate(ctx context.Context, req *jsonrpc2.Request) (_ context.Context, handled bool, err error) {
+	if s.opts.Verifier == nil {
+		return ctx, false, nil
+	}
+	s.mu.Lock()
+	subject, authenticated := s.subject, s.authenticated
+	s.mu.Unlock()
+	switch {
+	case req.Method == AuthenticateMethod && authenticated:
+		return ctx, true, jsonrpc2.NewError(jsonrpc2.CodeInvalidRequest, "already authenticated")
+	case req.Method == AuthenticateMethod:
+		var params AuthenticateParams
+		if err := req.UnmarshalParams(&params); err != nil {
+			return ctx, true, err
+		}
+		if err := s.Authenticate(ctx, params.Token); err != nil {
+			return ctx, true, jsonrpc2.NewError(CodeUnauthenticated, "authentication failed")
+		}
+		return ctx, true, nil
+	case authenticated:
+		return context.WithValue(ctx, subjectKey{}, subject), false, nil
+	case req.Method == "exit":
+		// exit only ends the connection, which the client can do anyway.
+		return ctx, false, nil
+	case req.IsNotification():
+		return ctx, true, nil
+	}
+	return ctx, true, jsonrpc2.NewError(CodeUnauthenticated, "%s before %s", req.Method, AuthenticateMethod)
+}
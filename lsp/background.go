@@ -0,0 +1,102 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// background is the work Go has running.
+type background struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running int
+	stopped bool
+}
+
+// Go runs fn in a goroutine tied to the session rather than to the request
+// which started it, for work a handler kicks off but doesn't wait for, such
+// as re-indexing after a save. fn's context keeps ctx's values but not its
+// cancellation, and is cancelled at shutdown, which waits for fn to return,
+// as does Serve when the connection ends. A panic in fn is recovered and
+// logged, as is an error other than the context's own; neither takes the
+// server down.
+//
+// Go reports false, without running fn, once the session is shutting down.
+func (s *Server) Go(ctx context.Context, fn func(ctx context.Context) error) bool {
+	s.mu.Lock()
+	bg := &s.background
+	if bg.stopped {
+		s.mu.Unlock()
+		return false
+	}
+	if bg.ctx == nil {
+		bg.ctx, bg.cancel = context.WithCancel(context.Background())
+	}
+	bg.running++
+	bg.wg.Add(1)
+	session := bg.ctx
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(session, cancel)
+	go func() {
+		defer func() {
+			stop()
+			cancel()
+			s.mu.Lock()
+			bg.running--
+			s.mu.Unlock()
+			bg.wg.Done()
+		}()
+		defer func() {
+			if value := recover(); value != nil {
+				s.logBackground(ctx, fmt.Sprintf("background work panicked: %v", value),
+					slog.Any("panic", value), slog.String("stack", string(debug.Stack())))
+			}
+		}()
+		if err := fn(ctx); err != nil && !errors.Is(err, ctx.Err()) {
+			s.logBackground(ctx, fmt.Sprintf("background work failed: %v", err), slog.Any("error", err))
+		}
+	}()
+	return true
+}
+
+// Background is how many goroutines started with Go are running.
+func (s *Server) Background() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.background.running
+}
+
+// stopBackground cancels the work Go has running, waits for it to return
+// and refuses more.
+func (s *Server) stopBackground() {
+	s.mu.Lock()
+	bg := &s.background
+	bg.stopped = true
+	if bg.cancel != nil {
+		bg.cancel()
+	}
+	s.mu.Unlock()
+	bg.wg.Wait()
+}
+
+// logBackground logs a failure of background work to Options.Logger, or to
+// the client when there is none.
+func (s *Server) logBackground(ctx context.Context, msg string, attrs ...slog.Attr) {
+	if s.opts.Logger != nil {
+		s.opts.Logger.LogAttrs(ctx, slog.LevelError, msg, attrs...)
+		return
+	}
+	_ = s.Notify(context.WithoutCancel(ctx), "window/logMessage", &protocol.LogMessageParams{
+		Type:    protocol.MessageError,
+		Message: msg,
+	})
+}
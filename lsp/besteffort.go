@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BestEffort runs fn to gather a result piece by piece, for requests where
+// part of an answer beats a timeout, such as completion or workspace
+// symbols. fn passes what it finds to add. When fn returns in time its
+// items are returned with complete true. When ctx has a deadline and fn is
+// still running margin before it, the items added so far are returned at
+// once with complete false, and fn's context is cancelled; BestEffort
+// doesn't wait for fn, and add drops whatever it adds later. Answer with
+// complete false marked incomplete where the protocol allows, as
+// CompletionList.isIncomplete does, so the client asks again.
+//
+// A request the client cancels returns ctx's error, as does fn's own error
+// before the cutoff. middleware.Deadline gives requests their deadlines.
+func BestEffort[T any](ctx context.Context, margin time.Duration, fn func(ctx context.Context, add func(items ...T)) error) (items []T, complete bool, _ error) {
+	var (
+		mu       sync.Mutex
+		gathered []T
+		cut      bool
+	)
+	add := func(items ...T) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !cut {
+			gathered = append(gathered, items...)
+		}
+	}
+	cutoff := func() []T {
+		mu.Lock()
+		defer mu.Unlock()
+		cut = true
+		return slices.Clip(gathered)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		if err := fn(ctx, add); err != nil {
+			return nil, false, err
+		}
+		return cutoff(), true, nil
+	}
+	workCtx, cancel := context.WithDeadline(ctx, deadline.Add(-margin))
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- fn(workCtx, add) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-workCtx.Done():
+		// fn may have finished as the cutoff came.
+		select {
+		case err = <-done:
+		default:
+			if ctx.Err() != nil {
+				return nil, false, ctx.Err()
+			}
+			return cutoff(), false, nil
+		}
+	}
+	switch {
+	case err == nil:
+		return cutoff(), true, nil
+	case workCtx.Err() == nil || ctx.Err() != nil:
+		return nil, false, err
+	}
+	return cutoff(), false, nil
+}
@@ -0,0 +1,85 @@
+package lsp
+
+// methodCapabilities maps client-to-server methods to the server capability
+// which advertises them, as a dotted path into ServerCapabilities.
+var methodCapabilities = map[string]string{
+	"textDocument/completion":                "completionProvider",
+	"completionItem/resolve":                 "completionProvider.resolveProvider",
+	"textDocument/hover":                     "hoverProvider",
+	"textDocument/signatureHelp":             "signatureHelpProvider",
+	"textDocument/declaration":               "declarationProvider",
+	"textDocument/definition":                "definitionProvider",
+	"textDocument/typeDefinition":            "typeDefinitionProvider",
+	"textDocument/implementation":            "implementationProvider",
+	"textDocument/references":                "referencesProvider",
+	"textDocument/documentHighlight":         "documentHighlightProvider",
+	"textDocument/documentSymbol":            "documentSymbolProvider",
+	"textDocument/codeAction":                "codeActionProvider",
+	"codeAction/resolve":                     "codeActionProvider.resolveProvider",
+	"textDocument/codeLens":                  "codeLensProvider",
+	"codeLens/resolve":                       "codeLensProvider.resolveProvider",
+	"textDocument/documentLink":              "documentLinkProvider",
+	"documentLink/resolve":                   "documentLinkProvider.resolveProvider",
+	"textDocument/documentColor":             "colorProvider",
+	"textDocument/colorPresentation":         "colorProvider",
+	"textDocument/formatting":                "documentFormattingProvider",
+	"textDocument/rangeFormatting":           "documentRangeFormattingProvider",
+	"textDocument/onTypeFormatting":          "documentOnTypeFormattingProvider",
+	"textDocument/rename":                    "renameProvider",
+	"textDocument/prepareRename":             "renameProvider.prepareProvider",
+	"textDocument/foldingRange":              "foldingRangeProvider",
+	"textDocument/selectionRange":            "selectionRangeProvider",
+	"textDocument/prepareCallHierarchy":      "callHierarchyProvider",
+	"callHierarchy/incomingCalls":            "callHierarchyProvider",
+	"callHierarchy/outgoingCalls":            "callHierarchyProvider",
+	"textDocument/prepareTypeHierarchy":      "typeHierarchyProvider",
+	"typeHierarchy/supertypes":               "typeHierarchyProvider",
+	"typeHierarchy/subtypes":                 "typeHierarchyProvider",
+	"textDocument/semanticTokens/full":       "semanticTokensProvider",
+	"textDocument/semanticTokens/full/delta": "semanticTokensProvider.full.delta",
+	"textDocument/semanticTokens/range":      "semanticTokensProvider.range",
+	"textDocument/linkedEditingRange":        "linkedEditingRangeProvider",
+	"textDocument/moniker":                   "monikerProvider",
+	"textDocument/inlayHint":                 "inlayHintProvider",
+	"inlayHint/resolve":                      "inlayHintProvider.resolveProvider",
+	"textDocument/inlineValue":               "inlineValueProvider",
+	"textDocument/diagnostic":                "diagnosticProvider",
+	"workspace/diagnostic":                   "diagnosticProvider.workspaceDiagnostics",
+	"workspace/symbol":                       "workspaceSymbolProvider",
+	"workspaceSymbol/resolve":                "workspaceSymbolProvider.resolveProvider",
+	"workspace/executeCommand":               "executeCommandProvider",
+	"textDocument/didOpen":                   "textDocumentSync",
+	"textDocument/didChange":                 "textDocumentSync",
+	"textDocument/didClose":                  "textDocumentSync",
+	"textDocument/didSave":                   "textDocumentSync",
+	"textDocument/willSave":                  "textDocumentSync.willSave",
+	"textDocument/willSaveWaitUntil":         "textDocumentSync.willSaveWaitUntil",
+	"workspace/willCreateFiles":              "workspace.fileOperations.willCreate",
+	"workspace/didCreateFiles":               "workspace.fileOperations.didCreate",
+	"workspace/willRenameFiles":              "workspace.fileOperations.willRename",
+	"workspace/didRenameFiles":               "workspace.fileOperations.didRename",
+	"workspace/willDeleteFiles":              "workspace.fileOperations.willDelete",
+	"workspace/didDeleteFiles":               "workspace.fileOperations.didDelete",
+	"workspace/didChangeWorkspaceFolders":    "workspace.workspaceFolders.changeNotifications",
+	"notebookDocument/didOpen":               "notebookDocumentSync",
+	"notebookDocument/didChange":             "notebookDocumentSync",
+	"notebookDocument/didSave":               "notebookDocumentSync",
+	"notebookDocument/didClose":              "notebookDocumentSync",
+}
+
+// CapabilityFor returns the server capability advertising a method, as a
+// dotted path such as "semanticTokensProvider.range". Methods every server
+// handles, like initialize or didChangeConfiguration, have none.
+func CapabilityFor(method string) (string, bool) {
+	capability, ok := methodCapabilities[method]
+	return capability, ok
+}
+
+// MethodCapabilities returns every method with an advertising capability.
+func MethodCapabilities() map[string]string {
+	out := make(map[string]string, len(methodCapabilities))
+	for method, capability := range methodCapabilities {
+		out[method] = capability
+	}
+	return out
+}
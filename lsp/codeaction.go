@@ -0,0 +1,49 @@
+package lsp
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// KindMatches reports whether kind falls under requested in the code action
+// kind hierarchy: it is requested itself or one of its dot-separated
+// sub-kinds, so "refactor" matches "refactor.extract.function" but not
+// "refactoring". The empty kind matches every kind.
+func KindMatches(requested, kind protocol.CodeActionKind) bool {
+	return requested == protocol.CodeActionEmpty || kind == requested || strings.HasPrefix(string(kind), string(requested)+".")
+}
+
+// NormalizeKinds returns the set of kinds, sorted, without duplicates and
+// without kinds a broader one in the set already covers, as servers
+// advertise them in CodeActionOptions.
+func NormalizeKinds(kinds []protocol.CodeActionKind) []protocol.CodeActionKind {
+	sorted := slices.Clone(kinds)
+	slices.Sort(sorted)
+	out := make([]protocol.CodeActionKind, 0, len(sorted))
+	for _, kind := range sorted {
+		// Sorting puts broader kinds before the kinds they cover.
+		if slices.ContainsFunc(out, func(broader protocol.CodeActionKind) bool { return KindMatches(broader, kind) }) {
+			continue
+		}
+		out = append(out, kind)
+	}
+	return out
+}
+
+// FilterCodeActions keeps the actions whose kind matches one of only, a
+// request's CodeActionContext.Only; every action when only is empty.
+// Actions without a kind match only the empty kind.
+func FilterCodeActions(only []protocol.CodeActionKind, actions []protocol.CodeAction) []protocol.CodeAction {
+	if len(only) == 0 {
+		return actions
+	}
+	out := make([]protocol.CodeAction, 0, len(actions))
+	for _, action := range actions {
+		if slices.ContainsFunc(only, func(requested protocol.CodeActionKind) bool { return KindMatches(requested, action.Kind) }) {
+			out = append(out, action)
+		}
+	}
+	return out
+}
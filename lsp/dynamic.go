@@ -0,0 +1,301 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Dynamic is a feature registered with the client at runtime instead of
+// advertised at initialize, such as formatting a configuration change has
+// just enabled. A DynamicRegistration built by lspschema's generated
+// constructors converts to it, with options of the method's type.
+type Dynamic struct {
+	Method string
+
+	// RegisterOptions are the method's registration options, such as a
+	// documentSelector; nil for none.
+	RegisterOptions any
+}
+
+// dynamicState is what SetDynamic has registered.
+type dynamicState struct {
+	seq int
+	// registered maps each method to its registration's id and options as
+	// JSON, for comparing with the next set.
+	registered map[string]dynamicReg
+	// managed are the methods SetDynamic has ever been given, and current
+	// those it was given last; managed methods not current are refused.
+	managed map[string]bool
+	current map[string]bool
+}
+
+type dynamicReg struct {
+	id      string
+	options string
+}
+
+// SetDynamic makes the features registered with the client exactly the
+// given ones: new methods are registered, those left out are unregistered
+// and those whose options changed are registered anew, all in one request
+// each, registrations first so no feature goes missing in between. Methods
+// given to SetDynamic before but not now are refused when dispatched,
+// whether or not the client honoured the unregistration.
+//
+// Clients declare dynamic registration support per feature. The methods of
+// features a client can't register dynamically are returned; a server may
+// advertise them statically instead on the next initialize. A feature the
+// server already advertised statically is an error, as the client would
+// have it twice.
+func (s *Server) SetDynamic(ctx context.Context, features ...Dynamic) (unsupported []string, err error) {
+	s.dynamicMu.Lock()
+	defer s.dynamicMu.Unlock()
+	return s.setDynamic(ctx, features)
+}
+
+// Register registers features with the client alongside those already
+// registered, as SetDynamic would given both. A method already registered
+// is registered anew if its options changed.
+func (s *Server) Register(ctx context.Context, features ...Dynamic) (unsupported []string, err error) {
+	s.dynamicMu.Lock()
+	defer s.dynamicMu.Unlock()
+	adding := map[string]bool{}
+	for _, f := range features {
+		adding[f.Method] = true
+	}
+	return s.setDynamic(ctx, append(s.currentDynamic(func(method string) bool { return !adding[method] }), features...))
+}
+
+// Unregister unregisters the given methods' features, leaving the rest
+// registered. The methods are refused from then on, as those SetDynamic
+// leaves out are.
+func (s *Server) Unregister(ctx context.Context, methods ...string) error {
+	s.dynamicMu.Lock()
+	defer s.dynamicMu.Unlock()
+	removing := map[string]bool{}
+	for _, method := range methods {
+		removing[method] = true
+	}
+	_, err := s.setDynamic(ctx, s.currentDynamic(func(method string) bool { return !removing[method] }))
+	return err
+}
+
+// currentDynamic is the features SetDynamic was last given whose methods
+// keep allows, with the options they were registered with.
+func (s *Server) currentDynamic(keep func(method string) bool) []Dynamic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var features []Dynamic
+	for _, method := range sortedKeys(s.dynamic.current) {
+		if !keep(method) {
+			continue
+		}
+		f := Dynamic{Method: method}
+		if reg, ok := s.dynamic.registered[method]; ok {
+			f.RegisterOptions = json.RawMessage(reg.options)
+		}
+		features = append(features, f)
+	}
+	return features
+}
+
+// setDynamic implements SetDynamic, with dynamicMu held.
+func (s *Server) setDynamic(ctx context.Context, features []Dynamic) (unsupported []string, err error) {
+	state := &s.dynamic
+
+	want := map[string]string{}
+	for _, f := range features {
+		options, err := json.Marshal(f.RegisterOptions)
+		if err != nil {
+			return nil, fmt.Errorf("lsp: registration options for %s: %w", f.Method, err)
+		}
+		if s.Registered(f.Method) == RegisteredStatic {
+			return nil, fmt.Errorf("lsp: %s is advertised statically", f.Method)
+		}
+		if !s.ClientSupports(dynamicCapability(f.Method)) {
+			unsupported = append(unsupported, f.Method)
+			continue
+		}
+		want[f.Method] = string(options)
+	}
+
+	var add []protocol.Registration
+	var remove []protocol.Unregistration
+	next := map[string]dynamicReg{}
+	for _, method := range sortedKeys(want) {
+		if reg, ok := state.registered[method]; ok && reg.options == want[method] {
+			next[method] = reg
+			continue
+		}
+		state.seq++
+		reg := dynamicReg{id: fmt.Sprintf("lsplib/dynamic/%d", state.seq), options: want[method]}
+		add = append(add, protocol.Registration{ID: reg.id, Method: method, RegisterOptions: json.RawMessage(reg.options)})
+		next[method] = reg
+	}
+	for _, method := range sortedKeys(state.registered) {
+		if reg := state.registered[method]; next[method] != reg {
+			remove = append(remove, protocol.Unregistration{ID: reg.id, Method: method})
+		}
+	}
+
+	if len(add) > 0 {
+		if err := s.Call(ctx, "client/registerCapability", &protocol.RegistrationParams{Registrations: add}, nil); err != nil {
+			return unsupported, fmt.Errorf("lsp: registering %d features: %w", len(add), err)
+		}
+	}
+	s.mu.Lock()
+	if state.managed == nil {
+		state.managed = map[string]bool{}
+	}
+	state.current = map[string]bool{}
+	for _, f := range features {
+		state.managed[f.Method] = true
+		state.current[f.Method] = true
+	}
+	state.registered = next
+	s.mu.Unlock()
+	if len(remove) > 0 {
+		if err := s.Call(ctx, "client/unregisterCapability", &protocol.UnregistrationParams{Unregisterations: remove}, nil); err != nil {
+			return unsupported, fmt.Errorf("lsp: unregistering %d features: %w", len(remove), err)
+		}
+	}
+	return unsupported, nil
+}
+
+// Registered is how the client learned of a feature.
+type Registered int
+
+const (
+	NotRegistered Registered = iota
+	// RegisteredStatic features were advertised in the initialize result.
+	RegisteredStatic
+	// RegisteredDynamic features were registered with SetDynamic or
+	// Register.
+	RegisteredDynamic
+)
+
+// Registered reports how the client learned of method's feature: from the
+// capabilities the server answered initialize with, by dynamic registration,
+// or not at all.
+func (s *Server) Registered(method string) Registered {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.dynamic.registered[method]; ok {
+		return RegisteredDynamic
+	}
+	capability, ok := CapabilityFor(method)
+	if !ok {
+		return NotRegistered
+	}
+	switch string(lookup(s.advertised, capability)) {
+	case "", "null", "false", "0":
+		return NotRegistered
+	}
+	return RegisteredStatic
+}
+
+// dynamicWithdrawn reports whether SetDynamic was once given method but
+// no longer is.
+func (s *Server) dynamicWithdrawn(method string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dynamic.managed[method] && !s.dynamic.current[method]
+}
+
+func (s *Server) refuseWithdrawn(req *jsonrpc2.Request) (any, error) {
+	if req.IsNotification() {
+		return nil, nil
+	}
+	return nil, jsonrpc2.NewError(jsonrpc2.CodeMethodNotFound, "%s is not currently registered", req.Method)
+}
+
+// dynamicCapabilities are the client capabilities declaring dynamic
+// registration support for methods which don't follow the rule in
+// dynamicCapability.
+var dynamicCapabilities = map[string]string{
+	"textDocument/didOpen":                   "textDocument.synchronization",
+	"textDocument/didChange":                 "textDocument.synchronization",
+	"textDocument/didClose":                  "textDocument.synchronization",
+	"textDocument/didSave":                   "textDocument.synchronization",
+	"textDocument/willSave":                  "textDocument.synchronization",
+	"textDocument/willSaveWaitUntil":         "textDocument.synchronization",
+	"textDocument/documentColor":             "textDocument.colorProvider",
+	"textDocument/colorPresentation":         "textDocument.colorProvider",
+	"textDocument/prepareCallHierarchy":      "textDocument.callHierarchy",
+	"textDocument/prepareTypeHierarchy":      "textDocument.typeHierarchy",
+	"callHierarchy/incomingCalls":            "textDocument.callHierarchy",
+	"callHierarchy/outgoingCalls":            "textDocument.callHierarchy",
+	"typeHierarchy/supertypes":               "textDocument.typeHierarchy",
+	"typeHierarchy/subtypes":                 "textDocument.typeHierarchy",
+	"textDocument/prepareRename":             "textDocument.rename",
+	"completionItem/resolve":                 "textDocument.completion",
+	"codeAction/resolve":                     "textDocument.codeAction",
+	"codeLens/resolve":                       "textDocument.codeLens",
+	"documentLink/resolve":                   "textDocument.documentLink",
+	"inlayHint/resolve":                      "textDocument.inlayHint",
+	"workspaceSymbol/resolve":                "workspace.symbol",
+	"workspace/diagnostic":                   "textDocument.diagnostic",
+	"textDocument/semanticTokens/full":       "textDocument.semanticTokens",
+	"textDocument/semanticTokens/full/delta": "textDocument.semanticTokens",
+	"textDocument/semanticTokens/range":      "textDocument.semanticTokens",
+	"workspace/willCreateFiles":              "workspace.fileOperations",
+	"workspace/didCreateFiles":               "workspace.fileOperations",
+	"workspace/willRenameFiles":              "workspace.fileOperations",
+	"workspace/didRenameFiles":               "workspace.fileOperations",
+	"workspace/willDeleteFiles":              "workspace.fileOperations",
+	"workspace/didDeleteFiles":               "workspace.fileOperations",
+	"notebookDocument/didOpen":               "notebookDocument.synchronization",
+	"notebookDocument/didChange":             "notebookDocument.synchronization",
+	"notebookDocument/didSave":               "notebookDocument.synchronization",
+	"notebookDocument/didClose":              "notebookDocument.synchronization",
+}
+
+// dynamicCapability is the path of the client capability declaring
+// dynamic registration support for method: that of its feature, which for
+// most methods is the method with its slashes as dots.
+func dynamicCapability(method string) string {
+	feature, ok := dynamicCapabilities[method]
+	if !ok {
+		feature = strings.ReplaceAll(method, "/", ".")
+	}
+	return feature + ".dynamicRegistration"
+}
+
+// lookup returns the value at a dotted path in a JSON object, nil when
+// there is none.
+func lookup(raw json.RawMessage, path string) json.RawMessage {
+	for _, key := range strings.Split(path, ".") {
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(raw, &obj) != nil {
+			return nil
+		}
+		raw = obj[key]
+	}
+	return raw
+}
+
+// ClientSupports reports whether the client set the boolean capability at
+// a dotted path such as "textDocument.formatting.dynamicRegistration",
+// including capabilities protocol.ClientCapabilities doesn't model. It is
+// false before initialize.
+func (s *Server) ClientSupports(path string) bool {
+	s.mu.Lock()
+	raw := s.rawCapabilities
+	s.mu.Unlock()
+	var set bool
+	return json.Unmarshal(lookup(raw, path), &set) == nil && set
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
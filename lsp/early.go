@@ -0,0 +1,95 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// DefaultEarlyNotifications is the number of notifications held back until
+// initialized when Options.EarlyNotifications is zero.
+const DefaultEarlyNotifications = 256
+
+// early holds the notifications sent before the client's initialized,
+// which the specification forbids the server to send until then.
+type early struct {
+	// sent is set once they are flushed, and notifications go straight to
+	// the connection.
+	sent atomic.Bool
+
+	mu      sync.Mutex
+	held    []heldNotification
+	dropped int
+}
+
+type heldNotification struct {
+	ctx    context.Context
+	method string
+	params json.RawMessage
+}
+
+// hold keeps a notification sent before initialized, reporting false if it
+// is to be sent now. Its params are encoded at once, as the caller may
+// change them once Notify returns. Beyond the cap the oldest held is
+// dropped: a later publishDiagnostics for a document supersedes an earlier
+// one, and a log's latest lines say most.
+//
+// $/progress is never held: the specification lets the server report
+// progress on initialize's workDoneToken while initialize runs, and
+// progress shown only once the work is done is no use.
+func (s *Server) hold(ctx context.Context, method string, params any) (bool, error) {
+	e := &s.early
+	if method == "$/progress" || e.sent.Load() {
+		return false, nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sent.Load() {
+		return false, nil
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return true, err
+	}
+	limit := s.opts.EarlyNotifications
+	if limit == 0 {
+		limit = DefaultEarlyNotifications
+	}
+	if limit < 0 {
+		return true, nil
+	}
+	if len(e.held) >= limit {
+		e.held = append(e.held[:0], e.held[1:]...)
+		e.dropped++
+	}
+	e.held = append(e.held, heldNotification{ctx: context.WithoutCancel(ctx), method: method, params: raw})
+	return true, nil
+}
+
+// flushEarly sends the notifications held back, in the order they were
+// sent, once the client is initialized. Notifications sent meanwhile wait
+// for it, so none overtakes those held.
+func (s *Server) flushEarly(ctx context.Context) {
+	e := &s.early
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sent.Load() {
+		return
+	}
+	conn := s.Conn()
+	if e.dropped > 0 {
+		p := s.Printer()
+		_ = conn.Notify(ctx, "window/logMessage", &protocol.LogMessageParams{
+			Type:    protocol.MessageWarning,
+			Message: p.Sprintf("%d notifications sent before initialized were dropped", e.dropped),
+		})
+	}
+	for _, n := range e.held {
+		_ = conn.Notify(n.ctx, n.method, n.params)
+	}
+	e.held, e.dropped = nil, 0
+	e.sent.Store(true)
+}
@@ -0,0 +1,168 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pentops/lsplib/config"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Feature is a part of the server users can switch off from their editor
+// settings, by setting it to false in initializationOptions:
+//
+//	{"features": {"semanticTokens": false}}
+//
+// A disabled feature is not advertised and its methods are not dispatched.
+type Feature struct {
+	// Name is the key under the features object.
+	Name string
+
+	// Methods are refused while the feature is disabled: requests fail with
+	// CodeMethodNotFound and notifications are dropped.
+	Methods []string
+
+	// Withdraw removes the feature from the advertised capabilities.
+	Withdraw func(caps *protocol.ServerCapabilities)
+}
+
+// DefaultFeatures covers the capabilities in protocol.ServerCapabilities.
+var DefaultFeatures = []Feature{
+	{Name: "completion", Methods: []string{"textDocument/completion", "completionItem/resolve"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.CompletionProvider = nil }},
+	{Name: "hover", Methods: []string{"textDocument/hover"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.HoverProvider = nil }},
+	{Name: "signatureHelp", Methods: []string{"textDocument/signatureHelp"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.SignatureHelpProvider = nil }},
+	{Name: "definition", Methods: []string{"textDocument/definition"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.DefinitionProvider = nil }},
+	{Name: "references", Methods: []string{"textDocument/references"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.ReferencesProvider = nil }},
+	{Name: "documentSymbol", Methods: []string{"textDocument/documentSymbol"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.DocumentSymbolProvider = nil }},
+	{Name: "codeAction", Methods: []string{"textDocument/codeAction", "codeAction/resolve"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.CodeActionProvider = nil }},
+	{Name: "formatting", Methods: []string{"textDocument/formatting"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.DocumentFormattingProvider = nil }},
+	{Name: "rename", Methods: []string{"textDocument/rename", "textDocument/prepareRename"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.RenameProvider = nil }},
+	{Name: "semanticTokens", Methods: []string{
+		"textDocument/semanticTokens/full",
+		"textDocument/semanticTokens/full/delta",
+		"textDocument/semanticTokens/range",
+	}, Withdraw: func(c *protocol.ServerCapabilities) { c.SemanticTokensProvider = nil }},
+	{Name: "executeCommand", Methods: []string{"workspace/executeCommand"},
+		Withdraw: func(c *protocol.ServerCapabilities) { c.ExecuteCommandProvider = nil }},
+}
+
+// featureSet is the negotiated state of a session's features.
+type featureSet struct {
+	disabled map[string]bool
+	methods  map[string]bool
+}
+
+// applyFeatures reads the features object from the initialize params and
+// withdraws every disabled feature from result. Unknown names are returned so
+// they can be reported.
+func (s *Server) applyFeatures(params *protocol.InitializeParams, result *protocol.InitializeResult) (featureSet, []string) {
+	set := featureSet{disabled: map[string]bool{}, methods: map[string]bool{}}
+	var options struct {
+		Features map[string]bool `json:"features"`
+	}
+	if len(params.InitializationOptions) == 0 || json.Unmarshal(params.InitializationOptions, &options) != nil {
+		return set, nil
+	}
+
+	known := map[string]Feature{}
+	for _, feature := range s.features() {
+		known[feature.Name] = feature
+	}
+	var unknown []string
+	for name, enabled := range options.Features {
+		feature, ok := known[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if enabled {
+			continue
+		}
+		set.disabled[name] = true
+		for _, method := range feature.Methods {
+			set.methods[method] = true
+		}
+		if feature.Withdraw != nil {
+			feature.Withdraw(&result.Capabilities)
+		}
+	}
+	sort.Strings(unknown)
+	return set, unknown
+}
+
+func (s *Server) features() []Feature {
+	if s.opts.Features != nil {
+		return s.opts.Features
+	}
+	return DefaultFeatures
+}
+
+// FeatureEnabled reports whether the user left a feature switched on. Every
+// feature is enabled before initialize.
+func (s *Server) FeatureEnabled(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.featureSet.disabled[name]
+}
+
+func (s *Server) methodDisabled(method string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.featureSet.methods[method]
+}
+
+func (s *Server) refuseDisabled(req *jsonrpc2.Request) (any, error) {
+	if req.IsNotification() {
+		return nil, nil
+	}
+	return nil, jsonrpc2.NewError(jsonrpc2.CodeMethodNotFound, "%s is disabled by initializationOptions.features", req.Method)
+}
+
+func (s *Server) reportUnknownFeatures(ctx context.Context, unknown []string) {
+	if len(unknown) == 0 {
+		return
+	}
+	_ = s.Notify(ctx, "window/logMessage", &protocol.LogMessageParams{
+		Type:    protocol.MessageWarning,
+		Message: s.Printer().Sprintf("unknown features in initializationOptions: %s", strings.Join(unknown, ", ")),
+	})
+}
+
+// reportInvalidOptions shows the user the initializationOptions which don't
+// fit Options.InitializationOptions. The features object is left to
+// applyFeatures.
+func (s *Server) reportInvalidOptions(ctx context.Context, raw json.RawMessage) {
+	if s.opts.InitializationOptions == nil || len(raw) == 0 {
+		return
+	}
+	var object map[string]json.RawMessage
+	if json.Unmarshal(raw, &object) == nil && object != nil {
+		delete(object, "features")
+		raw, _ = json.Marshal(object)
+	}
+	problems := config.Validate(raw, s.opts.InitializationOptions)
+	if len(problems) == 0 {
+		return
+	}
+	lines := make([]string, len(problems))
+	for i, problem := range problems {
+		problem.Path = strings.TrimSuffix("initializationOptions."+problem.Path, ".")
+		lines[i] = problem.String()
+	}
+	_ = s.Notify(ctx, "window/showMessage", &protocol.ShowMessageParams{
+		Type:    protocol.MessageWarning,
+		Message: s.Printer().Sprintf("invalid initializationOptions: %s", strings.Join(lines, "; ")),
+	})
+}
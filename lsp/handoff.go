@@ -0,0 +1,120 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/pentops/lsplib/handoff"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Handoff passes the session to cmd, a new server process, as after an
+// upgrade: it stops reading in, the Input the session's stream reads,
+// waits for the messages already read to be handled, stops warmup and
+// background work, and starts cmd on the same input and output with state,
+// to which it adds the initialize params, the authenticated subject and
+// what was read but not handled. The caller fills in the documents,
+// settings and its own data. Serve then returns; the process should wait
+// for cmd and exit with its code.
+//
+// Call it outside any handler and any work started with Go, such as on a
+// signal, as it waits for those. Once in has stopped, a failure leaves a
+// session which can't continue, and the process should exit. Outbound
+// calls still waiting for the client fail with jsonrpc2.ErrClosed.
+func (s *Server) Handoff(ctx context.Context, in *handoff.Input, cmd *exec.Cmd, state *handoff.State) error {
+	s.mu.Lock()
+	conn, running := s.conn, s.state == stateRunning
+	initialize, subject := s.initializeRaw, s.subject
+	s.mu.Unlock()
+	if conn == nil || !running {
+		return errors.New("lsp: handoff needs an initialized session")
+	}
+	pending, err := in.Stop(ctx)
+	if err != nil {
+		return err
+	}
+	if err := conn.Drain(ctx); err != nil {
+		return fmt.Errorf("lsp: handoff: %w", err)
+	}
+	s.stopWarmup()
+	s.stopBackground()
+
+	state.Initialize, state.Subject, state.Pending = initialize, subject, pending
+	if err := handoff.Start(cmd, in, state); err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Resume serves a session a predecessor handed over with Handoff, over
+// stream, as Serve does: initialize is replayed without answering it, then
+// initialized, a didOpen of each document, the settings as
+// workspace/didChangeConfiguration and the pending input go through the
+// handlers before what stream brings. Restore state.Data first. The stream
+// must use the Content-Length framing.
+func (s *Server) Resume(ctx context.Context, stream io.ReadWriter, state *handoff.State) error {
+	s.mu.Lock()
+	if s.state != stateNew {
+		s.mu.Unlock()
+		return errors.New("lsp: resume needs a new server")
+	}
+	s.state = stateInitializing
+	if state.Subject != "" {
+		s.subject, s.authenticated = state.Subject, true
+	}
+	s.mu.Unlock()
+	req := &jsonrpc2.Request{ID: json.RawMessage("0"), Method: "initialize", Params: state.Initialize}
+	if _, err := s.initialize(ctx, req); err != nil {
+		s.resetInitialize()
+		return fmt.Errorf("lsp: resuming: %w", err)
+	}
+
+	var replay bytes.Buffer
+	frame := func(method string, params any) error {
+		raw, err := json.Marshal(map[string]any{"jsonrpc": jsonrpc2.Version, "method": method, "params": params})
+		if err != nil {
+			return fmt.Errorf("lsp: resuming: %w", err)
+		}
+		fmt.Fprintf(&replay, "Content-Length: %d\r\n\r\n%s", len(raw), raw)
+		return nil
+	}
+	if err := frame("initialized", struct{}{}); err != nil {
+		return err
+	}
+	for _, doc := range state.Documents {
+		err := frame("textDocument/didOpen", &protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{
+			URI: doc.URI, LanguageID: doc.LanguageID, Version: doc.Version, Text: doc.Text,
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	if state.Settings != nil {
+		if err := frame("workspace/didChangeConfiguration", &protocol.DidChangeConfigurationParams{Settings: state.Settings}); err != nil {
+			return err
+		}
+	}
+	replay.Write(state.Pending)
+	return s.Serve(ctx, &resumed{Reader: io.MultiReader(&replay, stream), stream: stream})
+}
+
+// resumed reads the replay before the stream.
+type resumed struct {
+	io.Reader
+	stream io.ReadWriter
+}
+
+func (r *resumed) Write(p []byte) (int, error) { return r.stream.Write(p) }
+
+func (r *resumed) Close() error {
+	if c, ok := r.stream.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package lsp
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// Keepalive configures dead-peer detection for network connections. Remote
+// editors, particularly through dev-container port forwards, often vanish
+// without closing the connection; without probes the session, and whatever
+// it holds, lives forever.
+type Keepalive struct {
+	// Idle, Interval and Count configure TCP keepalive probes: the first is
+	// sent after Idle without traffic, then every Interval, and the peer is
+	// dead after Count go unanswered. Zero values take the operating system
+	// defaults.
+	Idle     time.Duration
+	Interval time.Duration
+	Count    int
+
+	// WriteTimeout is how long a write may block on a peer which has
+	// stopped reading before the connection is torn down. Zero disables it.
+	WriteTimeout time.Duration
+
+	// ReadTimeout tears the connection down after this long without
+	// receiving anything. Editors can be idle for hours, so it is off when
+	// zero and only suits clients which send their own pings.
+	ReadTimeout time.Duration
+
+	// OnDead, when set, is told why a connection was declared dead.
+	OnDead func(conn net.Conn, err error)
+}
+
+// DefaultKeepalive notices a silent peer within about a minute.
+var DefaultKeepalive = Keepalive{
+	Idle:         30 * time.Second,
+	Interval:     10 * time.Second,
+	Count:        3,
+	WriteTimeout: time.Minute,
+}
+
+// ErrPeerDead is reported to OnDead when a deadline declares the peer gone.
+// It is a *jsonrpc2.TransportError.
+var ErrPeerDead error = &jsonrpc2.TransportError{Err: errors.New("lsp: peer stopped responding")}
+
+// Listener wraps ln so every accepted connection is configured by Conn.
+func (k Keepalive) Listener(ln net.Listener) net.Listener {
+	return &keepaliveListener{Listener: ln, k: k}
+}
+
+// Conn enables TCP keepalive on conn, when it is TCP, and applies the read
+// and write timeouts. A connection which times out is closed, so the
+// session serving it ends as though the peer had disconnected.
+func (k Keepalive) Conn(conn net.Conn) net.Conn {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetKeepAliveConfig(net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     k.Idle,
+			Interval: k.Interval,
+			Count:    k.Count,
+		})
+	}
+	if k.WriteTimeout <= 0 && k.ReadTimeout <= 0 && k.OnDead == nil {
+		return conn
+	}
+	return &keepaliveConn{Conn: conn, k: k}
+}
+
+type keepaliveListener struct {
+	net.Listener
+	k Keepalive
+}
+
+func (l *keepaliveListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.k.Conn(conn), nil
+}
+
+type keepaliveConn struct {
+	net.Conn
+	k        Keepalive
+	deadOnce sync.Once
+}
+
+func (c *keepaliveConn) Read(p []byte) (int, error) {
+	if c.k.ReadTimeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.k.ReadTimeout))
+	}
+	n, err := c.Conn.Read(p)
+	if err != nil {
+		c.check(err)
+	}
+	return n, err
+}
+
+func (c *keepaliveConn) Write(p []byte) (int, error) {
+	if c.k.WriteTimeout > 0 {
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(c.k.WriteTimeout))
+	}
+	n, err := c.Conn.Write(p)
+	if err != nil {
+		c.check(err)
+	}
+	return n, err
+}
+
+// check closes the connection when err shows the peer is gone: a deadline
+// expired, or TCP keepalive gave up on it.
+func (c *keepaliveConn) check(err error) {
+	var dead error
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		dead = ErrPeerDead
+	case isConnDead(err):
+		dead = err
+	default:
+		return
+	}
+	c.deadOnce.Do(func() {
+		if c.k.OnDead != nil {
+			c.k.OnDead(c.Conn, dead)
+		}
+		_ = c.Conn.Close()
+	})
+}
+
+func isConnDead(err error) bool {
+	return errors.Is(err, syscall.ETIMEDOUT) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
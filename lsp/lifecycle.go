@@ -0,0 +1,90 @@
+package lsp
+
+import (
+	"context"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsperror"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// CodeServerNotInitialized answers requests sent before initialize.
+const CodeServerNotInitialized = lsperror.CodeServerNotInitialized
+
+// state is the server's position in the LSP lifecycle.
+type state int
+
+const (
+	stateNew state = iota
+	stateInitializing
+	stateRunning
+	stateShutdown
+	stateExited
+)
+
+// lifecycle gates a message on the server's state, returning ok to let it
+// through or the response to refuse it with. Notifications which arrive
+// out of order are dropped, as the specification requires; exit is always
+// let through.
+func (s *Server) lifecycle(req *jsonrpc2.Request) (ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Method == "exit" {
+		return true, nil
+	}
+	switch s.state {
+	case stateNew:
+		if req.Method == "initialize" {
+			s.state = stateInitializing
+			return true, nil
+		}
+		if req.IsNotification() {
+			return false, nil
+		}
+		return false, lsperror.ServerNotInitialized(req.Method)
+	case stateInitializing, stateRunning:
+		if req.Method == "initialize" {
+			return false, jsonrpc2.NewError(jsonrpc2.CodeInvalidRequest, "initialize was already received")
+		}
+		if req.Method == "shutdown" {
+			s.state = stateShutdown
+		}
+		return true, nil
+	}
+	if req.IsNotification() {
+		return false, nil
+	}
+	return false, jsonrpc2.NewError(jsonrpc2.CodeInvalidRequest, "%s after shutdown", req.Method)
+}
+
+// ExitCode is the process exit code the specification asks for once Serve
+// returns: 0 when the client sent shutdown before exit, 1 otherwise,
+// including when the connection was lost without an exit.
+func (s *Server) ExitCode() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == stateExited && s.shutdownSeen {
+		return 0
+	}
+	return 1
+}
+
+// ClientCapabilities returns the capabilities from initialize, or nil
+// before it.
+func (s *Server) ClientCapabilities() *protocol.ClientCapabilities {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.params == nil {
+		return nil
+	}
+	return &s.params.Capabilities
+}
+
+func (s *Server) exit(context.Context) error {
+	s.mu.Lock()
+	s.shutdownSeen = s.state == stateShutdown
+	s.state = stateExited
+	conn := s.conn
+	s.mu.Unlock()
+	return conn.Close()
+}
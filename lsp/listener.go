@@ -0,0 +1,46 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// ServeListener accepts client connections until ctx is done, serving each
+// with the Server newServer returns for it. Servers built by newServer may
+// share state, such as a shared.Files and shared.Cache, with a per-connection
+// shared.Overlay for each client's open documents. Wrap ln with
+// Keepalive.Listener to detect clients which disappear without closing.
+//
+// On return the listener is closed and every connection has finished.
+func ServeListener(ctx context.Context, ln net.Listener, newServer func(conn net.Conn) *Server) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return ctx.Err()
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			_ = newServer(conn).Serve(ctx, conn)
+		}()
+	}
+}
@@ -0,0 +1,75 @@
+package lsp
+
+import "github.com/pentops/lsplib/protocol"
+
+// NewLocationLink builds a link to target. fullRange encloses the whole
+// target and selection its name; a zero selection selects the start of
+// fullRange, and fullRange is widened if it doesn't enclose selection, which
+// clients otherwise reject. origin is the range at the request position the
+// link is for, usually the identifier under the cursor, and may be nil.
+func NewLocationLink(origin *protocol.Range, target protocol.DocumentURI, fullRange, selection protocol.Range) protocol.LocationLink {
+	if selection == (protocol.Range{}) {
+		selection = protocol.Range{Start: fullRange.Start, End: fullRange.Start}
+	}
+	if before(selection.Start, fullRange.Start) {
+		fullRange.Start = selection.Start
+	}
+	if before(fullRange.End, selection.End) {
+		fullRange.End = selection.End
+	}
+	return protocol.LocationLink{
+		OriginSelectionRange: origin,
+		TargetURI:            target,
+		TargetRange:          fullRange,
+		TargetSelectionRange: selection,
+	}
+}
+
+// Locations returns links as a go-to result: []protocol.LocationLink when the
+// client supports links, otherwise []protocol.Location pointing at each
+// target's selection range, so the cursor still lands on the name.
+func Locations(linkSupport bool, links []protocol.LocationLink) any {
+	if linkSupport {
+		if links == nil {
+			return []protocol.LocationLink{}
+		}
+		return links
+	}
+	locations := make([]protocol.Location, 0, len(links))
+	for _, link := range links {
+		locations = append(locations, protocol.Location{URI: link.TargetURI, Range: link.TargetSelectionRange})
+	}
+	return locations
+}
+
+// Locations returns links in the form the client accepts for method, one of
+// textDocument/definition, declaration, typeDefinition or implementation.
+func (s *Server) Locations(method string, links []protocol.LocationLink) any {
+	return Locations(s.LinkSupport(method), links)
+}
+
+// LinkSupport reports whether the client accepts LocationLink results for a
+// go-to method.
+func (s *Server) LinkSupport(method string) bool {
+	params := s.InitializeParams()
+	if params == nil || params.Capabilities.TextDocument == nil {
+		return false
+	}
+	caps := params.Capabilities.TextDocument
+	var link *protocol.LinkClientCapabilities
+	switch method {
+	case "textDocument/definition":
+		link = caps.Definition
+	case "textDocument/declaration":
+		link = caps.Declaration
+	case "textDocument/typeDefinition":
+		link = caps.TypeDefinition
+	case "textDocument/implementation":
+		link = caps.Implementation
+	}
+	return link != nil && link.LinkSupport
+}
+
+func before(a, b protocol.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
@@ -0,0 +1,135 @@
+package lsp
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// LogHandler is a slog.Handler sending records to the client as
+// window/logMessage, so a server's logs show in the editor's output panel
+// next to its traffic. A record reads as its message followed by its
+// attributes as key=value pairs, group members qualified as group.key; the
+// client adds the time. Debug records are sent as MessageLog, which every
+// client shows. Records logged before initialize or after the connection
+// closes are dropped.
+type LogHandler struct {
+	s     *Server
+	level slog.Leveler
+
+	// attrs are the attributes of WithAttrs, formatted, and group the
+	// prefix of WithGroup.
+	attrs string
+	group string
+}
+
+// NewLogHandler returns a LogHandler for s logging records at level and
+// above, slog.LevelInfo when nil.
+func NewLogHandler(s *Server, level slog.Leveler) *LogHandler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &LogHandler{s: s, level: level}
+}
+
+// Enabled reports whether level is at or above the handler's.
+func (h *LogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle sends r to the client.
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+	return h.s.Notify(context.WithoutCancel(ctx), "window/logMessage", &protocol.LogMessageParams{
+		Type:    messageType(r.Level),
+		Message: b.String(),
+	})
+}
+
+// WithAttrs returns a handler adding attrs to every record.
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		appendAttr(&b, h.group, a)
+	}
+	out := *h
+	out.attrs += b.String()
+	return &out
+}
+
+// WithGroup returns a handler qualifying the keys of later attributes with
+// name.
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	out := *h
+	out.group += name + "."
+	return &out
+}
+
+func appendAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		// An inline group, with an empty key, adds its members unqualified.
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, member := range a.Value.Group() {
+			appendAttr(b, group, member)
+		}
+		return
+	}
+	b.WriteByte(' ')
+	b.WriteString(group)
+	b.WriteString(a.Key)
+	b.WriteByte('=')
+	var value string
+	switch a.Value.Kind() {
+	case slog.KindTime:
+		value = a.Value.Time().Format(time.RFC3339Nano)
+	default:
+		value = a.Value.String()
+	}
+	b.WriteString(quoteValue(value))
+}
+
+// quoteValue quotes values which wouldn't read as one, as
+// slog.TextHandler does.
+func quoteValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+func messageType(level slog.Level) protocol.MessageType {
+	switch {
+	case level >= slog.LevelError:
+		return protocol.MessageError
+	case level >= slog.LevelWarn:
+		return protocol.MessageWarning
+	case level >= slog.LevelInfo:
+		return protocol.MessageInfo
+	}
+	return protocol.MessageLog
+}
@@ -0,0 +1,45 @@
+package lsp
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// Supersede is a jsonrpc2.Overload key for the requests a newer one makes
+// stale: the textDocument/ queries, keyed by method and document, so of a
+// backlog of hovers over one document only the newest is served. Requests
+// whose answer the client waits on to proceed, such as willSaveWaitUntil,
+// are never superseded. Set it as Options.Conn.Overload.Supersede.
+func Supersede(req *jsonrpc2.Request) string {
+	if !strings.HasPrefix(req.Method, "textDocument/") || req.Method == "textDocument/willSaveWaitUntil" {
+		return ""
+	}
+	uri := Document(req)
+	if uri == "" {
+		return ""
+	}
+	return req.Method + " " + uri
+}
+
+// Document is the URI of the text or notebook document req is about, as
+// its params name it, or "" for none; it is the default
+// jsonrpc2.Options.Document of a Server's connection.
+func Document(req *jsonrpc2.Request) string {
+	var params struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		NotebookDocument struct {
+			URI string `json:"uri"`
+		} `json:"notebookDocument"`
+	}
+	if json.Unmarshal(req.Params, &params) != nil {
+		return ""
+	}
+	if params.TextDocument.URI != "" {
+		return params.TextDocument.URI
+	}
+	return params.NotebookDocument.URI
+}
@@ -0,0 +1,66 @@
+package lsp
+
+import (
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/pathmap"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// pathRewrite translates the session's messages through the map
+// Options.PathMap chose at initialize, inside outer.
+func (s *Server) pathRewrite(outer jsonrpc2.Rewrite) jsonrpc2.Rewrite {
+	return jsonrpc2.Rewrite{
+		In: func(msg []byte) ([]byte, error) {
+			msg, err := chain(outer.In, msg)
+			if err != nil {
+				return msg, err
+			}
+			if m := s.pathMap.Load(); m != nil {
+				return m.ToServerJSON(msg)
+			}
+			return msg, nil
+		},
+		Out: func(msg []byte) ([]byte, error) {
+			if m := s.pathMap.Load(); m != nil {
+				if out, err := m.ToClientJSON(msg); err == nil {
+					msg = out
+				}
+			}
+			return chain(outer.Out, msg)
+		},
+	}
+}
+
+func chain(fn func([]byte) ([]byte, error), msg []byte) ([]byte, error) {
+	if fn == nil {
+		return msg, nil
+	}
+	return fn(msg)
+}
+
+// choosePathMap asks Options.PathMap for the session's map given the
+// client's params, and returns the params as the server sees them. The
+// initialize request itself was read before there was a map, so its raw
+// params are translated here.
+func (s *Server) choosePathMap(req *jsonrpc2.Request, params *protocol.InitializeParams) (*protocol.InitializeParams, error) {
+	m := s.opts.PathMap(params)
+	if m == nil {
+		return params, nil
+	}
+	s.pathMap.Store(m)
+	raw, err := m.ToServerJSON(req.Params)
+	if err != nil {
+		return params, nil
+	}
+	mapped := &protocol.InitializeParams{}
+	if err := req.WithParams(raw).UnmarshalParams(mapped); err != nil {
+		return nil, err
+	}
+	return mapped, nil
+}
+
+// PathMap returns the session's path map, or nil when paths are not being
+// translated.
+func (s *Server) PathMap() *pathmap.Map {
+	return s.pathMap.Load()
+}
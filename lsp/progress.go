@@ -0,0 +1,176 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// ErrProgressCancelled is the cause of a progress context the user
+// cancelled from the editor.
+var ErrProgressCancelled = errors.New("lsp: cancelled by the user")
+
+// Progress reports work-done progress: Begin once, Report any number of
+// times, then End. It implements walk.Reporter. When the client can't show
+// the progress every method is a no-op, so callers never need to check.
+type Progress struct {
+	server *Server
+	token  protocol.ProgressToken
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	mu          sync.Mutex
+	enabled     bool
+	begun       bool
+	ended       bool
+	lastMessage string
+	lastPercent uint32
+}
+
+// NewProgress returns a handle for reporting the progress of a request or
+// of background work. A request's handler passes the workDoneToken from
+// its params, which the client already listens on; with a nil token a
+// server-initiated one is created through window/workDoneProgress/create,
+// provided the client advertised window.workDoneProgress. The handle's
+// Context derives from ctx.
+func (s *Server) NewProgress(ctx context.Context, token *protocol.ProgressToken) *Progress {
+	p := &Progress{server: s}
+	p.ctx, p.cancel = context.WithCancelCause(ctx)
+	if token != nil {
+		p.token, p.enabled = *token, true
+		return p
+	}
+	s.mu.Lock()
+	s.progressID++
+	created := *protocol.NewStringCode(fmt.Sprintf("lsplib/%d", s.progressID))
+	params := s.params
+	s.mu.Unlock()
+
+	p.token = created
+	if params == nil || params.Capabilities.Window == nil || !params.Capabilities.Window.WorkDoneProgress {
+		return p
+	}
+	if err := s.Call(ctx, "window/workDoneProgress/create", &protocol.WorkDoneProgressCreateParams{Token: created}, nil); err != nil {
+		return p
+	}
+	p.enabled = true
+	return p
+}
+
+// Token is the progress token, for clients of the handle which send their
+// own $/progress values.
+func (p *Progress) Token() protocol.ProgressToken {
+	return p.token
+}
+
+// Context is cancelled, with cause ErrProgressCancelled, when the user
+// cancels a progress begun with BeginCancellable, and otherwise when the
+// context given to NewProgress is. Do the work under it.
+func (p *Progress) Context() context.Context {
+	return p.ctx
+}
+
+// Begin starts showing the progress, at 0%. Later calls do nothing.
+func (p *Progress) Begin(title, message string) {
+	p.begin(title, message, false)
+}
+
+// BeginCancellable is Begin with a cancel button: the client's
+// window/workDoneProgress/cancel cancels Context until End.
+func (p *Progress) BeginCancellable(title, message string) {
+	p.begin(title, message, true)
+}
+
+func (p *Progress) begin(title, message string, cancellable bool) {
+	p.mu.Lock()
+	if !p.enabled || p.begun {
+		p.mu.Unlock()
+		return
+	}
+	p.begun = true
+	p.mu.Unlock()
+	if cancellable {
+		p.server.trackProgress(p)
+	}
+	zero := uint32(0)
+	p.send(&protocol.WorkDoneProgressBegin{Kind: "begin", Title: title, Cancellable: cancellable, Message: message, Percentage: &zero})
+}
+
+// Report updates the progress. Percentage is 0 to 100; repeated identical
+// reports are not sent, nor reports before Begin or after End.
+func (p *Progress) Report(message string, percentage uint32) {
+	p.mu.Lock()
+	if !p.enabled || !p.begun || p.ended || (message == p.lastMessage && percentage == p.lastPercent) {
+		p.mu.Unlock()
+		return
+	}
+	percentage = min(percentage, 100)
+	p.lastMessage, p.lastPercent = message, percentage
+	p.mu.Unlock()
+	p.send(&protocol.WorkDoneProgressReport{Kind: "report", Message: message, Percentage: &percentage})
+}
+
+// End finishes the progress. Later calls do nothing, as does End without
+// Begin.
+func (p *Progress) End(message string) {
+	p.mu.Lock()
+	if !p.enabled || !p.begun || p.ended {
+		p.mu.Unlock()
+		return
+	}
+	p.ended = true
+	p.mu.Unlock()
+	p.server.untrackProgress(p)
+	p.send(&protocol.WorkDoneProgressEnd{Kind: "end", Message: message})
+}
+
+func (p *Progress) send(value any) {
+	_ = p.server.Notify(context.Background(), "$/progress", &protocol.ProgressParams{Token: p.token, Value: value})
+}
+
+// progressKey tells integer and string tokens apart, which Value doesn't.
+func progressKey(token protocol.ProgressToken) string {
+	if token.Integer != nil {
+		return "i" + token.Value()
+	}
+	return "s" + token.Value()
+}
+
+func (s *Server) trackProgress(p *Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancellable == nil {
+		s.cancellable = map[string]*Progress{}
+	}
+	s.cancellable[progressKey(p.token)] = p
+}
+
+func (s *Server) untrackProgress(p *Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key := progressKey(p.token); s.cancellable[key] == p {
+		delete(s.cancellable, key)
+	}
+}
+
+// cancelProgress handles window/workDoneProgress/cancel, cancelling the
+// context of the progress with the token. Tokens of progress which has
+// ended, or was never cancellable, are ignored.
+func (s *Server) cancelProgress(req *jsonrpc2.Request) {
+	var params protocol.WorkDoneProgressCancelParams
+	if json.Unmarshal(req.Params, &params) != nil {
+		return
+	}
+	s.mu.Lock()
+	p := s.cancellable[progressKey(params.Token)]
+	s.mu.Unlock()
+	if p != nil {
+		p.cancel(ErrProgressCancelled)
+	}
+}
@@ -0,0 +1,110 @@
+package lsp
+
+import (
+	"sync"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// Scheduler is a jsonrpc2.Scheduler which runs requests concurrently while
+// keeping each document's changes in order and out of the way of requests
+// reading it. Set it as Options.Conn.Scheduler, a new one per connection:
+//
+//   - A notification about a document, such as textDocument/didChange,
+//     waits for the messages about that document before it, and for the
+//     notifications about no document before it.
+//   - A request about a document waits for the notifications about it
+//     before it, and holds back those after it until it returns, so it
+//     works on the document as it was when the request arrived.
+//   - A request about no document in particular, such as workspace/symbol,
+//     does the same for every document.
+//   - Any other notification, such as workspace/didChangeConfiguration,
+//     waits for the notifications before it, and everything after it waits
+//     for it, but it doesn't wait for requests.
+//
+// Handlers may then find a document's state without locking against its
+// notifications, though requests still run concurrently with each other.
+type Scheduler struct {
+	// Case controls document URI comparison.
+	Case fscase.Sensitivity
+
+	mu sync.Mutex
+	// pending are the messages admitted and not yet done, in arrival order.
+	pending []*scheduled
+}
+
+type scheduled struct {
+	// doc is the document's URI key, "" for none in particular.
+	doc          string
+	notification bool
+	ready        chan struct{}
+	started      bool
+}
+
+// waitsFor reports whether later, admitted after earlier, must wait for it.
+func (later *scheduled) waitsFor(earlier *scheduled) bool {
+	switch {
+	case earlier.notification && earlier.doc == "":
+		return true
+	case earlier.notification:
+		return later.doc == "" || later.doc == earlier.doc
+	case later.notification && later.doc != "":
+		return earlier.doc == "" || earlier.doc == later.doc
+	}
+	return false
+}
+
+// Admit implements jsonrpc2.Scheduler.
+func (s *Scheduler) Admit(req *jsonrpc2.Request) (ready <-chan struct{}, done func()) {
+	op := &scheduled{doc: s.document(req), notification: req.IsNotification(), ready: make(chan struct{})}
+	s.mu.Lock()
+	s.pending = append(s.pending, op)
+	s.advance()
+	s.mu.Unlock()
+
+	var once sync.Once
+	return op.ready, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for idx, p := range s.pending {
+				if p == op {
+					s.pending = append(s.pending[:idx], s.pending[idx+1:]...)
+					break
+				}
+			}
+			s.advance()
+		})
+	}
+}
+
+// advance starts each pending message which no earlier one holds back,
+// with mu held.
+func (s *Scheduler) advance() {
+	for idx, op := range s.pending {
+		if op.started {
+			continue
+		}
+		blocked := false
+		for _, earlier := range s.pending[:idx] {
+			if op.waitsFor(earlier) {
+				blocked = true
+				break
+			}
+		}
+		if !blocked {
+			op.started = true
+			close(op.ready)
+		}
+	}
+}
+
+// document is the key of the document req is about, "" if none.
+func (s *Scheduler) document(req *jsonrpc2.Request) string {
+	uri := Document(req)
+	if uri == "" {
+		return ""
+	}
+	return s.Case.URIKey(uri)
+}
@@ -0,0 +1,178 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// message is a request, or a notification when id is empty, about the
+// document uri, or no document when it is empty.
+func message(id, method, uri string) *jsonrpc2.Request {
+	req := &jsonrpc2.Request{Method: method, Params: json.RawMessage(`{}`)}
+	if id != "" {
+		req.ID = json.RawMessage(id)
+	}
+	if uri != "" {
+		req.Params = json.RawMessage(`{"textDocument":{"uri":"` + uri + `"}}`)
+	}
+	return req
+}
+
+// admitted is a message admitted to a Scheduler.
+type admitted struct {
+	name  string
+	ready <-chan struct{}
+	done  func()
+}
+
+func admit(s *Scheduler, name string, req *jsonrpc2.Request) *admitted {
+	ready, done := s.Admit(req)
+	return &admitted{name: name, ready: ready, done: done}
+}
+
+func (a *admitted) started() bool {
+	select {
+	case <-a.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// expect fails unless exactly the messages in want have started.
+func expect(t *testing.T, step string, all []*admitted, want ...*admitted) {
+	t.Helper()
+	for _, a := range all {
+		wanted := false
+		for _, w := range want {
+			wanted = wanted || w == a
+		}
+		if a.started() != wanted {
+			t.Fatalf("%s: %s started = %v, want %v", step, a.name, a.started(), wanted)
+		}
+	}
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	const a, b = "file:///a.go", "file:///b.go"
+	s := &Scheduler{}
+	hoverA := admit(s, "hover a", message("1", "textDocument/hover", a))
+	changeA := admit(s, "change a", message("", "textDocument/didChange", a))
+	hoverB := admit(s, "hover b", message("2", "textDocument/hover", b))
+	changeB := admit(s, "change b", message("", "textDocument/didChange", b))
+	hoverA2 := admit(s, "second hover a", message("3", "textDocument/hover", a))
+	hoverB2 := admit(s, "second hover b", message("4", "textDocument/hover", b))
+	symbols := admit(s, "symbols", message("5", "workspace/symbol", ""))
+	all := []*admitted{hoverA, changeA, hoverB, changeB, hoverA2, hoverB2, symbols}
+
+	// Each change waits for the request reading its document before it,
+	// and the requests after it wait for it; the documents are independent.
+	expect(t, "admitted", all, hoverA, hoverB)
+	hoverB.done()
+	expect(t, "hover b done", all, hoverA, hoverB, changeB)
+	changeB.done()
+	expect(t, "change b done", all, hoverA, hoverB, changeB, hoverB2)
+	// A request about no document waits for every change before it.
+	hoverA.done()
+	expect(t, "hover a done", all, hoverA, changeA, hoverB, changeB, hoverB2)
+	changeA.done()
+	expect(t, "change a done", all, all...)
+
+	// A notification about no document doesn't wait for requests, but the
+	// change after it waits for it, and for the requests before it about
+	// its document or none.
+	config := admit(s, "configuration", message("", "workspace/didChangeConfiguration", ""))
+	changeA2 := admit(s, "second change a", message("", "textDocument/didChange", a))
+	all = append(all, config, changeA2)
+	expect(t, "configuration admitted", all, hoverA, changeA, hoverB, changeB, hoverA2, hoverB2, symbols, config)
+	config.done()
+	hoverB2.done()
+	expect(t, "configuration done", all, hoverA, changeA, hoverB, changeB, hoverA2, hoverB2, symbols, config)
+	hoverA2.done()
+	expect(t, "second hover a done", all, hoverA, changeA, hoverB, changeB, hoverA2, hoverB2, symbols, config)
+	symbols.done()
+	expect(t, "symbols done", all, all...)
+}
+
+func TestSchedulerCase(t *testing.T) {
+	for _, tc := range []struct {
+		sensitivity fscase.Sensitivity
+		waits       bool
+	}{
+		{fscase.Sensitive, false},
+		{fscase.Insensitive, true},
+	} {
+		s := &Scheduler{Case: tc.sensitivity}
+		hover := admit(s, "hover", message("1", "textDocument/hover", "file:///A.go"))
+		change := admit(s, "change", message("", "textDocument/didChange", "file:///a.go"))
+		if change.started() == tc.waits {
+			t.Errorf("%v: a change to a.go held back by a hover of A.go = %v, want %v", tc.sensitivity, !change.started(), tc.waits)
+		}
+		hover.done()
+		change.done()
+	}
+}
+
+// TestSchedulerSnapshots runs interleaved changes and reads of two
+// documents as dispatch does, each read checking it sees the changes sent
+// before it and no others. The documents are unlocked, for the race
+// detector to check too.
+func TestSchedulerSnapshots(t *testing.T) {
+	uris := []string{"file:///a.go", "file:///b.go"}
+	versions := make([]int, len(uris))
+	sent := make([]int, len(uris))
+	rnd := rand.New(rand.NewSource(1))
+	s := &Scheduler{}
+	var wg sync.WaitGroup
+	errs := make(chan string, 1000)
+	for i := 0; i < 500; i++ {
+		doc := rnd.Intn(len(uris))
+		var run func()
+		var req *jsonrpc2.Request
+		switch rnd.Intn(5) {
+		case 0, 1:
+			sent[doc]++
+			req = message("", "textDocument/didChange", uris[doc])
+			run = func() { versions[doc]++ }
+		case 2, 3:
+			want := sent[doc]
+			req = message("1", "textDocument/hover", uris[doc])
+			run = func() {
+				if versions[doc] != want {
+					errs <- fmt.Sprintf("a hover read version %d of %s, want %d", versions[doc], uris[doc], want)
+				}
+			}
+		case 4:
+			want := append([]int(nil), sent...)
+			req = message("1", "workspace/symbol", "")
+			run = func() {
+				for doc := range uris {
+					if versions[doc] != want[doc] {
+						errs <- fmt.Sprintf("a workspace request read version %d of %s, want %d", versions[doc], uris[doc], want[doc])
+					}
+				}
+			}
+		}
+		ready, done := s.Admit(req)
+		wg.Add(1)
+		go func(delay time.Duration) {
+			defer wg.Done()
+			defer done()
+			<-ready
+			time.Sleep(delay)
+			run()
+		}(time.Duration(rnd.Intn(100)) * time.Microsecond)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
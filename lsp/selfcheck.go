@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// SelfCheck is a check of what the server needs from its environment, run
+// once the client is initialized, so a missing tool or a misconfigured
+// workspace is shown to the user rather than leaving features silently
+// not working.
+type SelfCheck struct {
+	// Name says what is checked, such as "workspace layout", and prefixes
+	// the message of a failure. Name and Fix are translated with
+	// Options.Catalog, as keys of their own.
+	Name string
+
+	// Check fails with what is wrong. It gets the client's initialize
+	// params, for the workspace folders and initializationOptions.
+	Check func(ctx context.Context, params *protocol.InitializeParams) error
+
+	// Fix tells the user how to fix a failure, such as the command
+	// installing a tool; empty offers none.
+	Fix string
+
+	// FixURI documents the fix. A failure offers to open it, by
+	// window/showMessageRequest, when set.
+	FixURI protocol.URI
+}
+
+// ToolCheck checks that the program tool is on the PATH, as the server
+// runs it.
+func ToolCheck(tool, fix string) SelfCheck {
+	return SelfCheck{
+		Name: tool,
+		Check: func(context.Context, *protocol.InitializeParams) error {
+			if _, err := exec.LookPath(tool); err != nil {
+				return errors.New("not found on the PATH")
+			}
+			return nil
+		},
+		Fix: fix,
+	}
+}
+
+// runSelfChecks runs Options.SelfChecks off the request path, showing each
+// failure as a warning of its own. Checks run one at a time, in order, and
+// a failure offering FixURI waits for the user's answer without holding up
+// the rest.
+func (s *Server) runSelfChecks(ctx context.Context) {
+	if len(s.opts.SelfChecks) == 0 {
+		return
+	}
+	s.mu.Lock()
+	params := s.params
+	s.mu.Unlock()
+	s.Go(ctx, func(ctx context.Context) error {
+		for _, check := range s.opts.SelfChecks {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := check.Check(ctx, params); err != nil {
+				s.reportSelfCheck(ctx, check, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Server) reportSelfCheck(ctx context.Context, check SelfCheck, err error) {
+	p := s.Printer()
+	message := p.Sprintf("%s: %v", p.Text(check.Name), err)
+	if check.Fix != "" {
+		message = p.Sprintf("%s. %s", message, p.Text(check.Fix))
+	}
+	if check.FixURI == "" {
+		_ = s.ShowMessage(ctx, protocol.MessageWarning, "%s", message)
+		return
+	}
+	s.Go(ctx, func(ctx context.Context) error {
+		_, open, err := ShowMessageRequest(ctx, s, protocol.MessageWarning, message, Action[struct{}]{Title: p.Text("Learn more")})
+		if err != nil || !open {
+			return nil
+		}
+		_, _ = s.ShowDocument(ctx, check.FixURI, ShowDocumentOptions{External: true, FallbackToMessage: true})
+		return nil
+	})
+}
@@ -0,0 +1,451 @@
+// Package lsp is the language server framework: it runs the LSP lifecycle
+// over a jsonrpc2.Conn and routes everything else to handlers registered on
+// the server's Mux.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/locale"
+	"github.com/pentops/lsplib/pathmap"
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Options configures a Server.
+type Options struct {
+	// Name and Version are reported as serverInfo.
+	Name    string
+	Version string
+
+	// Capabilities is the initialize result's capabilities.
+	Capabilities protocol.ServerCapabilities
+
+	// Conn configures the underlying connection.
+	Conn jsonrpc2.Options
+
+	// Middleware wraps every inbound message, requests and notifications
+	// and lifecycle methods included, the first listed being outermost.
+	// Package middleware has panic recovery, logging and timing.
+	Middleware []jsonrpc2.Middleware
+
+	// OnInitialize is called with the client's initialize params and may
+	// adjust the result, e.g. to drop capabilities the client can't use.
+	// An error fails the initialize request.
+	OnInitialize func(ctx context.Context, params *protocol.InitializeParams, result *protocol.InitializeResult) error
+
+	// OnDisconnect is called when Serve returns, however the session
+	// ended, to release what the session held, after the hooks registered
+	// with Cleanup.
+	OnDisconnect func()
+
+	// OnWarmup runs once after initialized, off the request path, for
+	// indexing and other cold-start work. Its context is cancelled on
+	// shutdown. Progress is shown to the user titled WarmupTitle.
+	OnWarmup func(ctx context.Context, progress *Progress) error
+
+	// WarmupTitle titles the warmup progress, "Indexing" when empty.
+	WarmupTitle string
+
+	// WarmupCancellable lets the user cancel the warmup from the progress
+	// shown, which cancels its context with cause ErrProgressCancelled.
+	WarmupCancellable bool
+
+	// SelfChecks run once after initialized, off the request path, each
+	// failure shown to the user with its fix. ToolCheck checks for a tool.
+	SelfChecks []SelfCheck
+
+	// PositionEncodings are the encodings the server can work in, most
+	// preferred first. The first the client supports is negotiated, through
+	// either general.positionEncodings or clangd's offsetEncoding; UTF-16
+	// when none is.
+	PositionEncodings []position.Encoding
+
+	// Features are the parts of the server users may disable through
+	// initializationOptions, DefaultFeatures when nil.
+	Features []Feature
+
+	// InitializationOptions declares the initializationOptions the server
+	// accepts, as a value of a struct type, or a pointer to one, whose json
+	// tags name them. At initialize, options the client sends which it
+	// doesn't declare, or of the wrong JSON type, are shown to the user
+	// with window/showMessage by their JSON path, rather than silently
+	// ignored. The features object needn't be declared. Nil checks nothing.
+	InitializationOptions any
+
+	// PathMap chooses, at initialize, how paths and URIs are translated
+	// between the client's file system and the server's for the session;
+	// nil, or a nil result, leaves them untouched. Every message in both
+	// directions is translated from then on, initialize included, so
+	// handlers only ever see the server's paths.
+	PathMap func(params *protocol.InitializeParams) *pathmap.Map
+
+	// Allow authorizes each inbound message before it is dispatched, with
+	// its raw params; returning an error refuses it. It sees every method
+	// but exit, lifecycle methods included, once the lifecycle permits the
+	// message, and runs inside Middleware, so ctx carries whatever they
+	// attach. Nil allows everything.
+	Allow func(ctx context.Context, method string, params json.RawMessage) error
+
+	// Verifier, when set, requires clients to authenticate with a bearer
+	// token before anything else: through Authenticate, from a transport
+	// header, or by sending AuthenticateMethod first. Until then requests
+	// fail with CodeUnauthenticated and notifications are dropped.
+	// Handlers find the subject with Subject.
+	Verifier Verifier
+
+	// Catalog translates the messages the server shows the user into the
+	// client's locale, lsplib's own and those handlers format with Printer;
+	// locale.Default when nil.
+	Catalog *locale.Catalog
+
+	// EarlyNotifications caps the notifications held back until the client
+	// sends initialized, such as logs and diagnostics of eager analysis,
+	// which are then sent in order; DefaultEarlyNotifications when zero, and
+	// none are held, but dropped, when negative. Past the cap the oldest are
+	// dropped, and the client told how many.
+	EarlyNotifications int
+
+	// Logger receives failures of the work handlers start with Go. Nil sends
+	// them to the client as window/logMessage.
+	Logger *slog.Logger
+}
+
+// Server is a language server. Register request and notification handlers
+// on Mux before calling Serve.
+type Server struct {
+	Mux *jsonrpc2.Mux
+
+	opts Options
+
+	mu         sync.Mutex
+	conn       *jsonrpc2.Conn
+	params     *protocol.InitializeParams
+	featureSet featureSet
+	encoding   position.Encoding
+	trace      protocol.TraceValue
+	state      state
+	// shutdownSeen records that exit followed shutdown, for ExitCode.
+	shutdownSeen bool
+	warmupStop   context.CancelFunc
+	warmupDone   chan struct{}
+	background   background
+	progressID   int64
+	// cancellable are the progresses the client may cancel, by token.
+	cancellable map[string]*Progress
+	pathMap     atomic.Pointer[pathmap.Map]
+	early       early
+
+	authenticated bool
+	subject       string
+
+	// rawCapabilities are the client's capabilities as sent, for
+	// ClientSupports, and advertised the server's as answered, for
+	// Registered; dynamicMu serialises SetDynamic.
+	rawCapabilities json.RawMessage
+	advertised      json.RawMessage
+	// initializeRaw is the client's initialize params as sent, for Handoff.
+	initializeRaw json.RawMessage
+	dynamicMu     sync.Mutex
+	dynamic       dynamicState
+
+	// cleanups are the hooks registered with Cleanup.
+	cleanups []func(err error)
+}
+
+func NewServer(opts Options) *Server {
+	if opts.WarmupTitle == "" {
+		opts.WarmupTitle = "Indexing"
+	}
+	return &Server{Mux: jsonrpc2.NewMux(), opts: opts}
+}
+
+// ServeStdio runs the server over standard input and output, as editors
+// expect of servers they launch. A main function typically ends
+//
+//	_ = s.ServeStdio(ctx)
+//	os.Exit(s.ExitCode())
+func (s *Server) ServeStdio(ctx context.Context) error {
+	return s.Serve(ctx, jsonrpc2.Stdio())
+}
+
+// ErrDisconnected is returned by Serve when the client closed the stream
+// without exit, as one killed mid-session does. It is a
+// *jsonrpc2.TransportError wrapping io.EOF, telling a caller deciding
+// whether to restart the server that the client went away, rather than a
+// protocol error such as jsonrpc2.ErrTooManyMalformed.
+var ErrDisconnected error = &jsonrpc2.TransportError{Err: fmt.Errorf("lsp: client disconnected without exit: %w", io.EOF)}
+
+// Serve runs the server over stream until the client exits, the stream ends
+// or ctx is done. It returns nil after exit; ExitCode then says whether the
+// exit was clean. Otherwise it returns ErrDisconnected if the client closed
+// the stream, and what ended the connection if anything else did. Handlers
+// still running have their contexts cancelled, with cause
+// jsonrpc2.ErrClosed when the stream ended, and the Cleanup hooks run
+// before Serve returns.
+//
+// Serve enforces the lifecycle: requests before initialize fail with
+// CodeServerNotInitialized, a second initialize and requests after shutdown
+// fail with CodeInvalidRequest, and notifications out of order are dropped.
+func (s *Server) Serve(ctx context.Context, stream io.ReadWriter) error {
+	conn := jsonrpc2.NewConn(stream, s.connOptions())
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+
+	h := jsonrpc2.Chain(jsonrpc2.HandlerFunc(s.handle), s.opts.Middleware...)
+	err := conn.Run(ctx, h)
+	s.stopWarmup()
+	s.stopBackground()
+
+	s.mu.Lock()
+	exited := s.state == stateExited
+	cleanups := s.cleanups
+	s.cleanups = nil
+	s.mu.Unlock()
+	switch {
+	case exited:
+		err = nil
+	case err == nil:
+		err = ErrDisconnected
+	}
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i](err)
+	}
+	if s.opts.OnDisconnect != nil {
+		s.opts.OnDisconnect()
+	}
+	return err
+}
+
+// Cleanup registers fn to be called when Serve returns, with the error it
+// returns, so that whatever a handler set up for the session, such as a
+// subprocess or a watch, is released however the session ends, the client
+// disconnecting mid-request included. Hooks run last registered first.
+func (s *Server) Cleanup(fn func(err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanups = append(s.cleanups, fn)
+}
+
+// connOptions are Options.Conn with LSP's cancellation method and
+// documents, unless others are set, and the session's path translation.
+func (s *Server) connOptions() jsonrpc2.Options {
+	opts := s.opts.Conn
+	if opts.CancelMethod == "" {
+		opts.CancelMethod = "$/cancelRequest"
+	}
+	if opts.Document == nil {
+		opts.Document = Document
+	}
+	if s.opts.PathMap != nil {
+		opts.Rewrite = s.pathRewrite(opts.Rewrite)
+	}
+	return opts
+}
+
+// InitializeParams returns the client's initialize params, or nil before
+// initialize.
+func (s *Server) InitializeParams() *protocol.InitializeParams {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.params
+}
+
+// PositionEncoding is the negotiated position encoding, position.Default
+// before initialize.
+func (s *Server) PositionEncoding() position.Encoding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.encoding == "" {
+		return position.Default
+	}
+	return s.encoding
+}
+
+// Conn returns the connection being served, or nil before Serve.
+func (s *Server) Conn() *jsonrpc2.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+// Notify sends a notification to the client. Until the client sends
+// initialized it is held back, up to Options.EarlyNotifications, and sent
+// then; $/progress is sent at once.
+func (s *Server) Notify(ctx context.Context, method string, params any) error {
+	conn := s.Conn()
+	if conn == nil {
+		return jsonrpc2.ErrClosed
+	}
+	if held, err := s.hold(ctx, method, params); held {
+		return err
+	}
+	return conn.Notify(ctx, method, params)
+}
+
+// Call sends a request to the client and waits for the result.
+func (s *Server) Call(ctx context.Context, method string, params, result any) error {
+	conn := s.Conn()
+	if conn == nil {
+		return jsonrpc2.ErrClosed
+	}
+	return conn.Call(ctx, method, params, result)
+}
+
+func (s *Server) handle(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	ctx, handled, err := s.authenticate(ctx, req)
+	if handled {
+		return nil, err
+	}
+	if ok, err := s.lifecycle(req); !ok {
+		return nil, err
+	}
+	if ok, err := s.allow(ctx, req); !ok {
+		if req.Method == "initialize" {
+			s.resetInitialize()
+		}
+		return nil, err
+	}
+	switch req.Method {
+	case "initialize":
+		result, err := s.initialize(ctx, req)
+		if err != nil {
+			s.resetInitialize()
+		}
+		return result, err
+	case "initialized":
+		s.flushEarly(ctx)
+		s.runSelfChecks(ctx)
+		s.startWarmup()
+	case "shutdown":
+		s.stopWarmup()
+		s.stopBackground()
+		return nil, nil
+	case "exit":
+		return nil, s.exit(ctx)
+	case "window/workDoneProgress/cancel":
+		s.cancelProgress(req)
+	case "$/setTrace":
+		s.setTrace(req)
+	}
+	if s.methodDisabled(req.Method) {
+		return s.refuseDisabled(req)
+	}
+	if s.dynamicWithdrawn(req.Method) {
+		return s.refuseWithdrawn(req)
+	}
+	return s.Mux.Handle(ctx, req)
+}
+
+// resetInitialize undoes a failed initialize, so the client may try again.
+func (s *Server) resetInitialize() {
+	s.mu.Lock()
+	s.state = stateNew
+	s.mu.Unlock()
+	s.pathMap.Store(nil)
+}
+
+func (s *Server) initialize(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	params := &protocol.InitializeParams{}
+	if err := req.UnmarshalParams(params); err != nil {
+		return nil, err
+	}
+	if s.opts.PathMap != nil {
+		var err error
+		if params, err = s.choosePathMap(req, params); err != nil {
+			return nil, err
+		}
+	}
+	result := &protocol.InitializeResult{Capabilities: s.opts.Capabilities}
+	if s.opts.Name != "" {
+		result.ServerInfo = &protocol.ServerInfo{Name: s.opts.Name, Version: s.opts.Version}
+	}
+	encoding := position.Negotiate(params.Capabilities, s.opts.PositionEncodings)
+	encoding.Apply(result)
+	if s.opts.OnInitialize != nil {
+		if err := s.opts.OnInitialize(ctx, params, result); err != nil {
+			return nil, err
+		}
+	}
+	features, unknown := s.applyFeatures(params, result)
+	var raw struct {
+		Capabilities json.RawMessage `json:"capabilities"`
+	}
+	_ = json.Unmarshal(req.Params, &raw)
+	advertised, _ := json.Marshal(result.Capabilities)
+	s.mu.Lock()
+	s.state = stateRunning
+	s.rawCapabilities = raw.Capabilities
+	s.initializeRaw = req.Params
+	s.advertised = advertised
+	s.params = params
+	s.featureSet = features
+	s.encoding = encoding.Encoding
+	s.trace = params.Trace
+	s.mu.Unlock()
+	s.reportUnknownFeatures(ctx, unknown)
+	s.reportInvalidOptions(ctx, params.InitializationOptions)
+	return result, nil
+}
+
+func (s *Server) startWarmup() {
+	if s.opts.OnWarmup == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.warmupDone != nil {
+		s.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.warmupStop = cancel
+	done := make(chan struct{})
+	s.warmupDone = done
+	s.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		defer cancel()
+		progress := s.NewProgress(ctx, nil)
+		p := s.Printer()
+		if s.opts.WarmupCancellable {
+			progress.BeginCancellable(p.Text(s.opts.WarmupTitle), "")
+		} else {
+			progress.Begin(p.Text(s.opts.WarmupTitle), "")
+		}
+		ctx := progress.Context()
+		err := s.opts.OnWarmup(ctx, progress)
+		switch {
+		case err == nil:
+			progress.End("")
+		case ctx.Err() != nil:
+			progress.End(p.Text("Cancelled"))
+		default:
+			progress.End(p.Text("Failed"))
+			_ = s.Notify(context.Background(), "window/logMessage", &protocol.LogMessageParams{
+				Type:    protocol.MessageError,
+				Message: p.Sprintf("%s failed: %v", p.Text(s.opts.WarmupTitle), err),
+			})
+		}
+	}()
+}
+
+// stopWarmup cancels a running warmup and waits for it to return.
+func (s *Server) stopWarmup() {
+	s.mu.Lock()
+	stop, done := s.warmupStop, s.warmupDone
+	s.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	stop()
+	<-done
+}
@@ -0,0 +1,103 @@
+package lsp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// TLS configures encryption, and optionally client certificate
+// authentication, for network listeners. Serving beyond localhost without
+// it exposes every document the editor opens.
+type TLS struct {
+	// CertFile and KeyFile are the server's PEM certificate chain and key.
+	// Certificates are used as well, or instead.
+	CertFile     string
+	KeyFile      string
+	Certificates []tls.Certificate
+
+	// ClientCAFile and ClientCAs are the authorities client certificates
+	// are verified against. With either set, clients presenting a
+	// certificate must present a valid one.
+	ClientCAFile string
+	ClientCAs    *x509.CertPool
+
+	// RequireClientCert refuses clients without a valid certificate. It
+	// needs ClientCAFile or ClientCAs.
+	RequireClientCert bool
+}
+
+// Config builds the tls.Config, loading the files. The minimum version is
+// TLS 1.2.
+func (t TLS) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: append([]tls.Certificate(nil), t.Certificates...),
+		ClientCAs:    t.ClientCAs,
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("lsp: loading TLS certificate: %w", err)
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+	if len(cfg.Certificates) == 0 {
+		return nil, errors.New("lsp: TLS needs a server certificate")
+	}
+	if t.ClientCAFile != "" {
+		pem, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("lsp: loading client CAs: %w", err)
+		}
+		if cfg.ClientCAs == nil {
+			cfg.ClientCAs = x509.NewCertPool()
+		}
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("lsp: no certificates in %s", t.ClientCAFile)
+		}
+	}
+	switch {
+	case t.RequireClientCert && cfg.ClientCAs == nil:
+		return nil, errors.New("lsp: RequireClientCert needs client CAs")
+	case t.RequireClientCert:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	case cfg.ClientCAs != nil:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// Listener wraps ln so every accepted connection speaks TLS. Apply
+// Keepalive.Listener to ln first, so its probes and timeouts act on the
+// underlying connection.
+func (t TLS) Listener(ln net.Listener) (net.Listener, error) {
+	cfg, err := t.Config()
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(ln, cfg), nil
+}
+
+// PeerCertificate completes the TLS handshake on conn, if it is a TLS
+// connection, and returns the client's verified certificate: nil when the
+// connection isn't TLS or the client presented none. A ServeListener
+// newServer can use it to tie the session to an identity, for Options.Allow
+// to consult.
+func PeerCertificate(conn net.Conn) (*x509.Certificate, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil, nil
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, nil
+	}
+	return certs[0], nil
+}
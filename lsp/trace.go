@@ -0,0 +1,48 @@
+package lsp
+
+import (
+	"context"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Trace is the level the client asked the server to trace at, through
+// initialize or $/setTrace; TraceOff until it does.
+func (s *Server) Trace() protocol.TraceValue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.trace == "" {
+		return protocol.TraceOff
+	}
+	return s.trace
+}
+
+// LogTrace sends the client a $/logTrace message, as traces of the server's
+// own work such as a build it ran, if the client asked for traces; verbose
+// is sent along only at TraceVerbose. Those of the messages themselves are
+// jsonrpc2.Tracer's.
+func (s *Server) LogTrace(ctx context.Context, message, verbose string) error {
+	params := &protocol.LogTraceParams{Message: message}
+	switch s.Trace() {
+	case protocol.TraceOff:
+		return nil
+	case protocol.TraceVerbose:
+		params.Verbose = verbose
+	}
+	return s.Notify(ctx, "$/logTrace", params)
+}
+
+// setTrace handles $/setTrace, ignoring values it doesn't know.
+func (s *Server) setTrace(req *jsonrpc2.Request) {
+	var params protocol.SetTraceParams
+	if req.UnmarshalParams(&params) != nil {
+		return
+	}
+	switch params.Value {
+	case protocol.TraceOff, protocol.TraceMessages, protocol.TraceVerbose:
+		s.mu.Lock()
+		s.trace = params.Value
+		s.mu.Unlock()
+	}
+}
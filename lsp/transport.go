@@ -0,0 +1,161 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListenTCP listens on a TCP address such as "127.0.0.1:2087", for
+// ServeListener. Bind to loopback unless clients authenticate: anyone who
+// can connect can drive the server.
+func ListenTCP(ctx context.Context, addr string) (net.Listener, error) {
+	var lc net.ListenConfig
+	return lc.Listen(ctx, "tcp", addr)
+}
+
+// ListenUnix listens on a Unix domain socket at path, for ServeListener. A
+// socket left behind by a previous run which nothing answers on any more is
+// removed first; one still in use is an error.
+func ListenUnix(ctx context.Context, path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		d := net.Dialer{Timeout: time.Second}
+		if conn, err := d.DialContext(ctx, "unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("lsp: %s is in use by another server", path)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("lsp: removing stale socket: %w", err)
+		}
+	}
+	var lc net.ListenConfig
+	return lc.Listen(ctx, "unix", path)
+}
+
+// Transport is the connection a server's command line asks for, in the
+// conventions editors launch language servers with.
+type Transport struct {
+	// Socket is the localhost port of --socket=PORT, which the editor
+	// listens on and the server connects to, as VS Code's socket transport
+	// has it.
+	Socket int
+
+	// Pipe is the path of --pipe=PATH, a Unix socket the editor listens on.
+	Pipe string
+
+	// Port is the port of --port=PORT, which the server listens on for
+	// editors to connect to, one session per connection.
+	Port int
+
+	// ClientProcessID is the editor's process id from --clientProcessId,
+	// zero when not given.
+	ClientProcessID int
+}
+
+// Stdio reports whether the transport is standard input and output: given
+// as --stdio, or no transport given at all.
+func (t Transport) Stdio() bool {
+	return t.Socket == 0 && t.Pipe == "" && t.Port == 0
+}
+
+// ParseTransport reads the transport flags --stdio, --socket=PORT,
+// --pipe=PATH, --port=PORT and --clientProcessId=PID from args, as either
+// --flag=value or --flag value, and returns the rest for the server's own
+// flags. At most one transport may be given.
+func ParseTransport(args []string) (Transport, []string, error) {
+	var t Transport
+	var rest []string
+	transports := 0
+	for idx := 0; idx < len(args); idx++ {
+		if args[idx] == "--" {
+			rest = append(rest, args[idx:]...)
+			break
+		}
+		if !strings.HasPrefix(args[idx], "-") {
+			rest = append(rest, args[idx])
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[idx], "-"), "=")
+		switch name {
+		case "stdio":
+			transports++
+			continue
+		case "socket", "pipe", "port", "clientProcessId":
+		default:
+			rest = append(rest, args[idx])
+			continue
+		}
+		if !hasValue {
+			if idx+1 == len(args) {
+				return t, nil, fmt.Errorf("lsp: --%s needs a value", name)
+			}
+			idx++
+			value = args[idx]
+		}
+		if name == "pipe" {
+			transports++
+			t.Pipe = value
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return t, nil, fmt.Errorf("lsp: --%s=%s: not a port or process id", name, value)
+		}
+		switch name {
+		case "socket":
+			transports++
+			t.Socket = n
+		case "port":
+			transports++
+			t.Port = n
+		case "clientProcessId":
+			t.ClientProcessID = n
+		}
+	}
+	if transports > 1 {
+		return t, nil, errors.New("lsp: more than one transport given")
+	}
+	return t, rest, nil
+}
+
+// Serve runs servers from newServer over the transport until the session
+// ends, or for Port until ctx is done. With the other transports a main
+// function typically ends
+//
+//	t, _, err := lsp.ParseTransport(os.Args[1:])
+//	...
+//	var s *lsp.Server
+//	err = t.Serve(ctx, func() *lsp.Server { s = newServer(); return s })
+//	os.Exit(s.ExitCode())
+func (t Transport) Serve(ctx context.Context, newServer func() *Server) error {
+	var d net.Dialer
+	switch {
+	case t.Socket != 0:
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(t.Socket)))
+		if err != nil {
+			return fmt.Errorf("lsp: connecting to the editor: %w", err)
+		}
+		defer conn.Close()
+		return newServer().Serve(ctx, conn)
+	case t.Pipe != "":
+		conn, err := d.DialContext(ctx, "unix", t.Pipe)
+		if err != nil {
+			return fmt.Errorf("lsp: connecting to the editor: %w", err)
+		}
+		defer conn.Close()
+		return newServer().Serve(ctx, conn)
+	case t.Port != 0:
+		ln, err := ListenTCP(ctx, net.JoinHostPort("127.0.0.1", strconv.Itoa(t.Port)))
+		if err != nil {
+			return err
+		}
+		return ServeListener(ctx, ln, func(net.Conn) *Server { return newServer() })
+	}
+	return newServer().ServeStdio(ctx)
+}
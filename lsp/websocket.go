@@ -0,0 +1,94 @@
+package lsp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pentops/lsplib/internal/websocket"
+)
+
+// WebSocketOptions configures ServeWebSocket.
+type WebSocketOptions struct {
+	// Origins are the origins browsers may connect from, such as
+	// "https://ide.example.com", or "*" for any. Requests without an Origin
+	// header, from clients which aren't browsers, and those whose origin is
+	// the request's own host are always accepted.
+	Origins []string
+
+	// Keepalive applies to each connection as to a listener's. Its
+	// Interval also paces WebSocket pings, which keep proxies from dropping
+	// idle sessions, and a connection which answers none of Count of them
+	// in a row, 3 when zero, is dead.
+	Keepalive Keepalive
+}
+
+// ServeWebSocket serves a session over each WebSocket connection to the
+// handler, with the Server newServer returns for its request, for browser
+// editors and remote setups which only pass HTTP. Each WebSocket message
+// carries one JSON-RPC message, without Content-Length headers, as
+// vscode-ws-jsonrpc frames them.
+//
+// A bearer token in the Authorization header authenticates the session
+// when the server has a Verifier, and a bad one is refused with 401
+// before the upgrade; without the header the client authenticates with
+// AuthenticateMethod. Sessions end with the request's context; give the
+// http.Server a BaseContext to end them on shutdown.
+func ServeWebSocket(newServer func(r *http.Request) *Server, opts WebSocketOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !opts.allowOrigin(r) {
+			http.Error(w, "lsp: origin not allowed", http.StatusForbidden)
+			return
+		}
+		s := newServer(r)
+		if token, ok := BearerToken(r.Header.Get("Authorization")); ok {
+			if err := s.Authenticate(r.Context(), token); err != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "lsp: authentication failed", http.StatusUnauthorized)
+				return
+			}
+		}
+		ws, err := websocket.Upgrade(w, r, opts.Keepalive.Conn)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		if interval := opts.Keepalive.Interval; interval > 0 {
+			count := opts.Keepalive.Count
+			if count <= 0 {
+				count = 3
+			}
+			ws.SetReadTimeout(time.Duration(count)*interval + interval/2)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			go func() {
+				for {
+					select {
+					case <-ticker.C:
+						if ws.Ping() != nil {
+							return
+						}
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		_ = s.Serve(ctx, ws)
+	})
+}
+
+func (o WebSocketOptions) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(o.Origins, "*") || slices.Contains(o.Origins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
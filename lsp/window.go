@@ -0,0 +1,135 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/locale"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Printer formats messages in the client's locale, as initialize gave it,
+// with the translations of Options.Catalog. Before initialize it formats
+// them in English.
+func (s *Server) Printer() locale.Printer {
+	s.mu.Lock()
+	params := s.params
+	s.mu.Unlock()
+	var tag string
+	if params != nil {
+		tag = params.Locale
+	}
+	return s.opts.Catalog.Printer(tag)
+}
+
+// ShowMessage shows the user a message, formatted as fmt.Sprintf does.
+func (s *Server) ShowMessage(ctx context.Context, typ protocol.MessageType, format string, args ...any) error {
+	return s.Notify(ctx, "window/showMessage", &protocol.ShowMessageParams{Type: typ, Message: fmt.Sprintf(format, args...)})
+}
+
+// LogMessage writes a message to the client's log of the server, formatted
+// as fmt.Sprintf does. NewLogHandler does the same for a slog.Logger.
+func (s *Server) LogMessage(ctx context.Context, typ protocol.MessageType, format string, args ...any) error {
+	return s.Notify(ctx, "window/logMessage", &protocol.LogMessageParams{Type: typ, Message: fmt.Sprintf(format, args...)})
+}
+
+// Action is a choice ShowMessageRequest offers: the button's title, and
+// the value it stands for.
+type Action[T any] struct {
+	Title string
+	Value T
+}
+
+// ShowMessageRequest shows the user a message with a button per action and
+// returns the value of the one they chose, false when they dismissed the
+// message. A client which doesn't handle window/showMessageRequest gets
+// the message by window/showMessage instead, as though it was dismissed,
+// so a server can offer a choice without requiring one.
+func ShowMessageRequest[T any](ctx context.Context, s *Server, typ protocol.MessageType, message string, actions ...Action[T]) (T, bool, error) {
+	var zero T
+	params := &protocol.ShowMessageRequestParams{Type: typ, Message: message}
+	for _, a := range actions {
+		params.Actions = append(params.Actions, protocol.MessageActionItem{Title: a.Title})
+	}
+	var chosen *protocol.MessageActionItem
+	err := s.Call(ctx, "window/showMessageRequest", params, &chosen)
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound {
+		return zero, false, s.Notify(ctx, "window/showMessage", &protocol.ShowMessageParams{Type: typ, Message: message})
+	}
+	if err != nil {
+		return zero, false, fmt.Errorf("lsp: asking %q: %w", message, err)
+	}
+	if chosen == nil {
+		return zero, false, nil
+	}
+	for _, a := range actions {
+		if a.Title == chosen.Title {
+			return a.Value, true, nil
+		}
+	}
+	return zero, false, fmt.Errorf("lsp: the client chose %q, which was not offered", chosen.Title)
+}
+
+// ErrNoShowDocument is returned by ShowDocument when the client can't show
+// documents; a server may fall back to showing the URI in a message.
+var ErrNoShowDocument = errors.New("lsp: the client does not support window/showDocument")
+
+// ShowDocumentOptions configures ShowDocument.
+type ShowDocumentOptions struct {
+	// Selection is the range to select once the document is open, such as
+	// the declaration a generated file was made for; nil selects nothing.
+	Selection *protocol.Range
+
+	// TakeFocus moves focus to the document's editor.
+	TakeFocus bool
+
+	// External opens the URI in the system's default application rather
+	// than an editor. URIs whose scheme editors don't open, such as https or
+	// mailto, are always opened externally.
+	External bool
+
+	// FallbackToMessage shows the URI in window/showMessage when the client
+	// can't show documents, so ShowDocument reports false rather than
+	// failing with ErrNoShowDocument.
+	FallbackToMessage bool
+}
+
+// ShowDocument asks the client to show a document, as "go to generated
+// file" commands do, reporting whether it did. Selection and TakeFocus are
+// left out for external URIs, which they don't apply to.
+func (s *Server) ShowDocument(ctx context.Context, uri protocol.URI, opts ShowDocumentOptions) (bool, error) {
+	if !s.ClientSupports("window.showDocument.support") {
+		if opts.FallbackToMessage {
+			return false, s.ShowMessage(ctx, protocol.MessageInfo, "%s", uri)
+		}
+		return false, ErrNoShowDocument
+	}
+	params := &protocol.ShowDocumentParams{URI: uri, External: opts.External || external(uri)}
+	if !params.External {
+		params.TakeFocus = opts.TakeFocus
+		params.Selection = opts.Selection
+	}
+	var result protocol.ShowDocumentResult
+	if err := s.Call(ctx, "window/showDocument", params, &result); err != nil {
+		return false, fmt.Errorf("lsp: showing %s: %w", uri, err)
+	}
+	return result.Success, nil
+}
+
+// external reports whether uri's scheme is one editors leave to other
+// applications.
+func external(uri protocol.URI) bool {
+	u, err := url.Parse(string(uri))
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package lspclient
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/pentops/lsplib/pathmap"
+)
+
+// Mount bind-mounts a host directory into a container.
+type Mount struct {
+	Host      string
+	Container string
+	ReadOnly  bool
+}
+
+// Container launches a server inside a Docker or Podman container. The
+// server sees the workspace through bind mounts at different paths from the
+// editor, so URIs and paths are translated in both directions by a
+// pathmap.Map built from the mounts.
+type Container struct {
+	// Runtime is the container CLI, "docker" when empty; "podman" takes the
+	// same arguments.
+	Runtime string
+
+	Image  string
+	Server string
+	Args   []string
+
+	Mounts []Mount
+
+	// Workdir is the server's working directory inside the container.
+	Workdir string
+
+	Env map[string]string
+
+	// User runs the server as uid[:gid], so files it writes to the mounts
+	// stay owned by the editor's user.
+	User string
+
+	// RunArgs are passed to run before the image, e.g. "--network=none".
+	RunArgs []string
+}
+
+// Command returns the run invocation. The container is removed when the
+// server exits.
+func (c *Container) Command(ctx context.Context) (*exec.Cmd, error) {
+	runtime := c.Runtime
+	if runtime == "" {
+		runtime = "docker"
+	}
+	args := []string{"run", "--rm", "-i"}
+	if c.User != "" {
+		args = append(args, "--user", c.User)
+	}
+	if c.Workdir != "" {
+		args = append(args, "--workdir", c.Workdir)
+	}
+	keys := make([]string, 0, len(c.Env))
+	for key := range c.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--env", key+"="+c.Env[key])
+	}
+	for _, m := range c.Mounts {
+		host, err := filepath.Abs(m.Host)
+		if err != nil {
+			return nil, fmt.Errorf("lspclient: mount %s: %w", m.Host, err)
+		}
+		spec := "type=bind,source=" + host + ",target=" + m.Container
+		if m.ReadOnly {
+			spec += ",readonly"
+		}
+		args = append(args, "--mount", spec)
+	}
+	args = append(args, c.RunArgs...)
+	args = append(args, c.Image)
+	if c.Server != "" {
+		args = append(args, c.Server)
+	}
+	args = append(args, c.Args...)
+	return exec.CommandContext(ctx, runtime, args...), nil
+}
+
+// PathMap translates between host and container paths for the mounts.
+func (c *Container) PathMap() *pathmap.Map {
+	prefixes := make([]pathmap.Prefix, 0, len(c.Mounts))
+	for _, m := range c.Mounts {
+		host, err := filepath.Abs(m.Host)
+		if err != nil {
+			host = m.Host
+		}
+		prefixes = append(prefixes, pathmap.Prefix{Client: filepath.ToSlash(host), Server: m.Container})
+	}
+	return pathmap.New(prefixes...)
+}
+
+// Start runs the container. The Process's stream carries host URIs: the
+// translation to container paths happens inside it.
+func (c *Container) Start(ctx context.Context) (*Process, error) {
+	cmd, err := c.Command(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Start(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Mounts) > 0 {
+		p.stream = c.PathMap().Stream(p.stream)
+	}
+	return p, nil
+}
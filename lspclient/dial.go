@@ -0,0 +1,68 @@
+package lspclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/pentops/lsplib/internal/websocket"
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// Dial connects to a server already listening at addr, a URL:
+//
+//	tcp://host:port    plain TCP
+//	tls://host:port    TCP with TLS
+//	unix:///path/sock  a Unix domain socket
+//	ws://host/path     a WebSocket, one message per JSON-RPC message
+//	wss://host/path    a WebSocket over TLS
+//
+// cfg configures TLS, for a client certificate or a private CA; nil uses
+// the system roots. The connection is ready for jsonrpc2.NewConn, which
+// sends WebSocket messages unframed. A failure to connect is a
+// *jsonrpc2.TransportError.
+func Dial(ctx context.Context, addr string, cfg *tls.Config) (net.Conn, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("lspclient: address %q: %w", addr, err)
+	}
+	var d net.Dialer
+	switch u.Scheme {
+	case "tcp":
+		return dialed(d.DialContext(ctx, "tcp", u.Host))
+	case "tls":
+		td := tls.Dialer{NetDialer: &d, Config: cfg}
+		return dialed(td.DialContext(ctx, "tcp", u.Host))
+	case "unix":
+		return dialed(d.DialContext(ctx, "unix", u.Path))
+	case "ws", "wss":
+		return dialed(websocket.Dial(ctx, u, nil, cfg))
+	}
+	return nil, fmt.Errorf("lspclient: address %q: unsupported scheme %q", addr, u.Scheme)
+}
+
+// DialWebSocket is Dial for ws:// and wss:// addresses, sending header
+// with the handshake, e.g. an Authorization bearer token for servers which
+// verify clients.
+func DialWebSocket(ctx context.Context, addr string, header http.Header, cfg *tls.Config) (net.Conn, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("lspclient: address %q: %w", addr, err)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, fmt.Errorf("lspclient: address %q: not a WebSocket URL", addr)
+	}
+	return dialed(websocket.Dial(ctx, u, header, cfg))
+}
+
+// dialed reports a failure to connect as a *jsonrpc2.TransportError, of
+// the class jsonrpc2.ErrTransport, as the failures of the connection are.
+func dialed(conn net.Conn, err error) (net.Conn, error) {
+	if err != nil {
+		return nil, &jsonrpc2.TransportError{Op: "dial", Err: err}
+	}
+	return conn, nil
+}
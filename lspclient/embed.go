@@ -0,0 +1,94 @@
+package lspclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsp"
+)
+
+// EmbedOptions configures Embed.
+type EmbedOptions struct {
+	// Framed carries messages as a child process's stdio would, with
+	// Content-Length headers over a byte stream, for tests which should
+	// exercise the wire format. Otherwise they pass between client and
+	// server whole, still encoded as JSON but never framed.
+	Framed bool
+
+	// Direct skips serialization where it can, passing params and results
+	// as the values sent, as jsonrpc2.DirectPipe describes; Framed
+	// overrides it. Neither side may then modify a value it has sent.
+	Direct bool
+}
+
+// Embedded is a server running in this process, as Process is one running
+// as a child, for editors and tests written in Go.
+type Embedded struct {
+	stream io.ReadWriteCloser
+	server *lsp.Server
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Embed serves server in the background, until the client exits, the
+// Embedded is closed or ctx is done.
+func Embed(ctx context.Context, server *lsp.Server, opts EmbedOptions) *Embedded {
+	var clientEnd, serverEnd io.ReadWriteCloser
+	switch {
+	case opts.Framed:
+		clientEnd, serverEnd = net.Pipe()
+	case opts.Direct:
+		clientEnd, serverEnd = jsonrpc2.DirectPipe()
+	default:
+		clientEnd, serverEnd = jsonrpc2.MessagePipe()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	e := &Embedded{stream: clientEnd, server: server, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		err := server.Serve(ctx, serverEnd)
+		_ = serverEnd.Close()
+		if err == nil && server.ExitCode() != 0 {
+			err = fmt.Errorf("lspclient: server exited with code %d", server.ExitCode())
+		}
+		e.err = err
+		close(e.done)
+	}()
+	return e
+}
+
+// Close ends the session, as closing a child's stdin does, and waits for
+// the server to stop.
+func (e *Embedded) Close() error {
+	_ = e.stream.Close()
+	<-e.done
+	e.cancel()
+	return nil
+}
+
+// Done is closed once the server has stopped.
+func (e *Embedded) Done() <-chan struct{} {
+	return e.done
+}
+
+// Wait blocks until the server stops. It fails unless the client shut the
+// server down before it exited.
+func (e *Embedded) Wait() error {
+	<-e.done
+	return e.err
+}
+
+// Server is the embedded server.
+func (e *Embedded) Server() *lsp.Server {
+	return e.server
+}
+
+// Connect returns a connection to the server. The caller runs it with
+// Conn.Run.
+func (e *Embedded) Connect(opts jsonrpc2.Options) *jsonrpc2.Conn {
+	return jsonrpc2.NewConn(e.stream, opts)
+}
@@ -0,0 +1,179 @@
+// Package lspclient starts language servers from the editor side and
+// connects to them: as local child processes, on remote hosts over SSH,
+// inside containers, over the network with Dial, or in this process with
+// Embed. Retrying resends the requests which can safely be sent again when
+// they fail transiently.
+package lspclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// ErrNotFound is wrapped by Process.Wait when the launcher could not find
+// the server binary.
+var ErrNotFound = errors.New("lspclient: server binary not found")
+
+// exitNotFound is the shell's exit status for a command it can't find.
+const exitNotFound = 127
+
+// gracePeriod is how long Close waits for the server to exit once its stdin
+// is closed before killing it.
+const gracePeriod = 5 * time.Second
+
+// Process is a server running as a child process, with its standard input
+// and output as the stream. It is an io.ReadWriteCloser, ready for
+// jsonrpc2.NewConn.
+type Process struct {
+	cmd    *exec.Cmd
+	stderr *tail
+
+	// stream is what Read, Write and Close use: the stdio pipes, possibly
+	// wrapped, as with a path-mapped container.
+	stream io.ReadWriteCloser
+
+	done chan struct{}
+	err  error
+}
+
+// Start runs cmd, which must not have its Stdin or Stdout set. Stderr is
+// kept for error reports and also copied to cmd.Stderr when that is set.
+// The process is killed if ctx is done before it exits.
+func Start(ctx context.Context, cmd *exec.Cmd) (*Process, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &Process{
+		cmd:    cmd,
+		stderr: &tail{w: cmd.Stderr},
+		stream: &stdio{stdout: stdout, stdin: stdin},
+		done:   make(chan struct{}),
+	}
+	cmd.Stderr = p.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("lspclient: starting %s: %w", cmd.Path, err)
+	}
+	go func() {
+		err := cmd.Wait()
+		p.err = p.exitError(err)
+		close(p.done)
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = cmd.Process.Kill()
+		case <-p.done:
+		}
+	}()
+	return p, nil
+}
+
+func (p *Process) Read(b []byte) (int, error)  { return p.stream.Read(b) }
+func (p *Process) Write(b []byte) (int, error) { return p.stream.Write(b) }
+
+// Close closes the server's stdin, which a well-behaved server treats as
+// the end of the session, and kills it if it hasn't exited shortly after.
+func (p *Process) Close() error {
+	_ = p.stream.Close()
+	select {
+	case <-p.done:
+	case <-time.After(gracePeriod):
+		_ = p.cmd.Process.Kill()
+		<-p.done
+	}
+	return nil
+}
+
+// Done is closed once the process has exited.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait blocks until the process exits. The error carries the end of the
+// server's stderr, which is usually where it says why.
+func (p *Process) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// Stderr returns the last few kilobytes the server wrote to stderr.
+func (p *Process) Stderr() string {
+	return p.stderr.String()
+}
+
+// Connect returns a connection over the process's stdio. The caller runs
+// it with Conn.Run.
+func (p *Process) Connect(opts jsonrpc2.Options) *jsonrpc2.Conn {
+	return jsonrpc2.NewConn(p, opts)
+}
+
+func (p *Process) exitError(err error) error {
+	if err == nil {
+		return nil
+	}
+	detail := strings.TrimSpace(p.stderr.String())
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == exitNotFound {
+		if detail != "" {
+			return fmt.Errorf("%w: %s", ErrNotFound, detail)
+		}
+		return ErrNotFound
+	}
+	if detail != "" {
+		return fmt.Errorf("lspclient: server exited: %w: %s", err, detail)
+	}
+	return fmt.Errorf("lspclient: server exited: %w", err)
+}
+
+// stdio is the process's pipes as one stream; closing it closes stdin.
+type stdio struct {
+	stdout io.Reader
+	stdin  io.WriteCloser
+}
+
+func (s *stdio) Read(b []byte) (int, error)  { return s.stdout.Read(b) }
+func (s *stdio) Write(b []byte) (int, error) { return s.stdin.Write(b) }
+func (s *stdio) Close() error                { return s.stdin.Close() }
+
+// tailSize is how much stderr a Process keeps.
+const tailSize = 4 << 10
+
+// tail keeps the end of what is written to it, passing everything on to w.
+type tail struct {
+	w io.Writer
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tail) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	t.buf = append(t.buf, b...)
+	if len(t.buf) > tailSize {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-tailSize:]...)
+	}
+	t.mu.Unlock()
+	if t.w != nil {
+		_, _ = t.w.Write(b)
+	}
+	return len(b), nil
+}
+
+func (t *tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
@@ -0,0 +1,150 @@
+package lspclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsperror"
+)
+
+const (
+	DefaultRetries = 2
+	DefaultBackoff = 200 * time.Millisecond
+)
+
+// Caller sends requests to a server; *jsonrpc2.Conn is one.
+type Caller interface {
+	Call(ctx context.Context, method string, params, result any) error
+}
+
+// Retrying is a Caller which retries idempotent requests that fail
+// transiently, such as those the server cancelled while busy. Retrying a
+// dropped connection is only worthwhile when Caller reconnects, as one
+// redialing with Dial can; such a Caller should count jsonrpc2.ErrClosed as
+// transient through Transient.
+type Retrying struct {
+	Caller Caller
+
+	// Retries is the number of additional attempts. Negative disables
+	// retries; zero means DefaultRetries.
+	Retries int
+
+	// Backoff is the delay before the first retry, doubling each time,
+	// DefaultBackoff when zero.
+	Backoff time.Duration
+
+	// Idempotent reports which methods are safe to send again, Idempotent
+	// when nil.
+	Idempotent func(method string) bool
+
+	// Transient reports which failures are worth another attempt,
+	// Transient when nil.
+	Transient func(err error) bool
+
+	// Clock times backoff, clock.Real when nil.
+	Clock clock.Clock
+}
+
+// RetryError is returned by Retrying.Call when every attempt at a request
+// failed transiently. It wraps the last failure.
+type RetryError struct {
+	Method   string
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("lspclient: %s failed after %d attempts: %v", e.Method, e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// Call sends the request through Caller, sending it again after a backoff
+// while it fails transiently, if method is idempotent and attempts remain.
+// If ctx is done during a backoff, the last failure is returned.
+func (r *Retrying) Call(ctx context.Context, method string, params, result any) error {
+	idempotent, transient := r.Idempotent, r.Transient
+	if idempotent == nil {
+		idempotent = Idempotent
+	}
+	if transient == nil {
+		transient = Transient
+	}
+	retries := r.Retries
+	if retries == 0 {
+		retries = DefaultRetries
+	}
+	if retries < 0 || !idempotent(method) {
+		retries = 0
+	}
+	backoff := r.Backoff
+	if backoff == 0 {
+		backoff = DefaultBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := r.Caller.Call(ctx, method, params, result)
+		if err == nil || !transient(err) || retries == 0 {
+			return err
+		}
+		if attempt >= retries {
+			return &RetryError{Method: method, Attempts: attempt + 1, Err: err}
+		}
+		if clock.Sleep(ctx, r.Clock, backoff<<attempt) != nil {
+			return err
+		}
+	}
+}
+
+// Idempotent reports whether sending a request for method twice leaves
+// the server as sending it once would: the queries of textDocument/,
+// resolve and hierarchy requests, and the workspace requests which only
+// compute. Requests with side effects, such as workspace/executeCommand,
+// and methods the protocol doesn't define are not.
+func Idempotent(method string) bool {
+	if idempotentMethods[method] {
+		return true
+	}
+	for _, prefix := range []string{"textDocument/", "callHierarchy/", "typeHierarchy/"} {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return strings.HasSuffix(method, "/resolve") && !strings.HasPrefix(method, "$/")
+}
+
+var idempotentMethods = map[string]bool{
+	"workspace/symbol":              true,
+	"workspace/diagnostic":          true,
+	"workspace/textDocumentContent": true,
+	"workspace/willCreateFiles":     true,
+	"workspace/willRenameFiles":     true,
+	"workspace/willDeleteFiles":     true,
+}
+
+// Transient reports whether err is a failure which may not recur: a
+// request the server cancelled, as it may when busy, or a connection which
+// timed out, was reset or refused. The caller's own deadline is not.
+func Transient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == lsperror.CodeServerCancelled
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF)
+}
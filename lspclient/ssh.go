@@ -0,0 +1,152 @@
+package lspclient
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultSearchPath is where SSH looks for a server binary which isn't on
+// the remote PATH; non-interactive SSH sessions often miss the directories
+// a user's shell profile adds.
+var DefaultSearchPath = []string{"~/go/bin", "~/.local/bin", "~/bin", "/usr/local/bin", "/opt/homebrew/bin"}
+
+// SSH launches a server on a remote host and tunnels its stdio back over
+// the SSH connection. It relies on the system ssh client, so keys, agents,
+// known hosts and ~/.ssh/config behave as they do on the command line.
+type SSH struct {
+	// Host is the destination, [user@]host or a ~/.ssh/config alias.
+	Host string
+
+	// Port and IdentityFile are passed as -p and -i when set.
+	Port         int
+	IdentityFile string
+
+	// Options are extra -o settings, such as "ProxyJump=bastion".
+	Options []string
+
+	// Client is the ssh executable, "ssh" when empty.
+	Client string
+
+	// Server is the server binary: a path, or a name looked up on the
+	// remote PATH and then in SearchPath.
+	Server string
+	Args   []string
+
+	// SearchPath is tried, in order, for a Server given by name which isn't
+	// on the remote PATH. Entries may start with ~/. DefaultSearchPath when
+	// nil.
+	SearchPath []string
+
+	// Profile sources the remote ~/.profile first, for servers which need
+	// the user's environment.
+	Profile bool
+
+	// Env is set in the server's environment; keys must be valid shell
+	// variable names. Dir is the server's working directory.
+	Env map[string]string
+	Dir string
+}
+
+// Command returns the ssh invocation which starts the server.
+func (s *SSH) Command(ctx context.Context) *exec.Cmd {
+	client := s.Client
+	if client == "" {
+		client = "ssh"
+	}
+	// -T: no pseudo-terminal, which would mangle the byte stream.
+	// BatchMode: fail rather than prompt on a stdin that is ours.
+	// ServerAlive*: notice a dead connection within about a minute.
+	args := []string{"-T", "-o", "BatchMode=yes", "-o", "ServerAliveInterval=15", "-o", "ServerAliveCountMax=4"}
+	if s.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(s.Port))
+	}
+	if s.IdentityFile != "" {
+		args = append(args, "-i", s.IdentityFile)
+	}
+	for _, opt := range s.Options {
+		args = append(args, "-o", opt)
+	}
+	args = append(args, s.Host, "sh -c "+shellQuote(s.Script()))
+	return exec.CommandContext(ctx, client, args...)
+}
+
+// Start runs the server on the remote host. A server the remote shell can't
+// find makes Process.Wait return an error wrapping ErrNotFound.
+func (s *SSH) Start(ctx context.Context) (*Process, error) {
+	for key := range s.Env {
+		if !validName(key) {
+			return nil, fmt.Errorf("lspclient: invalid environment variable name %q", key)
+		}
+	}
+	return Start(ctx, s.Command(ctx))
+}
+
+// Script is the remote shell script: set up the environment, find the
+// binary, then exec it so the server owns the tunnelled stdio.
+func (s *SSH) Script() string {
+	var b strings.Builder
+	if s.Profile {
+		b.WriteString(`[ -f "$HOME/.profile" ] && . "$HOME/.profile" >/dev/null 2>&1` + "\n")
+	}
+	if s.Dir != "" {
+		b.WriteString("cd " + remotePath(s.Dir) + " || exit 1\n")
+	}
+	keys := make([]string, 0, len(s.Env))
+	for key := range s.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString("export " + key + "=" + shellQuote(s.Env[key]) + "\n")
+	}
+
+	b.WriteString("server=" + remotePath(s.Server) + "\n")
+	if !strings.Contains(s.Server, "/") {
+		search := s.SearchPath
+		if search == nil {
+			search = DefaultSearchPath
+		}
+		b.WriteString(`if ! command -v "$server" >/dev/null 2>&1; then` + "\n")
+		b.WriteString("\tfor dir in")
+		for _, dir := range search {
+			b.WriteString(" " + remotePath(dir))
+		}
+		b.WriteString("; do\n")
+		b.WriteString("\t\tif [ -x \"$dir/$server\" ]; then server=\"$dir/$server\"; break; fi\n")
+		b.WriteString("\tdone\nfi\n")
+	}
+	b.WriteString(`if ! command -v "$server" >/dev/null 2>&1; then` + "\n")
+	b.WriteString("\techo \"$server not found on $(hostname)\" >&2\n\texit 127\nfi\n")
+
+	b.WriteString(`exec "$server"`)
+	for _, arg := range s.Args {
+		b.WriteString(" " + shellQuote(arg))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// remotePath quotes a path for the remote shell, expanding a leading ~/.
+func remotePath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return `"$HOME"/` + shellQuote(rest)
+	}
+	return shellQuote(path)
+}
+
+func validName(s string) bool {
+	for idx, r := range s {
+		if r != '_' && !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || idx > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,138 @@
+// Package lsperror defines the error codes of JSON-RPC and LSP, and the
+// errors handlers return to answer a request with one:
+//
+//	if params.TextDocument.Version != doc.Version {
+//		return nil, lsperror.ContentModified()
+//	}
+//
+// Errors which carry data, such as initialize's retry flag, are a
+// ResponseError with the data's type from the request's errorData.
+package lsperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// JSON-RPC error codes, with the two LSP defines in the range JSON-RPC
+// reserves for servers.
+const (
+	CodeParseError     = jsonrpc2.CodeParseError
+	CodeInvalidRequest = jsonrpc2.CodeInvalidRequest
+	CodeMethodNotFound = jsonrpc2.CodeMethodNotFound
+	CodeInvalidParams  = jsonrpc2.CodeInvalidParams
+	CodeInternalError  = jsonrpc2.CodeInternalError
+
+	// CodeServerNotInitialized answers requests sent before initialize.
+	CodeServerNotInitialized int64 = -32002
+	CodeUnknownErrorCode     int64 = -32001
+)
+
+// LSP error codes, in the range JSON-RPC reserves for implementations.
+const (
+	// CodeRequestFailed answers a request which was valid but failed, such
+	// as a rename to a name already taken.
+	CodeRequestFailed int64 = -32803
+
+	// CodeServerCancelled answers a request the server gave up on, only
+	// for requests the protocol says a server may cancel, such as
+	// diagnostic pulls.
+	CodeServerCancelled int64 = -32802
+
+	// CodeContentModified answers a request whose document changed while it
+	// ran, so its result would no longer apply.
+	CodeContentModified int64 = -32801
+
+	// CodeRequestCancelled answers a request the client cancelled.
+	CodeRequestCancelled = jsonrpc2.CodeRequestCancelled
+)
+
+// ResponseError is an error reply whose data has type D. The connection
+// sends it as a *jsonrpc2.Error, with Data encoded as JSON.
+type ResponseError[D any] struct {
+	Code    int64
+	Message string
+	Data    D
+}
+
+// New builds a ResponseError with a formatted message.
+func New[D any](code int64, data D, format string, args ...any) *ResponseError[D] {
+	return &ResponseError[D]{Code: code, Message: fmt.Sprintf(format, args...), Data: data}
+}
+
+func (e *ResponseError[D]) Error() string {
+	return fmt.Sprintf("lsp error %d: %s", e.Code, e.Message)
+}
+
+// As converts e to a *jsonrpc2.Error, so errors.As finds the reply to send.
+func (e *ResponseError[D]) As(target any) bool {
+	rpcErr, ok := target.(**jsonrpc2.Error)
+	if !ok {
+		return false
+	}
+	*rpcErr = &jsonrpc2.Error{Code: e.Code, Message: e.Message}
+	if data, err := json.Marshal(e.Data); err == nil && string(data) != "null" {
+		(*rpcErr).Data = data
+	}
+	return true
+}
+
+// Data decodes the data of an error reply as D, as a client receiving a
+// *jsonrpc2.Error reads it. It reports false when err is no reply or has no
+// data of that type.
+func Data[D any](err error) (D, bool) {
+	var data D
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) || len(rpcErr.Data) == 0 {
+		return data, false
+	}
+	if json.Unmarshal(rpcErr.Data, &data) != nil {
+		return data, false
+	}
+	return data, true
+}
+
+// Code is the code an error is answered with: that of a *jsonrpc2.Error or
+// ResponseError it wraps, or CodeInternalError.
+func Code(err error) int64 {
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code
+	}
+	return CodeInternalError
+}
+
+// Is reports whether err is an error reply with code.
+func Is(err error, code int64) bool {
+	var rpcErr *jsonrpc2.Error
+	return errors.As(err, &rpcErr) && rpcErr.Code == code
+}
+
+// ServerNotInitialized answers method, sent before initialize.
+func ServerNotInitialized(method string) *jsonrpc2.Error {
+	return jsonrpc2.NewError(CodeServerNotInitialized, "%s before initialize", method)
+}
+
+// RequestFailed answers a valid request which failed, with a formatted
+// message for the user.
+func RequestFailed(format string, args ...any) *jsonrpc2.Error {
+	return jsonrpc2.NewError(CodeRequestFailed, format, args...)
+}
+
+// ServerCancelled answers a request the server gave up on.
+func ServerCancelled() *jsonrpc2.Error {
+	return jsonrpc2.NewError(CodeServerCancelled, "server cancelled the request")
+}
+
+// ContentModified answers a request whose document changed while it ran.
+func ContentModified() *jsonrpc2.Error {
+	return jsonrpc2.NewError(CodeContentModified, "content modified")
+}
+
+// RequestCancelled answers a request the client cancelled.
+func RequestCancelled() *jsonrpc2.Error {
+	return jsonrpc2.NewError(CodeRequestCancelled, "request cancelled")
+}
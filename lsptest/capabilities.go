@@ -0,0 +1,139 @@
+package lsptest
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/lsperror"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Supported reports whether the server offers method to this session,
+// statically or by dynamic registration, for scenarios whose client
+// capabilities leave some features out. When it doesn't, Supported sends
+// the request with params and fails the test unless the server refuses it
+// with CodeMethodNotFound, as it must a method it never offered. Methods
+// no capability advertises are always supported.
+func (s *Session) Supported(t testing.TB, method string, params any) bool {
+	t.Helper()
+	capability, ok := lsp.CapabilityFor(method)
+	if !ok || s.Server.Registered(method) != lsp.NotRegistered {
+		return true
+	}
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	if err := s.Client.Call(ctx, method, params, nil); !lsperror.Is(err, lsperror.CodeMethodNotFound) {
+		t.Errorf("%s is not advertised but %s was answered with %v, not MethodNotFound", capability, method, err)
+	}
+	return false
+}
+
+// AssertNotOffered fails the test unless the server leaves method out and
+// refuses it, as it should when the session's client capabilities rule the
+// feature out.
+func (s *Session) AssertNotOffered(t testing.TB, method string, params any) {
+	t.Helper()
+	if s.Supported(t, method, params) {
+		t.Errorf("the server offers %s", method)
+	}
+}
+
+// Require skips the rest of the test, typically one variant of Permute,
+// when the server doesn't offer method, having checked with Supported that
+// it refuses it.
+func (s *Session) Require(t testing.TB, method string, params any) {
+	t.Helper()
+	if !s.Supported(t, method, params) {
+		t.Skipf("the server doesn't offer %s", method)
+	}
+}
+
+// AssertCapabilityAdvertised fails the test unless the initialize result
+// advertises capability, a dotted path into ServerCapabilities such as
+// "hoverProvider" or "semanticTokensProvider.full.delta".
+func (s *Session) AssertCapabilityAdvertised(t testing.TB, capability string) {
+	t.Helper()
+	if !s.Advertised(capability) {
+		t.Errorf("capability %s is not advertised", capability)
+	}
+}
+
+// AssertNoHandlerWithoutCapability fails the test for every registered
+// handler whose capability isn't advertised: the client will never call it.
+func (s *Session) AssertNoHandlerWithoutCapability(t testing.TB) {
+	t.Helper()
+	for _, method := range s.Server.Mux.Methods() {
+		capability, ok := lsp.CapabilityFor(method)
+		if ok && !s.Advertised(capability) {
+			t.Errorf("%s has a handler but %s is not advertised", method, capability)
+		}
+	}
+}
+
+// AssertNoCapabilityWithoutHandler fails the test for every advertised
+// capability with no handler for any of its methods: the client will call
+// them and get MethodNotFound.
+func (s *Session) AssertNoCapabilityWithoutHandler(t testing.TB) {
+	t.Helper()
+	handled := map[string]bool{}
+	for _, method := range s.Server.Mux.Methods() {
+		if capability, ok := lsp.CapabilityFor(method); ok {
+			handled[capability] = true
+		}
+	}
+	methods := map[string][]string{}
+	for method, capability := range lsp.MethodCapabilities() {
+		methods[capability] = append(methods[capability], method)
+	}
+	capabilities := make([]string, 0, len(methods))
+	for capability := range methods {
+		capabilities = append(capabilities, capability)
+	}
+	sort.Strings(capabilities)
+	for _, capability := range capabilities {
+		if s.Advertised(capability) && !handled[capability] {
+			sort.Strings(methods[capability])
+			t.Errorf("%s is advertised but none of %s has a handler", capability, strings.Join(methods[capability], ", "))
+		}
+	}
+}
+
+// Advertised reports whether the initialize result advertises a capability:
+// present and neither null, false nor zero.
+func (s *Session) Advertised(capability string) bool {
+	return advertises(s.Result.Capabilities, capability)
+}
+
+func advertises(caps protocol.ServerCapabilities, capability string) bool {
+	raw, err := json.Marshal(caps)
+	if err != nil {
+		return false
+	}
+	var value any
+	if json.Unmarshal(raw, &value) != nil {
+		return false
+	}
+	for _, key := range strings.Split(capability, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		if value, ok = object[key]; !ok {
+			return false
+		}
+	}
+	switch value := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	}
+	return true
+}
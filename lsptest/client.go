@@ -0,0 +1,314 @@
+package lsptest
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Client is a scriptable client connected to a server under test over an
+// in-memory pipe, for integration tests which would otherwise spawn the
+// server as a subprocess. It records every notification the server sends
+// and tracks the versions of the documents it opens. Its methods fail the
+// test when the connection does; Request returns error replies for the test
+// to check.
+type Client struct {
+	Server *lsp.Server
+	Conn   *jsonrpc2.Conn
+
+	// Mux serves requests and notifications from the server.
+	// window/workDoneProgress/create is answered by default.
+	Mux *jsonrpc2.Mux
+
+	// Result is the server's initialize result, once Initialize has run.
+	Result *protocol.InitializeResult
+
+	// Timeout bounds each request and each wait for a notification, 5s
+	// when zero.
+	Timeout time.Duration
+
+	t testing.TB
+
+	mu            sync.Mutex
+	notifications []Notification
+	// arrived is closed, and replaced, when a notification is recorded.
+	arrived  chan struct{}
+	versions map[protocol.DocumentURI]int32
+	// edited holds how many notifications had arrived when each document
+	// was last opened or changed, so waits skip diagnostics from before.
+	edited map[protocol.DocumentURI]int
+}
+
+// Notification is a notification the server sent.
+type Notification struct {
+	Method string
+	Params json.RawMessage
+}
+
+// NewClient serves server over a pipe and connects a Client to it, without
+// initializing. The server is shut down when the test ends.
+func NewClient(t testing.TB, server *lsp.Server) *Client {
+	t.Helper()
+	serverEnd, clientEnd := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = server.Serve(ctx, serverEnd)
+	}()
+
+	c := &Client{
+		Server:   server,
+		Conn:     jsonrpc2.NewConn(clientEnd, jsonrpc2.Options{}),
+		Mux:      jsonrpc2.NewMux(),
+		t:        t,
+		arrived:  make(chan struct{}),
+		versions: map[protocol.DocumentURI]int32{},
+		edited:   map[protocol.DocumentURI]int{},
+	}
+	c.Mux.RegisterFunc("window/workDoneProgress/create", func(context.Context, *jsonrpc2.Request) (any, error) {
+		return nil, nil
+	})
+	go func() { _ = c.Conn.Run(ctx, jsonrpc2.HandlerFunc(c.handle)) }()
+
+	t.Cleanup(func() {
+		shutdownCtx, done := context.WithTimeout(context.Background(), 5*time.Second)
+		defer done()
+		_ = c.Conn.Call(shutdownCtx, "shutdown", nil, nil)
+		_ = c.Conn.Notify(shutdownCtx, "exit", nil)
+		select {
+		case <-served:
+		case <-shutdownCtx.Done():
+		}
+		cancel()
+		_ = c.Conn.Close()
+	})
+	return c
+}
+
+func (c *Client) handle(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	if req.IsNotification() {
+		c.mu.Lock()
+		c.notifications = append(c.notifications, Notification{Method: req.Method, Params: req.Params})
+		close(c.arrived)
+		c.arrived = make(chan struct{})
+		c.mu.Unlock()
+	}
+	return c.Mux.Handle(ctx, req)
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 5 * time.Second
+}
+
+// Initialize runs the initialize handshake with params, which may be nil,
+// and returns the server's result.
+func (c *Client) Initialize(params *protocol.InitializeParams) *protocol.InitializeResult {
+	c.t.Helper()
+	if params == nil {
+		params = &protocol.InitializeParams{}
+	}
+	result := &protocol.InitializeResult{}
+	if err := c.Request("initialize", params, result); err != nil {
+		c.t.Fatalf("initialize: %v", err)
+	}
+	c.Result = result
+	c.Notify("initialized", &protocol.InitializedParams{})
+	return result
+}
+
+// Request sends a request and decodes its result into result, which may be
+// nil. An error reply is returned as a *jsonrpc2.Error.
+func (c *Client) Request(method string, params, result any) error {
+	c.t.Helper()
+	ctx, done := context.WithTimeout(context.Background(), c.timeout())
+	defer done()
+	return c.Conn.Call(ctx, method, params, result)
+}
+
+// Notify sends a notification.
+func (c *Client) Notify(method string, params any) {
+	c.t.Helper()
+	ctx, done := context.WithTimeout(context.Background(), c.timeout())
+	defer done()
+	if err := c.Conn.Notify(ctx, method, params); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+}
+
+// OpenDocument opens a document at version 1, its language id being the
+// extension of uri.
+func (c *Client) OpenDocument(uri protocol.DocumentURI, text string) {
+	c.t.Helper()
+	version := c.edit(uri, true)
+	c.Notify("textDocument/didOpen", &protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{
+		URI:        uri,
+		LanguageID: strings.TrimPrefix(path.Ext(string(uri)), "."),
+		Version:    version,
+		Text:       text,
+	}})
+}
+
+// ChangeDocument replaces the whole text of an open document, bumping its
+// version.
+func (c *Client) ChangeDocument(uri protocol.DocumentURI, text string) {
+	c.t.Helper()
+	version := c.edit(uri, false)
+	c.Notify("textDocument/didChange", &protocol.DidChangeTextDocumentParams{
+		TextDocument:   protocol.VersionedTextDocumentIdentifier{URI: uri, Version: version},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{{Text: text}},
+	})
+}
+
+// CloseDocument closes a document.
+func (c *Client) CloseDocument(uri protocol.DocumentURI) {
+	c.t.Helper()
+	c.mu.Lock()
+	delete(c.versions, uri)
+	c.edited[uri] = len(c.notifications)
+	c.mu.Unlock()
+	c.Notify("textDocument/didClose", &protocol.DidCloseTextDocumentParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}})
+}
+
+// edit records an open or change of uri and returns its new version.
+func (c *Client) edit(uri protocol.DocumentURI, open bool) int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.versions[uri]
+	switch {
+	case open && ok:
+		c.t.Fatalf("%s is already open", uri)
+	case !open && !ok:
+		c.t.Fatalf("%s is not open", uri)
+	}
+	c.versions[uri]++
+	c.edited[uri] = len(c.notifications)
+	return c.versions[uri]
+}
+
+// Version is the version of an open document, and whether it is open.
+func (c *Client) Version(uri protocol.DocumentURI) (int32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	version, ok := c.versions[uri]
+	return version, ok
+}
+
+// Notifications returns the notifications received so far with method, or
+// all of them when method is empty.
+func (c *Client) Notifications(method string) []Notification {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []Notification
+	for _, n := range c.notifications {
+		if method == "" || n.Method == method {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// WaitNotification returns the first notification with method whose params
+// match, which may be nil to match any, waiting for one if none has arrived.
+// The test fails if none arrives within the timeout.
+func (c *Client) WaitNotification(method string, match func(params json.RawMessage) bool) Notification {
+	c.t.Helper()
+	n, ok := c.wait(0, false, func(n Notification) bool {
+		return n.Method == method && (match == nil || match(n.Params))
+	})
+	if !ok {
+		c.t.Fatalf("no %s notification within %v", method, c.timeout())
+	}
+	return n
+}
+
+// wait returns the first, or last, notification from index from which
+// matches once one has arrived, or false at the timeout.
+func (c *Client) wait(from int, last bool, match func(Notification) bool) (Notification, bool) {
+	deadline := time.NewTimer(c.timeout())
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		var found *Notification
+		for idx := from; idx < len(c.notifications); idx++ {
+			if n := c.notifications[idx]; match(n) {
+				found = &n
+				if !last {
+					break
+				}
+			}
+		}
+		arrived := c.arrived
+		c.mu.Unlock()
+		if found != nil {
+			return *found, true
+		}
+		select {
+		case <-arrived:
+		case <-deadline.C:
+			return Notification{}, false
+		}
+	}
+}
+
+// Diagnostics waits for the server to publish diagnostics for uri since the
+// document was last opened, changed or closed, for its current version or
+// none, and returns the latest such. The test fails if none are published
+// within the timeout.
+func (c *Client) Diagnostics(uri protocol.DocumentURI) []protocol.Diagnostic {
+	c.t.Helper()
+	c.mu.Lock()
+	from := c.edited[uri]
+	version, open := c.versions[uri]
+	c.mu.Unlock()
+
+	decode := func(n Notification) (params protocol.PublishDiagnosticsParams, ok bool) {
+		if n.Method != "textDocument/publishDiagnostics" || json.Unmarshal(n.Params, &params) != nil {
+			return params, false
+		}
+		return params, params.URI == uri && (params.Version == nil || open && *params.Version == version)
+	}
+	n, ok := c.wait(from, true, func(n Notification) bool {
+		_, ok := decode(n)
+		return ok
+	})
+	if !ok {
+		c.t.Fatalf("no diagnostics for %s within %v", uri, c.timeout())
+	}
+	params, _ := decode(n)
+	return params.Diagnostics
+}
+
+// AssertDiagnostics fails the test unless the diagnostics Diagnostics
+// returns for uri have exactly the given messages, in order.
+func (c *Client) AssertDiagnostics(uri protocol.DocumentURI, messages ...string) {
+	c.t.Helper()
+	diags := c.Diagnostics(uri)
+	got := make([]string, len(diags))
+	for idx, d := range diags {
+		got[idx] = d.Message
+	}
+	if !slices.Equal(got, messages) {
+		c.t.Errorf("%s has diagnostics %q, want %q", uri, got, messages)
+	}
+}
+
+// AssertNoDiagnostics fails the test unless the server publishes an empty
+// set of diagnostics for uri.
+func (c *Client) AssertNoDiagnostics(uri protocol.DocumentURI) {
+	c.t.Helper()
+	c.AssertDiagnostics(uri)
+}
@@ -0,0 +1,161 @@
+package lsptest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+)
+
+// FakeClock is a clock.Clock which only moves when told to. Timers fire
+// during Advance, in deadline order, with Now reporting each timer's
+// deadline as it fires. AfterFunc callbacks run synchronously on the
+// goroutine calling Advance.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	seq     int
+	timers  []*fakeTimer
+	changed chan struct{}
+}
+
+var _ clock.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a fake clock reading start, or an arbitrary fixed
+// time when start is zero.
+func NewFakeClock(start time.Time) *FakeClock {
+	if start.IsZero() {
+		start = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return &FakeClock{now: start, changed: make(chan struct{})}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) clock.Timer {
+	ch := make(chan time.Time, 1)
+	t := &fakeTimer{clock: c, c: ch}
+	t.fire = func(now time.Time) {
+		select {
+		case ch <- now:
+		default:
+		}
+	}
+	t.Reset(d)
+	return t
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	t := &fakeTimer{clock: c, fire: func(time.Time) { f() }}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer due on the way.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		if len(c.timers) == 0 || c.timers[0].when.After(target) {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		t.armed = false
+		c.now = t.when
+		c.mu.Unlock()
+		t.fire(t.when)
+	}
+}
+
+// Pending is the number of armed timers.
+func (c *FakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// WaitFor blocks until at least n timers are armed, so a test can advance
+// the clock only once the code under test has started waiting. It gives up
+// after timeout of real time, reporting whether the timers appeared.
+func (c *FakeClock) WaitFor(n int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		if len(c.timers) >= n {
+			c.mu.Unlock()
+			return true
+		}
+		changed := c.changed
+		c.mu.Unlock()
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+// schedule arms or disarms t, reporting whether it was armed. The caller
+// holds c.mu.
+func (c *FakeClock) schedule(t *fakeTimer, d time.Duration, arm bool) bool {
+	wasArmed := t.armed
+	if wasArmed {
+		for idx, other := range c.timers {
+			if other == t {
+				c.timers = append(c.timers[:idx], c.timers[idx+1:]...)
+				break
+			}
+		}
+	}
+	t.armed = arm
+	if arm {
+		c.seq++
+		t.when, t.seq = c.now.Add(d), c.seq
+		c.timers = append(c.timers, t)
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			if !c.timers[i].when.Equal(c.timers[j].when) {
+				return c.timers[i].when.Before(c.timers[j].when)
+			}
+			return c.timers[i].seq < c.timers[j].seq
+		})
+	}
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return wasArmed
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	c     <-chan time.Time
+	fire  func(time.Time)
+
+	// Guarded by clock.mu.
+	when  time.Time
+	seq   int
+	armed bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.schedule(t, 0, false)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.schedule(t, d, true)
+}
@@ -0,0 +1,394 @@
+package lsptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Coverage records the protocol surface a server's tests exercise: which
+// of the methods it offers clients call, and under which client
+// capabilities, so a team can see the features their tests never reach.
+// Install a Middleware on every server the tests start and report once
+// they have run:
+//
+//	var coverage lsptest.Coverage
+//
+//	func newServer() *lsp.Server {
+//		return lsp.NewServer(lsp.Options{
+//			Middleware: []jsonrpc2.Middleware{coverage.Middleware()},
+//		})
+//	}
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		coverage.Report(os.Stdout)
+//		os.Exit(code)
+//	}
+//
+// A method is offered when a session's initialize result advertises its
+// capability, or when Offer names it. A branch is a client capability
+// under the method's part of ClientCapabilities, such as
+// textDocument.completion.completionItem.snippetSupport, or general ones
+// for textDocument methods; it is covered once the method was called in a
+// session with it set and in one without. Permute's variants cover
+// markdown, snippets and position encodings both ways. The zero value is
+// usable and safe for concurrent use.
+type Coverage struct {
+	mu       sync.Mutex
+	methods  map[string]*methodCoverage
+	offered  map[string]bool
+	sessions []*coverageSession
+}
+
+type methodCoverage struct {
+	calls, errors int
+	// sessions are those the method was called in.
+	sessions map[*coverageSession]bool
+}
+
+// coverageSession is one server's session: the client capabilities it
+// initialized with, as the leaves flattenCapabilities finds.
+type coverageSession struct {
+	capabilities map[string]bool
+}
+
+// lifecycle methods are exercised by every session and reported by none.
+var lifecycleMethods = map[string]bool{
+	"initialize":  true,
+	"initialized": true,
+	"shutdown":    true,
+	"exit":        true,
+}
+
+// Middleware records the messages of one server's session. Each server
+// needs its own, as it keeps the session's client capabilities.
+func (c *Coverage) Middleware() jsonrpc2.Middleware {
+	sess := &coverageSession{capabilities: map[string]bool{}}
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			result, err := next.Handle(ctx, req)
+			if req.Method == "initialize" {
+				c.initialized(sess, req, result, err)
+			} else if !lifecycleMethods[req.Method] {
+				c.record(sess, req.Method, err)
+			}
+			return result, err
+		})
+	}
+}
+
+// Offer adds methods the server offers without advertising them in the
+// initialize result, such as its custom requests or those registered
+// dynamically; s.Mux.Methods(), typically.
+func (c *Coverage) Offer(methods ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, method := range methods {
+		if !lifecycleMethods[method] {
+			c.offer(method)
+		}
+	}
+}
+
+func (c *Coverage) offer(method string) {
+	if c.offered == nil {
+		c.offered = map[string]bool{}
+	}
+	c.offered[method] = true
+}
+
+func (c *Coverage) initialized(sess *coverageSession, req *jsonrpc2.Request, result any, err error) {
+	if err != nil {
+		return
+	}
+	var params struct {
+		Capabilities json.RawMessage `json:"capabilities"`
+	}
+	_ = json.Unmarshal(req.Params, &params)
+	var capabilities any
+	_ = json.Unmarshal(params.Capabilities, &capabilities)
+	initialize, _ := result.(*protocol.InitializeResult)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(sess.capabilities)
+	flattenCapabilities("", capabilities, sess.capabilities)
+	if !slices.Contains(c.sessions, sess) {
+		c.sessions = append(c.sessions, sess)
+	}
+	if initialize == nil {
+		return
+	}
+	for method, capability := range lsp.MethodCapabilities() {
+		if advertises(initialize.Capabilities, capability) {
+			c.offer(method)
+		}
+	}
+}
+
+func (c *Coverage) record(sess *coverageSession, method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.methods == nil {
+		c.methods = map[string]*methodCoverage{}
+	}
+	m := c.methods[method]
+	if m == nil {
+		m = &methodCoverage{sessions: map[*coverageSession]bool{}}
+		c.methods[method] = m
+	}
+	m.calls++
+	if err != nil {
+		m.errors++
+	}
+	m.sessions[sess] = true
+}
+
+// flattenCapabilities adds the leaves of capabilities below path to out,
+// each with whether it is set: a boolean true or a number other than zero.
+// Each string, alone or in an array, is a leaf of its own, as
+// "general.positionEncodings[utf-8]", since the values a client lists,
+// markdown among markup kinds say, are what servers branch on.
+func flattenCapabilities(path string, value any, out map[string]bool) {
+	key := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+	switch value := value.(type) {
+	case map[string]any:
+		for name, v := range value {
+			flattenCapabilities(key(name), v, out)
+		}
+	case []any:
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				out[path+"["+s+"]"] = true
+			}
+		}
+	case string:
+		out[path+"["+value+"]"] = true
+	case bool:
+		out[path] = value
+	case float64:
+		out[path] = value != 0
+	}
+}
+
+// clientSections overrides the part of ClientCapabilities a method's
+// behaviour depends on where it isn't named after the method, by method or
+// by the prefix of a family of methods.
+var clientSections = map[string]string{
+	"completionItem/":                   "textDocument.completion",
+	"codeAction/":                       "textDocument.codeAction",
+	"codeLens/":                         "textDocument.codeLens",
+	"documentLink/":                     "textDocument.documentLink",
+	"inlayHint/":                        "textDocument.inlayHint",
+	"callHierarchy/":                    "textDocument.callHierarchy",
+	"typeHierarchy/":                    "textDocument.typeHierarchy",
+	"workspaceSymbol/":                  "workspace.symbol",
+	"textDocument/prepareCallHierarchy": "textDocument.callHierarchy",
+	"textDocument/prepareTypeHierarchy": "textDocument.typeHierarchy",
+	"textDocument/prepareRename":        "textDocument.rename",
+	"textDocument/documentColor":        "textDocument.colorProvider",
+	"textDocument/colorPresentation":    "textDocument.colorProvider",
+	"textDocument/didOpen":              "textDocument.synchronization",
+	"textDocument/didChange":            "textDocument.synchronization",
+	"textDocument/didClose":             "textDocument.synchronization",
+	"textDocument/didSave":              "textDocument.synchronization",
+	"textDocument/willSave":             "textDocument.synchronization",
+	"textDocument/willSaveWaitUntil":    "textDocument.synchronization",
+	"workspace/diagnostic":              "textDocument.diagnostic",
+}
+
+// branchPrefixes are the capability paths whose leaves are method's
+// branches.
+func branchPrefixes(method string) []string {
+	section, ok := clientSections[method]
+	if !ok {
+		if i := strings.IndexByte(method, '/'); i >= 0 {
+			section, ok = clientSections[method[:i+1]]
+		}
+	}
+	if !ok {
+		family, name, found := strings.Cut(method, "/")
+		if !found || (family != "textDocument" && family != "workspace") {
+			return nil
+		}
+		name, _, _ = strings.Cut(name, "/")
+		section = family + "." + name
+	}
+	prefixes := []string{section + "."}
+	if strings.HasPrefix(section, "textDocument.") {
+		prefixes = append(prefixes, "general.")
+	}
+	return prefixes
+}
+
+// MethodCoverage is how a method was exercised.
+type MethodCoverage struct {
+	Method string
+
+	// Offered reports whether a session advertised the method, or Offer
+	// named it.
+	Offered bool
+
+	// Calls counts the requests and notifications of the method the
+	// server handled, and Errors the requests it failed.
+	Calls  int
+	Errors int
+}
+
+// BranchCoverage is how a client capability was exercised by a method.
+type BranchCoverage struct {
+	Method     string
+	Capability string
+
+	// Set and Unset report whether the method was called in a session
+	// with the capability set, and in one without.
+	Set, Unset bool
+}
+
+// CoverageSummary is what a Coverage recorded, sorted by method.
+type CoverageSummary struct {
+	// Methods are those offered or called, lifecycle methods aside.
+	Methods []MethodCoverage
+
+	// Branches are those of every method called.
+	Branches []BranchCoverage
+}
+
+// Summary returns what has been recorded so far.
+func (c *Coverage) Summary() CoverageSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var summary CoverageSummary
+	names := map[string]bool{}
+	for method := range c.offered {
+		names[method] = true
+	}
+	for method := range c.methods {
+		names[method] = true
+	}
+	for _, method := range sortedSet(names) {
+		mc := MethodCoverage{Method: method, Offered: c.offered[method]}
+		m := c.methods[method]
+		if m != nil {
+			mc.Calls, mc.Errors = m.calls, m.errors
+		}
+		summary.Methods = append(summary.Methods, mc)
+		if m != nil {
+			summary.Branches = append(summary.Branches, c.branches(method, m)...)
+		}
+	}
+	return summary
+}
+
+// branches are method's branches: the capabilities some session set under
+// its prefixes, with how the sessions calling it had them.
+func (c *Coverage) branches(method string, m *methodCoverage) []BranchCoverage {
+	prefixes := branchPrefixes(method)
+	capabilities := map[string]bool{}
+	for _, sess := range c.sessions {
+		for capability, set := range sess.capabilities {
+			if set && hasAnyPrefix(capability, prefixes) {
+				capabilities[capability] = true
+			}
+		}
+	}
+	var out []BranchCoverage
+	for _, capability := range sortedSet(capabilities) {
+		b := BranchCoverage{Method: method, Capability: capability}
+		for sess := range m.sessions {
+			if sess.capabilities[capability] {
+				b.Set = true
+			} else {
+				b.Unset = true
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedSet(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for s := range set {
+		out = append(out, s)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Report writes the summary to w: how many of the methods offered were
+// exercised, each method's calls, and the branches exercised only one way,
+// those being where tests most often miss a feature.
+func (c *Coverage) Report(w io.Writer) error {
+	summary := c.Summary()
+	offered, exercised := 0, 0
+	for _, m := range summary.Methods {
+		if m.Offered {
+			offered++
+			if m.Calls > 0 {
+				exercised++
+			}
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "protocol coverage: %d of %d offered methods exercised (%.1f%%)\n", exercised, offered, percent(exercised, offered))
+	for _, m := range summary.Methods {
+		switch {
+		case m.Calls == 0:
+			fmt.Fprintf(tw, "  %s\tnot exercised\n", m.Method)
+		case !m.Offered:
+			fmt.Fprintf(tw, "  %s\t%d calls\t%d errors\tnot offered\n", m.Method, m.Calls, m.Errors)
+		default:
+			fmt.Fprintf(tw, "  %s\t%d calls\t%d errors\t\n", m.Method, m.Calls, m.Errors)
+		}
+	}
+	both := 0
+	var partial []BranchCoverage
+	for _, b := range summary.Branches {
+		if b.Set && b.Unset {
+			both++
+		} else {
+			partial = append(partial, b)
+		}
+	}
+	fmt.Fprintf(tw, "\nclient capability branches: %d of %d exercised both ways (%.1f%%)\n", both, len(summary.Branches), percent(both, len(summary.Branches)))
+	for _, b := range partial {
+		only := "set"
+		if b.Unset {
+			only = "unset"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\tonly %s\n", b.Method, b.Capability, only)
+	}
+	return tw.Flush()
+}
+
+func percent(n, of int) float64 {
+	if of == 0 {
+		return 100
+	}
+	return 100 * float64(n) / float64(of)
+}
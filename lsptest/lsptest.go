@@ -0,0 +1,35 @@
+// Package lsptest drives an lsp.Server from tests: it connects a client over
+// an in-memory pipe, runs the initialize handshake, scripts documents and
+// requests through a Client, and offers assertions about what the server
+// sends back. Coverage reports the parts of the protocol a suite exercises.
+package lsptest
+
+import (
+	"testing"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Session is a server under test with a connected, initialized client.
+type Session struct {
+	Server *lsp.Server
+	Client *jsonrpc2.Conn
+
+	// ClientMux serves requests and notifications from the server.
+	// window/workDoneProgress/create is answered by default.
+	ClientMux *jsonrpc2.Mux
+
+	// Result is the server's initialize result.
+	Result *protocol.InitializeResult
+}
+
+// Start serves server over a pipe and initializes it with params, which may
+// be nil. The session is shut down when the test ends.
+func Start(t testing.TB, server *lsp.Server, params *protocol.InitializeParams) *Session {
+	t.Helper()
+	c := NewClient(t, server)
+	c.Initialize(params)
+	return &Session{Server: server, Client: c.Conn, ClientMux: c.Mux, Result: c.Result}
+}
@@ -0,0 +1,161 @@
+package lsptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Variant is one permutation of the client capabilities servers most often
+// branch on.
+type Variant struct {
+	Markdown bool
+	Snippets bool
+	Encoding position.Encoding
+}
+
+// Variants returns every combination of markdown on and off, snippets on and
+// off, and UTF-8 or UTF-16 positions.
+func Variants() []Variant {
+	var out []Variant
+	for _, markdown := range []bool{true, false} {
+		for _, snippets := range []bool{true, false} {
+			for _, enc := range []position.Encoding{position.UTF16, position.UTF8} {
+				out = append(out, Variant{Markdown: markdown, Snippets: snippets, Encoding: enc})
+			}
+		}
+	}
+	return out
+}
+
+func (v Variant) String() string {
+	markup := "plaintext"
+	if v.Markdown {
+		markup = "markdown"
+	}
+	snippets := "nosnippets"
+	if v.Snippets {
+		snippets = "snippets"
+	}
+	return markup + "," + snippets + "," + string(v.Encoding)
+}
+
+// Capabilities returns client capabilities declaring the variant.
+func (v Variant) Capabilities() protocol.ClientCapabilities {
+	formats := []protocol.MarkupKind{protocol.PlainText}
+	if v.Markdown {
+		formats = []protocol.MarkupKind{protocol.Markdown, protocol.PlainText}
+	}
+	return protocol.ClientCapabilities{
+		TextDocument: &protocol.TextDocumentClientCapabilities{
+			Completion: &protocol.CompletionClientCapabilities{
+				CompletionItem: &protocol.CompletionItemClientCapabilities{
+					SnippetSupport:      v.Snippets,
+					DocumentationFormat: formats,
+				},
+			},
+			Hover: &protocol.HoverClientCapabilities{ContentFormat: formats},
+		},
+		General: &protocol.GeneralClientCapabilities{
+			PositionEncodings: []string{string(v.Encoding)},
+		},
+	}
+}
+
+// Run is a scenario's view of one variant: an initialized session whose
+// responses are checked against what the client declared.
+type Run struct {
+	*Session
+	Variant Variant
+
+	t testing.TB
+}
+
+// Permute runs scenario once per variant, each in its own subtest against a
+// fresh server from newServer.
+func Permute(t *testing.T, newServer func() *lsp.Server, scenario func(t *testing.T, run *Run)) {
+	t.Helper()
+	for _, v := range Variants() {
+		t.Run(v.String(), func(t *testing.T) {
+			params := &protocol.InitializeParams{Capabilities: v.Capabilities()}
+			sess := Start(t, newServer(), params)
+			run := &Run{Session: sess, Variant: v, t: t}
+			if enc := sess.Result.Capabilities.PositionEncoding; enc != "" && enc != string(v.Encoding) && enc != string(position.UTF16) {
+				t.Errorf("server chose position encoding %s, the client offered %s", enc, v.Encoding)
+			}
+			scenario(t, run)
+		})
+	}
+}
+
+// Encoding is the position encoding the server negotiated for this run.
+func (r *Run) Encoding() position.Encoding {
+	if enc := r.Result.Capabilities.PositionEncoding; enc != "" {
+		return position.Encoding(enc)
+	}
+	return position.Default
+}
+
+// Call sends a request, fails the test if the response uses anything the
+// variant didn't declare, and decodes it into result, which may be nil.
+func (r *Run) Call(method string, params, result any) error {
+	r.t.Helper()
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	var raw json.RawMessage
+	if err := r.Client.Call(ctx, method, params, &raw); err != nil {
+		return err
+	}
+	for _, problem := range r.Variant.Check(raw) {
+		r.t.Errorf("%s: %s", method, problem)
+	}
+	if result == nil || len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// Check lists the ways a response uses capabilities the variant lacks:
+// markdown MarkupContent without markdown support, and snippet completion
+// items without snippet support.
+func (v Variant) Check(raw json.RawMessage) []string {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	var problems []string
+	var walk func(path []string, value any)
+	walk = func(path []string, value any) {
+		switch value := value.(type) {
+		case map[string]any:
+			where := "result"
+			if len(path) > 0 {
+				where += "." + strings.Join(path, ".")
+			}
+			if _, hasValue := value["value"]; hasValue && value["kind"] == string(protocol.Markdown) && !v.Markdown {
+				problems = append(problems, where+" is markdown but the client only renders plaintext")
+			}
+			if format, ok := value["insertTextFormat"].(float64); ok && format == 2 && !v.Snippets {
+				problems = append(problems, where+" is a snippet but the client has no snippet support")
+			}
+			for key, item := range value {
+				walk(append(path, key), item)
+			}
+		case []any:
+			for idx, item := range value {
+				walk(append(path, fmt.Sprint(idx)), item)
+			}
+		}
+	}
+	walk(nil, value)
+	sort.Strings(problems)
+	return problems
+}
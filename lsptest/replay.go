@@ -0,0 +1,53 @@
+package lsptest
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/replay"
+)
+
+// Replay replays the session recorded in the file at path, as a
+// replay.Recorder writes it, against server, failing the test with each
+// response which differs from the one recorded. Record a session with the
+// server run from an editor, confirm it behaves, and keep the recording as
+// a test:
+//
+//	rec := &replay.Recorder{Out: file}
+//	opts.Conn.Rewrite = rec.ServerRewrite()
+func Replay(t testing.TB, server *lsp.Server, path string, opts replay.Options) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	frames, err := replay.Read(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+
+	serverEnd, clientEnd := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = server.Serve(ctx, serverEnd)
+	}()
+	defer func() {
+		cancel()
+		_ = clientEnd.Close()
+		<-served
+	}()
+
+	mismatches, err := replay.Replay(ctx, clientEnd, frames, opts)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	for _, m := range mismatches {
+		t.Errorf("%s: %s", path, m)
+	}
+}
@@ -0,0 +1,79 @@
+package lsptest
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/pentops/lsplib/internal/metamodel"
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// SoakOptions configures Soak.
+type SoakOptions struct {
+	// MetaModel is the metaModel file or URL requests are generated from,
+	// such as the metaModel.json of the protocol version the server
+	// implements. It is required.
+	MetaModel string
+
+	// Methods are the requests to send; every client-to-server request
+	// other than initialize and shutdown when empty.
+	Methods []string
+
+	// Seed makes the generated params reproducible.
+	Seed int64
+
+	// Stress configures the run; its Requests are replaced by the
+	// generated ones.
+	Stress StressOptions
+}
+
+// Soak is Stress with requests generated from the LSP metaModel: random
+// but schema-valid params for each method, aimed at the run's documents.
+// It complements fuzzing with malformed input by covering handlers with
+// valid input they may not expect.
+func Soak(t testing.TB, server *lsp.Server, opts SoakOptions) StressReport {
+	t.Helper()
+	if opts.MetaModel == "" {
+		t.Fatalf("soak: no SoakOptions.MetaModel to generate requests from")
+	}
+	model, err := metamodel.Load(opts.MetaModel)
+	if err != nil {
+		t.Fatalf("soak: %v", err)
+	}
+	opts.Stress.defaults()
+	methods := opts.Methods
+	if len(methods) == 0 {
+		for _, req := range model.Requests {
+			if req.MessageDirection != metamodel.ServerToClient && req.Method != "initialize" && req.Method != "shutdown" {
+				methods = append(methods, req.Method)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	rnd := &metamodel.Random{Model: model, Rand: rand.New(rand.NewSource(opts.Seed))}
+	stress := opts.Stress
+	stress.Requests = nil
+	for _, method := range methods {
+		if _, ok := rnd.Params(method); !ok {
+			t.Fatalf("soak: no request %q in the metaModel", method)
+		}
+		stress.Requests = append(stress.Requests, StressRequest{
+			Method: method,
+			Params: func(uri protocol.DocumentURI) any {
+				mu.Lock()
+				defer mu.Unlock()
+				params, _ := rnd.Params(method)
+				if obj, ok := params.(map[string]any); ok {
+					if doc, ok := obj["textDocument"].(map[string]any); ok {
+						doc["uri"] = string(uri)
+					}
+				}
+				return params
+			},
+		})
+	}
+	return Stress(t, server, stress)
+}
@@ -0,0 +1,370 @@
+package lsptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pentops/lsplib/framing"
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// StressOptions configures Stress. The zero value is usable.
+type StressOptions struct {
+	// Workers is the number of goroutines firing messages, 8 when zero.
+	Workers int
+
+	// Operations is the number of messages each worker sends, 200 when zero.
+	Operations int
+
+	// Documents are opened before the run and edited during it; four
+	// file:///stress documents when empty.
+	Documents []protocol.DocumentURI
+
+	// Requests are the requests workers choose from; a hover at the start
+	// of a document when empty.
+	Requests []StressRequest
+
+	// Settings builds the didChangeConfiguration payload for the n-th
+	// change, {"iteration": n} when nil.
+	Settings func(n int) any
+
+	// Params are sent with initialize.
+	Params *protocol.InitializeParams
+
+	// Seed makes the sequence of operations reproducible.
+	Seed int64
+
+	// Timeout bounds the whole run, 30s when zero.
+	Timeout time.Duration
+}
+
+// StressRequest builds the params of one kind of request for a document.
+type StressRequest struct {
+	Method string
+	Params func(uri protocol.DocumentURI) any
+}
+
+// StressReport counts what a Stress run sent and received.
+type StressReport struct {
+	Requests      int
+	Responses     int
+	ErrorReplies  int
+	Cancels       int
+	Edits         int
+	ConfigChanges int
+}
+
+// Stress connects to server over raw frames and has concurrent workers fire
+// edits, requests, cancellations and configuration changes at it, then waits
+// for every request to be answered. Run it under -race. The test fails when
+// the server breaks an invariant:
+//
+//   - every response answers a request that was sent, exactly once, even
+//     when the request was cancelled;
+//   - publishDiagnostics versions never go backwards for a document, and
+//     never run ahead of the version the client sent.
+func Stress(t testing.TB, server *lsp.Server, opts StressOptions) StressReport {
+	t.Helper()
+	opts.defaults()
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	serverEnd, clientEnd := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = server.Serve(ctx, serverEnd)
+	}()
+	defer func() {
+		_ = clientEnd.Close()
+		<-served
+	}()
+
+	st := &stress{
+		t:        t,
+		w:        framing.NewHeaderWriter(clientEnd),
+		pending:  map[int64]string{},
+		answered: map[int64]bool{},
+		sent:     map[protocol.DocumentURI]int32{},
+		seen:     map[protocol.DocumentURI]int32{},
+		idle:     make(chan struct{}, 1),
+	}
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		st.read(framing.NewHeaderReader(clientEnd))
+	}()
+
+	params := opts.Params
+	if params == nil {
+		params = &protocol.InitializeParams{}
+	}
+	st.request("initialize", params)
+	if !st.wait(ctx) {
+		t.Fatalf("stress: no initialize response")
+	}
+	st.notify("initialized", &protocol.InitializedParams{})
+	for _, uri := range opts.Documents {
+		st.edit(uri, true)
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < opts.Workers; worker++ {
+		wg.Add(1)
+		go func(rng *rand.Rand) {
+			defer wg.Done()
+			var mine []int64
+			for op := 0; op < opts.Operations && ctx.Err() == nil; op++ {
+				uri := opts.Documents[rng.Intn(len(opts.Documents))]
+				switch n := rng.Intn(10); {
+				case n < 4:
+					st.edit(uri, false)
+				case n < 8:
+					req := opts.Requests[rng.Intn(len(opts.Requests))]
+					mine = append(mine, st.request(req.Method, req.Params(uri)))
+				case n < 9 && len(mine) > 0:
+					st.cancel(mine[rng.Intn(len(mine))])
+				default:
+					st.config(opts.Settings)
+				}
+			}
+		}(rand.New(rand.NewSource(opts.Seed + int64(worker))))
+	}
+	wg.Wait()
+
+	if !st.wait(ctx) {
+		st.mu.Lock()
+		for id, method := range st.pending {
+			t.Errorf("stress: %s request %d was never answered", method, id)
+		}
+		st.mu.Unlock()
+	}
+	st.request("shutdown", nil)
+	st.wait(ctx)
+	st.notify("exit", nil)
+	select {
+	case <-served:
+	case <-ctx.Done():
+		t.Errorf("stress: server still running after exit")
+	}
+	_ = clientEnd.Close()
+	<-readDone
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.report
+}
+
+func (o *StressOptions) defaults() {
+	if o.Workers <= 0 {
+		o.Workers = 8
+	}
+	if o.Operations <= 0 {
+		o.Operations = 200
+	}
+	if len(o.Documents) == 0 {
+		for n := 0; n < 4; n++ {
+			o.Documents = append(o.Documents, protocol.DocumentURI(fmt.Sprintf("file:///stress/%d.txt", n)))
+		}
+	}
+	if len(o.Requests) == 0 {
+		o.Requests = []StressRequest{{
+			Method: "textDocument/hover",
+			Params: func(uri protocol.DocumentURI) any {
+				return &protocol.TextDocumentPositionParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}
+			},
+		}}
+	}
+	if o.Settings == nil {
+		o.Settings = func(n int) any { return map[string]int{"iteration": n} }
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+}
+
+type stress struct {
+	t testing.TB
+
+	writeMu sync.Mutex
+	w       *framing.HeaderWriter
+
+	mu       sync.Mutex
+	seq      int64
+	pending  map[int64]string
+	answered map[int64]bool
+	sent     map[protocol.DocumentURI]int32
+	seen     map[protocol.DocumentURI]int32
+	report   StressReport
+	idle     chan struct{}
+}
+
+type stressMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+func (st *stress) send(msg map[string]any) {
+	msg["jsonrpc"] = "2.0"
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		st.t.Errorf("stress: encoding %v: %v", msg["method"], err)
+		return
+	}
+	st.writeMu.Lock()
+	defer st.writeMu.Unlock()
+	_ = st.w.WriteMessage(raw)
+}
+
+func (st *stress) request(method string, params any) int64 {
+	st.mu.Lock()
+	st.seq++
+	id := st.seq
+	st.pending[id] = method
+	st.report.Requests++
+	st.mu.Unlock()
+	msg := map[string]any{"id": id, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	st.send(msg)
+	return id
+}
+
+func (st *stress) notify(method string, params any) {
+	msg := map[string]any{"method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	st.send(msg)
+}
+
+func (st *stress) cancel(id int64) {
+	st.mu.Lock()
+	st.report.Cancels++
+	st.mu.Unlock()
+	st.notify("$/cancelRequest", map[string]any{"id": id})
+}
+
+func (st *stress) config(settings func(int) any) {
+	st.mu.Lock()
+	st.report.ConfigChanges++
+	n := st.report.ConfigChanges
+	st.mu.Unlock()
+	st.notify("workspace/didChangeConfiguration", map[string]any{"settings": settings(n)})
+}
+
+// edit opens or changes a document. The lock is held while sending so
+// versions reach the server in order.
+func (st *stress) edit(uri protocol.DocumentURI, open bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sent[uri]++
+	version := st.sent[uri]
+	text := fmt.Sprintf("version %d of %s\n", version, uri)
+	if open {
+		st.notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": "plaintext", "version": version, "text": text},
+		})
+		return
+	}
+	st.report.Edits++
+	st.notify("textDocument/didChange", map[string]any{
+		"textDocument":   protocol.VersionedTextDocumentIdentifier{URI: uri, Version: version},
+		"contentChanges": []map[string]string{{"text": text}},
+	})
+}
+
+// wait blocks until no request is pending, reporting false on timeout.
+func (st *stress) wait(ctx context.Context) bool {
+	for {
+		st.mu.Lock()
+		n := len(st.pending)
+		st.mu.Unlock()
+		if n == 0 {
+			return true
+		}
+		select {
+		case <-st.idle:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (st *stress) read(r *framing.HeaderReader) {
+	for {
+		raw, err := r.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg stressMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			st.t.Errorf("stress: server sent invalid JSON: %s", raw)
+			continue
+		}
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			st.send(map[string]any{"id": msg.ID, "result": nil})
+		case msg.Method == "textDocument/publishDiagnostics":
+			st.checkDiagnostics(msg.Params)
+		case msg.Method == "":
+			st.checkResponse(msg)
+		}
+	}
+}
+
+func (st *stress) checkResponse(msg stressMessage) {
+	var id int64
+	if err := json.Unmarshal(msg.ID, &id); err != nil {
+		st.t.Errorf("stress: response with unexpected id %s", msg.ID)
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.answered[id] {
+		st.t.Errorf("stress: second response to request %d", id)
+		return
+	}
+	if _, ok := st.pending[id]; !ok {
+		st.t.Errorf("stress: response to request %d, which was never sent", id)
+		return
+	}
+	delete(st.pending, id)
+	st.answered[id] = true
+	st.report.Responses++
+	if msg.Error != nil {
+		st.report.ErrorReplies++
+	}
+	select {
+	case st.idle <- struct{}{}:
+	default:
+	}
+}
+
+func (st *stress) checkDiagnostics(raw json.RawMessage) {
+	var params protocol.PublishDiagnosticsParams
+	if json.Unmarshal(raw, &params) != nil || params.Version == nil {
+		return
+	}
+	version := *params.Version
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if version < st.seen[params.URI] {
+		st.t.Errorf("stress: diagnostics for %s went back from version %d to %d", params.URI, st.seen[params.URI], version)
+	}
+	if version > st.sent[params.URI] {
+		st.t.Errorf("stress: diagnostics for %s at version %d, the client only sent %d", params.URI, version, st.sent[params.URI])
+	}
+	st.seen[params.URI] = version
+}
@@ -0,0 +1,182 @@
+// Package markup writes documentation in the form the client renders. The
+// Formats of a client, read from its capabilities at initialize, give the
+// markup kind to write hovers, completion documentation and signatures in;
+// a Builder writes text and code in that kind, escaping text and fencing
+// code in markdown, and a Snippet writes completion insert text, escaping
+// it and numbering placeholders when the client supports snippets and
+// leaving placeholders' text plain when it doesn't.
+package markup
+
+import (
+	"strings"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Formats are the markup kinds a client renders, by feature, and whether
+// it supports snippets in completion insert text.
+type Formats struct {
+	Hover         protocol.MarkupKind
+	Documentation protocol.MarkupKind
+	Signature     protocol.MarkupKind
+	Snippets      bool
+}
+
+// ForClient returns the Formats caps declares, plaintext where it declares
+// none. caps may be nil, as lsp.Server.ClientCapabilities is before
+// initialize.
+func ForClient(caps *protocol.ClientCapabilities) Formats {
+	f := Formats{Hover: protocol.PlainText, Documentation: protocol.PlainText, Signature: protocol.PlainText}
+	if caps == nil || caps.TextDocument == nil {
+		return f
+	}
+	td := caps.TextDocument
+	if td.Hover != nil {
+		f.Hover = Preferred(td.Hover.ContentFormat)
+	}
+	if td.Completion != nil && td.Completion.CompletionItem != nil {
+		f.Documentation = Preferred(td.Completion.CompletionItem.DocumentationFormat)
+		f.Snippets = td.Completion.CompletionItem.SnippetSupport
+	}
+	if td.SignatureHelp != nil && td.SignatureHelp.SignatureInformation != nil {
+		f.Signature = Preferred(td.SignatureHelp.SignatureInformation.DocumentationFormat)
+	}
+	return f
+}
+
+// Preferred returns the first kind of kinds, a client's preferences, which
+// a Builder writes, and plaintext when there is none.
+func Preferred(kinds []protocol.MarkupKind) protocol.MarkupKind {
+	for _, kind := range kinds {
+		if kind == protocol.Markdown || kind == protocol.PlainText {
+			return kind
+		}
+	}
+	return protocol.PlainText
+}
+
+// Builder writes MarkupContent of one kind as a series of paragraphs and
+// code blocks. The zero value writes plaintext.
+type Builder struct {
+	Kind protocol.MarkupKind
+
+	buf strings.Builder
+	// blank is set when the next text starts a paragraph of its own.
+	blank bool
+}
+
+// New returns a Builder writing kind.
+func New(kind protocol.MarkupKind) *Builder {
+	return &Builder{Kind: kind}
+}
+
+// Text writes text as it should read, escaping characters markdown would
+// interpret, continuing the current paragraph.
+func (b *Builder) Text(text string) {
+	b.start()
+	if b.Kind == protocol.Markdown {
+		text = Escape(text)
+	}
+	b.buf.WriteString(text)
+}
+
+// Markdown writes text which is already markdown, continuing the current
+// paragraph. Plaintext gets it as it is.
+func (b *Builder) Markdown(text string) {
+	b.start()
+	b.buf.WriteString(text)
+}
+
+// Paragraph ends the current paragraph.
+func (b *Builder) Paragraph() {
+	if b.buf.Len() > 0 {
+		b.blank = true
+	}
+}
+
+// Code writes code as a paragraph of its own, fenced and tagged with
+// language in markdown, so it is highlighted.
+func (b *Builder) Code(language, code string) {
+	b.Paragraph()
+	b.start()
+	code = strings.TrimSuffix(code, "\n")
+	if b.Kind == protocol.Markdown {
+		fence := strings.Repeat("`", max(3, longestRun(code, '`')+1))
+		b.buf.WriteString(fence + language + "\n" + code + "\n" + fence)
+	} else {
+		b.buf.WriteString(code)
+	}
+	b.Paragraph()
+}
+
+// String is the content written so far.
+func (b *Builder) String() string {
+	return b.buf.String()
+}
+
+// Content is the MarkupContent written.
+func (b *Builder) Content() protocol.MarkupContent {
+	kind := b.Kind
+	if kind == "" {
+		kind = protocol.PlainText
+	}
+	return protocol.MarkupContent{Kind: kind, Value: b.buf.String()}
+}
+
+func (b *Builder) start() {
+	if b.blank {
+		b.buf.WriteString("\n\n")
+		b.blank = false
+	}
+}
+
+// Escape escapes the characters of text which markdown would interpret,
+// including those which start a list, heading or quote at a line's start,
+// so it renders as it reads.
+func Escape(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	lineStart := true
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case strings.IndexByte("\\`*_[]<>&#|~", c) >= 0:
+			b.WriteByte('\\')
+		case lineStart && (c == '-' || c == '+' || c == '='):
+			b.WriteByte('\\')
+		case lineStart && c >= '0' && c <= '9':
+			// An ordered list item: digits, then "." or ")".
+			j := i
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			b.WriteString(text[i:j])
+			if j < len(text) && (text[j] == '.' || text[j] == ')') {
+				b.WriteByte('\\')
+			}
+			i = j - 1
+			lineStart = false
+			continue
+		}
+		b.WriteByte(c)
+		if c == '\n' {
+			lineStart = true
+		} else if c != ' ' && c != '\t' {
+			lineStart = false
+		}
+	}
+	return b.String()
+}
+
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
@@ -0,0 +1,69 @@
+package markup
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Snippet writes completion insert text with tab stops, such as a call
+// with a placeholder for each argument. The zero value is ready to use.
+type Snippet struct {
+	snippet, plain strings.Builder
+	stops          int
+}
+
+// Text writes text to be inserted as it is.
+func (s *Snippet) Text(text string) {
+	s.snippet.WriteString(EscapeSnippet(text))
+	s.plain.WriteString(text)
+}
+
+// Placeholder writes a tab stop with text selected in it, numbered after
+// the stops before it. Without snippet support the text is inserted
+// plain.
+func (s *Snippet) Placeholder(text string) {
+	s.stops++
+	s.snippet.WriteString("${" + strconv.Itoa(s.stops) + ":" + EscapeSnippet(text) + "}")
+	s.plain.WriteString(text)
+}
+
+// Final writes the tab stop the cursor ends at, $0.
+func (s *Snippet) Final() {
+	s.snippet.WriteString("$0")
+}
+
+// String is the snippet, in snippet syntax.
+func (s *Snippet) String() string {
+	return s.snippet.String()
+}
+
+// Plain is the text inserted without snippet support.
+func (s *Snippet) Plain() string {
+	return s.plain.String()
+}
+
+// Apply sets item's insert text, or the text of its TextEdit when it has
+// one, to the snippet, or to the plain text when snippets is false, as
+// Formats.Snippets says of the client.
+func (s *Snippet) Apply(item *protocol.CompletionItem, snippets bool) {
+	text, format := s.Plain(), protocol.PlainTextFormat
+	if snippets {
+		text, format = s.String(), protocol.SnippetFormat
+	}
+	if item.TextEdit != nil {
+		item.TextEdit.NewText = text
+	} else {
+		item.InsertText = text
+	}
+	item.InsertTextFormat = format
+}
+
+// EscapeSnippet escapes text for snippet syntax, so it is inserted as it
+// is rather than read as tab stops or variables.
+func EscapeSnippet(text string) string {
+	return snippetEscaper.Replace(text)
+}
+
+var snippetEscaper = strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`)
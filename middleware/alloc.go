@@ -0,0 +1,159 @@
+// Package middleware contains optional jsonrpc2.Middleware for language
+// servers: diagnostics for server authors, resilience and policy hooks.
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// AllocStats is the measured cost of one request.
+type AllocStats struct {
+	Method   string
+	Duration time.Duration
+
+	// Bytes and Objects allocated while the handler ran.
+	Bytes   uint64
+	Objects uint64
+
+	// HeapDelta is the change in live heap across the handler. It is
+	// negative when a collection ran in between.
+	HeapDelta int64
+
+	// Concurrent is the number of other measured handlers in flight at some
+	// point during this one. The runtime counters are process-wide, so a
+	// non-zero value means the numbers include their allocations too.
+	Concurrent int
+}
+
+// AllocOptions configures Allocations.
+type AllocOptions struct {
+	// SampleEvery measures one in every N messages; 0 or 1 measures all.
+	SampleEvery int
+
+	// Threshold, when non-zero, only logs requests allocating more bytes.
+	Threshold uint64
+
+	// Keep is the number of worst offenders retained, 10 when zero.
+	Keep int
+
+	// Logger receives a warning for each request over Threshold. Nil
+	// disables logging.
+	Logger *slog.Logger
+
+	// Observe, when set, receives every sample, e.g. to feed a histogram.
+	Observe func(AllocStats)
+}
+
+// AllocTracker retains the worst offenders seen by an Allocations middleware.
+type AllocTracker struct {
+	mu    sync.Mutex
+	worst []AllocStats
+	keep  int
+}
+
+// Worst returns the retained samples, largest allocation first.
+func (t *AllocTracker) Worst() []AllocStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]AllocStats(nil), t.worst...)
+}
+
+func (t *AllocTracker) record(stats AllocStats) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.worst) >= t.keep && stats.Bytes <= t.worst[len(t.worst)-1].Bytes {
+		return
+	}
+	t.worst = append(t.worst, stats)
+	sort.Slice(t.worst, func(i, j int) bool { return t.worst[i].Bytes > t.worst[j].Bytes })
+	if len(t.worst) > t.keep {
+		t.worst = t.worst[:t.keep]
+	}
+}
+
+var allocMetrics = []string{
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/allocs:objects",
+	"/memory/classes/heap/objects:bytes",
+}
+
+type allocSnapshot struct {
+	bytes, objects, heap uint64
+}
+
+func readAllocs() allocSnapshot {
+	samples := make([]metrics.Sample, len(allocMetrics))
+	for idx, name := range allocMetrics {
+		samples[idx].Name = name
+	}
+	metrics.Read(samples)
+	var snap allocSnapshot
+	values := []*uint64{&snap.bytes, &snap.objects, &snap.heap}
+	for idx, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			*values[idx] = sample.Value.Uint64()
+		}
+	}
+	return snap
+}
+
+// Allocations measures the memory allocated by each handler using runtime
+// metrics. It is meant for finding pathological handlers during development
+// or in a canary, not for precise accounting: the counters are process-wide.
+func Allocations(opts AllocOptions) (jsonrpc2.Middleware, *AllocTracker) {
+	keep := opts.Keep
+	if keep <= 0 {
+		keep = 10
+	}
+	tracker := &AllocTracker{keep: keep}
+	var counter atomic.Uint64
+	var inFlight atomic.Int64
+
+	mw := func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			if opts.SampleEvery > 1 && counter.Add(1)%uint64(opts.SampleEvery) != 0 {
+				return next.Handle(ctx, req)
+			}
+
+			peers := int(inFlight.Add(1) - 1)
+			start := time.Now()
+			before := readAllocs()
+			result, err := next.Handle(ctx, req)
+			after := readAllocs()
+			peers = max(peers, int(inFlight.Add(-1)))
+
+			stats := AllocStats{
+				Method:     req.Method,
+				Duration:   time.Since(start),
+				Bytes:      after.bytes - before.bytes,
+				Objects:    after.objects - before.objects,
+				HeapDelta:  int64(after.heap) - int64(before.heap),
+				Concurrent: peers,
+			}
+			tracker.record(stats)
+			if opts.Observe != nil {
+				opts.Observe(stats)
+			}
+			if opts.Logger != nil && stats.Bytes > opts.Threshold {
+				opts.Logger.WarnContext(ctx, "request allocations",
+					slog.String("method", stats.Method),
+					slog.Uint64("bytes", stats.Bytes),
+					slog.Uint64("objects", stats.Objects),
+					slog.Int64("heapDelta", stats.HeapDelta),
+					slog.Duration("duration", stats.Duration),
+					slog.Int("concurrent", stats.Concurrent),
+				)
+			}
+			return result, err
+		})
+	}
+	return mw, tracker
+}
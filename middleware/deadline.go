@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// DeadlineOptions configures Deadline.
+type DeadlineOptions struct {
+	// Default bounds every request not in Methods; zero leaves them
+	// unbounded.
+	Default time.Duration
+
+	// Methods bounds requests by method, such as 500ms for
+	// textDocument/completion.
+	Methods map[string]time.Duration
+}
+
+// Deadline gives each request's context a deadline, which handlers may
+// answer before with what they have, as lsp.BestEffort does, rather than
+// keep the user waiting. Notifications are left unbounded.
+func Deadline(opts DeadlineOptions) jsonrpc2.Middleware {
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			limit, ok := opts.Methods[req.Method]
+			if !ok {
+				limit = opts.Default
+			}
+			if req.IsNotification() || limit <= 0 {
+				return next.Handle(ctx, req)
+			}
+			ctx, cancel := context.WithTimeout(ctx, limit)
+			defer cancel()
+			return next.Handle(ctx, req)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+)
+
+// FaultKind is a kind of injected failure.
+type FaultKind int
+
+const (
+	// FaultDelay holds a message back before handling it.
+	FaultDelay FaultKind = iota
+	// FaultDrop discards a notification unhandled.
+	FaultDrop
+	// FaultMalformed replaces a request's result with a value of the wrong
+	// shape.
+	FaultMalformed
+	// FaultError fails a request with CodeInternalError.
+	FaultError
+	// FaultCancel cancels a request's context while it is being handled.
+	FaultCancel
+)
+
+func (k FaultKind) String() string {
+	switch k {
+	case FaultDelay:
+		return "delay"
+	case FaultDrop:
+		return "drop"
+	case FaultMalformed:
+		return "malformed"
+	case FaultError:
+		return "error"
+	default:
+		return "cancel"
+	}
+}
+
+// Fault is one injected failure.
+type Fault struct {
+	Method string
+	Kind   FaultKind
+
+	// Delay is how long a FaultDelay held the message, or how far into the
+	// handler a FaultCancel struck.
+	Delay time.Duration
+}
+
+// FaultOptions configures Faults. Rates are probabilities from 0 to 1,
+// drawn independently for each message.
+type FaultOptions struct {
+	// Seed makes the faults reproducible; the time is used when zero.
+	Seed int64
+
+	// Methods limits injection to these methods; all when empty. The
+	// lifecycle methods are exempt unless listed here.
+	Methods []string
+
+	// DelayRate delays messages by up to MaxDelay, 1s when zero. MaxDelay
+	// also bounds how long a cancelled request runs first.
+	DelayRate float64
+	MaxDelay  time.Duration
+
+	// DropRate discards notifications.
+	DropRate float64
+
+	// MalformedRate and ErrorRate corrupt or fail request results.
+	MalformedRate float64
+	ErrorRate     float64
+
+	// CancelRate cancels requests while their handler runs.
+	CancelRate float64
+
+	// Observe, when set, is told about each fault as it is injected.
+	Observe func(Fault)
+
+	// Clock times delays, clock.Real when nil.
+	Clock clock.Clock
+}
+
+// lifecycle methods are left alone by default: failing them only tests that
+// a session which never started doesn't work.
+var lifecycle = map[string]bool{
+	"initialize":  true,
+	"initialized": true,
+	"shutdown":    true,
+	"exit":        true,
+}
+
+func (o *FaultOptions) applies(method string) bool {
+	if len(o.Methods) == 0 {
+		return !lifecycle[method]
+	}
+	for _, m := range o.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *FaultOptions) maxDelay() time.Duration {
+	if o.MaxDelay > 0 {
+		return o.MaxDelay
+	}
+	return time.Second
+}
@@ -0,0 +1,15 @@
+//go:build !lspfaults
+
+package middleware
+
+import "github.com/pentops/lsplib/jsonrpc2"
+
+// FaultsEnabled reports whether the build injects faults.
+const FaultsEnabled = false
+
+// Faults injects failures into the messages it wraps, for testing how
+// editors and the server recover. It is only active in builds with the
+// lspfaults tag; elsewhere it passes every message straight through.
+func Faults(opts FaultOptions) jsonrpc2.Middleware {
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler { return next }
+}
@@ -0,0 +1,95 @@
+//go:build lspfaults
+
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// FaultsEnabled reports whether the build injects faults.
+const FaultsEnabled = true
+
+// malformed is what FaultMalformed answers with: valid JSON no LSP result
+// has the shape of.
+var malformed = json.RawMessage(`{"lsplibFault":["malformed",1,true]}`)
+
+// Faults injects failures into the messages it wraps, for testing how
+// editors and the server recover. It is only active in builds with the
+// lspfaults tag; elsewhere it passes every message straight through.
+func Faults(opts FaultOptions) jsonrpc2.Middleware {
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	var mu sync.Mutex
+	rng := rand.New(rand.NewSource(seed))
+	roll := func(rate float64) bool {
+		if rate <= 0 {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return rng.Float64() < rate
+	}
+	within := func(max time.Duration) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Duration(rng.Int63n(int64(max) + 1))
+	}
+	observe := func(f Fault) {
+		if opts.Observe != nil {
+			opts.Observe(f)
+		}
+	}
+	c := clock.Or(opts.Clock)
+
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			if !opts.applies(req.Method) {
+				return next.Handle(ctx, req)
+			}
+			if req.IsNotification() && roll(opts.DropRate) {
+				observe(Fault{Method: req.Method, Kind: FaultDrop})
+				return nil, nil
+			}
+			if roll(opts.DelayRate) {
+				d := within(opts.maxDelay())
+				observe(Fault{Method: req.Method, Kind: FaultDelay, Delay: d})
+				if err := clock.Sleep(ctx, c, d); err != nil {
+					return nil, err
+				}
+			}
+			if req.IsNotification() {
+				return next.Handle(ctx, req)
+			}
+
+			if roll(opts.CancelRate) {
+				d := within(opts.maxDelay())
+				observe(Fault{Method: req.Method, Kind: FaultCancel, Delay: d})
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithCancel(ctx)
+				defer cancel()
+				timer := c.AfterFunc(d, cancel)
+				defer timer.Stop()
+			}
+			result, err := next.Handle(ctx, req)
+			switch {
+			case err != nil:
+			case roll(opts.ErrorRate):
+				observe(Fault{Method: req.Method, Kind: FaultError})
+				return nil, jsonrpc2.NewError(jsonrpc2.CodeInternalError, "injected fault")
+			case roll(opts.MalformedRate):
+				observe(Fault{Method: req.Method, Kind: FaultMalformed})
+				return malformed, nil
+			}
+			return result, err
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// Call describes one handled message.
+type Call struct {
+	Method       string
+	Notification bool
+	Duration     time.Duration
+	Err          error
+}
+
+// LogOptions configures Log.
+type LogOptions struct {
+	// Level is the level successful messages are logged at; failures are
+	// logged as warnings.
+	Level slog.Level
+
+	// Params includes each message's params, which can hold whole
+	// documents.
+	Params bool
+}
+
+// Log logs each message after it is handled, with its method, id, duration
+// and any error. $/ notifications such as progress and cancellation are
+// logged only when they fail.
+func Log(logger *slog.Logger, opts LogOptions) jsonrpc2.Middleware {
+	return Timing(func(ctx context.Context, req *jsonrpc2.Request, call Call) {
+		if call.Err == nil && call.Notification && strings.HasPrefix(call.Method, "$/") {
+			return
+		}
+		attrs := []slog.Attr{slog.String("method", call.Method), slog.Duration("duration", call.Duration)}
+		if !call.Notification {
+			attrs = append(attrs, slog.String("id", string(req.ID)))
+		}
+		if opts.Params {
+			attrs = append(attrs, slog.String("params", string(req.Params)))
+		}
+		msg, level := "handled request", opts.Level
+		if call.Notification {
+			msg = "handled notification"
+		}
+		if call.Err != nil {
+			attrs = append(attrs, slog.String("error", call.Err.Error()))
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(ctx, level, msg, attrs...)
+	})
+}
+
+// Timing measures each handled message and passes it to observe, e.g. to
+// feed a latency histogram by method.
+func Timing(observe func(ctx context.Context, req *jsonrpc2.Request, call Call)) jsonrpc2.Middleware {
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			start := time.Now()
+			result, err := next.Handle(ctx, req)
+			observe(ctx, req, Call{
+				Method:       req.Method,
+				Notification: req.IsNotification(),
+				Duration:     time.Since(start),
+				Err:          err,
+			})
+			return result, err
+		})
+	}
+}
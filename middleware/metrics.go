@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// MetricsMethod is the custom request a Metrics middleware answers with a
+// MetricsSnapshot, for editor extensions showing the server's health.
+const MetricsMethod = "lsplib/metrics"
+
+// MetricsSnapshot is the session's counters at one moment.
+type MetricsSnapshot struct {
+	UptimeSeconds float64 `json:"uptimeSeconds"`
+
+	// Requests, Notifications and Errors count the messages handled so
+	// far, Errors those requests which failed, cancelled ones included.
+	Requests      uint64 `json:"requests"`
+	Notifications uint64 `json:"notifications"`
+	Errors        uint64 `json:"errors"`
+	Cancelled     uint64 `json:"cancelled"`
+	InFlight      int64  `json:"inFlight"`
+
+	Methods map[string]MethodMetrics `json:"methods"`
+	Caches  map[string]CacheMetrics  `json:"caches"`
+	Memory  MemoryMetrics            `json:"memory"`
+}
+
+// MethodMetrics counts the messages of one method.
+type MethodMetrics struct {
+	Count  uint64 `json:"count"`
+	Errors uint64 `json:"errors"`
+
+	// TotalMillis and MaxMillis are the time spent in the handler.
+	TotalMillis float64 `json:"totalMillis"`
+	MaxMillis   float64 `json:"maxMillis"`
+}
+
+// CacheMetrics is one cache's effectiveness.
+type CacheMetrics struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+}
+
+// MemoryMetrics is the process's memory use, which other sessions in the
+// process share.
+type MemoryMetrics struct {
+	HeapBytes  uint64 `json:"heapBytes"`
+	TotalBytes uint64 `json:"totalBytes"`
+	GCCycles   uint64 `json:"gcCycles"`
+	Goroutines int    `json:"goroutines"`
+}
+
+// MetricsTracker holds the counters of a Metrics middleware. It is safe for
+// concurrent use.
+type MetricsTracker struct {
+	start time.Time
+
+	requests, notifications, errors, cancelled atomic.Uint64
+	inFlight                                   atomic.Int64
+
+	mu      sync.Mutex
+	methods map[string]*MethodMetrics
+	caches  map[string]*CacheCounter
+}
+
+// CacheCounter counts the hits and misses of one cache for a
+// MetricsTracker. It is safe for concurrent use.
+type CacheCounter struct {
+	hits, misses atomic.Uint64
+}
+
+// Record counts one lookup.
+func (c *CacheCounter) Record(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// Cache returns the counter reported under name, creating it on first use.
+func (t *MetricsTracker) Cache(name string) *CacheCounter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.caches[name]
+	if !ok {
+		c = &CacheCounter{}
+		t.caches[name] = c
+	}
+	return c
+}
+
+// Snapshot returns the counters as they are now.
+func (t *MetricsTracker) Snapshot() MetricsSnapshot {
+	snap := MetricsSnapshot{
+		UptimeSeconds: time.Since(t.start).Seconds(),
+		Requests:      t.requests.Load(),
+		Notifications: t.notifications.Load(),
+		Errors:        t.errors.Load(),
+		Cancelled:     t.cancelled.Load(),
+		InFlight:      t.inFlight.Load(),
+		Methods:       map[string]MethodMetrics{},
+		Caches:        map[string]CacheMetrics{},
+		Memory:        readMemory(),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for method, m := range t.methods {
+		snap.Methods[method] = *m
+	}
+	for name, c := range t.caches {
+		stats := CacheMetrics{Hits: c.hits.Load(), Misses: c.misses.Load()}
+		if total := stats.Hits + stats.Misses; total > 0 {
+			stats.HitRate = float64(stats.Hits) / float64(total)
+		}
+		snap.Caches[name] = stats
+	}
+	return snap
+}
+
+func (t *MetricsTracker) record(method string, d time.Duration, failed bool) {
+	millis := float64(d) / float64(time.Millisecond)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m, ok := t.methods[method]
+	if !ok {
+		m = &MethodMetrics{}
+		t.methods[method] = m
+	}
+	m.Count++
+	if failed {
+		m.Errors++
+	}
+	m.TotalMillis += millis
+	m.MaxMillis = max(m.MaxMillis, millis)
+}
+
+var memoryMetrics = []string{
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/total:bytes",
+	"/gc/cycles/total:gc-cycles",
+}
+
+func readMemory() MemoryMetrics {
+	samples := make([]metrics.Sample, len(memoryMetrics))
+	for idx, name := range memoryMetrics {
+		samples[idx].Name = name
+	}
+	metrics.Read(samples)
+	mem := MemoryMetrics{Goroutines: runtime.NumGoroutine()}
+	values := []*uint64{&mem.HeapBytes, &mem.TotalBytes, &mem.GCCycles}
+	for idx, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			*values[idx] = sample.Value.Uint64()
+		}
+	}
+	return mem
+}
+
+// Metrics counts the messages of a session, by method, and answers
+// MetricsMethod with a snapshot of them, the caches counted through
+// MetricsTracker.Cache and the process's memory, so an extension can show
+// a health panel without a metrics pipeline. MetricsMethod requests are
+// answered whatever the lifecycle state and aren't counted.
+func Metrics() (jsonrpc2.Middleware, *MetricsTracker) {
+	tracker := &MetricsTracker{
+		start:   time.Now(),
+		methods: map[string]*MethodMetrics{},
+		caches:  map[string]*CacheCounter{},
+	}
+	mw := func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			if req.Method == MetricsMethod && !req.IsNotification() {
+				return tracker.Snapshot(), nil
+			}
+			if req.IsNotification() {
+				tracker.notifications.Add(1)
+			} else {
+				tracker.requests.Add(1)
+			}
+			tracker.inFlight.Add(1)
+			start := time.Now()
+			result, err := next.Handle(ctx, req)
+			elapsed := time.Since(start)
+			tracker.inFlight.Add(-1)
+
+			failed := err != nil && !req.IsNotification()
+			if failed {
+				tracker.errors.Add(1)
+				if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+					tracker.cancelled.Add(1)
+				}
+			}
+			tracker.record(req.Method, elapsed, failed)
+			return result, err
+		})
+	}
+	return mw, tracker
+}
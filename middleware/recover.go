@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// RecoverOptions configures Recover.
+type RecoverOptions struct {
+	// Logger receives an error with the stack of each panic. Nil disables
+	// logging.
+	Logger *slog.Logger
+
+	// OnPanic, when set, is told about each panic, e.g. to report it.
+	OnPanic func(ctx context.Context, method string, value any, stack []byte)
+}
+
+// Recover turns a handler's panic into a CodeInternalError reply, or drops
+// the notification, so one bad handler doesn't take the server down with
+// every open editor. List it first in Options.Middleware to cover the
+// others too.
+func Recover(opts RecoverOptions) jsonrpc2.Middleware {
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (result any, err error) {
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				stack := debug.Stack()
+				if opts.Logger != nil {
+					opts.Logger.ErrorContext(ctx, "handler panicked",
+						slog.String("method", req.Method),
+						slog.Any("panic", value),
+						slog.String("stack", string(stack)),
+					)
+				}
+				if opts.OnPanic != nil {
+					opts.OnPanic(ctx, req.Method, value, stack)
+				}
+				result, err = nil, jsonrpc2.NewError(jsonrpc2.CodeInternalError, "%s: internal error", req.Method)
+			}()
+			return next.Handle(ctx, req)
+		})
+	}
+}
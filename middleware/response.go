@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// Response is a handler's reply to a request as it will be sent: its result
+// encoded, or its error as the client will receive it. Exactly one of
+// Result and Err is set.
+type Response struct {
+	Result json.RawMessage
+	Err    *jsonrpc2.Error
+}
+
+// Inspect hands fn each request's response once the handler returns, to
+// observe, such as the size of results by method, or to change, such as
+// trimming a result too large for the client: what fn leaves in resp is
+// what is sent. Setting Err replaces the result with that error, and
+// clearing it sends Result instead. Notifications pass through.
+func Inspect(fn func(ctx context.Context, req *jsonrpc2.Request, resp *Response)) jsonrpc2.Middleware {
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			result, err := next.Handle(ctx, req)
+			if req.IsNotification() {
+				return result, err
+			}
+			resp := response(ctx, result, err)
+			fn(ctx, req, resp)
+			if resp.Err != nil {
+				return nil, resp.Err
+			}
+			if resp.Result == nil {
+				return nil, nil
+			}
+			return resp.Result, nil
+		})
+	}
+}
+
+// response encodes a handler's reply as the connection would.
+func response(ctx context.Context, result any, err error) *Response {
+	if err != nil {
+		var rpcErr *jsonrpc2.Error
+		switch {
+		case errors.As(err, &rpcErr):
+		case errors.Is(context.Cause(ctx), jsonrpc2.ErrCancelled):
+			rpcErr = jsonrpc2.NewError(jsonrpc2.CodeRequestCancelled, "request cancelled")
+		default:
+			rpcErr = &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: err.Error()}
+		}
+		return &Response{Err: rpcErr}
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return &Response{Err: jsonrpc2.NewError(jsonrpc2.CodeInternalError, "encoding result: %v", err)}
+	}
+	return &Response{Result: raw}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/internal/glob"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Scope selects the messages a Scoped middleware applies to by the document
+// they concern.
+type Scope struct {
+	// Selector denotes the documents in scope.
+	Selector protocol.DocumentSelector
+
+	// Language returns the language id of an open document, for filters
+	// with a Language, as a textsync.Store records it. Without it only
+	// textDocument/didOpen, which carries its language id, matches them.
+	Language func(uri protocol.DocumentURI) (string, bool)
+
+	// Case is the case sensitivity Pattern filters match paths with.
+	Case fscase.Sensitivity
+}
+
+// Scoped applies mw only to messages about a document the scope's selector
+// matches: those whose params name a textDocument.uri. Every other message,
+// including those about no document at all, goes straight to the next
+// handler. A handler for documents in scope alone is a middleware which
+// doesn't call next. An invalid Pattern is an error.
+func Scoped(scope Scope, mw ...jsonrpc2.Middleware) (jsonrpc2.Middleware, error) {
+	patterns := make([]*glob.Pattern, len(scope.Selector))
+	for idx, f := range scope.Selector {
+		if f.Pattern == nil {
+			continue
+		}
+		p, err := glob.New(*f.Pattern, scope.Case)
+		if err != nil {
+			return nil, fmt.Errorf("middleware: document filter pattern %q: %w", f.Pattern.Pattern, err)
+		}
+		patterns[idx] = p
+	}
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		scoped := jsonrpc2.Chain(next, mw...)
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			var params struct {
+				TextDocument struct {
+					URI        protocol.DocumentURI `json:"uri"`
+					LanguageID string               `json:"languageId"`
+				} `json:"textDocument"`
+			}
+			if json.Unmarshal(req.Params, &params) != nil || params.TextDocument.URI == "" {
+				return next.Handle(ctx, req)
+			}
+			doc := params.TextDocument
+			if matches(scope, patterns, doc.URI, doc.LanguageID) {
+				return scoped.Handle(ctx, req)
+			}
+			return next.Handle(ctx, req)
+		})
+	}, nil
+}
+
+// matches reports whether any filter matches the document at uri, whose
+// language is looked up when not known.
+func matches(scope Scope, patterns []*glob.Pattern, uri protocol.DocumentURI, language string) bool {
+	var scheme string
+	if u, err := url.Parse(string(uri)); err == nil {
+		scheme = u.Scheme
+	}
+	path := glob.Path(string(uri))
+	for idx, f := range scope.Selector {
+		if f.Scheme != "" && f.Scheme != scheme {
+			continue
+		}
+		if patterns[idx] != nil && !patterns[idx].Match(path) {
+			continue
+		}
+		if f.Language != "" {
+			if language == "" && scope.Language != nil {
+				language, _ = scope.Language(uri)
+			}
+			if f.Language != language {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
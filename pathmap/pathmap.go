@@ -0,0 +1,161 @@
+// Package pathmap translates file paths and file URIs between the client's
+// file system and the server's, for servers which see the workspace at a
+// different location: inside a container, or on another host.
+//
+// Translation is textual and applies to every JSON string in a message, map
+// keys included, so URIs in params, results, WorkspaceEdit keys and paths
+// quoted in diagnostic messages are all covered in one place. Either end of
+// the connection can translate: a client with Stream or ClientRewrite, a
+// server with ServerRewrite or lsp.Options.PathMap.
+package pathmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Prefix pairs a directory as the client sees it with the same directory as
+// the server sees it. Both are absolute, slash-separated paths.
+type Prefix struct {
+	Client string
+	Server string
+}
+
+// Map translates between the two sides of a set of prefixes.
+type Map struct {
+	toServer []form
+	toClient []form
+}
+
+// form is one spelling of a prefix to find, and its replacement.
+type form struct {
+	from, to string
+}
+
+// New builds a map. Where prefixes nest, the longest match wins.
+func New(prefixes ...Prefix) *Map {
+	m := &Map{}
+	for _, p := range prefixes {
+		client, server := clean(p.Client), clean(p.Server)
+		m.toServer = append(m.toServer, form{fileURI(client), fileURI(server)}, form{client, server})
+		m.toClient = append(m.toClient, form{fileURI(server), fileURI(client)}, form{server, client})
+	}
+	for _, forms := range [][]form{m.toServer, m.toClient} {
+		sort.SliceStable(forms, func(i, j int) bool { return len(forms[i].from) > len(forms[j].from) })
+	}
+	return m
+}
+
+func clean(path string) string {
+	path = strings.ReplaceAll(path, "\\", "/")
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// ToServer rewrites client paths and URIs in s to the server's.
+func (m *Map) ToServer(s string) string {
+	return replace(s, m.toServer)
+}
+
+// ToClient rewrites server paths and URIs in s to the client's.
+func (m *Map) ToClient(s string) string {
+	return replace(s, m.toClient)
+}
+
+// replace rewrites every occurrence of a form's prefix which sits on path
+// boundaries: not inside a longer name on either side.
+func replace(s string, forms []form) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '/' && s[i] != 'f' || i > 0 && isNameByte(s[i-1]) {
+			continue
+		}
+		for _, f := range forms {
+			end := i + len(f.from)
+			if !strings.HasPrefix(s[i:], f.from) || end < len(s) && isNameByte(s[end]) {
+				continue
+			}
+			b.WriteString(s[last:i])
+			b.WriteString(f.to)
+			last = end
+			i = end - 1
+			break
+		}
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func isNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-' || c == '.' || c == '%'
+}
+
+// ToServerJSON rewrites every string in a JSON message for the server.
+func (m *Map) ToServerJSON(raw []byte) ([]byte, error) {
+	return rewrite(raw, m.toServer)
+}
+
+// ToClientJSON rewrites every string in a JSON message for the client.
+func (m *Map) ToClientJSON(raw []byte) ([]byte, error) {
+	return rewrite(raw, m.toClient)
+}
+
+func rewrite(raw []byte, forms []form) ([]byte, error) {
+	found := false
+	for _, f := range forms {
+		if bytes.Contains(raw, []byte(f.from)) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return raw, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return raw, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(walk(value, forms)); err != nil {
+		return raw, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func walk(value any, forms []form) any {
+	switch value := value.(type) {
+	case string:
+		return replace(value, forms)
+	case []any:
+		for idx, item := range value {
+			value[idx] = walk(item, forms)
+		}
+	case map[string]any:
+		out := make(map[string]any, len(value))
+		for key, item := range value {
+			out[replace(key, forms)] = walk(item, forms)
+		}
+		return out
+	}
+	return value
+}
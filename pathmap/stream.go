@@ -0,0 +1,77 @@
+package pathmap
+
+import (
+	"errors"
+	"io"
+
+	"github.com/pentops/lsplib/framing"
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// ServerRewrite translates a server's connection, whatever its framing:
+// messages it reads are rewritten for the server, messages it writes for the
+// client. Set it as jsonrpc2.Options.Rewrite.
+func (m *Map) ServerRewrite() jsonrpc2.Rewrite {
+	return jsonrpc2.Rewrite{In: m.ToServerJSON, Out: m.ToClientJSON}
+}
+
+// ClientRewrite is ServerRewrite for the client's end of the connection.
+func (m *Map) ClientRewrite() jsonrpc2.Rewrite {
+	return jsonrpc2.Rewrite{In: m.ToClientJSON, Out: m.ToServerJSON}
+}
+
+// Stream wraps the client's end of a connection to a server so messages are
+// translated as they cross: writes are rewritten for the server, reads for
+// the client. Both directions use Content-Length framing; ClientRewrite does
+// the same inside a jsonrpc2.Conn of any framing.
+func (m *Map) Stream(server io.ReadWriteCloser) io.ReadWriteCloser {
+	s := &stream{server: server}
+	var toClient *io.PipeWriter
+	s.in, toClient = io.Pipe()
+	var fromClient *io.PipeReader
+	fromClient, s.out = io.Pipe()
+
+	go func() {
+		toClient.CloseWithError(relay(framing.NewHeaderReader(server), framing.NewHeaderWriter(toClient), m.ToClientJSON))
+	}()
+	go func() {
+		fromClient.CloseWithError(relay(framing.NewHeaderReader(fromClient), framing.NewHeaderWriter(server), m.ToServerJSON))
+	}()
+	return s
+}
+
+// relay copies messages from r to w through translate. A message which
+// isn't valid JSON is passed on untouched for the peer to reject.
+func relay(r framing.Reader, w framing.Writer, translate func([]byte) ([]byte, error)) error {
+	for {
+		msg, err := r.ReadMessage()
+		if err != nil {
+			var frameErr *framing.FrameError
+			if errors.As(err, &frameErr) {
+				continue
+			}
+			return err
+		}
+		if out, err := translate(msg); err == nil {
+			msg = out
+		}
+		if err := w.WriteMessage(msg); err != nil {
+			return err
+		}
+	}
+}
+
+type stream struct {
+	server io.ReadWriteCloser
+	in     *io.PipeReader
+	out    *io.PipeWriter
+}
+
+func (s *stream) Read(p []byte) (int, error)  { return s.in.Read(p) }
+func (s *stream) Write(p []byte) (int, error) { return s.out.Write(p) }
+
+func (s *stream) Close() error {
+	_ = s.out.Close()
+	_ = s.in.Close()
+	return s.server.Close()
+}
@@ -0,0 +1,97 @@
+// Package position deals with the units of LSP character offsets, which
+// depend on the position encoding negotiated at initialize: choosing the
+// encoding, and converting positions to and from byte offsets with an Index.
+package position
+
+import (
+	"slices"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Encoding is the unit of Position.Character.
+type Encoding string
+
+const (
+	UTF8  Encoding = "utf-8"
+	UTF16 Encoding = "utf-16"
+	UTF32 Encoding = "utf-32"
+)
+
+// Default is the encoding every client supports.
+const Default = UTF16
+
+// Negotiation is the result of Negotiate.
+type Negotiation struct {
+	Encoding Encoding
+
+	// Standard reports that the client listed the encoding in
+	// general.positionEncodings, and Offset that it listed it in the
+	// clangd-style offsetEncoding extension. Either is set only when the
+	// matching initialize result field must be sent.
+	Standard bool
+	Offset   bool
+}
+
+// Negotiate picks the first of the server's preferred encodings the client
+// supports, reading both the standard general.positionEncodings and the
+// clangd offsetEncoding extension. UTF-16 is used when nothing matches.
+func Negotiate(caps protocol.ClientCapabilities, preferred []Encoding) Negotiation {
+	var standard []string
+	if caps.General != nil {
+		standard = caps.General.PositionEncodings
+	}
+	for _, enc := range preferred {
+		inStandard := contains(standard, enc)
+		inOffset := contains(caps.OffsetEncoding, enc)
+		if inStandard || inOffset || enc == Default && len(standard) == 0 && len(caps.OffsetEncoding) == 0 {
+			return Negotiation{Encoding: enc, Standard: inStandard, Offset: inOffset}
+		}
+	}
+	return Negotiation{
+		Encoding: Default,
+		Standard: contains(standard, Default),
+		Offset:   contains(caps.OffsetEncoding, Default),
+	}
+}
+
+// Offered lists the encodings the client supports, from
+// general.positionEncodings and then offsetEncoding, without repeats. A
+// client listing neither supports only UTF-16.
+func Offered(caps protocol.ClientCapabilities) []Encoding {
+	var listed []string
+	if caps.General != nil {
+		listed = caps.General.PositionEncodings
+	}
+	listed = append(listed[:len(listed):len(listed)], caps.OffsetEncoding...)
+	var out []Encoding
+	for _, item := range listed {
+		if !slices.Contains(out, Encoding(item)) {
+			out = append(out, Encoding(item))
+		}
+	}
+	if len(out) == 0 {
+		return []Encoding{Default}
+	}
+	return out
+}
+
+// Apply records a negotiation in the initialize result, in whichever fields
+// the client reads.
+func (n Negotiation) Apply(result *protocol.InitializeResult) {
+	if n.Standard {
+		result.Capabilities.PositionEncoding = string(n.Encoding)
+	}
+	if n.Offset {
+		result.OffsetEncoding = string(n.Encoding)
+	}
+}
+
+func contains(list []string, enc Encoding) bool {
+	for _, item := range list {
+		if Encoding(item) == enc {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package position
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Index converts between byte offsets in a document's text and positions
+// in any encoding. Lines end at \n, \r\n or \r, as the specification has
+// it. An Index is immutable; build a new one when the text changes.
+type Index struct {
+	text string
+
+	// starts holds the byte offset each line begins at.
+	starts []int
+}
+
+// NewIndex indexes the lines of text.
+func NewIndex(text string) *Index {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+			starts = append(starts, i+1)
+		case '\n':
+			starts = append(starts, i+1)
+		}
+	}
+	return &Index{text: text, starts: starts}
+}
+
+// Text is the indexed text.
+func (ix *Index) Text() string {
+	return ix.text
+}
+
+// Lines is the number of lines, one more than the number of line endings.
+func (ix *Index) Lines() int {
+	return len(ix.starts)
+}
+
+// Line is the text of line n, without its ending; empty for a line past
+// the last.
+func (ix *Index) Line(n int) string {
+	if n < 0 || n >= len(ix.starts) {
+		return ""
+	}
+	start, end := ix.line(n)
+	return ix.text[start:end]
+}
+
+// line returns the byte range of a line's content, without its ending.
+func (ix *Index) line(n int) (start, end int) {
+	start = ix.starts[n]
+	if n+1 == len(ix.starts) {
+		return start, len(ix.text)
+	}
+	end = ix.starts[n+1] - 1
+	if ix.text[end] == '\n' && end > start && ix.text[end-1] == '\r' {
+		end--
+	}
+	return start, end
+}
+
+// PositionToOffset returns the byte offset of pos, whose character is
+// counted in enc. A line past the last is clamped to the end of the text, a
+// character past the end of its line to the line's end, and a character
+// inside a UTF-16 surrogate pair or UTF-8 sequence to the start of the
+// character it splits.
+func (ix *Index) PositionToOffset(pos protocol.Position, enc Encoding) int {
+	if int(pos.Line) >= len(ix.starts) {
+		return len(ix.text)
+	}
+	start, end := ix.line(int(pos.Line))
+	units := 0
+	for i := start; i < end; {
+		r, size := utf8.DecodeRuneInString(ix.text[i:end])
+		units += width(r, size, enc)
+		if units > int(pos.Character) {
+			return i
+		}
+		i += size
+	}
+	return end
+}
+
+// OffsetToPosition returns the position of a byte offset, its character
+// counted in enc. An offset past the end of the text is clamped to it, one
+// inside a line ending to the end of the line, and one inside a UTF-8
+// sequence to the start of its character.
+func (ix *Index) OffsetToPosition(offset int, enc Encoding) protocol.Position {
+	offset = max(0, min(offset, len(ix.text)))
+	n := sort.Search(len(ix.starts), func(i int) bool { return ix.starts[i] > offset }) - 1
+	start, end := ix.line(n)
+	offset = min(offset, end)
+	// The end of the text has no character to back off into.
+	for offset > start && offset < len(ix.text) && !utf8.RuneStart(ix.text[offset]) {
+		offset--
+	}
+	return protocol.Position{Line: uint32(n), Character: uint32(Len(ix.text[start:offset], enc))}
+}
+
+// RangeToOffsets returns the byte offsets of a range's ends, in order.
+func (ix *Index) RangeToOffsets(r protocol.Range, enc Encoding) (start, end int) {
+	start = ix.PositionToOffset(r.Start, enc)
+	end = ix.PositionToOffset(r.End, enc)
+	if end < start {
+		start, end = end, start
+	}
+	return start, end
+}
+
+// OffsetsToRange returns the range between two byte offsets.
+func (ix *Index) OffsetsToRange(start, end int, enc Encoding) protocol.Range {
+	return protocol.Range{Start: ix.OffsetToPosition(start, enc), End: ix.OffsetToPosition(end, enc)}
+}
+
+// Convert re-counts a position's character from one encoding in another.
+func (ix *Index) Convert(pos protocol.Position, from, to Encoding) protocol.Position {
+	return ix.OffsetToPosition(ix.PositionToOffset(pos, from), to)
+}
+
+// Len is the length of s in enc's units.
+func Len(s string, enc Encoding) int {
+	switch enc {
+	case UTF8:
+		return len(s)
+	case UTF32:
+		return utf8.RuneCountInString(s)
+	}
+	n := 0
+	for _, r := range s {
+		n += width(r, utf8.RuneLen(r), enc)
+	}
+	return n
+}
+
+// width is the number of enc's units a character takes, size being its
+// length in UTF-8. Invalid UTF-8 counts one unit per byte in every encoding,
+// as the byte was decoded to one replacement character.
+func width(r rune, size int, enc Encoding) int {
+	switch enc {
+	case UTF8:
+		return size
+	case UTF32:
+		return 1
+	}
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
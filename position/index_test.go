@@ -0,0 +1,87 @@
+package position
+
+import (
+	"testing"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+func pos(line, char uint32) protocol.Position {
+	return protocol.Position{Line: line, Character: char}
+}
+
+func TestOffsetToPosition(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		text   string
+		offset int
+		// want by encoding.
+		utf8, utf16, utf32 protocol.Position
+	}{
+		{"empty", "", 0, pos(0, 0), pos(0, 0), pos(0, 0)},
+		{"eof without newline", "ab", 2, pos(0, 2), pos(0, 2), pos(0, 2)},
+		{"past eof", "ab", 9, pos(0, 2), pos(0, 2), pos(0, 2)},
+		{"negative", "ab", -1, pos(0, 0), pos(0, 0), pos(0, 0)},
+		{"eof after newline", "ab\n", 3, pos(1, 0), pos(1, 0), pos(1, 0)},
+		{"before crlf", "a\r\nb", 1, pos(0, 1), pos(0, 1), pos(0, 1)},
+		{"inside crlf", "a\r\nb", 2, pos(0, 1), pos(0, 1), pos(0, 1)},
+		{"after crlf", "a\r\nb", 3, pos(1, 0), pos(1, 0), pos(1, 0)},
+		{"eof after crlf line", "a\r\nb", 4, pos(1, 1), pos(1, 1), pos(1, 1)},
+		{"lone cr", "a\rb", 2, pos(1, 0), pos(1, 0), pos(1, 0)},
+		{"eof after lone cr", "a\r", 2, pos(1, 0), pos(1, 0), pos(1, 0)},
+		{"before surrogate pair", "a😀b", 1, pos(0, 1), pos(0, 1), pos(0, 1)},
+		{"inside surrogate pair", "a😀b", 3, pos(0, 1), pos(0, 1), pos(0, 1)},
+		{"after surrogate pair", "a😀b", 5, pos(0, 5), pos(0, 3), pos(0, 2)},
+		{"eof after surrogate pair", "a😀", 5, pos(0, 5), pos(0, 3), pos(0, 2)},
+		{"eof after two-byte rune", "é", 2, pos(0, 2), pos(0, 1), pos(0, 1)},
+		{"second line", "é\n😀x", 7, pos(1, 4), pos(1, 2), pos(1, 1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ix := NewIndex(tc.text)
+			for enc, want := range map[Encoding]protocol.Position{UTF8: tc.utf8, UTF16: tc.utf16, UTF32: tc.utf32} {
+				if got := ix.OffsetToPosition(tc.offset, enc); got != want {
+					t.Errorf("%s: OffsetToPosition(%d) = %v, want %v", enc, tc.offset, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPositionToOffset(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		pos  protocol.Position
+		// want by encoding.
+		utf8, utf16, utf32 int
+	}{
+		{"start", "ab", pos(0, 0), 0, 0, 0},
+		{"eof without newline", "ab", pos(0, 2), 2, 2, 2},
+		{"past line end", "ab", pos(0, 9), 2, 2, 2},
+		{"past last line", "ab\ncd", pos(5, 0), 5, 5, 5},
+		{"crlf line end", "ab\r\ncd", pos(0, 5), 2, 2, 2},
+		{"after crlf", "ab\r\ncd", pos(1, 1), 5, 5, 5},
+		{"lone cr", "ab\rcd", pos(1, 1), 4, 4, 4},
+		{"after surrogate pair", "a😀b", pos(0, 2), 1, 1, 5},
+		{"char after pair", "a😀b", pos(0, 3), 1, 5, 6},
+		{"eof after pair", "a😀", pos(0, 9), 5, 5, 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ix := NewIndex(tc.text)
+			for enc, want := range map[Encoding]int{UTF8: tc.utf8, UTF16: tc.utf16, UTF32: tc.utf32} {
+				if got := ix.PositionToOffset(tc.pos, enc); got != want {
+					t.Errorf("%s: PositionToOffset(%v) = %d, want %d", enc, tc.pos, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLine(t *testing.T) {
+	ix := NewIndex("a\r\nb\rc\nd")
+	for n, want := range []string{"a", "b", "c", "d", ""} {
+		if got := ix.Line(n); got != want {
+			t.Errorf("Line(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package presets holds starting points for a server's capabilities, each
+// advertising a coherent set of features with the options lsplib's
+// packages serve, so a new server begins from a configuration which is
+// correct rather than one copied from another server:
+//
+//	opts.Capabilities = presets.FullIDE(presets.Language{
+//		CompletionTriggers: []string{"."},
+//		SignatureTriggers:  []string{"(", ","},
+//	})
+//	opts.Capabilities.SemanticTokensProvider = protocol.SemanticTokensOptions{
+//		Legend: legend.Protocol(),
+//		Full:   &protocol.SemanticTokensFull{Delta: true},
+//	}
+//
+// Every function returns a fresh value, for the server to change as it
+// likes. Each lists the methods it commits the server to handling;
+// lsptest's AssertNoCapabilityWithoutHandler checks none is missed.
+package presets
+
+import (
+	"github.com/pentops/lsplib/diagnostics"
+	"github.com/pentops/lsplib/folders"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/textsync"
+)
+
+// Language holds what the capabilities of servers for different
+// languages differ by.
+type Language struct {
+	// CompletionTriggers are the characters after which the client asks
+	// for completion without the user asking, such as "." of member
+	// access. Completion is asked for while typing words regardless.
+	CompletionTriggers []string
+
+	// SignatureTriggers are the characters after which it asks for
+	// signature help, such as "(" and ",", and SignatureRetriggers those
+	// after which it asks again while help is showing, such as ")".
+	SignatureTriggers   []string
+	SignatureRetriggers []string
+}
+
+// Minimal keeps documents in sync and nothing more, for servers which only
+// push diagnostics, with diagnostics.Manager without Pull. A textsync.Store
+// serves it.
+func Minimal() protocol.ServerCapabilities {
+	return protocol.ServerCapabilities{
+		TextDocumentSync: textsync.Capability(),
+	}
+}
+
+// LintOnly is Minimal with pulled diagnostics and their quick fixes, for
+// linters: a diagnostics.Manager with Pull set serves textDocument/diagnostic
+// and workspace/diagnostic, and the server handles textDocument/codeAction.
+func LintOnly() protocol.ServerCapabilities {
+	caps := Minimal()
+	caps.DiagnosticProvider = diagnostics.Capability()
+	caps.CodeActionProvider = protocol.CodeActionOptions{
+		CodeActionKinds: []protocol.CodeActionKind{protocol.CodeActionQuickFix},
+	}
+	return caps
+}
+
+// FullIDE is LintOnly with the features an editor's users expect of a
+// language server, for lang. Besides those of LintOnly, the server handles
+// textDocument/completion, hover, signatureHelp, definition, references,
+// documentSymbol, formatting, rename, foldingRange, selectionRange and
+// inlayHint, and a folders.Set serves the workspace folders. Code actions
+// extend to refactorings and source actions.
+func FullIDE(lang Language) protocol.ServerCapabilities {
+	caps := LintOnly()
+	caps.CompletionProvider = protocol.CompletionOptions{
+		TriggerCharacters: lang.CompletionTriggers,
+	}
+	caps.HoverProvider = true
+	caps.SignatureHelpProvider = protocol.SignatureHelpOptions{
+		TriggerCharacters:   lang.SignatureTriggers,
+		RetriggerCharacters: lang.SignatureRetriggers,
+	}
+	caps.DefinitionProvider = true
+	caps.ReferencesProvider = true
+	caps.DocumentSymbolProvider = true
+	caps.CodeActionProvider = protocol.CodeActionOptions{
+		CodeActionKinds: []protocol.CodeActionKind{
+			protocol.CodeActionQuickFix,
+			protocol.CodeActionRefactor,
+			protocol.CodeActionSource,
+		},
+	}
+	caps.DocumentFormattingProvider = true
+	caps.RenameProvider = true
+	caps.FoldingRangeProvider = true
+	caps.SelectionRangeProvider = true
+	caps.InlayHintProvider = true
+	caps.Workspace = map[string]any{"workspaceFolders": folders.Capability()}
+	return caps
+}
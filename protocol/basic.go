@@ -0,0 +1,157 @@
+// Package protocol contains the Language Server Protocol wire types used by
+// the lsplib runtime packages.
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pentops/lsplib/uri"
+)
+
+// DocumentURI is a URI identifying a text document, usually with the file
+// scheme. Its methods convert it to a file path and compare it with other
+// spellings of the same file.
+type DocumentURI = uri.DocumentURI
+
+// URI is a generic URI which is not necessarily a document.
+type URI = uri.URI
+
+// Position is a zero-based line and character offset in a text document. The
+// character unit depends on the negotiated position encoding, UTF-16 by
+// default.
+type Position struct {
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+// Range is a half-open span between two positions.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range inside a document.
+type Location struct {
+	URI   DocumentURI `json:"uri"`
+	Range Range       `json:"range"`
+}
+
+// LocationLink is a link from an origin range to a target. TargetRange
+// encloses the whole target, such as a function body, and
+// TargetSelectionRange the part to select on navigation, such as its name.
+type LocationLink struct {
+	OriginSelectionRange *Range      `json:"originSelectionRange,omitempty"`
+	TargetURI            DocumentURI `json:"targetUri"`
+	TargetRange          Range       `json:"targetRange"`
+	TargetSelectionRange Range       `json:"targetSelectionRange"`
+}
+
+// IntegerOrString holds a value which the protocol allows to be either an
+// integer or a string, such as Diagnostic.Code.
+type IntegerOrString struct {
+	Integer *int32
+	String  *string
+}
+
+// NewIntegerCode builds an integer IntegerOrString.
+func NewIntegerCode(v int32) *IntegerOrString {
+	return &IntegerOrString{Integer: &v}
+}
+
+// NewStringCode builds a string IntegerOrString.
+func NewStringCode(v string) *IntegerOrString {
+	return &IntegerOrString{String: &v}
+}
+
+// Value returns the value formatted as a string, whichever form it takes.
+func (v IntegerOrString) Value() string {
+	switch {
+	case v.String != nil:
+		return *v.String
+	case v.Integer != nil:
+		return strconv.FormatInt(int64(*v.Integer), 10)
+	default:
+		return ""
+	}
+}
+
+func (v IntegerOrString) MarshalJSON() ([]byte, error) {
+	switch {
+	case v.String != nil:
+		return json.Marshal(*v.String)
+	case v.Integer != nil:
+		return json.Marshal(*v.Integer)
+	default:
+		return []byte("null"), nil
+	}
+}
+
+func (v *IntegerOrString) UnmarshalJSON(data []byte) error {
+	*v = IntegerOrString{}
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		v.String = &s
+		return nil
+	}
+	var i int32
+	if err := json.Unmarshal(data, &i); err != nil {
+		return fmt.Errorf("expected integer or string: %w", err)
+	}
+	v.Integer = &i
+	return nil
+}
+
+// TextDocumentIdentifier identifies a text document.
+type TextDocumentIdentifier struct {
+	URI DocumentURI `json:"uri"`
+}
+
+// VersionedTextDocumentIdentifier identifies a specific version of a text
+// document.
+type VersionedTextDocumentIdentifier struct {
+	URI     DocumentURI `json:"uri"`
+	Version int32       `json:"version"`
+}
+
+// TextDocumentPositionParams is the base of requests at a position in a
+// document.
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// DocumentFilter denotes documents by language, URI scheme and path, such as
+// {Language: "go", Scheme: "file"} or {Pattern: &GlobPattern{Pattern:
+// "**/vendor/**"}}. A document matches when it matches every field set.
+type DocumentFilter struct {
+	Language string       `json:"language,omitempty"`
+	Scheme   string       `json:"scheme,omitempty"`
+	Pattern  *GlobPattern `json:"pattern,omitempty"`
+}
+
+// DocumentSelector is a set of filters; a document matches when any of them
+// does.
+type DocumentSelector []DocumentFilter
+
+// MarkupKind is the format of MarkupContent.
+type MarkupKind string
+
+const (
+	PlainText MarkupKind = "plaintext"
+	Markdown  MarkupKind = "markdown"
+)
+
+// MarkupContent is documentation or hover text in a format the client
+// declared it renders.
+type MarkupContent struct {
+	Kind  MarkupKind `json:"kind"`
+	Value string     `json:"value"`
+}
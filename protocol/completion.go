@@ -0,0 +1,134 @@
+package protocol
+
+import "encoding/json"
+
+// CompletionTriggerKind is how a completion request was triggered.
+type CompletionTriggerKind int
+
+const (
+	CompletionInvoked                         CompletionTriggerKind = 1
+	CompletionTriggerCharacter                CompletionTriggerKind = 2
+	CompletionTriggerForIncompleteCompletions CompletionTriggerKind = 3
+)
+
+// CompletionContext says why completion was requested. TriggerCharacter is
+// set when Kind is CompletionTriggerCharacter.
+type CompletionContext struct {
+	TriggerKind      CompletionTriggerKind `json:"triggerKind"`
+	TriggerCharacter string                `json:"triggerCharacter,omitempty"`
+}
+
+// CompletionParams is the payload of textDocument/completion.
+type CompletionParams struct {
+	TextDocumentPositionParams
+	WorkDoneProgressParams
+	PartialResultParams
+	Context *CompletionContext `json:"context,omitempty"`
+}
+
+// CompletionItemKind is the kind of a completion item, which picks its
+// icon.
+type CompletionItemKind int
+
+const (
+	CompletionText CompletionItemKind = iota + 1
+	CompletionMethod
+	CompletionFunction
+	CompletionConstructor
+	CompletionField
+	CompletionVariable
+	CompletionClass
+	CompletionInterface
+	CompletionModule
+	CompletionProperty
+	CompletionUnit
+	CompletionValue
+	CompletionEnum
+	CompletionKeyword
+	CompletionSnippet
+	CompletionColor
+	CompletionFile
+	CompletionReference
+	CompletionFolder
+	CompletionEnumMember
+	CompletionConstant
+	CompletionStruct
+	CompletionEvent
+	CompletionOperator
+	CompletionTypeParameter
+)
+
+// InsertTextFormat is how an item's insert text is interpreted.
+type InsertTextFormat int
+
+const (
+	PlainTextFormat InsertTextFormat = 1
+	SnippetFormat   InsertTextFormat = 2
+)
+
+// CompletionItemLabelDetails is shown beside an item's label: Detail
+// directly after it, such as a signature, and Description further away,
+// such as a package.
+type CompletionItemLabelDetails struct {
+	Detail      string `json:"detail,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// CompletionItem is one completion proposal. Without a TextEdit the client
+// inserts InsertText or, failing that, Label at the word before the
+// cursor. In a CompletionList with a default edit range, TextEditText is
+// the text the range is replaced with, Label when empty. Data is kept by
+// the client and sent back in completionItem/resolve.
+type CompletionItem struct {
+	Label               string                      `json:"label"`
+	LabelDetails        *CompletionItemLabelDetails `json:"labelDetails,omitempty"`
+	Kind                CompletionItemKind          `json:"kind,omitempty"`
+	Detail              string                      `json:"detail,omitempty"`
+	Documentation       *MarkupContent              `json:"documentation,omitempty"`
+	Deprecated          bool                        `json:"deprecated,omitempty"`
+	Preselect           bool                        `json:"preselect,omitempty"`
+	SortText            string                      `json:"sortText,omitempty"`
+	FilterText          string                      `json:"filterText,omitempty"`
+	InsertText          string                      `json:"insertText,omitempty"`
+	InsertTextFormat    InsertTextFormat            `json:"insertTextFormat,omitempty"`
+	TextEdit            *TextEdit                   `json:"textEdit,omitempty"`
+	TextEditText        string                      `json:"textEditText,omitempty"`
+	AdditionalTextEdits []TextEdit                  `json:"additionalTextEdits,omitempty"`
+	CommitCharacters    []string                    `json:"commitCharacters,omitempty"`
+	Command             *Command                    `json:"command,omitempty"`
+	Data                json.RawMessage             `json:"data,omitempty"`
+}
+
+// CompletionItemDefaults are the values of a CompletionList's items which
+// don't set their own.
+type CompletionItemDefaults struct {
+	CommitCharacters []string         `json:"commitCharacters,omitempty"`
+	EditRange        *Range           `json:"editRange,omitempty"`
+	InsertTextFormat InsertTextFormat `json:"insertTextFormat,omitempty"`
+	Data             json.RawMessage  `json:"data,omitempty"`
+}
+
+// CompletionList answers textDocument/completion. IsIncomplete asks the
+// client to request completion again as the user goes on typing, rather
+// than filter the items itself.
+type CompletionList struct {
+	IsIncomplete bool                    `json:"isIncomplete"`
+	ItemDefaults *CompletionItemDefaults `json:"itemDefaults,omitempty"`
+	Items        []CompletionItem        `json:"items"`
+}
+
+// CompletionOptions is the completionProvider server capability.
+// ResolveProvider says the server answers completionItem/resolve.
+type CompletionOptions struct {
+	TriggerCharacters   []string `json:"triggerCharacters,omitempty"`
+	AllCommitCharacters []string `json:"allCommitCharacters,omitempty"`
+	ResolveProvider     bool     `json:"resolveProvider,omitempty"`
+}
+
+// SignatureHelpOptions is the signatureHelpProvider server capability:
+// the characters after which the client asks for signature help, and those
+// after which it asks again while it is showing.
+type SignatureHelpOptions struct {
+	TriggerCharacters   []string `json:"triggerCharacters,omitempty"`
+	RetriggerCharacters []string `json:"retriggerCharacters,omitempty"`
+}
@@ -0,0 +1,214 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DiagnosticSeverity is the severity of a Diagnostic.
+type DiagnosticSeverity uint32
+
+const (
+	SeverityError       DiagnosticSeverity = 1
+	SeverityWarning     DiagnosticSeverity = 2
+	SeverityInformation DiagnosticSeverity = 3
+	SeverityHint        DiagnosticSeverity = 4
+)
+
+// DiagnosticTag is additional metadata about a Diagnostic.
+type DiagnosticTag uint32
+
+const (
+	TagUnnecessary DiagnosticTag = 1
+	TagDeprecated  DiagnosticTag = 2
+)
+
+// CodeDescription links a diagnostic code to its documentation.
+type CodeDescription struct {
+	Href URI `json:"href"`
+}
+
+// DiagnosticRelatedInformation points at another location related to a
+// diagnostic, e.g. the first declaration of a duplicate symbol.
+type DiagnosticRelatedInformation struct {
+	Location Location `json:"location"`
+	Message  string   `json:"message"`
+}
+
+// Diagnostic is a compiler error, warning or similar problem within a
+// document.
+type Diagnostic struct {
+	Range              Range                          `json:"range"`
+	Severity           DiagnosticSeverity             `json:"severity,omitempty"`
+	Code               *IntegerOrString               `json:"code,omitempty"`
+	CodeDescription    *CodeDescription               `json:"codeDescription,omitempty"`
+	Source             string                         `json:"source,omitempty"`
+	Message            string                         `json:"message"`
+	Tags               []DiagnosticTag                `json:"tags,omitempty"`
+	RelatedInformation []DiagnosticRelatedInformation `json:"relatedInformation,omitempty"`
+	Data               json.RawMessage                `json:"data,omitempty"`
+}
+
+// PublishDiagnosticsParams is the payload of textDocument/publishDiagnostics.
+type PublishDiagnosticsParams struct {
+	URI         DocumentURI  `json:"uri"`
+	Version     *int32       `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// DocumentDiagnosticParams is the payload of textDocument/diagnostic, by
+// which a client pulls a document's diagnostics.
+type DocumentDiagnosticParams struct {
+	TextDocument     TextDocumentIdentifier `json:"textDocument"`
+	Identifier       string                 `json:"identifier,omitempty"`
+	PreviousResultID string                 `json:"previousResultId,omitempty"`
+}
+
+// The kinds of a document diagnostic report.
+const (
+	DiagnosticReportFull      = "full"
+	DiagnosticReportUnchanged = "unchanged"
+)
+
+// FullDocumentDiagnosticReport answers textDocument/diagnostic with every
+// diagnostic of the document.
+type FullDocumentDiagnosticReport struct {
+	Kind     string       `json:"kind"`
+	ResultID string       `json:"resultId,omitempty"`
+	Items    []Diagnostic `json:"items"`
+}
+
+// UnchangedDocumentDiagnosticReport answers textDocument/diagnostic when
+// the diagnostics are those of the previous result.
+type UnchangedDocumentDiagnosticReport struct {
+	Kind     string `json:"kind"`
+	ResultID string `json:"resultId"`
+}
+
+// DocumentDiagnosticReport answers textDocument/diagnostic: one of Full
+// and Unchanged, told apart by their kind.
+type DocumentDiagnosticReport struct {
+	Full      *FullDocumentDiagnosticReport
+	Unchanged *UnchangedDocumentDiagnosticReport
+}
+
+func (r DocumentDiagnosticReport) MarshalJSON() ([]byte, error) {
+	if r.Unchanged != nil {
+		return json.Marshal(r.Unchanged)
+	}
+	return json.Marshal(r.Full)
+}
+
+func (r *DocumentDiagnosticReport) UnmarshalJSON(data []byte) error {
+	*r = DocumentDiagnosticReport{}
+	kind, err := reportKind(data)
+	if err != nil {
+		return err
+	}
+	if kind == DiagnosticReportUnchanged {
+		r.Unchanged = &UnchangedDocumentDiagnosticReport{}
+		return json.Unmarshal(data, r.Unchanged)
+	}
+	r.Full = &FullDocumentDiagnosticReport{}
+	return json.Unmarshal(data, r.Full)
+}
+
+// DiagnosticOptions is the diagnosticProvider server capability.
+type DiagnosticOptions struct {
+	Identifier string `json:"identifier,omitempty"`
+	// InterFileDependencies is set when a change to one document can
+	// change the diagnostics of others, so the client pulls those again.
+	InterFileDependencies bool `json:"interFileDependencies"`
+	WorkspaceDiagnostics  bool `json:"workspaceDiagnostics"`
+}
+
+// WorkspaceDiagnosticParams is the payload of workspace/diagnostic, by which
+// a client pulls the diagnostics of every document the server knows of.
+type WorkspaceDiagnosticParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+	Identifier string `json:"identifier,omitempty"`
+	// PreviousResultIDs are the result ids of the reports the client
+	// already has.
+	PreviousResultIDs []PreviousResultID `json:"previousResultIds"`
+}
+
+// PreviousResultID is a document's result id as the client last had it.
+type PreviousResultID struct {
+	URI   DocumentURI `json:"uri"`
+	Value string      `json:"value"`
+}
+
+// WorkspaceDiagnosticReport answers workspace/diagnostic.
+type WorkspaceDiagnosticReport struct {
+	Items []WorkspaceDocumentDiagnosticReport `json:"items"`
+}
+
+// WorkspaceDiagnosticReportPartialResult is a part of a
+// WorkspaceDiagnosticReport streamed as a partial result.
+type WorkspaceDiagnosticReportPartialResult struct {
+	Items []WorkspaceDocumentDiagnosticReport `json:"items"`
+}
+
+// WorkspaceFullDocumentDiagnosticReport is a FullDocumentDiagnosticReport
+// for one document of a workspace report. Version is nil for documents which
+// aren't open.
+type WorkspaceFullDocumentDiagnosticReport struct {
+	Kind     string       `json:"kind"`
+	ResultID string       `json:"resultId,omitempty"`
+	Items    []Diagnostic `json:"items"`
+	URI      DocumentURI  `json:"uri"`
+	Version  *int32       `json:"version"`
+}
+
+// WorkspaceUnchangedDocumentDiagnosticReport is an
+// UnchangedDocumentDiagnosticReport for one document of a workspace report.
+type WorkspaceUnchangedDocumentDiagnosticReport struct {
+	Kind     string      `json:"kind"`
+	ResultID string      `json:"resultId"`
+	URI      DocumentURI `json:"uri"`
+	Version  *int32      `json:"version"`
+}
+
+// WorkspaceDocumentDiagnosticReport is one document's report in a
+// workspace report: one of Full and Unchanged, told apart by their kind.
+type WorkspaceDocumentDiagnosticReport struct {
+	Full      *WorkspaceFullDocumentDiagnosticReport
+	Unchanged *WorkspaceUnchangedDocumentDiagnosticReport
+}
+
+func (r WorkspaceDocumentDiagnosticReport) MarshalJSON() ([]byte, error) {
+	if r.Unchanged != nil {
+		return json.Marshal(r.Unchanged)
+	}
+	return json.Marshal(r.Full)
+}
+
+func (r *WorkspaceDocumentDiagnosticReport) UnmarshalJSON(data []byte) error {
+	*r = WorkspaceDocumentDiagnosticReport{}
+	kind, err := reportKind(data)
+	if err != nil {
+		return err
+	}
+	if kind == DiagnosticReportUnchanged {
+		r.Unchanged = &WorkspaceUnchangedDocumentDiagnosticReport{}
+		return json.Unmarshal(data, r.Unchanged)
+	}
+	r.Full = &WorkspaceFullDocumentDiagnosticReport{}
+	return json.Unmarshal(data, r.Full)
+}
+
+// reportKind is the kind of an encoded diagnostic report.
+func reportKind(data []byte) (string, error) {
+	var head struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return "", err
+	}
+	switch head.Kind {
+	case DiagnosticReportFull, DiagnosticReportUnchanged:
+		return head.Kind, nil
+	}
+	return "", fmt.Errorf("unknown diagnostic report kind %q", head.Kind)
+}
@@ -0,0 +1,142 @@
+package protocol
+
+import "encoding/json"
+
+// TextEdit replaces a range of a document. An empty range inserts and an
+// empty NewText deletes.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit is a set of edits across documents: as a map from URI in
+// Changes or, for clients declaring workspace.workspaceEdit.documentChanges,
+// as DocumentChanges carrying each document's version.
+type WorkspaceEdit struct {
+	Changes         map[DocumentURI][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []TextDocumentEdit         `json:"documentChanges,omitempty"`
+}
+
+// OptionalVersionedTextDocumentIdentifier identifies a document at a
+// version, or with a nil Version a document on disk which isn't open.
+type OptionalVersionedTextDocumentIdentifier struct {
+	URI     DocumentURI `json:"uri"`
+	Version *int32      `json:"version"`
+}
+
+// TextDocumentEdit is edits to one document, which the client applies only
+// if the document is still at the given version.
+type TextDocumentEdit struct {
+	TextDocument OptionalVersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit                              `json:"edits"`
+}
+
+// Command is a reference to a command the client can run.
+type Command struct {
+	Title     string            `json:"title"`
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+// ExecuteCommandOptions is the executeCommandProvider server capability,
+// listing the commands the server runs.
+type ExecuteCommandOptions struct {
+	Commands []string `json:"commands"`
+}
+
+// ExecuteCommandParams is the payload of workspace/executeCommand.
+type ExecuteCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+// ApplyWorkspaceEditParams is the payload of workspace/applyEdit, by which
+// the server asks the client to make an edit. Label names it for undo.
+type ApplyWorkspaceEditParams struct {
+	Label string        `json:"label,omitempty"`
+	Edit  WorkspaceEdit `json:"edit"`
+}
+
+// ApplyWorkspaceEditResult answers workspace/applyEdit.
+type ApplyWorkspaceEditResult struct {
+	Applied       bool    `json:"applied"`
+	FailureReason string  `json:"failureReason,omitempty"`
+	FailedChange  *uint32 `json:"failedChange,omitempty"`
+}
+
+// CodeActionKind is a hierarchical, dot-separated code action category.
+// Kinds other than the constants below are allowed and kept as they are;
+// IsKnown reports whether a kind is one of them.
+type CodeActionKind string
+
+const (
+	CodeActionEmpty                 CodeActionKind = ""
+	CodeActionQuickFix              CodeActionKind = "quickfix"
+	CodeActionRefactor              CodeActionKind = "refactor"
+	CodeActionRefactorExtract       CodeActionKind = "refactor.extract"
+	CodeActionRefactorInline        CodeActionKind = "refactor.inline"
+	CodeActionRefactorRewrite       CodeActionKind = "refactor.rewrite"
+	CodeActionSource                CodeActionKind = "source"
+	CodeActionSourceOrganizeImports CodeActionKind = "source.organizeImports"
+	CodeActionSourceFixAll          CodeActionKind = "source.fixAll"
+)
+
+func (k CodeActionKind) IsKnown() bool {
+	switch k {
+	case CodeActionEmpty, CodeActionQuickFix, CodeActionRefactor, CodeActionRefactorExtract, CodeActionRefactorInline,
+		CodeActionRefactorRewrite, CodeActionSource, CodeActionSourceOrganizeImports, CodeActionSourceFixAll:
+		return true
+	}
+	return false
+}
+
+// CodeAction is a change the user can apply, returned by
+// textDocument/codeAction.
+type CodeAction struct {
+	Title       string          `json:"title"`
+	Kind        CodeActionKind  `json:"kind,omitempty"`
+	Diagnostics []Diagnostic    `json:"diagnostics,omitempty"`
+	IsPreferred bool            `json:"isPreferred,omitempty"`
+	Edit        *WorkspaceEdit  `json:"edit,omitempty"`
+	Command     *Command        `json:"command,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+}
+
+// CodeActionContext is what a textDocument/codeAction request is for. Only,
+// when set, asks for actions of those kinds and their sub-kinds alone.
+type CodeActionContext struct {
+	Diagnostics []Diagnostic     `json:"diagnostics"`
+	Only        []CodeActionKind `json:"only,omitempty"`
+}
+
+// CodeActionParams is the payload of textDocument/codeAction.
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      CodeActionContext      `json:"context"`
+	WorkDoneProgressParams
+}
+
+// CodeActionOptions is the codeActionProvider capability, and the
+// registration options of textDocument/codeAction without a selector.
+type CodeActionOptions struct {
+	CodeActionKinds []CodeActionKind `json:"codeActionKinds,omitempty"`
+	ResolveProvider bool             `json:"resolveProvider,omitempty"`
+}
+
+// FormattingOptions are the client's preferences for formatting a
+// document.
+type FormattingOptions struct {
+	TabSize                uint32 `json:"tabSize"`
+	InsertSpaces           bool   `json:"insertSpaces"`
+	TrimTrailingWhitespace bool   `json:"trimTrailingWhitespace,omitempty"`
+	InsertFinalNewline     bool   `json:"insertFinalNewline,omitempty"`
+	TrimFinalNewlines      bool   `json:"trimFinalNewlines,omitempty"`
+}
+
+// DocumentFormattingParams is the payload of textDocument/formatting.
+type DocumentFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Options      FormattingOptions      `json:"options"`
+	WorkDoneProgressParams
+}
@@ -0,0 +1,126 @@
+package protocol
+
+import "encoding/json"
+
+// SymbolKind is the kind of a symbol, which picks its icon.
+type SymbolKind uint32
+
+const (
+	SymbolFile SymbolKind = iota + 1
+	SymbolModule
+	SymbolNamespace
+	SymbolPackage
+	SymbolClass
+	SymbolMethod
+	SymbolProperty
+	SymbolField
+	SymbolConstructor
+	SymbolEnum
+	SymbolInterface
+	SymbolFunction
+	SymbolVariable
+	SymbolConstant
+	SymbolString
+	SymbolNumber
+	SymbolBoolean
+	SymbolArray
+	SymbolObject
+	SymbolKey
+	SymbolNull
+	SymbolEnumMember
+	SymbolStruct
+	SymbolEvent
+	SymbolOperator
+	SymbolTypeParameter
+)
+
+// SymbolTag marks how a symbol is shown.
+type SymbolTag uint32
+
+const SymbolDeprecated SymbolTag = 1
+
+// CallHierarchyItem is a function or other callable in a call hierarchy.
+// Range spans its whole definition, and SelectionRange its name. Data is
+// kept by the client and sent back with the item in the incoming and
+// outgoing calls requests.
+type CallHierarchyItem struct {
+	Name           string          `json:"name"`
+	Kind           SymbolKind      `json:"kind"`
+	Tags           []SymbolTag     `json:"tags,omitempty"`
+	Detail         string          `json:"detail,omitempty"`
+	URI            DocumentURI     `json:"uri"`
+	Range          Range           `json:"range"`
+	SelectionRange Range           `json:"selectionRange"`
+	Data           json.RawMessage `json:"data,omitempty"`
+}
+
+// CallHierarchyPrepareParams is the payload of
+// textDocument/prepareCallHierarchy.
+type CallHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+	WorkDoneProgressParams
+}
+
+// CallHierarchyIncomingCallsParams is the payload of
+// callHierarchy/incomingCalls.
+type CallHierarchyIncomingCallsParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyIncomingCall is a caller of an item, with the ranges of its
+// calls, in the caller.
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// CallHierarchyOutgoingCallsParams is the payload of
+// callHierarchy/outgoingCalls.
+type CallHierarchyOutgoingCallsParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyOutgoingCall is what an item calls, with the ranges of the
+// calls, in the item.
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// TypeHierarchyItem is a type in a type hierarchy, as CallHierarchyItem is
+// a callable; Data comes back in the supertypes and subtypes requests.
+type TypeHierarchyItem struct {
+	Name           string          `json:"name"`
+	Kind           SymbolKind      `json:"kind"`
+	Tags           []SymbolTag     `json:"tags,omitempty"`
+	Detail         string          `json:"detail,omitempty"`
+	URI            DocumentURI     `json:"uri"`
+	Range          Range           `json:"range"`
+	SelectionRange Range           `json:"selectionRange"`
+	Data           json.RawMessage `json:"data,omitempty"`
+}
+
+// TypeHierarchyPrepareParams is the payload of
+// textDocument/prepareTypeHierarchy.
+type TypeHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+	WorkDoneProgressParams
+}
+
+// TypeHierarchySupertypesParams is the payload of typeHierarchy/supertypes.
+type TypeHierarchySupertypesParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+	Item TypeHierarchyItem `json:"item"`
+}
+
+// TypeHierarchySubtypesParams is the payload of typeHierarchy/subtypes.
+type TypeHierarchySubtypesParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+	Item TypeHierarchyItem `json:"item"`
+}
@@ -0,0 +1,182 @@
+package protocol
+
+import "encoding/json"
+
+// ClientInfo and ServerInfo identify the two ends of a session.
+type ClientInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// WorkspaceFolder is a root of a multi-root workspace.
+type WorkspaceFolder struct {
+	URI  URI    `json:"uri"`
+	Name string `json:"name"`
+}
+
+// InitializeParams is sent with the initialize request.
+type InitializeParams struct {
+	ProcessID             *int32             `json:"processId"`
+	ClientInfo            *ClientInfo        `json:"clientInfo,omitempty"`
+	Locale                string             `json:"locale,omitempty"`
+	RootURI               *DocumentURI       `json:"rootUri"`
+	InitializationOptions json.RawMessage    `json:"initializationOptions,omitempty"`
+	Capabilities          ClientCapabilities `json:"capabilities"`
+	Trace                 TraceValue         `json:"trace,omitempty"`
+	WorkspaceFolders      []WorkspaceFolder  `json:"workspaceFolders,omitempty"`
+}
+
+// TraceValue is how much the client asks the server to trace with
+// $/logTrace.
+type TraceValue string
+
+const (
+	TraceOff      TraceValue = "off"
+	TraceMessages TraceValue = "messages"
+	TraceVerbose  TraceValue = "verbose"
+)
+
+// SetTraceParams is the payload of $/setTrace.
+type SetTraceParams struct {
+	Value TraceValue `json:"value"`
+}
+
+// LogTraceParams is the payload of $/logTrace. Verbose is sent only at
+// TraceVerbose.
+type LogTraceParams struct {
+	Message string `json:"message"`
+	Verbose string `json:"verbose,omitempty"`
+}
+
+// ClientCapabilities describes what the client supports. Sections not yet
+// modelled are kept raw.
+type ClientCapabilities struct {
+	Workspace    json.RawMessage                 `json:"workspace,omitempty"`
+	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+	Window       *WindowClientCapabilities       `json:"window,omitempty"`
+	General      *GeneralClientCapabilities      `json:"general,omitempty"`
+	Experimental json.RawMessage                 `json:"experimental,omitempty"`
+
+	// OffsetEncoding is the clangd extension predating
+	// general.positionEncodings, listing encodings in preference order.
+	OffsetEncoding []string `json:"offsetEncoding,omitempty"`
+}
+
+// TextDocumentClientCapabilities holds the per-feature capabilities which
+// the runtime packages consult.
+type TextDocumentClientCapabilities struct {
+	Completion     *CompletionClientCapabilities    `json:"completion,omitempty"`
+	Hover          *HoverClientCapabilities         `json:"hover,omitempty"`
+	SignatureHelp  *SignatureHelpClientCapabilities `json:"signatureHelp,omitempty"`
+	Declaration    *LinkClientCapabilities          `json:"declaration,omitempty"`
+	Definition     *LinkClientCapabilities          `json:"definition,omitempty"`
+	TypeDefinition *LinkClientCapabilities          `json:"typeDefinition,omitempty"`
+	Implementation *LinkClientCapabilities          `json:"implementation,omitempty"`
+	FoldingRange   *FoldingRangeClientCapabilities  `json:"foldingRange,omitempty"`
+	InlayHint      *InlayHintClientCapabilities     `json:"inlayHint,omitempty"`
+}
+
+type CompletionClientCapabilities struct {
+	CompletionItem *CompletionItemClientCapabilities `json:"completionItem,omitempty"`
+	CompletionList *CompletionListClientCapabilities `json:"completionList,omitempty"`
+}
+
+// CompletionListClientCapabilities lists in ItemDefaults the properties of
+// CompletionList.itemDefaults the client fills its items in from, such as
+// "editRange" and "commitCharacters".
+type CompletionListClientCapabilities struct {
+	ItemDefaults []string `json:"itemDefaults,omitempty"`
+}
+
+// CompletionItemClientCapabilities says how completion items may be
+// written: SnippetSupport allows InsertTextFormat snippets, and
+// DocumentationFormat lists the markup kinds in preference order.
+type CompletionItemClientCapabilities struct {
+	SnippetSupport      bool         `json:"snippetSupport,omitempty"`
+	DocumentationFormat []MarkupKind `json:"documentationFormat,omitempty"`
+}
+
+// HoverClientCapabilities lists the markup kinds the client renders in
+// hovers, in preference order.
+type HoverClientCapabilities struct {
+	ContentFormat []MarkupKind `json:"contentFormat,omitempty"`
+}
+
+// SignatureHelpClientCapabilities says how signatures may be written.
+type SignatureHelpClientCapabilities struct {
+	SignatureInformation *SignatureInformationClientCapabilities `json:"signatureInformation,omitempty"`
+}
+
+// SignatureInformationClientCapabilities lists the markup kinds the client
+// renders in signature documentation, in preference order.
+type SignatureInformationClientCapabilities struct {
+	DocumentationFormat []MarkupKind `json:"documentationFormat,omitempty"`
+}
+
+// LinkClientCapabilities is shared by the go-to requests. LinkSupport means
+// the client accepts LocationLink results.
+type LinkClientCapabilities struct {
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+	LinkSupport         bool `json:"linkSupport,omitempty"`
+}
+
+type WindowClientCapabilities struct {
+	WorkDoneProgress bool            `json:"workDoneProgress,omitempty"`
+	ShowMessage      json.RawMessage `json:"showMessage,omitempty"`
+	ShowDocument     json.RawMessage `json:"showDocument,omitempty"`
+}
+
+type GeneralClientCapabilities struct {
+	PositionEncodings []string `json:"positionEncodings,omitempty"`
+}
+
+// InitializeError is the data of an initialize error reply.
+type InitializeError struct {
+	// Retry asks the client to show the error and offer to initialize
+	// again.
+	Retry bool `json:"retry"`
+}
+
+// InitializeResult is the response to initialize.
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   *ServerInfo        `json:"serverInfo,omitempty"`
+
+	// OffsetEncoding answers the clangd offsetEncoding extension.
+	OffsetEncoding string `json:"offsetEncoding,omitempty"`
+}
+
+// ServerCapabilities advertises the server's features. Fields whose spec
+// type is a union of a boolean and an options object are left as any.
+type ServerCapabilities struct {
+	PositionEncoding           string `json:"positionEncoding,omitempty"`
+	TextDocumentSync           any    `json:"textDocumentSync,omitempty"`
+	NotebookDocumentSync       any    `json:"notebookDocumentSync,omitempty"`
+	CompletionProvider         any    `json:"completionProvider,omitempty"`
+	HoverProvider              any    `json:"hoverProvider,omitempty"`
+	SignatureHelpProvider      any    `json:"signatureHelpProvider,omitempty"`
+	DefinitionProvider         any    `json:"definitionProvider,omitempty"`
+	ReferencesProvider         any    `json:"referencesProvider,omitempty"`
+	DocumentSymbolProvider     any    `json:"documentSymbolProvider,omitempty"`
+	CodeActionProvider         any    `json:"codeActionProvider,omitempty"`
+	DocumentFormattingProvider any    `json:"documentFormattingProvider,omitempty"`
+	RenameProvider             any    `json:"renameProvider,omitempty"`
+	SemanticTokensProvider     any    `json:"semanticTokensProvider,omitempty"`
+	ExecuteCommandProvider     any    `json:"executeCommandProvider,omitempty"`
+	DiagnosticProvider         any    `json:"diagnosticProvider,omitempty"`
+	CallHierarchyProvider      any    `json:"callHierarchyProvider,omitempty"`
+	TypeHierarchyProvider      any    `json:"typeHierarchyProvider,omitempty"`
+	FoldingRangeProvider       any    `json:"foldingRangeProvider,omitempty"`
+	SelectionRangeProvider     any    `json:"selectionRangeProvider,omitempty"`
+	InlayHintProvider          any    `json:"inlayHintProvider,omitempty"`
+	Workspace                  any    `json:"workspace,omitempty"`
+	Experimental               any    `json:"experimental,omitempty"`
+}
+
+// InitializedParams is sent with the initialized notification.
+type InitializedParams struct{}
@@ -0,0 +1,142 @@
+package protocol
+
+import "encoding/json"
+
+// NotebookCellKind is whether a notebook cell is markup or code.
+type NotebookCellKind uint32
+
+const (
+	CellMarkup NotebookCellKind = 1
+	CellCode   NotebookCellKind = 2
+)
+
+// ExecutionSummary is the outcome of a code cell's last execution.
+type ExecutionSummary struct {
+	ExecutionOrder uint32 `json:"executionOrder"`
+	Success        *bool  `json:"success,omitempty"`
+}
+
+// NotebookCell is a cell of a notebook. Its text is a text document of its
+// own, named by Document and synchronized with the notebook.
+type NotebookCell struct {
+	Kind             NotebookCellKind  `json:"kind"`
+	Document         DocumentURI       `json:"document"`
+	Metadata         json.RawMessage   `json:"metadata,omitempty"`
+	ExecutionSummary *ExecutionSummary `json:"executionSummary,omitempty"`
+}
+
+// NotebookDocument is an opened notebook's structure.
+type NotebookDocument struct {
+	URI          URI             `json:"uri"`
+	NotebookType string          `json:"notebookType"`
+	Version      int32           `json:"version"`
+	Metadata     json.RawMessage `json:"metadata,omitempty"`
+	Cells        []NotebookCell  `json:"cells"`
+}
+
+// NotebookDocumentIdentifier identifies a notebook.
+type NotebookDocumentIdentifier struct {
+	URI URI `json:"uri"`
+}
+
+// VersionedNotebookDocumentIdentifier identifies a specific version of a
+// notebook.
+type VersionedNotebookDocumentIdentifier struct {
+	Version int32 `json:"version"`
+	URI     URI   `json:"uri"`
+}
+
+// DidOpenNotebookDocumentParams is the payload of notebookDocument/didOpen,
+// which opens the text documents of the notebook's cells with it.
+type DidOpenNotebookDocumentParams struct {
+	NotebookDocument  NotebookDocument   `json:"notebookDocument"`
+	CellTextDocuments []TextDocumentItem `json:"cellTextDocuments"`
+}
+
+// DidChangeNotebookDocumentParams is the payload of
+// notebookDocument/didChange.
+type DidChangeNotebookDocumentParams struct {
+	NotebookDocument VersionedNotebookDocumentIdentifier `json:"notebookDocument"`
+	Change           NotebookDocumentChangeEvent         `json:"change"`
+}
+
+// NotebookDocumentChangeEvent is a change to a notebook: its metadata
+// replaced when Metadata is set, and changes to its cells.
+type NotebookDocumentChangeEvent struct {
+	Metadata json.RawMessage              `json:"metadata,omitempty"`
+	Cells    *NotebookDocumentCellChanges `json:"cells,omitempty"`
+}
+
+// NotebookDocumentCellChanges are the changes to a notebook's cells: cells
+// added, removed or moved, cells whose kind, metadata or execution summary
+// changed, and edits to cells' text.
+type NotebookDocumentCellChanges struct {
+	Structure   *NotebookDocumentCellChangeStructure `json:"structure,omitempty"`
+	Data        []NotebookCell                       `json:"data,omitempty"`
+	TextContent []NotebookDocumentCellContentChanges `json:"textContent,omitempty"`
+}
+
+// NotebookDocumentCellChangeStructure is a change to the list of a
+// notebook's cells, opening the text documents of new cells and closing
+// those of removed ones.
+type NotebookDocumentCellChangeStructure struct {
+	Array    NotebookCellArrayChange  `json:"array"`
+	DidOpen  []TextDocumentItem       `json:"didOpen,omitempty"`
+	DidClose []TextDocumentIdentifier `json:"didClose,omitempty"`
+}
+
+// NotebookCellArrayChange replaces DeleteCount cells from Start with Cells.
+type NotebookCellArrayChange struct {
+	Start       uint32         `json:"start"`
+	DeleteCount uint32         `json:"deleteCount"`
+	Cells       []NotebookCell `json:"cells,omitempty"`
+}
+
+// NotebookDocumentCellContentChanges are edits to one cell's text, as
+// textDocument/didChange would make them.
+type NotebookDocumentCellContentChanges struct {
+	Document VersionedTextDocumentIdentifier  `json:"document"`
+	Changes  []TextDocumentContentChangeEvent `json:"changes"`
+}
+
+// DidSaveNotebookDocumentParams is the payload of notebookDocument/didSave.
+type DidSaveNotebookDocumentParams struct {
+	NotebookDocument NotebookDocumentIdentifier `json:"notebookDocument"`
+}
+
+// DidCloseNotebookDocumentParams is the payload of
+// notebookDocument/didClose, which closes the text documents of the
+// notebook's cells with it.
+type DidCloseNotebookDocumentParams struct {
+	NotebookDocument  NotebookDocumentIdentifier `json:"notebookDocument"`
+	CellTextDocuments []TextDocumentIdentifier   `json:"cellTextDocuments"`
+}
+
+// NotebookDocumentSyncOptions is the notebookDocumentSync server
+// capability: the notebooks the server wants synchronized, and whether it
+// wants didSave.
+type NotebookDocumentSyncOptions struct {
+	NotebookSelector []NotebookSelector `json:"notebookSelector"`
+	Save             bool               `json:"save,omitempty"`
+}
+
+// NotebookSelector selects notebooks, by Notebook, a notebook type string
+// or a *NotebookDocumentFilter, and by the languages of their cells. At
+// least one of the two must be set.
+type NotebookSelector struct {
+	Notebook any                    `json:"notebook,omitempty"`
+	Cells    []NotebookCellLanguage `json:"cells,omitempty"`
+}
+
+// NotebookDocumentFilter matches notebooks by type, URI scheme or glob
+// pattern.
+type NotebookDocumentFilter struct {
+	NotebookType string `json:"notebookType,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	Pattern      string `json:"pattern,omitempty"`
+}
+
+// NotebookCellLanguage selects the cells of one language.
+type NotebookCellLanguage struct {
+	Language string `json:"language"`
+}
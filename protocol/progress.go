@@ -0,0 +1,54 @@
+package protocol
+
+// ProgressToken identifies a progress stream.
+type ProgressToken = IntegerOrString
+
+// ProgressParams is sent with $/progress.
+type ProgressParams struct {
+	Token ProgressToken `json:"token"`
+	Value any           `json:"value"`
+}
+
+// WorkDoneProgressParams is embedded in the params of requests which can
+// report their progress on a token the client chose.
+type WorkDoneProgressParams struct {
+	WorkDoneToken *ProgressToken `json:"workDoneToken,omitempty"`
+}
+
+// PartialResultParams is embedded in the params of requests which can
+// stream parts of their result as $/progress on a token the client chose.
+type PartialResultParams struct {
+	PartialResultToken *ProgressToken `json:"partialResultToken,omitempty"`
+}
+
+// WorkDoneProgressCreateParams is sent with window/workDoneProgress/create.
+type WorkDoneProgressCreateParams struct {
+	Token ProgressToken `json:"token"`
+}
+
+// WorkDoneProgressCancelParams is sent with window/workDoneProgress/cancel.
+type WorkDoneProgressCancelParams struct {
+	Token ProgressToken `json:"token"`
+}
+
+// WorkDoneProgressBegin, Report and End are the values of a work-done
+// progress stream. Percentage is 0 to 100.
+type WorkDoneProgressBegin struct {
+	Kind        string  `json:"kind"`
+	Title       string  `json:"title"`
+	Cancellable bool    `json:"cancellable,omitempty"`
+	Message     string  `json:"message,omitempty"`
+	Percentage  *uint32 `json:"percentage,omitempty"`
+}
+
+type WorkDoneProgressReport struct {
+	Kind        string  `json:"kind"`
+	Cancellable bool    `json:"cancellable,omitempty"`
+	Message     string  `json:"message,omitempty"`
+	Percentage  *uint32 `json:"percentage,omitempty"`
+}
+
+type WorkDoneProgressEnd struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
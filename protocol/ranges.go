@@ -0,0 +1,153 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FoldingRangeKind is the kind of a folding range, which clients fold on
+// commands such as "fold all comments".
+type FoldingRangeKind string
+
+const (
+	FoldingComment FoldingRangeKind = "comment"
+	FoldingImports FoldingRangeKind = "imports"
+	FoldingRegion  FoldingRangeKind = "region"
+)
+
+// FoldingRange is a range of lines the client can fold. StartCharacter and
+// EndCharacter, which clients with lineFoldingOnly ignore, are nil for the
+// ends of the lines. CollapsedText is shown in place of a folded range by
+// clients supporting it.
+type FoldingRange struct {
+	StartLine      uint32           `json:"startLine"`
+	StartCharacter *uint32          `json:"startCharacter,omitempty"`
+	EndLine        uint32           `json:"endLine"`
+	EndCharacter   *uint32          `json:"endCharacter,omitempty"`
+	Kind           FoldingRangeKind `json:"kind,omitempty"`
+	CollapsedText  string           `json:"collapsedText,omitempty"`
+}
+
+// FoldingRangeParams is the payload of textDocument/foldingRange.
+type FoldingRangeParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// FoldingRangeClientCapabilities says which folding ranges the client
+// uses: RangeLimit, when positive, caps how many it keeps, and
+// LineFoldingOnly means it folds whole lines.
+type FoldingRangeClientCapabilities struct {
+	RangeLimit      uint32                              `json:"rangeLimit,omitempty"`
+	LineFoldingOnly bool                                `json:"lineFoldingOnly,omitempty"`
+	FoldingRange    *FoldingRangeItemClientCapabilities `json:"foldingRange,omitempty"`
+}
+
+// FoldingRangeItemClientCapabilities says whether the client shows a
+// range's CollapsedText.
+type FoldingRangeItemClientCapabilities struct {
+	CollapsedText bool `json:"collapsedText,omitempty"`
+}
+
+// SelectionRangeParams is the payload of textDocument/selectionRange,
+// which is answered with a SelectionRange per position.
+type SelectionRangeParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Positions    []Position             `json:"positions"`
+}
+
+// SelectionRange is a range the client selects as the user expands the
+// selection, and the range enclosing it, selected next.
+type SelectionRange struct {
+	Range  Range           `json:"range"`
+	Parent *SelectionRange `json:"parent,omitempty"`
+}
+
+// InlayHintKind is the kind of an inlay hint, which clients style.
+type InlayHintKind uint32
+
+const (
+	InlayHintType      InlayHintKind = 1
+	InlayHintParameter InlayHintKind = 2
+)
+
+// InlayHint is text the client shows inline at Position without it being
+// part of the document, such as an inferred type or a parameter name.
+// TextEdits, when set, insert the hint for real when the user accepts it.
+// Data is kept by the client and sent back with the hint in
+// inlayHint/resolve.
+type InlayHint struct {
+	Position     Position        `json:"position"`
+	Label        InlayHintLabel  `json:"label"`
+	Kind         InlayHintKind   `json:"kind,omitempty"`
+	TextEdits    []TextEdit      `json:"textEdits,omitempty"`
+	Tooltip      *MarkupContent  `json:"tooltip,omitempty"`
+	PaddingLeft  bool            `json:"paddingLeft,omitempty"`
+	PaddingRight bool            `json:"paddingRight,omitempty"`
+	Data         json.RawMessage `json:"data,omitempty"`
+}
+
+// InlayHintLabel is a hint's label: Parts when set, each with a tooltip,
+// location or command of its own, and Value otherwise.
+type InlayHintLabel struct {
+	Value string
+	Parts []InlayHintLabelPart
+}
+
+// MarshalJSON writes the label as a string, or as an array of parts.
+func (l InlayHintLabel) MarshalJSON() ([]byte, error) {
+	if len(l.Parts) > 0 {
+		return json.Marshal(l.Parts)
+	}
+	return json.Marshal(l.Value)
+}
+
+// UnmarshalJSON reads either form of the label.
+func (l *InlayHintLabel) UnmarshalJSON(data []byte) error {
+	*l = InlayHintLabel{}
+	if json.Unmarshal(data, &l.Value) == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &l.Parts); err != nil {
+		return fmt.Errorf("protocol: inlay hint label is neither a string nor parts: %w", err)
+	}
+	return nil
+}
+
+// InlayHintLabelPart is a part of a hint's label. Location makes it
+// clickable, going to the location; Command runs instead when set.
+type InlayHintLabelPart struct {
+	Value    string         `json:"value"`
+	Tooltip  *MarkupContent `json:"tooltip,omitempty"`
+	Location *Location      `json:"location,omitempty"`
+	Command  *Command       `json:"command,omitempty"`
+}
+
+// InlayHintParams is the payload of textDocument/inlayHint, for the hints
+// in Range, usually what the editor shows.
+type InlayHintParams struct {
+	WorkDoneProgressParams
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+// InlayHintClientCapabilities lists in ResolveSupport the hint properties
+// the client lets inlayHint/resolve fill in, such as "tooltip" and
+// "label.location".
+type InlayHintClientCapabilities struct {
+	ResolveSupport *ResolveSupportClientCapabilities `json:"resolveSupport,omitempty"`
+}
+
+// ResolveSupportClientCapabilities lists the properties a resolve request
+// may fill in.
+type ResolveSupportClientCapabilities struct {
+	Properties []string `json:"properties"`
+}
+
+// InlayHintOptions is the inlayHintProvider server capability.
+type InlayHintOptions struct {
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
@@ -0,0 +1,62 @@
+package protocol
+
+// SemanticTokensLegend names the token types and modifiers the encoded
+// data refers to by index: a type by its position in TokenTypes, modifiers
+// by bits set in a mask, bit n for TokenModifiers[n].
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokensOptions is the semanticTokensProvider capability.
+type SemanticTokensOptions struct {
+	Legend SemanticTokensLegend `json:"legend"`
+	Range  bool                 `json:"range,omitempty"`
+	Full   *SemanticTokensFull  `json:"full,omitempty"`
+}
+
+// SemanticTokensFull says the server answers full requests, and with
+// Delta full/delta ones too.
+type SemanticTokensFull struct {
+	Delta bool `json:"delta,omitempty"`
+}
+
+// SemanticTokensParams is the payload of textDocument/semanticTokens/full.
+type SemanticTokensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// SemanticTokensDeltaParams is the payload of
+// textDocument/semanticTokens/full/delta.
+type SemanticTokensDeltaParams struct {
+	TextDocument     TextDocumentIdentifier `json:"textDocument"`
+	PreviousResultID string                 `json:"previousResultId"`
+}
+
+// SemanticTokensRangeParams is the payload of
+// textDocument/semanticTokens/range.
+type SemanticTokensRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+// SemanticTokens is a document's tokens, five integers each, relative to
+// the token before. ResultID identifies them for a later delta request.
+type SemanticTokens struct {
+	ResultID string   `json:"resultId,omitempty"`
+	Data     []uint32 `json:"data"`
+}
+
+// SemanticTokensDelta is the change from the tokens of a previous result.
+type SemanticTokensDelta struct {
+	ResultID string               `json:"resultId,omitempty"`
+	Edits    []SemanticTokensEdit `json:"edits"`
+}
+
+// SemanticTokensEdit replaces DeleteCount integers of the previous data at
+// Start with Data.
+type SemanticTokensEdit struct {
+	Start       uint32   `json:"start"`
+	DeleteCount uint32   `json:"deleteCount"`
+	Data        []uint32 `json:"data,omitempty"`
+}
@@ -0,0 +1,66 @@
+package protocol
+
+// TextDocumentSyncKind is how the client sends document changes.
+type TextDocumentSyncKind uint32
+
+const (
+	SyncNone        TextDocumentSyncKind = 0
+	SyncFull        TextDocumentSyncKind = 1
+	SyncIncremental TextDocumentSyncKind = 2
+)
+
+// SaveOptions asks for didSave notifications, with the saved text when
+// IncludeText is set.
+type SaveOptions struct {
+	IncludeText bool `json:"includeText,omitempty"`
+}
+
+// TextDocumentSyncOptions is the textDocumentSync server capability.
+type TextDocumentSyncOptions struct {
+	OpenClose bool                 `json:"openClose,omitempty"`
+	Change    TextDocumentSyncKind `json:"change,omitempty"`
+	Save      *SaveOptions         `json:"save,omitempty"`
+}
+
+// TextDocumentItem is an opened document's content.
+type TextDocumentItem struct {
+	URI        DocumentURI `json:"uri"`
+	LanguageID string      `json:"languageId"`
+	Version    int32       `json:"version"`
+	Text       string      `json:"text"`
+}
+
+// DidOpenTextDocumentParams is the payload of textDocument/didOpen.
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// TextDocumentContentChangeEvent replaces Range with Text, or the whole
+// document when Range is nil.
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+
+	// Deprecated: Use Range.
+	RangeLength *uint32 `json:"rangeLength,omitempty"`
+
+	Text string `json:"text"`
+}
+
+// DidChangeTextDocumentParams is the payload of textDocument/didChange.
+// Changes apply in order, each to the result of the one before.
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+// DidCloseTextDocumentParams is the payload of textDocument/didClose.
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DidSaveTextDocumentParams is the payload of textDocument/didSave. Text is
+// set when the server asked for it in SaveOptions.
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Text         *string                `json:"text,omitempty"`
+}
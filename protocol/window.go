@@ -0,0 +1,53 @@
+package protocol
+
+// MessageType is the importance of a window message.
+type MessageType uint32
+
+const (
+	MessageError   MessageType = 1
+	MessageWarning MessageType = 2
+	MessageInfo    MessageType = 3
+	MessageLog     MessageType = 4
+	MessageDebug   MessageType = 5
+)
+
+// ShowMessageParams is the payload of window/showMessage.
+type ShowMessageParams struct {
+	Type    MessageType `json:"type"`
+	Message string      `json:"message"`
+}
+
+// ShowMessageRequestParams is the payload of window/showMessageRequest,
+// which the client answers with the MessageActionItem the user chose, or
+// null when they dismissed the message.
+type ShowMessageRequestParams struct {
+	Type    MessageType         `json:"type"`
+	Message string              `json:"message"`
+	Actions []MessageActionItem `json:"actions,omitempty"`
+}
+
+// MessageActionItem is an action offered by window/showMessageRequest.
+type MessageActionItem struct {
+	Title string `json:"title"`
+}
+
+// LogMessageParams is the payload of window/logMessage.
+type LogMessageParams struct {
+	Type    MessageType `json:"type"`
+	Message string      `json:"message"`
+}
+
+// ShowDocumentParams is the payload of window/showDocument. External opens
+// URI in the system's default application instead of an editor; TakeFocus
+// and Selection apply to editors only.
+type ShowDocumentParams struct {
+	URI       URI    `json:"uri"`
+	External  bool   `json:"external,omitempty"`
+	TakeFocus bool   `json:"takeFocus,omitempty"`
+	Selection *Range `json:"selection,omitempty"`
+}
+
+// ShowDocumentResult is the response to window/showDocument.
+type ShowDocumentResult struct {
+	Success bool `json:"success"`
+}
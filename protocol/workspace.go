@@ -0,0 +1,240 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Registration is one capability registered with client/registerCapability.
+type Registration struct {
+	ID              string `json:"id"`
+	Method          string `json:"method"`
+	RegisterOptions any    `json:"registerOptions,omitempty"`
+}
+
+// RegistrationParams is the payload of client/registerCapability.
+type RegistrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+// Unregistration removes a capability registered earlier.
+type Unregistration struct {
+	ID     string `json:"id"`
+	Method string `json:"method"`
+}
+
+// UnregistrationParams is the payload of client/unregisterCapability. The
+// misspelt field name is the specification's.
+type UnregistrationParams struct {
+	Unregisterations []Unregistration `json:"unregisterations"`
+}
+
+// WatchKind is a bit set of the file events a watcher reports.
+type WatchKind uint32
+
+const (
+	WatchCreate WatchKind = 1
+	WatchChange WatchKind = 2
+	WatchDelete WatchKind = 4
+
+	// WatchAll is the kind of a watcher which doesn't give one.
+	WatchAll = WatchCreate | WatchChange | WatchDelete
+)
+
+// GlobPattern is a glob over file paths: absolute when BaseURI is empty,
+// otherwise a RelativePattern matched against paths below BaseURI.
+type GlobPattern struct {
+	BaseURI URI
+	Pattern string
+}
+
+func (g GlobPattern) MarshalJSON() ([]byte, error) {
+	if g.BaseURI == "" {
+		return json.Marshal(g.Pattern)
+	}
+	return json.Marshal(struct {
+		BaseURI URI    `json:"baseUri"`
+		Pattern string `json:"pattern"`
+	}{g.BaseURI, g.Pattern})
+}
+
+func (g *GlobPattern) UnmarshalJSON(data []byte) error {
+	*g = GlobPattern{}
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &g.Pattern)
+	}
+	var rel struct {
+		BaseURI json.RawMessage `json:"baseUri"`
+		Pattern string          `json:"pattern"`
+	}
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return err
+	}
+	g.Pattern = rel.Pattern
+	// The base is a URI or a WorkspaceFolder.
+	if len(rel.BaseURI) > 0 && rel.BaseURI[0] == '"' {
+		return json.Unmarshal(rel.BaseURI, &g.BaseURI)
+	}
+	var folder WorkspaceFolder
+	if err := json.Unmarshal(rel.BaseURI, &folder); err != nil {
+		return fmt.Errorf("relative pattern base: %w", err)
+	}
+	g.BaseURI = folder.URI
+	return nil
+}
+
+// FileSystemWatcher asks the client to report events on files matching
+// GlobPattern. A zero Kind means WatchAll.
+type FileSystemWatcher struct {
+	GlobPattern GlobPattern `json:"globPattern"`
+	Kind        WatchKind   `json:"kind,omitempty"`
+}
+
+// DidChangeWatchedFilesRegistrationOptions registers file watchers.
+type DidChangeWatchedFilesRegistrationOptions struct {
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+// FileChangeType is what happened to a watched file.
+type FileChangeType uint32
+
+const (
+	FileCreated FileChangeType = 1
+	FileChanged FileChangeType = 2
+	FileDeleted FileChangeType = 3
+)
+
+// FileEvent is one change to a watched file.
+type FileEvent struct {
+	URI  DocumentURI    `json:"uri"`
+	Type FileChangeType `json:"type"`
+}
+
+// DidChangeWatchedFilesParams is the payload of
+// workspace/didChangeWatchedFiles.
+type DidChangeWatchedFilesParams struct {
+	Changes []FileEvent `json:"changes"`
+}
+
+// ConfigurationItem names a settings section to fetch, optionally as it
+// applies to one resource.
+type ConfigurationItem struct {
+	ScopeURI URI    `json:"scopeUri,omitempty"`
+	Section  string `json:"section,omitempty"`
+}
+
+// ConfigurationParams is the payload of workspace/configuration, whose
+// result has one value per item, null for a section the client lacks.
+type ConfigurationParams struct {
+	Items []ConfigurationItem `json:"items"`
+}
+
+// DidChangeConfigurationParams is the payload of
+// workspace/didChangeConfiguration. Clients which answer
+// workspace/configuration commonly send null settings, leaving the server
+// to fetch them.
+type DidChangeConfigurationParams struct {
+	Settings json.RawMessage `json:"settings"`
+}
+
+// FileOperationPatternKind restricts a file operation pattern to files or
+// to folders.
+type FileOperationPatternKind string
+
+const (
+	FileOperationFile   FileOperationPatternKind = "file"
+	FileOperationFolder FileOperationPatternKind = "folder"
+)
+
+// FileOperationPatternOptions qualifies a file operation pattern.
+type FileOperationPatternOptions struct {
+	IgnoreCase bool `json:"ignoreCase,omitempty"`
+}
+
+// FileOperationPattern is a glob over the paths of files operated on. An
+// empty Matches matches both files and folders.
+type FileOperationPattern struct {
+	Glob    string                       `json:"glob"`
+	Matches FileOperationPatternKind     `json:"matches,omitempty"`
+	Options *FileOperationPatternOptions `json:"options,omitempty"`
+}
+
+// FileOperationFilter selects the files whose operations a server is told
+// about: those matching Pattern, with the URI scheme Scheme, any when
+// empty.
+type FileOperationFilter struct {
+	Scheme  string               `json:"scheme,omitempty"`
+	Pattern FileOperationPattern `json:"pattern"`
+}
+
+// FileOperationRegistrationOptions are the filters of one file operation.
+type FileOperationRegistrationOptions struct {
+	Filters []FileOperationFilter `json:"filters"`
+}
+
+// FileOperationOptions is the workspace.fileOperations server capability,
+// naming the file operations the server is told about.
+type FileOperationOptions struct {
+	DidCreate  *FileOperationRegistrationOptions `json:"didCreate,omitempty"`
+	WillCreate *FileOperationRegistrationOptions `json:"willCreate,omitempty"`
+	DidRename  *FileOperationRegistrationOptions `json:"didRename,omitempty"`
+	WillRename *FileOperationRegistrationOptions `json:"willRename,omitempty"`
+	DidDelete  *FileOperationRegistrationOptions `json:"didDelete,omitempty"`
+	WillDelete *FileOperationRegistrationOptions `json:"willDelete,omitempty"`
+}
+
+// WorkspaceFoldersServerCapabilities is the workspace.workspaceFolders
+// server capability. ChangeNotifications is true to be sent
+// workspace/didChangeWorkspaceFolders, or a registration ID to unregister
+// it by later.
+type WorkspaceFoldersServerCapabilities struct {
+	Supported           bool `json:"supported,omitempty"`
+	ChangeNotifications any  `json:"changeNotifications,omitempty"`
+}
+
+// WorkspaceFoldersChangeEvent is the folders added to and removed from the
+// workspace.
+type WorkspaceFoldersChangeEvent struct {
+	Added   []WorkspaceFolder `json:"added"`
+	Removed []WorkspaceFolder `json:"removed"`
+}
+
+// DidChangeWorkspaceFoldersParams is the payload of
+// workspace/didChangeWorkspaceFolders.
+type DidChangeWorkspaceFoldersParams struct {
+	Event WorkspaceFoldersChangeEvent `json:"event"`
+}
+
+// FileCreate is a file created by the user.
+type FileCreate struct {
+	URI DocumentURI `json:"uri"`
+}
+
+// CreateFilesParams is the payload of workspace/willCreateFiles and
+// workspace/didCreateFiles.
+type CreateFilesParams struct {
+	Files []FileCreate `json:"files"`
+}
+
+// FileRename is a file, or folder, renamed by the user.
+type FileRename struct {
+	OldURI DocumentURI `json:"oldUri"`
+	NewURI DocumentURI `json:"newUri"`
+}
+
+// RenameFilesParams is the payload of workspace/willRenameFiles and
+// workspace/didRenameFiles.
+type RenameFilesParams struct {
+	Files []FileRename `json:"files"`
+}
+
+// FileDelete is a file deleted by the user.
+type FileDelete struct {
+	URI DocumentURI `json:"uri"`
+}
+
+// DeleteFilesParams is the payload of workspace/willDeleteFiles and
+// workspace/didDeleteFiles.
+type DeleteFilesParams struct {
+	Files []FileDelete `json:"files"`
+}
@@ -0,0 +1,120 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// difference is a value which differs between two responses, at path.
+type difference struct {
+	path             string
+	recorded, actual json.RawMessage
+}
+
+// diff lists the values which differ between the responses recorded and
+// actual, other than those at ignored paths. Numbers compare as they are
+// spelled, so their precision is kept.
+func diff(recorded, actual json.RawMessage, ignore []string) []difference {
+	var patterns [][]string
+	for _, path := range ignore {
+		patterns = append(patterns, strings.Split(path, "."))
+	}
+	var diffs []difference
+	walk(decodeValue(recorded), decodeValue(actual), nil, patterns, &diffs)
+	return diffs
+}
+
+func walk(recorded, actual any, path []string, ignore [][]string, diffs *[]difference) {
+	if ignored(path, ignore) {
+		return
+	}
+	switch r := recorded.(type) {
+	case map[string]any:
+		if a, ok := actual.(map[string]any); ok {
+			keys := make([]string, 0, len(r)+len(a))
+			for k := range r {
+				keys = append(keys, k)
+			}
+			for k := range a {
+				if _, ok := r[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				walk(property(r, k), property(a, k), append(path, k), ignore, diffs)
+			}
+			return
+		}
+	case []any:
+		if a, ok := actual.([]any); ok && len(a) == len(r) {
+			for i := range r {
+				walk(r[i], a[i], append(path, strconv.Itoa(i)), ignore, diffs)
+			}
+			return
+		}
+	}
+	rec, act := encodeValue(recorded), encodeValue(actual)
+	if !bytes.Equal(rec, act) || (rec == nil) != (act == nil) {
+		*diffs = append(*diffs, difference{path: strings.Join(path, "."), recorded: rec, actual: act})
+	}
+}
+
+// ignored reports whether an ignore pattern matches path, or a field it is
+// under.
+func ignored(path []string, ignore [][]string) bool {
+	for _, pattern := range ignore {
+		if len(pattern) > len(path) {
+			continue
+		}
+		match := true
+		for i, segment := range pattern {
+			if segment != "*" && segment != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// absent marks a property one response has and the other lacks.
+type absent struct{}
+
+func property(obj map[string]any, key string) any {
+	if v, ok := obj[key]; ok {
+		return v
+	}
+	return absent{}
+}
+
+func decodeValue(raw json.RawMessage) any {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if dec.Decode(&v) != nil {
+		return string(raw)
+	}
+	return v
+}
+
+// encodeValue is v as JSON, nil for a property which is absent.
+func encodeValue(v any) json.RawMessage {
+	switch v.(type) {
+	case absent:
+		return nil
+	case nil:
+		return json.RawMessage("null")
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage(strconv.Quote(err.Error()))
+	}
+	return raw
+}
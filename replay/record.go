@@ -0,0 +1,97 @@
+// Package replay records LSP sessions and replays them against a server, so
+// traffic captured from a real editor becomes a regression test without
+// scripting the editor. A Recorder, set as a connection's Rewrite, writes
+// every message with the time it crossed; Replay sends the client's side of
+// a recording to a server, answering the server's requests as the client
+// did, and reports where the server's responses differ from those
+// recorded.
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// From is the side of the session which sent a message.
+type From string
+
+const (
+	FromClient From = "client"
+	FromServer From = "server"
+)
+
+// Frame is a recorded message. A recording is a series of frames as JSON,
+// one per line.
+type Frame struct {
+	Time    time.Time       `json:"time"`
+	From    From            `json:"from"`
+	Message json.RawMessage `json:"message"`
+}
+
+// Recorder writes the messages crossing a connection to Out as frames. A
+// Recorder serves one connection and is safe for concurrent use.
+type Recorder struct {
+	Out   io.Writer
+	Clock clock.Clock
+
+	mu sync.Mutex
+}
+
+// ServerRewrite records a server's connection, messages it reads as the
+// client's and those it writes as its own, leaving them as they are. Set it
+// as jsonrpc2.Options.Rewrite, as lsp.Options.Conn.Rewrite for a Server,
+// which records messages as they are on the wire.
+func (r *Recorder) ServerRewrite() jsonrpc2.Rewrite {
+	return jsonrpc2.Rewrite{In: r.recorder(FromClient), Out: r.recorder(FromServer)}
+}
+
+// ClientRewrite is ServerRewrite for the client's end of the connection.
+func (r *Recorder) ClientRewrite() jsonrpc2.Rewrite {
+	return jsonrpc2.Rewrite{In: r.recorder(FromServer), Out: r.recorder(FromClient)}
+}
+
+func (r *Recorder) recorder(from From) func([]byte) ([]byte, error) {
+	return func(msg []byte) ([]byte, error) {
+		r.record(from, msg)
+		return msg, nil
+	}
+}
+
+// record writes a frame of msg, unless it isn't JSON, as a message which
+// fails to decode can't be replayed.
+func (r *Recorder) record(from From, msg []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	line, err := json.Marshal(Frame{Time: clock.Or(r.Clock).Now(), From: from, Message: msg})
+	if err != nil {
+		return
+	}
+	_, _ = r.Out.Write(append(line, '\n'))
+}
+
+// Read reads the frames of a recording.
+func Read(r io.Reader) ([]Frame, error) {
+	dec := json.NewDecoder(r)
+	var frames []Frame
+	for {
+		var f Frame
+		err := dec.Decode(&f)
+		if errors.Is(err, io.EOF) {
+			return frames, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("replay: frame %d: %w", len(frames)+1, err)
+		}
+		if f.From != FromClient && f.From != FromServer {
+			return nil, fmt.Errorf("replay: frame %d is from %q, neither client nor server", len(frames)+1, f.From)
+		}
+		frames = append(frames, f)
+	}
+}
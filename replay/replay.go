@@ -0,0 +1,339 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// Options configures Replay.
+type Options struct {
+	// Ignore lists, by method, the fields of responses which aren't
+	// compared, such as those holding timestamps or opaque data; the
+	// fields under "" are ignored in every response. A field is a dotted
+	// path from the response, such as "result.items.*.data", where *
+	// matches any property or element.
+	Ignore map[string][]string
+
+	// Paced keeps the recorded gaps between the client's messages, for
+	// servers whose behaviour depends on timing, such as debouncing;
+	// otherwise each is sent as soon as the responses the client had
+	// before sending it have arrived.
+	Paced bool
+
+	// Timeout bounds each request, 10s when zero.
+	Timeout time.Duration
+
+	// Clock paces the replay, clock.Real when nil.
+	Clock clock.Clock
+}
+
+// Mismatch is a difference between the session replayed and the one
+// recorded. With a Path, it is a field of a response whose value differs,
+// Recorded or Actual being nil where the field is absent. Without one, it
+// is a response which was recorded but didn't arrive, so Actual is nil, or
+// a request from the server which the recording has no answer to, so
+// Recorded is nil and Actual holds its params.
+type Mismatch struct {
+	Method string
+	// ID is the request's recorded ID.
+	ID       jsonrpc2.ID
+	Path     string
+	Recorded json.RawMessage
+	Actual   json.RawMessage
+}
+
+func (m Mismatch) String() string {
+	switch {
+	case m.Path == "" && m.Recorded == nil:
+		return fmt.Sprintf("%s: the server sent a request the recording doesn't answer", m.Method)
+	case m.Path == "":
+		return fmt.Sprintf("%s (%s): no response", m.Method, m.ID)
+	}
+	return fmt.Sprintf("%s (%s): %s: recorded %s, got %s", m.Method, m.ID, m.Path, shown(m.Recorded), shown(m.Actual))
+}
+
+func shown(v json.RawMessage) string {
+	if v == nil {
+		return "nothing"
+	}
+	return string(v)
+}
+
+// message is a recorded message, decoded.
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *jsonrpc2.Error `json:"error"`
+}
+
+// call is a recorded request of the client's, as replayed.
+type call struct {
+	req *message
+	// answer is the recorded response's frame index, or -1.
+	answer   int
+	response *message
+	done     chan struct{}
+	cancel   context.CancelFunc
+	// cancelled is set when the recording cancels the request, which
+	// leaves its response unknown.
+	cancelled bool
+	result    json.RawMessage
+	err       error
+}
+
+// Replay sends the client's messages of frames to the server at the other
+// end of stream in the order they were recorded, each once the responses
+// the client had received before it have arrived, answers the server's
+// requests with the client's recorded responses, method by method, and
+// returns where the server's responses differ from those in frames.
+// Cancellations are replayed, and the responses to the requests they
+// cancel aren't compared. Notifications from the server aren't compared
+// either. Replay returns once every request has been answered; it fails if
+// the connection does first.
+func Replay(ctx context.Context, stream io.ReadWriter, frames []Frame, opts Options) ([]Mismatch, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	msgs := make([]*message, len(frames))
+	for i, f := range frames {
+		msgs[i] = &message{}
+		if err := json.Unmarshal(f.Message, msgs[i]); err != nil {
+			return nil, fmt.Errorf("replay: frame %d: %w", i+1, err)
+		}
+	}
+	calls, answers := index(frames, msgs)
+
+	var mu sync.Mutex
+	var mismatches []Mismatch
+	conn := jsonrpc2.NewConn(stream, jsonrpc2.Options{CancelMethod: "$/cancelRequest"})
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	ran := make(chan error, 1)
+	go func() {
+		ran <- conn.Run(ctx, jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			if req.IsNotification() {
+				return nil, nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(answers[req.Method]) == 0 {
+				mismatches = append(mismatches, Mismatch{Method: req.Method, Actual: req.Params})
+				return nil, jsonrpc2.NewError(jsonrpc2.CodeMethodNotFound, "replay: no recorded answer to %s", req.Method)
+			}
+			answer := answers[req.Method][0]
+			answers[req.Method] = answers[req.Method][1:]
+			if answer.Error != nil {
+				return nil, answer.Error
+			}
+			return answer.Result, nil
+		}))
+	}()
+
+	clk := clock.Or(opts.Clock)
+	var started []*call
+	var last time.Time
+	for i, f := range frames {
+		if f.From != FromClient {
+			continue
+		}
+		for _, c := range started {
+			if c.answer >= 0 && c.answer < i {
+				if err := wait(ctx, c, ran); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if opts.Paced && !last.IsZero() && f.Time.After(last) {
+			t := clk.NewTimer(f.Time.Sub(last))
+			select {
+			case <-t.C():
+			case <-ctx.Done():
+				t.Stop()
+				return nil, ctx.Err()
+			}
+		}
+		last = f.Time
+
+		msg := msgs[i]
+		switch {
+		case msg.Method == "":
+			// An answer to the server, given when it asks.
+		case msg.ID == nil && msg.Method == "$/cancelRequest":
+			var params struct {
+				ID json.RawMessage `json:"id"`
+			}
+			if json.Unmarshal(msg.Params, &params) != nil {
+				continue
+			}
+			if id, err := jsonrpc2.ParseID(params.ID); err == nil && calls[id] != nil {
+				calls[id].cancelled = true
+				calls[id].cancel()
+			}
+		case msg.ID == nil:
+			if err := conn.Notify(ctx, msg.Method, params(msg)); err != nil {
+				return nil, fmt.Errorf("replay: %s: %w", msg.Method, err)
+			}
+		default:
+			id, _ := jsonrpc2.ParseID(msg.ID)
+			c := calls[id]
+			if c == nil || c.cancel != nil {
+				// A recorded ID reused, which only the first use of is
+				// replayed.
+				continue
+			}
+			var callCtx context.Context
+			callCtx, c.cancel = context.WithTimeout(ctx, opts.Timeout)
+			started = append(started, c)
+			go func() {
+				defer close(c.done)
+				defer c.cancel()
+				c.err = conn.Call(callCtx, msg.Method, params(msg), &c.result)
+			}()
+		}
+	}
+	for _, c := range started {
+		if err := wait(ctx, c, ran); err != nil {
+			return nil, err
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, c := range started {
+		if c.cancelled || c.response == nil {
+			continue
+		}
+		actual, ok := responseOf(c)
+		if !ok {
+			mismatches = append(mismatches, Mismatch{Method: c.req.Method, ID: idOf(c.req), Recorded: c.recorded()})
+			continue
+		}
+		ignore := append(opts.Ignore[""], opts.Ignore[c.req.Method]...)
+		for _, d := range diff(c.recorded(), actual, ignore) {
+			mismatches = append(mismatches, Mismatch{Method: c.req.Method, ID: idOf(c.req), Path: d.path, Recorded: d.recorded, Actual: d.actual})
+		}
+	}
+	return mismatches, nil
+}
+
+// index pairs the client's recorded requests with the server's responses,
+// by ID, and lists the client's answers to the server's requests by
+// method, in the order the server asked.
+func index(frames []Frame, msgs []*message) (map[jsonrpc2.ID]*call, map[string][]*message) {
+	calls := map[jsonrpc2.ID]*call{}
+	asked := map[jsonrpc2.ID]string{}
+	answered := map[jsonrpc2.ID]*message{}
+	var order []jsonrpc2.ID
+	for i, msg := range msgs {
+		if msg.ID == nil {
+			continue
+		}
+		id, err := jsonrpc2.ParseID(msg.ID)
+		if err != nil {
+			continue
+		}
+		fromClient := frames[i].From == FromClient
+		switch {
+		case msg.Method != "" && fromClient:
+			if calls[id] == nil {
+				calls[id] = &call{req: msg, answer: -1, done: make(chan struct{})}
+			}
+		case msg.Method != "":
+			asked[id] = msg.Method
+			order = append(order, id)
+		case fromClient:
+			answered[id] = msg
+		case calls[id] != nil && calls[id].response == nil:
+			calls[id].answer, calls[id].response = i, msg
+		}
+	}
+	answers := map[string][]*message{}
+	for _, id := range order {
+		answer := answered[id]
+		if answer == nil {
+			answer = &message{Result: json.RawMessage("null")}
+		}
+		answers[asked[id]] = append(answers[asked[id]], answer)
+	}
+	return calls, answers
+}
+
+// wait waits for c's response, failing if the connection ends first, as
+// it does once the replay exits the server.
+func wait(ctx context.Context, c *call, ran chan error) error {
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	select {
+	case <-c.done:
+		return nil
+	case err := <-ran:
+		ran <- err
+		select {
+		case <-c.done:
+			return nil
+		default:
+		}
+		if err == nil {
+			err = jsonrpc2.ErrClosed
+		}
+		return fmt.Errorf("replay: waiting for %s: %w", c.req.Method, err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// params are msg's params, nil when it has none.
+func params(msg *message) any {
+	if msg.Params == nil {
+		return nil
+	}
+	return msg.Params
+}
+
+func idOf(msg *message) jsonrpc2.ID {
+	id, _ := jsonrpc2.ParseID(msg.ID)
+	return id
+}
+
+// responseOf is the response c received, as JSON of the form recorded
+// writes, or false if none did.
+func responseOf(c *call) (json.RawMessage, bool) {
+	var rpcErr *jsonrpc2.Error
+	if c.err != nil && !errors.As(c.err, &rpcErr) {
+		return nil, false
+	}
+	return response(c.result, rpcErr), true
+}
+
+// recorded is the recorded response to c.
+func (c *call) recorded() json.RawMessage {
+	return response(c.response.Result, c.response.Error)
+}
+
+// response is a response's result or error as the object compared, to
+// which ignored paths are relative.
+func response(result json.RawMessage, rpcErr *jsonrpc2.Error) json.RawMessage {
+	var raw []byte
+	if rpcErr != nil {
+		raw, _ = json.Marshal(map[string]any{"error": rpcErr})
+		return raw
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	raw, _ = json.Marshal(map[string]json.RawMessage{"result": result})
+	return raw
+}
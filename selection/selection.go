@@ -0,0 +1,72 @@
+// Package selection builds textDocument/selectionRange results, the ranges
+// the client selects in turn as the user expands the selection, from the
+// spans of a document's syntax: each position's chain runs from the
+// innermost span around it out through every span enclosing that one.
+package selection
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Ranges returns the selection range of each of positions from spans, the
+// ranges of a document's syntax nodes in any order, such as those of every
+// node of its tree. Spans equal to the one inside them are dropped, as are
+// spans which overlap it without enclosing it, which clients reject. A
+// position no span contains gets an empty range at itself, as every
+// position must have a result.
+func Ranges(positions []protocol.Position, spans []protocol.Range) []protocol.SelectionRange {
+	sorted := slices.Clone(spans)
+	// Innermost first: the earliest end, then the latest start, which puts
+	// every span before those enclosing it.
+	slices.SortFunc(sorted, func(x, y protocol.Range) int {
+		if c := compare(x.End, y.End); c != 0 {
+			return c
+		}
+		return compare(y.Start, x.Start)
+	})
+	out := make([]protocol.SelectionRange, len(positions))
+	for i, pos := range positions {
+		var chain []protocol.Range
+		for _, span := range sorted {
+			if !contains(span, protocol.Range{Start: pos, End: pos}) {
+				continue
+			}
+			if len(chain) > 0 {
+				inner := chain[len(chain)-1]
+				if span == inner || !contains(span, inner) {
+					continue
+				}
+			}
+			chain = append(chain, span)
+		}
+		out[i] = link(pos, chain)
+	}
+	return out
+}
+
+// link builds the SelectionRange of chain, innermost first.
+func link(pos protocol.Position, chain []protocol.Range) protocol.SelectionRange {
+	if len(chain) == 0 {
+		return protocol.SelectionRange{Range: protocol.Range{Start: pos, End: pos}}
+	}
+	var parent *protocol.SelectionRange
+	for i := len(chain) - 1; i > 0; i-- {
+		parent = &protocol.SelectionRange{Range: chain[i], Parent: parent}
+	}
+	return protocol.SelectionRange{Range: chain[0], Parent: parent}
+}
+
+// contains reports whether outer encloses inner, ends included.
+func contains(outer, inner protocol.Range) bool {
+	return compare(outer.Start, inner.Start) <= 0 && compare(inner.End, outer.End) <= 0
+}
+
+func compare(a, b protocol.Position) int {
+	if c := cmp.Compare(a.Line, b.Line); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Character, b.Character)
+}
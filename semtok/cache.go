@@ -0,0 +1,92 @@
+package semtok
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Cache remembers the last tokens sent for each document, so a delta
+// request can be answered with the edits from them. It is safe for
+// concurrent use.
+type Cache struct {
+	// Case controls URI comparison.
+	Case fscase.Sensitivity
+
+	mu   sync.Mutex
+	seq  uint64
+	docs map[string]result
+}
+
+type result struct {
+	id   string
+	data []uint32
+}
+
+// Full answers textDocument/semanticTokens/full with data, remembering it
+// under a new result id.
+func (c *Cache) Full(uri protocol.DocumentURI, data []uint32) *protocol.SemanticTokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &protocol.SemanticTokens{ResultID: c.store(uri, data), Data: data}
+}
+
+// Delta answers textDocument/semanticTokens/full/delta with data: edits
+// from the previous result when previousResultID is the one remembered for
+// uri, the full tokens otherwise, such as after a restart. The result is a
+// *protocol.SemanticTokensDelta or a *protocol.SemanticTokens, either of
+// which the request allows.
+func (c *Cache) Delta(uri protocol.DocumentURI, previousResultID string, data []uint32) any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, ok := c.docs[c.Case.URIKey(string(uri))]
+	id := c.store(uri, data)
+	if !ok || prev.id != previousResultID {
+		return &protocol.SemanticTokens{ResultID: id, Data: data}
+	}
+	return &protocol.SemanticTokensDelta{ResultID: id, Edits: Diff(prev.data, data)}
+}
+
+// Forget drops what is remembered for uri, as when it is closed.
+func (c *Cache) Forget(uri protocol.DocumentURI) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.docs, c.Case.URIKey(string(uri)))
+}
+
+func (c *Cache) store(uri protocol.DocumentURI, data []uint32) string {
+	if c.docs == nil {
+		c.docs = map[string]result{}
+	}
+	c.seq++
+	id := strconv.FormatUint(c.seq, 10)
+	c.docs[c.Case.URIKey(string(uri))] = result{id: id, data: data}
+	return id
+}
+
+// Diff returns the edits turning the encoded tokens prev into next: none
+// when they are equal, otherwise one replacing what lies between their
+// common prefix and suffix. Both are kept to whole tokens, so a client
+// applying the edit never sees half of one.
+func Diff(prev, next []uint32) []protocol.SemanticTokensEdit {
+	prefix := 0
+	for prefix < len(prev) && prefix < len(next) && prev[prefix] == next[prefix] {
+		prefix++
+	}
+	prefix -= prefix % 5
+	if prefix == len(prev) && prefix == len(next) {
+		return []protocol.SemanticTokensEdit{}
+	}
+	suffix := 0
+	for suffix < len(prev)-prefix && suffix < len(next)-prefix && prev[len(prev)-1-suffix] == next[len(next)-1-suffix] {
+		suffix++
+	}
+	suffix -= suffix % 5
+	return []protocol.SemanticTokensEdit{{
+		Start:       uint32(prefix),
+		DeleteCount: uint32(len(prev) - prefix - suffix),
+		Data:        next[prefix : len(next)-suffix],
+	}}
+}
@@ -0,0 +1,107 @@
+package semtok
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// applyEdits applies edits, each counted in prev, to prev.
+func applyEdits(prev []uint32, edits []protocol.SemanticTokensEdit) []uint32 {
+	out := slices.Clone(prev)
+	sorted := slices.Clone(edits)
+	slices.SortFunc(sorted, func(a, b protocol.SemanticTokensEdit) int { return int(b.Start) - int(a.Start) })
+	for _, e := range sorted {
+		out = slices.Replace(out, int(e.Start), int(e.Start+e.DeleteCount), e.Data...)
+	}
+	return out
+}
+
+// randomTokens returns n encoded tokens with small values, so edits to
+// them leave runs equal by chance.
+func randomTokens(rnd *rand.Rand, n int) []uint32 {
+	data := make([]uint32, 5*n)
+	for i := range data {
+		data[i] = uint32(rnd.Intn(3))
+	}
+	return data
+}
+
+func TestDiff(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		prev := randomTokens(rnd, rnd.Intn(8))
+		next := slices.Clone(prev)
+		switch rnd.Intn(4) {
+		case 0:
+			// Unchanged.
+		case 1:
+			at := 5 * rnd.Intn(len(next)/5+1)
+			next = slices.Insert(next, at, randomTokens(rnd, 1+rnd.Intn(3))...)
+		case 2:
+			if len(next) > 0 {
+				at := 5 * rnd.Intn(len(next)/5)
+				next = slices.Delete(next, at, min(len(next), at+5*(1+rnd.Intn(3))))
+			}
+		case 3:
+			next = randomTokens(rnd, rnd.Intn(8))
+		}
+		edits := Diff(prev, next)
+		if got := applyEdits(prev, edits); !slices.Equal(got, next) {
+			t.Fatalf("Diff(%v, %v) = %+v, which applied gives %v", prev, next, edits, got)
+		}
+		if slices.Equal(prev, next) && len(edits) != 0 {
+			t.Fatalf("Diff of equal tokens = %+v, want no edits", edits)
+		}
+		for _, e := range edits {
+			if e.Start%5 != 0 || e.DeleteCount%5 != 0 || len(e.Data)%5 != 0 {
+				t.Fatalf("Diff(%v, %v) = %+v, which splits a token", prev, next, edits)
+			}
+		}
+	}
+}
+
+func TestCacheDelta(t *testing.T) {
+	const uri protocol.DocumentURI = "file:///a.go"
+	var c Cache
+	v1 := []uint32{0, 0, 1, 0, 0, 1, 2, 3, 1, 0}
+	v2 := []uint32{0, 0, 1, 0, 0, 0, 4, 1, 1, 0, 1, 2, 3, 1, 0}
+	v3 := []uint32{0, 0, 2, 0, 0}
+	full := c.Full(uri, v1)
+
+	got := c.Delta(uri, full.ResultID, v2)
+	res, ok := got.(*protocol.SemanticTokensDelta)
+	if !ok {
+		t.Fatalf("Delta from the last result = %T, want edits", got)
+	}
+	if got := applyEdits(v1, res.Edits); !slices.Equal(got, v2) {
+		t.Fatalf("the delta applied to the last result gives %v, want %v", got, v2)
+	}
+	if res.ResultID == full.ResultID {
+		t.Fatalf("the delta reused result id %s", res.ResultID)
+	}
+
+	// A result older than the last, or one never sent, gets the tokens in
+	// full.
+	var last string
+	for _, previous := range []string{full.ResultID, "unknown", ""} {
+		tokens, ok := c.Delta(uri, previous, v3).(*protocol.SemanticTokens)
+		if !ok || !slices.Equal(tokens.Data, v3) {
+			t.Fatalf("Delta from result %q = %+v, want the full tokens", previous, tokens)
+		}
+		last = tokens.ResultID
+	}
+	// Those are the base of the next delta.
+	delta, ok := c.Delta(uri, last, v1).(*protocol.SemanticTokensDelta)
+	if !ok || !slices.Equal(applyEdits(v3, delta.Edits), v1) {
+		t.Fatalf("Delta from the full tokens last sent = %+v, want edits to them", delta)
+	}
+
+	// A document forgotten gets them in full too.
+	c.Forget(uri)
+	if _, ok := c.Delta(uri, delta.ResultID, v2).(*protocol.SemanticTokens); !ok {
+		t.Fatal("Delta for a forgotten document wasn't answered in full")
+	}
+}
@@ -0,0 +1,163 @@
+// Package semtok encodes semantic tokens. A server appends each token of a
+// document to a Builder, in any order, by position and by the names of its
+// type and modifiers; the Builder sorts them and produces the relative
+// integer encoding of the legend it was made with. A Cache remembers each
+// document's last result so textDocument/semanticTokens/full/delta can be
+// answered with edits. A Viewport lexes only the lines a
+// textDocument/semanticTokens/range request covers, from the lexer states
+// it keeps every so many lines.
+package semtok
+
+import (
+	"sort"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// The token types and modifiers the specification predefines.
+var (
+	StandardTypes = []string{
+		"namespace", "type", "class", "enum", "interface", "struct",
+		"typeParameter", "parameter", "variable", "property", "enumMember",
+		"event", "function", "method", "macro", "keyword", "modifier",
+		"comment", "string", "number", "regexp", "operator", "decorator",
+	}
+	StandardModifiers = []string{
+		"declaration", "definition", "readonly", "static", "deprecated",
+		"abstract", "async", "modification", "documentation", "defaultLibrary",
+	}
+)
+
+// Legend is the set of token types and modifiers a server uses, advertised
+// at initialize. At most 32 modifiers fit the encoding.
+type Legend struct {
+	legend    protocol.SemanticTokensLegend
+	types     map[string]uint32
+	modifiers map[string]uint32
+}
+
+// NewLegend builds a legend from type and modifier names; modifiers past
+// the 32nd are dropped.
+func NewLegend(types, modifiers []string) *Legend {
+	if len(modifiers) > 32 {
+		modifiers = modifiers[:32]
+	}
+	l := &Legend{
+		legend: protocol.SemanticTokensLegend{
+			TokenTypes:     append([]string{}, types...),
+			TokenModifiers: append([]string{}, modifiers...),
+		},
+		types:     map[string]uint32{},
+		modifiers: map[string]uint32{},
+	}
+	for idx, name := range types {
+		l.types[name] = uint32(idx)
+	}
+	for idx, name := range modifiers {
+		l.modifiers[name] = 1 << idx
+	}
+	return l
+}
+
+// Protocol is the legend as the semanticTokensProvider capability carries
+// it.
+func (l *Legend) Protocol() protocol.SemanticTokensLegend {
+	return l.legend
+}
+
+// Type is the index of a token type, and whether the legend has it.
+func (l *Legend) Type(name string) (uint32, bool) {
+	idx, ok := l.types[name]
+	return idx, ok
+}
+
+// Modifiers is the bit mask of modifier names; names the legend lacks are
+// ignored.
+func (l *Legend) Modifiers(names ...string) uint32 {
+	var mask uint32
+	for _, name := range names {
+		mask |= l.modifiers[name]
+	}
+	return mask
+}
+
+type token struct {
+	line, char, length, typ, modifiers uint32
+}
+
+// Builder collects a document's tokens. Its zero value is not usable; make
+// one with NewBuilder.
+type Builder struct {
+	legend *Legend
+	tokens []token
+}
+
+// NewBuilder returns a Builder encoding with legend.
+func NewBuilder(legend *Legend) *Builder {
+	return &Builder{legend: legend}
+}
+
+// Add appends a token at a zero-based line and character, its character
+// and length in the negotiated position encoding. A token of a type the
+// legend lacks, or of no length, is dropped, as are modifiers it lacks.
+// Tokens must not span lines.
+func (b *Builder) Add(line, char, length uint32, tokenType string, modifiers ...string) {
+	typ, ok := b.legend.Type(tokenType)
+	if !ok || length == 0 {
+		return
+	}
+	b.tokens = append(b.tokens, token{line, char, length, typ, b.legend.Modifiers(modifiers...)})
+}
+
+// Len is the number of tokens added.
+func (b *Builder) Len() int {
+	return len(b.tokens)
+}
+
+// Encode sorts the tokens by position and returns their encoding: five
+// integers per token, its line and start relative to the token before, its
+// length, type and modifiers. A token overlapping the one before it is
+// dropped, as clients don't render overlaps.
+func (b *Builder) Encode() []uint32 {
+	sort.SliceStable(b.tokens, func(i, j int) bool {
+		if b.tokens[i].line != b.tokens[j].line {
+			return b.tokens[i].line < b.tokens[j].line
+		}
+		return b.tokens[i].char < b.tokens[j].char
+	})
+	data := make([]uint32, 0, 5*len(b.tokens))
+	var prev token
+	first := true
+	for _, t := range b.tokens {
+		if !first && t.line == prev.line && t.char < prev.char+prev.length {
+			continue
+		}
+		deltaLine, deltaChar := t.line, t.char
+		if !first {
+			deltaLine = t.line - prev.line
+			if deltaLine == 0 {
+				deltaChar = t.char - prev.char
+			}
+		}
+		data = append(data, deltaLine, deltaChar, t.length, t.typ, t.modifiers)
+		prev, first = t, false
+	}
+	return data
+}
+
+// Range returns the encoding of the tokens which start within r, for
+// textDocument/semanticTokens/range.
+func (b *Builder) Range(r protocol.Range) []uint32 {
+	in := &Builder{legend: b.legend}
+	for _, t := range b.tokens {
+		pos := protocol.Position{Line: t.line, Character: t.char}
+		if !before(pos, r.Start) && before(pos, r.End) {
+			in.tokens = append(in.tokens, t)
+		}
+	}
+	return in.Encode()
+}
+
+func before(a, b protocol.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
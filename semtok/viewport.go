@@ -0,0 +1,202 @@
+package semtok
+
+import (
+	"sync"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Line is a line of a document being lexed.
+type Line struct {
+	// Number is the line's zero-based number, and Text its text without
+	// its ending.
+	Number uint32
+	Text   string
+
+	b   *Builder
+	enc position.Encoding
+	// at is the byte in Text the last token added ended at, and units its
+	// character, so tokens added along the line are counted from there.
+	at, units int
+}
+
+// Wanted reports whether the line's tokens are wanted. A line which is
+// only lexed for the state it ends in drops them, and the lexer may skip
+// the work of finding them.
+func (l *Line) Wanted() bool {
+	return l.b != nil
+}
+
+// Add adds a token spanning the bytes start to end of Text, counted in the
+// negotiated position encoding. Adding a line's tokens in order is
+// fastest.
+func (l *Line) Add(start, end int, tokenType string, modifiers ...string) {
+	if l.b == nil {
+		return
+	}
+	start = max(0, min(start, len(l.Text)))
+	end = max(start, min(end, len(l.Text)))
+	if start < l.at {
+		l.at, l.units = 0, 0
+	}
+	char := l.units + position.Len(l.Text[l.at:start], l.enc)
+	length := position.Len(l.Text[start:end], l.enc)
+	l.at, l.units = end, char+length
+	l.b.Add(l.Number, uint32(char), uint32(length), tokenType, modifiers...)
+}
+
+// LexFunc lexes one line, adding its tokens to line, and returns the state
+// the next line starts in from the state this one does, the zero S for the
+// first line: whether a block comment or a string is open, say. The state
+// must follow from the line's text and the state before alone, as it is
+// kept for lexing from that line again.
+type LexFunc[S any] func(line *Line, state S) S
+
+// DefaultInterval is the number of lines between the lexer states a
+// Viewport keeps, when its Interval is zero.
+const DefaultInterval = 64
+
+// Viewport answers textDocument/semanticTokens/range by lexing only the
+// lines of the range, as the client asks for the part of a document on
+// screen while it is scrolled. It keeps each document's line index and the
+// lexer's state every Interval lines, so a range is lexed from the last
+// state before it rather than from the top of the document, and a change
+// drops only the states past the first line it touches. It is safe for
+// concurrent use.
+type Viewport[S any] struct {
+	// Case controls URI comparison.
+	Case fscase.Sensitivity
+
+	// Encoding returns the negotiated position encoding, as
+	// lsp.Server.PositionEncoding does. UTF-16 when nil.
+	Encoding func() position.Encoding
+
+	// Interval is the number of lines between the states kept,
+	// DefaultInterval when zero.
+	Interval int
+
+	legend *Legend
+	lex    LexFunc[S]
+
+	mu   sync.Mutex
+	docs map[string]*lexed[S]
+}
+
+// lexed is what a Viewport keeps of a document.
+type lexed[S any] struct {
+	text  string
+	index *position.Index
+	// states holds the state line i*Interval starts in, as far as the
+	// document has been lexed.
+	states []S
+}
+
+// NewViewport returns a Viewport lexing with lex, encoding with legend.
+func NewViewport[S any](legend *Legend, lex LexFunc[S]) *Viewport[S] {
+	return &Viewport[S]{legend: legend, lex: lex}
+}
+
+// Range returns the encoding of the tokens of text, the document at uri,
+// which start within r.
+func (v *Viewport[S]) Range(uri protocol.DocumentURI, text string, r protocol.Range) []uint32 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	doc := v.document(uri, text)
+	b := NewBuilder(v.legend)
+	if int(r.Start.Line) < doc.index.Lines() {
+		v.lexLines(doc, int(r.Start.Line), min(int(r.End.Line), doc.index.Lines()-1), b)
+	}
+	return b.Range(r)
+}
+
+// Full returns the encoding of every token of text, the document at uri,
+// for textDocument/semanticTokens/full, keeping the states for the ranges
+// asked for after.
+func (v *Viewport[S]) Full(uri protocol.DocumentURI, text string) []uint32 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	doc := v.document(uri, text)
+	b := NewBuilder(v.legend)
+	v.lexLines(doc, 0, doc.index.Lines()-1, b)
+	return b.Encode()
+}
+
+// Forget drops what is kept for uri, as when it is closed.
+func (v *Viewport[S]) Forget(uri protocol.DocumentURI) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.docs, v.Case.URIKey(string(uri)))
+}
+
+// document returns what is kept for uri, brought up to date with text.
+func (v *Viewport[S]) document(uri protocol.DocumentURI, text string) *lexed[S] {
+	key := v.Case.URIKey(string(uri))
+	doc := v.docs[key]
+	switch {
+	case doc == nil:
+		var zero S
+		doc = &lexed[S]{text: text, index: position.NewIndex(text), states: []S{zero}}
+		if v.docs == nil {
+			v.docs = map[string]*lexed[S]{}
+		}
+		v.docs[key] = doc
+	case doc.text != text:
+		changed := commonPrefix(doc.text, text)
+		doc.text, doc.index = text, position.NewIndex(text)
+		// The states of lines starting before the first byte changed
+		// still hold. When the text is what it was cut short, as by
+		// deleting at its end, that is every line of it.
+		line := doc.index.Lines() - 1
+		if changed < len(text) {
+			line = int(doc.index.OffsetToPosition(changed, position.UTF8).Line)
+		}
+		doc.states = doc.states[:min(len(doc.states), line/v.interval()+1)]
+	}
+	return doc
+}
+
+// lexLines lexes the lines first to last into b, from the last state kept
+// before first, keeping the states of the lines passed.
+func (v *Viewport[S]) lexLines(doc *lexed[S], first, last int, b *Builder) {
+	interval := v.interval()
+	enc := position.UTF16
+	if v.Encoding != nil {
+		enc = v.Encoding()
+	}
+	k := min(first/interval, len(doc.states)-1)
+	state := doc.states[k]
+	for n := k * interval; n <= last; n++ {
+		if n%interval == 0 && n/interval == len(doc.states) {
+			doc.states = append(doc.states, state)
+		}
+		line := &Line{Number: uint32(n), Text: doc.index.Line(n), enc: enc}
+		if n >= first {
+			line.b = b
+		}
+		state = v.lex(line, state)
+	}
+}
+
+func (v *Viewport[S]) interval() int {
+	if v.Interval > 0 {
+		return v.Interval
+	}
+	return DefaultInterval
+}
+
+// commonPrefix is the length of the longest prefix a and b share.
+func commonPrefix(a, b string) int {
+	n := min(len(a), len(b))
+	i := 0
+	// Whole chunks compare at memory speed.
+	const chunk = 4096
+	for i+chunk <= n && a[i:i+chunk] == b[i:i+chunk] {
+		i += chunk
+	}
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
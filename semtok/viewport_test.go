@@ -0,0 +1,104 @@
+package semtok
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// lexComments lexes block comments, which may span lines, and the words
+// between them. The state is whether a comment is open.
+func lexComments(line *Line, open bool) bool {
+	text := line.Text
+	for i := 0; i < len(text); {
+		if open {
+			end := strings.Index(text[i:], "*/")
+			if end < 0 {
+				line.Add(i, len(text), "comment")
+				return true
+			}
+			line.Add(i, i+end+2, "comment")
+			i, open = i+end+2, false
+			continue
+		}
+		switch {
+		case strings.HasPrefix(text[i:], "/*"):
+			open = true
+		case text[i] == ' ':
+			i++
+		default:
+			end := i + strings.IndexAny(text[i:]+" ", " /")
+			if end == i {
+				end++
+			}
+			line.Add(i, end, "variable")
+			i = end
+		}
+	}
+	return open
+}
+
+func TestViewportEditAtEnd(t *testing.T) {
+	legend := NewLegend(StandardTypes, nil)
+	all := protocol.Range{End: protocol.Position{Line: 100}}
+	for _, tc := range []struct {
+		name      string
+		old, text string
+	}{
+		{"delete last character", "abc", "ab"},
+		{"delete everything", "abc", ""},
+		{"delete trailing newline", "a\nb\n", "a\nb"},
+		{"delete last line", "a /*\nb\nc", "a /*\nb\n"},
+		{"cut to a prefix of the first line", "a /*\nb\nc", "a /"},
+		{"delete crlf ending", "a\r\nb\r\n", "a\r\nb\r"},
+		{"append character", "ab", "abc"},
+		{"append line", "a\nb", "a\nb\nc"},
+		{"append comment", "a\nb\n", "a\nb\n/* c\nd"},
+		{"close comment at end", "/* a\nb\nc", "/* a\nb\nc */ d"},
+		{"open comment before end", "a\nb c", "a\nb /*c"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			const uri = "file:///a.txt"
+			v := NewViewport(legend, lexComments)
+			v.Interval = 1
+			v.Full(uri, tc.old)
+			if got, want := v.Range(uri, tc.text, all), lexAll(legend, tc.text).Range(all); !slices.Equal(got, want) {
+				t.Errorf("Range after editing %q to %q = %v, want %v", tc.old, tc.text, got, want)
+			}
+			// The states kept hold for a range lexed from them after the
+			// next edit.
+			next := tc.text + "\nz */ y"
+			last := protocol.Range{Start: protocol.Position{Line: uint32(position.NewIndex(next).Lines() - 1)}, End: all.End}
+			if got, want := v.Range(uri, next, last), lexAll(legend, next).Range(last); !slices.Equal(got, want) {
+				t.Errorf("Range(%v) after editing %q to %q = %v, want %v", last, tc.text, next, got, want)
+			}
+		})
+	}
+}
+
+// lexAll lexes the whole of text into a Builder.
+func lexAll(legend *Legend, text string) *Builder {
+	v := NewViewport(legend, lexComments)
+	doc := v.document("file:///all.txt", text)
+	b := NewBuilder(legend)
+	v.lexLines(doc, 0, doc.index.Lines()-1, b)
+	return b
+}
+
+func TestViewportRange(t *testing.T) {
+	legend := NewLegend(StandardTypes, nil)
+	const text = "a /* b\nc\nd */ e\nf\n/* g\nh"
+	all := lexAll(legend, text)
+	v := NewViewport(legend, lexComments)
+	v.Interval = 2
+	// From the bottom up, so each range starts past the states kept.
+	for first := 7; first >= 0; first-- {
+		r := protocol.Range{Start: protocol.Position{Line: uint32(first)}, End: protocol.Position{Line: uint32(first) + 1}}
+		if got, want := v.Range("file:///a.txt", text, r), all.Range(r); !slices.Equal(got, want) {
+			t.Errorf("Range(%v) = %v, want %v", r, got, want)
+		}
+	}
+}
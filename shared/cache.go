@@ -0,0 +1,104 @@
+package shared
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// ContentKey identifies a document's content independently of which
+// connection holds it, so two editors with the same buffer share one
+// analysis.
+type ContentKey struct {
+	URI protocol.DocumentURI
+	Sum [sha256.Size]byte
+}
+
+// KeyOf builds the ContentKey of a document.
+func KeyOf(doc Document) ContentKey {
+	return ContentKey{URI: doc.URI, Sum: sha256.Sum256([]byte(doc.Content))}
+}
+
+// Cache memoises analysis results across connections. Concurrent Gets for
+// the same key share one computation. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	// Max bounds the number of results kept, evicting the least recently
+	// used; zero is unbounded.
+	Max int
+
+	mu      sync.Mutex
+	entries map[K]*cacheEntry[V]
+	tick    uint64
+}
+
+type cacheEntry[V any] struct {
+	ready chan struct{}
+	value V
+	err   error
+	used  uint64
+}
+
+// Get returns the cached result for key, computing it if needed. Failed
+// computations are not cached.
+func (c *Cache[K, V]) Get(key K, compute func() (V, error)) (V, error) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = map[K]*cacheEntry[V]{}
+	}
+	c.tick++
+	if entry, ok := c.entries[key]; ok {
+		entry.used = c.tick
+		c.mu.Unlock()
+		<-entry.ready
+		return entry.value, entry.err
+	}
+	entry := &cacheEntry[V]{ready: make(chan struct{}), used: c.tick}
+	c.entries[key] = entry
+	c.evict()
+	c.mu.Unlock()
+
+	entry.value, entry.err = compute()
+	close(entry.ready)
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	return entry.value, entry.err
+}
+
+// Forget drops a cached result.
+func (c *Cache[K, V]) Forget(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// ForgetFunc drops every cached result whose key matches, e.g. all results
+// for a URI.
+func (c *Cache[K, V]) ForgetFunc(match func(K) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if match(key) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *Cache[K, V]) evict() {
+	for c.Max > 0 && len(c.entries) > c.Max {
+		var oldest K
+		var oldestUse uint64
+		first := true
+		for key, entry := range c.entries {
+			if first || entry.used < oldestUse {
+				oldest, oldestUse, first = key, entry.used, false
+			}
+		}
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,78 @@
+// Package shared holds the state one server process shares between several
+// client connections, as when editors attach to a single server over TCP:
+// file contents read from disk and analysis results are computed once, while
+// each connection keeps its own overlay of unsaved documents on top.
+package shared
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/ctxfs"
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/textenc"
+)
+
+// Files caches decoded file contents read from disk. It is safe for
+// concurrent use.
+type Files struct {
+	// Case controls URI comparison.
+	Case fscase.Sensitivity
+
+	// Timeout bounds each read, as ctxfs.FS.Timeout does, so a request
+	// reading a file on a hung mount fails rather than waiting on it. None
+	// when zero.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	files map[string]*textenc.Text
+}
+
+// Read returns a file's disk content, reading it on first use.
+func (f *Files) Read(ctx context.Context, uri protocol.DocumentURI) (*textenc.Text, error) {
+	key := f.Case.URIKey(string(uri))
+	f.mu.Lock()
+	text, ok := f.files[key]
+	f.mu.Unlock()
+	if ok {
+		return text, nil
+	}
+
+	path, err := uri.Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ctxfs.FS{Timeout: f.Timeout}.ReadFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if text, err = textenc.Decode(data); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	if f.files == nil {
+		f.files = map[string]*textenc.Text{}
+	}
+	f.files[key] = text
+	f.mu.Unlock()
+	return text, nil
+}
+
+// Invalidate forgets files changed on disk, e.g. on didChangeWatchedFiles or
+// didSave from any connection.
+func (f *Files) Invalidate(uris ...protocol.DocumentURI) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, uri := range uris {
+		delete(f.files, f.Case.URIKey(string(uri)))
+	}
+}
+
+// InvalidateAll forgets every file.
+func (f *Files) InvalidateAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files = nil
+}
@@ -0,0 +1,77 @@
+package shared
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Document is a document's content as one connection sees it.
+type Document struct {
+	URI     protocol.DocumentURI
+	Content string
+
+	// Version is the client's version for open documents.
+	Version int32
+
+	// Open reports that the content comes from the connection's overlay
+	// rather than disk.
+	Open bool
+}
+
+// Overlay is one connection's view of the workspace: the documents it has
+// open, over the shared disk contents. It is safe for concurrent use.
+type Overlay struct {
+	files *Files
+
+	mu   sync.Mutex
+	docs map[string]Document
+}
+
+// NewOverlay creates a connection's overlay over shared files.
+func NewOverlay(files *Files) *Overlay {
+	return &Overlay{files: files, docs: map[string]Document{}}
+}
+
+// Set records an open document's content, on didOpen and didChange.
+func (o *Overlay) Set(uri protocol.DocumentURI, version int32, content string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.docs[o.files.Case.URIKey(string(uri))] = Document{URI: uri, Content: content, Version: version, Open: true}
+}
+
+// Close drops an open document, on didClose; reads see disk content again.
+func (o *Overlay) Close(uri protocol.DocumentURI) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.docs, o.files.Case.URIKey(string(uri)))
+}
+
+// Read returns the document as this connection sees it.
+func (o *Overlay) Read(ctx context.Context, uri protocol.DocumentURI) (Document, error) {
+	o.mu.Lock()
+	doc, ok := o.docs[o.files.Case.URIKey(string(uri))]
+	o.mu.Unlock()
+	if ok {
+		return doc, nil
+	}
+	text, err := o.files.Read(ctx, uri)
+	if err != nil {
+		return Document{}, err
+	}
+	return Document{URI: uri, Content: text.Content}, nil
+}
+
+// Open lists the connection's open documents by URI.
+func (o *Overlay) Open() []Document {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	docs := make([]Document, 0, len(o.docs))
+	for _, doc := range o.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].URI < docs[j].URI })
+	return docs
+}
@@ -0,0 +1,206 @@
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of Metrics' latency
+// histogram unless it sets its own.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// otherMethod labels the methods past MaxMethods.
+const otherMethod = "other"
+
+// Metrics is an Instrument counting the messages of the connections it is
+// set on, which may be many: messages by method, requests answered with an
+// error by method and code, handling latency as a histogram by method, the
+// messages being handled, and the bytes read and written. It is an
+// http.Handler serving them in the Prometheus text format, for a scraper or
+// a Prometheus client's collector to read. The zero value is ready to use
+// and it is safe for concurrent use.
+type Metrics struct {
+	// Namespace prefixes the metrics' names, "jsonrpc" when empty.
+	Namespace string
+
+	// Buckets are the latency histogram's upper bounds in seconds,
+	// ascending, DefaultBuckets when nil.
+	Buckets []float64
+
+	// MaxMethods bounds the methods counted apart, 200 when zero, so a
+	// peer sending made-up methods can't grow the metrics without end;
+	// further methods are counted together as "other".
+	MaxMethods int
+
+	Clock clock.Clock
+
+	inFlight      atomic.Int64
+	read, written atomic.Uint64
+
+	mu      sync.Mutex
+	methods map[string]*methodMetrics
+}
+
+type methodMetrics struct {
+	requests, notifications uint64
+	errors                  map[int64]uint64
+	// buckets counts the latencies up to each bound, and the last those
+	// above all of them.
+	buckets []uint64
+	seconds float64
+}
+
+var _ jsonrpc2.Instrument = (*Metrics)(nil)
+
+func (m *Metrics) Start(ctx context.Context, req *jsonrpc2.Request) (context.Context, func(err *jsonrpc2.Error)) {
+	m.inFlight.Add(1)
+	clk := clock.Or(m.Clock)
+	start := clk.Now()
+	return ctx, func(err *jsonrpc2.Error) {
+		m.inFlight.Add(-1)
+		seconds := clk.Now().Sub(start).Seconds()
+		bounds := m.bounds()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		mm := m.method(req.Method, len(bounds))
+		if req.IsNotification() {
+			mm.notifications++
+		} else {
+			mm.requests++
+		}
+		if err != nil {
+			mm.errors[err.Code]++
+		}
+		mm.buckets[sort.SearchFloat64s(bounds, seconds)]++
+		mm.seconds += seconds
+	}
+}
+
+func (m *Metrics) Read(n int)  { m.read.Add(uint64(n)) }
+func (m *Metrics) Wrote(n int) { m.written.Add(uint64(n)) }
+
+func (m *Metrics) bounds() []float64 {
+	if m.Buckets == nil {
+		return DefaultBuckets
+	}
+	return m.Buckets
+}
+
+// method is the counters of method, with mu held.
+func (m *Metrics) method(method string, bounds int) *methodMetrics {
+	if m.methods == nil {
+		m.methods = map[string]*methodMetrics{}
+	}
+	mm, ok := m.methods[method]
+	if ok {
+		return mm
+	}
+	limit := m.MaxMethods
+	if limit <= 0 {
+		limit = 200
+	}
+	if len(m.methods) >= limit {
+		method = otherMethod
+		if mm, ok := m.methods[method]; ok {
+			return mm
+		}
+	}
+	mm = &methodMetrics{errors: map[int64]uint64{}, buckets: make([]uint64, bounds+1)}
+	m.methods[method] = mm
+	return mm
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.WritePrometheus(w)
+}
+
+// WritePrometheus writes the metrics to w in the Prometheus text format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	ns := m.Namespace
+	if ns == "" {
+		ns = "jsonrpc"
+	}
+	bounds := m.bounds()
+	b := bufio.NewWriter(w)
+	family := func(name, kind, help string) string {
+		name = ns + "_" + name
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		return name
+	}
+
+	m.mu.Lock()
+	names := make([]string, 0, len(m.methods))
+	for method := range m.methods {
+		names = append(names, method)
+	}
+	slices.Sort(names)
+
+	name := family("messages_total", "counter", "Messages handled, by method and kind.")
+	for _, method := range names {
+		mm := m.methods[method]
+		if mm.requests > 0 {
+			fmt.Fprintf(b, "%s{method=%s,kind=\"request\"} %d\n", name, label(method), mm.requests)
+		}
+		if mm.notifications > 0 {
+			fmt.Fprintf(b, "%s{method=%s,kind=\"notification\"} %d\n", name, label(method), mm.notifications)
+		}
+	}
+	name = family("errors_total", "counter", "Requests answered with an error, by method and error code.")
+	for _, method := range names {
+		mm := m.methods[method]
+		codes := make([]int64, 0, len(mm.errors))
+		for code := range mm.errors {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		for _, code := range codes {
+			fmt.Fprintf(b, "%s{method=%s,code=\"%d\"} %d\n", name, label(method), code, mm.errors[code])
+		}
+	}
+	name = family("handler_duration_seconds", "histogram", "Time spent handling messages, by method.")
+	for _, method := range names {
+		mm := m.methods[method]
+		var cumulative uint64
+		for i, bound := range bounds {
+			if i < len(mm.buckets)-1 {
+				cumulative += mm.buckets[i]
+			}
+			fmt.Fprintf(b, "%s_bucket{method=%s,le=\"%s\"} %d\n", name, label(method), strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		count := mm.requests + mm.notifications
+		fmt.Fprintf(b, "%s_bucket{method=%s,le=\"+Inf\"} %d\n", name, label(method), count)
+		fmt.Fprintf(b, "%s_sum{method=%s} %s\n", name, label(method), strconv.FormatFloat(mm.seconds, 'g', -1, 64))
+		fmt.Fprintf(b, "%s_count{method=%s} %d\n", name, label(method), count)
+	}
+	m.mu.Unlock()
+
+	name = family("in_flight", "gauge", "Messages being handled.")
+	fmt.Fprintf(b, "%s %d\n", name, m.inFlight.Load())
+	name = family("read_bytes_total", "counter", "Bytes of the messages read.")
+	fmt.Fprintf(b, "%s %d\n", name, m.read.Load())
+	name = family("written_bytes_total", "counter", "Bytes of the messages written.")
+	fmt.Fprintf(b, "%s %d\n", name, m.written.Load())
+	return b.Flush()
+}
+
+// label quotes a label value as the text format does.
+func label(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,46 @@
+// Package telemetry instruments JSON-RPC connections, for language servers
+// run as long-lived remote services: Metrics counts messages, errors by
+// code, latency, requests in flight and bytes transferred, and serves them
+// in the Prometheus text format, and Tracing starts a span per message
+// through whatever tracer the service uses, such as OpenTelemetry's, which
+// the handler's context carries. Set one, or several with Join, as
+// jsonrpc2.Options.Instrument.
+package telemetry
+
+import (
+	"context"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// Join returns an Instrument telling each of instruments, in order; the
+// contexts they return are chained, so a handler's carries each one's.
+func Join(instruments ...jsonrpc2.Instrument) jsonrpc2.Instrument {
+	return joined(instruments)
+}
+
+type joined []jsonrpc2.Instrument
+
+func (j joined) Start(ctx context.Context, req *jsonrpc2.Request) (context.Context, func(err *jsonrpc2.Error)) {
+	ends := make([]func(err *jsonrpc2.Error), len(j))
+	for i, instrument := range j {
+		ctx, ends[i] = instrument.Start(ctx, req)
+	}
+	return ctx, func(err *jsonrpc2.Error) {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](err)
+		}
+	}
+}
+
+func (j joined) Read(n int) {
+	for _, instrument := range j {
+		instrument.Read(n)
+	}
+}
+
+func (j joined) Wrote(n int) {
+	for _, instrument := range j {
+		instrument.Wrote(n)
+	}
+}
@@ -0,0 +1,63 @@
+package telemetry
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pentops/lsplib/jsonrpc2"
+)
+
+// Attribute is a span attribute, named as OpenTelemetry's RPC semantic
+// conventions name them.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Tracing is an Instrument starting a span for each message handled, named
+// by its method, through StartSpan, which adapts the service's tracer.
+// With OpenTelemetry's:
+//
+//	tracing := &telemetry.Tracing{StartSpan: func(ctx context.Context, name string, attrs []telemetry.Attribute) (context.Context, func([]telemetry.Attribute)) {
+//		ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(kv(attrs)...))
+//		return ctx, func(attrs []telemetry.Attribute) {
+//			span.SetAttributes(kv(attrs)...)
+//			span.End()
+//		}
+//	}}
+//
+// The handler's context carries the span, so spans the handler starts are
+// its children. Without StartSpan it does nothing.
+type Tracing struct {
+	// StartSpan starts a span with attrs and returns the context carrying
+	// it and the function ending it, which is given the attributes known
+	// only once the message has been handled: the error's code and message
+	// for a request answered with one.
+	StartSpan func(ctx context.Context, name string, attrs []Attribute) (context.Context, func(attrs []Attribute))
+}
+
+var _ jsonrpc2.Instrument = (*Tracing)(nil)
+
+func (t *Tracing) Start(ctx context.Context, req *jsonrpc2.Request) (context.Context, func(err *jsonrpc2.Error)) {
+	if t.StartSpan == nil {
+		return ctx, func(*jsonrpc2.Error) {}
+	}
+	attrs := []Attribute{{"rpc.system", "jsonrpc"}, {"rpc.method", req.Method}}
+	if !req.IsNotification() {
+		attrs = append(attrs, Attribute{"rpc.jsonrpc.request_id", string(req.ID)})
+	}
+	ctx, end := t.StartSpan(ctx, req.Method, attrs)
+	return ctx, func(err *jsonrpc2.Error) {
+		var attrs []Attribute
+		if err != nil {
+			attrs = []Attribute{
+				{"rpc.jsonrpc.error_code", strconv.FormatInt(err.Code, 10)},
+				{"rpc.jsonrpc.error_message", err.Message},
+			}
+		}
+		end(attrs)
+	}
+}
+
+func (t *Tracing) Read(int)  {}
+func (t *Tracing) Wrote(int) {}
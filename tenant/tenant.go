@@ -0,0 +1,266 @@
+// Package tenant runs one long-lived server process for many independent
+// workspaces, as cloud IDE backends do. A Pool holds each tenant's state —
+// its document store, caches and configuration — apart from every other's,
+// creates it when the tenant's first session initializes and closes it when
+// the last one leaves, and enforces per-tenant quotas.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/lsp"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// ErrQuota is returned, wrapped, when a quota refuses a session or request.
+var ErrQuota = errors.New("tenant: quota exceeded")
+
+// Quota limits what tenants may use. Zero fields are unlimited.
+type Quota struct {
+	// Tenants bounds how many tenants the pool holds at once.
+	Tenants int
+
+	// Sessions bounds the connections of one tenant.
+	Sessions int
+
+	// Inflight bounds the requests one tenant's sessions have running at
+	// once; more fail with lsp.CodeRequestFailed. Notifications are not
+	// counted, as dropping a document change would corrupt the tenant's
+	// state.
+	Inflight int
+}
+
+// KeyFunc chooses the tenant of a session at initialize. ctx carries
+// lsp.Subject when the server authenticates clients.
+type KeyFunc func(ctx context.Context, params *protocol.InitializeParams) string
+
+// WorkspaceKey is a KeyFunc making each workspace root a tenant: the first
+// workspace folder, or the root URI of clients which send no folders.
+func WorkspaceKey(ctx context.Context, params *protocol.InitializeParams) string {
+	if len(params.WorkspaceFolders) > 0 {
+		return string(params.WorkspaceFolders[0].URI)
+	}
+	if params.RootURI != nil {
+		return string(*params.RootURI)
+	}
+	return ""
+}
+
+// Pool holds tenants' state of type T. Configure it before the first
+// session attaches; it is safe for concurrent use after that.
+type Pool[T any] struct {
+	// New creates a tenant's state on its first session.
+	New func(ctx context.Context, key string) (T, error)
+
+	// Close, when set, releases a tenant's state once its last session has
+	// gone and Linger has passed without another joining. New and Close run
+	// with the pool locked and must not call back into it.
+	Close  func(key string, state T)
+	Linger time.Duration
+
+	Quota Quota
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	tenants map[string]*tenant[T]
+}
+
+type tenant[T any] struct {
+	key      string
+	state    T
+	sessions int
+	inflight int
+	linger   clock.Timer
+}
+
+// Stats describes one tenant's use.
+type Stats struct {
+	Key      string
+	Sessions int
+	Inflight int
+}
+
+type stateKey struct{}
+
+// From returns the state of the tenant whose session a handler's ctx
+// belongs to.
+func From[T any](ctx context.Context) (T, bool) {
+	t, ok := ctx.Value(stateKey{}).(*tenant[T])
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return t.state, true
+}
+
+// Attach makes the server built with opts a session of the tenant key
+// chooses, joined at initialize; an initialize the quota refuses fails.
+// Every message after it carries the tenant's state for From, and the
+// session leaves the tenant when it disconnects.
+func (p *Pool[T]) Attach(opts *lsp.Options, key KeyFunc) {
+	var (
+		mu     sync.Mutex
+		joined *tenant[T]
+	)
+	current := func() *tenant[T] {
+		mu.Lock()
+		defer mu.Unlock()
+		return joined
+	}
+
+	onInitialize := opts.OnInitialize
+	opts.OnInitialize = func(ctx context.Context, params *protocol.InitializeParams, result *protocol.InitializeResult) error {
+		t, err := p.join(ctx, key(ctx, params))
+		if err != nil {
+			return err
+		}
+		if onInitialize != nil {
+			if err := onInitialize(context.WithValue(ctx, stateKey{}, t), params, result); err != nil {
+				p.leave(t)
+				return err
+			}
+		}
+		mu.Lock()
+		joined = t
+		mu.Unlock()
+		return nil
+	}
+
+	onDisconnect := opts.OnDisconnect
+	opts.OnDisconnect = func() {
+		if t := current(); t != nil {
+			p.leave(t)
+		}
+		if onDisconnect != nil {
+			onDisconnect()
+		}
+	}
+
+	mw := func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			t := current()
+			if t == nil {
+				return next.Handle(ctx, req)
+			}
+			ctx = context.WithValue(ctx, stateKey{}, t)
+			if req.IsNotification() {
+				return next.Handle(ctx, req)
+			}
+			if !p.start(t) {
+				return nil, jsonrpc2.NewError(lsp.CodeRequestFailed, "%s: %v: too many requests in flight", req.Method, ErrQuota)
+			}
+			defer p.finish(t)
+			return next.Handle(ctx, req)
+		})
+	}
+	opts.Middleware = append([]jsonrpc2.Middleware{mw}, opts.Middleware...)
+}
+
+// join adds a session to a tenant, creating it if need be.
+func (p *Pool[T]) join(ctx context.Context, key string) (*tenant[T], error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tenants == nil {
+		p.tenants = map[string]*tenant[T]{}
+	}
+	t, ok := p.tenants[key]
+	if !ok {
+		if p.Quota.Tenants > 0 && len(p.tenants) >= p.Quota.Tenants && !p.evictLingering() {
+			return nil, jsonrpc2.NewError(lsp.CodeRequestFailed, "%v: the server holds too many workspaces", ErrQuota)
+		}
+		// New should be quick, deferring heavy work to warmup: it holds the
+		// lock, so two first sessions can't both create the tenant.
+		state, err := p.New(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		t = &tenant[T]{key: key, state: state}
+		p.tenants[key] = t
+	}
+	if p.Quota.Sessions > 0 && t.sessions >= p.Quota.Sessions {
+		return nil, jsonrpc2.NewError(lsp.CodeRequestFailed, "%v: too many sessions for this workspace", ErrQuota)
+	}
+	if t.linger != nil {
+		t.linger.Stop()
+		t.linger = nil
+	}
+	t.sessions++
+	return t, nil
+}
+
+// leave removes a session, closing the tenant after Linger if it was the
+// last.
+func (p *Pool[T]) leave(t *tenant[T]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.sessions--
+	if t.sessions > 0 {
+		return
+	}
+	if p.Linger <= 0 {
+		p.remove(t)
+		return
+	}
+	t.linger = clock.Or(p.Clock).AfterFunc(p.Linger, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if t.sessions == 0 && p.tenants[t.key] == t {
+			p.remove(t)
+		}
+	})
+}
+
+// evictLingering closes one tenant which has no sessions left, to make room
+// for a new one, reporting whether there was one.
+func (p *Pool[T]) evictLingering() bool {
+	for _, t := range p.tenants {
+		if t.sessions == 0 {
+			t.linger.Stop()
+			p.remove(t)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool[T]) remove(t *tenant[T]) {
+	delete(p.tenants, t.key)
+	t.linger = nil
+	if p.Close != nil {
+		p.Close(t.key, t.state)
+	}
+}
+
+func (p *Pool[T]) start(t *tenant[T]) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Quota.Inflight > 0 && t.inflight >= p.Quota.Inflight {
+		return false
+	}
+	t.inflight++
+	return true
+}
+
+func (p *Pool[T]) finish(t *tenant[T]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.inflight--
+}
+
+// Tenants reports the tenants held, by key.
+func (p *Pool[T]) Tenants() []Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]Stats, 0, len(p.tenants))
+	for _, t := range p.tenants {
+		stats = append(stats, Stats{Key: t.key, Sessions: t.sessions, Inflight: t.inflight})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+	return stats
+}
@@ -0,0 +1,90 @@
+package textedit
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pentops/lsplib/internal/diff"
+	"github.com/pentops/lsplib/markup"
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// FileDiff is what a WorkspaceEdit would do to one document, as a unified
+// diff.
+type FileDiff struct {
+	URI  protocol.DocumentURI
+	Diff string
+}
+
+// PreviewWorkspaceEdit returns the unified diff of every document edit
+// changes, in URI order, without applying anything, so a command can show
+// the user what it is about to do before sending workspace/applyEdit.
+// content returns a document's current text, such as from a textsync.Store
+// for open documents and from disk for the others. Edits are read as the
+// client reads them, documentChanges in place of changes when both are
+// set, and documents they leave unchanged are left out.
+func PreviewWorkspaceEdit(edit *protocol.WorkspaceEdit, content func(protocol.DocumentURI) (string, error), enc position.Encoding) ([]FileDiff, error) {
+	if edit == nil {
+		return nil, nil
+	}
+	var order []protocol.DocumentURI
+	edits := map[protocol.DocumentURI][][]protocol.TextEdit{}
+	if len(edit.DocumentChanges) > 0 {
+		for _, doc := range edit.DocumentChanges {
+			uri := doc.TextDocument.URI
+			if _, ok := edits[uri]; !ok {
+				order = append(order, uri)
+			}
+			edits[uri] = append(edits[uri], doc.Edits)
+		}
+	} else {
+		for uri, es := range edit.Changes {
+			order = append(order, uri)
+			edits[uri] = [][]protocol.TextEdit{es}
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+
+	var out []FileDiff
+	for _, uri := range order {
+		old, err := content(uri)
+		if err != nil {
+			return nil, fmt.Errorf("textedit: reading %s: %w", uri, err)
+		}
+		// A document's TextDocumentEdits apply one after another, each to
+		// the text the one before left.
+		text := old
+		for _, es := range edits[uri] {
+			if text, err = Apply(text, es, enc); err != nil {
+				return nil, fmt.Errorf("%w in %s", err, uri)
+			}
+		}
+		name := previewName(uri)
+		if d := diff.Unified(name, name, old, text); d != "" {
+			out = append(out, FileDiff{URI: uri, Diff: d})
+		}
+	}
+	return out, nil
+}
+
+// previewName is how a diff names the document at uri: its path for a
+// file, the URI itself otherwise.
+func previewName(uri protocol.DocumentURI) string {
+	if path, err := uri.Path(); err == nil {
+		return path
+	}
+	return string(uri)
+}
+
+// PreviewMarkdown renders diffs as markdown, a diff block per document
+// under its name, for a hover, a message or a document opened with
+// window/showDocument.
+func PreviewMarkdown(diffs []FileDiff) string {
+	b := markup.New(protocol.Markdown)
+	for _, d := range diffs {
+		b.Markdown("**" + markup.Escape(previewName(d.URI)) + "**")
+		b.Code("diff", d.Diff)
+	}
+	return b.String()
+}
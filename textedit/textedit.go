@@ -0,0 +1,80 @@
+// Package textedit applies TextEdits to document text and assembles
+// WorkspaceEdits, for formatting, rename and code action providers. Edits
+// are ordered and checked the way clients apply them: all ranges refer to
+// the original text, inserts at one position keep their order, and no two
+// edits may overlap. PreviewWorkspaceEdit shows what an edit would do as
+// unified diffs, without applying it.
+package textedit
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// OverlapError reports two edits whose ranges overlap, which clients
+// refuse to apply.
+type OverlapError struct {
+	First, Second protocol.TextEdit
+}
+
+func (e *OverlapError) Error() string {
+	return fmt.Sprintf("textedit: edit at %s overlaps edit at %s", formatRange(e.Second.Range), formatRange(e.First.Range))
+}
+
+func formatRange(r protocol.Range) string {
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character)
+}
+
+// Sort returns edits ordered by position, inserts at one position in their
+// given order and before a range starting there, and fails with
+// *OverlapError if any two overlap or with an error if a range ends before
+// it starts. Touching ranges don't overlap.
+func Sort(edits []protocol.TextEdit) ([]protocol.TextEdit, error) {
+	sorted := append([]protocol.TextEdit{}, edits...)
+	for _, e := range sorted {
+		if before(e.Range.End, e.Range.Start) {
+			return nil, fmt.Errorf("textedit: range %s ends before it starts", formatRange(e.Range))
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Range, sorted[j].Range
+		return before(a.Start, b.Start) || a.Start == b.Start && before(a.End, b.End)
+	})
+	for idx := 1; idx < len(sorted); idx++ {
+		prev, next := sorted[idx-1], sorted[idx]
+		if before(next.Range.Start, prev.Range.End) {
+			return nil, &OverlapError{First: prev, Second: next}
+		}
+	}
+	return sorted, nil
+}
+
+// Apply returns text with edits applied, their characters counted in enc.
+// Positions past the end of a line or of the text are clamped to it.
+func Apply(text string, edits []protocol.TextEdit, enc position.Encoding) (string, error) {
+	sorted, err := Sort(edits)
+	if err != nil {
+		return "", err
+	}
+	ix := position.NewIndex(text)
+	var out []byte
+	last := 0
+	for _, e := range sorted {
+		start, end := ix.RangeToOffsets(e.Range, enc)
+		// Clamping can pull a start back before the previous edit's end on a
+		// line shorter than the edits claim.
+		start, end = max(start, last), max(end, last)
+		out = append(out, text[last:start]...)
+		out = append(out, e.NewText...)
+		last = end
+	}
+	out = append(out, text[last:]...)
+	return string(out), nil
+}
+
+func before(a, b protocol.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
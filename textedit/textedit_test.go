@@ -0,0 +1,111 @@
+package textedit
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+func edit(startLine, startChar, endLine, endChar uint32, newText string) protocol.TextEdit {
+	return protocol.TextEdit{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: startLine, Character: startChar},
+			End:   protocol.Position{Line: endLine, Character: endChar},
+		},
+		NewText: newText,
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		text  string
+		edits []protocol.TextEdit
+		enc   position.Encoding
+		want  string
+	}{
+		{"none", "abc", nil, position.UTF16, "abc"},
+		{"inserts at one position", "ab", []protocol.TextEdit{edit(0, 1, 0, 1, "x"), edit(0, 1, 0, 1, "y"), edit(0, 1, 0, 1, "z")}, position.UTF16, "axyzb"},
+		{"touching ranges", "abc", []protocol.TextEdit{edit(0, 0, 0, 1, "A"), edit(0, 1, 0, 2, "B")}, position.UTF16, "ABc"},
+		{"insert at the start of a range", "abcd", []protocol.TextEdit{edit(0, 1, 0, 3, "XY"), edit(0, 1, 0, 1, "i")}, position.UTF16, "aiXYd"},
+		{"insert at the end of a range", "abcd", []protocol.TextEdit{edit(0, 3, 0, 3, "i"), edit(0, 1, 0, 3, "XY")}, position.UTF16, "aXYid"},
+		{"across lines", "ab\ncd\nef", []protocol.TextEdit{edit(0, 1, 2, 1, "-"), edit(2, 2, 2, 2, "!")}, position.UTF16, "a-f!"},
+		{"past line ends", "ab\ncd", []protocol.TextEdit{edit(0, 5, 0, 6, "x"), edit(0, 7, 0, 9, "y")}, position.UTF16, "abxy\ncd"},
+		{"at eof", "ab", []protocol.TextEdit{edit(0, 2, 0, 2, "c"), edit(3, 0, 3, 0, "d")}, position.UTF16, "abcd"},
+		{"utf-16 after pair", "😀a😀b", []protocol.TextEdit{edit(0, 2, 0, 3, "A"), edit(0, 5, 0, 6, "B")}, position.UTF16, "😀A😀B"},
+		{"utf-8 after pair", "😀a😀b", []protocol.TextEdit{edit(0, 4, 0, 5, "A"), edit(0, 9, 0, 10, "B")}, position.UTF8, "😀A😀B"},
+		{"utf-32 after pair", "😀a😀b", []protocol.TextEdit{edit(0, 1, 0, 2, "A"), edit(0, 3, 0, 4, "B")}, position.UTF32, "😀A😀B"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply(tc.text, tc.edits, tc.enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Apply(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+			// All ranges are of the original text, so their order doesn't
+			// matter unless they insert at one position.
+			reversed := slices.Clone(tc.edits)
+			slices.Reverse(reversed)
+			if !sameInserts(tc.edits) {
+				if got, err := Apply(tc.text, reversed, tc.enc); err != nil || got != tc.want {
+					t.Errorf("Apply(%q) in reverse = %q, %v, want %q", tc.text, got, err, tc.want)
+				}
+			}
+		})
+	}
+}
+
+// sameInserts reports whether two of edits insert at one position.
+func sameInserts(edits []protocol.TextEdit) bool {
+	seen := map[protocol.Position]bool{}
+	for _, e := range edits {
+		if e.Range.Start == e.Range.End {
+			if seen[e.Range.Start] {
+				return true
+			}
+			seen[e.Range.Start] = true
+		}
+	}
+	return false
+}
+
+func TestApplyReversedInserts(t *testing.T) {
+	edits := []protocol.TextEdit{edit(0, 1, 0, 1, "x"), edit(0, 1, 0, 1, "y")}
+	slices.Reverse(edits)
+	if got, err := Apply("ab", edits, position.UTF16); err != nil || got != "ayxb" {
+		t.Fatalf("Apply = %q, %v, want the inserts in their given order, %q", got, err, "ayxb")
+	}
+}
+
+func TestApplyOverlap(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		edits []protocol.TextEdit
+	}{
+		{"overlapping ranges", []protocol.TextEdit{edit(0, 0, 0, 2, "A"), edit(0, 1, 0, 3, "B")}},
+		{"enclosed range", []protocol.TextEdit{edit(0, 0, 1, 0, "A"), edit(0, 1, 0, 2, "B")}},
+		{"insert within a range", []protocol.TextEdit{edit(0, 2, 0, 2, "i"), edit(0, 1, 0, 3, "XY")}},
+		{"same range", []protocol.TextEdit{edit(0, 1, 0, 2, "A"), edit(0, 1, 0, 2, "B")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Apply("abcd\nef", tc.edits, position.UTF16)
+			var overlap *OverlapError
+			if !errors.As(err, &overlap) {
+				t.Fatalf("Apply = %v, want an *OverlapError", err)
+			}
+		})
+	}
+}
+
+func TestApplyBackwardsRange(t *testing.T) {
+	_, err := Apply("abcd", []protocol.TextEdit{edit(0, 3, 0, 1, "x")}, position.UTF16)
+	var overlap *OverlapError
+	if err == nil || errors.As(err, &overlap) {
+		t.Fatalf("Apply = %v, want an error for a range ending before it starts", err)
+	}
+}
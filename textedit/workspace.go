@@ -0,0 +1,78 @@
+package textedit
+
+import (
+	"fmt"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Builder assembles edits to several documents into one WorkspaceEdit.
+// The zero value is ready to use.
+type Builder struct {
+	// DocumentChanges emits documentChanges, whose versions make the client
+	// refuse edits to a document changed since they were computed, instead
+	// of the changes map. Set it from the client's
+	// workspace.workspaceEdit.documentChanges capability, as
+	// lsp.Server.ClientSupports reports it.
+	DocumentChanges bool
+
+	docs  []protocol.TextDocumentEdit
+	index map[protocol.DocumentURI]int
+}
+
+// Edit adds edits to the document at uri, which the edits were computed
+// against at version; nil for a document which isn't open. Edits to one
+// document accumulate, all against the same text, and the last version
+// given is kept.
+func (b *Builder) Edit(uri protocol.DocumentURI, version *int32, edits ...protocol.TextEdit) {
+	if b.index == nil {
+		b.index = map[protocol.DocumentURI]int{}
+	}
+	idx, ok := b.index[uri]
+	if !ok {
+		idx = len(b.docs)
+		b.index[uri] = idx
+		b.docs = append(b.docs, protocol.TextDocumentEdit{TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{URI: uri}})
+	}
+	doc := &b.docs[idx]
+	if version != nil {
+		v := *version
+		doc.TextDocument.Version = &v
+	}
+	doc.Edits = append(doc.Edits, edits...)
+}
+
+// Empty reports whether no edits were added.
+func (b *Builder) Empty() bool {
+	for _, doc := range b.docs {
+		if len(doc.Edits) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WorkspaceEdit returns the edit, each document's edits sorted. It fails
+// if any document's edits overlap.
+func (b *Builder) WorkspaceEdit() (*protocol.WorkspaceEdit, error) {
+	out := &protocol.WorkspaceEdit{}
+	for _, doc := range b.docs {
+		edits, err := Sort(doc.Edits)
+		if err != nil {
+			return nil, fmt.Errorf("%w in %s", err, doc.TextDocument.URI)
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		if !b.DocumentChanges {
+			if out.Changes == nil {
+				out.Changes = map[protocol.DocumentURI][]protocol.TextEdit{}
+			}
+			out.Changes[doc.TextDocument.URI] = edits
+			continue
+		}
+		doc.Edits = edits
+		out.DocumentChanges = append(out.DocumentChanges, doc)
+	}
+	return out, nil
+}
@@ -0,0 +1,247 @@
+// Package textenc reads source files in whatever encoding they were saved
+// with. Content is normalised to UTF-8 for the rest of the server, while the
+// original encoding is remembered so offsets and rewritten content can be
+// mapped back to the bytes on disk.
+//
+// Editors decode open documents themselves; this is for files the server
+// reads from disk, such as while indexing or resolving a definition in a file
+// that isn't open.
+package textenc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/pentops/lsplib/ctxfs"
+)
+
+// Encoding is a file's character encoding.
+type Encoding int
+
+const (
+	UTF8 Encoding = iota
+	UTF16LE
+	UTF16BE
+
+	// Latin1 is assumed for files which are not valid UTF-8 and show no sign
+	// of UTF-16. Every byte is a character, so decoding never fails and
+	// encoding round-trips.
+	Latin1
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case UTF16LE:
+		return "utf-16le"
+	case UTF16BE:
+		return "utf-16be"
+	case Latin1:
+		return "iso-8859-1"
+	default:
+		return "utf-8"
+	}
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// bom is the byte order mark for the encoding.
+func (e Encoding) bom() []byte {
+	switch e {
+	case UTF8:
+		return bomUTF8
+	case UTF16LE:
+		return bomUTF16LE
+	case UTF16BE:
+		return bomUTF16BE
+	default:
+		return nil
+	}
+}
+
+// Detect guesses the encoding of data from its byte order mark, or for
+// BOM-less UTF-16 from the pattern of zero bytes in ASCII-heavy text, and
+// reports whether data starts with a byte order mark.
+func Detect(data []byte) (enc Encoding, bom bool) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return UTF8, true
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return UTF16LE, true
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return UTF16BE, true
+	}
+	if enc, ok := guessUTF16(data); ok {
+		return enc, false
+	}
+	if utf8.Valid(data) {
+		return UTF8, false
+	}
+	return Latin1, false
+}
+
+// guessUTF16 looks for a zero byte in most odd (LE) or even (BE) positions of
+// the first kilobyte.
+func guessUTF16(data []byte) (Encoding, bool) {
+	sample := data[:min(len(data), 1024)]
+	if len(sample) < 4 || len(sample)%2 != 0 && len(sample) == len(data) {
+		return 0, false
+	}
+	var even, odd int
+	for idx, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if idx%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	pairs := len(sample) / 2
+	switch {
+	case odd > pairs*3/4 && even == 0:
+		return UTF16LE, true
+	case even > pairs*3/4 && odd == 0:
+		return UTF16BE, true
+	}
+	return 0, false
+}
+
+// Text is decoded file content.
+type Text struct {
+	// Content is the text as UTF-8, without any byte order mark.
+	Content  string
+	Encoding Encoding
+
+	// BOM records that the file started with a byte order mark, so it is
+	// written back with one.
+	BOM bool
+}
+
+// Decode detects the encoding of data and decodes it.
+func Decode(data []byte) (*Text, error) {
+	enc, _ := Detect(data)
+	return DecodeAs(data, enc)
+}
+
+// DecodeAs decodes data in a known encoding. A byte order mark matching the
+// encoding is skipped.
+func DecodeAs(data []byte, enc Encoding) (*Text, error) {
+	bom := enc.bom()
+	hasBOM := bom != nil && bytes.HasPrefix(data, bom)
+	text, err := decode(bytes.TrimPrefix(data, bom), enc)
+	if err != nil {
+		return nil, err
+	}
+	text.BOM = hasBOM
+	return text, nil
+}
+
+func decode(data []byte, enc Encoding) (*Text, error) {
+	switch enc {
+	case UTF8:
+		if !utf8.Valid(data) {
+			return nil, fmt.Errorf("textenc: invalid UTF-8")
+		}
+		return &Text{Content: string(data), Encoding: enc}, nil
+	case UTF16LE, UTF16BE:
+		if len(data)%2 != 0 {
+			return nil, fmt.Errorf("textenc: odd length for %s", enc)
+		}
+		var order binary.ByteOrder = binary.LittleEndian
+		if enc == UTF16BE {
+			order = binary.BigEndian
+		}
+		units := make([]uint16, len(data)/2)
+		for idx := range units {
+			units[idx] = order.Uint16(data[idx*2:])
+		}
+		return &Text{Content: string(utf16.Decode(units)), Encoding: enc}, nil
+	case Latin1:
+		runes := make([]rune, len(data))
+		for idx, b := range data {
+			runes[idx] = rune(b)
+		}
+		return &Text{Content: string(runes), Encoding: enc}, nil
+	}
+	return nil, fmt.Errorf("textenc: unknown encoding %d", enc)
+}
+
+// ReadFile reads and decodes a file, giving up as soon as ctx is done, even
+// while the read is blocked on a slow filesystem. ctxfs.FS.ReadFile and
+// Decode do the same with a timeout.
+func ReadFile(ctx context.Context, path string) (*Text, error) {
+	data, err := ctxfs.FS{}.ReadFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Encode converts UTF-8 content back to the text's original encoding,
+// including any byte order mark, for writing edited content to disk.
+// Characters Latin-1 can't represent are written as '?'.
+func (t *Text) Encode(content string) []byte {
+	bom := t.bom()
+	switch t.Encoding {
+	case UTF16LE, UTF16BE:
+		units := utf16.Encode([]rune(content))
+		out := make([]byte, len(bom), len(bom)+len(units)*2)
+		copy(out, bom)
+		for _, unit := range units {
+			if t.Encoding == UTF16LE {
+				out = binary.LittleEndian.AppendUint16(out, unit)
+			} else {
+				out = binary.BigEndian.AppendUint16(out, unit)
+			}
+		}
+		return out
+	case Latin1:
+		out := make([]byte, 0, len(content))
+		for _, r := range content {
+			if r > 0xFF {
+				r = '?'
+			}
+			out = append(out, byte(r))
+		}
+		return out
+	default:
+		out := make([]byte, 0, len(bom)+len(content))
+		out = append(out, bom...)
+		return append(out, content...)
+	}
+}
+
+// ByteOffset maps a byte offset in Content to the offset of the same point in
+// the original file, byte order mark included.
+func (t *Text) ByteOffset(offset int) int {
+	offset = max(0, min(offset, len(t.Content)))
+	base := len(t.bom())
+	switch t.Encoding {
+	case UTF16LE, UTF16BE:
+		n := 0
+		for _, r := range t.Content[:offset] {
+			n += 2 * utf16.RuneLen(r)
+		}
+		return base + n
+	case Latin1:
+		return utf8.RuneCountInString(t.Content[:offset])
+	default:
+		return base + offset
+	}
+}
+
+func (t *Text) bom() []byte {
+	if !t.BOM {
+		return nil
+	}
+	return t.Encoding.bom()
+}
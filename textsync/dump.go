@@ -0,0 +1,116 @@
+package textsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dumped is an entry of a dump's index.json.
+type dumped struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId,omitempty"`
+	Version    int32  `json:"version"`
+	Notebook   string `json:"notebook,omitempty"`
+	File       string `json:"file"`
+}
+
+// Dump writes the text of each open document, as the editor has it, saved
+// or not, to a new directory under dir, os.TempDir when empty, with an
+// index.json giving each file's URI and version, and returns the directory.
+// The buffers are the user's code, so only dump them when the user has
+// agreed to; the directory is readable by its owner alone.
+//
+// Dump is for reproducing crashes which depend on unsaved edits: call it
+// from middleware.RecoverOptions.OnPanic, or defer DumpOnPanic.
+func (s *Store) Dump(dir string) (string, error) {
+	// A panic while the Store was held would leave it locked, as its
+	// methods unlock without defer.
+	if !s.mu.TryRLock() {
+		return "", errors.New("textsync: dump: store is locked")
+	}
+	docs := make([]Document, 0, len(s.docs))
+	for _, doc := range s.docs {
+		docs = append(docs, doc)
+	}
+	s.mu.RUnlock()
+	sort.Slice(docs, func(i, j int) bool { return docs[i].URI < docs[j].URI })
+
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	out, err := os.MkdirTemp(dir, "unsaved-buffers-")
+	if err != nil {
+		return "", fmt.Errorf("textsync: dump: %w", err)
+	}
+	index := make([]dumped, len(docs))
+	for i, doc := range docs {
+		name := fmt.Sprintf("%03d-%s", i+1, fileName(string(doc.URI)))
+		if err := os.WriteFile(filepath.Join(out, name), []byte(doc.Text), 0o600); err != nil {
+			return out, fmt.Errorf("textsync: dump: %w", err)
+		}
+		index[i] = dumped{
+			URI:        string(doc.URI),
+			LanguageID: doc.LanguageID,
+			Version:    doc.Version,
+			Notebook:   string(doc.Notebook),
+			File:       name,
+		}
+	}
+	encoded, _ := json.MarshalIndent(index, "", "  ")
+	if err := os.WriteFile(filepath.Join(out, "index.json"), append(encoded, '\n'), 0o600); err != nil {
+		return out, fmt.Errorf("textsync: dump: %w", err)
+	}
+	return out, nil
+}
+
+// DumpOnPanic, deferred at the top of main and of each goroutine the
+// server starts, dumps the open documents to dir with Dump when the
+// goroutine panics, writes the directory to log, os.Stderr when nil, and
+// panics again with the same value: the process still crashes, and its
+// crash log names the directory just before the panic's trace.
+//
+//	defer store.DumpOnPanic("", nil)
+func (s *Store) DumpOnPanic(dir string, log io.Writer) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	if log == nil {
+		log = os.Stderr
+	}
+	out, err := s.Dump(dir)
+	if err != nil {
+		fmt.Fprintf(log, "%v\n", err)
+	} else {
+		fmt.Fprintf(log, "textsync: open documents saved to %s\n", out)
+	}
+	panic(value)
+}
+
+// fileName is a file name for a document, from the last element of its
+// URI, with characters file systems may refuse replaced.
+func fileName(uri string) string {
+	if i := strings.LastIndexAny(uri, "/\\:"); i >= 0 {
+		uri = uri[i+1:]
+	}
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, uri)
+	if name == "" || strings.Trim(name, ".") == "" {
+		return "document"
+	}
+	return name
+}
@@ -0,0 +1,251 @@
+package textsync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Notebook is an open notebook as of one version. The text of each cell is
+// in the Store as a document of its own, named by the cell's Document.
+type Notebook struct {
+	URI          protocol.URI
+	NotebookType string
+	Version      int32
+	Metadata     json.RawMessage
+	Cells        []protocol.NotebookCell
+}
+
+// NotebookCapability is the notebookDocumentSync server capability a Store
+// serves, for the notebooks selector selects.
+func NotebookCapability(selector ...protocol.NotebookSelector) protocol.NotebookDocumentSyncOptions {
+	return protocol.NotebookDocumentSyncOptions{NotebookSelector: selector, Save: true}
+}
+
+// GetNotebook returns the current structure of an open notebook.
+func (s *Store) GetNotebook(uri protocol.URI) (Notebook, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	book, ok := s.notebooks[s.key(protocol.DocumentURI(uri))]
+	return book, ok
+}
+
+// Cell returns the notebook whose cell document uri is, and the index of
+// the cell in it.
+func (s *Store) Cell(uri protocol.DocumentURI) (Notebook, int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	doc, ok := s.docs[s.key(uri)]
+	if !ok || doc.Notebook == "" {
+		return Notebook{}, 0, false
+	}
+	book, ok := s.notebooks[s.key(protocol.DocumentURI(doc.Notebook))]
+	if !ok {
+		return Notebook{}, 0, false
+	}
+	i := s.cellIndex(book.Cells, uri)
+	return book, i, i >= 0
+}
+
+// DidOpenNotebook handles notebookDocument/didOpen, opening the notebook's
+// cell documents with it. Opening a notebook which is already open
+// replaces it.
+func (s *Store) DidOpenNotebook(ctx context.Context, params *protocol.DidOpenNotebookDocumentParams) error {
+	nb := params.NotebookDocument
+	book := Notebook{URI: nb.URI, NotebookType: nb.NotebookType, Version: nb.Version, Metadata: nb.Metadata, Cells: nb.Cells}
+	events := make([]Event, 0, len(params.CellTextDocuments))
+	s.mu.Lock()
+	if s.docs == nil {
+		s.docs = map[string]Document{}
+	}
+	if s.notebooks == nil {
+		s.notebooks = map[string]Notebook{}
+	}
+	s.notebooks[s.key(protocol.DocumentURI(nb.URI))] = book
+	for _, item := range params.CellTextDocuments {
+		doc := cellDocument(item, nb.URI)
+		s.docs[s.key(item.URI)] = doc
+		events = append(events, Event{Opened, doc})
+	}
+	s.mu.Unlock()
+	for _, event := range events {
+		s.emit(ctx, event)
+	}
+	return nil
+}
+
+// DidChangeNotebook handles notebookDocument/didChange: it replaces the
+// notebook's metadata, splices its cells, opening and closing their
+// documents, updates cells' kind, metadata and execution summary, and
+// edits cells' text. Changes to a notebook which isn't open, or which
+// don't advance its version or that of a cell they edit, are rejected and
+// leave it and its cells untouched.
+func (s *Store) DidChangeNotebook(ctx context.Context, params *protocol.DidChangeNotebookDocumentParams) error {
+	events, err := s.changeNotebook(params)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		s.emit(ctx, event)
+	}
+	return nil
+}
+
+// changeNotebook applies a notebook change, returning the events of its
+// cell documents.
+func (s *Store) changeNotebook(params *protocol.DidChangeNotebookDocumentParams) ([]Event, error) {
+	uri := params.NotebookDocument.URI
+	key := s.key(protocol.DocumentURI(uri))
+	enc := s.encoding()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	book, ok := s.notebooks[key]
+	if !ok {
+		return nil, fmt.Errorf("textsync: change to notebook %s, which is not open", uri)
+	}
+	if params.NotebookDocument.Version <= book.Version {
+		return nil, fmt.Errorf("textsync: change to notebook %s at version %d, already at %d", uri, params.NotebookDocument.Version, book.Version)
+	}
+	change := params.Change
+	if change.Metadata != nil {
+		book.Metadata = change.Metadata
+	}
+
+	// The cells' documents as changed, or closed, by key, until all the
+	// changes have been checked.
+	changed := map[string]Document{}
+	closed := map[string]bool{}
+	get := func(uri protocol.DocumentURI) (Document, bool) {
+		key := s.key(uri)
+		if doc, ok := changed[key]; ok {
+			return doc, true
+		}
+		if closed[key] {
+			return Document{}, false
+		}
+		doc, ok := s.docs[key]
+		return doc, ok
+	}
+	var events []Event
+
+	if cells := change.Cells; cells != nil {
+		book.Cells = slices.Clone(book.Cells)
+		if st := cells.Structure; st != nil {
+			start, end := int(st.Array.Start), int(st.Array.Start)+int(st.Array.DeleteCount)
+			if end > len(book.Cells) {
+				return nil, fmt.Errorf("textsync: change to notebook %s replaces cells %d to %d of %d", uri, start, end, len(book.Cells))
+			}
+			book.Cells = slices.Concat(book.Cells[:start], st.Array.Cells, book.Cells[end:])
+			for _, id := range st.DidClose {
+				if doc, ok := get(id.URI); ok {
+					delete(changed, s.key(id.URI))
+					closed[s.key(id.URI)] = true
+					events = append(events, Event{Closed, doc})
+				}
+			}
+			for _, item := range st.DidOpen {
+				doc := cellDocument(item, uri)
+				changed[s.key(item.URI)] = doc
+				delete(closed, s.key(item.URI))
+				events = append(events, Event{Opened, doc})
+			}
+		}
+		for _, cell := range cells.Data {
+			i := s.cellIndex(book.Cells, cell.Document)
+			if i < 0 {
+				return nil, fmt.Errorf("textsync: change to notebook %s updates cell %s, which it doesn't have", uri, cell.Document)
+			}
+			book.Cells[i] = cell
+		}
+		for _, content := range cells.TextContent {
+			id := content.Document
+			doc, ok := get(id.URI)
+			if !ok {
+				return nil, fmt.Errorf("textsync: change to cell %s, which is not open", id.URI)
+			}
+			if id.Version <= doc.Version {
+				return nil, fmt.Errorf("textsync: change to cell %s at version %d, already at %d", id.URI, id.Version, doc.Version)
+			}
+			for _, c := range content.Changes {
+				doc.Text = Apply(doc.Text, c, enc)
+			}
+			doc.Version = id.Version
+			changed[s.key(id.URI)] = doc
+			events = append(events, Event{Changed, doc})
+		}
+	}
+
+	book.Version = params.NotebookDocument.Version
+	s.notebooks[key] = book
+	for key := range closed {
+		delete(s.docs, key)
+	}
+	for key, doc := range changed {
+		s.docs[key] = doc
+	}
+	return events, nil
+}
+
+// DidSaveNotebook handles notebookDocument/didSave, reporting each of the
+// notebook's cell documents saved.
+func (s *Store) DidSaveNotebook(ctx context.Context, params *protocol.DidSaveNotebookDocumentParams) error {
+	uri := params.NotebookDocument.URI
+	s.mu.RLock()
+	book, ok := s.notebooks[s.key(protocol.DocumentURI(uri))]
+	var events []Event
+	for _, cell := range book.Cells {
+		if doc, ok := s.docs[s.key(cell.Document)]; ok {
+			events = append(events, Event{Saved, doc})
+		}
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("textsync: save of notebook %s, which is not open", uri)
+	}
+	for _, event := range events {
+		s.emit(ctx, event)
+	}
+	return nil
+}
+
+// DidCloseNotebook handles notebookDocument/didClose, closing the
+// notebook's cell documents with it.
+func (s *Store) DidCloseNotebook(ctx context.Context, params *protocol.DidCloseNotebookDocumentParams) error {
+	uri := params.NotebookDocument.URI
+	key := s.key(protocol.DocumentURI(uri))
+	s.mu.Lock()
+	book, ok := s.notebooks[key]
+	delete(s.notebooks, key)
+	ids := params.CellTextDocuments
+	for _, cell := range book.Cells {
+		ids = append(ids, protocol.TextDocumentIdentifier{URI: cell.Document})
+	}
+	var events []Event
+	for _, id := range ids {
+		if doc, open := s.docs[s.key(id.URI)]; open && doc.Notebook == uri {
+			delete(s.docs, s.key(id.URI))
+			events = append(events, Event{Closed, doc})
+		}
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("textsync: close of notebook %s, which is not open", uri)
+	}
+	for _, event := range events {
+		s.emit(ctx, event)
+	}
+	return nil
+}
+
+// cellIndex is the index of the cell whose document is uri, or -1.
+func (s *Store) cellIndex(cells []protocol.NotebookCell, uri protocol.DocumentURI) int {
+	key := s.key(uri)
+	return slices.IndexFunc(cells, func(cell protocol.NotebookCell) bool { return s.key(cell.Document) == key })
+}
+
+func cellDocument(item protocol.TextDocumentItem, notebook protocol.URI) Document {
+	return Document{URI: item.URI, LanguageID: item.LanguageID, Version: item.Version, Text: item.Text, Notebook: notebook}
+}
@@ -0,0 +1,237 @@
+// Package textsync keeps the server's copy of each open document in step
+// with the client's. A Store handles textDocument/didOpen, didChange, in
+// both full and incremental form, didClose and didSave, so handlers read a
+// document's current text and version with Get instead of applying edits
+// themselves. It keeps notebooks too, through the notebookDocument/
+// notifications, each cell's text as a document of its own, so handlers
+// serve cells as they do files. Dump, or DumpOnPanic deferred, writes the
+// open documents out for a crash report.
+package textsync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Document is an open document as of one version.
+type Document struct {
+	URI        protocol.DocumentURI
+	LanguageID string
+	Version    int32
+	Text       string
+
+	// Notebook is the notebook the document is a cell of, empty for text
+	// documents.
+	Notebook protocol.URI
+}
+
+// EventKind is what happened to a document.
+type EventKind int
+
+const (
+	Opened EventKind = iota
+	Changed
+	Saved
+	Closed
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Opened:
+		return "opened"
+	case Changed:
+		return "changed"
+	case Saved:
+		return "saved"
+	default:
+		return "closed"
+	}
+}
+
+// Event reports a document after it was opened, changed or saved, or as it
+// was when closed.
+type Event struct {
+	Kind     EventKind
+	Document Document
+}
+
+// Store holds the open documents. The zero value is ready to use; it is
+// safe for concurrent use.
+type Store struct {
+	// Case controls URI comparison.
+	Case fscase.Sensitivity
+
+	// Encoding returns the position encoding incremental changes use, as
+	// lsp.Server.PositionEncoding does once negotiated. UTF-16 when nil.
+	Encoding func() position.Encoding
+
+	mu        sync.RWMutex
+	docs      map[string]Document
+	notebooks map[string]Notebook
+	listeners []func(context.Context, Event)
+}
+
+// Capability is the textDocumentSync server capability a Store serves.
+func Capability() protocol.TextDocumentSyncOptions {
+	return protocol.TextDocumentSyncOptions{
+		OpenClose: true,
+		Change:    protocol.SyncIncremental,
+		Save:      &protocol.SaveOptions{},
+	}
+}
+
+// Register routes the synchronisation notifications on mux to the Store,
+// for notebooks as well as text documents.
+func (s *Store) Register(mux *jsonrpc2.Mux) {
+	jsonrpc2.RegisterNotification(mux, "textDocument/didOpen", s.DidOpen)
+	jsonrpc2.RegisterNotification(mux, "textDocument/didChange", s.DidChange)
+	jsonrpc2.RegisterNotification(mux, "textDocument/didClose", s.DidClose)
+	jsonrpc2.RegisterNotification(mux, "textDocument/didSave", s.DidSave)
+	jsonrpc2.RegisterNotification(mux, "notebookDocument/didOpen", s.DidOpenNotebook)
+	jsonrpc2.RegisterNotification(mux, "notebookDocument/didChange", s.DidChangeNotebook)
+	jsonrpc2.RegisterNotification(mux, "notebookDocument/didClose", s.DidCloseNotebook)
+	jsonrpc2.RegisterNotification(mux, "notebookDocument/didSave", s.DidSaveNotebook)
+}
+
+// OnEvent registers a callback for document events. Callbacks run
+// synchronously, in the order notifications arrive, after the Store is
+// updated; they may read the Store.
+func (s *Store) OnEvent(fn func(ctx context.Context, event Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Get returns the current content of an open document.
+func (s *Store) Get(uri protocol.DocumentURI) (Document, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	doc, ok := s.docs[s.key(uri)]
+	return doc, ok
+}
+
+// All lists the open documents by URI.
+func (s *Store) All() []Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	docs := make([]Document, 0, len(s.docs))
+	for _, doc := range s.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].URI < docs[j].URI })
+	return docs
+}
+
+func (s *Store) key(uri protocol.DocumentURI) string {
+	return s.Case.URIKey(string(uri))
+}
+
+func (s *Store) encoding() position.Encoding {
+	if s.Encoding == nil {
+		return position.Default
+	}
+	return s.Encoding()
+}
+
+// DidOpen handles textDocument/didOpen. Opening a document which is already
+// open replaces it.
+func (s *Store) DidOpen(ctx context.Context, params *protocol.DidOpenTextDocumentParams) error {
+	item := params.TextDocument
+	doc := Document{URI: item.URI, LanguageID: item.LanguageID, Version: item.Version, Text: item.Text}
+	s.mu.Lock()
+	if s.docs == nil {
+		s.docs = map[string]Document{}
+	}
+	s.docs[s.key(item.URI)] = doc
+	s.mu.Unlock()
+	s.emit(ctx, Event{Opened, doc})
+	return nil
+}
+
+// DidChange handles textDocument/didChange, applying the changes in order.
+// Changes to a document which isn't open, or which don't advance its
+// version, are rejected and leave it untouched.
+func (s *Store) DidChange(ctx context.Context, params *protocol.DidChangeTextDocumentParams) error {
+	uri := params.TextDocument.URI
+	enc := s.encoding()
+	s.mu.Lock()
+	doc, ok := s.docs[s.key(uri)]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("textsync: change to %s, which is not open", uri)
+	}
+	if params.TextDocument.Version <= doc.Version {
+		s.mu.Unlock()
+		return fmt.Errorf("textsync: change to %s at version %d, already at %d", uri, params.TextDocument.Version, doc.Version)
+	}
+	text := doc.Text
+	for _, change := range params.ContentChanges {
+		text = Apply(text, change, enc)
+	}
+	doc.Text = text
+	doc.Version = params.TextDocument.Version
+	s.docs[s.key(uri)] = doc
+	s.mu.Unlock()
+	s.emit(ctx, Event{Changed, doc})
+	return nil
+}
+
+// DidSave handles textDocument/didSave. Saved text the client includes
+// replaces the document's.
+func (s *Store) DidSave(ctx context.Context, params *protocol.DidSaveTextDocumentParams) error {
+	uri := params.TextDocument.URI
+	s.mu.Lock()
+	doc, ok := s.docs[s.key(uri)]
+	if ok && params.Text != nil {
+		doc.Text = *params.Text
+		s.docs[s.key(uri)] = doc
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("textsync: save of %s, which is not open", uri)
+	}
+	s.emit(ctx, Event{Saved, doc})
+	return nil
+}
+
+// DidClose handles textDocument/didClose.
+func (s *Store) DidClose(ctx context.Context, params *protocol.DidCloseTextDocumentParams) error {
+	uri := params.TextDocument.URI
+	s.mu.Lock()
+	doc, ok := s.docs[s.key(uri)]
+	delete(s.docs, s.key(uri))
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("textsync: close of %s, which is not open", uri)
+	}
+	s.emit(ctx, Event{Closed, doc})
+	return nil
+}
+
+func (s *Store) emit(ctx context.Context, event Event) {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(ctx, event)
+	}
+}
+
+// Apply returns text with one content change applied: the whole text
+// replaced when the change has no range, otherwise the range, whose
+// characters are counted in enc. Positions past the end of a line or of the
+// text are clamped to it, as the specification requires.
+func Apply(text string, change protocol.TextDocumentContentChangeEvent, enc position.Encoding) string {
+	if change.Range == nil {
+		return change.Text
+	}
+	start, end := position.NewIndex(text).RangeToOffsets(*change.Range, enc)
+	return text[:start] + change.Text + text[end:]
+}
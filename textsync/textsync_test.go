@@ -0,0 +1,95 @@
+package textsync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pentops/lsplib/position"
+	"github.com/pentops/lsplib/protocol"
+)
+
+func span(startLine, startChar, endLine, endChar uint32) *protocol.Range {
+	return &protocol.Range{
+		Start: protocol.Position{Line: startLine, Character: startChar},
+		End:   protocol.Position{Line: endLine, Character: endChar},
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		text    string
+		r       *protocol.Range
+		newText string
+		enc     position.Encoding
+		want    string
+	}{
+		{"whole text", "ab", nil, "xyz", position.UTF16, "xyz"},
+		{"insert at eof", "ab", span(0, 2, 0, 2), "c", position.UTF16, "abc"},
+		{"insert at eof after newline", "ab\n", span(1, 0, 1, 0), "c", position.UTF16, "ab\nc"},
+		{"insert into empty", "", span(0, 0, 0, 0), "a", position.UTF16, "a"},
+		{"delete to eof", "ab\ncd", span(0, 1, 1, 2), "", position.UTF16, "a"},
+		{"range past eof", "ab\ncd", span(1, 1, 9, 9), "!", position.UTF16, "ab\nc!"},
+		{"start past eof", "ab", span(5, 0, 6, 0), "!", position.UTF16, "ab!"},
+		{"character past line end", "ab\ncd", span(0, 99, 0, 99), "!", position.UTF16, "ab!\ncd"},
+		{"reversed range", "abcd", span(0, 3, 0, 1), "", position.UTF16, "ad"},
+		{"join crlf lines", "ab\r\ncd", span(0, 2, 1, 0), "", position.UTF16, "abcd"},
+		{"past line end before crlf", "ab\r\ncd", span(0, 9, 0, 9), "!", position.UTF16, "ab!\r\ncd"},
+		{"insert crlf", "abcd", span(0, 2, 0, 2), "\r\n", position.UTF16, "ab\r\ncd"},
+		{"after lone cr", "a\rb", span(1, 0, 1, 1), "X", position.UTF16, "a\rX"},
+		{"utf-16 surrogate pair", "a😀b", span(0, 1, 0, 3), "", position.UTF16, "ab"},
+		{"utf-16 after pair", "a😀b", span(0, 3, 0, 4), "c", position.UTF16, "a😀c"},
+		{"utf-8 surrogate pair", "a😀b", span(0, 1, 0, 5), "", position.UTF8, "ab"},
+		{"utf-32 surrogate pair", "a😀b", span(0, 1, 0, 2), "", position.UTF32, "ab"},
+		{"utf-16 inside pair", "a😀b", span(0, 2, 0, 2), "x", position.UTF16, "ax😀b"},
+		{"utf-16 pair at eof", "a😀", span(0, 3, 0, 3), "b", position.UTF16, "a😀b"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Apply(tc.text, protocol.TextDocumentContentChangeEvent{Range: tc.r, Text: tc.newText}, tc.enc)
+			if got != tc.want {
+				t.Errorf("Apply(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDidChangeBatch(t *testing.T) {
+	ctx := context.Background()
+	const uri protocol.DocumentURI = "file:///a.txt"
+	s := &Store{Encoding: func() position.Encoding { return position.UTF16 }}
+	if err := s.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{URI: uri, Version: 1, Text: "hello\r\nworld"}}); err != nil {
+		t.Fatal(err)
+	}
+	// Each change is to the text the one before left.
+	err := s.DidChange(ctx, &protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{
+			{Range: span(0, 5, 0, 5), Text: "\n😀 there"},
+			{Range: span(1, 3, 1, 8), Text: "here"},
+			{Range: span(2, 5, 2, 5), Text: "!"},
+			{Range: span(0, 0, 1, 0), Text: ""},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := s.Get(uri)
+	if want := "😀 here\r\nworld!"; doc.Text != want || doc.Version != 2 {
+		t.Fatalf("after the batch, version %d reads %q, want version 2 reading %q", doc.Version, doc.Text, want)
+	}
+
+	// A batch replacing the whole text then editing it.
+	err = s.DidChange(ctx, &protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []protocol.TextDocumentContentChangeEvent{
+			{Text: "abc"},
+			{Range: span(0, 3, 0, 3), Text: "d"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc, _ := s.Get(uri); doc.Text != "abcd" {
+		t.Fatalf("after replacing the text, it reads %q, want %q", doc.Text, "abcd")
+	}
+}
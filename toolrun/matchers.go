@@ -0,0 +1,61 @@
+package toolrun
+
+import (
+	"regexp"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// filePattern matches a path, allowing a Windows drive letter prefix.
+const filePattern = `(?P<file>(?:[A-Za-z]:)?[^:\s][^:]*)`
+
+var (
+	gccPattern = regexp.MustCompile(`^` + filePattern +
+		`:(?P<line>\d+):(?:(?P<column>\d+):)?\s+(?P<severity>fatal error|error|warning|note|remark):\s+(?P<message>.*?)(?:\s+\[(?P<code>-W[^\]]+)\])?$`)
+
+	goPattern = regexp.MustCompile(`^(?:vet:\s+)?` + filePattern +
+		`:(?P<line>\d+)(?::(?P<column>\d+))?:\s+(?P<message>.+)$`)
+
+	genericPattern = regexp.MustCompile(`^` + filePattern +
+		`:(?P<line>\d+)(?::(?P<column>\d+))?:\s*(?:(?P<severity>(?i:error|warning|warn|info|note|hint)):\s*)?(?P<message>.+)$`)
+)
+
+// GCCParser matches GCC and Clang diagnostics:
+//
+//	main.c:3:10: warning: unused variable 'x' [-Wunused-variable]
+//
+// The -W flag, when present, becomes the diagnostic code. Lines without a
+// severity word (include stacks, caret lines) are skipped.
+func GCCParser() Parser {
+	return &RegexParser{Pattern: gccPattern}
+}
+
+// GoParser matches the Go compiler and go vet:
+//
+//	./main.go:12:2: undefined: foo
+//
+// Package headers ("# example.com/pkg") don't match and are skipped.
+func GoParser() Parser {
+	return &RegexParser{Pattern: goPattern, Severity: protocol.SeverityError}
+}
+
+// GenericParser matches the common file:line:col: message convention, with
+// an optional column and an optional "error:" / "warning:" style prefix on
+// the message. Severity defaults to the given value when not printed.
+func GenericParser(severity protocol.DiagnosticSeverity) Parser {
+	return &RegexParser{Pattern: genericPattern, Severity: severity}
+}
+
+// Matcher returns a built-in parser by name: "gcc", "clang", "go" or
+// "generic". It reports false for unknown names.
+func Matcher(name string) (Parser, bool) {
+	switch name {
+	case "gcc", "clang":
+		return GCCParser(), true
+	case "go":
+		return GoParser(), true
+	case "generic":
+		return GenericParser(protocol.SeverityError), true
+	}
+	return nil, false
+}
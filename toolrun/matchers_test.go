@@ -0,0 +1,100 @@
+package toolrun
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+func TestMatchers(t *testing.T) {
+	for _, tc := range []struct {
+		matcher string
+		output  string
+		want    []Problem
+	}{
+		{
+			"gcc",
+			"In file included from main.c:1:\n" +
+				"util.h:4:1: fatal error: foo.h: No such file or directory\n" +
+				"main.c:3:10: warning: unused variable 'x' [-Wunused-variable]\n" +
+				"    3 |   int x;\n" +
+				"      |       ^\n" +
+				"main.c:7: error: expected ';'\n" +
+				"main.c:2:5: note: declared here\n",
+			[]Problem{
+				{File: "util.h", Line: 4, Column: 1, Severity: protocol.SeverityError, Message: "foo.h: No such file or directory"},
+				{File: "main.c", Line: 3, Column: 10, Severity: protocol.SeverityWarning, Code: "-Wunused-variable", Message: "unused variable 'x'"},
+				{File: "main.c", Line: 7, Severity: protocol.SeverityError, Message: "expected ';'"},
+				{File: "main.c", Line: 2, Column: 5, Severity: protocol.SeverityInformation, Message: "declared here"},
+			},
+		},
+		{
+			"clang",
+			`C:\src\loop.c:12:3: remark: loop not vectorized [-Wpass-failed]` + "\n",
+			[]Problem{
+				{File: `C:\src\loop.c`, Line: 12, Column: 3, Severity: protocol.SeverityInformation, Code: "-Wpass-failed", Message: "loop not vectorized"},
+			},
+		},
+		{
+			"go",
+			"# example.com/pkg\n" +
+				"./main.go:12:2: undefined: foo\n" +
+				"vet: ./main.go:20: unreachable code\n",
+			[]Problem{
+				{File: "./main.go", Line: 12, Column: 2, Severity: protocol.SeverityError, Message: "undefined: foo"},
+				{File: "./main.go", Line: 20, Severity: protocol.SeverityError, Message: "unreachable code"},
+			},
+		},
+		{
+			"generic",
+			"a.txt:1:2: something odd\n" +
+				"a.txt:3: WARN: spelling\n" +
+				"a.txt:4:1: info: 2 words\n" +
+				"a.txt:5:1:hint: rephrase\n" +
+				"not a problem\n",
+			[]Problem{
+				{File: "a.txt", Line: 1, Column: 2, Severity: protocol.SeverityError, Message: "something odd"},
+				{File: "a.txt", Line: 3, Severity: protocol.SeverityWarning, Message: "spelling"},
+				{File: "a.txt", Line: 4, Column: 1, Severity: protocol.SeverityInformation, Message: "2 words"},
+				{File: "a.txt", Line: 5, Column: 1, Severity: protocol.SeverityHint, Message: "rephrase"},
+			},
+		},
+	} {
+		t.Run(tc.matcher, func(t *testing.T) {
+			parser, ok := Matcher(tc.matcher)
+			if !ok {
+				t.Fatalf("no matcher %q", tc.matcher)
+			}
+			got, err := parser.Parse([]byte(tc.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("parsed\n%+v\nwant\n%+v", got, tc.want)
+			}
+		})
+	}
+	if _, ok := Matcher("msvc"); ok {
+		t.Fatal("Matcher found an unknown name")
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for val, want := range map[string]protocol.DiagnosticSeverity{
+		"fatal error": protocol.SeverityError,
+		"Warning":     protocol.SeverityWarning,
+		"note":        protocol.SeverityInformation,
+		"remark":      protocol.SeverityInformation,
+		" info ":      protocol.SeverityInformation,
+		"help":        protocol.SeverityHint,
+		"4":           protocol.SeverityHint,
+	} {
+		if got, ok := ParseSeverity(val); !ok || got != want {
+			t.Errorf("ParseSeverity(%q) = %v, %v, want %v", val, got, ok, want)
+		}
+	}
+	if _, ok := ParseSeverity("verbose"); ok {
+		t.Error("ParseSeverity mapped an unknown word")
+	}
+}
@@ -0,0 +1,214 @@
+package toolrun
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Problem is a single finding reported by a tool. Line and column numbers are
+// one-based as printed by almost every compiler; zero means unknown.
+type Problem struct {
+	File      string
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Severity  protocol.DiagnosticSeverity
+	Code      string
+	Message   string
+}
+
+// Parser turns raw tool output into problems.
+type Parser interface {
+	Parse(output []byte) ([]Problem, error)
+}
+
+// ParserFunc adapts a function to the Parser interface.
+type ParserFunc func(output []byte) ([]Problem, error)
+
+func (f ParserFunc) Parse(output []byte) ([]Problem, error) {
+	return f(output)
+}
+
+// RegexParser matches each line of output against Pattern. Named groups
+// populate the problem: file, line, column, endLine, endColumn, severity, code
+// and message. Lines which do not match are ignored.
+type RegexParser struct {
+	Pattern *regexp.Regexp
+
+	// Severity is used when the pattern has no severity group, or the group
+	// did not match a known word. Defaults to error.
+	Severity protocol.DiagnosticSeverity
+}
+
+func (p *RegexParser) Parse(output []byte) ([]Problem, error) {
+	names := p.Pattern.SubexpNames()
+	var problems []Problem
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		match := p.Pattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		problem := Problem{Severity: p.Severity}
+		for idx, name := range names {
+			if name == "" || match[idx] == "" {
+				continue
+			}
+			setField(&problem, name, match[idx])
+		}
+		if problem.Severity == 0 {
+			problem.Severity = protocol.SeverityError
+		}
+		problems = append(problems, problem)
+	}
+	return problems, scanner.Err()
+}
+
+// JSONFields maps problem fields to keys in a tool's JSON output. Keys may be
+// dotted to reach into nested objects, e.g. "location.line".
+type JSONFields struct {
+	File      string
+	Line      string
+	Column    string
+	EndLine   string
+	EndColumn string
+	Severity  string
+	Code      string
+	Message   string
+}
+
+// JSONParser reads JSON tool output: either a single array of objects, or one
+// object per line when Lines is set.
+type JSONParser struct {
+	Fields JSONFields
+	Lines  bool
+
+	// Severity is the default when the severity field is absent.
+	Severity protocol.DiagnosticSeverity
+}
+
+func (p *JSONParser) Parse(output []byte) ([]Problem, error) {
+	var records []map[string]any
+	if p.Lines {
+		scanner := bufio.NewScanner(bytes.NewReader(output))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 || line[0] != '{' {
+				continue
+			}
+			record := map[string]any{}
+			if err := json.Unmarshal(line, &record); err != nil {
+				return nil, fmt.Errorf("parsing tool output line: %w", err)
+			}
+			records = append(records, record)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if len(bytes.TrimSpace(output)) > 0 {
+		if err := json.Unmarshal(output, &records); err != nil {
+			return nil, fmt.Errorf("parsing tool output: %w", err)
+		}
+	}
+
+	fields := []struct{ name, key string }{
+		{"file", p.Fields.File},
+		{"line", p.Fields.Line},
+		{"column", p.Fields.Column},
+		{"endLine", p.Fields.EndLine},
+		{"endColumn", p.Fields.EndColumn},
+		{"severity", p.Fields.Severity},
+		{"code", p.Fields.Code},
+		{"message", p.Fields.Message},
+	}
+
+	problems := make([]Problem, 0, len(records))
+	for _, record := range records {
+		problem := Problem{Severity: p.Severity}
+		for _, field := range fields {
+			if field.key == "" {
+				continue
+			}
+			if val, ok := lookup(record, field.key); ok {
+				setField(&problem, field.name, val)
+			}
+		}
+		if problem.Severity == 0 {
+			problem.Severity = protocol.SeverityError
+		}
+		problems = append(problems, problem)
+	}
+	return problems, nil
+}
+
+func lookup(record map[string]any, key string) (string, bool) {
+	var cur any = record
+	for _, part := range strings.Split(key, ".") {
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if cur, ok = obj[part]; !ok {
+			return "", false
+		}
+	}
+	switch v := cur.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case nil:
+		return "", false
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+func setField(problem *Problem, name, val string) {
+	switch name {
+	case "file":
+		problem.File = val
+	case "line":
+		problem.Line, _ = strconv.Atoi(val)
+	case "column":
+		problem.Column, _ = strconv.Atoi(val)
+	case "endLine":
+		problem.EndLine, _ = strconv.Atoi(val)
+	case "endColumn":
+		problem.EndColumn, _ = strconv.Atoi(val)
+	case "severity":
+		if sev, ok := ParseSeverity(val); ok {
+			problem.Severity = sev
+		}
+	case "code":
+		problem.Code = val
+	case "message":
+		problem.Message = strings.TrimSpace(val)
+	}
+}
+
+// ParseSeverity maps the severity words tools commonly print, and the
+// numeric LSP values, onto DiagnosticSeverity.
+func ParseSeverity(val string) (protocol.DiagnosticSeverity, bool) {
+	switch strings.ToLower(strings.TrimSpace(val)) {
+	case "error", "fatal", "fatal error", "err", "e", "1":
+		return protocol.SeverityError, true
+	case "warning", "warn", "w", "2":
+		return protocol.SeverityWarning, true
+	case "info", "information", "note", "remark", "i", "3":
+		return protocol.SeverityInformation, true
+	case "hint", "help", "suggestion", "h", "4":
+		return protocol.SeverityHint, true
+	}
+	return 0, false
+}
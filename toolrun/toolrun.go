@@ -0,0 +1,262 @@
+// Package toolrun wraps an external build or lint command as a diagnostics
+// source: it runs the command through an execx.Sandbox, parses the output
+// into problems and publishes them as LSP diagnostics.
+package toolrun
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pentops/lsplib/execx"
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/uri"
+)
+
+// Publisher receives the diagnostics produced by a run.
+type Publisher interface {
+	PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error
+}
+
+// Stream selects which output stream(s) are handed to the parser.
+type Stream int
+
+const (
+	StreamBoth Stream = iota
+	StreamStdout
+	StreamStderr
+)
+
+// FilePlaceholder in a tool's arguments is replaced by the saved file's path
+// on save-triggered runs. Arguments containing it are dropped from
+// workspace-wide runs.
+const FilePlaceholder = "${file}"
+
+// Tool describes the external command and how to read its output.
+type Tool struct {
+	// Name is used as the diagnostic source.
+	Name    string
+	Command execx.Command
+	Parser  Parser
+	Stream  Stream
+
+	// ReportExitStatus publishes a diagnostic when the command fails without
+	// any parseable problems, rather than treating that as a clean run.
+	ReportExitStatus bool
+}
+
+// Runner executes a Tool and publishes the results. Only one run is active at
+// a time; starting a new run cancels the previous one.
+type Runner struct {
+	Sandbox   *execx.Sandbox
+	Tool      Tool
+	Publisher Publisher
+
+	// Case is the workspace filesystem's case sensitivity. Tools on
+	// insensitive filesystems may report a file under a different case from
+	// the previous run; those are the same file and must not leave stale
+	// diagnostics behind.
+	Case fscase.Sensitivity
+
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	published map[string]protocol.DocumentURI
+}
+
+// Run executes the tool over the whole workspace, on demand.
+func (r *Runner) Run(ctx context.Context) error {
+	return r.run(ctx, "")
+}
+
+// OnSave runs the tool for a saved document. Wire it to textDocument/didSave.
+func (r *Runner) OnSave(ctx context.Context, uri protocol.DocumentURI) error {
+	path, err := uri.Path()
+	if err != nil {
+		return err
+	}
+	return r.run(ctx, path)
+}
+
+func (r *Runner) run(ctx context.Context, file string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.cancel = cancel
+	r.mu.Unlock()
+	defer cancel()
+
+	cmd := r.Tool.Command
+	cmd.Args = substituteFile(cmd.Args, file)
+
+	res, runErr := r.Sandbox.Run(ctx, cmd)
+	var execErr *execx.Error
+	if errors.As(runErr, &execErr) && execErr.Kind == execx.KindCancelled {
+		return nil
+	}
+
+	byURI := map[protocol.DocumentURI][]protocol.Diagnostic{}
+	var failure error
+	if runErr == nil {
+		problems, err := r.Tool.Parser.Parse(selectOutput(res, r.Tool.Stream))
+		if err != nil {
+			failure = err
+		}
+		for _, problem := range problems {
+			uri := r.problemURI(problem.File, file)
+			if uri == "" {
+				continue
+			}
+			byURI[uri] = append(byURI[uri], r.diagnostic(problem))
+		}
+	}
+
+	if runErr != nil || failure != nil || (len(byURI) == 0 && res.ExitCode != 0 && r.Tool.ReportExitStatus) {
+		if file != "" {
+			diag, ok := execx.Diagnostic(res, errors.Join(runErr, failure), protocol.Range{})
+			if ok {
+				diag.Source = r.Tool.Name
+				doc := uri.FromPath(file)
+				byURI[doc] = append(byURI[doc], diag)
+			}
+		}
+	}
+
+	if err := r.publish(ctx, byURI, file == ""); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// publish sends diagnostics for every reported file and clears files which
+// reported problems last time but not this time. Save-triggered runs only
+// clear the saved file, since the tool may not have looked at the others.
+func (r *Runner) publish(ctx context.Context, byURI map[protocol.DocumentURI][]protocol.Diagnostic, full bool) error {
+	r.mu.Lock()
+	previous := r.published
+	next := make(map[string]protocol.DocumentURI, len(byURI))
+	if !full {
+		for key, uri := range previous {
+			next[key] = uri
+		}
+	}
+	for uri := range byURI {
+		next[r.Case.URIKey(string(uri))] = uri
+	}
+	r.published = next
+	r.mu.Unlock()
+
+	var errs []error
+	for uri, diags := range byURI {
+		errs = append(errs, r.Publisher.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+			URI:         uri,
+			Diagnostics: diags,
+		}))
+	}
+	if full {
+		for key, uri := range previous {
+			if _, ok := next[key]; ok {
+				continue
+			}
+			errs = append(errs, r.Publisher.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+				URI:         uri,
+				Diagnostics: []protocol.Diagnostic{},
+			}))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runner) problemURI(reported, savedFile string) protocol.DocumentURI {
+	if reported == "" {
+		if savedFile == "" {
+			return ""
+		}
+		return uri.FromPath(savedFile)
+	}
+	path := reported
+	if !filepath.IsAbs(path) {
+		dir, err := r.Sandbox.ResolveDir(r.Tool.Command.Dir)
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(dir, path)
+	}
+	return uri.FromPath(path)
+}
+
+func (r *Runner) diagnostic(problem Problem) protocol.Diagnostic {
+	diag := protocol.Diagnostic{
+		Range:    problemRange(problem),
+		Severity: problem.Severity,
+		Source:   r.Tool.Name,
+		Message:  problem.Message,
+	}
+	if problem.Code != "" {
+		diag.Code = protocol.NewStringCode(problem.Code)
+	}
+	return diag
+}
+
+// problemRange converts one-based tool positions to a zero-based range.
+// Columns are taken as-is, which is exact for ASCII lines; tools rarely agree
+// on what a column is for anything else.
+func problemRange(problem Problem) protocol.Range {
+	start := protocol.Position{
+		Line:      oneBased(problem.Line),
+		Character: oneBased(problem.Column),
+	}
+	end := start
+	if problem.EndLine > 0 {
+		end.Line = oneBased(problem.EndLine)
+		end.Character = oneBased(problem.EndColumn)
+	} else if problem.EndColumn > 0 {
+		end.Character = oneBased(problem.EndColumn)
+	}
+	if end.Line < start.Line || (end.Line == start.Line && end.Character < start.Character) {
+		end = start
+	}
+	return protocol.Range{Start: start, End: end}
+}
+
+func oneBased(n int) uint32 {
+	if n <= 1 {
+		return 0
+	}
+	return uint32(n - 1)
+}
+
+func substituteFile(args []string, file string) []string {
+	out := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.Contains(arg, FilePlaceholder) {
+			out = append(out, arg)
+			continue
+		}
+		if file == "" {
+			continue
+		}
+		out = append(out, strings.ReplaceAll(arg, FilePlaceholder, file))
+	}
+	return out
+}
+
+func selectOutput(res *execx.Result, stream Stream) []byte {
+	switch stream {
+	case StreamStdout:
+		return res.Stdout
+	case StreamStderr:
+		return res.Stderr
+	default:
+		out := make([]byte, 0, len(res.Stdout)+len(res.Stderr)+1)
+		out = append(out, res.Stdout...)
+		if len(res.Stdout) > 0 && res.Stdout[len(res.Stdout)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		return append(out, res.Stderr...)
+	}
+}
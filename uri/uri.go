@@ -0,0 +1,124 @@
+// Package uri is the URIs LSP names documents and resources by, and their
+// conversion to and from file paths. Clients spell the same file in
+// different ways, such as "file:///C:/src/main.go" and
+// "file:///c%3A/src/main.go", so URIs are compared in their normalized form
+// rather than as strings.
+package uri
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pentops/lsplib/fscase"
+)
+
+// DocumentURI is a URI identifying a text document, usually with the file
+// scheme.
+type DocumentURI string
+
+// URI is a generic URI which is not necessarily a document.
+type URI string
+
+// FromPath returns the file URI of path, which should be absolute; a
+// relative one is taken as relative to the root. Windows paths
+// with a drive letter, such as `C:\src\main.go`, are recognized on every
+// platform, and their drive letter is lower-cased as VS Code does.
+func FromPath(path string) DocumentURI {
+	if runtime.GOOS == "windows" || hasDrive(path) {
+		path = strings.ReplaceAll(path, `\`, "/")
+	}
+	host := ""
+	if rest, ok := strings.CutPrefix(path, "//"); ok && !strings.HasPrefix(rest, "/") {
+		// A UNC path, //host/share/file.
+		host, path, _ = strings.Cut(rest, "/")
+		path = "/" + path
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	path = lowerDrive(cleanSlash(path))
+	return DocumentURI((&url.URL{Scheme: "file", Host: host, Path: path}).String())
+}
+
+// Path returns the file path u names, in the platform's form. It fails for
+// URIs which aren't file URIs.
+func (u DocumentURI) Path() (string, error) {
+	parsed, err := url.Parse(string(u))
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(parsed.Scheme, "file") {
+		return "", errors.New("uri: not a file URI: " + string(u))
+	}
+	path := parsed.Path
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	if parsed.Host != "" && parsed.Host != "localhost" {
+		path = "//" + parsed.Host + path
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// IsFile reports whether u has the file scheme.
+func (u DocumentURI) IsFile() bool {
+	scheme, _, ok := strings.Cut(string(u), ":")
+	return ok && strings.EqualFold(scheme, "file")
+}
+
+// Normalize returns u in one spelling of each file: the scheme and drive
+// letter lower-cased, "localhost" dropped, the path cleaned and percent
+// encoding only where a URI needs it. Other URIs, and those which don't
+// parse, are returned unchanged.
+func (u DocumentURI) Normalize() DocumentURI {
+	parsed, err := url.Parse(string(u))
+	if err != nil || !strings.EqualFold(parsed.Scheme, "file") {
+		return u
+	}
+	host := strings.ToLower(parsed.Host)
+	if host == "localhost" {
+		host = ""
+	}
+	path := lowerDrive(cleanSlash(parsed.Path))
+	return DocumentURI((&url.URL{Scheme: "file", Host: host, Path: path}).String())
+}
+
+// Key returns the form of u to index documents by, which agrees for every
+// spelling of the same file: normalized, with the path case-folded when
+// the filesystem is case-insensitive.
+func (u DocumentURI) Key(s fscase.Sensitivity) string {
+	return s.URIKey(string(u.Normalize()))
+}
+
+// Equal reports whether u and v name the same document on a filesystem
+// with case sensitivity s.
+func (u DocumentURI) Equal(v DocumentURI, s fscase.Sensitivity) bool {
+	return u.Key(s) == v.Key(s)
+}
+
+// hasDrive reports whether path starts with a Windows drive letter.
+func hasDrive(path string) bool {
+	return len(path) >= 2 && path[1] == ':' && ('a' <= path[0] && path[0] <= 'z' || 'A' <= path[0] && path[0] <= 'Z')
+}
+
+// lowerDrive lower-cases the drive letter of a URI path such as /C:/src.
+func lowerDrive(path string) string {
+	if len(path) >= 3 && path[0] == '/' && hasDrive(path[1:]) {
+		return "/" + strings.ToLower(path[1:2]) + path[2:]
+	}
+	return path
+}
+
+// cleanSlash cleans a slash-separated path, keeping a trailing slash, which
+// names a directory.
+func cleanSlash(p string) string {
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(cleaned, "/") {
+		cleaned += "/"
+	}
+	return cleaned
+}
@@ -0,0 +1,182 @@
+package walk
+
+import (
+	"bufio"
+	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pentops/lsplib/ctxfs"
+	"github.com/pentops/lsplib/fscase"
+)
+
+// rule is one line of a .gitignore style file.
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+
+	// base is the slash-separated directory of the ignore file, relative to
+	// the walk root, "" for the root itself.
+	base string
+}
+
+// Rules is an ordered set of ignore rules; the last matching rule wins.
+type Rules struct {
+	rules []*rule
+	cs    fscase.Sensitivity
+}
+
+// ParseRules parses ignore file content whose patterns are relative to base,
+// a slash-separated directory relative to the walk root. Patterns match
+// case-insensitively when cs says the filesystem is, as git does with
+// core.ignorecase.
+func ParseRules(content, base string, cs fscase.Sensitivity) *Rules {
+	rs := &Rules{cs: cs}
+	rs.add(content, base)
+	return rs
+}
+
+func (rs *Rules) add(content, base string) {
+	fold := rs.cs.Equal("A", "a")
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		if r := parseRule(scanner.Text(), base, fold); r != nil {
+			rs.rules = append(rs.rules, r)
+		}
+	}
+}
+
+// with returns a copy extended by the rules in any of the named ignore files
+// found in dir. The receiver is shared by sibling directories and is never
+// modified.
+func (rs *Rules) with(ctx context.Context, fsys ctxfs.FS, dir, base string, names []string) *Rules {
+	var extra []string
+	for _, name := range names {
+		data, err := fsys.ReadFile(ctx, filepath.Join(dir, name))
+		if err == nil {
+			extra = append(extra, string(data))
+		}
+	}
+	if len(extra) == 0 {
+		return rs
+	}
+	out := &Rules{rules: append([]*rule(nil), rs.rules...), cs: rs.cs}
+	for _, content := range extra {
+		out.add(content, base)
+	}
+	return out
+}
+
+// Ignored reports whether rel, a slash-separated path relative to the walk
+// root, is excluded.
+func (rs *Rules) Ignored(rel string, isDir bool) bool {
+	if rs == nil {
+		return false
+	}
+	ignored := false
+	for _, r := range rs.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if r.base != "" {
+			prefix := r.base + "/"
+			if !rs.cs.HasPrefix(rel, prefix) {
+				continue
+			}
+			sub = rel[len(prefix):]
+		}
+		if r.re.MatchString(sub) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func parseRule(line, base string, fold bool) *rule {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	r := &rule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil
+	}
+
+	// A slash anywhere but the end anchors the pattern to the ignore file's
+	// directory; otherwise it matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var re strings.Builder
+	if fold {
+		re.WriteString("(?i)")
+	}
+	if anchored {
+		re.WriteString("^")
+	} else {
+		re.WriteString("^(?:.*/)?")
+	}
+	re.WriteString(globToRegexp(line))
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil
+	}
+	r.re = compiled
+	return r
+}
+
+func globToRegexp(glob string) string {
+	var out strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			out.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			out.WriteString(".*")
+			i++
+		case c == '*':
+			out.WriteString("[^/]*")
+		case c == '?':
+			out.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				out.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			out.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			out.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			out.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,312 @@
+// Package walk enumerates the files of a workspace for indexing and headless
+// runs, honouring .gitignore and .ignore files and skipping binary content.
+package walk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pentops/lsplib/ctxfs"
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/locale"
+)
+
+// DefaultIgnoreFiles are read in every directory when Options.IgnoreFiles is
+// nil.
+var DefaultIgnoreFiles = []string{".gitignore", ".ignore"}
+
+// DefaultSkipDirs are never descended into when Options.SkipDirs is nil.
+var DefaultSkipDirs = []string{".git", ".hg", ".svn"}
+
+// Reporter receives progress updates. A work-done progress handle satisfies
+// it, so a walk can drive the editor's progress bar directly.
+type Reporter interface {
+	Report(message string, percentage uint32)
+}
+
+// Options configures a walk.
+type Options struct {
+	Root string
+
+	// Case is the filesystem's case sensitivity, applied to ignore patterns
+	// and SkipDirs. Auto probes Root.
+	Case fscase.Sensitivity
+
+	// Workers bounds concurrent directory reads, GOMAXPROCS when zero.
+	Workers int
+
+	IgnoreFiles []string
+	SkipDirs    []string
+
+	// IncludeBinary disables binary detection.
+	IncludeBinary bool
+
+	// Timeout bounds each directory listing and file read, as
+	// ctxfs.FS.Timeout does, so a hung network mount costs the walk its
+	// directories rather than the walk itself; what times out is skipped
+	// like what is unreadable. None when zero, though cancelling ctx still
+	// stops the walk promptly.
+	Timeout time.Duration
+
+	// MaxFileSize skips larger files when positive.
+	MaxFileSize int64
+
+	// Match, when set, further filters files by their slash-separated path
+	// relative to Root.
+	Match func(rel string) bool
+
+	Progress Reporter
+
+	// ProgressInterval throttles Progress updates, 250ms when zero.
+	ProgressInterval time.Duration
+
+	// Printer translates the messages of Progress updates, such as with
+	// lsp.Server.Printer; the zero Printer leaves them in English.
+	Printer locale.Printer
+}
+
+// File is one file yielded by Walk.
+type File struct {
+	Path    string
+	Rel     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Walk calls fn for every file under opts.Root which is not ignored. fn is
+// called from multiple goroutines and must be safe for concurrent use; an
+// error from fn stops the walk and is returned. Unreadable directories and
+// symbolic links are skipped.
+func Walk(ctx context.Context, opts Options, fn func(File) error) error {
+	root, err := filepath.Abs(opts.Root)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("walk root %s is not a directory", root)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := &walker{
+		opts:   opts,
+		fn:     fn,
+		fs:     ctxfs.FS{Timeout: opts.Timeout},
+		cancel: cancel,
+	}
+	if w.opts.IgnoreFiles == nil {
+		w.opts.IgnoreFiles = DefaultIgnoreFiles
+	}
+	w.opts.Case = opts.Case.Resolve(root)
+	w.skip = map[string]bool{}
+	skipDirs := opts.SkipDirs
+	if skipDirs == nil {
+		skipDirs = DefaultSkipDirs
+	}
+	for _, name := range skipDirs {
+		w.skip[w.opts.Case.Key(name)] = true
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	w.sem = make(chan struct{}, workers)
+
+	stopProgress := w.startProgress(ctx)
+	w.dirsFound.Add(1)
+	w.wg.Add(1)
+	go w.dir(ctx, root, "", &Rules{cs: w.opts.Case})
+	w.wg.Wait()
+	stopProgress()
+
+	if w.err != nil {
+		return w.err
+	}
+	return context.Cause(ctx)
+}
+
+type walker struct {
+	opts   Options
+	fn     func(File) error
+	fs     ctxfs.FS
+	skip   map[string]bool
+	sem    chan struct{}
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+
+	errOnce sync.Once
+	err     error
+
+	dirsFound atomic.Int64
+	dirsDone  atomic.Int64
+	files     atomic.Int64
+}
+
+func (w *walker) fail(err error) {
+	w.errOnce.Do(func() {
+		w.err = err
+		w.cancel()
+	})
+}
+
+func (w *walker) dir(ctx context.Context, abs, rel string, parent *Rules) {
+	defer w.wg.Done()
+	defer w.dirsDone.Add(1)
+
+	select {
+	case w.sem <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() { <-w.sem }()
+
+	entries, err := w.fs.ReadDir(ctx, abs)
+	if err != nil {
+		return
+	}
+	rules := parent.with(ctx, w.fs, abs, rel, w.opts.IgnoreFiles)
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		name := entry.Name()
+		childRel := name
+		if rel != "" {
+			childRel = rel + "/" + name
+		}
+		childAbs := filepath.Join(abs, name)
+
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			continue
+		case entry.IsDir():
+			if w.skip[w.opts.Case.Key(name)] || rules.Ignored(childRel, true) {
+				continue
+			}
+			w.dirsFound.Add(1)
+			w.wg.Add(1)
+			go w.dir(ctx, childAbs, childRel, rules)
+		case entry.Type().IsRegular():
+			if rules.Ignored(childRel, false) {
+				continue
+			}
+			if w.opts.Match != nil && !w.opts.Match(childRel) {
+				continue
+			}
+			if err := w.file(ctx, childAbs, childRel, entry); err != nil {
+				w.fail(err)
+				return
+			}
+		}
+	}
+}
+
+func (w *walker) file(ctx context.Context, abs, rel string, entry fs.DirEntry) error {
+	info, err := entry.Info()
+	if err != nil {
+		return nil
+	}
+	if w.opts.MaxFileSize > 0 && info.Size() > w.opts.MaxFileSize {
+		return nil
+	}
+	if !w.opts.IncludeBinary {
+		prefix, err := w.fs.ReadHead(ctx, abs, sniffLen)
+		if err != nil || IsBinary(prefix) {
+			return nil
+		}
+	}
+	w.files.Add(1)
+	return w.fn(File{
+		Path:    abs,
+		Rel:     rel,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
+}
+
+// startProgress reports periodically until the returned func is called. The
+// percentage is the fraction of discovered directories completed, which only
+// grows once discovery has run ahead of reading.
+func (w *walker) startProgress(ctx context.Context) func() {
+	if w.opts.Progress == nil {
+		return func() {}
+	}
+	interval := w.opts.ProgressInterval
+	if interval == 0 {
+		interval = 250 * time.Millisecond
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var best uint32
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			found := w.dirsFound.Load()
+			pct := uint32(0)
+			if found > 0 {
+				pct = uint32(w.dirsDone.Load() * 100 / found)
+			}
+			if pct < best {
+				pct = best
+			}
+			best = pct
+			w.opts.Progress.Report(w.opts.Printer.Sprintf("%d files", w.files.Load()), pct)
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// sniffLen matches the amount git inspects when deciding a file is binary.
+const sniffLen = 8000
+
+// IsBinaryFile reports whether the file looks binary: it contains a NUL byte
+// in its first few kilobytes and is not UTF-16 text with a byte order mark.
+func IsBinaryFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return IsBinary(buf[:n]), nil
+}
+
+// IsBinary applies the IsBinaryFile heuristic to a prefix of file content.
+func IsBinary(prefix []byte) bool {
+	if bytes.HasPrefix(prefix, []byte{0xFF, 0xFE}) || bytes.HasPrefix(prefix, []byte{0xFE, 0xFF}) {
+		return false
+	}
+	return bytes.IndexByte(prefix, 0) >= 0
+}
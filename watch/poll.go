@@ -0,0 +1,121 @@
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/uri"
+	"github.com/pentops/lsplib/walk"
+)
+
+// Poller finds file changes from the server side, for clients which can't
+// watch files for it: every Interval it lists the files below Roots and
+// reports those created, changed or deleted since the list before, as the
+// client would have.
+type Poller struct {
+	// Roots are the directories to watch, such as the workspace folders'
+	// paths.
+	Roots []string
+
+	// Interval is the time between listings, 2s when zero.
+	Interval time.Duration
+
+	// SkipDirs are never descended into, walk.DefaultSkipDirs when nil.
+	SkipDirs []string
+
+	Clock clock.Clock
+}
+
+// stat is what a listing remembers of a file to notice changes.
+type stat struct {
+	size    int64
+	modTime time.Time
+}
+
+// list returns the files below the roots.
+func (p *Poller) list() map[string]stat {
+	skip := p.SkipDirs
+	if skip == nil {
+		skip = walk.DefaultSkipDirs
+	}
+	files := map[string]stat{}
+	for _, root := range p.Roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				for _, name := range skip {
+					if d.Name() == name && path != root {
+						return filepath.SkipDir
+					}
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				files[path] = stat{size: info.Size(), modTime: info.ModTime()}
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// changes returns the events turning listing prev into next, by path.
+func changes(prev, next map[string]stat) []protocol.FileEvent {
+	var events []protocol.FileEvent
+	for path, s := range next {
+		old, ok := prev[path]
+		switch {
+		case !ok:
+			events = append(events, protocol.FileEvent{URI: uri.FromPath(path), Type: protocol.FileCreated})
+		case old != s:
+			events = append(events, protocol.FileEvent{URI: uri.FromPath(path), Type: protocol.FileChanged})
+		}
+	}
+	for path := range prev {
+		if _, ok := next[path]; !ok {
+			events = append(events, protocol.FileEvent{URI: uri.FromPath(path), Type: protocol.FileDeleted})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].URI < events[j].URI })
+	return events
+}
+
+// poll lists the files every interval, handing the changes to the
+// subscribers, until stop is closed.
+func (m *Manager) poll(p *Poller, stop <-chan struct{}) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	c := clock.Or(p.Clock)
+	prev := p.list()
+	for {
+		timer := c.NewTimer(interval)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		next := p.list()
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if events := changes(prev, next); len(events) > 0 {
+			_ = m.DidChangeWatchedFiles(context.Background(), &protocol.DidChangeWatchedFilesParams{Changes: events})
+		}
+		prev = next
+	}
+}
@@ -0,0 +1,378 @@
+// Package watch shares the client's file watching among a server's
+// features. Each subscribes with the watchers it needs; the Manager asks the
+// client for one minimal set covering them all and routes each
+// workspace/didChangeWatchedFiles event back to the subscribers whose
+// watchers match it.
+//
+// The client must support dynamic registration of
+// workspace/didChangeWatchedFiles, unless the Manager is given a Poller to
+// fall back on: it then lists the files itself, and subscribers see its
+// events as they would the client's.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/internal/glob"
+	"github.com/pentops/lsplib/jsonrpc2"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Method is the notification events arrive in, and the method watchers are
+// registered for.
+const Method = "workspace/didChangeWatchedFiles"
+
+// Caller sends requests to the client; *lsp.Server is one.
+type Caller interface {
+	Call(ctx context.Context, method string, params, result any) error
+}
+
+// Supporter reports the client's capabilities, by dotted path; *lsp.Server
+// is one.
+type Supporter interface {
+	ClientSupports(path string) bool
+}
+
+// dynamicCapability is the client capability a Manager needs, unless it
+// falls back on polling.
+const dynamicCapability = "workspace.didChangeWatchedFiles.dynamicRegistration"
+
+// Manager merges subscriptions into one registration.
+type Manager struct {
+	caller   Caller
+	cs       fscase.Sensitivity
+	fallback *Poller
+
+	mu   sync.Mutex
+	subs map[*Subscription]bool
+
+	// syncMu serialises registrations; registered is the current one.
+	syncMu     sync.Mutex
+	seq        int
+	registered string
+	watchers   []protocol.FileSystemWatcher
+	// pollStop stops the fallback poller, nil when it isn't running.
+	pollStop chan struct{}
+}
+
+// Subscription is one feature's interest in a set of files.
+type Subscription struct {
+	m        *Manager
+	fn       func(ctx context.Context, events []protocol.FileEvent)
+	watchers []protocol.FileSystemWatcher
+	matchers []*glob.Pattern
+}
+
+// New returns a Manager registering watchers through caller and matching
+// paths with the given case sensitivity.
+func New(caller Caller, cs fscase.Sensitivity) *Manager {
+	return &Manager{caller: caller, cs: cs, subs: map[*Subscription]bool{}}
+}
+
+// Fallback has the Manager poll for changes with p when the client can't
+// watch files: when the caller is a Supporter which reports no dynamic
+// registration of workspace/didChangeWatchedFiles. Polling runs while there
+// are subscriptions. Call it before subscribing.
+func (m *Manager) Fallback(p *Poller) {
+	m.fallback = p
+}
+
+// Register routes the client's events from mux to the Manager.
+func (m *Manager) Register(mux *jsonrpc2.Mux) {
+	jsonrpc2.RegisterNotification(mux, Method, m.DidChangeWatchedFiles)
+}
+
+// Subscribe calls fn with the events matching watchers, from now until the
+// subscription is closed, and updates the client's registration to cover
+// them.
+func (m *Manager) Subscribe(ctx context.Context, watchers []protocol.FileSystemWatcher, fn func(ctx context.Context, events []protocol.FileEvent)) (*Subscription, error) {
+	sub := &Subscription{m: m, fn: fn, watchers: watchers}
+	for _, w := range watchers {
+		matcher, err := glob.New(w.GlobPattern, m.cs)
+		if err != nil {
+			return nil, fmt.Errorf("watch: pattern %q: %w", w.GlobPattern.Pattern, err)
+		}
+		sub.matchers = append(sub.matchers, matcher)
+	}
+	m.mu.Lock()
+	m.subs[sub] = true
+	m.mu.Unlock()
+	if err := m.sync(ctx); err != nil {
+		m.mu.Lock()
+		delete(m.subs, sub)
+		m.mu.Unlock()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Watch subscribes fn to the events of files matching pattern, such as
+// "**/*.go", of every kind.
+func (m *Manager) Watch(ctx context.Context, pattern string, fn func(ctx context.Context, events []protocol.FileEvent)) (*Subscription, error) {
+	return m.Subscribe(ctx, []protocol.FileSystemWatcher{{GlobPattern: protocol.GlobPattern{Pattern: pattern}}}, fn)
+}
+
+// Close ends the subscription, shrinking the registration if that allows.
+func (s *Subscription) Close(ctx context.Context) error {
+	s.m.mu.Lock()
+	delete(s.m.subs, s)
+	s.m.mu.Unlock()
+	return s.m.sync(ctx)
+}
+
+// Watchers returns the minimal set currently registered with the client.
+func (m *Manager) Watchers() []protocol.FileSystemWatcher {
+	m.syncMu.Lock()
+	defer m.syncMu.Unlock()
+	return append([]protocol.FileSystemWatcher(nil), m.watchers...)
+}
+
+// sync brings the client's registration in line with the subscriptions. The
+// new registration is made before the old is removed, so no event is lost
+// in between.
+func (m *Manager) sync(ctx context.Context) error {
+	m.syncMu.Lock()
+	defer m.syncMu.Unlock()
+
+	m.mu.Lock()
+	var all []protocol.FileSystemWatcher
+	for sub := range m.subs {
+		all = append(all, sub.watchers...)
+	}
+	m.mu.Unlock()
+	want := Minimize(all)
+	if equal(want, m.watchers) {
+		return nil
+	}
+	if m.polling() {
+		m.watchers = want
+		m.setPolling(len(want) > 0)
+		return nil
+	}
+
+	old := m.registered
+	if len(want) > 0 {
+		m.seq++
+		id := fmt.Sprintf("watch/%d", m.seq)
+		err := m.caller.Call(ctx, "client/registerCapability", &protocol.RegistrationParams{
+			Registrations: []protocol.Registration{{
+				ID:              id,
+				Method:          Method,
+				RegisterOptions: &protocol.DidChangeWatchedFilesRegistrationOptions{Watchers: want},
+			}},
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("watch: registering watchers: %w", err)
+		}
+		m.registered = id
+	} else {
+		m.registered = ""
+	}
+	m.watchers = want
+	if old == "" {
+		return nil
+	}
+	err := m.caller.Call(ctx, "client/unregisterCapability", &protocol.UnregistrationParams{
+		Unregisterations: []protocol.Unregistration{{ID: old, Method: Method}},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("watch: unregistering watchers: %w", err)
+	}
+	return nil
+}
+
+// polling reports whether the Manager watches files itself.
+func (m *Manager) polling() bool {
+	if m.fallback == nil {
+		return false
+	}
+	s, ok := m.caller.(Supporter)
+	return ok && !s.ClientSupports(dynamicCapability)
+}
+
+// setPolling starts or stops the fallback poller, with syncMu held. It
+// doesn't wait for the poller to stop, as a subscriber handling its events
+// may be the one closing the last subscription.
+func (m *Manager) setPolling(on bool) {
+	switch {
+	case on && m.pollStop == nil:
+		m.pollStop = make(chan struct{})
+		go m.poll(m.fallback, m.pollStop)
+	case !on && m.pollStop != nil:
+		close(m.pollStop)
+		m.pollStop = nil
+	}
+}
+
+// Stop stops the fallback poller, as when the session ends; subscriptions
+// made after it start it again.
+func (m *Manager) Stop() {
+	m.syncMu.Lock()
+	defer m.syncMu.Unlock()
+	m.setPolling(false)
+	m.watchers = nil
+}
+
+// DidChangeWatchedFiles hands each subscriber the events matching its
+// watchers, in the order the client sent them.
+func (m *Manager) DidChangeWatchedFiles(ctx context.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	m.mu.Lock()
+	subs := make([]*Subscription, 0, len(m.subs))
+	for sub := range m.subs {
+		subs = append(subs, sub)
+	}
+	m.mu.Unlock()
+
+	for _, sub := range subs {
+		var events []protocol.FileEvent
+		for _, event := range params.Changes {
+			if sub.wants(event) {
+				events = append(events, event)
+			}
+		}
+		if len(events) > 0 {
+			sub.fn(ctx, events)
+		}
+	}
+	return nil
+}
+
+func (s *Subscription) wants(event protocol.FileEvent) bool {
+	kind := protocol.WatchKind(1) << (event.Type - 1)
+	path := glob.Path(string(event.URI))
+	for idx, w := range s.watchers {
+		if kindOf(w)&kind != 0 && s.matchers[idx].Match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func kindOf(w protocol.FileSystemWatcher) protocol.WatchKind {
+	if w.Kind == 0 {
+		return protocol.WatchAll
+	}
+	return w.Kind
+}
+
+// Minimize returns a smaller set of watchers reporting at least the events
+// of the given ones: duplicates are combined, watchers another already
+// covers are dropped, and patterns differing only in their last segment
+// are merged into one {a,b} alternative. Covering is judged conservatively,
+// from the patterns' structure.
+func Minimize(watchers []protocol.FileSystemWatcher) []protocol.FileSystemWatcher {
+	kinds := map[protocol.GlobPattern]protocol.WatchKind{}
+	for _, w := range watchers {
+		kinds[w.GlobPattern] |= kindOf(w)
+	}
+
+	var kept []protocol.FileSystemWatcher
+	for glob, kind := range kinds {
+		covered := false
+		for other, otherKind := range kinds {
+			if other == glob || otherKind&kind != kind || other.BaseURI != glob.BaseURI || !covers(other.Pattern, glob.Pattern) {
+				continue
+			}
+			// Of two equivalent watchers, keep the first by pattern.
+			equivalent := kind == otherKind && covers(glob.Pattern, other.Pattern)
+			if !equivalent || other.Pattern < glob.Pattern {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			kept = append(kept, protocol.FileSystemWatcher{GlobPattern: glob, Kind: kind})
+		}
+	}
+
+	// Merge the last segments of patterns whose base, directory part and
+	// kind agree.
+	type group struct {
+		base protocol.URI
+		dir  string
+		kind protocol.WatchKind
+	}
+	groups := map[group][]string{}
+	var out []protocol.FileSystemWatcher
+	for _, w := range kept {
+		dir, last := splitLast(w.GlobPattern.Pattern)
+		if strings.ContainsAny(last, "{},") {
+			out = append(out, w)
+			continue
+		}
+		key := group{w.GlobPattern.BaseURI, dir, w.Kind}
+		groups[key] = append(groups[key], last)
+	}
+	for key, lasts := range groups {
+		sort.Strings(lasts)
+		pattern := key.dir + lasts[0]
+		if len(lasts) > 1 {
+			pattern = key.dir + "{" + strings.Join(lasts, ",") + "}"
+		}
+		out = append(out, protocol.FileSystemWatcher{
+			GlobPattern: protocol.GlobPattern{BaseURI: key.base, Pattern: pattern},
+			Kind:        key.kind,
+		})
+	}
+
+	for idx := range out {
+		if out[idx].Kind == protocol.WatchAll {
+			out[idx].Kind = 0
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].GlobPattern, out[j].GlobPattern
+		if a.BaseURI != b.BaseURI {
+			return a.BaseURI < b.BaseURI
+		}
+		if a.Pattern != b.Pattern {
+			return a.Pattern < b.Pattern
+		}
+		return out[i].Kind < out[j].Kind
+	})
+	return out
+}
+
+// covers reports whether every path pattern inner matches is certainly
+// matched by outer. Only the shapes watchers commonly take are recognised:
+// everything, everything below a literal directory, and a last segment
+// anywhere.
+func covers(outer, inner string) bool {
+	switch {
+	case outer == "**" || outer == "**/*":
+		return true
+	case strings.HasSuffix(outer, "/**") || strings.HasSuffix(outer, "/**/*"):
+		dir := outer[:strings.LastIndex(outer, "/**")+1]
+		return !hasMeta(dir) && strings.HasPrefix(inner, dir)
+	case strings.HasPrefix(outer, "**/") && !strings.Contains(outer[3:], "/"):
+		_, last := splitLast(inner)
+		return last == outer[3:]
+	}
+	return false
+}
+
+func splitLast(pattern string) (dir, last string) {
+	idx := strings.LastIndex(pattern, "/")
+	return pattern[:idx+1], pattern[idx+1:]
+}
+
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[]{}")
+}
+
+func equal(a, b []protocol.FileSystemWatcher) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
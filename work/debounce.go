@@ -0,0 +1,304 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/clock"
+	"github.com/pentops/lsplib/fscase"
+	"github.com/pentops/lsplib/protocol"
+	"github.com/pentops/lsplib/textsync"
+)
+
+// DebounceOptions configures a Debouncer.
+type DebounceOptions struct {
+	// Delay is the quiet period after a document's last change before it
+	// is run, 200ms when zero. Each change restarts the wait, up to
+	// MaxDelay, 1s when zero, after the first change not yet run.
+	Delay    time.Duration
+	MaxDelay time.Duration
+
+	// Case controls URI comparison.
+	Case fscase.Sensitivity
+
+	// OnError is told about runs which fail, other than by cancellation.
+	OnError func(uri protocol.DocumentURI, err error)
+
+	Clock clock.Clock
+}
+
+// DebounceStats are a Debouncer's counters, for telling whether analysis
+// keeps up with typing.
+type DebounceStats struct {
+	// Pending documents are waiting out their delay, and Running ones
+	// being run, a superseded run until it returns.
+	Pending int
+	Running int
+
+	// Scheduled counts changes, Coalesced those folded into a change
+	// already waiting, and Superseded the runs cancelled by a change.
+	Scheduled  uint64
+	Coalesced  uint64
+	Superseded uint64
+	Runs       uint64
+	Failures   uint64
+
+	// MaxLag is the longest a change has waited for its run to start.
+	MaxLag time.Duration
+}
+
+// Debouncer runs a task for each document once changes to it have quieted,
+// so a burst of didChange notifications is analysed once, after the last.
+// A change while the document's run is in progress cancels the run, whose
+// result would be stale, and the next run starts only once it has returned,
+// so a document never has two at once and they finish in order. It is safe
+// for concurrent use.
+type Debouncer struct {
+	run   func(ctx context.Context, uri protocol.DocumentURI) error
+	opts  DebounceOptions
+	clock clock.Clock
+	ctx   context.Context
+	stop  context.CancelFunc
+
+	mu     sync.Mutex
+	docs   map[string]*debounced
+	stats  DebounceStats
+	closed bool
+}
+
+// debounced is a document with work pending or running.
+type debounced struct {
+	key string
+	uri protocol.DocumentURI
+	// timer is armed while a change waits out the delay; first is when the
+	// first change not yet run was scheduled.
+	timer clock.Timer
+	first time.Time
+	// cancel is set while a run is in progress; again is set when the
+	// delay expired before a superseded run returned, which starts the
+	// next as soon as it does.
+	cancel context.CancelFunc
+	again  bool
+	// idle is closed once the document has nothing pending or running.
+	idle chan struct{}
+}
+
+// NewDebouncer returns a Debouncer running run for each document changed.
+func NewDebouncer(run func(ctx context.Context, uri protocol.DocumentURI) error, opts DebounceOptions) *Debouncer {
+	if opts.Delay <= 0 {
+		opts.Delay = 200 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = time.Second
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &Debouncer{
+		run:   run,
+		opts:  opts,
+		clock: clock.Or(opts.Clock),
+		ctx:   ctx,
+		stop:  stop,
+		docs:  map[string]*debounced{},
+	}
+}
+
+// Track schedules a document whenever store opens or changes it, and
+// cancels its work when it is closed. Call it before serving.
+func (d *Debouncer) Track(store *textsync.Store) {
+	store.OnEvent(func(ctx context.Context, event textsync.Event) {
+		switch event.Kind {
+		case textsync.Opened, textsync.Changed:
+			d.Schedule(event.Document.URI)
+		case textsync.Closed:
+			d.Cancel(event.Document.URI)
+		}
+	})
+}
+
+// Schedule records a change to uri, which is run once the delay passes
+// without another. A run in progress is cancelled.
+func (d *Debouncer) Schedule(uri protocol.DocumentURI) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.stats.Scheduled++
+	doc := d.doc(uri)
+	if doc.cancel != nil {
+		doc.cancel()
+		d.stats.Superseded++
+	}
+	now := d.clock.Now()
+	switch {
+	case doc.timer == nil && !doc.again:
+		doc.first = now
+		doc.timer = d.clock.AfterFunc(d.opts.Delay, func() { d.fire(doc) })
+	case doc.timer == nil:
+		// Already due, waiting for the superseded run to return.
+		d.stats.Coalesced++
+	default:
+		d.stats.Coalesced++
+		if now.Add(d.opts.Delay).Sub(doc.first) <= d.opts.MaxDelay {
+			doc.timer.Reset(d.opts.Delay)
+		}
+	}
+}
+
+// Flush runs uri's pending change now rather than after the delay.
+func (d *Debouncer) Flush(uri protocol.DocumentURI) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	doc, ok := d.docs[d.opts.Case.URIKey(string(uri))]
+	if !ok || doc.timer == nil || !doc.timer.Stop() {
+		return
+	}
+	d.due(doc)
+}
+
+// Cancel drops uri's pending change and cancels its run, as when the
+// document is closed.
+func (d *Debouncer) Cancel(uri protocol.DocumentURI) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	doc, ok := d.docs[d.opts.Case.URIKey(string(uri))]
+	if !ok {
+		return
+	}
+	if doc.timer != nil {
+		doc.timer.Stop()
+		doc.timer = nil
+	}
+	doc.again = false
+	if doc.cancel != nil {
+		doc.cancel()
+		return
+	}
+	d.settle(doc)
+}
+
+// Wait waits until uri has no change pending and no run in progress, as a
+// request answered from the analysis should, failing if ctx is done first.
+func (d *Debouncer) Wait(ctx context.Context, uri protocol.DocumentURI) error {
+	d.mu.Lock()
+	doc, ok := d.docs[d.opts.Case.URIKey(string(uri))]
+	d.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-doc.idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the Debouncer's counters.
+func (d *Debouncer) Stats() DebounceStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := d.stats
+	for _, doc := range d.docs {
+		if doc.timer != nil || doc.again {
+			stats.Pending++
+		}
+		if doc.cancel != nil {
+			stats.Running++
+		}
+	}
+	return stats
+}
+
+// Close drops pending changes and cancels runs in progress; changes
+// scheduled afterwards are ignored.
+func (d *Debouncer) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	d.stop()
+	for _, doc := range d.docs {
+		if doc.timer != nil {
+			doc.timer.Stop()
+			doc.timer = nil
+		}
+		doc.again = false
+		if doc.cancel == nil {
+			d.settle(doc)
+		}
+	}
+}
+
+// doc is uri's entry, created if it has none, with mu held.
+func (d *Debouncer) doc(uri protocol.DocumentURI) *debounced {
+	key := d.opts.Case.URIKey(string(uri))
+	doc, ok := d.docs[key]
+	if !ok {
+		doc = &debounced{key: key, uri: uri, idle: make(chan struct{})}
+		d.docs[key] = doc
+	}
+	return doc
+}
+
+func (d *Debouncer) fire(doc *debounced) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if doc.timer == nil {
+		// Flushed or cancelled as the timer fired.
+		return
+	}
+	d.due(doc)
+}
+
+// due starts doc's run, or has it start when the superseded one returns,
+// with mu held.
+func (d *Debouncer) due(doc *debounced) {
+	doc.timer = nil
+	if doc.cancel != nil {
+		doc.again = true
+		return
+	}
+	d.start(doc)
+}
+
+// start runs doc, with mu held.
+func (d *Debouncer) start(doc *debounced) {
+	d.stats.MaxLag = max(d.stats.MaxLag, d.clock.Now().Sub(doc.first))
+	ctx, cancel := context.WithCancel(d.ctx)
+	doc.cancel = cancel
+	go func() {
+		err := d.run(ctx, doc.uri)
+		failed := err != nil && !errors.Is(err, context.Canceled) && ctx.Err() == nil
+		cancel()
+
+		d.mu.Lock()
+		doc.cancel = nil
+		d.stats.Runs++
+		if failed {
+			d.stats.Failures++
+		}
+		switch {
+		case doc.again && !d.closed:
+			doc.again = false
+			d.start(doc)
+		case doc.timer == nil:
+			d.settle(doc)
+		}
+		d.mu.Unlock()
+
+		if failed && d.opts.OnError != nil {
+			d.opts.OnError(doc.uri, err)
+		}
+	}()
+}
+
+// settle forgets doc, which has nothing pending or running, waking those
+// waiting for it, with mu held.
+func (d *Debouncer) settle(doc *debounced) {
+	if d.docs[doc.key] == doc {
+		delete(d.docs, doc.key)
+	}
+	close(doc.idle)
+}
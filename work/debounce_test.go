@@ -0,0 +1,149 @@
+package work
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pentops/lsplib/lsptest"
+	"github.com/pentops/lsplib/protocol"
+)
+
+const doc protocol.DocumentURI = "file:///a.go"
+
+// run is a Debouncer task reporting when each run starts; a blocking one
+// then waits to be cancelled.
+type run struct {
+	clock   *lsptest.FakeClock
+	start   time.Time
+	started chan time.Duration
+	block   bool
+}
+
+func newRun(block bool) *run {
+	c := lsptest.NewFakeClock(time.Time{})
+	return &run{clock: c, start: c.Now(), started: make(chan time.Duration, 8), block: block}
+}
+
+func (r *run) run(ctx context.Context, _ protocol.DocumentURI) error {
+	r.started <- r.clock.Now().Sub(r.start)
+	if r.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+// next waits for the next run to start, returning when it did on the
+// clock.
+func (r *run) next(t *testing.T) time.Duration {
+	t.Helper()
+	select {
+	case at := <-r.started:
+		return at
+	case <-time.After(5 * time.Second):
+		t.Fatal("no run started")
+		return 0
+	}
+}
+
+func (r *run) none(t *testing.T) {
+	t.Helper()
+	select {
+	case at := <-r.started:
+		t.Fatalf("a run started at %v", at)
+	default:
+	}
+}
+
+func TestDebouncerCoalesces(t *testing.T) {
+	r := newRun(false)
+	d := NewDebouncer(r.run, DebounceOptions{Clock: r.clock})
+	defer d.Close()
+	for range 3 {
+		d.Schedule(doc)
+		r.clock.Advance(100 * time.Millisecond)
+	}
+	r.clock.Advance(99 * time.Millisecond)
+	r.none(t)
+	r.clock.Advance(time.Millisecond)
+	if at := r.next(t); at != 400*time.Millisecond {
+		t.Fatalf("ran at %v, want 400ms, the delay after the last change", at)
+	}
+	if err := d.Wait(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	r.none(t)
+	if stats := d.Stats(); stats.Scheduled != 3 || stats.Coalesced != 2 || stats.Runs != 1 || stats.MaxLag != 400*time.Millisecond {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestDebouncerMaxDelay(t *testing.T) {
+	r := newRun(false)
+	d := NewDebouncer(r.run, DebounceOptions{Clock: r.clock})
+	defer d.Close()
+	// Changes every 150ms keep restarting the 200ms wait, until restarting
+	// it would pass a second after the first.
+	for range 7 {
+		d.Schedule(doc)
+		r.clock.Advance(150 * time.Millisecond)
+	}
+	r.next(t)
+	if err := d.Wait(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	// The run reads the clock once it has moved on; the lag is when it
+	// started.
+	if stats := d.Stats(); stats.Runs != 1 || stats.MaxLag != 950*time.Millisecond {
+		t.Fatalf("stats = %+v, want one run started at 950ms", stats)
+	}
+}
+
+func TestDebouncerSupersedesRun(t *testing.T) {
+	r := newRun(true)
+	d := NewDebouncer(r.run, DebounceOptions{Clock: r.clock})
+	defer d.Close()
+	d.Schedule(doc)
+	r.clock.Advance(200 * time.Millisecond)
+	r.next(t)
+	// A change cancels the run in progress, and the next starts the delay
+	// after it.
+	d.Schedule(doc)
+	r.clock.Advance(199 * time.Millisecond)
+	r.none(t)
+	r.clock.Advance(time.Millisecond)
+	if at := r.next(t); at != 400*time.Millisecond {
+		t.Fatalf("the next run started at %v, want 400ms", at)
+	}
+	if stats := d.Stats(); stats.Superseded != 1 || stats.Running != 1 {
+		t.Fatalf("stats = %+v, want one run superseded and one running", stats)
+	}
+	d.Cancel(doc)
+	if err := d.Wait(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if stats := d.Stats(); stats.Runs != 2 || stats.Failures != 0 {
+		t.Fatalf("stats = %+v, want two runs, and no failures for those cancelled", stats)
+	}
+}
+
+func TestDebouncerFlush(t *testing.T) {
+	r := newRun(false)
+	d := NewDebouncer(r.run, DebounceOptions{Clock: r.clock})
+	defer d.Close()
+	d.Schedule(doc)
+	r.clock.Advance(50 * time.Millisecond)
+	d.Flush(doc)
+	if at := r.next(t); at != 50*time.Millisecond {
+		t.Fatalf("ran at %v, want 50ms, when flushed", at)
+	}
+	if err := d.Wait(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.clock.Pending(); n != 0 {
+		t.Fatalf("%d timers still armed after the flush", n)
+	}
+	r.clock.Advance(time.Second)
+	r.none(t)
+}
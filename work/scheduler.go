@@ -0,0 +1,302 @@
+// Package work schedules background analysis: a Scheduler runs jobs so the
+// documents the user is looking at are handled first, and a Debouncer runs
+// a document's analysis once its changes quiet down.
+package work
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pentops/lsplib/focus"
+	"github.com/pentops/lsplib/protocol"
+)
+
+// Attention ranks documents by user interaction. *focus.Tracker implements
+// it.
+type Attention interface {
+	Priority(uri protocol.DocumentURI) focus.Priority
+	LastSeen(uri protocol.DocumentURI) time.Time
+}
+
+// Job is a unit of background work.
+type Job struct {
+	// Key identifies the work, e.g. "parse:"+uri. Submitting a job with the
+	// key of a pending one replaces it, and cancels a running one as stale.
+	Key string
+
+	// URI is the document the job is for, ranking it. Workspace-wide jobs
+	// leave it empty and run at focus.Background.
+	URI protocol.DocumentURI
+
+	// Run does the work. It must return promptly once ctx is done: the job
+	// may be preempted, in which case it is queued again and re-run later.
+	Run func(ctx context.Context) error
+}
+
+// Options configures a Scheduler.
+type Options struct {
+	// Workers is the number of jobs run at once, GOMAXPROCS when zero.
+	Workers int
+
+	// Attention ranks jobs; without it jobs run in submission order. If it
+	// also has an OnFocusChange(func(protocol.DocumentURI)) method, as
+	// *focus.Tracker does, the queue is re-ranked whenever focus moves.
+	Attention Attention
+
+	// OnError is told about jobs which fail, other than by cancellation.
+	OnError func(job Job, err error)
+}
+
+// Scheduler runs jobs focused document first, then visible and open
+// documents by recency, then the rest of the workspace. When focus changes
+// mid-run, lower-ranked running jobs are preempted to make room.
+type Scheduler struct {
+	opts Options
+
+	mu      sync.Mutex
+	pending jobHeap
+	byKey   map[string]*item
+	running map[*item]struct{}
+	seq     uint64
+	wake    chan struct{}
+}
+
+type item struct {
+	job   Job
+	seq   uint64
+	prio  focus.Priority
+	seen  time.Time
+	index int
+
+	cancel     context.CancelFunc
+	preempted  bool
+	superseded bool
+}
+
+// NewScheduler creates a Scheduler. Jobs are accepted immediately and start
+// once Run is called.
+func NewScheduler(opts Options) *Scheduler {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	s := &Scheduler{
+		opts:    opts,
+		byKey:   map[string]*item{},
+		running: map[*item]struct{}{},
+		wake:    make(chan struct{}, 1),
+	}
+	if notifier, ok := opts.Attention.(interface {
+		OnFocusChange(func(protocol.DocumentURI))
+	}); ok {
+		notifier.OnFocusChange(func(protocol.DocumentURI) { s.Reprioritize() })
+	}
+	return s
+}
+
+// Submit queues a job.
+func (s *Scheduler) Submit(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job.Key != "" {
+		if old, ok := s.byKey[job.Key]; ok {
+			if old.index >= 0 {
+				heap.Remove(&s.pending, old.index)
+			} else {
+				old.superseded = true
+				old.cancel()
+			}
+		}
+	}
+	s.seq++
+	it := &item{job: job, seq: s.seq}
+	s.rank(it)
+	heap.Push(&s.pending, it)
+	if job.Key != "" {
+		s.byKey[job.Key] = it
+	}
+	s.rebalance()
+	s.signal()
+}
+
+// Len is the number of pending jobs.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Reprioritize re-ranks queued and running jobs, preempting running jobs
+// which now rank below pending ones.
+func (s *Scheduler) Reprioritize() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, it := range s.pending {
+		s.rank(it)
+	}
+	heap.Init(&s.pending)
+	for it := range s.running {
+		s.rank(it)
+	}
+	s.rebalance()
+}
+
+func (s *Scheduler) rank(it *item) {
+	if s.opts.Attention == nil || it.job.URI == "" {
+		it.prio, it.seen = focus.Background, time.Time{}
+		return
+	}
+	it.prio = s.opts.Attention.Priority(it.job.URI)
+	it.seen = s.opts.Attention.LastSeen(it.job.URI)
+}
+
+// rebalance preempts running jobs while a pending job outranks them and no
+// worker is free for it. Each preempted job frees one worker, so the k-th
+// best pending job is compared with the k-th worst running one.
+func (s *Scheduler) rebalance() {
+	free := s.opts.Workers - len(s.running)
+	if len(s.pending) <= free || len(s.running) == 0 {
+		return
+	}
+	waiting := append([]*item(nil), s.pending...)
+	sort.Slice(waiting, func(i, j int) bool { return waiting[i].before(waiting[j]) })
+	waiting = waiting[max(free, 0):]
+
+	var victims []*item
+	for it := range s.running {
+		if !it.preempted && !it.superseded {
+			victims = append(victims, it)
+		}
+	}
+	sort.Slice(victims, func(i, j int) bool { return victims[j].before(victims[i]) })
+
+	for idx := 0; idx < len(waiting) && idx < len(victims); idx++ {
+		if waiting[idx].prio <= victims[idx].prio {
+			break
+		}
+		victims[idx].preempted = true
+		victims[idx].cancel()
+	}
+}
+
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run executes jobs until ctx is done. Jobs still pending are kept and run
+// by a later call.
+func (s *Scheduler) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for range s.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker(ctx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (s *Scheduler) worker(ctx context.Context) {
+	for {
+		it, jobCtx := s.next(ctx)
+		if it == nil {
+			return
+		}
+		err := it.job.Run(jobCtx)
+		it.cancel()
+		s.finish(ctx, it, err)
+	}
+}
+
+func (s *Scheduler) next(ctx context.Context) (*item, context.Context) {
+	for {
+		s.mu.Lock()
+		if len(s.pending) > 0 && len(s.running) < s.opts.Workers {
+			it := heap.Pop(&s.pending).(*item)
+			jobCtx, cancel := context.WithCancel(ctx)
+			it.cancel = cancel
+			s.running[it] = struct{}{}
+			more := len(s.pending) > 0
+			s.mu.Unlock()
+			if more {
+				s.signal()
+			}
+			return it, jobCtx
+		}
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *Scheduler) finish(ctx context.Context, it *item, err error) {
+	s.mu.Lock()
+	delete(s.running, it)
+	requeue := it.preempted && !it.superseded && ctx.Err() == nil
+	if requeue {
+		it.preempted = false
+		s.rank(it)
+		heap.Push(&s.pending, it)
+	} else if s.byKey[it.job.Key] == it {
+		delete(s.byKey, it.job.Key)
+	}
+	s.mu.Unlock()
+	s.signal()
+
+	if requeue || it.superseded || err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	if s.opts.OnError != nil {
+		s.opts.OnError(it.job, err)
+	}
+}
+
+// before orders items: higher priority, then more recently seen, then
+// submitted earlier.
+func (it *item) before(other *item) bool {
+	if it.prio != other.prio {
+		return it.prio > other.prio
+	}
+	if !it.seen.Equal(other.seen) {
+		return it.seen.After(other.seen)
+	}
+	return it.seq < other.seq
+}
+
+type jobHeap []*item
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x any) {
+	it := x.(*item)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	old[len(old)-1] = nil
+	it.index = -1
+	*h = old[:len(old)-1]
+	return it
+}